@@ -0,0 +1,29 @@
+-- Migration: Add win-rate breakdown to baseline strategy performance
+-- Date: 2026-10-15
+-- Description: Persist long/short and per-symbol closed-trade counts and wins per run
+-- Requires: add_baseline_strategies.sql
+
+-- ============================================================================
+-- 1. Per-side closed trades and winners
+-- ============================================================================
+ALTER TABLE baseline_strategy_performance
+ADD COLUMN long_trades INTEGER DEFAULT 0;
+
+ALTER TABLE baseline_strategy_performance
+ADD COLUMN long_wins INTEGER DEFAULT 0;
+
+ALTER TABLE baseline_strategy_performance
+ADD COLUMN short_trades INTEGER DEFAULT 0;
+
+ALTER TABLE baseline_strategy_performance
+ADD COLUMN short_wins INTEGER DEFAULT 0;
+
+-- ============================================================================
+-- 2. Per-symbol closed trades and winners (JSON object keyed by symbol)
+-- ============================================================================
+ALTER TABLE baseline_strategy_performance
+ADD COLUMN symbol_breakdown TEXT DEFAULT '{}';
+
+-- ============================================================================
+-- Migration Complete
+-- ============================================================================
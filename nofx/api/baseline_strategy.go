@@ -1,11 +1,20 @@
 package api
 
 import (
+	"encoding/csv"
+	"errors"
+	"fmt"
 	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"nofx/logger"
+	"nofx/market"
 	"nofx/store"
 )
 
@@ -32,6 +41,8 @@ type BaselineStrategyResponse struct {
 	Config          store.BaselineConfig    `json:"config"`
 	IsSystemDefault bool                    `json:"is_system_default"`
 	Stats           *store.AggregatedStats  `json:"stats,omitempty"`
+	RecentStats     *store.AggregatedStats  `json:"recent_stats,omitempty"`     // rankings only: the most recent runs
+	ReturnTrendPct  float64                 `json:"return_trend_pct,omitempty"` // rankings only: recent minus all-time avg return
 	CreatedAt       string                  `json:"created_at"`
 	UpdatedAt       string                  `json:"updated_at"`
 }
@@ -43,9 +54,14 @@ func (s *Server) handleListBaselineStrategies(c *gin.Context) {
 		userID = "default"
 	}
 
+	filter, ok := baselinePerformanceFilter(c)
+	if !ok {
+		return
+	}
+
 	logger.Infof("🔍 Querying baseline strategies for user %s", userID)
 
-	strategies, err := s.store.BaselineStrategy().ListWithPerformance(userID)
+	strategies, err := s.store.BaselineStrategy().ListWithPerformance(userID, filter)
 	if err != nil {
 		logger.Errorf("❌ Failed to query baseline strategies: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -79,6 +95,11 @@ func (s *Server) handleGetBaselineStrategy(c *gin.Context) {
 		userID = "default"
 	}
 
+	filter, ok := baselinePerformanceFilter(c)
+	if !ok {
+		return
+	}
+
 	id := c.Param("id")
 	strategy, err := s.store.BaselineStrategy().Get(userID, id)
 	if err != nil {
@@ -86,7 +107,7 @@ func (s *Server) handleGetBaselineStrategy(c *gin.Context) {
 		return
 	}
 
-	stats, _ := s.store.BaselineStrategy().GetAggregatedStats(id)
+	stats, _ := s.store.BaselineStrategy().GetAggregatedStats(id, filter)
 
 	response := BaselineStrategyResponse{
 		ID:              strategy.ID,
@@ -103,6 +124,21 @@ func (s *Server) handleGetBaselineStrategy(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
+// handleValidateBaselineConfig checks a baseline config without saving it
+func (s *Server) handleValidateBaselineConfig(c *gin.Context) {
+	var config store.BaselineConfig
+	if err := c.ShouldBindJSON(&config); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	errs := store.ValidateBaselineConfig(&config)
+	if errs == nil {
+		errs = []store.BaselineConfigError{}
+	}
+	c.JSON(http.StatusOK, gin.H{"valid": len(errs) == 0, "errors": errs})
+}
+
 // handleCreateBaselineStrategy creates a new baseline strategy
 func (s *Server) handleCreateBaselineStrategy(c *gin.Context) {
 	userID := c.GetString("user_id")
@@ -126,7 +162,7 @@ func (s *Server) handleCreateBaselineStrategy(c *gin.Context) {
 	}
 
 	if err := s.store.BaselineStrategy().Create(strategy); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeBaselineStoreError(c, err)
 		return
 	}
 
@@ -167,7 +203,7 @@ func (s *Server) handleUpdateBaselineStrategy(c *gin.Context) {
 	}
 
 	if err := s.store.BaselineStrategy().Update(strategy); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeBaselineStoreError(c, err)
 		return
 	}
 
@@ -192,6 +228,39 @@ func (s *Server) handleUpdateBaselineStrategy(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
+// handleCloneBaselineStrategy copies a baseline strategy into a new user-owned strategy
+func (s *Server) handleCloneBaselineStrategy(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		userID = "default"
+	}
+
+	id := c.Param("id")
+	if _, err := s.store.BaselineStrategy().Get(userID, id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Baseline strategy not found"})
+		return
+	}
+
+	clone, err := s.store.BaselineStrategy().Clone(userID, id, uuid.New().String())
+	if err != nil {
+		writeBaselineStoreError(c, err)
+		return
+	}
+
+	response := BaselineStrategyResponse{
+		ID:              clone.ID,
+		UserID:          clone.UserID,
+		Name:            clone.Name,
+		Description:     clone.Description,
+		Config:          clone.Config,
+		IsSystemDefault: clone.IsSystemDefault,
+		CreatedAt:       clone.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		UpdatedAt:       clone.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+	}
+
+	c.JSON(http.StatusCreated, response)
+}
+
 // handleDeleteBaselineStrategy deletes a baseline strategy
 func (s *Server) handleDeleteBaselineStrategy(c *gin.Context) {
 	userID := c.GetString("user_id")
@@ -222,6 +291,76 @@ func (s *Server) handleGetBaselinePerformance(c *gin.Context) {
 	c.JSON(http.StatusOK, performances)
 }
 
+// baselinePerformanceCSVHeader is the header line of the performance history CSV export
+var baselinePerformanceCSVHeader = []string{
+	"run_id", "symbols", "timeframe", "start", "end", "return_pct", "drawdown_pct",
+	"sharpe", "win_rate", "trades", "created_at",
+}
+
+// unsafeFilenameChars matches characters replaced in download file names
+var unsafeFilenameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// handleExportBaselinePerformanceCSV streams the performance history of a baseline strategy as CSV
+func (s *Server) handleExportBaselinePerformanceCSV(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		userID = "default"
+	}
+
+	id := c.Param("id")
+	strategy, err := s.store.BaselineStrategy().Get(userID, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Baseline strategy not found"})
+		return
+	}
+
+	filename := strings.Trim(unsafeFilenameChars.ReplaceAllString(strategy.Name, "_"), "_")
+	if filename == "" {
+		filename = strategy.ID
+	}
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-performance.csv"`, filename))
+	c.Status(http.StatusOK)
+
+	w := csv.NewWriter(c.Writer)
+	if err := w.Write(baselinePerformanceCSVHeader); err != nil {
+		return
+	}
+	err = s.store.BaselineStrategy().ForEachPerformance(id, func(perf *store.BaselineStrategyPerformance) error {
+		if err := w.Write(baselinePerformanceCSVRow(perf)); err != nil {
+			return err
+		}
+		w.Flush()
+		return w.Error()
+	})
+	w.Flush()
+	if err != nil {
+		// Headers are already sent, so the error can only be logged
+		logger.Errorf("❌ Failed to export performance of baseline strategy %s: %v", id, err)
+	}
+}
+
+// baselinePerformanceCSVRow formats one performance record in baselinePerformanceCSVHeader order
+func baselinePerformanceCSVRow(perf *store.BaselineStrategyPerformance) []string {
+	formatFloat := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+	return []string{
+		perf.RunID,
+		strings.Join(perf.Symbols, ";"),
+		perf.Timeframe,
+		time.Unix(perf.StartTS, 0).UTC().Format(time.RFC3339),
+		time.Unix(perf.EndTS, 0).UTC().Format(time.RFC3339),
+		formatFloat(perf.TotalReturnPct),
+		formatFloat(perf.MaxDrawdownPct),
+		formatFloat(perf.SharpeRatio),
+		formatFloat(perf.WinRate),
+		strconv.Itoa(perf.TotalTrades),
+		perf.CreatedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+// defaultBaselineRecentRuns is how many of the latest runs the rankings' recent stats cover
+const defaultBaselineRecentRuns = 5
+
 // handleGetBaselineRankings gets performance rankings for all baseline strategies
 func (s *Server) handleGetBaselineRankings(c *gin.Context) {
 	userID := c.GetString("user_id")
@@ -229,16 +368,46 @@ func (s *Server) handleGetBaselineRankings(c *gin.Context) {
 		userID = "default"
 	}
 
-	strategies, err := s.store.BaselineStrategy().ListWithPerformance(userID)
+	sortBy := strings.ToLower(strings.TrimSpace(c.DefaultQuery("sort_by", "avg_return")))
+	metric, ok := baselineRankingMetrics[sortBy]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid sort_by: " + sortBy})
+		return
+	}
+	order := strings.ToLower(strings.TrimSpace(c.DefaultQuery("order", "desc")))
+	if order != "asc" && order != "desc" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid order: " + order})
+		return
+	}
+	minRuns := queryInt(c, "min_runs", 0)
+	recentRuns := queryInt(c, "recent_runs", defaultBaselineRecentRuns)
+	if recentRuns <= 0 {
+		recentRuns = defaultBaselineRecentRuns
+	}
+	filter, ok := baselinePerformanceFilter(c)
+	if !ok {
+		return
+	}
+
+	strategies, err := s.store.BaselineStrategy().ListWithPerformance(userID, filter)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Sort by average return percentage (descending)
-	// Note: In production, you might want to add sorting options
+	strategies = filterBaselinesByRuns(strategies, minRuns)
+	sortBaselineRankings(strategies, metric, order == "desc")
+
 	var rankings []BaselineStrategyResponse
 	for _, strategy := range strategies {
+		var recent *store.AggregatedStats
+		if hasBaselineRuns(strategy) {
+			recent, err = s.store.BaselineStrategy().GetRecentStats(strategy.ID, recentRuns, filter)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
 		rankings = append(rankings, BaselineStrategyResponse{
 			ID:              strategy.ID,
 			UserID:          strategy.UserID,
@@ -247,6 +416,8 @@ func (s *Server) handleGetBaselineRankings(c *gin.Context) {
 			Config:          strategy.Config,
 			IsSystemDefault: strategy.IsSystemDefault,
 			Stats:           strategy.Stats,
+			RecentStats:     recent,
+			ReturnTrendPct:  store.ReturnTrendPct(recent, strategy.Stats),
 			CreatedAt:       strategy.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
 			UpdatedAt:       strategy.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
 		})
@@ -254,3 +425,98 @@ func (s *Server) handleGetBaselineRankings(c *gin.Context) {
 
 	c.JSON(http.StatusOK, rankings)
 }
+
+// baselinePerformanceFilter parses the start_ts, end_ts (unix seconds) and symbol query params
+// that restrict which runs the stats aggregate. It writes the error response on failure.
+func baselinePerformanceFilter(c *gin.Context) (store.PerformanceFilter, bool) {
+	var filter store.PerformanceFilter
+	for _, param := range []struct {
+		name string
+		dest *int64
+	}{
+		{"start_ts", &filter.StartTS},
+		{"end_ts", &filter.EndTS},
+	} {
+		value := strings.TrimSpace(c.Query(param.name))
+		if value == "" {
+			continue
+		}
+		ts, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || ts < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + param.name + ": " + value})
+			return filter, false
+		}
+		*param.dest = ts
+	}
+	if filter.StartTS > 0 && filter.EndTS > 0 && filter.EndTS <= filter.StartTS {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "end_ts must be after start_ts"})
+		return filter, false
+	}
+	if symbol := strings.TrimSpace(c.Query("symbol")); symbol != "" {
+		filter.Symbol = market.Normalize(symbol)
+	}
+	return filter, true
+}
+
+// baselineRankingMetrics maps the sort_by values of the rankings endpoint to the stat they sort on
+var baselineRankingMetrics = map[string]func(*store.AggregatedStats) float64{
+	"avg_return":   func(st *store.AggregatedStats) float64 { return st.AvgReturnPct },
+	"avg_drawdown": func(st *store.AggregatedStats) float64 { return st.AvgDrawdownPct },
+	"avg_sharpe":   func(st *store.AggregatedStats) float64 { return st.AvgSharpeRatio },
+	"avg_win_rate": func(st *store.AggregatedStats) float64 { return st.AvgWinRate },
+}
+
+// hasBaselineRuns reports whether a strategy has any recorded performance
+func hasBaselineRuns(strategy *store.BaselineStrategyWithStats) bool {
+	return strategy.Stats != nil && strategy.Stats.TotalRuns > 0
+}
+
+// filterBaselinesByRuns drops strategies with fewer than minRuns runs, so a single lucky run
+// does not top the rankings. A minRuns of 0 keeps every strategy, including untested ones.
+func filterBaselinesByRuns(strategies []*store.BaselineStrategyWithStats, minRuns int) []*store.BaselineStrategyWithStats {
+	if minRuns <= 0 {
+		return strategies
+	}
+	filtered := make([]*store.BaselineStrategyWithStats, 0, len(strategies))
+	for _, strategy := range strategies {
+		if hasBaselineRuns(strategy) && strategy.Stats.TotalRuns >= minRuns {
+			filtered = append(filtered, strategy)
+		}
+	}
+	return filtered
+}
+
+// sortBaselineRankings orders strategies by metric. Strategies without performance data sort
+// last regardless of direction; ties are broken by name and ID to keep the order stable.
+func sortBaselineRankings(strategies []*store.BaselineStrategyWithStats, metric func(*store.AggregatedStats) float64, desc bool) {
+	sort.SliceStable(strategies, func(i, j int) bool {
+		a, b := strategies[i], strategies[j]
+		aRuns, bRuns := hasBaselineRuns(a), hasBaselineRuns(b)
+		if aRuns != bRuns {
+			return aRuns
+		}
+		if aRuns {
+			if va, vb := metric(a.Stats), metric(b.Stats); va != vb {
+				if desc {
+					return va > vb
+				}
+				return va < vb
+			}
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.ID < b.ID
+	})
+}
+
+// writeBaselineStoreError reports a failed baseline strategy write: 400 with the invalid
+// fields when the config was rejected, 500 otherwise
+func writeBaselineStoreError(c *gin.Context, err error) {
+	var invalid *store.InvalidBaselineConfigError
+	if errors.As(err, &invalid) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid baseline config", "errors": invalid.Errors})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
@@ -0,0 +1,315 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"nofx/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newBaselineTestServer creates a server whose store has the baseline strategy tables,
+// which are created by the SQL migration rather than by the store itself
+func newBaselineTestServer(t *testing.T) *Server {
+	t.Helper()
+	st, err := store.New(filepath.Join(t.TempDir(), "store.db"))
+	if err != nil {
+		t.Fatalf("store.New failed: %v", err)
+	}
+	t.Cleanup(func() { st.Close() })
+
+	for _, name := range []string{"add_baseline_strategies.sql", "add_baseline_performance_breakdown.sql"} {
+		migration, err := os.ReadFile(filepath.Join("../../migrations", name))
+		if err != nil {
+			t.Fatalf("failed to read baseline migration %s: %v", name, err)
+		}
+		if _, err := st.DB().Exec(string(migration)); err != nil {
+			t.Fatalf("failed to apply baseline migration %s: %v", name, err)
+		}
+	}
+	return &Server{store: st}
+}
+
+// testBaselineConfig returns a config that passes store.ValidateBaselineConfig
+func testBaselineConfig() store.BaselineConfig {
+	return store.BaselineConfig{
+		RSIPeriod:      14,
+		MACDFast:       12,
+		MACDSlow:       26,
+		MACDSignal:     9,
+		EMAPeriod:      20,
+		StochRSIPeriod: 14,
+		ATRPeriod:      14,
+		SignalThresholds: store.BaselineSignalThresholds{
+			RSIOversold:     30,
+			RSIOverbought:   70,
+			StochOversold:   20,
+			StochOverbought: 80,
+		},
+		RiskManagement: store.BaselineRiskManagement{Leverage: 5},
+	}
+}
+
+func cloneBaseline(s *Server, userID, id string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/baseline-strategies/"+id+"/clone", nil)
+	c.Params = gin.Params{{Key: "id", Value: id}}
+	c.Set("user_id", userID)
+	s.handleCloneBaselineStrategy(c)
+	return w
+}
+
+func decodeBaselineResponse(t *testing.T, w *httptest.ResponseRecorder) BaselineStrategyResponse {
+	t.Helper()
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp BaselineStrategyResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	return resp
+}
+
+func TestCloneBaselineStrategyOfUser(t *testing.T) {
+	s := newBaselineTestServer(t)
+	config := testBaselineConfig()
+	config.RSIPeriod, config.MACDFast, config.MACDSlow = 21, 8, 21
+	source := &store.BaselineStrategy{
+		ID:     "mine",
+		UserID: "user-1",
+		Name:   "Trend",
+		Config: config,
+	}
+	if err := s.store.BaselineStrategy().Create(source); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	first := decodeBaselineResponse(t, cloneBaseline(s, "user-1", "mine"))
+	if first.ID == "mine" || first.UserID != "user-1" || first.Name != "Trend (copy)" {
+		t.Fatalf("unexpected clone: %+v", first)
+	}
+	if first.Config.RSIPeriod != 21 || first.Config.MACDFast != 8 {
+		t.Fatalf("expected config to be copied, got %+v", first.Config)
+	}
+
+	second := decodeBaselineResponse(t, cloneBaseline(s, "user-1", "mine"))
+	if second.Name != "Trend (copy 2)" || second.ID == first.ID {
+		t.Fatalf("expected a numbered name for the second clone, got %+v", second)
+	}
+
+	if w := cloneBaseline(s, "user-2", "mine"); w.Code != http.StatusNotFound {
+		t.Fatalf("expected another user's strategy to be invisible, got %d", w.Code)
+	}
+}
+
+func TestCloneSystemDefaultBaselineStrategy(t *testing.T) {
+	s := newBaselineTestServer(t)
+	source := &store.BaselineStrategy{
+		ID:              "system",
+		UserID:          "",
+		Name:            "Default",
+		Config:          testBaselineConfig(),
+		IsSystemDefault: true,
+	}
+	if err := s.store.BaselineStrategy().Create(source); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	clone := decodeBaselineResponse(t, cloneBaseline(s, "user-1", "system"))
+	if clone.UserID != "user-1" || clone.IsSystemDefault || clone.Name != "Default (copy)" {
+		t.Fatalf("expected a user-owned, non-default clone, got %+v", clone)
+	}
+
+	// The clone is editable by its owner, unlike the system default
+	edited := &store.BaselineStrategy{ID: clone.ID, UserID: "user-1", Name: "Edited", Config: clone.Config}
+	if err := s.store.BaselineStrategy().Update(edited); err != nil {
+		t.Fatalf("expected clone to be editable: %v", err)
+	}
+	if err := s.store.BaselineStrategy().Update(&store.BaselineStrategy{ID: "system", UserID: "user-1", Name: "Edited", Config: clone.Config}); err == nil {
+		t.Fatal("expected the system default to stay read-only")
+	}
+}
+
+func TestBaselineConfigValidationEndpoints(t *testing.T) {
+	s := newBaselineTestServer(t)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("user_id", "user-1") })
+	router.POST("/baseline-strategies", s.handleCreateBaselineStrategy)
+	router.POST("/baseline-strategies/validate", s.handleValidateBaselineConfig)
+	router.POST("/baseline-strategies/:id/clone", s.handleCloneBaselineStrategy)
+
+	invalid := `{"rsi_period":14,"macd_fast":26,"macd_slow":12,"macd_signal":9,"ema_period":20,"stoch_rsi_period":14,"atr_period":14,
+		"signal_thresholds":{"rsi_oversold":30,"rsi_overbought":70,"stoch_oversold":20,"stoch_overbought":80},
+		"risk_management":{"leverage":0}}`
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/baseline-strategies/validate", strings.NewReader(invalid)))
+	var result struct {
+		Valid  bool                        `json:"valid"`
+		Errors []store.BaselineConfigError `json:"errors"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatalf("failed to decode validation result: %v (%s)", err, w.Body.String())
+	}
+	if w.Code != http.StatusOK || result.Valid || len(result.Errors) != 2 {
+		t.Fatalf("expected macd_fast and leverage errors, got %d %+v", w.Code, result)
+	}
+
+	w = httptest.NewRecorder()
+	body := `{"name":"Broken","config":` + invalid + `}`
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/baseline-strategies", strings.NewReader(body)))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected create to reject an invalid config, got %d: %s", w.Code, w.Body.String())
+	}
+	if strategies, _ := s.store.BaselineStrategy().List("user-1"); len(strategies) != 0 {
+		t.Fatalf("expected nothing to be saved, got %d strategies", len(strategies))
+	}
+
+	// A config saved before validation existed cannot be copied by Clone either
+	if _, err := s.store.DB().Exec(`INSERT INTO baseline_strategies (id, user_id, name, description, config_json, is_system_default)
+		VALUES ('legacy', 'user-1', 'Legacy', '', '{"rsi_period":0}', 0)`); err != nil {
+		t.Fatalf("failed to insert legacy strategy: %v", err)
+	}
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/baseline-strategies/legacy/clone", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected clone to reject an invalid config, got %d: %s", w.Code, w.Body.String())
+	}
+	if strategies, _ := s.store.BaselineStrategy().List("user-1"); len(strategies) != 1 {
+		t.Fatalf("expected only the legacy strategy, got %d strategies", len(strategies))
+	}
+}
+
+func rankedBaselines() []*store.BaselineStrategyWithStats {
+	withStats := func(id string, runs int, ret, dd, sharpe, winRate float64) *store.BaselineStrategyWithStats {
+		return &store.BaselineStrategyWithStats{
+			BaselineStrategy: store.BaselineStrategy{ID: id, Name: id},
+			Stats: &store.AggregatedStats{
+				TotalRuns:      runs,
+				AvgReturnPct:   ret,
+				AvgDrawdownPct: dd,
+				AvgSharpeRatio: sharpe,
+				AvgWinRate:     winRate,
+			},
+		}
+	}
+	return []*store.BaselineStrategyWithStats{
+		{BaselineStrategy: store.BaselineStrategy{ID: "untested-b", Name: "untested-b"}},
+		withStats("a", 5, 10, 8, 1.2, 55),
+		{BaselineStrategy: store.BaselineStrategy{ID: "untested-a", Name: "untested-a"}, Stats: &store.AggregatedStats{}},
+		withStats("b", 1, 30, 20, 0.8, 40),
+		withStats("c", 3, -5, 4, 1.5, 60),
+	}
+}
+
+func rankingIDs(strategies []*store.BaselineStrategyWithStats) string {
+	ids := make([]string, len(strategies))
+	for i, s := range strategies {
+		ids[i] = s.ID
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestSortBaselineRankings(t *testing.T) {
+	tests := []struct {
+		sortBy string
+		desc   bool
+		want   string
+	}{
+		{"avg_return", true, "b,a,c,untested-a,untested-b"},
+		{"avg_return", false, "c,a,b,untested-a,untested-b"},
+		{"avg_drawdown", false, "c,a,b,untested-a,untested-b"},
+		{"avg_sharpe", true, "c,a,b,untested-a,untested-b"},
+		{"avg_win_rate", true, "c,a,b,untested-a,untested-b"},
+		{"avg_win_rate", false, "b,a,c,untested-a,untested-b"},
+	}
+
+	for _, tt := range tests {
+		strategies := rankedBaselines()
+		sortBaselineRankings(strategies, baselineRankingMetrics[tt.sortBy], tt.desc)
+		if got := rankingIDs(strategies); got != tt.want {
+			t.Errorf("sort_by=%s desc=%v: expected %s, got %s", tt.sortBy, tt.desc, tt.want, got)
+		}
+	}
+}
+
+func TestFilterBaselinesByRuns(t *testing.T) {
+	if got := rankingIDs(filterBaselinesByRuns(rankedBaselines(), 0)); got != "untested-b,a,untested-a,b,c" {
+		t.Fatalf("expected no filtering without min_runs, got %s", got)
+	}
+	if got := rankingIDs(filterBaselinesByRuns(rankedBaselines(), 3)); got != "a,c" {
+		t.Fatalf("expected only strategies with at least 3 runs, got %s", got)
+	}
+}
+
+func TestExportBaselinePerformanceCSV(t *testing.T) {
+	s := newBaselineTestServer(t)
+	baselines := s.store.BaselineStrategy()
+	if err := baselines.Create(&store.BaselineStrategy{ID: "trend", UserID: "user-1", Name: "Trend v2", Config: testBaselineConfig()}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	// Performance rows reference the backtest run they came from
+	if _, err := s.store.DB().Exec(`INSERT INTO backtest_runs (run_id) VALUES ('run-1')`); err != nil {
+		t.Fatalf("failed to insert backtest run: %v", err)
+	}
+	if err := baselines.SavePerformance(&store.BaselineStrategyPerformance{
+		BaselineStrategyID: "trend",
+		RunID:              "run-1",
+		Symbols:            []string{"BTCUSDT", "ETHUSDT"},
+		Timeframe:          "1h",
+		StartTS:            1_700_000_000,
+		EndTS:              1_700_086_400,
+		InitialBalance:     1000,
+		FinalEquity:        1125,
+		TotalReturnPct:     12.5,
+		MaxDrawdownPct:     4.25,
+		SharpeRatio:        1.5,
+		WinRate:            55,
+		TotalTrades:        12,
+	}); err != nil {
+		t.Fatalf("SavePerformance failed: %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("user_id", "user-1") })
+	router.GET("/baseline-strategies/:id/performance", s.handleGetBaselinePerformance)
+	router.GET("/baseline-strategies/:id/performance.csv", s.handleExportBaselinePerformanceCSV)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/baseline-strategies/trend/performance.csv", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("Content-Disposition"); got != `attachment; filename="Trend_v2-performance.csv"` {
+		t.Fatalf("unexpected Content-Disposition: %q", got)
+	}
+
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected header and one row, got %q", w.Body.String())
+	}
+	if lines[0] != "run_id,symbols,timeframe,start,end,return_pct,drawdown_pct,sharpe,win_rate,trades,created_at" {
+		t.Fatalf("unexpected header: %q", lines[0])
+	}
+	wantPrefix := "run-1,BTCUSDT;ETHUSDT,1h,2023-11-14T22:13:20Z,2023-11-15T22:13:20Z,12.5,4.25,1.5,55,12,"
+	if !strings.HasPrefix(lines[1], wantPrefix) {
+		t.Fatalf("expected row to start with %q, got %q", wantPrefix, lines[1])
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/baseline-strategies/missing/performance.csv", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown strategy, got %d", w.Code)
+	}
+}
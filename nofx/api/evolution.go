@@ -1,4 +1,359 @@
 package api
 
-// Evolution API handlers will be implemented here
-// This is a placeholder file for the evolution module
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"nofx/backtest"
+	"nofx/evotypes"
+	"nofx/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// evolutionStreamInterval is how often the evolution stream polls for changes
+const evolutionStreamInterval = time.Second
+
+func (s *Server) registerEvolutionRoutes(router *gin.RouterGroup) {
+	router.GET("/:id/iterations", s.handleListEvolutionIterations)
+	router.GET("/:id/iterations/:version", s.handleEvolutionIterationDetail)
+	router.GET("/:id/iterations/:version/equity", s.handleEvolutionIterationEquity)
+	router.GET("/:id/iterations/:version/decisions", s.handleEvolutionIterationDecisions)
+	router.GET("/:id/compare", s.handleCompareEvolutionIterations)
+	router.GET("/:id/stream", s.handleEvolutionStream)
+	router.DELETE("/:id", s.handleDeleteEvolution)
+	router.POST("/:id/restore", s.handleRestoreEvolution)
+	router.GET("/:id/export", s.handleExportEvolution)
+	router.POST("/import", s.handleImportEvolution)
+}
+
+// handleDeleteEvolution soft-deletes an evolution so it can still be restored
+func (s *Server) handleDeleteEvolution(c *gin.Context) {
+	evolution, ok := s.userEvolution(c)
+	if !ok {
+		return
+	}
+	if evolution.Status == evotypes.StatusRunning {
+		c.JSON(http.StatusConflict, gin.H{"error": "stop the evolution before deleting it"})
+		return
+	}
+
+	if err := s.store.Evolution().Delete(evolution.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Evolution deleted"})
+}
+
+// handleRestoreEvolution restores a soft-deleted evolution
+func (s *Server) handleRestoreEvolution(c *gin.Context) {
+	userID := c.GetString("user_id")
+	evolutionID := c.Param("id")
+
+	if err := s.store.Evolution().Restore(userID, evolutionID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "deleted evolution not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Evolution restored"})
+}
+
+// handleExportEvolution returns an evolution with its iterations and strategies as a JSON bundle
+func (s *Server) handleExportEvolution(c *gin.Context) {
+	evolution, ok := s.userEvolution(c)
+	if !ok {
+		return
+	}
+
+	bundle, err := s.store.Evolution().ExportEvolution(evolution.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="evolution-%s.json"`, evolution.ID))
+	c.JSON(http.StatusOK, bundle)
+}
+
+// handleImportEvolution recreates an exported evolution bundle for the user
+func (s *Server) handleImportEvolution(c *gin.Context) {
+	var bundle store.EvolutionBundle
+	if err := c.ShouldBindJSON(&bundle); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid evolution bundle: " + err.Error()})
+		return
+	}
+	if bundle.Evolution == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid evolution bundle: missing evolution"})
+		return
+	}
+
+	evolution, err := s.store.Evolution().ImportEvolution(c.GetString("user_id"), &bundle)
+	if errors.Is(err, store.ErrInvalidEvolution) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, evolution)
+}
+
+// handleEvolutionStream handles SSE streaming of live evolution progress
+func (s *Server) handleEvolutionStream(c *gin.Context) {
+	var backtestStatus func(runID string) *backtest.StatusPayload
+	if s.backtestManager != nil {
+		backtestStatus = s.backtestManager.Status
+	}
+	s.streamEvolution(c, backtestStatus, evolutionStreamInterval)
+}
+
+// streamEvolution polls the evolution and pushes its state transitions ("state"), the progress
+// of the running backtest ("progress") and newly completed iterations ("iteration") as
+// Server-Sent Events. It returns when the evolution has finished or the client disconnects.
+// Each poll only loads the iterations from the first one that has not settled yet on.
+func (s *Server) streamEvolution(c *gin.Context, backtestStatus func(runID string) *backtest.StatusPayload, interval time.Duration) {
+	userID := c.GetString("user_id")
+	evolutionID := c.Param("id")
+	if _, ok := s.userEvolution(c); !ok {
+		return
+	}
+
+	// Set SSE headers
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+
+	var (
+		lastStatus    string
+		lastIteration = -1
+		lastRunID     string
+		lastProgress  = -1.0
+		completed     map[int]bool
+		settled       int // Leading iterations that are finished and no longer polled
+	)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	clientGone := c.Request.Context().Done()
+	for {
+		evolution, err := s.store.Evolution().Get(userID, evolutionID)
+		if err != nil {
+			writeEvolutionEvent(c, "error", gin.H{"error": err.Error()})
+			return
+		}
+		if evolution.Status != lastStatus || evolution.CurrentIteration != lastIteration {
+			lastStatus, lastIteration = evolution.Status, evolution.CurrentIteration
+			writeEvolutionEvent(c, "state", evolution)
+		}
+
+		iterations, _, err := s.store.Evolution().GetIterationsPaged(evolutionID, 0, settled)
+		if err != nil {
+			writeEvolutionEvent(c, "error", gin.H{"error": err.Error()})
+			return
+		}
+		// Iterations completed before the client connected are part of the initial state
+		initial := completed == nil
+		if initial {
+			completed = make(map[int]bool)
+		}
+		for _, iter := range iterations {
+			if iter.Status != evotypes.IterStatusCompleted || completed[iter.Version] {
+				continue
+			}
+			completed[iter.Version] = true
+			if !initial {
+				writeEvolutionEvent(c, "iteration", iter)
+			}
+		}
+		// Candidates of a generation finish out of order, so only a finished prefix is skipped
+		for _, iter := range iterations {
+			if !iterationSettled(iter.Status) {
+				break
+			}
+			settled++
+			delete(completed, iter.Version)
+		}
+
+		if n := len(iterations); n > 0 && backtestStatus != nil {
+			current := iterations[n-1]
+			if current.Status == evotypes.IterStatusBacktest && current.BacktestRunID != "" {
+				if payload := backtestStatus(current.BacktestRunID); payload != nil &&
+					(payload.ProgressPct != lastProgress || current.BacktestRunID != lastRunID) {
+					lastRunID, lastProgress = current.BacktestRunID, payload.ProgressPct
+					writeEvolutionEvent(c, "progress", payload)
+				}
+			}
+		}
+
+		if evolution.Status == evotypes.StatusCompleted || evolution.Status == evotypes.StatusStopped {
+			return
+		}
+
+		select {
+		case <-clientGone:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// iterationSettled reports whether an iteration has finished and its record no longer changes
+func iterationSettled(status string) bool {
+	switch status {
+	case evotypes.IterStatusCompleted, evotypes.IterStatusFailed, evotypes.IterStatusStalled:
+		return true
+	}
+	return false
+}
+
+// writeEvolutionEvent writes one Server-Sent Event and flushes it to the client
+func writeEvolutionEvent(c *gin.Context, event string, data interface{}) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return
+	}
+	c.Writer.Write([]byte(fmt.Sprintf("event: %s\ndata: %s\n\n", event, payload)))
+	c.Writer.Flush()
+}
+
+// handleListEvolutionIterations returns a page of an evolution's iterations
+func (s *Server) handleListEvolutionIterations(c *gin.Context) {
+	evolutionID := c.Param("id")
+	if _, ok := s.userEvolution(c); !ok {
+		return
+	}
+
+	limit := queryInt(c, "limit", 50)
+	offset := queryInt(c, "offset", 0)
+	if limit <= 0 {
+		limit = 50
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	iterations, total, err := s.store.Evolution().GetIterationsPaged(evolutionID, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"total": total,
+		"items": iterations,
+	})
+}
+
+// handleCompareEvolutionIterations compares the iterations given by the a and b query params
+func (s *Server) handleCompareEvolutionIterations(c *gin.Context) {
+	versionA, errA := strconv.Atoi(c.Query("a"))
+	versionB, errB := strconv.Atoi(c.Query("b"))
+	if errA != nil || errB != nil || versionA <= 0 || versionB <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "a and b must be iteration versions"})
+		return
+	}
+	evolution, ok := s.userEvolution(c)
+	if !ok {
+		return
+	}
+
+	comparison, err := s.store.Evolution().GetIterationComparison(evolution.ID, versionA, versionB)
+	if writeEvolutionIterationError(c, err) {
+		return
+	}
+	c.JSON(http.StatusOK, comparison)
+}
+
+// handleEvolutionIterationDetail returns one evolution iteration with its parsed evaluation,
+// prompt diff, decision samples and equity curve
+func (s *Server) handleEvolutionIterationDetail(c *gin.Context) {
+	evolutionID, version, ok := s.evolutionIterationParams(c)
+	if !ok {
+		return
+	}
+
+	detail, err := s.store.Evolution().GetIterationDetail(evolutionID, version)
+	if writeEvolutionIterationError(c, err) {
+		return
+	}
+	c.JSON(http.StatusOK, detail)
+}
+
+// handleEvolutionIterationEquity returns the stored equity curve of one evolution iteration
+func (s *Server) handleEvolutionIterationEquity(c *gin.Context) {
+	evolutionID, version, ok := s.evolutionIterationParams(c)
+	if !ok {
+		return
+	}
+
+	points, err := s.store.Evolution().GetIterationEquityCurve(evolutionID, version)
+	if writeEvolutionIterationError(c, err) {
+		return
+	}
+	c.JSON(http.StatusOK, points)
+}
+
+// handleEvolutionIterationDecisions returns the sampled decisions of one evolution iteration
+func (s *Server) handleEvolutionIterationDecisions(c *gin.Context) {
+	evolutionID, version, ok := s.evolutionIterationParams(c)
+	if !ok {
+		return
+	}
+
+	samples, err := s.store.Evolution().GetIterationDecisionSamples(evolutionID, version)
+	if writeEvolutionIterationError(c, err) {
+		return
+	}
+	c.JSON(http.StatusOK, samples)
+}
+
+// evolutionIterationParams parses the evolution ID and iteration version from the path and
+// checks that the evolution belongs to the user. It writes the error response on failure.
+func (s *Server) evolutionIterationParams(c *gin.Context) (string, int, bool) {
+	evolutionID := c.Param("id")
+
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil || version <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid version"})
+		return "", 0, false
+	}
+
+	if _, ok := s.userEvolution(c); !ok {
+		return "", 0, false
+	}
+	return evolutionID, version, true
+}
+
+// userEvolution loads the evolution named by the id path param if it belongs to the user.
+// It writes the error response on failure.
+func (s *Server) userEvolution(c *gin.Context) (*evotypes.Evolution, bool) {
+	evolution, err := s.store.Evolution().Get(c.GetString("user_id"), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "evolution not found"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return evolution, true
+}
+
+func writeEvolutionIterationError(c *gin.Context, err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "iteration not found"})
+		return true
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	return true
+}
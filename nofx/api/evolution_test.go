@@ -0,0 +1,325 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"nofx/autoevolver"
+	"nofx/backtest"
+	"nofx/evotypes"
+	"nofx/mcp"
+	"nofx/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newEvolutionTestServer(t *testing.T) *Server {
+	t.Helper()
+	st, err := store.New(filepath.Join(t.TempDir(), "store.db"))
+	if err != nil {
+		t.Fatalf("store.New failed: %v", err)
+	}
+	t.Cleanup(func() { st.Close() })
+
+	if err := st.Evolution().Create(&evotypes.Evolution{
+		ID:             "evo-1",
+		UserID:         "user-1",
+		Name:           "evo",
+		BaseStrategyID: "base",
+		Status:         evotypes.StatusRunning,
+		MaxIterations:  1,
+		Config:         "{}",
+	}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := st.Evolution().CreateIteration(&evotypes.Iteration{
+		EvolutionID:   "evo-1",
+		Version:       1,
+		StrategyID:    "base",
+		BacktestRunID: "run-1",
+		Status:        evotypes.IterStatusBacktest,
+	}); err != nil {
+		t.Fatalf("CreateIteration failed: %v", err)
+	}
+	return &Server{store: st}
+}
+
+func newEvolutionStreamContext(ctx context.Context, userID string) (*gin.Context, *httptest.ResponseRecorder) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/evolutions/evo-1/stream", nil).WithContext(ctx)
+	c.Params = gin.Params{{Key: "id", Value: "evo-1"}}
+	c.Set("user_id", userID)
+	return c, w
+}
+
+func TestEvolutionStreamEmitsProgressAndCompletion(t *testing.T) {
+	s := newEvolutionTestServer(t)
+	evolutions := s.store.Evolution()
+
+	// The fake backtest advances on every poll; once done the evolution finishes
+	progress := 0.0
+	backtestStatus := func(runID string) *backtest.StatusPayload {
+		progress += 50
+		if progress >= 100 {
+			evolutions.UpdateIterationStatus("evo-1", 1, evotypes.IterStatusCompleted)
+			evolutions.UpdateStatus("evo-1", evotypes.StatusCompleted)
+		}
+		return &backtest.StatusPayload{RunID: runID, State: backtest.RunStateRunning, ProgressPct: progress}
+	}
+
+	c, w := newEvolutionStreamContext(context.Background(), "user-1")
+	s.streamEvolution(c, backtestStatus, time.Millisecond)
+
+	if ct := w.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("expected SSE content type, got %q", ct)
+	}
+	body := w.Body.String()
+	for _, want := range []string{
+		`"status":"running"`,
+		`"progress_pct":50`,
+		`"progress_pct":100`,
+		"event: iteration\n",
+		`"status":"completed"`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("expected stream to contain %q, got:\n%s", want, body)
+		}
+	}
+	if strings.Count(body, "event: state\n") != 2 {
+		t.Fatalf("expected a state event for running and for completed, got:\n%s", body)
+	}
+}
+
+// runningBacktestManager keeps every backtest running at half progress
+type runningBacktestManager struct{}
+
+func (runningBacktestManager) Start(ctx context.Context, cfg backtest.BacktestConfig) (*backtest.Runner, error) {
+	return nil, nil
+}
+func (runningBacktestManager) Status(runID string) *backtest.StatusPayload {
+	return &backtest.StatusPayload{RunID: runID, State: backtest.RunStateRunning, ProgressPct: 50}
+}
+func (runningBacktestManager) LoadMetadata(runID string) (*backtest.RunMetadata, error) {
+	return nil, errors.New("not found")
+}
+func (runningBacktestManager) Delete(runID string) error { return nil }
+func (runningBacktestManager) GetMetrics(runID string) (*backtest.Metrics, error) {
+	return nil, errors.New("not finished")
+}
+func (runningBacktestManager) LoadTrades(runID string, limit int) ([]backtest.TradeEvent, error) {
+	return nil, nil
+}
+func (runningBacktestManager) LoadEquity(runID string, timeframe string, limit int) ([]backtest.EquityPoint, error) {
+	return nil, nil
+}
+func (runningBacktestManager) LoadDecisions(runID string, limit, offset int) ([]*store.DecisionRecord, error) {
+	return nil, nil
+}
+
+// failingAIClient fails every call, so generations only backtest the base strategy
+type failingAIClient struct{}
+
+func (failingAIClient) SetAPIKey(apiKey string, customURL string, customModel string) {}
+func (failingAIClient) SetTimeout(timeout time.Duration)                              {}
+func (failingAIClient) CallWithMessages(systemPrompt, userPrompt string) (string, error) {
+	return "", errors.New("no AI in tests")
+}
+func (failingAIClient) CallWithRequest(req *mcp.Request) (string, error) {
+	return "", errors.New("no AI in tests")
+}
+
+func TestEvolutionStreamEmitsProgressOfEvolverBacktests(t *testing.T) {
+	for _, tc := range []struct {
+		name               string
+		parallelCandidates int
+	}{
+		{"sequential iteration", 0},
+		{"parallel candidates", 2},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			st, err := store.New(filepath.Join(t.TempDir(), "store.db"))
+			if err != nil {
+				t.Fatalf("store.New failed: %v", err)
+			}
+			t.Cleanup(func() { st.Close() })
+			if err := st.AIModel().Create("user-1", "model-1", "deepseek", "deepseek", true, "test-key", ""); err != nil {
+				t.Fatalf("failed to create AI model: %v", err)
+			}
+			if err := st.Strategy().Create(&store.Strategy{ID: "base", UserID: "user-1", Name: "base", Config: "base-prompt"}); err != nil {
+				t.Fatalf("failed to create strategy: %v", err)
+			}
+			if err := st.Evolution().Create(&evotypes.Evolution{
+				ID: "evo-1", UserID: "user-1", Name: "evo", BaseStrategyID: "base",
+				Status: evotypes.StatusRunning, MaxIterations: 2, Config: "{}",
+			}); err != nil {
+				t.Fatalf("Create failed: %v", err)
+			}
+
+			// The evolver records the iteration states the stream reads
+			mgr := runningBacktestManager{}
+			evolver := autoevolver.NewAutoEvolver("evo-1", &autoevolver.EvolutionConfig{
+				UserID:             "user-1",
+				Name:               "evo",
+				BaseStrategyID:     "base",
+				MaxIterations:      2,
+				ParallelCandidates: tc.parallelCandidates,
+				FixedParams:        autoevolver.FixedParams{AIModelID: "model-1"},
+			}, mgr, failingAIClient{}, st)
+			evolverCtx, stopEvolver := context.WithCancel(context.Background())
+			evolverDone := make(chan struct{})
+			go func() {
+				defer close(evolverDone)
+				evolver.Start(evolverCtx)
+			}()
+			defer func() {
+				stopEvolver()
+				<-evolverDone
+			}()
+
+			// Disconnect once the first progress event has been written
+			streamCtx, disconnect := context.WithTimeout(context.Background(), 5*time.Second)
+			defer disconnect()
+			backtestStatus := func(runID string) *backtest.StatusPayload {
+				defer disconnect()
+				return mgr.Status(runID)
+			}
+			c, w := newEvolutionStreamContext(streamCtx, "user-1")
+			(&Server{store: st}).streamEvolution(c, backtestStatus, time.Millisecond)
+
+			if body := w.Body.String(); !strings.Contains(body, "event: progress\n") || !strings.Contains(body, `"progress_pct":50`) {
+				t.Fatalf("expected a progress event for the running backtest, got:\n%s", body)
+			}
+		})
+	}
+}
+
+func TestEvolutionStreamStopsOnClientDisconnect(t *testing.T) {
+	s := newEvolutionTestServer(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c, _ := newEvolutionStreamContext(ctx, "user-1")
+	done := make(chan struct{})
+	go func() {
+		s.streamEvolution(c, nil, time.Millisecond)
+		close(done)
+	}()
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("stream did not stop after the client disconnected")
+	}
+}
+
+func TestEvolutionStreamRejectsOtherUsers(t *testing.T) {
+	s := newEvolutionTestServer(t)
+
+	c, w := newEvolutionStreamContext(context.Background(), "user-2")
+	s.streamEvolution(c, nil, time.Millisecond)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for another user's evolution, got %d", w.Code)
+	}
+}
+
+func TestDeleteEvolutionIsSoftAndRestorable(t *testing.T) {
+	s := newEvolutionTestServer(t)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("user_id", "user-1") })
+	s.registerEvolutionRoutes(router.Group("/evolutions"))
+
+	request := func(method, path string) int {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w.Code
+	}
+
+	if code := request(http.MethodDelete, "/evolutions/evo-1"); code != http.StatusConflict {
+		t.Fatalf("expected a running evolution to be protected, got %d", code)
+	}
+	s.store.Evolution().UpdateStatus("evo-1", evotypes.StatusStopped)
+	if code := request(http.MethodDelete, "/evolutions/evo-1"); code != http.StatusOK {
+		t.Fatalf("expected delete to succeed, got %d", code)
+	}
+	if code := request(http.MethodGet, "/evolutions/evo-1/iterations/1/equity"); code != http.StatusNotFound {
+		t.Fatalf("expected deleted evolution to be hidden, got %d", code)
+	}
+	if code := request(http.MethodPost, "/evolutions/evo-1/restore"); code != http.StatusOK {
+		t.Fatalf("expected restore to succeed, got %d", code)
+	}
+	if code := request(http.MethodPost, "/evolutions/evo-1/restore"); code != http.StatusNotFound {
+		t.Fatalf("expected a second restore to find nothing, got %d", code)
+	}
+}
+
+func TestEvolutionIterationDetailIncludesPromptDiff(t *testing.T) {
+	s := newEvolutionTestServer(t)
+	if err := s.store.Evolution().UpdateIterationPrompts("evo-1", 1, `{"risk_control":{"max_positions":3}}`, `{"risk_control":{"max_positions":2}}`); err != nil {
+		t.Fatalf("UpdateIterationPrompts failed: %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("user_id", "user-1") })
+	s.registerEvolutionRoutes(router.Group("/evolutions"))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/evolutions/evo-1/iterations/1", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var detail evotypes.IterationDetail
+	if err := json.Unmarshal(w.Body.Bytes(), &detail); err != nil {
+		t.Fatalf("decode detail: %v", err)
+	}
+	want := evotypes.PromptFieldChange{Path: "risk_control.max_positions", Kind: "changed", Old: "3", New: "2"}
+	if detail.PromptDiff == nil || len(detail.PromptDiff.Fields) != 1 || detail.PromptDiff.Fields[0] != want {
+		t.Fatalf("expected prompt diff %+v, got %+v", want, detail.PromptDiff)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/evolutions/evo-1/iterations/7", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown iteration, got %d", w.Code)
+	}
+}
+
+func TestImportEvolutionRejectsInvalidWebhookURL(t *testing.T) {
+	s := newEvolutionTestServer(t)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("user_id", "user-1") })
+	s.registerEvolutionRoutes(router.Group("/evolutions"))
+
+	body := `{"format_version":1,"evolution":{"name":"evo","base_strategy_id":"base","config":"{\"webhook_url\":\"file:///etc/passwd\"}"}}`
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/evolutions/import", strings.NewReader(body)))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestImportEvolutionRejectsUnsupportedFormatVersion(t *testing.T) {
+	s := newEvolutionTestServer(t)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("user_id", "user-1") })
+	s.registerEvolutionRoutes(router.Group("/evolutions"))
+
+	body := `{"format_version":99,"evolution":{"name":"evo","base_strategy_id":"base","config":"{}"}}`
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/evolutions/import", strings.NewReader(body)))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d: %s", w.Code, w.Body.String())
+	}
+}
@@ -178,10 +178,13 @@ func (s *Server) setupRoutes() {
 			// Baseline strategy management
 			protected.GET("/baseline-strategies", s.handleListBaselineStrategies)
 			protected.POST("/baseline-strategies", s.handleCreateBaselineStrategy)
+			protected.POST("/baseline-strategies/validate", s.handleValidateBaselineConfig)
 			protected.GET("/baseline-strategies/rankings", s.handleGetBaselineRankings)
 			protected.GET("/baseline-strategies/:id/performance", s.handleGetBaselinePerformance)
+			protected.GET("/baseline-strategies/:id/performance.csv", s.handleExportBaselinePerformanceCSV)
 			protected.GET("/baseline-strategies/:id", s.handleGetBaselineStrategy)
 			protected.PUT("/baseline-strategies/:id", s.handleUpdateBaselineStrategy)
+			protected.POST("/baseline-strategies/:id/clone", s.handleCloneBaselineStrategy)
 			protected.DELETE("/baseline-strategies/:id", s.handleDeleteBaselineStrategy)
 
 			// Debate Arena
@@ -208,6 +211,10 @@ func (s *Server) setupRoutes() {
 			// Backtest routes
 			backtest := protected.Group("/backtest")
 			s.registerBacktestRoutes(backtest)
+
+			// Evolution routes
+			evolutions := protected.Group("/evolutions")
+			s.registerEvolutionRoutes(evolutions)
 		}
 	}
 }
@@ -0,0 +1,92 @@
+package autoevolver
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"nofx/backtest"
+	"nofx/logger"
+	"nofx/mcp"
+	"nofx/store"
+)
+
+// modelAIConfig converts a stored AI model into the AI configuration of a backtest. An empty
+// or "inherit" provider is inferred from the model name. Both the backtests and the evaluation
+// client resolve models through here, so they always agree on the provider.
+func modelAIConfig(model *store.AIModel) backtest.AIConfig {
+	provider := strings.ToLower(strings.TrimSpace(model.Provider))
+	if provider == "" || provider == "inherit" {
+		modelNameLower := strings.ToLower(model.Name)
+		if strings.Contains(modelNameLower, "claude") {
+			provider = "anthropic"
+		} else if strings.Contains(modelNameLower, "gpt") {
+			provider = "openai"
+		} else if strings.Contains(modelNameLower, "gemini") {
+			provider = "google"
+		} else if strings.Contains(modelNameLower, "deepseek") {
+			provider = "deepseek"
+		} else if model.CustomAPIURL != "" {
+			provider = "custom"
+		} else {
+			provider = "openai"
+		}
+	}
+
+	return backtest.AIConfig{
+		Provider: provider,
+		APIKey:   strings.TrimSpace(model.APIKey),
+		BaseURL:  strings.TrimSpace(model.CustomAPIURL),
+		Model:    strings.TrimSpace(model.CustomModelName),
+	}
+}
+
+// newModelClient creates the AI client of a stored model with the provider mapping of the backtest runner
+func newModelClient(model *store.AIModel) (mcp.AIClient, error) {
+	return backtest.NewAIClient(modelAIConfig(model))
+}
+
+// hydrateEvaluationClient builds the client the analyzer uses from EvaluationModel. Without an
+// evaluation model (or when it is the optimization model) both stages share aiClient. An
+// EvaluationModel that names no stored model (configs from before it held a model ID stored
+// free text such as "claude-opus") also falls back to the shared client, with a warning.
+func (e *AutoEvolver) hydrateEvaluationClient() error {
+	e.evalClient = nil
+	modelID := strings.TrimSpace(e.config.EvaluationModel)
+	if modelID == "" || modelID == strings.TrimSpace(e.config.FixedParams.AIModelID) {
+		return nil
+	}
+
+	model, err := e.store.AIModel().Get(e.config.UserID, modelID)
+	if errors.Is(err, sql.ErrNoRows) {
+		logger.Warnf("Evolution %s: evaluation model %q is not a configured AI model, evaluating with the optimization model",
+			e.evolutionID, modelID)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load evaluation AI model: %w", err)
+	}
+	if !model.Enabled {
+		return fmt.Errorf("evaluation AI model %s is not enabled", model.Name)
+	}
+	if strings.TrimSpace(model.APIKey) == "" {
+		return fmt.Errorf("evaluation AI model %s is missing API Key", model.Name)
+	}
+
+	client, err := e.newModelClient(model)
+	if err != nil {
+		return fmt.Errorf("failed to create evaluation AI client: %w", err)
+	}
+	e.evalClient = client
+	logger.Infof("Evolution %s: evaluating with AI model %s (provider=%s)", e.evolutionID, model.Name, model.Provider)
+	return nil
+}
+
+// evaluationClient returns the client backtests are analyzed with
+func (e *AutoEvolver) evaluationClient() mcp.AIClient {
+	if e.evalClient != nil {
+		return e.evalClient
+	}
+	return e.aiClient
+}
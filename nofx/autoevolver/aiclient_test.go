@@ -0,0 +1,109 @@
+package autoevolver
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"nofx/mcp"
+	"nofx/store"
+)
+
+// runWithEvaluationModel runs a single iteration with the given evaluation model and returns
+// the optimization client, the client built for the evaluation model (nil if none was built)
+// and the error of Start
+func runWithEvaluationModel(t *testing.T, evaluationModel string) (optClient, evalClient *stubAIClient, err error) {
+	t.Helper()
+	cfg := &EvolutionConfig{
+		UserID:          "user-1",
+		Name:            "evo",
+		BaseStrategyID:  "base",
+		MaxIterations:   1,
+		EvaluationModel: evaluationModel,
+		FixedParams:     FixedParams{AIModelID: "model-1"},
+	}
+	mgr := newStubBacktestManager(time.Millisecond, map[string]float64{"base-prompt": 5})
+	evolver, st := newTestEvolver(t, cfg, mgr)
+	if err := st.AIModel().Create("user-1", "model-eval", "deepseek-eval", "deepseek", true, "eval-key", ""); err != nil {
+		t.Fatalf("failed to create AI model: %v", err)
+	}
+	if err := st.AIModel().Create("user-1", "model-off", "deepseek-off", "deepseek", false, "off-key", ""); err != nil {
+		t.Fatalf("failed to create AI model: %v", err)
+	}
+	evolver.newModelClient = func(model *store.AIModel) (mcp.AIClient, error) {
+		if model.ID != "model-eval" {
+			t.Errorf("unexpected evaluation model %s", model.ID)
+		}
+		evalClient = &stubAIClient{}
+		return evalClient, nil
+	}
+
+	err = evolver.Start(context.Background())
+	return evolver.aiClient.(*stubAIClient), evalClient, err
+}
+
+func TestEvaluationModelAnalyzesBacktests(t *testing.T) {
+	optClient, evalClient, err := runWithEvaluationModel(t, "model-eval")
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if evalClient == nil {
+		t.Fatal("expected a client for the evaluation model")
+	}
+	if evalClient.analyses != 1 || evalClient.mutations != 0 {
+		t.Errorf("evaluation client: expected 1 analysis and no mutations, got %d and %d", evalClient.analyses, evalClient.mutations)
+	}
+	if optClient.analyses != 0 || optClient.mutations != 1 {
+		t.Errorf("optimization client: expected no analyses and 1 mutation, got %d and %d", optClient.analyses, optClient.mutations)
+	}
+}
+
+func TestEvaluationModelFallsBackToOptimizationModel(t *testing.T) {
+	// "claude-opus" is free text from before EvaluationModel held a model ID
+	for _, model := range []string{"", "model-1", "claude-opus"} {
+		optClient, evalClient, err := runWithEvaluationModel(t, model)
+		if err != nil {
+			t.Fatalf("%q: Start failed: %v", model, err)
+		}
+		if evalClient != nil {
+			t.Errorf("%q: expected no separate evaluation client", model)
+		}
+		if optClient.analyses != 1 || optClient.mutations != 1 {
+			t.Errorf("%q: expected 1 analysis and 1 mutation on the shared client, got %d and %d", model, optClient.analyses, optClient.mutations)
+		}
+	}
+}
+
+func TestEvaluationModelMustBeEnabled(t *testing.T) {
+	_, _, err := runWithEvaluationModel(t, "model-off")
+	if err == nil || !strings.Contains(err.Error(), "not enabled") {
+		t.Fatalf("expected a disabled model error, got %v", err)
+	}
+}
+
+func TestModelAIConfigInfersProvider(t *testing.T) {
+	tests := []struct {
+		model *store.AIModel
+		want  string
+	}{
+		{&store.AIModel{Name: "DeepSeek Chat", Provider: "inherit"}, "deepseek"},
+		{&store.AIModel{Name: "my-gpt-4o", Provider: ""}, "openai"},
+		{&store.AIModel{Name: "proxy", CustomAPIURL: "https://llm.example.com"}, "custom"},
+		{&store.AIModel{Name: "anything", Provider: " Qwen "}, "qwen"},
+	}
+	for _, tt := range tests {
+		if got := modelAIConfig(tt.model).Provider; got != tt.want {
+			t.Errorf("%s/%q: expected provider %s, got %s", tt.model.Name, tt.model.Provider, tt.want, got)
+		}
+	}
+
+	// The evaluation client honours the inferred provider, like the backtests do
+	client, err := newModelClient(&store.AIModel{Name: "deepseek-eval", Provider: "inherit", APIKey: " key "})
+	if err != nil {
+		t.Fatalf("newModelClient failed: %v", err)
+	}
+	if _, ok := client.(*mcp.DeepSeekClient); !ok {
+		t.Errorf("expected a DeepSeek client, got %T", client)
+	}
+}
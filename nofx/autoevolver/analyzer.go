@@ -3,6 +3,7 @@ package autoevolver
 import (
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
 
 	"nofx/backtest"
@@ -13,12 +14,19 @@ import (
 
 // Analyzer evaluates backtest results using AI
 type Analyzer struct {
-	aiClient mcp.AIClient
+	aiClient   mcp.AIClient
+	maxRetries int
 }
 
 // NewAnalyzer creates a new Analyzer
 func NewAnalyzer(aiClient mcp.AIClient) *Analyzer {
-	return &Analyzer{aiClient: aiClient}
+	return &Analyzer{aiClient: aiClient, maxRetries: defaultMaxParseRetries}
+}
+
+// WithMaxRetries sets how often a malformed reply is re-requested (see parseRetries)
+func (a *Analyzer) WithMaxRetries(n int) *Analyzer {
+	a.maxRetries = parseRetries(n)
+	return a
 }
 
 // AnalysisInput contains all data needed for AI evaluation
@@ -28,12 +36,22 @@ type AnalysisInput struct {
 	Trades        []backtest.TradeEvent
 	Decisions     []DecisionSample
 	EquityCurve   []backtest.EquityPoint
+	Drawdown      *DrawdownAnalysis // derived from EquityCurve when nil
+	// SymbolPerformance covers all closed trades of the run, Trades may be just a sample;
+	// derived from Trades when nil
+	SymbolPerformance []symbolPerformance
 }
 
 // Analyze evaluates backtest results and returns an evaluation report
 func (a *Analyzer) Analyze(input *AnalysisInput) (*evotypes.EvaluationReport, error) {
+	if input.Drawdown == nil {
+		input.Drawdown = analyzeDrawdowns(input.EquityCurve, drawdownEventPct)
+	}
+	if input.SymbolPerformance == nil {
+		input.SymbolPerformance = aggregateBySymbol(input.Trades)
+	}
 	if a.aiClient == nil {
-		return a.createFallbackReport(input.Metrics), nil
+		return a.createFallbackReport(input.Metrics, input.Drawdown), nil
 	}
 
 	// Build analysis prompt
@@ -42,26 +60,24 @@ func (a *Analyzer) Analyze(input *AnalysisInput) (*evotypes.EvaluationReport, er
 
 	logger.Infof("Analyzer: calling AI for evaluation...")
 
-	// Call AI
-	response, err := a.aiClient.CallWithMessages(systemPrompt, userPrompt)
-	if err != nil {
+	// Call AI, re-prompting while the reply is not valid JSON
+	report, response, retries, err := callForJSON(a.aiClient, systemPrompt, userPrompt, a.maxRetries, parseEvaluationResponse)
+	if err != nil && response == "" {
 		logger.Warnf("AI analysis failed, using fallback: %v", err)
-		return a.createFallbackReport(input.Metrics), nil
+		return a.createFallbackReport(input.Metrics, input.Drawdown), nil
 	}
-
-	// Parse response
-	report, err := parseEvaluationResponse(response)
 	if err != nil {
 		logger.Warnf("Failed to parse AI response, using fallback: %v", err)
-		report = a.createFallbackReport(input.Metrics)
+		report = a.createFallbackReport(input.Metrics, input.Drawdown)
 	}
 	report.RawResponse = response
+	report.ParseRetries = retries
 
 	return report, nil
 }
 
 // createFallbackReport creates a basic report when AI is unavailable
-func (a *Analyzer) createFallbackReport(metrics *backtest.Metrics) *evotypes.EvaluationReport {
+func (a *Analyzer) createFallbackReport(metrics *backtest.Metrics, drawdown *DrawdownAnalysis) *evotypes.EvaluationReport {
 	report := &evotypes.EvaluationReport{
 		Strengths:   []string{},
 		Weaknesses:  []string{},
@@ -89,6 +105,11 @@ func (a *Analyzer) createFallbackReport(metrics *backtest.Metrics) *evotypes.Eva
 		report.Weaknesses = append(report.Weaknesses,
 			fmt.Sprintf("High drawdown: %.2f%%", metrics.MaxDrawdownPct))
 	}
+	if drawdown.IsLongUnrecovered() {
+		report.Weaknesses = append(report.Weaknesses,
+			fmt.Sprintf("Drawdown of %.2f%% not recovered after %d of %d bars",
+				drawdown.MaxDrawdownPct, drawdown.DurationBars, drawdown.TotalBars))
+	}
 
 	if metrics.WinRate > 50 {
 		report.Strengths = append(report.Strengths,
@@ -98,6 +119,7 @@ func (a *Analyzer) createFallbackReport(metrics *backtest.Metrics) *evotypes.Eva
 			fmt.Sprintf("Low win rate: %.1f%%", metrics.WinRate))
 	}
 
+	// Sharpe and Sortino are annualized by the backtest, so 1 is the usual bar for a good ratio
 	if metrics.SharpeRatio > 1 {
 		report.Strengths = append(report.Strengths,
 			fmt.Sprintf("Good Sharpe ratio: %.2f", metrics.SharpeRatio))
@@ -106,6 +128,27 @@ func (a *Analyzer) createFallbackReport(metrics *backtest.Metrics) *evotypes.Eva
 			fmt.Sprintf("Negative Sharpe ratio: %.2f", metrics.SharpeRatio))
 	}
 
+	if metrics.SortinoRatio > 1 && metrics.SortinoRatio > metrics.SharpeRatio {
+		report.Strengths = append(report.Strengths,
+			fmt.Sprintf("Volatility is mostly upside (Sortino ratio: %.2f)", metrics.SortinoRatio))
+	}
+
+	if metrics.CalmarRatio < 1 && metrics.MaxDrawdownPct > 0 {
+		report.Weaknesses = append(report.Weaknesses,
+			fmt.Sprintf("Return does not cover drawdown (Calmar ratio: %.2f)", metrics.CalmarRatio))
+	}
+
+	if metrics.MaxConsecutiveLosses >= 5 {
+		report.Weaknesses = append(report.Weaknesses,
+			fmt.Sprintf("Long losing streak: %d consecutive losses", metrics.MaxConsecutiveLosses))
+		report.Suggestions = append(report.Suggestions,
+			"Reduce leverage or pause entries after consecutive losses")
+	}
+	if metrics.Trades > 0 && metrics.Expectancy < 0 {
+		report.Weaknesses = append(report.Weaknesses,
+			fmt.Sprintf("Negative expectancy: %.2f USDT per trade", metrics.Expectancy))
+	}
+
 	// Generate suggestions based on weaknesses
 	if metrics.MaxDrawdownPct > 20 {
 		report.Suggestions = append(report.Suggestions,
@@ -172,11 +215,19 @@ func buildAnalysisUserPrompt(input *AnalysisInput) string {
 		sb.WriteString(fmt.Sprintf("- Total Return: %.2f%%\n", input.Metrics.TotalReturnPct))
 		sb.WriteString(fmt.Sprintf("- Max Drawdown: %.2f%%\n", input.Metrics.MaxDrawdownPct))
 		sb.WriteString(fmt.Sprintf("- Win Rate: %.1f%%\n", input.Metrics.WinRate))
-		sb.WriteString(fmt.Sprintf("- Sharpe Ratio: %.2f\n", input.Metrics.SharpeRatio))
+		sb.WriteString(fmt.Sprintf("- Sharpe Ratio (annualized): %.2f\n", input.Metrics.SharpeRatio))
+		sb.WriteString(fmt.Sprintf("- Sortino Ratio (annualized): %.2f\n", input.Metrics.SortinoRatio))
+		sb.WriteString(fmt.Sprintf("- Calmar Ratio: %.2f\n", input.Metrics.CalmarRatio))
 		sb.WriteString(fmt.Sprintf("- Total Trades: %d\n", input.Metrics.Trades))
 		sb.WriteString(fmt.Sprintf("- Profit Factor: %.2f\n", input.Metrics.ProfitFactor))
+		sb.WriteString(fmt.Sprintf("- Average Win: %.2f USDT / Average Loss: %.2f USDT\n",
+			input.Metrics.AvgWin, input.Metrics.AvgLoss))
+		sb.WriteString(fmt.Sprintf("- Expectancy: %.2f USDT per trade\n", input.Metrics.Expectancy))
+		sb.WriteString(fmt.Sprintf("- Max Consecutive Losses: %d\n", input.Metrics.MaxConsecutiveLosses))
 	}
 
+	writeDrawdownAnalysis(&sb, input.Drawdown)
+
 	// Add detailed trade analysis
 	if len(input.Trades) > 0 {
 		sb.WriteString("\n## Trade Analysis\n\n")
@@ -224,6 +275,8 @@ func buildAnalysisUserPrompt(input *AnalysisInput) string {
 				shortTrades, float64(shortWins)/float64(shortTrades)*100))
 		}
 
+		writeSymbolBreakdown(&sb, input.SymbolPerformance, symbolBreakdownLimit)
+
 		// Show sample winning trades
 		if len(winningTrades) > 0 {
 			sb.WriteString("\n### Sample Winning Trades\n")
@@ -245,6 +298,24 @@ func buildAnalysisUserPrompt(input *AnalysisInput) string {
 		}
 	}
 
+	// Show the reasoning behind key decisions
+	if len(input.Decisions) > 0 {
+		sb.WriteString("\n## Sampled Decisions\n\n")
+		count := 0
+		for _, d := range input.Decisions {
+			if count >= 10 {
+				break
+			}
+			marker := ""
+			if d.IsKeyEvent {
+				marker = "[KEY] "
+			}
+			sb.WriteString(fmt.Sprintf("- %s%s %s (PnL: %+.2f USDT): %s\n",
+				marker, d.Symbol, d.Action, d.PnL, truncateString(d.Reasoning, 200)))
+			count++
+		}
+	}
+
 	sb.WriteString("\n## Current Strategy Prompt (truncated)\n\n")
 	sb.WriteString("```\n")
 	sb.WriteString(truncateString(input.CurrentPrompt, 1500))
@@ -255,6 +326,90 @@ func buildAnalysisUserPrompt(input *AnalysisInput) string {
 	return sb.String()
 }
 
+// symbolBreakdownLimit is how many of the best and of the worst symbols the prompt lists
+const symbolBreakdownLimit = 5
+
+// symbolPerformance aggregates closed trades of one symbol
+type symbolPerformance struct {
+	Symbol string
+	PnL    float64
+	Trades int
+	Wins   int
+}
+
+// WinRate returns the percentage of winning closed trades
+func (p symbolPerformance) WinRate() float64 {
+	if p.Trades == 0 {
+		return 0
+	}
+	return float64(p.Wins) / float64(p.Trades) * 100
+}
+
+// aggregateBySymbol groups closed trades by symbol, sorted by PnL from best to worst
+func aggregateBySymbol(trades []backtest.TradeEvent) []symbolPerformance {
+	bySymbol := make(map[string]*symbolPerformance)
+	var order []string
+	for _, t := range trades {
+		if t.RealizedPnL == 0 && !strings.HasPrefix(t.Action, "close") && !t.LiquidationFlag {
+			continue // Opening fills carry no result
+		}
+		perf, ok := bySymbol[t.Symbol]
+		if !ok {
+			perf = &symbolPerformance{Symbol: t.Symbol}
+			bySymbol[t.Symbol] = perf
+			order = append(order, t.Symbol)
+		}
+		perf.PnL += t.RealizedPnL
+		perf.Trades++
+		if t.RealizedPnL > 0 {
+			perf.Wins++
+		}
+	}
+
+	result := make([]symbolPerformance, 0, len(order))
+	for _, symbol := range order {
+		result = append(result, *bySymbol[symbol])
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].PnL > result[j].PnL })
+	return result
+}
+
+// loadAnalysisTrades loads every trade of a run and returns a sample of 100 for the prompts
+// along with the per-symbol results over all of them
+func (e *AutoEvolver) loadAnalysisTrades(runID string) ([]backtest.TradeEvent, []symbolPerformance) {
+	trades, err := e.backtestMgr.LoadTrades(runID, 0)
+	if err != nil {
+		return nil, nil
+	}
+	return backtest.LimitTradeEvents(trades, 100), aggregateBySymbol(trades)
+}
+
+// writeSymbolBreakdown writes a per-symbol table, keeping only the top and bottom limit symbols
+func writeSymbolBreakdown(sb *strings.Builder, perf []symbolPerformance, limit int) {
+	if len(perf) == 0 {
+		return
+	}
+
+	sb.WriteString("\n### Per-Symbol Performance\n")
+	sb.WriteString("| Symbol | PnL (USDT) | Trades | Win Rate |\n|---|---|---|---|\n")
+	writeRow := func(p symbolPerformance) {
+		sb.WriteString(fmt.Sprintf("| %s | %+.2f | %d | %.1f%% |\n", p.Symbol, p.PnL, p.Trades, p.WinRate()))
+	}
+	if len(perf) <= 2*limit {
+		for _, p := range perf {
+			writeRow(p)
+		}
+		return
+	}
+	for _, p := range perf[:limit] {
+		writeRow(p)
+	}
+	sb.WriteString(fmt.Sprintf("| ... %d more symbols | | | |\n", len(perf)-2*limit))
+	for _, p := range perf[len(perf)-limit:] {
+		writeRow(p)
+	}
+}
+
 func safeAvg(total float64, count int) float64 {
 	if count == 0 {
 		return 0
@@ -0,0 +1,84 @@
+package autoevolver
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"nofx/backtest"
+)
+
+func TestAggregateBySymbol(t *testing.T) {
+	trades := []backtest.TradeEvent{
+		{Symbol: "BTCUSDT", Action: "open_long"},
+		{Symbol: "BTCUSDT", Action: "close_long", RealizedPnL: 40},
+		{Symbol: "SOLUSDT", Action: "close_short", RealizedPnL: -15},
+		{Symbol: "BTCUSDT", Action: "close_long", RealizedPnL: -10},
+		{Symbol: "SOLUSDT", Action: "close_long", RealizedPnL: -5},
+		{Symbol: "ETHUSDT", Action: "close_long", RealizedPnL: 0},
+	}
+
+	got := aggregateBySymbol(trades)
+	want := []symbolPerformance{
+		{Symbol: "BTCUSDT", PnL: 30, Trades: 2, Wins: 1},
+		{Symbol: "ETHUSDT", PnL: 0, Trades: 1, Wins: 0},
+		{Symbol: "SOLUSDT", PnL: -20, Trades: 2, Wins: 0},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d symbols, got %+v", len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("symbol %d = %+v, expected %+v", i, got[i], want[i])
+		}
+	}
+	if got[0].WinRate() != 50 {
+		t.Errorf("BTCUSDT win rate = %.1f, expected 50", got[0].WinRate())
+	}
+}
+
+func TestWriteSymbolBreakdownCapsToTopAndBottom(t *testing.T) {
+	var trades []backtest.TradeEvent
+	for i := 0; i < 8; i++ {
+		trades = append(trades, backtest.TradeEvent{
+			Symbol:      fmt.Sprintf("COIN%dUSDT", i),
+			Action:      "close_long",
+			RealizedPnL: float64(10 - 3*i),
+		})
+	}
+
+	var sb strings.Builder
+	writeSymbolBreakdown(&sb, aggregateBySymbol(trades), 2)
+	table := sb.String()
+
+	for _, symbol := range []string{"COIN0USDT", "COIN1USDT", "COIN6USDT", "COIN7USDT"} {
+		if !strings.Contains(table, symbol) {
+			t.Errorf("expected %s in the table:\n%s", symbol, table)
+		}
+	}
+	for _, symbol := range []string{"COIN2USDT", "COIN5USDT"} {
+		if strings.Contains(table, symbol) {
+			t.Errorf("expected %s to be left out of the table:\n%s", symbol, table)
+		}
+	}
+	if !strings.Contains(table, "4 more symbols") {
+		t.Errorf("expected the table to note the omitted symbols:\n%s", table)
+	}
+}
+
+func TestAnalysisPromptBreaksDownAllTrades(t *testing.T) {
+	var trades []backtest.TradeEvent
+	for i := 0; i < 150; i++ {
+		symbol := "BTCUSDT"
+		if i%50 == 49 {
+			symbol = "DOGEUSDT" // Only 3 of 150 trades, easily missed by the sample
+		}
+		trades = append(trades, backtest.TradeEvent{Symbol: symbol, Action: "close_long", RealizedPnL: 1})
+	}
+
+	input := &AnalysisInput{Trades: backtest.LimitTradeEvents(trades, 10), SymbolPerformance: aggregateBySymbol(trades)}
+	prompt := buildAnalysisUserPrompt(input)
+	if !strings.Contains(prompt, "| DOGEUSDT | +3.00 | 3 |") || !strings.Contains(prompt, "| BTCUSDT | +147.00 | 147 |") {
+		t.Errorf("expected the breakdown to cover all 150 trades:\n%s", prompt)
+	}
+}
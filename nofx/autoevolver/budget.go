@@ -0,0 +1,52 @@
+package autoevolver
+
+import (
+	"nofx/logger"
+	"nofx/mcp"
+)
+
+// tokenUsage returns the cumulative token usage of the AI clients, counting a client that
+// does not account its calls as zero. The clients are expected to be dedicated to this evolution.
+func (e *AutoEvolver) tokenUsage() mcp.TokenUsage {
+	var usage mcp.TokenUsage
+	for _, client := range []mcp.AIClient{e.aiClient, e.evalClient} {
+		if reporter, ok := client.(mcp.UsageReporter); ok {
+			used := reporter.TokenUsage()
+			usage.PromptTokens += used.PromptTokens
+			usage.CompletionTokens += used.CompletionTokens
+		}
+	}
+	return usage
+}
+
+// recordTokenUsage adds the tokens used while running an iteration (or a generation
+// starting at version) to that iteration's record
+func (e *AutoEvolver) recordTokenUsage(version int, used mcp.TokenUsage) {
+	if used.Total() == 0 {
+		return
+	}
+	logger.Infof("Evolution %s v%d: AI calls used %d prompt + %d completion tokens",
+		e.evolutionID, version, used.PromptTokens, used.CompletionTokens)
+	if err := e.store.Evolution().AddIterationTokenUsage(e.evolutionID, version, used.PromptTokens, used.CompletionTokens); err != nil {
+		logger.Warnf("Evolution %s v%d: failed to record token usage: %v", e.evolutionID, version, err)
+	}
+}
+
+// tokenBudgetExceeded reports whether the evolution's recorded token usage reached
+// MaxTotalTokens, along with the usage
+func (e *AutoEvolver) tokenBudgetExceeded() (bool, int64) {
+	if e.config.MaxTotalTokens <= 0 {
+		return false, 0
+	}
+	iterations, err := e.store.Evolution().GetIterations(e.evolutionID)
+	if err != nil {
+		logger.Warnf("Evolution %s: failed to load iterations for token budget: %v", e.evolutionID, err)
+		return false, 0
+	}
+
+	var used int64
+	for _, iter := range iterations {
+		used += iter.PromptTokens + iter.CompletionTokens
+	}
+	return used >= e.config.MaxTotalTokens, used
+}
@@ -0,0 +1,84 @@
+package autoevolver
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"nofx/mcp"
+)
+
+// meteredAIClient wraps stubAIClient and reports a fixed token usage per call
+type meteredAIClient struct {
+	stubAIClient
+	usageMu sync.Mutex
+	usage   mcp.TokenUsage
+}
+
+func (c *meteredAIClient) CallWithMessages(systemPrompt, userPrompt string) (string, error) {
+	c.usageMu.Lock()
+	c.usage.PromptTokens += 1000
+	c.usage.CompletionTokens += 200
+	c.usageMu.Unlock()
+	return c.stubAIClient.CallWithMessages(systemPrompt, userPrompt)
+}
+
+func (c *meteredAIClient) TokenUsage() mcp.TokenUsage {
+	c.usageMu.Lock()
+	defer c.usageMu.Unlock()
+	return c.usage
+}
+
+func TestStartStopsWhenTokenBudgetExceeded(t *testing.T) {
+	mgr := newStubBacktestManager(time.Millisecond, map[string]float64{"base-prompt": 1})
+	cfg := &EvolutionConfig{
+		UserID:               "user-1",
+		Name:                 "evo",
+		BaseStrategyID:       "base",
+		MaxIterations:        5,
+		ConvergenceThreshold: 10,
+		FixedParams:          FixedParams{AIModelID: "model-1"},
+		// Each iteration makes two calls (analyze + optimize) of 1200 tokens each
+		MaxTotalTokens: 3000,
+	}
+	evolver, st := newTestEvolver(t, cfg, mgr)
+	evolver.aiClient = &meteredAIClient{}
+
+	if err := evolver.Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	if len(mgr.runs) != 2 {
+		t.Fatalf("expected the budget to stop the evolution after 2 iterations, ran %d", len(mgr.runs))
+	}
+	if evolver.GetStatus() != StatusStopped {
+		t.Errorf("expected status %s, got %s", StatusStopped, evolver.GetStatus())
+	}
+
+	iter, err := st.Evolution().GetIteration("evo-1", 1)
+	if err != nil {
+		t.Fatalf("GetIteration failed: %v", err)
+	}
+	if iter.PromptTokens != 2000 || iter.CompletionTokens != 400 {
+		t.Errorf("expected v1 to record 2000+400 tokens, got %d+%d", iter.PromptTokens, iter.CompletionTokens)
+	}
+
+	status, err := evolver.GetEvolutionStatus()
+	if err != nil {
+		t.Fatalf("GetEvolutionStatus failed: %v", err)
+	}
+	if status.PromptTokens != 4000 || status.CompletionTokens != 800 {
+		t.Errorf("expected status totals 4000+800 tokens, got %d+%d", status.PromptTokens, status.CompletionTokens)
+	}
+}
+
+func TestTokenBudgetDisabledByDefault(t *testing.T) {
+	mgr := newStubBacktestManager(time.Millisecond, map[string]float64{})
+	cfg := &EvolutionConfig{UserID: "user-1", Name: "evo", BaseStrategyID: "base", MaxIterations: 1}
+	evolver, _ := newTestEvolver(t, cfg, mgr)
+
+	if exceeded, _ := evolver.tokenBudgetExceeded(); exceeded {
+		t.Fatal("expected no budget without MaxTotalTokens")
+	}
+}
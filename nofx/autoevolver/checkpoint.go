@@ -0,0 +1,63 @@
+package autoevolver
+
+import (
+	"encoding/json"
+
+	"nofx/backtest"
+	"nofx/evotypes"
+	"nofx/logger"
+)
+
+// evaluationCheckpoint is the decoded form of a stored IterationCheckpoint
+type evaluationCheckpoint struct {
+	metrics    *backtest.Metrics
+	trades     []backtest.TradeEvent
+	evaluation *evotypes.EvaluationReport
+}
+
+// saveCheckpoint persists the evaluation stage outputs of an iteration
+func (e *AutoEvolver) saveCheckpoint(version int, runID string, metrics *backtest.Metrics, trades []backtest.TradeEvent, evaluation *evotypes.EvaluationReport) {
+	metricsJSON, err := json.Marshal(metrics)
+	if err != nil {
+		logger.Warnf("Failed to encode checkpoint metrics for v%d: %v", version, err)
+		return
+	}
+	tradesJSON, _ := json.Marshal(trades)
+	evalJSON, _ := json.Marshal(evaluation)
+
+	if err := e.store.Evolution().SaveIterationCheckpoint(&evotypes.IterationCheckpoint{
+		EvolutionID:   e.evolutionID,
+		Version:       version,
+		BacktestRunID: runID,
+		EvalReport:    string(evalJSON),
+		MetricsJSON:   string(metricsJSON),
+		TradesJSON:    string(tradesJSON),
+	}); err != nil {
+		logger.Warnf("Failed to save checkpoint for v%d: %v", version, err)
+	}
+}
+
+// loadCheckpoint returns the evaluation checkpoint of iter, or nil when there is
+// no usable checkpoint for its current backtest run
+func (e *AutoEvolver) loadCheckpoint(iter *evotypes.Iteration) *evaluationCheckpoint {
+	cp, err := e.store.Evolution().GetIterationCheckpoint(e.evolutionID, iter.Version)
+	if err != nil || cp == nil || cp.BacktestRunID != iter.BacktestRunID {
+		return nil
+	}
+
+	var restored evaluationCheckpoint
+	if err := json.Unmarshal([]byte(cp.MetricsJSON), &restored.metrics); err != nil || restored.metrics == nil {
+		logger.Warnf("Evolution %s v%d: ignoring checkpoint with invalid metrics: %v", e.evolutionID, iter.Version, err)
+		return nil
+	}
+	if cp.TradesJSON != "" {
+		_ = json.Unmarshal([]byte(cp.TradesJSON), &restored.trades)
+	}
+	if cp.EvalReport != "" && cp.EvalReport != "null" {
+		var report evotypes.EvaluationReport
+		if err := json.Unmarshal([]byte(cp.EvalReport), &report); err == nil {
+			restored.evaluation = &report
+		}
+	}
+	return &restored
+}
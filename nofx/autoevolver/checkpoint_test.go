@@ -0,0 +1,81 @@
+package autoevolver
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"nofx/backtest"
+	"nofx/evotypes"
+)
+
+var backtestMetricsFixture = backtest.Metrics{TotalReturnPct: 7.5, MaxDrawdownPct: 4, WinRate: 0.6, Trades: 12}
+
+func TestResumeFromEvaluationCheckpointSkipsBacktest(t *testing.T) {
+	mgr := newStubBacktestManager(time.Millisecond, map[string]float64{})
+	cfg := &EvolutionConfig{
+		UserID:         "user-1",
+		Name:           "evo",
+		BaseStrategyID: "base",
+		MaxIterations:  1,
+		FixedParams:    FixedParams{AIModelID: "model-1"},
+	}
+	evolver, st := newTestEvolver(t, cfg, mgr)
+
+	// Simulate a crash during optimization: the backtest and evaluation of v1 finished
+	// and were checkpointed, but the iteration was never completed
+	if err := st.Evolution().CreateIteration(&evotypes.Iteration{
+		EvolutionID:   "evo-1",
+		Version:       1,
+		StrategyID:    "base",
+		BacktestRunID: "run-v1",
+		Status:        "optimizing",
+		PromptBefore:  "base-prompt",
+	}); err != nil {
+		t.Fatalf("CreateIteration failed: %v", err)
+	}
+	evolver.saveCheckpoint(1, "run-v1", &backtestMetricsFixture, nil, &evotypes.EvaluationReport{Strengths: []string{"checkpointed"}})
+	if err := st.Evolution().UpdateCurrentIteration("evo-1", 1); err != nil {
+		t.Fatalf("UpdateCurrentIteration failed: %v", err)
+	}
+
+	if err := evolver.Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	if len(mgr.runs) != 0 {
+		t.Fatalf("expected resume to skip the backtest, started %d", len(mgr.runs))
+	}
+
+	iter, err := st.Evolution().GetIteration("evo-1", 1)
+	if err != nil {
+		t.Fatalf("GetIteration failed: %v", err)
+	}
+	if iter.Status != IterStatusCompleted {
+		t.Errorf("expected completed iteration, got %s", iter.Status)
+	}
+	if iter.Metrics == nil || iter.Metrics.TotalReturn != backtestMetricsFixture.TotalReturnPct {
+		t.Errorf("expected checkpointed metrics to be stored, got %+v", iter.Metrics)
+	}
+	if iter.PromptAfter != mutationPrompt(1) {
+		t.Errorf("expected optimizer output to be stored, got %q", iter.PromptAfter)
+	}
+
+	if _, err := st.Evolution().GetIterationCheckpoint("evo-1", 1); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected checkpoint to be removed after completion, got %v", err)
+	}
+}
+
+func TestLoadCheckpointIgnoresOtherBacktestRun(t *testing.T) {
+	evolver, _ := newTestEvolver(t, &EvolutionConfig{UserID: "user-1", BaseStrategyID: "base"}, newStubBacktestManager(0, nil))
+	evolver.saveCheckpoint(1, "run-old", &backtestMetricsFixture, nil, nil)
+
+	if cp := evolver.loadCheckpoint(&Iteration{Version: 1, BacktestRunID: "run-new"}); cp != nil {
+		t.Error("checkpoint of a replaced backtest run must not be reused")
+	}
+	if cp := evolver.loadCheckpoint(&Iteration{Version: 1, BacktestRunID: "run-old"}); cp == nil || cp.metrics.TotalReturnPct != backtestMetricsFixture.TotalReturnPct {
+		t.Errorf("expected checkpoint to be restored, got %+v", cp)
+	}
+}
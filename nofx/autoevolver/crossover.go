@@ -0,0 +1,116 @@
+package autoevolver
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"nofx/store"
+)
+
+// Crossover parents a config block can be inherited from
+const (
+	CrossoverFromBest    = "best"
+	CrossoverFromCurrent = "current"
+)
+
+// defaultCrossoverMap takes the best version's position sizing and keeps the
+// current version's entry and stop logic
+var defaultCrossoverMap = map[string]string{
+	// Position sizing and leverage
+	"risk_control": CrossoverFromBest,
+	"baseline_config.risk_management.equity_multiplier": CrossoverFromBest,
+	"baseline_config.risk_management.leverage":          CrossoverFromBest,
+	// Entry signals and stop logic
+	"baseline_config.signal_thresholds":                  CrossoverFromCurrent,
+	"baseline_config.risk_management.hard_stop_loss_pct": CrossoverFromCurrent,
+}
+
+// Crossover merges two JSON strategy configs into an offspring config.
+// The offspring starts as a copy of current; every dotted JSON path that
+// crossoverMap assigns to "best" is replaced with best's value, or removed
+// when best does not set it. The result is checked to still decode as a
+// strategy config.
+func Crossover(bestPrompt, currentPrompt string, crossoverMap map[string]string) (string, error) {
+	var best, offspring map[string]interface{}
+	if err := json.Unmarshal([]byte(bestPrompt), &best); err != nil {
+		return "", fmt.Errorf("best prompt is not a JSON config: %w", err)
+	}
+	if err := json.Unmarshal([]byte(currentPrompt), &offspring); err != nil {
+		return "", fmt.Errorf("current prompt is not a JSON config: %w", err)
+	}
+
+	// Apply paths in sorted order so parents of nested paths are merged first
+	paths := make([]string, 0, len(crossoverMap))
+	for path := range crossoverMap {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		switch crossoverMap[path] {
+		case CrossoverFromCurrent:
+			// Offspring already carries current's value
+		case CrossoverFromBest:
+			keys := strings.Split(path, ".")
+			if value, ok := lookupJSONPath(best, keys); ok {
+				setJSONPath(offspring, keys, value)
+			} else {
+				deleteJSONPath(offspring, keys)
+			}
+		default:
+			return "", fmt.Errorf("crossover path %s: unknown parent %q", path, crossoverMap[path])
+		}
+	}
+
+	merged, err := json.Marshal(offspring)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode offspring: %w", err)
+	}
+	var check store.StrategyConfig
+	if err := json.Unmarshal(merged, &check); err != nil {
+		return "", fmt.Errorf("offspring is not a valid strategy config: %w", err)
+	}
+	return string(merged), nil
+}
+
+// lookupJSONPath returns the value at keys inside a decoded JSON object
+func lookupJSONPath(obj map[string]interface{}, keys []string) (interface{}, bool) {
+	var current interface{} = obj
+	for _, key := range keys {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if current, ok = m[key]; !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// setJSONPath sets the value at keys, creating intermediate objects as needed
+func setJSONPath(obj map[string]interface{}, keys []string, value interface{}) {
+	for _, key := range keys[:len(keys)-1] {
+		next, ok := obj[key].(map[string]interface{})
+		if !ok {
+			next = make(map[string]interface{})
+			obj[key] = next
+		}
+		obj = next
+	}
+	obj[keys[len(keys)-1]] = value
+}
+
+// deleteJSONPath removes the value at keys if present
+func deleteJSONPath(obj map[string]interface{}, keys []string) {
+	for _, key := range keys[:len(keys)-1] {
+		next, ok := obj[key].(map[string]interface{})
+		if !ok {
+			return
+		}
+		obj = next
+	}
+	delete(obj, keys[len(keys)-1])
+}
@@ -0,0 +1,120 @@
+package autoevolver
+
+import (
+	"encoding/json"
+	"testing"
+
+	"nofx/evotypes"
+	"nofx/store"
+)
+
+const (
+	crossoverBestPrompt = `{
+		"custom_prompt": "best prompt",
+		"risk_control": {"max_positions": 4, "max_margin_usage": 0.9},
+		"baseline_config": {
+			"signal_thresholds": {"min_signal_count": 3},
+			"risk_management": {"equity_multiplier": 8, "leverage": 5, "hard_stop_loss_pct": 4}
+		}
+	}`
+	crossoverCurrentPrompt = `{
+		"custom_prompt": "current prompt",
+		"risk_control": {"max_positions": 2, "max_margin_usage": 0.6},
+		"baseline_config": {
+			"signal_thresholds": {"min_signal_count": 2},
+			"risk_management": {"equity_multiplier": 3, "leverage": 3, "hard_stop_loss_pct": 2, "max_holding_bars": 12}
+		}
+	}`
+)
+
+func TestCrossoverMergesConfiguredBlocks(t *testing.T) {
+	merged, err := Crossover(crossoverBestPrompt, crossoverCurrentPrompt, defaultCrossoverMap)
+	if err != nil {
+		t.Fatalf("Crossover failed: %v", err)
+	}
+
+	var cfg store.StrategyConfig
+	if err := json.Unmarshal([]byte(merged), &cfg); err != nil {
+		t.Fatalf("offspring is not a strategy config: %v", err)
+	}
+	rm := cfg.BaselineConfig.RiskManagement
+
+	// Position sizing from best
+	if cfg.RiskControl.MaxPositions != 4 || cfg.RiskControl.MaxMarginUsage != 0.9 {
+		t.Errorf("expected risk_control from best, got %+v", cfg.RiskControl)
+	}
+	if rm.EquityMultiplier != 8 || rm.Leverage != 5 {
+		t.Errorf("expected sizing from best, got multiplier %.1f leverage %d", rm.EquityMultiplier, rm.Leverage)
+	}
+	// Entry and stop logic from current
+	if rm.HardStopLossPct != 2 || rm.MaxHoldingBars != 12 {
+		t.Errorf("expected stop logic from current, got stop %.1f holding %d", rm.HardStopLossPct, rm.MaxHoldingBars)
+	}
+	if cfg.BaselineConfig.SignalThresholds.MinSignalCount != 2 {
+		t.Errorf("expected signal thresholds from current, got %d", cfg.BaselineConfig.SignalThresholds.MinSignalCount)
+	}
+	if cfg.CustomPrompt != "current prompt" {
+		t.Errorf("unmapped fields should come from current, got %q", cfg.CustomPrompt)
+	}
+}
+
+func TestCrossoverRemovesFieldsMissingFromBest(t *testing.T) {
+	merged, err := Crossover(`{"custom_prompt": "best"}`, crossoverCurrentPrompt, map[string]string{
+		"baseline_config.risk_management.max_holding_bars": CrossoverFromBest,
+	})
+	if err != nil {
+		t.Fatalf("Crossover failed: %v", err)
+	}
+
+	var cfg store.StrategyConfig
+	if err := json.Unmarshal([]byte(merged), &cfg); err != nil {
+		t.Fatalf("offspring is not a strategy config: %v", err)
+	}
+	if cfg.BaselineConfig.RiskManagement.MaxHoldingBars != 0 {
+		t.Errorf("expected max_holding_bars to be dropped, got %d", cfg.BaselineConfig.RiskManagement.MaxHoldingBars)
+	}
+	if cfg.BaselineConfig.RiskManagement.HardStopLossPct != 2 {
+		t.Errorf("sibling fields should be kept, got %.1f", cfg.BaselineConfig.RiskManagement.HardStopLossPct)
+	}
+}
+
+func TestCrossoverRejectsInvalidInput(t *testing.T) {
+	if _, err := Crossover("baseline", crossoverCurrentPrompt, defaultCrossoverMap); err == nil {
+		t.Error("expected error for non-JSON best prompt")
+	}
+	if _, err := Crossover(crossoverBestPrompt, crossoverCurrentPrompt, map[string]string{"risk_control": "other"}); err == nil {
+		t.Error("expected error for unknown parent")
+	}
+	if _, err := Crossover(`{"risk_control": "oops"}`, crossoverCurrentPrompt, map[string]string{"risk_control": CrossoverFromBest}); err == nil {
+		t.Error("expected error when offspring no longer decodes as a strategy config")
+	}
+}
+
+func TestGenerateCandidatesIncludesCrossoverOffspring(t *testing.T) {
+	cfg := newParallelConfig(3, 3)
+	cfg.EnableCrossover = true
+	evolver, st := newTestEvolver(t, cfg, newStubBacktestManager(0, nil))
+
+	if err := st.Evolution().CreateIteration(&evotypes.Iteration{
+		EvolutionID:  "evo-1",
+		Version:      1,
+		StrategyID:   "base",
+		Status:       IterStatusCompleted,
+		PromptBefore: crossoverBestPrompt,
+	}); err != nil {
+		t.Fatalf("CreateIteration failed: %v", err)
+	}
+	if err := st.Evolution().UpdateBestVersion("evo-1", 1, 10, 5); err != nil {
+		t.Fatalf("UpdateBestVersion failed: %v", err)
+	}
+
+	candidates := evolver.generateCandidates(crossoverCurrentPrompt, 3)
+	if len(candidates) != 3 {
+		t.Fatalf("expected 3 candidates, got %d", len(candidates))
+	}
+	want, _ := Crossover(crossoverBestPrompt, crossoverCurrentPrompt, defaultCrossoverMap)
+	if candidates[0].prompt != crossoverCurrentPrompt || candidates[1].prompt != want || candidates[2].prompt != mutationPrompt(1) {
+		t.Errorf("expected current, offspring and AI mutation, got %q, %q, %q",
+			candidates[0].prompt, candidates[1].prompt, candidates[2].prompt)
+	}
+}
@@ -0,0 +1,115 @@
+package autoevolver
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"nofx/backtest"
+	"nofx/logger"
+	"nofx/store"
+)
+
+const (
+	// maxDecisionSamples caps the decisions stored per iteration
+	maxDecisionSamples = 30
+	// maxDecisionRecords caps the backtest decision records scanned for samples
+	maxDecisionRecords = 500
+	// keyEventPnLPct is the realized PnL, as a percentage of account equity,
+	// from which a decision counts as a key event
+	keyEventPnLPct = 1.0
+)
+
+// sampleDecisions loads the decisions of a backtest run and samples them for the iteration
+func (e *AutoEvolver) sampleDecisions(runID string) []DecisionSample {
+	records, err := e.backtestMgr.LoadDecisions(runID, maxDecisionRecords, 0)
+	if err != nil {
+		logger.Warnf("Evolution %s: failed to load decisions of %s: %v", e.evolutionID, runID, err)
+		return nil
+	}
+	trades, err := e.backtestMgr.LoadTrades(runID, 0)
+	if err != nil {
+		logger.Warnf("Evolution %s: failed to load trades of %s: %v", e.evolutionID, runID, err)
+	}
+	return sampleDecisions(records, trades, maxDecisionSamples)
+}
+
+// saveDecisionSamples stores the sampled decisions with the iteration
+func (e *AutoEvolver) saveDecisionSamples(version int, samples []DecisionSample) {
+	if samples == nil {
+		return
+	}
+	if err := e.store.Evolution().UpdateIterationDecisionSamples(e.evolutionID, version, samples); err != nil {
+		logger.Warnf("Evolution %s v%d: failed to save decision samples: %v", e.evolutionID, version, err)
+	}
+}
+
+// sampleDecisions turns the executed actions of decision records into samples, attributing
+// the realized PnL of trades in the same cycle and symbol to each action
+func sampleDecisions(records []*store.DecisionRecord, trades []backtest.TradeEvent, limit int) []DecisionSample {
+	pnlByAction := make(map[string]float64)
+	for _, t := range trades {
+		pnlByAction[decisionKey(t.Cycle, t.Symbol)] += t.RealizedPnL
+	}
+
+	samples := make([]DecisionSample, 0)
+	for _, record := range records {
+		if record == nil {
+			continue
+		}
+		equity := record.AccountState.TotalBalance
+		for _, action := range record.Decisions {
+			if action.Action == "hold" || action.Action == "wait" {
+				continue
+			}
+
+			pnl := pnlByAction[decisionKey(record.CycleNumber, action.Symbol)]
+			reasoning := action.Reasoning
+			if reasoning == "" {
+				reasoning = truncateString(record.CoTTrace, 300)
+			}
+			ts := action.Timestamp
+			if ts.IsZero() {
+				ts = record.Timestamp
+			}
+
+			samples = append(samples, DecisionSample{
+				Timestamp:  ts.UnixMilli(),
+				Symbol:     action.Symbol,
+				Action:     action.Action,
+				Reasoning:  reasoning,
+				PnL:        pnl,
+				IsKeyEvent: equity > 0 && math.Abs(pnl)/equity*100 >= keyEventPnLPct,
+			})
+		}
+	}
+
+	return truncateDecisionSamples(samples, limit)
+}
+
+// truncateDecisionSamples keeps at most limit samples, preferring key events (largest PnL
+// first) and then the most recent decisions, and returns them in chronological order
+func truncateDecisionSamples(samples []DecisionSample, limit int) []DecisionSample {
+	if limit > 0 && len(samples) > limit {
+		sort.SliceStable(samples, func(i, j int) bool {
+			a, b := samples[i], samples[j]
+			if a.IsKeyEvent != b.IsKeyEvent {
+				return a.IsKeyEvent
+			}
+			if a.IsKeyEvent && math.Abs(a.PnL) != math.Abs(b.PnL) {
+				return math.Abs(a.PnL) > math.Abs(b.PnL)
+			}
+			return a.Timestamp > b.Timestamp
+		})
+		samples = samples[:limit]
+	}
+
+	sort.SliceStable(samples, func(i, j int) bool {
+		return samples[i].Timestamp < samples[j].Timestamp
+	})
+	return samples
+}
+
+func decisionKey(cycle int, symbol string) string {
+	return fmt.Sprintf("%d|%s", cycle, symbol)
+}
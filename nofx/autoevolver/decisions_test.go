@@ -0,0 +1,100 @@
+package autoevolver
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"nofx/backtest"
+	"nofx/store"
+)
+
+func TestSampleDecisionsAttributesPnLAndFlagsKeyEvents(t *testing.T) {
+	base := time.UnixMilli(1_700_000_000_000)
+	records := []*store.DecisionRecord{
+		{
+			CycleNumber:  1,
+			Timestamp:    base,
+			AccountState: store.AccountSnapshot{TotalBalance: 1000},
+			Decisions: []store.DecisionAction{
+				{Action: "open_long", Symbol: "BTCUSDT", Reasoning: "breakout"},
+				{Action: "hold", Symbol: "ETHUSDT"},
+			},
+		},
+		{
+			CycleNumber:  2,
+			Timestamp:    base.Add(time.Minute),
+			AccountState: store.AccountSnapshot{TotalBalance: 1000},
+			CoTTrace:     "trend reversed",
+			Decisions:    []store.DecisionAction{{Action: "close_long", Symbol: "BTCUSDT"}},
+		},
+	}
+	trades := []backtest.TradeEvent{
+		{Cycle: 2, Symbol: "BTCUSDT", Action: "close_long", RealizedPnL: 25},
+	}
+
+	samples := sampleDecisions(records, trades, 10)
+	if len(samples) != 2 {
+		t.Fatalf("expected hold to be skipped, got %d samples", len(samples))
+	}
+	if samples[0].Action != "open_long" || samples[0].IsKeyEvent {
+		t.Fatalf("unexpected first sample: %+v", samples[0])
+	}
+	closing := samples[1]
+	if closing.PnL != 25 || !closing.IsKeyEvent {
+		t.Fatalf("expected closing decision to be a key event with PnL 25, got %+v", closing)
+	}
+	if closing.Reasoning != "trend reversed" {
+		t.Fatalf("expected reasoning to fall back to the CoT trace, got %q", closing.Reasoning)
+	}
+}
+
+func TestTruncateDecisionSamplesRetainsKeyEvents(t *testing.T) {
+	var samples []DecisionSample
+	for i := 0; i < 20; i++ {
+		samples = append(samples, DecisionSample{
+			Timestamp: int64(i),
+			Symbol:    fmt.Sprintf("COIN%d", i),
+			Action:    "open_long",
+		})
+	}
+	// Key events are the oldest decisions, so a recency cut alone would drop them
+	samples[0].IsKeyEvent, samples[0].PnL = true, -80
+	samples[1].IsKeyEvent, samples[1].PnL = true, 50
+	samples[2].IsKeyEvent, samples[2].PnL = true, 120
+
+	got := truncateDecisionSamples(samples, 5)
+	if len(got) != 5 {
+		t.Fatalf("expected 5 samples, got %d", len(got))
+	}
+
+	keyEvents := 0
+	for i, s := range got {
+		if s.IsKeyEvent {
+			keyEvents++
+		}
+		if i > 0 && got[i-1].Timestamp > s.Timestamp {
+			t.Fatalf("samples not in chronological order: %+v", got)
+		}
+	}
+	if keyEvents != 3 {
+		t.Fatalf("expected all 3 key events to be retained, got %d", keyEvents)
+	}
+	// The remaining slots go to the most recent decisions
+	if got[3].Timestamp != 18 || got[4].Timestamp != 19 {
+		t.Fatalf("expected most recent decisions to fill remaining slots, got %+v", got[3:])
+	}
+}
+
+func TestTruncateDecisionSamplesPrefersLargestKeyEvents(t *testing.T) {
+	samples := []DecisionSample{
+		{Timestamp: 1, IsKeyEvent: true, PnL: 10},
+		{Timestamp: 2, IsKeyEvent: true, PnL: -90},
+		{Timestamp: 3, IsKeyEvent: true, PnL: 40},
+	}
+
+	got := truncateDecisionSamples(samples, 2)
+	if len(got) != 2 || got[0].PnL != -90 || got[1].PnL != 40 {
+		t.Fatalf("expected the two largest key events in order, got %+v", got)
+	}
+}
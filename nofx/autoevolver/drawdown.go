@@ -0,0 +1,117 @@
+package autoevolver
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"nofx/backtest"
+)
+
+// drawdownEventPct is the depth from which a drawdown counts as a distinct drawdown event
+const drawdownEventPct = 5.0
+
+// DrawdownAnalysis describes the deepest drawdown of an equity curve and how often the
+// strategy went through significant drawdowns
+type DrawdownAnalysis struct {
+	MaxDrawdownPct float64 // depth of the deepest drawdown, peak to trough
+	PeakTime       int64   // timestamp of the peak the deepest drawdown started from
+	TroughTime     int64   // timestamp of the lowest equity of the deepest drawdown
+	RecoveryTime   int64   // timestamp equity regained the peak, 0 when not recovered
+	DurationBars   int     // bars from the peak to the recovery, or to the end of the curve
+	Recovered      bool
+	TotalBars      int
+	Events         int // drawdowns at least ThresholdPct deep
+	ThresholdPct   float64
+}
+
+// analyzeDrawdowns walks an equity curve and measures its drawdown periods. A drawdown runs
+// from an equity peak until equity regains that peak; it returns nil for curves too short to
+// contain one.
+func analyzeDrawdowns(points []backtest.EquityPoint, thresholdPct float64) *DrawdownAnalysis {
+	if len(points) < 2 {
+		return nil
+	}
+
+	result := &DrawdownAnalysis{
+		TotalBars:    len(points) - 1,
+		ThresholdPct: thresholdPct,
+	}
+
+	peak, peakIdx := points[0].Equity, 0
+	depth := 0.0     // deepest point of the current drawdown
+	maxPeakIdx := -1 // peak of the deepest drawdown seen so far
+	for i, p := range points {
+		if p.Equity >= peak {
+			if depth > 0 {
+				if depth >= thresholdPct {
+					result.Events++
+				}
+				if maxPeakIdx == peakIdx && !result.Recovered {
+					result.Recovered = true
+					result.RecoveryTime = p.Timestamp
+					result.DurationBars = i - peakIdx
+				}
+			}
+			peak, peakIdx, depth = p.Equity, i, 0
+			continue
+		}
+		if peak <= 0 {
+			continue
+		}
+
+		dd := (peak - p.Equity) / peak * 100
+		if dd > depth {
+			depth = dd
+		}
+		if dd > result.MaxDrawdownPct {
+			if maxPeakIdx != peakIdx {
+				maxPeakIdx = peakIdx
+				result.Recovered = false
+				result.RecoveryTime = 0
+			}
+			result.MaxDrawdownPct = dd
+			result.PeakTime = points[peakIdx].Timestamp
+			result.TroughTime = p.Timestamp
+		}
+	}
+
+	// The last drawdown is still open at the end of the curve
+	if depth > 0 && depth >= thresholdPct {
+		result.Events++
+	}
+	if maxPeakIdx >= 0 && !result.Recovered {
+		result.DurationBars = len(points) - 1 - maxPeakIdx
+	}
+	return result
+}
+
+// IsLongUnrecovered reports whether the deepest drawdown never recovered and lasted at
+// least a quarter of the backtest
+func (d *DrawdownAnalysis) IsLongUnrecovered() bool {
+	return d != nil && d.MaxDrawdownPct > 0 && !d.Recovered && d.DurationBars*4 >= d.TotalBars
+}
+
+// writeDrawdownAnalysis writes the drawdown section of the analysis prompt
+func writeDrawdownAnalysis(sb *strings.Builder, d *DrawdownAnalysis) {
+	if d == nil || d.MaxDrawdownPct <= 0 {
+		return
+	}
+
+	sb.WriteString("\n## Drawdown Analysis\n\n")
+	sb.WriteString(fmt.Sprintf("- Deepest Drawdown: %.2f%% from %s to trough at %s\n",
+		d.MaxDrawdownPct, formatEquityTime(d.PeakTime), formatEquityTime(d.TroughTime)))
+	if d.Recovered {
+		sb.WriteString(fmt.Sprintf("- Recovered at %s after %d of %d bars\n",
+			formatEquityTime(d.RecoveryTime), d.DurationBars, d.TotalBars))
+	} else {
+		sb.WriteString(fmt.Sprintf("- NOT recovered by the end of the backtest (%d of %d bars under water)\n",
+			d.DurationBars, d.TotalBars))
+	}
+	sb.WriteString(fmt.Sprintf("- Drawdowns deeper than %.0f%%: %d\n", d.ThresholdPct, d.Events))
+}
+
+// formatEquityTime formats an equity point timestamp (unix milliseconds)
+func formatEquityTime(ts int64) string {
+	return time.UnixMilli(ts).UTC().Format("2006-01-02 15:04")
+}
@@ -0,0 +1,83 @@
+package autoevolver
+
+import (
+	"strings"
+	"testing"
+
+	"nofx/backtest"
+)
+
+func equityCurve(values ...float64) []backtest.EquityPoint {
+	points := make([]backtest.EquityPoint, len(values))
+	for i, v := range values {
+		points[i] = backtest.EquityPoint{Timestamp: int64(i) * 60_000, Equity: v}
+	}
+	return points
+}
+
+func TestAnalyzeDrawdownsFindsTroughAndRecovery(t *testing.T) {
+	// A 3% dip, then a 20% drawdown from the 1100 peak recovering at bar 7
+	curve := equityCurve(1000, 970, 1000, 1100, 990, 880, 1000, 1120, 1150)
+
+	got := analyzeDrawdowns(curve, drawdownEventPct)
+	if got == nil {
+		t.Fatal("expected a drawdown analysis")
+	}
+	if got.MaxDrawdownPct != 20 {
+		t.Fatalf("expected a 20%% max drawdown, got %.2f", got.MaxDrawdownPct)
+	}
+	if got.PeakTime != curve[3].Timestamp || got.TroughTime != curve[5].Timestamp {
+		t.Fatalf("expected peak at bar 3 and trough at bar 5, got %+v", got)
+	}
+	if !got.Recovered || got.RecoveryTime != curve[7].Timestamp || got.DurationBars != 4 {
+		t.Fatalf("expected recovery at bar 7 after 4 bars, got %+v", got)
+	}
+	if got.Events != 1 {
+		t.Fatalf("expected only the 20%% drawdown to exceed the threshold, got %d", got.Events)
+	}
+	if got.IsLongUnrecovered() {
+		t.Fatal("a recovered drawdown must not be reported as unrecovered")
+	}
+}
+
+func TestAnalyzeDrawdownsReportsUnrecoveredDrawdown(t *testing.T) {
+	// A recovered 10% drawdown, then a 25% drawdown still open at the end
+	curve := equityCurve(1000, 900, 1000, 1200, 1000, 900, 950, 920)
+
+	got := analyzeDrawdowns(curve, drawdownEventPct)
+	if got.MaxDrawdownPct != 25 || got.Recovered || got.RecoveryTime != 0 {
+		t.Fatalf("expected an unrecovered 25%% drawdown, got %+v", got)
+	}
+	if got.DurationBars != 4 || got.TotalBars != 7 {
+		t.Fatalf("expected 4 of 7 bars under water, got %+v", got)
+	}
+	if got.Events != 2 {
+		t.Fatalf("expected 2 drawdowns over the threshold, got %d", got.Events)
+	}
+	if !got.IsLongUnrecovered() {
+		t.Fatal("expected the drawdown to count as long and unrecovered")
+	}
+
+	report := NewAnalyzer(nil).createFallbackReport(&backtest.Metrics{MaxDrawdownPct: 25}, got)
+	found := false
+	for _, w := range report.Weaknesses {
+		if strings.Contains(w, "not recovered") {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("expected fallback report to flag the unrecovered drawdown, got %v", report.Weaknesses)
+	}
+
+	var sb strings.Builder
+	writeDrawdownAnalysis(&sb, got)
+	if !strings.Contains(sb.String(), "NOT recovered") {
+		t.Fatalf("expected prompt to mention the open drawdown, got %q", sb.String())
+	}
+}
+
+func TestAnalyzeDrawdownsNeedsTwoPoints(t *testing.T) {
+	if got := analyzeDrawdowns(equityCurve(1000), drawdownEventPct); got != nil {
+		t.Fatalf("expected nil for a single point, got %+v", got)
+	}
+}
@@ -2,6 +2,9 @@ package autoevolver
 
 import (
 	"context"
+	"fmt"
+	"sync"
+	"time"
 
 	"nofx/backtest"
 	"nofx/logger"
@@ -9,45 +12,80 @@ import (
 	"nofx/store"
 )
 
+// BacktestManager is the subset of backtest.Manager used by the evolver
+type BacktestManager interface {
+	Start(ctx context.Context, cfg backtest.BacktestConfig) (*backtest.Runner, error)
+	Status(runID string) *backtest.StatusPayload
+	LoadMetadata(runID string) (*backtest.RunMetadata, error)
+	Delete(runID string) error
+	GetMetrics(runID string) (*backtest.Metrics, error)
+	LoadTrades(runID string, limit int) ([]backtest.TradeEvent, error)
+	LoadEquity(runID string, timeframe string, limit int) ([]backtest.EquityPoint, error)
+	LoadDecisions(runID string, limit, offset int) ([]*store.DecisionRecord, error)
+}
+
 // AutoEvolver manages the automatic evolution process
 type AutoEvolver struct {
-	evolutionID string
-	config      *EvolutionConfig
-	backtestMgr *backtest.Manager
-	aiClient    mcp.AIClient
-	store       *store.Store
-	status      string
-	stopChan    chan struct{}
-	pauseChan   chan struct{}
-	isPaused    bool
+	evolutionID  string
+	config       *EvolutionConfig
+	backtestMgr  BacktestManager
+	aiClient     mcp.AIClient // Optimizes prompts; also analyzes backtests unless evalClient is set
+	evalClient   mcp.AIClient // Analyzes backtests when EvaluationModel names another model
+	store        *store.Store
+	stopChan     chan struct{}
+	stopOnce     sync.Once
+	pollInterval time.Duration    // How often backtest status is polled
+	webhook      *webhookNotifier // nil unless config.WebhookURL is set
+
+	inactivityTimeout time.Duration // A backtest without progress for this long is stalled
+
+	newModelClient func(*store.AIModel) (mcp.AIClient, error) // Creates the client of a stored AI model
+
+	// mu guards status, isPaused and resumeChan, which are written by API handlers
+	// while the evolution loop reads them
+	mu         sync.Mutex
+	status     string
+	isPaused   bool
+	resumeChan chan struct{} // Closed by Resume; only non-nil while paused
 }
 
 // NewAutoEvolver creates a new AutoEvolver instance
 func NewAutoEvolver(
 	evolutionID string,
 	config *EvolutionConfig,
-	backtestMgr *backtest.Manager,
+	backtestMgr BacktestManager,
 	aiClient mcp.AIClient,
 	st *store.Store,
 ) *AutoEvolver {
 	return &AutoEvolver{
-		evolutionID: evolutionID,
-		config:      config,
-		backtestMgr: backtestMgr,
-		aiClient:    aiClient,
-		store:       st,
-		status:      StatusCreated,
-		stopChan:    make(chan struct{}),
-		pauseChan:   make(chan struct{}),
-		isPaused:    false,
+		evolutionID:  evolutionID,
+		config:       config,
+		backtestMgr:  backtestMgr,
+		aiClient:     aiClient,
+		store:        st,
+		status:       StatusCreated,
+		stopChan:     make(chan struct{}),
+		pollInterval: 5 * time.Second,
+		webhook:      newWebhookNotifier(config.WebhookURL),
+
+		inactivityTimeout: inactivityTimeout(config),
+
+		newModelClient: newModelClient,
 	}
 }
 
 // Start begins the evolution process
 func (e *AutoEvolver) Start(ctx context.Context) error {
-	e.status = StatusRunning
+	e.setStatus(StatusRunning)
 	logger.Infof("Starting evolution %s", e.evolutionID)
 
+	if err := e.hydrateEvaluationClient(); err != nil {
+		logger.Errorf("Evolution %s failed to start: %v", e.evolutionID, err)
+		e.setStatus(StatusStopped)
+		e.store.Evolution().UpdateStatus(e.evolutionID, StatusStopped)
+		return err
+	}
+
 	// Get current progress from database to resume from correct iteration
 	evolution, err := e.store.Evolution().Get(e.config.UserID, e.evolutionID)
 	startVersion := 1
@@ -63,7 +101,7 @@ func (e *AutoEvolver) Start(ctx context.Context) error {
 			lastIter := iterations[len(iterations)-1]
 			logger.Infof("Evolution %s: lastIter version=%d, status=%s", e.evolutionID, lastIter.Version, lastIter.Status)
 
-			if lastIter.Status == "completed" {
+			if lastIter.Status == IterStatusCompleted {
 				// Last iteration completed, start from next
 				startVersion = evolution.CurrentIteration + 1
 			} else {
@@ -77,22 +115,43 @@ func (e *AutoEvolver) Start(ctx context.Context) error {
 		logger.Infof("Evolution %s: resuming from iteration %d", e.evolutionID, startVersion)
 	}
 
-	for version := startVersion; version <= e.config.MaxIterations; version++ {
+	step := 1
+	for version := startVersion; version <= e.config.MaxIterations; version += step {
 		// Check for stop signal
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
 		case <-e.stopChan:
 			logger.Infof("Evolution %s stopped by user", e.evolutionID)
+			e.notify(WebhookEventStopped, version, nil, fmt.Sprintf("Evolution %s stopped by user before v%d", e.config.Name, version))
 			return nil
 		default:
 		}
 
+		// Stop once the AI token budget is spent, including by an earlier run of this evolution
+		if exceeded, used := e.tokenBudgetExceeded(); exceeded {
+			logger.Warnf("Evolution %s stopped: AI token budget exhausted (%d/%d tokens)",
+				e.evolutionID, used, e.config.MaxTotalTokens)
+			e.setStatus(StatusStopped)
+			e.store.Evolution().UpdateStatus(e.evolutionID, StatusStopped)
+			e.notify(WebhookEventStopped, version, nil, fmt.Sprintf("Evolution %s stopped: AI token budget exhausted (%d/%d tokens)",
+				e.config.Name, used, e.config.MaxTotalTokens))
+			return nil
+		}
+
 		// Check for pause signal
-		if e.isPaused {
+		if resumeChan := e.pausedChan(); resumeChan != nil {
 			logger.Infof("Evolution %s paused at version %d", e.evolutionID, version)
-			<-e.pauseChan // Wait for resume
-			logger.Infof("Evolution %s resumed", e.evolutionID)
+			select {
+			case <-resumeChan:
+				logger.Infof("Evolution %s resumed", e.evolutionID)
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-e.stopChan:
+				logger.Infof("Evolution %s stopped by user", e.evolutionID)
+				e.notify(WebhookEventStopped, version, nil, fmt.Sprintf("Evolution %s stopped by user while paused before v%d", e.config.Name, version))
+				return nil
+			}
 		}
 
 		// Run single iteration
@@ -101,46 +160,129 @@ func (e *AutoEvolver) Start(ctx context.Context) error {
 		// Update current iteration BEFORE running (so we can resume from this iteration if it fails)
 		e.store.Evolution().UpdateCurrentIteration(e.evolutionID, version)
 
-		if err := e.runIteration(ctx, version); err != nil {
+		// Population and parallel modes evaluate several candidates per generation, each taking its own version
+		var err error
+		usageBefore := e.tokenUsage()
+		switch {
+		case e.populationMode():
+			step, err = e.runPopulationGeneration(ctx, version)
+		case e.config.ParallelCandidates > 1:
+			step, err = e.runGeneration(ctx, version)
+		default:
+			err = e.runIteration(ctx, version)
+		}
+		e.recordTokenUsage(version, e.tokenUsage().Sub(usageBefore))
+		if err != nil {
 			logger.Errorf("Evolution %s iteration %d failed: %v", e.evolutionID, version, err)
 			e.store.Evolution().UpdateStatus(e.evolutionID, StatusStopped)
+			e.notify(WebhookEventFailed, version, nil, fmt.Sprintf("Evolution %s failed at v%d: %v", e.config.Name, version, err))
 			return err
 		}
+
+		// Stop early once too many iterations in a row failed to improve on the best
+		if converged, reason := e.checkConvergence(); converged {
+			logger.Infof("Evolution %s converged after iteration %d: %s", e.evolutionID, version+step-1, reason)
+			e.setStatus(StatusCompleted)
+			e.store.Evolution().UpdateStatus(e.evolutionID, StatusCompleted)
+			e.notify(WebhookEventCompleted, version+step-1, nil, fmt.Sprintf("Evolution %s converged after v%d: %s", e.config.Name, version+step-1, reason))
+			return nil
+		}
 	}
 
 	logger.Infof("Evolution %s completed all %d iterations", e.evolutionID, e.config.MaxIterations)
-	e.status = StatusCompleted
+	e.setStatus(StatusCompleted)
 	e.store.Evolution().UpdateStatus(e.evolutionID, StatusCompleted)
+	e.notify(WebhookEventCompleted, e.config.MaxIterations, nil, fmt.Sprintf("Evolution %s completed all %d iterations", e.config.Name, e.config.MaxIterations))
 	return nil
 }
 
-// Pause pauses the evolution process
+// Pause pauses the evolution process before its next iteration
 func (e *AutoEvolver) Pause() error {
-	e.isPaused = true
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.isPaused {
+		e.isPaused = true
+		e.resumeChan = make(chan struct{})
+	}
 	e.status = StatusPaused
 	logger.Infof("Evolution %s paused", e.evolutionID)
 	return nil
 }
 
-// Resume resumes the evolution process
+// Resume resumes the evolution process; it is a no-op when not paused
 func (e *AutoEvolver) Resume(ctx context.Context) error {
-	e.isPaused = false
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.isPaused {
+		e.isPaused = false
+		close(e.resumeChan)
+		e.resumeChan = nil
+	}
 	e.status = StatusRunning
-	close(e.pauseChan)
-	e.pauseChan = make(chan struct{})
 	logger.Infof("Evolution %s resumed", e.evolutionID)
 	return nil
 }
 
-// Stop stops the evolution process
+// Stop stops the evolution process; calling it more than once is safe
 func (e *AutoEvolver) Stop() error {
-	e.status = StatusStopped
-	close(e.stopChan)
+	e.setStatus(StatusStopped)
+	e.stopOnce.Do(func() { close(e.stopChan) })
 	logger.Infof("Evolution %s stopped", e.evolutionID)
 	return nil
 }
 
 // GetStatus returns the current status
 func (e *AutoEvolver) GetStatus() string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
 	return e.status
 }
+
+// setStatus updates the current status
+func (e *AutoEvolver) setStatus(status string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.status = status
+}
+
+// pausedChan returns the channel closed on resume, or nil when not paused
+func (e *AutoEvolver) pausedChan() chan struct{} {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.resumeChan
+}
+
+// GetEvolutionStatus returns the evolution with its recent iterations and convergence state.
+// It is polled by the UI, so only the recent iterations are loaded in full; totals come from
+// an aggregate query and the frontier from the scored iterations without their prompts.
+func (e *AutoEvolver) GetEvolutionStatus() (*EvolutionStatus, error) {
+	evolution, err := e.store.Evolution().Get(e.config.UserID, e.evolutionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get evolution: %w", err)
+	}
+	stats, err := e.store.Evolution().GetIterationStats(e.evolutionID, evolution.BestVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get iteration stats: %w", err)
+	}
+	recent, _, err := e.store.Evolution().GetIterationsPaged(e.evolutionID, 5, stats.Count-5)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get iterations: %w", err)
+	}
+	scored, err := e.store.Evolution().GetScoredIterations(e.evolutionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get scored iterations: %w", err)
+	}
+
+	status := &EvolutionStatus{Evolution: evolution}
+	if len(recent) > 0 {
+		status.CurrentIteration = recent[len(recent)-1]
+		status.RecentIterations = recent
+	}
+	status.ParetoFrontier = ParetoFrontier(scored)
+	status.PromptTokens = stats.PromptTokens
+	status.CompletionTokens = stats.CompletionTokens
+	status.StagnantIterations = stats.CompletedAfter
+	status.ConvergenceThreshold = e.config.ConvergenceThreshold
+	status.IsConverged, status.ConvergeReason = convergedAfter(stats.CompletedAfter, evolution.BestVersion, e.config.ConvergenceThreshold)
+	return status, nil
+}
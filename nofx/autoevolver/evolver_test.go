@@ -0,0 +1,191 @@
+package autoevolver
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStartStopsAtConvergenceThreshold(t *testing.T) {
+	mgr := newStubBacktestManager(time.Millisecond, map[string]float64{"base-prompt": -1})
+	cfg := &EvolutionConfig{
+		UserID:               "user-1",
+		Name:                 "evo",
+		BaseStrategyID:       "base",
+		MaxIterations:        10,
+		ConvergenceThreshold: 3,
+		FixedParams:          FixedParams{AIModelID: "model-1"},
+	}
+	evolver, st := newTestEvolver(t, cfg, mgr)
+
+	if err := evolver.Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	if len(mgr.runs) != 3 {
+		t.Fatalf("expected evolution to stop after 3 non-improving iterations, ran %d", len(mgr.runs))
+	}
+	if evolver.GetStatus() != StatusCompleted {
+		t.Errorf("expected status %s, got %s", StatusCompleted, evolver.GetStatus())
+	}
+
+	evolution, err := st.Evolution().Get("user-1", "evo-1")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if evolution.Status != StatusCompleted {
+		t.Errorf("expected stored status %s, got %s", StatusCompleted, evolution.Status)
+	}
+
+	status, err := evolver.GetEvolutionStatus()
+	if err != nil {
+		t.Fatalf("GetEvolutionStatus failed: %v", err)
+	}
+	if !status.IsConverged || status.ConvergeReason == "" {
+		t.Errorf("expected converged status with reason, got %+v", status)
+	}
+	if status.CurrentIteration == nil || status.CurrentIteration.Version != 3 {
+		t.Errorf("expected current iteration v3, got %+v", status.CurrentIteration)
+	}
+}
+
+func TestConvergenceCountsIterationsSinceBest(t *testing.T) {
+	cfg := &EvolutionConfig{UserID: "user-1", Name: "evo", BaseStrategyID: "base", MaxIterations: 5,
+		ConvergenceThreshold: 3, FixedParams: FixedParams{AIModelID: "model-1"}}
+	evolver, st := newTestEvolver(t, cfg, newStubBacktestManager(time.Millisecond, nil))
+	for version, status := range []string{IterStatusCompleted, IterStatusCompleted, IterStatusCompleted, IterStatusFailed, IterStatusCompleted} {
+		if err := st.Evolution().CreateIteration(&Iteration{EvolutionID: "evo-1", Version: version + 1, StrategyID: "base", Status: status}); err != nil {
+			t.Fatalf("CreateIteration failed: %v", err)
+		}
+	}
+
+	st.Evolution().UpdateBestVersion("evo-1", 2, 0, 0)
+	if converged, _ := evolver.checkConvergence(); converged {
+		t.Error("failed iterations should not count towards convergence")
+	}
+	st.Evolution().UpdateBestVersion("evo-1", 1, 0, 0)
+	if converged, reason := evolver.checkConvergence(); !converged || reason == "" {
+		t.Errorf("expected convergence after 3 iterations past best, got %v %q", converged, reason)
+	}
+	if converged, _ := convergedAfter(3, 1, 0); converged {
+		t.Error("a zero threshold disables convergence")
+	}
+}
+
+func TestEvolutionStatusReportsStagnantIterations(t *testing.T) {
+	tests := []struct {
+		name          string
+		statuses      []string // Statuses of v1..vN
+		bestVersion   int
+		wantStagnant  int
+		wantConverged bool
+	}{
+		{"best is latest", []string{IterStatusCompleted, IterStatusCompleted}, 2, 0, false},
+		{"near convergence", []string{IterStatusCompleted, IterStatusCompleted, IterStatusCompleted}, 1, 2, false},
+		{"failed iterations do not count", []string{IterStatusCompleted, IterStatusCompleted, IterStatusFailed, IterStatusCompleted}, 1, 2, false},
+		{"converged", []string{IterStatusCompleted, IterStatusCompleted, IterStatusCompleted, IterStatusCompleted}, 1, 3, true},
+		{"no best version", []string{IterStatusCompleted, IterStatusCompleted, IterStatusCompleted}, 0, 3, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &EvolutionConfig{UserID: "user-1", BaseStrategyID: "base", ConvergenceThreshold: 3}
+			evolver, st := newTestEvolver(t, cfg, newStubBacktestManager(0, nil))
+			for i, status := range tt.statuses {
+				iter := &Iteration{EvolutionID: "evo-1", Version: i + 1, StrategyID: "base", Status: status}
+				if err := st.Evolution().CreateIteration(iter); err != nil {
+					t.Fatalf("CreateIteration failed: %v", err)
+				}
+			}
+			if tt.bestVersion > 0 {
+				if err := st.Evolution().UpdateBestVersion("evo-1", tt.bestVersion, 5, 10); err != nil {
+					t.Fatalf("UpdateBestVersion failed: %v", err)
+				}
+			}
+
+			status, err := evolver.GetEvolutionStatus()
+			if err != nil {
+				t.Fatalf("GetEvolutionStatus failed: %v", err)
+			}
+			if status.StagnantIterations != tt.wantStagnant || status.ConvergenceThreshold != 3 {
+				t.Errorf("expected %d/3 stagnant iterations, got %d/%d", tt.wantStagnant, status.StagnantIterations, status.ConvergenceThreshold)
+			}
+			if status.IsConverged != tt.wantConverged {
+				t.Errorf("expected converged %v, got %v (%q)", tt.wantConverged, status.IsConverged, status.ConvergeReason)
+			}
+		})
+	}
+}
+
+func TestPauseResumeConcurrentWithRunningLoop(t *testing.T) {
+	mgr := newStubBacktestManager(time.Millisecond, map[string]float64{})
+	cfg := &EvolutionConfig{
+		UserID:         "user-1",
+		Name:           "evo",
+		BaseStrategyID: "base",
+		MaxIterations:  20,
+		FixedParams:    FixedParams{AIModelID: "model-1"},
+	}
+	evolver, _ := newTestEvolver(t, cfg, mgr)
+
+	done := make(chan error, 1)
+	go func() { done <- evolver.Start(context.Background()) }()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				if (i+j)%2 == 0 {
+					evolver.Pause()
+				} else {
+					evolver.Resume(context.Background())
+				}
+				_ = evolver.GetStatus()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// Leave the loop unpaused so it can run to completion
+	evolver.Resume(context.Background())
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Start failed: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("evolution did not finish after final resume")
+	}
+	if evolver.GetStatus() != StatusCompleted {
+		t.Errorf("expected status %s, got %s", StatusCompleted, evolver.GetStatus())
+	}
+}
+
+func TestStopWhilePausedEndsLoop(t *testing.T) {
+	mgr := newStubBacktestManager(time.Millisecond, map[string]float64{})
+	cfg := &EvolutionConfig{
+		UserID:         "user-1",
+		Name:           "evo",
+		BaseStrategyID: "base",
+		MaxIterations:  5,
+		FixedParams:    FixedParams{AIModelID: "model-1"},
+	}
+	evolver, _ := newTestEvolver(t, cfg, mgr)
+	evolver.Pause()
+
+	done := make(chan error, 1)
+	go func() { done <- evolver.Start(context.Background()) }()
+
+	evolver.Stop()
+	evolver.Stop() // second stop must not panic
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Start failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("paused evolution did not observe stop")
+	}
+}
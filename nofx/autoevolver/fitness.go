@@ -0,0 +1,141 @@
+package autoevolver
+
+import (
+	"fmt"
+
+	"nofx/backtest"
+	"nofx/evotypes"
+)
+
+// FitnessInput is the performance of one backtest as judged by a FitnessFunc
+type FitnessInput struct {
+	TotalReturn float64 // percent
+	MaxDrawdown float64 // percent
+	SharpeRatio float64
+	Trades      int
+	// PerPeriodSharpe marks a SharpeRatio stored before annualization (see evotypes.Metrics)
+	PerPeriodSharpe bool
+}
+
+// FitnessFunc decides whether a result improves on the current best.
+// Every improvement decision in the evolver goes through one, so best-version
+// promotion and the history shown to the optimizer can never disagree.
+type FitnessFunc interface {
+	// Improves reports whether candidate beats best and, if so, why
+	Improves(candidate, best FitnessInput) (bool, string)
+}
+
+// Default thresholds of ThresholdFitness, in percentage points
+const (
+	defaultReturnTolerancePct     = 3.0
+	defaultDrawdownImprovementPct = 5.0
+)
+
+// ThresholdFitness is the default improvement rule:
+// 1. Higher return is always better
+// 2. Similar return (within ReturnTolerancePct) but significantly better drawdown
+// (DrawdownImprovementPct or more) is also an improvement
+// Nil thresholds use the defaults of 3 and 5 points.
+type ThresholdFitness struct {
+	ReturnTolerancePct     *float64
+	DrawdownImprovementPct *float64
+}
+
+// Improves implements FitnessFunc
+func (f ThresholdFitness) Improves(candidate, best FitnessInput) (bool, string) {
+	returnTolerance := defaultReturnTolerancePct
+	if f.ReturnTolerancePct != nil {
+		returnTolerance = *f.ReturnTolerancePct
+	}
+	minDrawdownImprovement := defaultDrawdownImprovementPct
+	if f.DrawdownImprovementPct != nil {
+		minDrawdownImprovement = *f.DrawdownImprovementPct
+	}
+
+	returnDiff := candidate.TotalReturn - best.TotalReturn
+	drawdownImprovement := best.MaxDrawdown - candidate.MaxDrawdown
+	if returnDiff > 0 {
+		return true, fmt.Sprintf("higher return (%.2f%% vs %.2f%%)", candidate.TotalReturn, best.TotalReturn)
+	}
+	if returnDiff >= -returnTolerance && drawdownImprovement >= minDrawdownImprovement {
+		return true, fmt.Sprintf("similar return (%.2f%% vs %.2f%%) with better drawdown (%.2f%% vs %.2f%%)",
+			candidate.TotalReturn, best.TotalReturn, candidate.MaxDrawdown, best.MaxDrawdown)
+	}
+	return false, ""
+}
+
+// WeightedFitness collapses a result into a single score:
+// return×ReturnWeight − drawdown×DrawdownWeight + Sharpe×SharpeWeight − trades×TradeCountPenalty
+type WeightedFitness struct {
+	Weights evotypes.FitnessWeights
+}
+
+// Score returns the weighted fitness score of a result
+func (f WeightedFitness) Score(in FitnessInput) float64 {
+	w := f.Weights
+	return in.TotalReturn*w.ReturnWeight -
+		in.MaxDrawdown*w.DrawdownWeight +
+		in.SharpeRatio*w.SharpeWeight -
+		float64(in.Trades)*w.TradeCountPenalty
+}
+
+// Improves implements FitnessFunc. When only one side's Sharpe ratio is annualized the
+// Sharpe term is left out of both scores, as the two values are not comparable.
+func (f WeightedFitness) Improves(candidate, best FitnessInput) (bool, string) {
+	if candidate.PerPeriodSharpe != best.PerPeriodSharpe {
+		candidate.SharpeRatio, best.SharpeRatio = 0, 0
+	}
+	candidateScore, bestScore := f.Score(candidate), f.Score(best)
+	if candidateScore > bestScore {
+		return true, fmt.Sprintf("higher fitness score (%.2f vs %.2f)", candidateScore, bestScore)
+	}
+	return false, ""
+}
+
+// NewFitnessFunc returns the weighted fitness function when weights are configured,
+// otherwise the default threshold rule
+func NewFitnessFunc(weights *evotypes.FitnessWeights) FitnessFunc {
+	if weights == nil {
+		return ThresholdFitness{}
+	}
+	return WeightedFitness{Weights: *weights}
+}
+
+// noImprovementReason explains why candidate was not promoted over best
+func noImprovementReason(candidate, best FitnessInput) string {
+	return fmt.Sprintf("no improvement (return %.2f%% vs best %.2f%%, drawdown %.2f%% vs best %.2f%%)",
+		candidate.TotalReturn, best.TotalReturn, candidate.MaxDrawdown, best.MaxDrawdown)
+}
+
+// fitnessFromBacktest converts backtest metrics into a fitness input
+func fitnessFromBacktest(m *backtest.Metrics) FitnessInput {
+	return FitnessInput{TotalReturn: m.TotalReturnPct, MaxDrawdown: m.MaxDrawdownPct, SharpeRatio: m.SharpeRatio, Trades: m.Trades}
+}
+
+// fitnessFromMetrics converts stored iteration metrics into a fitness input
+func fitnessFromMetrics(m *evotypes.Metrics) FitnessInput {
+	return FitnessInput{TotalReturn: m.TotalReturn, MaxDrawdown: m.MaxDrawdown, SharpeRatio: m.SharpeRatio, Trades: m.Trades,
+		PerPeriodSharpe: m.PerPeriodSharpe}
+}
+
+// fitness returns the evolution's configured fitness function
+func (e *AutoEvolver) fitness() FitnessFunc {
+	if e.config.FitnessWeights == nil {
+		return ThresholdFitness{
+			ReturnTolerancePct:     e.config.ReturnTolerancePct,
+			DrawdownImprovementPct: e.config.DrawdownImprovementPct,
+		}
+	}
+	return NewFitnessFunc(e.config.FitnessWeights)
+}
+
+// bestFitness returns the current best version's performance
+func (e *AutoEvolver) bestFitness() FitnessInput {
+	best := FitnessInput{TotalReturn: e.getBestReturn(), MaxDrawdown: e.getBestDrawdown()}
+	if iter := e.getBestIteration(); iter != nil && iter.Metrics != nil {
+		best.SharpeRatio = iter.Metrics.SharpeRatio
+		best.Trades = iter.Metrics.Trades
+		best.PerPeriodSharpe = iter.Metrics.PerPeriodSharpe
+	}
+	return best
+}
@@ -0,0 +1,246 @@
+package autoevolver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"nofx/evotypes"
+)
+
+func TestThresholdFitnessMatchesDefaultRule(t *testing.T) {
+	fitness := NewFitnessFunc(nil)
+	best := FitnessInput{TotalReturn: 10, MaxDrawdown: 20}
+
+	for _, tc := range []struct {
+		name      string
+		candidate FitnessInput
+		want      bool
+	}{
+		{"higher return", FitnessInput{TotalReturn: 10.5, MaxDrawdown: 30}, true},
+		{"similar return, much lower drawdown", FitnessInput{TotalReturn: 8, MaxDrawdown: 14}, true},
+		{"similar return, slightly lower drawdown", FitnessInput{TotalReturn: 8, MaxDrawdown: 17}, false},
+		{"much lower return, lower drawdown", FitnessInput{TotalReturn: 6, MaxDrawdown: 5}, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, reason := fitness.Improves(tc.candidate, best)
+			if got != tc.want {
+				t.Errorf("expected %v, got %v", tc.want, got)
+			}
+			if got && reason == "" {
+				t.Error("improvements should carry a reason")
+			}
+		})
+	}
+}
+
+func TestWeightedFitness(t *testing.T) {
+	highReturn := FitnessInput{TotalReturn: 20, MaxDrawdown: 25, SharpeRatio: 0.8, Trades: 40}
+	lowDrawdown := FitnessInput{TotalReturn: 12, MaxDrawdown: 6, SharpeRatio: 1.1, Trades: 30}
+	highSharpe := FitnessInput{TotalReturn: 10, MaxDrawdown: 10, SharpeRatio: 2.5, Trades: 10}
+
+	for _, tc := range []struct {
+		name    string
+		weights evotypes.FitnessWeights
+		winner  FitnessInput
+		losers  []FitnessInput
+	}{
+		{
+			name:    "return dominant",
+			weights: evotypes.FitnessWeights{ReturnWeight: 1, DrawdownWeight: 0.1},
+			winner:  highReturn,
+			losers:  []FitnessInput{lowDrawdown, highSharpe},
+		},
+		{
+			name:    "drawdown dominant",
+			weights: evotypes.FitnessWeights{ReturnWeight: 0.2, DrawdownWeight: 1},
+			winner:  lowDrawdown,
+			losers:  []FitnessInput{highReturn, highSharpe},
+		},
+		{
+			name:    "sharpe weighted with trade penalty",
+			weights: evotypes.FitnessWeights{ReturnWeight: 0.5, DrawdownWeight: 0.5, SharpeWeight: 10, TradeCountPenalty: 0.1},
+			winner:  highSharpe,
+			losers:  []FitnessInput{highReturn, lowDrawdown},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fitness := NewFitnessFunc(&tc.weights)
+			for _, loser := range tc.losers {
+				if ok, _ := fitness.Improves(tc.winner, loser); !ok {
+					t.Errorf("expected %+v to beat %+v", tc.winner, loser)
+				}
+				if ok, _ := fitness.Improves(loser, tc.winner); ok {
+					t.Errorf("expected %+v not to beat %+v", loser, tc.winner)
+				}
+			}
+			if ok, _ := fitness.Improves(tc.winner, tc.winner); ok {
+				t.Error("an equal score is not an improvement")
+			}
+		})
+	}
+}
+
+func TestWeightedFitnessIgnoresSharpeAcrossUnits(t *testing.T) {
+	fitness := WeightedFitness{Weights: evotypes.FitnessWeights{ReturnWeight: 1, SharpeWeight: 10}}
+	best := FitnessInput{TotalReturn: 10, SharpeRatio: 0.05, PerPeriodSharpe: true}
+	candidate := FitnessInput{TotalReturn: 9, SharpeRatio: 1.5}
+
+	// An annualized Sharpe would win on its inflated scale; only return counts across units
+	if ok, _ := fitness.Improves(candidate, best); ok {
+		t.Error("expected Sharpe to be ignored against a per-period best")
+	}
+	candidate.TotalReturn = 11
+	if ok, _ := fitness.Improves(candidate, best); !ok {
+		t.Error("expected the higher return to win")
+	}
+
+	best.PerPeriodSharpe = false
+	candidate.TotalReturn = 9
+	if ok, _ := fitness.Improves(candidate, best); !ok {
+		t.Error("expected Sharpe to count when both are annualized")
+	}
+}
+
+func TestIterationHistoryFailedFlagUsesFitness(t *testing.T) {
+	cfg := &EvolutionConfig{
+		UserID:         "user-1",
+		BaseStrategyID: "base",
+		FitnessWeights: &evotypes.FitnessWeights{DrawdownWeight: 1},
+	}
+	evolver, st := newTestEvolver(t, cfg, newStubBacktestManager(0, nil))
+
+	for _, iter := range []*Iteration{
+		{EvolutionID: "evo-1", Version: 1, StrategyID: "base", Status: IterStatusCompleted, Metrics: &Metrics{TotalReturn: 5, MaxDrawdown: 10}},
+		{EvolutionID: "evo-1", Version: 2, StrategyID: "base", Status: IterStatusCompleted, Metrics: &Metrics{TotalReturn: 30, MaxDrawdown: 15}},
+		{EvolutionID: "evo-1", Version: 3, StrategyID: "base", Status: IterStatusCompleted, Metrics: &Metrics{TotalReturn: 1, MaxDrawdown: 8}},
+	} {
+		if err := st.Evolution().CreateIteration(iter); err != nil {
+			t.Fatalf("CreateIteration failed: %v", err)
+		}
+	}
+	if err := st.Evolution().UpdateBestVersion("evo-1", 1, 5, 10); err != nil {
+		t.Fatalf("UpdateBestVersion failed: %v", err)
+	}
+
+	history := evolver.getIterationHistory()
+	if len(history) != 3 {
+		t.Fatalf("expected 3 summaries, got %d", len(history))
+	}
+	// Drawdown-only fitness: v2's higher return does not help, v3's lower drawdown does
+	if history[0].Failed || !history[0].IsBest {
+		t.Errorf("v1 should be best, got %+v", history[0])
+	}
+	if !history[1].Failed {
+		t.Errorf("v2 should be failed under drawdown-only fitness, got %+v", history[1])
+	}
+	if history[2].Failed {
+		t.Errorf("v3 should not be failed under drawdown-only fitness, got %+v", history[2])
+	}
+}
+
+func TestCapIterationHistoryKeepsBest(t *testing.T) {
+	var history []IterationSummary
+	for v := 1; v <= 30; v++ {
+		history = append(history, IterationSummary{Version: v, IsBest: v == 3})
+	}
+
+	capped := capIterationHistory(history, 10)
+	if len(capped) != 10 {
+		t.Fatalf("expected 10 summaries, got %d", len(capped))
+	}
+	if capped[0].Version != 3 || !capped[0].IsBest {
+		t.Fatalf("expected the old best iteration to be kept first, got %+v", capped[0])
+	}
+	if capped[1].Version != 22 || capped[9].Version != 30 {
+		t.Fatalf("expected the most recent iterations to fill the rest, got v%d..v%d", capped[1].Version, capped[9].Version)
+	}
+
+	history[3].IsBest, history[2].IsBest = false, false
+	history[25].IsBest = true
+	capped = capIterationHistory(history, 10)
+	if capped[0].Version != 21 || capped[9].Version != 30 {
+		t.Fatalf("expected only recent iterations when the best is recent, got v%d..v%d", capped[0].Version, capped[9].Version)
+	}
+}
+
+func floatPtr(v float64) *float64 { return &v }
+
+func TestThresholdFitnessConfiguredThresholds(t *testing.T) {
+	best := FitnessInput{TotalReturn: 10, MaxDrawdown: 20}
+	candidate := FitnessInput{TotalReturn: 8, MaxDrawdown: 16} // 2 points less return, 4 points less drawdown
+
+	for _, tc := range []struct {
+		name    string
+		fitness ThresholdFitness
+		want    bool
+	}{
+		{"defaults reject", ThresholdFitness{}, false},
+		{"looser drawdown threshold promotes", ThresholdFitness{DrawdownImprovementPct: floatPtr(4)}, true},
+		{"tighter return tolerance rejects", ThresholdFitness{ReturnTolerancePct: floatPtr(1), DrawdownImprovementPct: floatPtr(4)}, false},
+		{"return tolerance boundary is inclusive", ThresholdFitness{ReturnTolerancePct: floatPtr(2), DrawdownImprovementPct: floatPtr(4)}, true},
+		{"zero return tolerance rejects any loss", ThresholdFitness{ReturnTolerancePct: floatPtr(0), DrawdownImprovementPct: floatPtr(4)}, false},
+		{"zero drawdown threshold promotes", ThresholdFitness{ReturnTolerancePct: floatPtr(2), DrawdownImprovementPct: floatPtr(0)}, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got, _ := tc.fitness.Improves(candidate, best); got != tc.want {
+				t.Errorf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestEvolutionImprovementThresholdsMovePromotionBoundary(t *testing.T) {
+	// v1 is the base strategy, v2 gives up 2 points of return for 4 points less drawdown
+	run := func(returnTolerance, drawdownImprovement *float64) (bestVersion int, v2Failed bool) {
+		t.Helper()
+		mgr := newStubBacktestManager(time.Millisecond, map[string]float64{"base-prompt": 10, mutationPrompt(1): 8})
+		mgr.drawdowns = map[string]float64{"base-prompt": 20, mutationPrompt(1): 16}
+		cfg := &EvolutionConfig{
+			UserID:                 "user-1",
+			Name:                   "evo",
+			BaseStrategyID:         "base",
+			MaxIterations:          2,
+			FixedParams:            FixedParams{AIModelID: "model-1"},
+			ReturnTolerancePct:     returnTolerance,
+			DrawdownImprovementPct: drawdownImprovement,
+		}
+		evolver, st := newTestEvolver(t, cfg, mgr)
+		if err := evolver.Start(context.Background()); err != nil {
+			t.Fatalf("Start failed: %v", err)
+		}
+
+		evolution, err := st.Evolution().Get("user-1", "evo-1")
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		history := evolver.getIterationHistory()
+		if len(history) != 2 {
+			t.Fatalf("expected 2 iterations, got %d", len(history))
+		}
+		return evolution.BestVersion, history[1].Failed
+	}
+
+	for _, tc := range []struct {
+		name                string
+		returnTolerance     *float64
+		drawdownImprovement *float64
+		wantBest            int
+	}{
+		{"defaults keep the base", nil, nil, 1},
+		{"looser drawdown threshold promotes", nil, floatPtr(4), 2},
+		{"tighter return tolerance rejects again", floatPtr(1), floatPtr(4), 1},
+		{"zero return tolerance rejects again", floatPtr(0), floatPtr(4), 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			best, v2Failed := run(tc.returnTolerance, tc.drawdownImprovement)
+			if best != tc.wantBest {
+				t.Errorf("expected best version v%d, got v%d", tc.wantBest, best)
+			}
+			// The history shown to the optimizer must agree with promotion
+			if v2Failed != (tc.wantBest != 2) {
+				t.Errorf("v2 failed flag = %v disagrees with best version v%d", v2Failed, best)
+			}
+		})
+	}
+}
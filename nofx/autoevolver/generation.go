@@ -0,0 +1,343 @@
+package autoevolver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"nofx/backtest"
+	"nofx/evotypes"
+	"nofx/logger"
+	"nofx/store"
+)
+
+// candidate is one prompt variant evaluated within a generation
+type candidate struct {
+	version int
+	runID   string
+	prompt  string
+	changes string
+	metrics *backtest.Metrics
+	err     error
+	carried bool // already evaluated in an earlier generation (population elites)
+}
+
+// runGeneration evaluates several candidate prompts concurrently and carries the best forward.
+// Each candidate is recorded as its own iteration, so the generation consumes one version per
+// candidate; the number of versions consumed is returned.
+func (e *AutoEvolver) runGeneration(ctx context.Context, version int) (int, error) {
+	// 1. Get current strategy
+	strategy, err := e.store.Strategy().Get(e.config.UserID, e.config.BaseStrategyID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get strategy: %w", err)
+	}
+
+	basePrompt := "baseline"
+	if strategy.Config != "" {
+		basePrompt = strategy.Config
+	}
+
+	size := e.config.ParallelCandidates
+	if remaining := e.config.MaxIterations - version + 1; size > remaining {
+		size = remaining
+	}
+
+	// 2. Generate candidate prompts: the carried-forward prompt plus AI mutations of it
+	candidates := e.generateCandidates(basePrompt, size)
+	logger.Infof("Evolution %s v%d: evaluating %d candidates in parallel", e.evolutionID, version, len(candidates))
+
+	// 3. Backtest all candidates through a bounded worker pool
+	e.runCandidates(ctx, strategy, version, candidates)
+	lastVersion := version + len(candidates) - 1
+	e.store.Evolution().UpdateCurrentIteration(e.evolutionID, lastVersion)
+
+	// 4. Select the best candidate of this generation
+	fitness := e.fitness()
+	var best *candidate
+	var errs []error
+	for _, c := range candidates {
+		if c.err != nil {
+			logger.Warnf("Evolution %s v%d: candidate failed: %v", e.evolutionID, c.version, c.err)
+			errs = append(errs, fmt.Errorf("v%d: %w", c.version, c.err))
+			continue
+		}
+		if best == nil {
+			best = c
+		} else if better, _ := fitness.Improves(fitnessFromBacktest(c.metrics), fitnessFromBacktest(best.metrics)); better {
+			best = c
+		}
+	}
+	if best == nil {
+		return 0, fmt.Errorf("all %d candidates failed: %v", len(candidates), errs)
+	}
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	logger.Infof("Evolution %s: generation winner v%d, return=%.2f%%, drawdown=%.2f%%",
+		e.evolutionID, best.version, best.metrics.TotalReturnPct, best.metrics.MaxDrawdownPct)
+
+	// 5-6. Evaluate the winner and promote it to best if it improves
+	isImproved, reason, failureReason := e.promoteWinner(ctx, strategy, best)
+	for _, c := range candidates {
+		if c.err == nil && c != best {
+			e.recordIterationOutcome(c.version, false, "", fmt.Sprintf("lost to generation winner v%d", best.version))
+		}
+	}
+
+	// 7. Carry the winning prompt forward as the base of the next generation
+	if err := e.carryForwardStrategy(strategy, best.version, best.prompt); err != nil {
+		return 0, err
+	}
+
+	for _, c := range candidates {
+		if c.err != nil {
+			continue
+		}
+		switch {
+		case c == best && isImproved:
+			e.notifyIterationCompleted(c.version, newIterationMetrics(c.metrics), true, reason)
+		case c == best:
+			e.notifyIterationCompleted(c.version, newIterationMetrics(c.metrics), false, failureReason)
+		default:
+			e.notifyIterationCompleted(c.version, newIterationMetrics(c.metrics), false, fmt.Sprintf("lost to generation winner v%d", best.version))
+		}
+	}
+
+	logger.Infof("Evolution %s v%d-v%d: generation completed successfully", e.evolutionID, version, lastVersion)
+	return len(candidates), nil
+}
+
+// promoteWinner runs the AI evaluation of a generation winner and, when it improves on the
+// best version and passes validation, promotes it. It records the winner's outcome and returns
+// whether it was promoted together with the improvement or failure reason.
+func (e *AutoEvolver) promoteWinner(ctx context.Context, strategy *store.Strategy, best *candidate) (bool, string, string) {
+	// AI evaluation of the winner only
+	trades, symbols := e.loadAnalysisTrades(best.runID)
+	equity, _ := e.backtestMgr.LoadEquity(best.runID, "", 0)
+	evaluation, err := NewAnalyzer(e.evaluationClient()).WithMaxRetries(e.config.MaxParseRetries).Analyze(&AnalysisInput{
+		Metrics:           best.metrics,
+		CurrentPrompt:     best.prompt,
+		Trades:            trades,
+		EquityCurve:       equity,
+		SymbolPerformance: symbols,
+	})
+	if err != nil {
+		logger.Warnf("AI evaluation failed: %v", err)
+	}
+	evalJSON, _ := json.Marshal(evaluation)
+	if err := e.store.Evolution().UpdateIterationEvaluation(e.evolutionID, best.version, string(evalJSON), best.changes); err != nil {
+		logger.Warnf("Failed to store evaluation for v%d: %v", best.version, err)
+	}
+	if err := e.store.Evolution().UpdateIterationPrompts(e.evolutionID, best.version, best.prompt, best.prompt); err != nil {
+		logger.Warnf("Failed to store prompts for v%d: %v", best.version, err)
+	}
+
+	// Update best version if improved
+	currentBest := e.bestFitness()
+	isImproved, reason := e.fitness().Improves(fitnessFromBacktest(best.metrics), currentBest)
+	isImproved = e.refreshParetoFrontier(ctx, best.version, isImproved)
+	failureReason := ""
+	switch {
+	case isImproved && reason == "":
+		reason = e.paretoChoiceLabel()
+	case !isImproved && reason != "":
+		failureReason = "not the " + e.paretoChoiceLabel()
+	case !isImproved:
+		failureReason = noImprovementReason(fitnessFromBacktest(best.metrics), currentBest)
+	}
+	if ok, validationReason := e.validateForPromotion(ctx, strategy, best.runID, best.prompt, best.version); !ok {
+		if isImproved {
+			failureReason = validationReason
+		}
+		isImproved = false
+	}
+	e.recordIterationOutcome(best.version, isImproved, reason, failureReason)
+	if isImproved {
+		logger.Infof("Evolution %s: new best version %d - %s", e.evolutionID, best.version, reason)
+		e.updateBestVersion(best.version, best.metrics.TotalReturnPct, best.metrics.MaxDrawdownPct)
+		e.notify(WebhookEventNewBest, best.version, newIterationMetrics(best.metrics),
+			fmt.Sprintf("Evolution %s: v%d is the new best - %s", e.config.Name, best.version, reason))
+	}
+	return isImproved, reason, failureReason
+}
+
+// generateCandidates returns up to size distinct prompts: basePrompt, a crossover of the
+// best version with basePrompt (when enabled), then AI mutations of basePrompt
+func (e *AutoEvolver) generateCandidates(basePrompt string, size int) []*candidate {
+	candidates := []*candidate{{prompt: basePrompt, changes: "Carried forward from previous generation"}}
+	seen := map[string]bool{basePrompt: true}
+
+	input := &OptimizationInput{
+		CurrentPrompt:    basePrompt,
+		IterationHistory: e.getIterationHistory(),
+		IsCurrentBest:    true,
+	}
+	if bestIter := e.getBestIteration(); bestIter != nil {
+		if e.config.EnableCrossover && size > 1 && bestIter.PromptBefore != "" && !seen[bestIter.PromptBefore] {
+			if offspring := e.crossoverCandidate(bestIter, basePrompt); offspring != nil && !seen[offspring.prompt] {
+				seen[offspring.prompt] = true
+				candidates = append(candidates, offspring)
+			}
+		}
+		if bestIter.EvalReport != "" {
+			var report evotypes.EvaluationReport
+			if err := json.Unmarshal([]byte(bestIter.EvalReport), &report); err == nil {
+				input.EvaluationReport = &report
+			}
+		}
+		if metrics, err := e.backtestMgr.GetMetrics(bestIter.BacktestRunID); err == nil {
+			input.CurrentMetrics = metrics
+			input.CurrentVersion = bestIter.Version
+		}
+		if trades, err := e.backtestMgr.LoadTrades(bestIter.BacktestRunID, 100); err == nil {
+			input.CurrentTrades = trades
+		}
+	}
+
+	optimizer := NewOptimizer(e.aiClient).WithMaxRetries(e.config.MaxParseRetries)
+	for attempt := 1; attempt < size && len(candidates) < size; attempt++ {
+		optimization, err := optimizer.Optimize(input)
+		if err != nil {
+			logger.Warnf("AI mutation %d failed: %v", attempt, err)
+			continue
+		}
+		// Identical prompts would only repeat the same backtest
+		if seen[optimization.NewPrompt] {
+			continue
+		}
+		seen[optimization.NewPrompt] = true
+		candidates = append(candidates, &candidate{
+			prompt:  optimization.NewPrompt,
+			changes: optimization.ExpectedEffect,
+		})
+	}
+	return candidates
+}
+
+// crossoverCandidate recombines the best version's prompt with basePrompt using the configured crossover map
+func (e *AutoEvolver) crossoverCandidate(bestIter *evotypes.Iteration, basePrompt string) *candidate {
+	offspring, err := Crossover(bestIter.PromptBefore, basePrompt, e.crossoverMap())
+	if err != nil {
+		logger.Warnf("Evolution %s: crossover with best v%d skipped: %v", e.evolutionID, bestIter.Version, err)
+		return nil
+	}
+	return &candidate{
+		prompt:  offspring,
+		changes: fmt.Sprintf("Crossover of best v%d with current prompt", bestIter.Version),
+	}
+}
+
+// crossoverMap returns the configured crossover map, falling back to defaultCrossoverMap
+func (e *AutoEvolver) crossoverMap() map[string]string {
+	if len(e.config.CrossoverMap) == 0 {
+		return defaultCrossoverMap
+	}
+	return e.config.CrossoverMap
+}
+
+// runCandidates backtests every candidate, running at most MaxParallelBacktests at once
+func (e *AutoEvolver) runCandidates(ctx context.Context, strategy *store.Strategy, version int, candidates []*candidate) {
+	workers := e.config.MaxParallelBacktests
+	if workers <= 0 || workers > len(candidates) {
+		workers = len(candidates)
+	}
+
+	// Versions are unique within an evolution, so they keep run IDs unique across candidates
+	stamp := time.Now().Format("20060102-1504")
+	sem := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+	for i, c := range candidates {
+		c.version = version + i
+		c.runID = fmt.Sprintf("evo-%s-epoch-%d", stamp, c.version)
+
+		wg.Add(1)
+		go func(c *candidate) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				c.err = ctx.Err()
+				return
+			}
+			defer func() { <-sem }()
+			c.metrics, c.err = e.runCandidate(ctx, strategy, c)
+		}(c)
+	}
+	wg.Wait()
+}
+
+// runCandidate runs a single candidate backtest and records it as an iteration
+func (e *AutoEvolver) runCandidate(ctx context.Context, strategy *store.Strategy, c *candidate) (*backtest.Metrics, error) {
+	// Reuse the iteration record (and its run ID) when retrying a generation after a restart
+	existingIter, err := e.store.Evolution().GetIteration(e.evolutionID, c.version)
+	if err == nil && existingIter != nil {
+		if existingIter.BacktestRunID != "" {
+			c.runID = existingIter.BacktestRunID
+			if err := e.backtestMgr.Delete(c.runID); err != nil {
+				logger.Warnf("Failed to delete old backtest data: %v", err)
+			}
+		}
+		e.store.Evolution().UpdateIterationStatus(e.evolutionID, c.version, IterStatusBacktest)
+		e.store.Evolution().UpdateIterationPrompts(e.evolutionID, c.version, c.prompt, "")
+	} else {
+		iteration := &evotypes.Iteration{
+			EvolutionID:   e.evolutionID,
+			Version:       c.version,
+			StrategyID:    strategy.ID,
+			BacktestRunID: c.runID,
+			Status:        IterStatusBacktest,
+			PromptBefore:  c.prompt,
+		}
+		if err := e.store.Evolution().CreateIteration(iteration); err != nil {
+			return nil, fmt.Errorf("failed to create iteration record: %w", err)
+		}
+	}
+
+	backtestConfig, err := e.newBacktestConfig(c.runID, strategy, c.prompt, c.version)
+	if err != nil {
+		e.store.Evolution().UpdateIterationStatus(e.evolutionID, c.version, IterStatusFailed)
+		return nil, err
+	}
+
+	logger.Infof("Evolution %s v%d: starting candidate backtest %s", e.evolutionID, c.version, c.runID)
+	releaseSlot, err := e.startBacktest(ctx, backtestConfig)
+	if err != nil {
+		e.store.Evolution().UpdateIterationStatus(e.evolutionID, c.version, IterStatusFailed)
+		return nil, fmt.Errorf("backtest start failed: %w", err)
+	}
+
+	err = e.awaitBacktest(ctx, strategy, c.runID, c.prompt, c.version, releaseSlot)
+	if err != nil {
+		// A backtest that ran out of stall restarts keeps its stalled status
+		if !errors.Is(err, errBacktestStalled) {
+			e.store.Evolution().UpdateIterationStatus(e.evolutionID, c.version, IterStatusFailed)
+		}
+		return nil, fmt.Errorf("backtest wait failed: %w", err)
+	}
+
+	metrics, err := e.backtestMgr.GetMetrics(c.runID)
+	if err != nil {
+		e.store.Evolution().UpdateIterationStatus(e.evolutionID, c.version, IterStatusFailed)
+		return nil, fmt.Errorf("failed to get metrics: %w", err)
+	}
+
+	logger.Infof("Evolution %s v%d: candidate backtest completed, return=%.2f%%, drawdown=%.2f%%",
+		e.evolutionID, c.version, metrics.TotalReturnPct, metrics.MaxDrawdownPct)
+
+	if err := e.store.Evolution().UpdateIterationComplete(
+		e.evolutionID, c.version,
+		newIterationMetrics(metrics),
+		"",
+		c.changes,
+		"",
+	); err != nil {
+		return nil, fmt.Errorf("failed to update iteration: %w", err)
+	}
+	e.saveEquityCurve(c.runID, c.version)
+	e.saveDecisionSamples(c.version, e.sampleDecisions(c.runID))
+	return metrics, nil
+}
@@ -0,0 +1,312 @@
+package autoevolver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"nofx/backtest"
+	"nofx/evotypes"
+	"nofx/mcp"
+	"nofx/store"
+)
+
+// stubBacktestManager completes every backtest after a fixed delay and
+// reports the return configured for the run's prompt
+type stubBacktestManager struct {
+	mu                sync.Mutex
+	delay             time.Duration
+	returns           map[string]float64 // prompt -> total return pct
+	validationReturns map[string]float64 // prompt -> out-of-sample total return pct
+	drawdowns         map[string]float64 // prompt -> max drawdown pct, default 10
+	runs              map[string]string  // runID -> prompt
+	configs           map[string]backtest.BacktestConfig
+	done              map[string]bool
+	inFlight          int
+	maxInFlight       int
+	limiter           *backtest.ConcurrencyLimiter // shared slot pool, nil means unlimited
+}
+
+func newStubBacktestManager(delay time.Duration, returns map[string]float64) *stubBacktestManager {
+	return &stubBacktestManager{
+		delay:             delay,
+		returns:           returns,
+		validationReturns: make(map[string]float64),
+		runs:              make(map[string]string),
+		configs:           make(map[string]backtest.BacktestConfig),
+		done:              make(map[string]bool),
+	}
+}
+
+func (m *stubBacktestManager) Start(ctx context.Context, cfg backtest.BacktestConfig) (*backtest.Runner, error) {
+	// Like backtest.Manager, wait for a slot before the run exists
+	release, err := m.limiter.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.runs[cfg.RunID]; ok {
+		release()
+		return nil, fmt.Errorf("run %s already exists", cfg.RunID)
+	}
+	m.runs[cfg.RunID] = cfg.PromptVariant
+	m.configs[cfg.RunID] = cfg
+	m.inFlight++
+	if m.inFlight > m.maxInFlight {
+		m.maxInFlight = m.inFlight
+	}
+	time.AfterFunc(m.delay, func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		m.inFlight--
+		m.done[cfg.RunID] = true
+		release()
+	})
+	return nil, nil
+}
+
+func (m *stubBacktestManager) Status(runID string) *backtest.StatusPayload {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.runs[runID]; !ok {
+		return nil
+	}
+	state := backtest.RunStateRunning
+	if m.done[runID] {
+		state = backtest.RunStateCompleted
+	}
+	return &backtest.StatusPayload{RunID: runID, State: state, LastUpdatedIso: time.Now().Format(time.RFC3339)}
+}
+
+func (m *stubBacktestManager) LoadMetadata(runID string) (*backtest.RunMetadata, error) {
+	return nil, fmt.Errorf("not found")
+}
+
+func (m *stubBacktestManager) Delete(runID string) error { return nil }
+
+func (m *stubBacktestManager) GetMetrics(runID string) (*backtest.Metrics, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	prompt, ok := m.runs[runID]
+	if !ok {
+		return nil, fmt.Errorf("run %s not found", runID)
+	}
+	totalReturn := m.returns[prompt]
+	if strings.HasSuffix(runID, "-oos") {
+		totalReturn = m.validationReturns[prompt]
+	}
+	drawdown, ok := m.drawdowns[prompt]
+	if !ok {
+		drawdown = 10
+	}
+	return &backtest.Metrics{TotalReturnPct: totalReturn, MaxDrawdownPct: drawdown, Trades: 5}, nil
+}
+
+func (m *stubBacktestManager) LoadTrades(runID string, limit int) ([]backtest.TradeEvent, error) {
+	return nil, nil
+}
+
+func (m *stubBacktestManager) LoadDecisions(runID string, limit, offset int) ([]*store.DecisionRecord, error) {
+	return nil, nil
+}
+
+func (m *stubBacktestManager) LoadEquity(runID string, timeframe string, limit int) ([]backtest.EquityPoint, error) {
+	return []backtest.EquityPoint{
+		{Timestamp: 1000, Equity: 1000, PnLPct: 0},
+		{Timestamp: 2000, Equity: 1050, PnLPct: 5},
+	}, nil
+}
+
+// stubAIClient returns a new distinct prompt for every optimization request
+type stubAIClient struct {
+	mu        sync.Mutex
+	mutations int
+	analyses  int
+}
+
+func (c *stubAIClient) SetAPIKey(apiKey string, customURL string, customModel string) {}
+func (c *stubAIClient) SetTimeout(timeout time.Duration)                              {}
+func (c *stubAIClient) CallWithRequest(req *mcp.Request) (string, error)              { return "", nil }
+
+func (c *stubAIClient) CallWithMessages(systemPrompt, userPrompt string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !strings.Contains(systemPrompt, "prompt engineer") {
+		c.analyses++
+		return `{"strengths":[],"weaknesses":[],"suggestions":[]}`, nil
+	}
+	c.mutations++
+	reply, _ := json.Marshal(map[string]interface{}{
+		"changes":         []string{"mutation"},
+		"new_prompt":      mutationPrompt(c.mutations),
+		"expected_effect": fmt.Sprintf("mutation %d", c.mutations),
+	})
+	return string(reply), nil
+}
+
+// mutationPrompt returns the n-th distinct strategy config produced by stubAIClient
+func mutationPrompt(n int) string {
+	return fmt.Sprintf(`{"coin_source":{"source_type":"static"},"custom_prompt":"mutation-%d",`+
+		`"risk_control":{"max_positions":3,"btc_eth_max_leverage":5,"altcoin_max_leverage":5,"max_margin_usage":0.8,"min_confidence":70}}`, n)
+}
+
+func newTestEvolver(t *testing.T, cfg *EvolutionConfig, mgr BacktestManager) (*AutoEvolver, *store.Store) {
+	t.Helper()
+
+	st, err := store.New(filepath.Join(t.TempDir(), "evolver.db"))
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	t.Cleanup(func() { st.Close() })
+
+	if err := st.AIModel().Create(cfg.UserID, "model-1", "deepseek", "deepseek", true, "test-key", ""); err != nil {
+		t.Fatalf("failed to create AI model: %v", err)
+	}
+	if err := st.Strategy().Create(&store.Strategy{ID: "base", UserID: cfg.UserID, Name: "base", Config: "base-prompt"}); err != nil {
+		t.Fatalf("failed to create strategy: %v", err)
+	}
+	if err := st.Evolution().Create(&evotypes.Evolution{
+		ID:             "evo-1",
+		UserID:         cfg.UserID,
+		Name:           cfg.Name,
+		BaseStrategyID: cfg.BaseStrategyID,
+		Status:         StatusCreated,
+		MaxIterations:  cfg.MaxIterations,
+		Config:         "{}",
+	}); err != nil {
+		t.Fatalf("failed to create evolution: %v", err)
+	}
+
+	evolver := NewAutoEvolver("evo-1", cfg, mgr, &stubAIClient{}, st)
+	evolver.pollInterval = 5 * time.Millisecond
+	return evolver, st
+}
+
+func newParallelConfig(candidates, workers int) *EvolutionConfig {
+	return &EvolutionConfig{
+		UserID:               "user-1",
+		Name:                 "evo",
+		BaseStrategyID:       "base",
+		MaxIterations:        candidates,
+		ParallelCandidates:   candidates,
+		MaxParallelBacktests: workers,
+		FixedParams:          FixedParams{AIModelID: "model-1"},
+	}
+}
+
+func TestRunGenerationLaunchesCandidatesInParallel(t *testing.T) {
+	mgr := newStubBacktestManager(100*time.Millisecond, map[string]float64{
+		"base-prompt":     1,
+		mutationPrompt(1): 3,
+		mutationPrompt(2): 12,
+		mutationPrompt(3): -4,
+	})
+	evolver, st := newTestEvolver(t, newParallelConfig(4, 4), mgr)
+
+	if err := evolver.Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	if len(mgr.runs) != 4 {
+		t.Fatalf("expected 4 backtests, got %d", len(mgr.runs))
+	}
+	if mgr.maxInFlight != 4 {
+		t.Errorf("expected 4 backtests in flight at once, got %d", mgr.maxInFlight)
+	}
+
+	iterations, err := st.Evolution().GetIterations("evo-1")
+	if err != nil {
+		t.Fatalf("GetIterations failed: %v", err)
+	}
+	if len(iterations) != 4 {
+		t.Fatalf("expected one iteration per candidate, got %d", len(iterations))
+	}
+	var winner *Iteration
+	for _, iter := range iterations {
+		if iter.Status != IterStatusCompleted {
+			t.Errorf("v%d: expected completed, got %s", iter.Version, iter.Status)
+		}
+		if iter.PromptBefore == mutationPrompt(2) {
+			winner = iter
+		}
+	}
+	if winner == nil {
+		t.Fatal("winning candidate was not recorded")
+	}
+	for _, iter := range iterations {
+		if iter.Version != winner.Version && iter.FailureReason != fmt.Sprintf("lost to generation winner v%d", winner.Version) {
+			t.Errorf("v%d: unexpected failure reason %q", iter.Version, iter.FailureReason)
+		}
+	}
+	if !strings.HasPrefix(winner.ImprovementReason, "higher return") {
+		t.Errorf("expected winner improvement reason, got %q", winner.ImprovementReason)
+	}
+
+	evolution, err := st.Evolution().Get("user-1", "evo-1")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if evolution.BestVersion != winner.Version || evolution.BestReturn != 12 {
+		t.Errorf("expected best v%d with 12%%, got v%d with %.2f%%", winner.Version, evolution.BestVersion, evolution.BestReturn)
+	}
+
+	next, err := st.Strategy().Get("user-1", evolution.BaseStrategyID)
+	if err != nil {
+		t.Fatalf("failed to load carried-forward strategy: %v", err)
+	}
+	if next.Config != mutationPrompt(2) {
+		t.Errorf("expected winner prompt to be carried forward, got %q", next.Config)
+	}
+}
+
+func TestRunGenerationRespectsWorkerPool(t *testing.T) {
+	mgr := newStubBacktestManager(50*time.Millisecond, map[string]float64{})
+	evolver, _ := newTestEvolver(t, newParallelConfig(4, 2), mgr)
+
+	if err := evolver.Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	if len(mgr.runs) != 4 {
+		t.Fatalf("expected 4 backtests, got %d", len(mgr.runs))
+	}
+	if mgr.maxInFlight != 2 {
+		t.Errorf("expected at most 2 backtests in flight, got %d", mgr.maxInFlight)
+	}
+}
+
+func TestRunGenerationQueuesBeyondManagerLimit(t *testing.T) {
+	mgr := newStubBacktestManager(30*time.Millisecond, map[string]float64{})
+	mgr.limiter = backtest.NewConcurrencyLimiter(2)
+	evolver, st := newTestEvolver(t, newParallelConfig(6, 6), mgr)
+
+	if err := evolver.Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	if len(mgr.runs) != 6 {
+		t.Fatalf("expected 6 backtests, got %d", len(mgr.runs))
+	}
+	if mgr.maxInFlight != 2 {
+		t.Errorf("expected the manager limit to cap backtests in flight at 2, got %d", mgr.maxInFlight)
+	}
+	if n := mgr.limiter.InUse(); n != 0 {
+		t.Errorf("expected every slot to be released, %d still held", n)
+	}
+
+	iterations, err := st.Evolution().GetIterations("evo-1")
+	if err != nil {
+		t.Fatalf("GetIterations failed: %v", err)
+	}
+	for _, iter := range iterations {
+		if iter.Status != IterStatusCompleted {
+			t.Errorf("v%d: queued candidate should still complete, got %s", iter.Version, iter.Status)
+		}
+	}
+}
@@ -1,10 +1,15 @@
 package autoevolver
 
 import (
+	"fmt"
+
 	"nofx/evotypes"
 	"nofx/logger"
 )
 
+// LineageModeHillClimb continues the lineage from the best version after a regression
+const LineageModeHillClimb = "hill_climb"
+
 // getBestReturn gets the current best return
 func (e *AutoEvolver) getBestReturn() float64 {
 	evolution, err := e.store.Evolution().Get(e.config.UserID, e.evolutionID)
@@ -37,6 +42,18 @@ func (e *AutoEvolver) updateBestVersion(version int, totalReturn, maxDrawdown fl
 	}
 }
 
+// recordIterationOutcome persists why a version was or was not promoted to best
+func (e *AutoEvolver) recordIterationOutcome(version int, improved bool, improvementReason, failureReason string) {
+	if improved {
+		failureReason = ""
+	} else {
+		improvementReason = ""
+	}
+	if err := e.store.Evolution().UpdateIterationOutcome(e.evolutionID, version, improvementReason, failureReason); err != nil {
+		logger.Warnf("Failed to record outcome for v%d: %v", version, err)
+	}
+}
+
 // getBestStrategyID gets the strategy ID of the best performing iteration
 func (e *AutoEvolver) getBestStrategyID() string {
 	evolution, err := e.store.Evolution().Get(e.config.UserID, e.evolutionID)
@@ -53,6 +70,21 @@ func (e *AutoEvolver) getBestStrategyID() string {
 	return iter.StrategyID
 }
 
+// revertToBestStrategy makes the strategy of the best version the base strategy of the next
+// iteration. It reports false when there is no best version to revert to.
+func (e *AutoEvolver) revertToBestStrategy(version int) bool {
+	bestStrategyID := e.getBestStrategyID()
+	if bestStrategyID == "" {
+		return false
+	}
+	e.config.BaseStrategyID = bestStrategyID
+	if err := e.store.Evolution().UpdateBaseStrategy(e.evolutionID, bestStrategyID); err != nil {
+		logger.Warnf("Failed to persist base_strategy_id: %v", err)
+	}
+	logger.Infof("Evolution %s v%d: reverted to best strategy %s for next iteration", e.evolutionID, version, bestStrategyID)
+	return true
+}
+
 // getBestIteration gets the best performing iteration record
 func (e *AutoEvolver) getBestIteration() *evotypes.Iteration {
 	evolution, err := e.store.Evolution().Get(e.config.UserID, e.evolutionID)
@@ -67,3 +99,33 @@ func (e *AutoEvolver) getBestIteration() *evotypes.Iteration {
 
 	return iter
 }
+
+// checkConvergence reports whether ConvergenceThreshold consecutive iterations
+// have completed without producing a new best version
+func (e *AutoEvolver) checkConvergence() (bool, string) {
+	if e.config.ConvergenceThreshold <= 0 {
+		return false, ""
+	}
+	evolution, err := e.store.Evolution().Get(e.config.UserID, e.evolutionID)
+	if err != nil {
+		return false, ""
+	}
+	stats, err := e.store.Evolution().GetIterationStats(e.evolutionID, evolution.BestVersion)
+	if err != nil {
+		return false, ""
+	}
+	return convergedAfter(stats.CompletedAfter, evolution.BestVersion, e.config.ConvergenceThreshold)
+}
+
+// convergedAfter reports whether stagnant iterations since bestVersion reach threshold.
+// The stagnant iterations are the completed ones after the best version; the best version
+// only moves on improvement, so these are exactly the consecutive non-improving iterations.
+func convergedAfter(stagnant, bestVersion, threshold int) (bool, string) {
+	if threshold <= 0 || stagnant < threshold {
+		return false, ""
+	}
+	if bestVersion == 0 {
+		return true, fmt.Sprintf("no improvement in %d consecutive iterations", stagnant)
+	}
+	return true, fmt.Sprintf("no improvement over best version v%d in %d consecutive iterations", bestVersion, stagnant)
+}
@@ -34,6 +34,30 @@ func (e *AutoEvolver) runIteration(ctx context.Context, version int) error {
 	var backtestRunID string
 	needReEvaluate := false // Flag to force re-evaluation when backtest is reset
 
+	// Outputs of the backtest and evaluation stages, possibly restored from a checkpoint
+	var (
+		metrics    *backtest.Metrics
+		trades     []backtest.TradeEvent
+		symbols    []symbolPerformance
+		equity     []backtest.EquityPoint
+		evaluation *evotypes.EvaluationReport
+		decisions  []DecisionSample
+	)
+	releaseSlot := func() {} // ends the run started by startBacktest
+
+	// Evaluation already finished before an interruption: resume at optimization
+	if err == nil && existingIter != nil && existingIter.Status != IterStatusCompleted {
+		if cp := e.loadCheckpoint(existingIter); cp != nil {
+			backtestRunID = existingIter.BacktestRunID
+			if existingIter.PromptBefore != "" {
+				promptVariant = existingIter.PromptBefore
+			}
+			metrics, trades, evaluation = cp.metrics, cp.trades, cp.evaluation
+			logger.Infof("Evolution %s v%d: resuming from evaluation checkpoint, skipping backtest", e.evolutionID, version)
+			goto optimizeIteration
+		}
+	}
+
 	if err == nil && existingIter != nil && existingIter.BacktestRunID != "" {
 		// Check backtest state
 		meta, metaErr := e.backtestMgr.LoadMetadata(existingIter.BacktestRunID)
@@ -45,6 +69,9 @@ func (e *AutoEvolver) runIteration(ctx context.Context, version int) error {
 				if statusPayload != nil {
 					// Runner exists, wait for it
 					backtestRunID = existingIter.BacktestRunID
+					if existingIter.PromptBefore != "" {
+						promptVariant = existingIter.PromptBefore
+					}
 					logger.Infof("Evolution %s v%d: resuming existing backtest %s", e.evolutionID, version, backtestRunID)
 					goto waitBacktest
 				}
@@ -66,7 +93,7 @@ func (e *AutoEvolver) runIteration(ctx context.Context, version int) error {
 				// Backtest already completed
 				backtestRunID = existingIter.BacktestRunID
 				// Check if evaluation/optimization was already done
-				if existingIter.Status == "completed" {
+				if existingIter.Status == IterStatusCompleted {
 					// Iteration fully completed, skip to next
 					logger.Infof("Evolution %s v%d: iteration already completed, skipping", e.evolutionID, version)
 					return nil
@@ -94,83 +121,51 @@ func (e *AutoEvolver) runIteration(ctx context.Context, version int) error {
 
 restartBacktest:
 	{
-		backtestConfig := backtest.BacktestConfig{
-			RunID:                backtestRunID,
-			UserID:               e.config.UserID,
-			AIModelID:            e.config.FixedParams.AIModelID,
-			StrategyID:           e.config.BaseStrategyID,
-			Symbols:              e.config.FixedParams.Symbols,
-			Timeframes:           e.config.FixedParams.Timeframes,
-			DecisionTimeframe:    e.config.FixedParams.DecisionTimeframe,
-			DecisionCadenceNBars: e.config.FixedParams.DecisionCadence,
-			StartTS:              e.config.FixedParams.StartTS,
-			EndTS:                e.config.FixedParams.EndTS,
-			InitialBalance:       e.config.FixedParams.InitialBalance,
-			FeeBps:               e.config.FixedParams.FeeBps,
-			SlippageBps:          e.config.FixedParams.SlippageBps,
-			PromptVariant:        promptVariant,
-			CacheAI:              e.config.FixedParams.CacheAI,
-		}
-
-		// Load strategy config (indicators, etc.) - use promptVariant which may be from existingIter.PromptBefore
-		configToLoad := promptVariant
-		if configToLoad == "baseline" || configToLoad == "" {
-			configToLoad = strategy.Config
-		}
-		if configToLoad != "" && configToLoad != "baseline" {
-			var strategyConfig store.StrategyConfig
-			if err := json.Unmarshal([]byte(configToLoad), &strategyConfig); err == nil {
-				backtestConfig.SetLoadedStrategy(&strategyConfig)
-				logger.Infof("Evolution %s v%d: loaded strategy config with indicators", e.evolutionID, version)
-			} else {
-				logger.Warnf("Evolution %s v%d: failed to parse strategy config: %v", e.evolutionID, version, err)
-			}
-		}
-
-		// Hydrate AI configuration from database
-		if err := e.hydrateAIConfig(&backtestConfig); err != nil {
-			return fmt.Errorf("failed to hydrate AI config: %w", err)
+		backtestConfig, err := e.newBacktestConfig(backtestRunID, strategy, promptVariant, version)
+		if err != nil {
+			return err
 		}
 
 		logger.Infof("Evolution %s v%d: starting backtest %s", e.evolutionID, version, backtestRunID)
 
-		// Create iteration record with "backtest" status (only if not restarting)
+		// Create iteration record in the backtesting state (only if not restarting)
 		if existingIter == nil || existingIter.BacktestRunID != backtestRunID {
 			iteration := &evotypes.Iteration{
 				EvolutionID:   e.evolutionID,
 				Version:       version,
 				StrategyID:    strategy.ID,
 				BacktestRunID: backtestRunID,
-				Status:        "backtest",
+				Status:        IterStatusBacktest,
 				PromptBefore:  promptVariant,
 			}
 			if err := e.store.Evolution().CreateIteration(iteration); err != nil {
 				logger.Warnf("Failed to create iteration record: %v", err)
 			}
 		} else {
-			// Update existing iteration status back to "backtest"
-			e.store.Evolution().UpdateIterationStatus(e.evolutionID, version, "backtest")
+			// Move the existing iteration back to the backtesting state
+			e.store.Evolution().UpdateIterationStatus(e.evolutionID, version, IterStatusBacktest)
 		}
 
 		// Start backtest
-		_, err = e.backtestMgr.Start(ctx, backtestConfig)
+		releaseSlot, err = e.startBacktest(ctx, backtestConfig)
 		if err != nil {
-			e.store.Evolution().UpdateIterationStatus(e.evolutionID, version, "failed")
+			e.store.Evolution().UpdateIterationStatus(e.evolutionID, version, IterStatusFailed)
 			return fmt.Errorf("backtest start failed: %w", err)
 		}
 	}
 
 waitBacktest:
 
-	// 5. Wait for backtest to complete
-	if err := e.waitForBacktestComplete(ctx, backtestRunID); err != nil {
+	// 5. Wait for backtest to complete, restarting it if it stalls
+	err = e.awaitBacktest(ctx, strategy, backtestRunID, promptVariant, version, releaseSlot)
+	if err != nil {
 		return fmt.Errorf("backtest wait failed: %w", err)
 	}
 
 evaluateBacktest:
 
 	// 5. Get backtest results
-	metrics, err := e.backtestMgr.GetMetrics(backtestRunID)
+	metrics, err = e.backtestMgr.GetMetrics(backtestRunID)
 	if err != nil {
 		return fmt.Errorf("failed to get metrics: %w", err)
 	}
@@ -179,37 +174,45 @@ evaluateBacktest:
 		e.evolutionID, version, metrics.TotalReturnPct, metrics.MaxDrawdownPct)
 
 	// 6. Get trades for analysis
-	trades, _ := e.backtestMgr.LoadTrades(backtestRunID, 100)
+	trades, symbols = e.loadAnalysisTrades(backtestRunID)
+	equity, _ = e.backtestMgr.LoadEquity(backtestRunID, "", 0)
+
+	// Sample decisions so the key trades of this iteration can be traced to their reasoning
+	decisions = e.sampleDecisions(backtestRunID)
+	e.saveDecisionSamples(version, decisions)
 
 	// 7. AI Evaluation - update status
 	if needReEvaluate {
 		logger.Infof("Evolution %s v%d: backtest was reset, forcing re-evaluation and re-optimization", e.evolutionID, version)
 	}
-	e.store.Evolution().UpdateIterationStatus(e.evolutionID, version, "evaluating")
+	e.store.Evolution().UpdateIterationStatus(e.evolutionID, version, IterStatusEvaluating)
 	logger.Infof("Evolution %s v%d: running AI evaluation...", e.evolutionID, version)
-	analyzer := NewAnalyzer(e.aiClient)
-	analysisInput := &AnalysisInput{
-		Metrics:       metrics,
-		CurrentPrompt: promptVariant,
-		Trades:        trades,
-	}
-	evaluation, err := analyzer.Analyze(analysisInput)
+	evaluation, err = NewAnalyzer(e.evaluationClient()).WithMaxRetries(e.config.MaxParseRetries).Analyze(&AnalysisInput{
+		Metrics:           metrics,
+		CurrentPrompt:     promptVariant,
+		Trades:            trades,
+		Decisions:         decisions,
+		EquityCurve:       equity,
+		SymbolPerformance: symbols,
+	})
 	if err != nil {
 		logger.Warnf("AI evaluation failed: %v", err)
 	}
 
+	// Checkpoint the evaluation so a restart can skip straight to optimization
+	e.saveCheckpoint(version, backtestRunID, metrics, trades, evaluation)
+
+optimizeIteration:
+
 	// 8. Get iteration history for optimization context
 	iterHistory := e.getIterationHistory()
 
 	// 9. AI Optimization - update status
-	e.store.Evolution().UpdateIterationStatus(e.evolutionID, version, "optimizing")
+	e.store.Evolution().UpdateIterationStatus(e.evolutionID, version, IterStatusOptimizing)
 
 	// Check if current epoch is better than best (using same criteria as improvement check)
-	currentBestReturn := e.getBestReturn()
-	currentBestDrawdown := e.getBestDrawdown()
-	returnDiff := metrics.TotalReturnPct - currentBestReturn
-	drawdownImprovement := currentBestDrawdown - metrics.MaxDrawdownPct
-	isCurrentBetter := returnDiff > 0 || (returnDiff >= -3.0 && drawdownImprovement >= 5.0)
+	currentBest := e.bestFitness()
+	isCurrentBetter, improvementReason := e.fitness().Improves(fitnessFromBacktest(metrics), currentBest)
 
 	// Prepare optimization input with comparison data
 	bestIter := e.getBestIteration()
@@ -255,8 +258,10 @@ evaluateBacktest:
 	}
 
 	logger.Infof("Evolution %s v%d: running AI optimization...", e.evolutionID, version)
-	optimizer := NewOptimizer(e.aiClient)
+	optimizer := NewOptimizer(e.aiClient).WithMaxRetries(e.config.MaxParseRetries)
 	optimization, err := optimizer.Optimize(optimInput)
+	// The new prompt descends from the best version when the optimizer started from its prompt
+	optimizedFromBest := err == nil && optimInput.BestPrompt != ""
 	if err != nil {
 		logger.Warnf("AI optimization failed: %v", err)
 		optimization = &evotypes.OptimizationResult{
@@ -267,53 +272,193 @@ evaluateBacktest:
 
 	// 10. Update iteration record with results
 	evalJSON, _ := json.Marshal(evaluation)
-	iterMetrics := &evotypes.Metrics{
-		TotalReturn: metrics.TotalReturnPct,
-		MaxDrawdown: metrics.MaxDrawdownPct,
-		WinRate:     metrics.WinRate,
-		SharpeRatio: metrics.SharpeRatio,
-		Trades:      metrics.Trades,
-	}
 	if err := e.store.Evolution().UpdateIterationComplete(
 		e.evolutionID, version,
-		iterMetrics,
+		newIterationMetrics(metrics),
 		string(evalJSON),
 		optimization.ExpectedEffect,
 		optimization.NewPrompt,
 	); err != nil {
 		return fmt.Errorf("failed to update iteration: %w", err)
 	}
+	e.saveEquityCurve(backtestRunID, version)
+	if err := e.store.Evolution().DeleteIterationCheckpoint(e.evolutionID, version); err != nil {
+		logger.Warnf("Failed to delete checkpoint for v%d: %v", version, err)
+	}
 
 	// 11. Update best version if improved
-	// Improvement criteria:
-	// 1. Higher return is always better
-	// 2. Similar return (within 3%) but significantly better drawdown (5%+ improvement) is also considered improvement
-	// Note: currentBestReturn, currentBestDrawdown, returnDiff, drawdownImprovement already calculated above
-
-	isImproved := false
-	improvementReason := ""
+	// Note: isCurrentBetter and improvementReason already calculated above with the configured fitness function
+	isImproved := isCurrentBetter
+
+	// Pareto selection replaces the rule above when configured
+	isImproved = e.refreshParetoFrontier(ctx, version, isImproved)
+	failureReason := ""
+	switch {
+	case isImproved && improvementReason == "":
+		improvementReason = e.paretoChoiceLabel()
+	case !isImproved && improvementReason != "":
+		failureReason = "not the " + e.paretoChoiceLabel()
+	case !isImproved:
+		failureReason = noImprovementReason(fitnessFromBacktest(metrics), currentBest)
+	}
 
-	if returnDiff > 0 {
-		// Higher return - clear improvement
-		isImproved = true
-		improvementReason = fmt.Sprintf("higher return (%.2f%% vs %.2f%%)", metrics.TotalReturnPct, currentBestReturn)
-	} else if returnDiff >= -3.0 && drawdownImprovement >= 5.0 {
-		// Similar return (within 3%) but significantly better drawdown (5%+ improvement)
-		isImproved = true
-		improvementReason = fmt.Sprintf("similar return (%.2f%% vs %.2f%%) with better drawdown (%.2f%% vs %.2f%%)",
-			metrics.TotalReturnPct, currentBestReturn, metrics.MaxDrawdownPct, currentBestDrawdown)
+	// Every iteration is validated out-of-sample (when configured) so its metrics are on
+	// record, but only in-sample improvements can be rejected by the result
+	if ok, reason := e.validateForPromotion(ctx, strategy, backtestRunID, promptVariant, version); !ok {
+		if isImproved {
+			failureReason = reason
+		}
+		isImproved = false
 	}
+	e.recordIterationOutcome(version, isImproved, improvementReason, failureReason)
 
 	if isImproved {
 		logger.Infof("Evolution %s: new best version %d - %s",
 			e.evolutionID, version, improvementReason)
 		e.updateBestVersion(version, metrics.TotalReturnPct, metrics.MaxDrawdownPct)
+		e.notify(WebhookEventNewBest, version, newIterationMetrics(metrics),
+			fmt.Sprintf("Evolution %s: v%d is the new best - %s", e.config.Name, version, improvementReason))
 	} else {
-		logger.Infof("Evolution %s v%d: no improvement (return %.2f%% vs best %.2f%%, drawdown %.2f%% vs best %.2f%%), will revert to best strategy",
-			e.evolutionID, version, metrics.TotalReturnPct, currentBestReturn, metrics.MaxDrawdownPct, currentBestDrawdown)
+		logger.Infof("Evolution %s v%d: no improvement (return %.2f%% vs best %.2f%%, drawdown %.2f%% vs best %.2f%%)",
+			e.evolutionID, version, metrics.TotalReturnPct, currentBest.TotalReturn, metrics.MaxDrawdownPct, currentBest.MaxDrawdown)
 	}
 
-	// 12. Create or update strategy version with optimized prompt
+	// 12. Carry the AI-generated new strategy (optimization.NewPrompt) forward as the base of
+	// the next iteration. In hill-climb mode a regression continues the lineage from the best
+	// strategy: its mutation is carried forward when the optimizer started from the best
+	// prompt, otherwise the best strategy itself becomes the base
+	parent := strategy
+	if !isImproved && e.config.LineageMode == LineageModeHillClimb {
+		if !optimizedFromBest && e.revertToBestStrategy(version) {
+			parent = nil
+		} else if best, err := e.store.Strategy().Get(e.config.UserID, e.getBestStrategyID()); err == nil {
+			parent = best
+		}
+	}
+	if parent != nil {
+		if err := e.carryForwardStrategy(parent, version, optimization.NewPrompt); err != nil {
+			return err
+		}
+	}
+
+	if isImproved {
+		e.notifyIterationCompleted(version, newIterationMetrics(metrics), true, improvementReason)
+	} else {
+		e.notifyIterationCompleted(version, newIterationMetrics(metrics), false, failureReason)
+	}
+
+	logger.Infof("Evolution %s v%d: iteration completed successfully", e.evolutionID, version)
+	return nil
+}
+
+// newBacktestConfig builds the backtest configuration for one evolution run
+func (e *AutoEvolver) newBacktestConfig(runID string, strategy *store.Strategy, promptVariant string, version int) (backtest.BacktestConfig, error) {
+	backtestConfig := backtest.BacktestConfig{
+		RunID:                runID,
+		UserID:               e.config.UserID,
+		AIModelID:            e.config.FixedParams.AIModelID,
+		StrategyID:           e.config.BaseStrategyID,
+		Symbols:              e.config.FixedParams.Symbols,
+		Timeframes:           e.config.FixedParams.Timeframes,
+		DecisionTimeframe:    e.config.FixedParams.DecisionTimeframe,
+		DecisionCadenceNBars: e.config.FixedParams.DecisionCadence,
+		StartTS:              e.config.FixedParams.StartTS,
+		EndTS:                e.config.FixedParams.EndTS,
+		InitialBalance:       e.config.FixedParams.InitialBalance,
+		FeeBps:               e.config.FixedParams.FeeBps,
+		SlippageBps:          e.config.FixedParams.SlippageBps,
+		PromptVariant:        promptVariant,
+		CacheAI:              e.config.FixedParams.CacheAI,
+		Leverage: backtest.LeverageConfig{
+			BTCETHLeverage:  e.config.FixedParams.BTCETHLeverage,
+			AltcoinLeverage: e.config.FixedParams.AltcoinLeverage,
+		},
+	}
+
+	// Optimize on the in-sample window only; the rest is held out for validation
+	if splitTS, ok := e.validationSplitTS(); ok {
+		backtestConfig.EndTS = splitTS
+	}
+
+	// Load strategy config (indicators, etc.) - use promptVariant which may be from existingIter.PromptBefore
+	configToLoad := promptVariant
+	if configToLoad == "baseline" || configToLoad == "" {
+		configToLoad = strategy.Config
+	}
+	if configToLoad != "" && configToLoad != "baseline" {
+		var strategyConfig store.StrategyConfig
+		if err := json.Unmarshal([]byte(configToLoad), &strategyConfig); err == nil {
+			backtestConfig.SetLoadedStrategy(&strategyConfig)
+			logger.Infof("Evolution %s v%d: loaded strategy config with indicators", e.evolutionID, version)
+		} else {
+			logger.Warnf("Evolution %s v%d: failed to parse strategy config: %v", e.evolutionID, version, err)
+		}
+	}
+
+	// Hydrate AI configuration from database
+	if err := e.hydrateAIConfig(&backtestConfig); err != nil {
+		return backtestConfig, fmt.Errorf("failed to hydrate AI config: %w", err)
+	}
+	return backtestConfig, nil
+}
+
+// newIterationMetrics converts backtest metrics into the stored iteration metrics
+func newIterationMetrics(metrics *backtest.Metrics) *evotypes.Metrics {
+	var avgTradePnL float64
+	if metrics.Trades > 0 {
+		var totalPnL float64
+		for _, stats := range metrics.SymbolStats {
+			totalPnL += stats.TotalPnL
+		}
+		avgTradePnL = totalPnL / float64(metrics.Trades)
+	}
+
+	return &evotypes.Metrics{
+		TotalReturn:  metrics.TotalReturnPct,
+		MaxDrawdown:  metrics.MaxDrawdownPct,
+		WinRate:      metrics.WinRate,
+		SharpeRatio:  metrics.SharpeRatio,
+		SortinoRatio: metrics.SortinoRatio,
+		CalmarRatio:  metrics.CalmarRatio,
+		Trades:       metrics.Trades,
+
+		MaxConsecutiveLosses: metrics.MaxConsecutiveLosses,
+		AvgWin:               metrics.AvgWin,
+		AvgLoss:              metrics.AvgLoss,
+		Expectancy:           metrics.Expectancy,
+		ProfitFactor:         metrics.ProfitFactor,
+		AvgTradePnL:          avgTradePnL,
+	}
+}
+
+// maxEquityCurvePoints bounds the equity curve stored per iteration
+const maxEquityCurvePoints = 500
+
+// saveEquityCurve stores a downsampled equity curve of the backtest run with the iteration.
+// A missing curve is not fatal: the iteration metrics are already on record.
+func (e *AutoEvolver) saveEquityCurve(runID string, version int) {
+	points, err := e.backtestMgr.LoadEquity(runID, "", maxEquityCurvePoints)
+	if err != nil {
+		logger.Warnf("Evolution %s v%d: failed to load equity curve: %v", e.evolutionID, version, err)
+		return
+	}
+
+	curve := make([]evotypes.EquityPoint, 0, len(points))
+	for _, p := range points {
+		curve = append(curve, evotypes.EquityPoint{
+			Timestamp: p.Timestamp,
+			Equity:    p.Equity,
+			Return:    p.PnLPct,
+		})
+	}
+	if err := e.store.Evolution().UpdateIterationEquityCurve(e.evolutionID, version, curve); err != nil {
+		logger.Warnf("Evolution %s v%d: failed to save equity curve: %v", e.evolutionID, version, err)
+	}
+}
+
+// carryForwardStrategy saves prompt as the strategy version for this iteration
+// and makes it the base strategy of the next iteration
+func (e *AutoEvolver) carryForwardStrategy(strategy *store.Strategy, version int, prompt string) error {
 	// Use evolution name as base, not current strategy name (to avoid name stacking like v3_v4_v5)
 	baseStrategyName := e.config.Name
 	if baseStrategyName == "" {
@@ -329,7 +474,7 @@ evaluateBacktest:
 	var newStrategy *store.Strategy
 	if err == nil && existingStrategy != nil {
 		// Strategy with same name exists, update it
-		existingStrategy.Config = optimization.NewPrompt
+		existingStrategy.Config = prompt
 		existingStrategy.Description = fmt.Sprintf("Evolution iteration %d", version)
 		if err := e.store.Strategy().Update(existingStrategy); err != nil {
 			return fmt.Errorf("failed to update strategy: %w", err)
@@ -342,7 +487,7 @@ evaluateBacktest:
 			ID:          uuid.New().String(),
 			UserID:      e.config.UserID,
 			Name:        strategyName,
-			Config:      optimization.NewPrompt,
+			Config:      prompt,
 			Description: fmt.Sprintf("Evolution iteration %d", version),
 		}
 		if err := e.store.Strategy().Create(newStrategy); err != nil {
@@ -351,27 +496,45 @@ evaluateBacktest:
 		logger.Infof("Evolution %s: created new strategy %s", e.evolutionID, strategyName)
 	}
 
-	// 13. Update base_strategy_id for next iteration (both in memory and database)
-	// Always use the AI-generated new strategy (optimization.NewPrompt) for next iteration
-	// The AI has already analyzed current vs best and generated an optimized prompt
+	// Update base_strategy_id for next iteration (both in memory and database)
 	e.config.BaseStrategyID = newStrategy.ID
 	// Persist to database so resume works correctly
 	if err := e.store.Evolution().UpdateBaseStrategy(e.evolutionID, newStrategy.ID); err != nil {
 		logger.Warnf("Failed to persist base_strategy_id: %v", err)
 	}
-	logger.Infof("Evolution %s: using AI-optimized strategy %s for next iteration", e.evolutionID, newStrategy.ID)
-
-	logger.Infof("Evolution %s v%d: iteration completed successfully", e.evolutionID, version)
+	logger.Infof("Evolution %s: using strategy %s for next iteration", e.evolutionID, newStrategy.ID)
 	return nil
 }
 
-// waitForBacktestComplete waits for backtest to finish
+// startBacktest starts a backtest. The manager queues it until a backtest slot is free (see
+// backtest.Manager.SetMaxConcurrent); queueing happens before the run exists, so it never
+// counts against waitForBacktestComplete's inactivity timeout. Stopping the evolution abandons
+// the queue and cancels the run. The returned release must be called after waiting on the run:
+// it cancels a run that is still going (e.g. a stalled one), which frees its slot.
+func (e *AutoEvolver) startBacktest(ctx context.Context, cfg backtest.BacktestConfig) (func(), error) {
+	runCtx, cancel := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-e.stopChan:
+			cancel()
+		case <-runCtx.Done():
+		}
+	}()
+	if _, err := e.backtestMgr.Start(runCtx, cfg); err != nil {
+		cancel()
+		return nil, err
+	}
+	return cancel, nil
+}
+
+// waitForBacktestComplete waits for backtest to finish. It returns an error wrapping
+// errBacktestStalled when the backtest makes no progress within the inactivity timeout.
 func (e *AutoEvolver) waitForBacktestComplete(ctx context.Context, runID string) error {
-	ticker := time.NewTicker(5 * time.Second)
+	ticker := time.NewTicker(e.pollInterval)
 	defer ticker.Stop()
 
-	// Activity-based timeout: if no progress update in 5 minutes, consider it stalled
-	const inactivityTimeout = 5 * time.Minute
+	// Activity-based timeout: if no progress update within the timeout, consider it stalled
+	inactivityTimeout := e.inactivityTimeout
 	lastProgress := float64(-1)
 	lastActivityTime := time.Now()
 
@@ -434,7 +597,7 @@ func (e *AutoEvolver) waitForBacktestComplete(ctx context.Context, runID string)
 				if time.Since(lastActivityTime) > inactivityTimeout {
 					logger.Warnf("Backtest %s appears stalled: no progress update in %v (progress: %.1f%%)",
 						runID, inactivityTimeout, currentProgress)
-					return fmt.Errorf("backtest stalled: no activity for %v", inactivityTimeout)
+					return fmt.Errorf("%w: no activity for %v", errBacktestStalled, inactivityTimeout)
 				}
 			default:
 				logger.Warnf("Unknown backtest state: %s", statusPayload.State)
@@ -443,7 +606,8 @@ func (e *AutoEvolver) waitForBacktestComplete(ctx context.Context, runID string)
 	}
 }
 
-// getIterationHistory returns summary of previous iterations for optimization context
+// getIterationHistory returns summary of previous iterations for optimization context, capped
+// to the most recent maxIterationHistory to bound the prompt size
 func (e *AutoEvolver) getIterationHistory() []IterationSummary {
 	iterations, err := e.store.Evolution().GetIterations(e.evolutionID)
 	if err != nil {
@@ -453,13 +617,18 @@ func (e *AutoEvolver) getIterationHistory() []IterationSummary {
 	// Get best version info
 	evolution, err := e.store.Evolution().Get(e.config.UserID, e.evolutionID)
 	bestVersion := 0
-	bestReturn := float64(-999999)
-	bestDrawdown := float64(100)
+	best := FitnessInput{TotalReturn: -999999, MaxDrawdown: 100}
 	if err == nil {
 		bestVersion = evolution.BestVersion
-		bestReturn = evolution.BestReturn
-		bestDrawdown = evolution.BestDrawdown
+		best.TotalReturn = evolution.BestReturn
+		best.MaxDrawdown = evolution.BestDrawdown
 	}
+	for _, iter := range iterations {
+		if iter.Version == bestVersion && iter.Metrics != nil {
+			best = fitnessFromMetrics(iter.Metrics)
+		}
+	}
+	fitness := e.fitness()
 
 	var history []IterationSummary
 	for _, iter := range iterations {
@@ -472,17 +641,41 @@ func (e *AutoEvolver) getIterationHistory() []IterationSummary {
 			summary.MaxDrawdown = iter.Metrics.MaxDrawdown
 			// Mark as best if this is the best version
 			summary.IsBest = iter.Version == bestVersion
-			// Mark as failed using same criteria as improvement check:
-			// Failed if: return is lower AND (return diff > 3% OR drawdown improvement < 5%)
-			returnDiff := iter.Metrics.TotalReturn - bestReturn
-			drawdownImprovement := bestDrawdown - iter.Metrics.MaxDrawdown
-			isBetterReturn := returnDiff > 0
-			isSimilarReturnWithBetterDrawdown := returnDiff >= -3.0 && drawdownImprovement >= 5.0
-			summary.Failed = !summary.IsBest && !isBetterReturn && !isSimilarReturnWithBetterDrawdown
+			// Mark as failed using the same fitness function as the improvement check
+			improves, _ := fitness.Improves(fitnessFromMetrics(iter.Metrics), best)
+			summary.Failed = !summary.IsBest && !improves
+			if summary.IsBest {
+				summary.Reason = iter.ImprovementReason
+			} else if summary.Failed {
+				summary.Reason = iter.FailureReason
+			}
 		}
 		history = append(history, summary)
 	}
-	return history
+	return capIterationHistory(history, maxIterationHistory)
+}
+
+// maxIterationHistory bounds how many previous iterations the optimization prompt lists
+const maxIterationHistory = 20
+
+// capIterationHistory keeps the most recent limit summaries. The best iteration is kept even
+// when it is older, since the optimizer is told to improve upon it.
+func capIterationHistory(history []IterationSummary, limit int) []IterationSummary {
+	if len(history) <= limit {
+		return history
+	}
+	recent := history[len(history)-limit:]
+	for _, h := range recent {
+		if h.IsBest {
+			return recent
+		}
+	}
+	for _, h := range history[:len(history)-limit] {
+		if h.IsBest {
+			return append([]IterationSummary{h}, recent[1:]...)
+		}
+	}
+	return recent
 }
 
 // hydrateAIConfig fills in AI configuration from database
@@ -501,34 +694,16 @@ func (e *AutoEvolver) hydrateAIConfig(cfg *backtest.BacktestConfig) error {
 		return fmt.Errorf("AI model %s is not enabled", model.Name)
 	}
 
-	apiKey := strings.TrimSpace(model.APIKey)
-	if apiKey == "" {
+	if strings.TrimSpace(model.APIKey) == "" {
 		return fmt.Errorf("AI model %s is missing API Key", model.Name)
 	}
 
-	provider := strings.ToLower(strings.TrimSpace(model.Provider))
-	if provider == "" || provider == "inherit" {
-		modelNameLower := strings.ToLower(model.Name)
-		if strings.Contains(modelNameLower, "claude") {
-			provider = "anthropic"
-		} else if strings.Contains(modelNameLower, "gpt") {
-			provider = "openai"
-		} else if strings.Contains(modelNameLower, "gemini") {
-			provider = "google"
-		} else if strings.Contains(modelNameLower, "deepseek") {
-			provider = "deepseek"
-		} else if model.CustomAPIURL != "" {
-			provider = "custom"
-		} else {
-			provider = "openai"
-		}
-	}
-
-	cfg.AICfg.Provider = provider
-	cfg.AICfg.APIKey = apiKey
-	cfg.AICfg.BaseURL = strings.TrimSpace(model.CustomAPIURL)
-	cfg.AICfg.Model = strings.TrimSpace(model.CustomModelName)
+	ai := modelAIConfig(model)
+	cfg.AICfg.Provider = ai.Provider
+	cfg.AICfg.APIKey = ai.APIKey
+	cfg.AICfg.BaseURL = ai.BaseURL
+	cfg.AICfg.Model = ai.Model
 
-	logger.Infof("Evolution AI config: provider=%s, model=%s", provider, cfg.AICfg.Model)
+	logger.Infof("Evolution AI config: provider=%s, model=%s", cfg.AICfg.Provider, cfg.AICfg.Model)
 	return nil
 }
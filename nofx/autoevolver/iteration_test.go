@@ -0,0 +1,166 @@
+package autoevolver
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"nofx/backtest"
+)
+
+func TestNewIterationMetricsCarriesProfitFactor(t *testing.T) {
+	metrics := newIterationMetrics(&backtest.Metrics{
+		TotalReturnPct: 4,
+		Trades:         4,
+		ProfitFactor:   1.6,
+		SymbolStats: map[string]backtest.SymbolMetrics{
+			"BTCUSDT": {TotalTrades: 3, TotalPnL: 30},
+			"ETHUSDT": {TotalTrades: 1, TotalPnL: -10},
+		},
+	})
+
+	if metrics.ProfitFactor != 1.6 {
+		t.Fatalf("ProfitFactor = %v, want 1.6", metrics.ProfitFactor)
+	}
+	if math.Abs(metrics.AvgTradePnL-5) > 1e-9 {
+		t.Fatalf("AvgTradePnL = %v, want 5", metrics.AvgTradePnL)
+	}
+
+	if empty := newIterationMetrics(&backtest.Metrics{}); empty.AvgTradePnL != 0 {
+		t.Fatalf("AvgTradePnL without trades = %v, want 0", empty.AvgTradePnL)
+	}
+}
+
+func TestRunIterationPersistsOutcomeReasons(t *testing.T) {
+	mgr := newStubBacktestManager(time.Millisecond, map[string]float64{
+		"base-prompt":     5,
+		mutationPrompt(1): 10,
+		mutationPrompt(2): 3,
+	})
+	cfg := &EvolutionConfig{
+		UserID:         "user-1",
+		Name:           "evo",
+		BaseStrategyID: "base",
+		MaxIterations:  3,
+		FixedParams:    FixedParams{AIModelID: "model-1"},
+	}
+	evolver, st := newTestEvolver(t, cfg, mgr)
+
+	if err := evolver.Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	iterations, err := st.Evolution().GetIterations("evo-1")
+	if err != nil {
+		t.Fatalf("GetIterations failed: %v", err)
+	}
+	if len(iterations) != 3 {
+		t.Fatalf("expected 3 iterations, got %d", len(iterations))
+	}
+	byReturn := map[float64]*Iteration{}
+	for _, iter := range iterations {
+		if iter.Metrics == nil {
+			t.Fatalf("v%d: expected metrics", iter.Version)
+		}
+		byReturn[iter.Metrics.TotalReturn] = iter
+	}
+
+	improved := byReturn[10]
+	if improved == nil {
+		t.Fatal("expected an iteration with 10% return")
+	}
+	if want := "higher return (10.00% vs 5.00%)"; improved.ImprovementReason != want {
+		t.Errorf("improvement reason = %q, expected %q", improved.ImprovementReason, want)
+	}
+	if improved.FailureReason != "" {
+		t.Errorf("promoted iteration should have no failure reason, got %q", improved.FailureReason)
+	}
+
+	failed := byReturn[3]
+	if failed == nil {
+		t.Fatal("expected an iteration with 3% return")
+	}
+	if failed.ImprovementReason != "" || !strings.Contains(failed.FailureReason, "return 3.00% vs best 10.00%") {
+		t.Errorf("unexpected reasons on regressed iteration: improvement=%q failure=%q", failed.ImprovementReason, failed.FailureReason)
+	}
+
+	// The optimizer history surfaces the stored reasons
+	for _, summary := range evolver.getIterationHistory() {
+		switch summary.Version {
+		case improved.Version:
+			if !summary.IsBest || summary.Reason != improved.ImprovementReason {
+				t.Errorf("best summary = %+v, expected reason %q", summary, improved.ImprovementReason)
+			}
+		case failed.Version:
+			if !summary.Failed || summary.Reason != failed.FailureReason {
+				t.Errorf("failed summary = %+v, expected reason %q", summary, failed.FailureReason)
+			}
+		}
+	}
+}
+
+func runLineage(t *testing.T, lineageMode string, maxIterations int) (*AutoEvolver, string) {
+	t.Helper()
+	// v1 (base) becomes best, v2 runs its mutation and regresses, as does every later mutation
+	mgr := newStubBacktestManager(time.Millisecond, map[string]float64{
+		"base-prompt":     5,
+		mutationPrompt(1): 1,
+		mutationPrompt(2): 1,
+	})
+	cfg := &EvolutionConfig{
+		UserID:         "user-1",
+		Name:           "evo",
+		BaseStrategyID: "base",
+		MaxIterations:  maxIterations,
+		LineageMode:    lineageMode,
+		FixedParams:    FixedParams{AIModelID: "model-1"},
+	}
+	evolver, st := newTestEvolver(t, cfg, mgr)
+	if err := evolver.Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	evolution, err := st.Evolution().Get("user-1", "evo-1")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if evolution.BestVersion != 1 {
+		t.Fatalf("expected best version v1, got v%d", evolution.BestVersion)
+	}
+	return evolver, evolution.BaseStrategyID
+}
+
+func TestRunIterationHillClimbContinuesFromBest(t *testing.T) {
+	evolver, next := runLineage(t, LineageModeHillClimb, 3)
+	if evolver.config.BaseStrategyID != next {
+		t.Errorf("in-memory base %q does not match persisted %q", evolver.config.BaseStrategyID, next)
+	}
+
+	// v2 regressed; its optimization started from the best (v1) prompt, so v3 runs that
+	// mutation instead of backtesting the best strategy again
+	for version, want := range map[int]string{1: "base-prompt", 2: mutationPrompt(1), 3: mutationPrompt(2)} {
+		iter, err := evolver.store.Evolution().GetIteration("evo-1", version)
+		if err != nil {
+			t.Fatalf("GetIteration(%d) failed: %v", version, err)
+		}
+		if iter.PromptBefore != want {
+			t.Errorf("v%d ran %q, expected %q", version, iter.PromptBefore, want)
+		}
+	}
+}
+
+func TestRunIterationForwardCarriesOptimizedStrategy(t *testing.T) {
+	evolver, next := runLineage(t, "", 2)
+	if next == "base" {
+		t.Fatal("expected the optimized strategy to be carried forward")
+	}
+	strategy, err := evolver.store.Strategy().Get("user-1", next)
+	if err != nil {
+		t.Fatalf("failed to load carried-forward strategy: %v", err)
+	}
+	if strategy.Config != mutationPrompt(2) {
+		t.Errorf("expected the v2 optimization to be carried forward, got %q", strategy.Config)
+	}
+}
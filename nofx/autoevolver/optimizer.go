@@ -14,12 +14,19 @@ import (
 
 // Optimizer generates improved prompts based on evaluation
 type Optimizer struct {
-	aiClient mcp.AIClient
+	aiClient   mcp.AIClient
+	maxRetries int
 }
 
 // NewOptimizer creates a new Optimizer
 func NewOptimizer(aiClient mcp.AIClient) *Optimizer {
-	return &Optimizer{aiClient: aiClient}
+	return &Optimizer{aiClient: aiClient, maxRetries: defaultMaxParseRetries}
+}
+
+// WithMaxRetries sets how often a malformed reply is re-requested (see parseRetries)
+func (o *Optimizer) WithMaxRetries(n int) *Optimizer {
+	o.maxRetries = parseRetries(n)
+	return o
 }
 
 // OptimizationInput contains data needed for prompt optimization
@@ -46,7 +53,8 @@ type IterationSummary struct {
 	MaxDrawdown float64 `json:"max_drawdown"`
 	Changes     string  `json:"changes"`
 	IsBest      bool    `json:"is_best"`
-	Failed      bool    `json:"failed"` // true if this iteration performed worse than best
+	Failed      bool    `json:"failed"`           // true if this iteration performed worse than best
+	Reason      string  `json:"reason,omitempty"` // stored reason the iteration was promoted (best) or not (failed)
 }
 
 // Optimize generates an improved prompt based on evaluation
@@ -60,18 +68,30 @@ func (o *Optimizer) Optimize(input *OptimizationInput) (*evotypes.OptimizationRe
 
 	logger.Infof("Optimizer: calling AI for prompt optimization...")
 
-	response, err := o.aiClient.CallWithMessages(systemPrompt, userPrompt)
-	if err != nil {
+	parse := func(response string) (*evotypes.OptimizationResult, error) {
+		return parseOptimizationResponse(response, input.CurrentPrompt)
+	}
+	result, response, retries, err := callForJSON(o.aiClient, systemPrompt, userPrompt, o.maxRetries, parse)
+	if err != nil && response == "" {
 		logger.Warnf("AI optimization failed, using fallback: %v", err)
 		return o.createFallbackResult(input), nil
 	}
-
-	result, err := parseOptimizationResponse(response, input.CurrentPrompt)
 	if err != nil {
 		logger.Warnf("Failed to parse optimization response: %v", err)
-		return o.createFallbackResult(input), nil
+		fallback := o.createFallbackResult(input)
+		fallback.ParseRetries = retries
+		return fallback, nil
 	}
 	result.RawResponse = response
+	result.ParseRetries = retries
+
+	// Reject mutations that would run the next backtest on a broken or out-of-bounds config
+	if result.NewPrompt != input.CurrentPrompt {
+		if err := validateStrategyConfig(result.NewPrompt, input.CurrentPrompt); err != nil {
+			logger.Warnf("Optimizer: rejecting AI mutation: %v", err)
+			return o.createRejectedResult(input, result, err.Error()), nil
+		}
+	}
 
 	return result, nil
 }
@@ -85,6 +105,18 @@ func (o *Optimizer) createFallbackResult(input *OptimizationInput) *evotypes.Opt
 	}
 }
 
+// createRejectedResult keeps the current prompt and records why the AI's mutation was rejected
+func (o *Optimizer) createRejectedResult(input *OptimizationInput, rejected *evotypes.OptimizationResult, reason string) *evotypes.OptimizationResult {
+	return &evotypes.OptimizationResult{
+		Changes:        []string{"Rejected AI mutation - keeping original prompt: " + reason},
+		NewPrompt:      input.CurrentPrompt,
+		ExpectedEffect: "Mutation rejected: " + reason,
+		RawResponse:    rejected.RawResponse,
+		ParseRetries:   rejected.ParseRetries,
+		RejectedReason: reason,
+	}
+}
+
 func buildOptimizationSystemPrompt() string {
 	return `You are an expert trading strategy prompt engineer for crypto futures. Your task is to improve a Stoch RSI + EMA + MACD strategy.
 
@@ -237,6 +269,9 @@ func buildOptimizationUserPrompt(input *OptimizationInput) string {
 		if bestIter != nil {
 			sb.WriteString(fmt.Sprintf("### 🏆 Best Performing (v%d): Return %.2f%%, Drawdown %.2f%%\n",
 				bestIter.Version, bestIter.TotalReturn, bestIter.MaxDrawdown))
+			if bestIter.Reason != "" {
+				sb.WriteString(fmt.Sprintf("Promoted because: %s\n", bestIter.Reason))
+			}
 			sb.WriteString("**This is the baseline. Your goal is to improve upon this, not make it worse.**\n\n")
 		}
 
@@ -252,6 +287,9 @@ func buildOptimizationUserPrompt(input *OptimizationInput) string {
 			for _, iter := range failedIters {
 				sb.WriteString(fmt.Sprintf("- v%d: Return %.2f%% - %s\n",
 					iter.Version, iter.TotalReturn, iter.Changes))
+				if iter.Reason != "" {
+					sb.WriteString(fmt.Sprintf("  Outcome: %s\n", iter.Reason))
+				}
 			}
 			sb.WriteString("\n**Pattern Analysis**: Look at what these failed attempts have in common. DO NOT repeat similar changes.\n\n")
 		}
@@ -321,7 +359,7 @@ func buildOptimizationUserPrompt(input *OptimizationInput) string {
 			sb.WriteString(fmt.Sprintf("- Total Return: %.2f%%\n", m.TotalReturnPct))
 			sb.WriteString(fmt.Sprintf("- Max Drawdown: %.2f%%\n", m.MaxDrawdownPct))
 			sb.WriteString(fmt.Sprintf("- Win Rate: %.2f%%\n", m.WinRate*100))
-			sb.WriteString(fmt.Sprintf("- Sharpe Ratio: %.2f\n", m.SharpeRatio))
+			sb.WriteString(fmt.Sprintf("- Sharpe Ratio (annualized): %.2f\n", m.SharpeRatio))
 			sb.WriteString(fmt.Sprintf("- Total Trades: %d\n", m.Trades))
 		}
 	}
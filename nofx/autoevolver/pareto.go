@@ -0,0 +1,203 @@
+package autoevolver
+
+import (
+	"context"
+	"math"
+
+	"nofx/evotypes"
+	"nofx/logger"
+)
+
+// SelectionModePareto promotes a member of the Pareto frontier to best version: the one
+// with the highest weighted score when FitnessWeights are configured, otherwise the knee point
+const SelectionModePareto = "pareto"
+
+// dominates reports whether a is at least as good as b on return, drawdown and
+// Sharpe, and strictly better on at least one of them. Sharpe is skipped when only
+// one of the two was annualized.
+func dominates(a, b *evotypes.Metrics) bool {
+	sharpeA, sharpeB := a.SharpeRatio, b.SharpeRatio
+	if a.PerPeriodSharpe != b.PerPeriodSharpe {
+		sharpeA, sharpeB = 0, 0
+	}
+	if a.TotalReturn < b.TotalReturn || a.MaxDrawdown > b.MaxDrawdown || sharpeA < sharpeB {
+		return false
+	}
+	return a.TotalReturn > b.TotalReturn || a.MaxDrawdown < b.MaxDrawdown || sharpeA > sharpeB
+}
+
+// mixedSharpeUnits reports whether the iterations mix per-period and annualized Sharpe ratios
+func mixedSharpeUnits(iterations []*evotypes.Iteration) bool {
+	for _, iter := range iterations {
+		if iter.Metrics.PerPeriodSharpe != iterations[0].Metrics.PerPeriodSharpe {
+			return true
+		}
+	}
+	return false
+}
+
+// ParetoFrontier returns the completed iterations not dominated by any other,
+// in version order
+func ParetoFrontier(iterations []*evotypes.Iteration) []*evotypes.Iteration {
+	var scored []*evotypes.Iteration
+	for _, iter := range iterations {
+		if iter.Status == IterStatusCompleted && iter.Metrics != nil {
+			scored = append(scored, iter)
+		}
+	}
+
+	var frontier []*evotypes.Iteration
+	for _, candidate := range scored {
+		dominated := false
+		for _, other := range scored {
+			if other != candidate && dominates(other.Metrics, candidate.Metrics) {
+				dominated = true
+				break
+			}
+		}
+		if !dominated {
+			frontier = append(frontier, candidate)
+		}
+	}
+	return frontier
+}
+
+// kneePoint picks the frontier member closest to the ideal point after scaling
+// each objective to [0, 1] across the frontier; ties go to the earlier version.
+// Sharpe is left out when the frontier mixes per-period and annualized values.
+func kneePoint(frontier []*evotypes.Iteration) *evotypes.Iteration {
+	if len(frontier) == 0 {
+		return nil
+	}
+	useSharpe := !mixedSharpeUnits(frontier)
+
+	minRet, maxRet := math.Inf(1), math.Inf(-1)
+	minDD, maxDD := math.Inf(1), math.Inf(-1)
+	minSharpe, maxSharpe := math.Inf(1), math.Inf(-1)
+	for _, iter := range frontier {
+		m := iter.Metrics
+		minRet, maxRet = math.Min(minRet, m.TotalReturn), math.Max(maxRet, m.TotalReturn)
+		minDD, maxDD = math.Min(minDD, m.MaxDrawdown), math.Max(maxDD, m.MaxDrawdown)
+		minSharpe, maxSharpe = math.Min(minSharpe, m.SharpeRatio), math.Max(maxSharpe, m.SharpeRatio)
+	}
+
+	// scale maps v to [0, 1] with 1 being the best value; a flat objective scores 1 for everyone
+	scale := func(v, lo, hi float64, higherIsBetter bool) float64 {
+		if hi-lo < 1e-12 {
+			return 1
+		}
+		if higherIsBetter {
+			return (v - lo) / (hi - lo)
+		}
+		return (hi - v) / (hi - lo)
+	}
+
+	var knee *evotypes.Iteration
+	bestDistance := math.Inf(1)
+	for _, iter := range frontier {
+		m := iter.Metrics
+		dRet := 1 - scale(m.TotalReturn, minRet, maxRet, true)
+		dDD := 1 - scale(m.MaxDrawdown, minDD, maxDD, false)
+		var dSharpe float64
+		if useSharpe {
+			dSharpe = 1 - scale(m.SharpeRatio, minSharpe, maxSharpe, true)
+		}
+		distance := math.Sqrt(dRet*dRet + dDD*dDD + dSharpe*dSharpe)
+		if distance < bestDistance-1e-12 {
+			bestDistance = distance
+			knee = iter
+		}
+	}
+	return knee
+}
+
+// weightedChoice picks the frontier member with the highest weighted fitness score;
+// ties go to the earlier version. Sharpe is left out when the frontier mixes
+// per-period and annualized values.
+func weightedChoice(frontier []*evotypes.Iteration, fitness WeightedFitness) *evotypes.Iteration {
+	useSharpe := !mixedSharpeUnits(frontier)
+	var choice *evotypes.Iteration
+	bestScore := math.Inf(-1)
+	for _, iter := range frontier {
+		in := fitnessFromMetrics(iter.Metrics)
+		if !useSharpe {
+			in.SharpeRatio = 0
+		}
+		if score := fitness.Score(in); score > bestScore+1e-12 {
+			bestScore = score
+			choice = iter
+		}
+	}
+	return choice
+}
+
+// paretoChoice picks the frontier member to promote: a user-weighted scalarization when
+// FitnessWeights are configured, otherwise the knee point
+func (e *AutoEvolver) paretoChoice(frontier []*evotypes.Iteration) *evotypes.Iteration {
+	if e.config.FitnessWeights != nil {
+		return weightedChoice(frontier, WeightedFitness{Weights: *e.config.FitnessWeights})
+	}
+	return kneePoint(frontier)
+}
+
+// paretoChoiceLabel describes the frontier member paretoChoice picks, for improvement reasons
+func (e *AutoEvolver) paretoChoiceLabel() string {
+	if e.config.FitnessWeights != nil {
+		return "highest weighted score on the Pareto frontier"
+	}
+	return "knee point of the Pareto frontier"
+}
+
+// refreshParetoFrontier recomputes and stores frontier membership for all iterations.
+// In pareto selection mode it reports whether version is now the frontier member picked
+// by paretoChoice, which replaces the return/drawdown improvement rule, and moves the best
+// version to an earlier pick when needed (subject to out-of-sample validation); otherwise
+// isImproved is returned as is.
+func (e *AutoEvolver) refreshParetoFrontier(ctx context.Context, version int, isImproved bool) bool {
+	iterations, err := e.store.Evolution().GetIterations(e.evolutionID)
+	if err != nil {
+		logger.Warnf("Evolution %s: failed to load iterations for Pareto frontier: %v", e.evolutionID, err)
+		return isImproved
+	}
+
+	frontier := ParetoFrontier(iterations)
+	versions := make([]int, 0, len(frontier))
+	for _, iter := range frontier {
+		versions = append(versions, iter.Version)
+	}
+	if err := e.store.Evolution().UpdateParetoFrontier(e.evolutionID, versions); err != nil {
+		logger.Warnf("Evolution %s: failed to store Pareto frontier: %v", e.evolutionID, err)
+	}
+
+	if e.config.SelectionMode != SelectionModePareto {
+		return isImproved
+	}
+	choice := e.paretoChoice(frontier)
+	if choice == nil {
+		return false
+	}
+	// A new frontier member can shift the pick to an earlier version
+	if choice.Version != version {
+		if evolution, err := e.store.Evolution().Get(e.config.UserID, e.evolutionID); err == nil && evolution.BestVersion != choice.Version {
+			e.promoteFrontierMember(ctx, choice)
+		}
+		return false
+	}
+	return true
+}
+
+// promoteFrontierMember makes an earlier frontier member the best version once it
+// passes out-of-sample validation, like any other promotion
+func (e *AutoEvolver) promoteFrontierMember(ctx context.Context, iter *evotypes.Iteration) {
+	strategy, err := e.store.Strategy().Get(e.config.UserID, iter.StrategyID)
+	if err != nil {
+		logger.Warnf("Evolution %s: failed to load strategy of Pareto pick v%d: %v", e.evolutionID, iter.Version, err)
+		return
+	}
+	if ok, reason := e.validateForPromotion(ctx, strategy, iter.BacktestRunID, iter.PromptBefore, iter.Version); !ok {
+		logger.Infof("Evolution %s: Pareto pick v%d not promoted: %s", e.evolutionID, iter.Version, reason)
+		return
+	}
+	logger.Infof("Evolution %s: Pareto pick moved to v%d", e.evolutionID, iter.Version)
+	e.updateBestVersion(iter.Version, iter.Metrics.TotalReturn, iter.Metrics.MaxDrawdown)
+}
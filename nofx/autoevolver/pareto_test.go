@@ -0,0 +1,238 @@
+package autoevolver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"nofx/evotypes"
+)
+
+func paretoIteration(version int, totalReturn, maxDrawdown, sharpe float64) *Iteration {
+	return &Iteration{
+		Version: version,
+		Status:  IterStatusCompleted,
+		Metrics: &Metrics{TotalReturn: totalReturn, MaxDrawdown: maxDrawdown, SharpeRatio: sharpe},
+	}
+}
+
+func frontierVersions(frontier []*Iteration) []int {
+	versions := make([]int, 0, len(frontier))
+	for _, iter := range frontier {
+		versions = append(versions, iter.Version)
+	}
+	return versions
+}
+
+func TestParetoFrontierKeepsNonDominatedIterations(t *testing.T) {
+	iterations := []*Iteration{
+		paretoIteration(1, 10, 20, 1.0), // high return, high drawdown
+		paretoIteration(2, 4, 5, 1.2),   // low return, much safer
+		paretoIteration(3, 8, 25, 0.8),  // dominated by v1
+		paretoIteration(4, 10, 20, 1.0), // identical to v1: neither dominates
+		paretoIteration(5, 3, 5, 1.2),   // dominated by v2 on return only
+		paretoIteration(6, 2, 15, 2.0),  // best Sharpe keeps it on the frontier
+		{Version: 7, Status: IterStatusFailed},
+	}
+
+	got := frontierVersions(ParetoFrontier(iterations))
+	want := []int{1, 2, 4, 6}
+	if len(got) != len(want) {
+		t.Fatalf("expected frontier %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected frontier %v, got %v", want, got)
+		}
+	}
+}
+
+func TestKneePointPrefersBalancedTradeOff(t *testing.T) {
+	frontier := []*Iteration{
+		paretoIteration(1, 20, 30, 1.0),
+		paretoIteration(2, 15, 10, 1.0),
+		paretoIteration(3, 2, 5, 1.0),
+	}
+	if knee := kneePoint(frontier); knee == nil || knee.Version != 2 {
+		t.Errorf("expected balanced v2 as knee, got %+v", knee)
+	}
+	if kneePoint(nil) != nil {
+		t.Error("empty frontier has no knee")
+	}
+}
+
+func TestParetoIgnoresSharpeAcrossUnits(t *testing.T) {
+	legacy := paretoIteration(1, 10, 20, 0.05) // per-period Sharpe from before annualization
+	legacy.Metrics.PerPeriodSharpe = true
+	annualized := paretoIteration(2, 8, 20, 1.5)
+
+	// On return and drawdown alone v1 dominates, whatever the Sharpe values say
+	got := frontierVersions(ParetoFrontier([]*Iteration{legacy, annualized}))
+	if len(got) != 1 || got[0] != 1 {
+		t.Errorf("expected frontier [1] when Sharpe units differ, got %v", got)
+	}
+
+	// Without the flag the higher Sharpe keeps v2 on the frontier
+	legacy.Metrics.PerPeriodSharpe = false
+	got = frontierVersions(ParetoFrontier([]*Iteration{legacy, annualized}))
+	if len(got) != 2 {
+		t.Errorf("expected frontier [1 2] with comparable Sharpe, got %v", got)
+	}
+}
+
+func TestParetoSelectionModeStoresFrontierAndBest(t *testing.T) {
+	// Every generation candidate gets the same in-sample metrics except return,
+	// so the frontier is decided by return alone
+	mgr := newStubBacktestManager(time.Millisecond, map[string]float64{
+		"base-prompt":     2,
+		mutationPrompt(1): 9,
+		mutationPrompt(2): 5,
+	})
+	cfg := newParallelConfig(3, 3)
+	cfg.SelectionMode = SelectionModePareto
+	evolver, st := newTestEvolver(t, cfg, mgr)
+
+	if err := evolver.Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	iterations, err := st.Evolution().GetIterations("evo-1")
+	if err != nil {
+		t.Fatalf("GetIterations failed: %v", err)
+	}
+	var winner int
+	for _, iter := range iterations {
+		onFrontier := iter.PromptBefore == mutationPrompt(1)
+		if onFrontier {
+			winner = iter.Version
+		}
+		if iter.OnParetoFrontier != onFrontier {
+			t.Errorf("v%d (%s): expected frontier flag %v", iter.Version, iter.PromptBefore, onFrontier)
+		}
+	}
+
+	evolution, err := st.Evolution().Get("user-1", "evo-1")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if evolution.BestVersion != winner {
+		t.Errorf("expected knee v%d as best, got v%d", winner, evolution.BestVersion)
+	}
+
+	status, err := evolver.GetEvolutionStatus()
+	if err != nil {
+		t.Fatalf("GetEvolutionStatus failed: %v", err)
+	}
+	if len(status.ParetoFrontier) != 1 || status.ParetoFrontier[0].Version != winner {
+		t.Errorf("expected status frontier [v%d], got %v", winner, frontierVersions(status.ParetoFrontier))
+	}
+}
+
+func TestWeightedChoiceFollowsUserWeights(t *testing.T) {
+	frontier := []*Iteration{
+		paretoIteration(1, 20, 30, 1.0),
+		paretoIteration(2, 15, 10, 1.0),
+		paretoIteration(3, 2, 5, 3.0),
+	}
+	tests := []struct {
+		name    string
+		weights evotypes.FitnessWeights
+		want    int
+	}{
+		{name: "return only", weights: evotypes.FitnessWeights{ReturnWeight: 1}, want: 1},
+		{name: "return and drawdown", weights: evotypes.FitnessWeights{ReturnWeight: 1, DrawdownWeight: 1}, want: 2},
+		{name: "sharpe heavy", weights: evotypes.FitnessWeights{ReturnWeight: 1, DrawdownWeight: 1, SharpeWeight: 20}, want: 3},
+		{name: "ties go to the earlier version", weights: evotypes.FitnessWeights{}, want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := weightedChoice(frontier, WeightedFitness{Weights: tt.weights}); got == nil || got.Version != tt.want {
+				t.Errorf("expected v%d, got %+v", tt.want, got)
+			}
+		})
+	}
+	if weightedChoice(nil, WeightedFitness{}) != nil {
+		t.Error("empty frontier has no pick")
+	}
+}
+
+func TestParetoSelectionModeUsesFitnessWeights(t *testing.T) {
+	mgr := newStubBacktestManager(time.Millisecond, map[string]float64{
+		"base-prompt":     20,
+		mutationPrompt(1): 2,
+	})
+	mgr.drawdowns = map[string]float64{"base-prompt": 30, mutationPrompt(1): 5}
+	cfg := &EvolutionConfig{
+		UserID:         "user-1",
+		Name:           "evo",
+		BaseStrategyID: "base",
+		MaxIterations:  2,
+		SelectionMode:  SelectionModePareto,
+		FitnessWeights: &evotypes.FitnessWeights{ReturnWeight: 1, DrawdownWeight: 1},
+		FixedParams:    FixedParams{AIModelID: "model-1"},
+	}
+	evolver, st := newTestEvolver(t, cfg, mgr)
+	if err := evolver.Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	// Score -10 for v1 against -3 for v2: the safer v2 wins
+	evolution, err := st.Evolution().Get("user-1", "evo-1")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if evolution.BestVersion != 2 {
+		t.Errorf("expected the best weighted score v2 as best, got v%d", evolution.BestVersion)
+	}
+	iter, err := st.Evolution().GetIteration("evo-1", 2)
+	if err != nil {
+		t.Fatalf("GetIteration failed: %v", err)
+	}
+	if iter.ImprovementReason != "higher fitness score (-3.00 vs -10.00)" {
+		t.Errorf("unexpected improvement reason %q", iter.ImprovementReason)
+	}
+}
+
+func TestParetoPromotionOfEarlierPickIsValidated(t *testing.T) {
+	// v1 and v2 tie as knee (v1 kept); v3 shifts the knee back to v2
+	run := func(v2ValidationReturn float64) int {
+		mgr := newStubBacktestManager(time.Millisecond, map[string]float64{
+			"base-prompt":     20,
+			mutationPrompt(1): 15,
+			mutationPrompt(2): 2,
+		})
+		mgr.drawdowns = map[string]float64{"base-prompt": 30, mutationPrompt(1): 10, mutationPrompt(2): 5}
+		mgr.validationReturns["base-prompt"] = 5
+		mgr.validationReturns[mutationPrompt(1)] = v2ValidationReturn
+		mgr.validationReturns[mutationPrompt(2)] = 5
+		cfg := &EvolutionConfig{
+			UserID:         "user-1",
+			Name:           "evo",
+			BaseStrategyID: "base",
+			MaxIterations:  3,
+			SelectionMode:  SelectionModePareto,
+			FixedParams: FixedParams{
+				AIModelID:       "model-1",
+				StartTS:         1000,
+				EndTS:           2000,
+				ValidationSplit: 0.25,
+			},
+		}
+		evolver, st := newTestEvolver(t, cfg, mgr)
+		if err := evolver.Start(context.Background()); err != nil {
+			t.Fatalf("Start failed: %v", err)
+		}
+		evolution, err := st.Evolution().Get("user-1", "evo-1")
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		return evolution.BestVersion
+	}
+
+	if best := run(5); best != 2 {
+		t.Errorf("expected the knee to move to v2, got v%d", best)
+	}
+	if best := run(-20); best != 1 {
+		t.Errorf("expected v2 to be rejected out-of-sample and v1 to stay best, got v%d", best)
+	}
+}
@@ -0,0 +1,298 @@
+package autoevolver
+
+import (
+	"context"
+	"fmt"
+
+	"nofx/evotypes"
+	"nofx/logger"
+)
+
+const (
+	defaultPopulationSize = 4 // Members per generation when PopulationSize is unset
+	defaultEliteCount     = 2 // Members surviving each generation when EliteCount is unset
+)
+
+// populationMode reports whether the evolution evolves a population of seed strategies
+func (e *AutoEvolver) populationMode() bool {
+	return len(e.config.SeedStrategyIDs) > 0
+}
+
+// populationSizes returns the number of members per generation and how many of them survive.
+// At least one slot per generation is always left for offspring.
+func (e *AutoEvolver) populationSizes() (size, elites int) {
+	size = e.config.PopulationSize
+	if size <= 0 {
+		size = max(len(e.config.SeedStrategyIDs)+1, defaultPopulationSize)
+	}
+	size = max(size, 2)
+
+	elites = e.config.EliteCount
+	if elites <= 0 {
+		elites = defaultEliteCount
+	}
+	return size, min(elites, size-1)
+}
+
+// runPopulationGeneration evaluates one generation of the population. The first generation
+// backtests the seed strategies; every later one keeps the elites of the previous generation
+// (without re-running them) and fills the remaining slots with their mutations and crossovers.
+// The members are ranked by fitness, the top EliteCount survive, and membership is persisted
+// per generation. Each new member consumes one version; the number consumed is returned.
+func (e *AutoEvolver) runPopulationGeneration(ctx context.Context, version int) (int, error) {
+	// 1. Get the strategy the iterations are recorded against
+	strategy, err := e.store.Strategy().Get(e.config.UserID, e.config.BaseStrategyID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get strategy: %w", err)
+	}
+
+	// 2. Load the elites that survived the previous generation
+	generation, parents, err := e.loadElites()
+	if err != nil {
+		return 0, fmt.Errorf("failed to load population: %w", err)
+	}
+	generation++
+
+	size, eliteCount := e.populationSizes()
+	remaining := e.config.MaxIterations - version + 1
+
+	// 3. Breed the new members: the seeds first, offspring of the elites afterwards.
+	// Seeds beyond the population size are dropped.
+	var offspring []*candidate
+	if len(parents) == 0 {
+		offspring, err = e.seedCandidates(min(size, remaining))
+		if err != nil {
+			return 0, err
+		}
+	} else {
+		offspring = e.breedOffspring(parents, min(size-len(parents), remaining))
+	}
+	if len(offspring) == 0 {
+		return 0, fmt.Errorf("no new members for generation %d", generation)
+	}
+	logger.Infof("Evolution %s v%d: generation %d evaluates %d new members alongside %d elites",
+		e.evolutionID, version, generation, len(offspring), len(parents))
+
+	// 4. Backtest the new members
+	e.runCandidates(ctx, strategy, version, offspring)
+	lastVersion := version + len(offspring) - 1
+	e.store.Evolution().UpdateCurrentIteration(e.evolutionID, lastVersion)
+
+	pool := append([]*candidate(nil), parents...)
+	var errs []error
+	for _, c := range offspring {
+		if c.err != nil {
+			logger.Warnf("Evolution %s v%d: member failed: %v", e.evolutionID, c.version, c.err)
+			errs = append(errs, fmt.Errorf("v%d: %w", c.version, c.err))
+			continue
+		}
+		pool = append(pool, c)
+	}
+	if len(pool) == len(parents) {
+		return 0, fmt.Errorf("all %d new members failed: %v", len(offspring), errs)
+	}
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	// 5. Rank the population; the elites come first, so a tie keeps the survivor
+	ranked := rankCandidates(e.fitness(), pool)
+	eliteCount = min(eliteCount, len(ranked))
+	best := ranked[0]
+	logger.Infof("Evolution %s: generation %d leader v%d, return=%.2f%%, drawdown=%.2f%%",
+		e.evolutionID, generation, best.version, best.metrics.TotalReturnPct, best.metrics.MaxDrawdownPct)
+
+	// 6. A new leader is evaluated and promoted like a generation winner
+	isImproved, reason, failureReason := false, "", ""
+	if !best.carried {
+		isImproved, reason, failureReason = e.promoteWinner(ctx, strategy, best)
+		if err := e.carryForwardStrategy(strategy, best.version, best.prompt); err != nil {
+			return 0, err
+		}
+	}
+
+	// 7. Persist the membership of this generation
+	members := make([]*evotypes.PopulationMember, len(ranked))
+	outcomes := make(map[*candidate]string, len(ranked))
+	for i, c := range ranked {
+		members[i] = &evotypes.PopulationMember{
+			EvolutionID: e.evolutionID,
+			Generation:  generation,
+			Version:     c.version,
+			Rank:        i + 1,
+			Elite:       i < eliteCount,
+		}
+		switch {
+		case i == 0:
+			// The leader's outcome is recorded when it is promoted
+		case i < eliteCount:
+			outcomes[c] = fmt.Sprintf("survived generation %d as elite #%d behind v%d", generation, i+1, best.version)
+		default:
+			outcomes[c] = fmt.Sprintf("eliminated in generation %d (rank %d of %d)", generation, i+1, len(ranked))
+		}
+	}
+	if err := e.store.Evolution().SavePopulation(e.evolutionID, generation, members); err != nil {
+		return 0, fmt.Errorf("failed to save population: %w", err)
+	}
+
+	for _, c := range offspring {
+		if c.err != nil {
+			continue
+		}
+		switch {
+		case c == best && isImproved:
+			e.notifyIterationCompleted(c.version, newIterationMetrics(c.metrics), true, reason)
+		case c == best:
+			e.notifyIterationCompleted(c.version, newIterationMetrics(c.metrics), false, failureReason)
+		default:
+			e.recordIterationOutcome(c.version, false, "", outcomes[c])
+			e.notifyIterationCompleted(c.version, newIterationMetrics(c.metrics), false, outcomes[c])
+		}
+	}
+
+	logger.Infof("Evolution %s v%d-v%d: generation %d completed successfully", e.evolutionID, version, lastVersion, generation)
+	return len(offspring), nil
+}
+
+// loadElites returns the latest population generation and its elites as already-evaluated
+// candidates, fittest first. Generation 0 without elites means the population is not seeded yet.
+func (e *AutoEvolver) loadElites() (int, []*candidate, error) {
+	generation, err := e.store.Evolution().GetLatestPopulationGeneration(e.evolutionID)
+	if err != nil || generation == 0 {
+		return 0, nil, err
+	}
+	members, err := e.store.Evolution().GetPopulation(e.evolutionID, generation)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var elites []*candidate
+	for _, m := range members {
+		if !m.Elite {
+			continue
+		}
+		iter, err := e.store.Evolution().GetIteration(e.evolutionID, m.Version)
+		if err != nil {
+			logger.Warnf("Evolution %s: elite v%d dropped, iteration not found: %v", e.evolutionID, m.Version, err)
+			continue
+		}
+		metrics, err := e.backtestMgr.GetMetrics(iter.BacktestRunID)
+		if err != nil {
+			logger.Warnf("Evolution %s: elite v%d dropped, metrics unavailable: %v", e.evolutionID, m.Version, err)
+			continue
+		}
+		elites = append(elites, &candidate{
+			version: m.Version,
+			runID:   iter.BacktestRunID,
+			prompt:  iter.PromptBefore,
+			metrics: metrics,
+			carried: true,
+		})
+	}
+	return generation, elites, nil
+}
+
+// seedCandidates returns the distinct prompts of the base and seed strategies, at most limit of them
+func (e *AutoEvolver) seedCandidates(limit int) ([]*candidate, error) {
+	var seeds []*candidate
+	seen := make(map[string]bool)
+	for _, id := range append([]string{e.config.BaseStrategyID}, e.config.SeedStrategyIDs...) {
+		if len(seeds) >= limit {
+			break
+		}
+		seed, err := e.store.Strategy().Get(e.config.UserID, id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get seed strategy %s: %w", id, err)
+		}
+		prompt := "baseline"
+		if seed.Config != "" {
+			prompt = seed.Config
+		}
+		if seen[prompt] {
+			continue
+		}
+		seen[prompt] = true
+		seeds = append(seeds, &candidate{prompt: prompt, changes: fmt.Sprintf("Seed strategy %s", seed.Name)})
+	}
+	return seeds, nil
+}
+
+// breedOffspring returns up to count new prompts bred from parents (fittest first): crossovers
+// of the fittest parent with every other one (when enabled), then AI mutations of the parents
+// taken round-robin
+func (e *AutoEvolver) breedOffspring(parents []*candidate, count int) []*candidate {
+	var offspring []*candidate
+	seen := make(map[string]bool)
+	for _, p := range parents {
+		seen[p.prompt] = true
+	}
+
+	if e.config.EnableCrossover {
+		for _, other := range parents[1:] {
+			if len(offspring) >= count {
+				break
+			}
+			child, err := Crossover(parents[0].prompt, other.prompt, e.crossoverMap())
+			if err != nil {
+				logger.Warnf("Evolution %s: crossover of v%d with v%d skipped: %v", e.evolutionID, parents[0].version, other.version, err)
+				continue
+			}
+			if seen[child] {
+				continue
+			}
+			seen[child] = true
+			offspring = append(offspring, &candidate{
+				prompt:  child,
+				changes: fmt.Sprintf("Crossover of v%d with v%d", parents[0].version, other.version),
+			})
+		}
+	}
+
+	history := e.getIterationHistory()
+	optimizer := NewOptimizer(e.aiClient).WithMaxRetries(e.config.MaxParseRetries)
+	// Allow a few wasted attempts for failed or duplicate mutations
+	for attempt := 0; len(offspring) < count && attempt < 2*count; attempt++ {
+		parent := parents[attempt%len(parents)]
+		optimization, err := optimizer.Optimize(&OptimizationInput{
+			CurrentPrompt:    parent.prompt,
+			IterationHistory: history,
+			CurrentMetrics:   parent.metrics,
+			CurrentVersion:   parent.version,
+			IsCurrentBest:    parent == parents[0],
+		})
+		if err != nil {
+			logger.Warnf("AI mutation of v%d failed: %v", parent.version, err)
+			continue
+		}
+		if seen[optimization.NewPrompt] {
+			continue
+		}
+		seen[optimization.NewPrompt] = true
+		offspring = append(offspring, &candidate{
+			prompt:  optimization.NewPrompt,
+			changes: fmt.Sprintf("Mutation of v%d: %s", parent.version, optimization.ExpectedEffect),
+		})
+	}
+	return offspring
+}
+
+// rankCandidates orders candidates fittest first by repeatedly taking the winner of a
+// left-to-right pass, in which a candidate replaces the current pick only when it improves on
+// it. Earlier candidates win ties, which keeps the order stable. Improves is not necessarily
+// transitive (ThresholdFitness compares within tolerances), so the pick is only guaranteed
+// to beat the candidates it was compared against and the order can depend on the pool order.
+func rankCandidates(fitness FitnessFunc, pool []*candidate) []*candidate {
+	remaining := append([]*candidate(nil), pool...)
+	ranked := make([]*candidate, 0, len(pool))
+	for len(remaining) > 0 {
+		bestIdx := 0
+		for i := 1; i < len(remaining); i++ {
+			if better, _ := fitness.Improves(fitnessFromBacktest(remaining[i].metrics), fitnessFromBacktest(remaining[bestIdx].metrics)); better {
+				bestIdx = i
+			}
+		}
+		ranked = append(ranked, remaining[bestIdx])
+		remaining = append(remaining[:bestIdx], remaining[bestIdx+1:]...)
+	}
+	return ranked
+}
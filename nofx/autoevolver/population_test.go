@@ -0,0 +1,158 @@
+package autoevolver
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"nofx/store"
+)
+
+// newPopulationConfig seeds the population with the base strategy plus seed-a and seed-b
+func newPopulationConfig(maxIterations int) *EvolutionConfig {
+	return &EvolutionConfig{
+		UserID:          "user-1",
+		Name:            "evo",
+		BaseStrategyID:  "base",
+		MaxIterations:   maxIterations,
+		SeedStrategyIDs: []string{"seed-a", "seed-b"},
+		PopulationSize:  4,
+		EliteCount:      2,
+		FixedParams:     FixedParams{AIModelID: "model-1"},
+	}
+}
+
+func runPopulation(t *testing.T, cfg *EvolutionConfig, mgr *stubBacktestManager) *store.Store {
+	t.Helper()
+	evolver, st := newTestEvolver(t, cfg, mgr)
+	for _, id := range []string{"seed-a", "seed-b"} {
+		if err := st.Strategy().Create(&store.Strategy{ID: id, UserID: "user-1", Name: id, Config: id + "-prompt"}); err != nil {
+			t.Fatalf("failed to create seed strategy: %v", err)
+		}
+	}
+	if err := evolver.Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	return st
+}
+
+// generationVersions returns the versions of a generation in rank order and its elites
+func generationVersions(t *testing.T, st *store.Store, generation int) (ranked, elites []int) {
+	t.Helper()
+	members, err := st.Evolution().GetPopulation("evo-1", generation)
+	if err != nil {
+		t.Fatalf("GetPopulation(%d) failed: %v", generation, err)
+	}
+	for _, m := range members {
+		ranked = append(ranked, m.Version)
+		if m.Elite {
+			elites = append(elites, m.Version)
+		}
+	}
+	return ranked, elites
+}
+
+func equalVersions(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestPopulationElitismKeepsBestMember(t *testing.T) {
+	// seed-a (v2) leads the seeds; none of the mutations of later generations beat it
+	mgr := newStubBacktestManager(time.Millisecond, map[string]float64{
+		"base-prompt":   5,
+		"seed-a-prompt": 20,
+		"seed-b-prompt": 1,
+	})
+	st := runPopulation(t, newPopulationConfig(7), mgr)
+
+	latest, err := st.Evolution().GetLatestPopulationGeneration("evo-1")
+	if err != nil || latest != 3 {
+		t.Fatalf("expected 3 generations, got %d (err %v)", latest, err)
+	}
+	for generation := 1; generation <= latest; generation++ {
+		ranked, elites := generationVersions(t, st, generation)
+		if len(ranked) == 0 || ranked[0] != 2 || elites[0] != 2 {
+			t.Errorf("generation %d: expected v2 to lead and survive, got ranks %v elites %v", generation, ranked, elites)
+		}
+	}
+
+	// Elites are carried over, not backtested again: every version ran exactly once
+	if len(mgr.runs) != 7 {
+		t.Errorf("expected 7 backtests, got %d", len(mgr.runs))
+	}
+	evolution, err := st.Evolution().Get("user-1", "evo-1")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if evolution.BestVersion != 2 {
+		t.Errorf("expected best version v2, got v%d", evolution.BestVersion)
+	}
+}
+
+func TestPopulationGenerationalTurnover(t *testing.T) {
+	// Generation 2 breeds mutation 1 (v4) from seed-a and mutation 2 (v5) from base;
+	// mutation 1 takes the lead and pushes the base strategy (v1) out of the elites
+	mgr := newStubBacktestManager(time.Millisecond, map[string]float64{
+		"base-prompt":     5,
+		"seed-a-prompt":   20,
+		"seed-b-prompt":   1,
+		mutationPrompt(1): 30,
+		mutationPrompt(2): 2,
+	})
+	st := runPopulation(t, newPopulationConfig(5), mgr)
+
+	ranked, elites := generationVersions(t, st, 1)
+	if !equalVersions(ranked, []int{2, 1, 3}) || !equalVersions(elites, []int{2, 1}) {
+		t.Fatalf("generation 1: got ranks %v elites %v", ranked, elites)
+	}
+	ranked, elites = generationVersions(t, st, 2)
+	if !equalVersions(ranked, []int{4, 2, 1, 5}) || !equalVersions(elites, []int{4, 2}) {
+		t.Fatalf("generation 2: got ranks %v elites %v", ranked, elites)
+	}
+
+	evolution, err := st.Evolution().Get("user-1", "evo-1")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if evolution.BestVersion != 4 {
+		t.Errorf("expected best version v4, got v%d", evolution.BestVersion)
+	}
+	eliminated, err := st.Evolution().GetIteration("evo-1", 5)
+	if err != nil {
+		t.Fatalf("GetIteration failed: %v", err)
+	}
+	if !strings.Contains(eliminated.FailureReason, "eliminated in generation 2") {
+		t.Errorf("expected v5 to be recorded as eliminated, got %q", eliminated.FailureReason)
+	}
+}
+
+func TestPopulationSeedsCappedAtPopulationSize(t *testing.T) {
+	// Three seed prompts but only two slots: seed-b is dropped from the first generation
+	mgr := newStubBacktestManager(time.Millisecond, map[string]float64{
+		"base-prompt":   5,
+		"seed-a-prompt": 20,
+		"seed-b-prompt": 1,
+	})
+	cfg := newPopulationConfig(4)
+	cfg.PopulationSize = 2
+	st := runPopulation(t, cfg, mgr)
+
+	ranked, _ := generationVersions(t, st, 1)
+	if !equalVersions(ranked, []int{2, 1}) {
+		t.Fatalf("generation 1: expected ranks [2 1], got %v", ranked)
+	}
+	for _, prompt := range mgr.runs {
+		if prompt == "seed-b-prompt" {
+			t.Errorf("expected seed-b to be dropped, but it was backtested")
+		}
+	}
+}
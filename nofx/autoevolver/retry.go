@@ -0,0 +1,52 @@
+package autoevolver
+
+import (
+	"fmt"
+
+	"nofx/logger"
+	"nofx/mcp"
+)
+
+// defaultMaxParseRetries is how often a malformed AI reply is re-requested before falling back
+const defaultMaxParseRetries = 2
+
+// jsonCorrectionPrompt is appended to the user prompt when the previous reply could not be parsed
+const jsonCorrectionPrompt = "\n\n## Correction\nYour previous reply was not valid JSON (%v). Return ONLY the JSON object in the required format, with no other text."
+
+// callForJSON calls the AI and parses its reply, re-prompting with a correction up to maxRetries
+// times while the reply cannot be parsed. It returns the parsed result, the last raw reply and
+// the number of retries made. Call errors are returned immediately: the client retries those itself.
+func callForJSON[T any](client mcp.AIClient, systemPrompt, userPrompt string, maxRetries int, parse func(string) (T, error)) (T, string, int, error) {
+	var zero T
+
+	prompt := userPrompt
+	for retries := 0; ; retries++ {
+		response, err := client.CallWithMessages(systemPrompt, prompt)
+		if err != nil {
+			return zero, "", retries, err
+		}
+
+		result, err := parse(response)
+		if err == nil {
+			return result, response, retries, nil
+		}
+		if retries >= maxRetries {
+			return zero, response, retries, fmt.Errorf("after %d retries: %w", retries, err)
+		}
+
+		logger.Warnf("AI reply is not valid JSON (%v), retrying (%d/%d)", err, retries+1, maxRetries)
+		prompt = userPrompt + fmt.Sprintf(jsonCorrectionPrompt, err)
+	}
+}
+
+// parseRetries returns the configured number of parse retries: 0 uses the default and a
+// negative value disables retries
+func parseRetries(configured int) int {
+	switch {
+	case configured < 0:
+		return 0
+	case configured == 0:
+		return defaultMaxParseRetries
+	}
+	return configured
+}
@@ -0,0 +1,120 @@
+package autoevolver
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"nofx/backtest"
+	"nofx/mcp"
+)
+
+// scriptedAIClient replies with the scripted responses in order and records the user prompts
+type scriptedAIClient struct {
+	responses []string
+	err       error
+	prompts   []string
+}
+
+func (c *scriptedAIClient) SetAPIKey(apiKey string, customURL string, customModel string) {}
+func (c *scriptedAIClient) SetTimeout(timeout time.Duration)                              {}
+func (c *scriptedAIClient) CallWithRequest(req *mcp.Request) (string, error)              { return "", nil }
+
+func (c *scriptedAIClient) CallWithMessages(systemPrompt, userPrompt string) (string, error) {
+	c.prompts = append(c.prompts, userPrompt)
+	if c.err != nil {
+		return "", c.err
+	}
+	if len(c.responses) == 0 {
+		return "", errors.New("no scripted response left")
+	}
+	response := c.responses[0]
+	c.responses = c.responses[1:]
+	return response, nil
+}
+
+// optimizationReply encodes an optimizer reply proposing newPrompt
+func optimizationReply(newPrompt string) string {
+	reply, _ := json.Marshal(map[string]interface{}{
+		"changes":         []string{"tighter stops"},
+		"new_prompt":      newPrompt,
+		"expected_effect": "less drawdown",
+	})
+	return string(reply)
+}
+
+func TestOptimizerRetriesMalformedJSON(t *testing.T) {
+	client := &scriptedAIClient{responses: []string{
+		"Sure! Here is the improved strategy: new_prompt = better",
+		optimizationReply(mutationPrompt(1)),
+	}}
+
+	result, err := NewOptimizer(client).Optimize(&OptimizationInput{CurrentPrompt: "base-prompt"})
+	if err != nil {
+		t.Fatalf("Optimize failed: %v", err)
+	}
+	if result.NewPrompt != mutationPrompt(1) {
+		t.Fatalf("expected retry to yield the improved prompt, got %q", result.NewPrompt)
+	}
+	if result.ParseRetries != 1 {
+		t.Fatalf("ParseRetries = %d, expected 1", result.ParseRetries)
+	}
+	if len(client.prompts) != 2 || !strings.Contains(client.prompts[1], "not valid JSON") {
+		t.Fatalf("expected a second call with a JSON correction, got prompts %q", client.prompts)
+	}
+}
+
+func TestAnalyzerRetriesMalformedJSON(t *testing.T) {
+	client := &scriptedAIClient{responses: []string{
+		`{"strengths": ["good entries"`,
+		`{"strengths":["good entries"],"weaknesses":["late exits"],"suggestions":["trail stops"]}`,
+	}}
+
+	report, err := NewAnalyzer(client).Analyze(&AnalysisInput{Metrics: &backtest.Metrics{}})
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+	if len(report.Weaknesses) != 1 || report.Weaknesses[0] != "late exits" {
+		t.Fatalf("expected the retried AI report, got %+v", report)
+	}
+	if report.ParseRetries != 1 {
+		t.Fatalf("ParseRetries = %d, expected 1", report.ParseRetries)
+	}
+}
+
+func TestOptimizerFallsBackAfterMaxRetries(t *testing.T) {
+	client := &scriptedAIClient{responses: []string{"garbage", "still garbage", "more garbage", "unused"}}
+
+	result, err := NewOptimizer(client).WithMaxRetries(2).Optimize(&OptimizationInput{CurrentPrompt: "base-prompt"})
+	if err != nil {
+		t.Fatalf("Optimize failed: %v", err)
+	}
+	if result.NewPrompt != "base-prompt" {
+		t.Fatalf("expected fallback to keep the current prompt, got %q", result.NewPrompt)
+	}
+	if len(client.prompts) != 3 || result.ParseRetries != 2 {
+		t.Fatalf("expected 1 call + 2 retries, got %d calls and %d retries", len(client.prompts), result.ParseRetries)
+	}
+}
+
+func TestCallErrorsAreNotRetried(t *testing.T) {
+	client := &scriptedAIClient{err: errors.New("connection refused")}
+
+	result, err := NewOptimizer(client).Optimize(&OptimizationInput{CurrentPrompt: "base-prompt"})
+	if err != nil {
+		t.Fatalf("Optimize failed: %v", err)
+	}
+	if result.NewPrompt != "base-prompt" || len(client.prompts) != 1 {
+		t.Fatalf("expected a single call and the fallback result, got %d calls and %q", len(client.prompts), result.NewPrompt)
+	}
+}
+
+func TestParseRetries(t *testing.T) {
+	for configured, expected := range map[int]int{-1: 0, 0: defaultMaxParseRetries, 4: 4} {
+		if got := parseRetries(configured); got != expected {
+			t.Errorf("parseRetries(%d) = %d, expected %d", configured, got, expected)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package autoevolver
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"nofx/store"
+)
+
+// strategyBound is a parameter boundary the optimization prompt tells the AI to respect
+type strategyBound struct {
+	field    string
+	min, max float64
+	value    func(rc *store.RiskControlConfig) float64
+}
+
+// strategyBounds mirrors the "Parameter Boundaries (STRICT)" section of the optimization prompt
+var strategyBounds = []strategyBound{
+	{"risk_control.max_positions", 2, 4, func(rc *store.RiskControlConfig) float64 { return float64(rc.MaxPositions) }},
+	{"risk_control.btc_eth_max_leverage", 4, 5, func(rc *store.RiskControlConfig) float64 { return float64(rc.BTCETHMaxLeverage) }},
+	{"risk_control.altcoin_max_leverage", 4, 5, func(rc *store.RiskControlConfig) float64 { return float64(rc.AltcoinMaxLeverage) }},
+	{"risk_control.max_margin_usage", 0.6, 0.9, func(rc *store.RiskControlConfig) float64 { return rc.MaxMarginUsage }},
+	{"risk_control.min_confidence", 65, 80, func(rc *store.RiskControlConfig) float64 { return float64(rc.MinConfidence) }},
+}
+
+// validateStrategyConfig checks that an optimized prompt is a strategy config within the
+// parameter boundaries. Violations the current prompt already has are tolerated: the
+// optimizer did not introduce them and rejecting every mutation would stall the evolution.
+func validateStrategyConfig(newPrompt, currentPrompt string) error {
+	violations, err := strategyConfigViolations(newPrompt)
+	if err != nil {
+		return fmt.Errorf("new_prompt is not a valid strategy config: %w", err)
+	}
+
+	inherited := make(map[string]bool)
+	if current, err := strategyConfigViolations(currentPrompt); err == nil {
+		for _, v := range current {
+			inherited[v] = true
+		}
+	}
+
+	var introduced []string
+	for _, v := range violations {
+		if !inherited[v] {
+			introduced = append(introduced, v)
+		}
+	}
+	if len(introduced) > 0 {
+		return fmt.Errorf("%s", strings.Join(introduced, "; "))
+	}
+	return nil
+}
+
+// strategyConfigViolations decodes prompt as a store.StrategyConfig and lists missing
+// required fields and parameters outside strategyBounds
+func strategyConfigViolations(prompt string) ([]string, error) {
+	if !strings.HasPrefix(strings.TrimSpace(prompt), "{") {
+		return nil, fmt.Errorf("not a JSON object")
+	}
+	var cfg store.StrategyConfig
+	if err := json.Unmarshal([]byte(prompt), &cfg); err != nil {
+		return nil, err
+	}
+
+	var violations []string
+	switch cfg.CoinSource.SourceType {
+	case "static", "coinpool", "oi_top", "mixed":
+	case "":
+		violations = append(violations, "coin_source.source_type is missing")
+	default:
+		violations = append(violations, fmt.Sprintf("coin_source.source_type %q is unknown", cfg.CoinSource.SourceType))
+	}
+
+	for _, b := range strategyBounds {
+		if v := b.value(&cfg.RiskControl); v < b.min || v > b.max {
+			violations = append(violations, fmt.Sprintf("%s=%g outside [%g, %g]", b.field, v, b.min, b.max))
+		}
+	}
+	return violations, nil
+}
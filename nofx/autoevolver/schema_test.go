@@ -0,0 +1,73 @@
+package autoevolver
+
+import (
+	"strings"
+	"testing"
+)
+
+const boundedStrategyPrompt = `{"coin_source":{"source_type":"static"},` +
+	`"risk_control":{"max_positions":3,"btc_eth_max_leverage":5,"altcoin_max_leverage":4,"max_margin_usage":0.8,"min_confidence":75}}`
+
+func TestOptimizerRejectsOutOfBoundsConfig(t *testing.T) {
+	outOfBounds := strings.Replace(boundedStrategyPrompt, `"btc_eth_max_leverage":5`, `"btc_eth_max_leverage":20`, 1)
+	client := &scriptedAIClient{responses: []string{optimizationReply(outOfBounds)}}
+
+	result, err := NewOptimizer(client).Optimize(&OptimizationInput{CurrentPrompt: boundedStrategyPrompt})
+	if err != nil {
+		t.Fatalf("Optimize failed: %v", err)
+	}
+	if result.NewPrompt != boundedStrategyPrompt {
+		t.Fatalf("expected out-of-bounds mutation to fall back to the current prompt, got %q", result.NewPrompt)
+	}
+	if !strings.Contains(result.RejectedReason, "btc_eth_max_leverage=20") {
+		t.Fatalf("expected rejection reason to name the leverage, got %q", result.RejectedReason)
+	}
+}
+
+func TestOptimizerRejectsNonStrategyJSON(t *testing.T) {
+	client := &scriptedAIClient{responses: []string{optimizationReply(`{"risk_control":"aggressive"}`)}}
+
+	result, err := NewOptimizer(client).Optimize(&OptimizationInput{CurrentPrompt: boundedStrategyPrompt})
+	if err != nil {
+		t.Fatalf("Optimize failed: %v", err)
+	}
+	if result.NewPrompt != boundedStrategyPrompt || result.RejectedReason == "" {
+		t.Fatalf("expected rejection of a config that does not decode, got %+v", result)
+	}
+}
+
+func TestOptimizerAcceptsBoundedConfig(t *testing.T) {
+	mutated := strings.Replace(boundedStrategyPrompt, `"max_positions":3`, `"max_positions":4`, 1)
+	client := &scriptedAIClient{responses: []string{optimizationReply(mutated)}}
+
+	result, err := NewOptimizer(client).Optimize(&OptimizationInput{CurrentPrompt: boundedStrategyPrompt})
+	if err != nil {
+		t.Fatalf("Optimize failed: %v", err)
+	}
+	if result.NewPrompt != mutated || result.RejectedReason != "" {
+		t.Fatalf("expected bounded mutation to be accepted, got %+v", result)
+	}
+}
+
+func TestValidateStrategyConfigToleratesInheritedViolations(t *testing.T) {
+	// The user's base config already runs at 10x; keeping it is not the optimizer's doing
+	current := strings.Replace(boundedStrategyPrompt, `"altcoin_max_leverage":4`, `"altcoin_max_leverage":10`, 1)
+	mutated := strings.Replace(current, `"min_confidence":75`, `"min_confidence":70`, 1)
+	if err := validateStrategyConfig(mutated, current); err != nil {
+		t.Fatalf("expected inherited violation to be tolerated, got %v", err)
+	}
+
+	// Moving the same parameter further out of bounds is a new violation
+	worse := strings.Replace(current, `"altcoin_max_leverage":10`, `"altcoin_max_leverage":20`, 1)
+	if err := validateStrategyConfig(worse, current); err == nil {
+		t.Fatal("expected a changed out-of-bounds value to be rejected")
+	}
+}
+
+func TestValidateStrategyConfigRequiresSourceType(t *testing.T) {
+	missing := strings.Replace(boundedStrategyPrompt, `"coin_source":{"source_type":"static"},`, ``, 1)
+	err := validateStrategyConfig(missing, boundedStrategyPrompt)
+	if err == nil || !strings.Contains(err.Error(), "coin_source.source_type") {
+		t.Fatalf("expected missing source type to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+package autoevolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"nofx/logger"
+	"nofx/store"
+)
+
+const (
+	defaultInactivityTimeout = 5 * time.Minute // Default BacktestInactivityTimeoutSec
+	defaultMaxStallRestarts  = 2               // Default MaxStallRestarts
+)
+
+// errBacktestStalled is wrapped by waitForBacktestComplete when a backtest stops making progress
+var errBacktestStalled = errors.New("backtest stalled")
+
+// inactivityTimeout returns the configured backtest inactivity timeout
+func inactivityTimeout(cfg *EvolutionConfig) time.Duration {
+	if cfg.BacktestInactivityTimeoutSec <= 0 {
+		return defaultInactivityTimeout
+	}
+	return time.Duration(cfg.BacktestInactivityTimeoutSec) * time.Second
+}
+
+// maxStallRestarts returns how often a stalled backtest may be restarted
+func (e *AutoEvolver) maxStallRestarts() int {
+	switch {
+	case e.config.MaxStallRestarts < 0:
+		return 0
+	case e.config.MaxStallRestarts == 0:
+		return defaultMaxStallRestarts
+	default:
+		return e.config.MaxStallRestarts
+	}
+}
+
+// awaitBacktest waits for the backtest of an iteration and then calls release. A stalled
+// backtest is restarted under the same run ID up to maxStallRestarts times: the iteration is
+// marked stalled while it restarts and keeps the restart count, so a completed iteration with
+// restarts recovered from a stall. Once the restarts are exhausted the iteration stays stalled.
+func (e *AutoEvolver) awaitBacktest(ctx context.Context, strategy *store.Strategy, runID, prompt string, version int, release func()) error {
+	maxRestarts := e.maxStallRestarts()
+	for restarts := 0; ; restarts++ {
+		err := e.waitForBacktestComplete(ctx, runID)
+		release()
+		if !errors.Is(err, errBacktestStalled) {
+			if err == nil && restarts > 0 {
+				logger.Infof("Evolution %s v%d: backtest %s recovered after %d restart(s)", e.evolutionID, version, runID, restarts)
+			}
+			return err
+		}
+
+		e.store.Evolution().UpdateIterationStatus(e.evolutionID, version, IterStatusStalled)
+		if restarts >= maxRestarts {
+			logger.Errorf("Evolution %s v%d: backtest %s stalled, giving up after %d restart(s)", e.evolutionID, version, runID, restarts)
+			return fmt.Errorf("%w after %d restart(s)", err, restarts)
+		}
+
+		logger.Warnf("Evolution %s v%d: backtest %s stalled, restarting (attempt %d/%d)", e.evolutionID, version, runID, restarts+1, maxRestarts)
+		release, err = e.restartBacktest(ctx, strategy, runID, prompt, version)
+		if err != nil {
+			e.store.Evolution().UpdateIterationStatus(e.evolutionID, version, IterStatusFailed)
+			return fmt.Errorf("backtest restart failed: %w", err)
+		}
+		if err := e.store.Evolution().UpdateIterationStallRestarts(e.evolutionID, version, restarts+1); err != nil {
+			logger.Warnf("Failed to record stall restarts for v%d: %v", version, err)
+		}
+		e.store.Evolution().UpdateIterationStatus(e.evolutionID, version, IterStatusBacktest)
+	}
+}
+
+// restartBacktest discards the data of a backtest (stopping its runner) and starts it again
+// under the same run ID. The returned release frees the slot of the new run.
+func (e *AutoEvolver) restartBacktest(ctx context.Context, strategy *store.Strategy, runID, prompt string, version int) (func(), error) {
+	if err := e.backtestMgr.Delete(runID); err != nil {
+		logger.Warnf("Failed to delete old backtest data: %v", err)
+	}
+	cfg, err := e.newBacktestConfig(runID, strategy, prompt, version)
+	if err != nil {
+		return nil, err
+	}
+	return e.startBacktest(ctx, cfg)
+}
@@ -0,0 +1,117 @@
+package autoevolver
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"nofx/backtest"
+)
+
+// stallingBacktestManager stalls the first stalls backtests it starts: they stay running
+// without progress until deleted. Later starts complete like stubBacktestManager's.
+type stallingBacktestManager struct {
+	*stubBacktestManager
+	stalls  int
+	starts  int
+	stalled map[string]bool
+}
+
+func newStallingBacktestManager(stalls int) *stallingBacktestManager {
+	return &stallingBacktestManager{
+		stubBacktestManager: newStubBacktestManager(time.Millisecond, map[string]float64{"base-prompt": 5}),
+		stalls:              stalls,
+		stalled:             make(map[string]bool),
+	}
+}
+
+func (m *stallingBacktestManager) Start(ctx context.Context, cfg backtest.BacktestConfig) (*backtest.Runner, error) {
+	m.mu.Lock()
+	m.starts++
+	if m.stalls > 0 {
+		m.stalls--
+		m.runs[cfg.RunID] = cfg.PromptVariant
+		m.stalled[cfg.RunID] = true
+		m.mu.Unlock()
+		return nil, nil
+	}
+	m.mu.Unlock()
+	return m.stubBacktestManager.Start(ctx, cfg)
+}
+
+func (m *stallingBacktestManager) Status(runID string) *backtest.StatusPayload {
+	m.mu.Lock()
+	stalled := m.stalled[runID]
+	m.mu.Unlock()
+	if stalled {
+		return &backtest.StatusPayload{
+			RunID:          runID,
+			State:          backtest.RunStateRunning,
+			ProgressPct:    10,
+			LastUpdatedIso: time.Now().Add(-time.Hour).Format(time.RFC3339),
+		}
+	}
+	return m.stubBacktestManager.Status(runID)
+}
+
+func (m *stallingBacktestManager) Delete(runID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.runs, runID)
+	delete(m.stalled, runID)
+	return nil
+}
+
+func runStallingEvolution(t *testing.T, mgr *stallingBacktestManager, maxRestarts int) (*AutoEvolver, error) {
+	t.Helper()
+	cfg := &EvolutionConfig{
+		UserID:           "user-1",
+		Name:             "evo",
+		BaseStrategyID:   "base",
+		MaxIterations:    1,
+		MaxStallRestarts: maxRestarts,
+		FixedParams:      FixedParams{AIModelID: "model-1"},
+	}
+	evolver, _ := newTestEvolver(t, cfg, mgr)
+	evolver.inactivityTimeout = 30 * time.Millisecond
+	return evolver, evolver.Start(context.Background())
+}
+
+func TestStalledBacktestRecoversOnRestart(t *testing.T) {
+	mgr := newStallingBacktestManager(1)
+	evolver, err := runStallingEvolution(t, mgr, 0)
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if mgr.starts != 2 {
+		t.Errorf("expected the stalled backtest to be started twice, got %d", mgr.starts)
+	}
+
+	iter, err := evolver.store.Evolution().GetIteration("evo-1", 1)
+	if err != nil {
+		t.Fatalf("GetIteration failed: %v", err)
+	}
+	if iter.Status != IterStatusCompleted || iter.StallRestarts != 1 {
+		t.Errorf("expected a completed iteration with 1 stall restart, got status %q with %d", iter.Status, iter.StallRestarts)
+	}
+}
+
+func TestStalledBacktestExhaustsRestarts(t *testing.T) {
+	mgr := newStallingBacktestManager(10)
+	evolver, err := runStallingEvolution(t, mgr, 2)
+	if !errors.Is(err, errBacktestStalled) {
+		t.Fatalf("expected a stalled error, got %v", err)
+	}
+	if mgr.starts != 3 {
+		t.Errorf("expected 1 start plus 2 restarts, got %d", mgr.starts)
+	}
+
+	iter, err := evolver.store.Evolution().GetIteration("evo-1", 1)
+	if err != nil {
+		t.Fatalf("GetIteration failed: %v", err)
+	}
+	if iter.Status != IterStatusStalled || iter.StallRestarts != 2 {
+		t.Errorf("expected a stalled iteration with 2 stall restarts, got status %q with %d", iter.Status, iter.StallRestarts)
+	}
+}
@@ -32,4 +32,5 @@ const (
 	IterStatusOptimizing = evotypes.IterStatusOptimizing
 	IterStatusCompleted  = evotypes.IterStatusCompleted
 	IterStatusFailed     = evotypes.IterStatusFailed
+	IterStatusStalled    = evotypes.IterStatusStalled
 )
@@ -0,0 +1,130 @@
+package autoevolver
+
+import (
+	"context"
+	"fmt"
+
+	"nofx/backtest"
+	"nofx/logger"
+	"nofx/store"
+)
+
+// defaultValidationTolerance is the out-of-sample degradation (percentage points) still accepted for promotion
+const defaultValidationTolerance = 3.0
+
+// validationSplitTS returns the timestamp separating the in-sample window from the
+// held-out validation window; ok is false when no validation split is configured
+func (e *AutoEvolver) validationSplitTS() (splitTS int64, ok bool) {
+	params := e.config.FixedParams
+	if params.ValidationSplit <= 0 || params.ValidationSplit >= 1 || params.EndTS <= params.StartTS {
+		return 0, false
+	}
+	return params.EndTS - int64(float64(params.EndTS-params.StartTS)*params.ValidationSplit), true
+}
+
+// runValidation backtests prompt on the held-out window and stores the out-of-sample
+// metrics on the iteration. It returns nil metrics when validation is disabled.
+func (e *AutoEvolver) runValidation(ctx context.Context, strategy *store.Strategy, runID, prompt string, version int) (*backtest.Metrics, error) {
+	splitTS, ok := e.validationSplitTS()
+	if !ok {
+		return nil, nil
+	}
+
+	validationRunID := runID + "-oos"
+	backtestConfig, err := e.newBacktestConfig(validationRunID, strategy, prompt, version)
+	if err != nil {
+		return nil, err
+	}
+	backtestConfig.StartTS = splitTS
+	backtestConfig.EndTS = e.config.FixedParams.EndTS
+
+	// Clear leftovers from an interrupted validation run
+	if meta, err := e.backtestMgr.LoadMetadata(validationRunID); err == nil && meta != nil {
+		if err := e.backtestMgr.Delete(validationRunID); err != nil {
+			logger.Warnf("Failed to delete old validation backtest data: %v", err)
+		}
+	}
+
+	logger.Infof("Evolution %s v%d: starting out-of-sample validation backtest %s", e.evolutionID, version, validationRunID)
+	releaseSlot, err := e.startBacktest(ctx, backtestConfig)
+	if err != nil {
+		return nil, fmt.Errorf("validation backtest start failed: %w", err)
+	}
+	err = e.waitForBacktestComplete(ctx, validationRunID)
+	releaseSlot()
+	if err != nil {
+		return nil, fmt.Errorf("validation backtest wait failed: %w", err)
+	}
+	metrics, err := e.backtestMgr.GetMetrics(validationRunID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get validation metrics: %w", err)
+	}
+
+	logger.Infof("Evolution %s v%d: out-of-sample return=%.2f%%, drawdown=%.2f%%",
+		e.evolutionID, version, metrics.TotalReturnPct, metrics.MaxDrawdownPct)
+
+	if err := e.store.Evolution().UpdateIterationValidationMetrics(e.evolutionID, version, newIterationMetrics(metrics)); err != nil {
+		logger.Warnf("Failed to store validation metrics for v%d: %v", version, err)
+	}
+	return metrics, nil
+}
+
+// validationMetrics returns the out-of-sample metrics of version, reusing the ones on
+// record (e.g. for an earlier version promoted from the Pareto frontier) before running
+// a new validation backtest
+func (e *AutoEvolver) validationMetrics(ctx context.Context, strategy *store.Strategy, runID, prompt string, version int) (*backtest.Metrics, error) {
+	if _, ok := e.validationSplitTS(); !ok {
+		return nil, nil
+	}
+	if iter, err := e.store.Evolution().GetIteration(e.evolutionID, version); err == nil && iter != nil && iter.ValidationMetrics != nil {
+		return &backtest.Metrics{
+			TotalReturnPct: iter.ValidationMetrics.TotalReturn,
+			MaxDrawdownPct: iter.ValidationMetrics.MaxDrawdown,
+		}, nil
+	}
+	return e.runValidation(ctx, strategy, runID, prompt, version)
+}
+
+// passesValidation reports whether out-of-sample metrics are good enough to promote a
+// version to best: neither return nor drawdown may degrade by more than the tolerance
+// versus the current best version's out-of-sample metrics
+func (e *AutoEvolver) passesValidation(metrics *backtest.Metrics) (bool, string) {
+	bestIter := e.getBestIteration()
+	if bestIter == nil || bestIter.ValidationMetrics == nil {
+		return true, ""
+	}
+
+	tolerance := e.config.FixedParams.ValidationTolerance
+	if tolerance <= 0 {
+		tolerance = defaultValidationTolerance
+	}
+	best := bestIter.ValidationMetrics
+	if metrics.TotalReturnPct < best.TotalReturn-tolerance {
+		return false, fmt.Sprintf("out-of-sample return %.2f%% vs best %.2f%%", metrics.TotalReturnPct, best.TotalReturn)
+	}
+	if metrics.MaxDrawdownPct > best.MaxDrawdown+tolerance {
+		return false, fmt.Sprintf("out-of-sample drawdown %.2f%% vs best %.2f%%", metrics.MaxDrawdownPct, best.MaxDrawdown)
+	}
+	return true, ""
+}
+
+// validateForPromotion runs the out-of-sample backtest for a version and reports whether
+// it may be promoted to best, and if not, why. It runs for every completed iteration, not
+// only in-sample improvements, so each version's out-of-sample metrics are on record (an
+// earlier Pareto frontier member can be promoted later); callers only act on a rejection
+// of a version they would otherwise promote.
+func (e *AutoEvolver) validateForPromotion(ctx context.Context, strategy *store.Strategy, runID, prompt string, version int) (bool, string) {
+	metrics, err := e.validationMetrics(ctx, strategy, runID, prompt, version)
+	if err != nil {
+		logger.Warnf("Evolution %s v%d: out-of-sample validation failed, not promoting: %v", e.evolutionID, version, err)
+		return false, fmt.Sprintf("out-of-sample validation failed: %v", err)
+	}
+	if metrics == nil {
+		return true, ""
+	}
+	if ok, reason := e.passesValidation(metrics); !ok {
+		logger.Infof("Evolution %s v%d: not eligible as best out-of-sample: %s", e.evolutionID, version, reason)
+		return false, "rejected by validation: " + reason
+	}
+	return true, ""
+}
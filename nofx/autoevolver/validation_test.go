@@ -0,0 +1,78 @@
+package autoevolver
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidationRejectsOutOfSampleRegression(t *testing.T) {
+	mgr := newStubBacktestManager(time.Millisecond, map[string]float64{
+		"base-prompt":     5,
+		mutationPrompt(1): 10,
+	})
+	mgr.validationReturns["base-prompt"] = 4
+	mgr.validationReturns[mutationPrompt(1)] = -6
+
+	cfg := &EvolutionConfig{
+		UserID:         "user-1",
+		Name:           "evo",
+		BaseStrategyID: "base",
+		MaxIterations:  2,
+		FixedParams: FixedParams{
+			AIModelID:       "model-1",
+			StartTS:         1000,
+			EndTS:           2000,
+			ValidationSplit: 0.25,
+		},
+	}
+	evolver, st := newTestEvolver(t, cfg, mgr)
+
+	if err := evolver.Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	for runID, backtestConfig := range mgr.configs {
+		if strings.HasSuffix(runID, "-oos") {
+			if backtestConfig.StartTS != 1750 || backtestConfig.EndTS != 2000 {
+				t.Errorf("%s: expected out-of-sample window 1750-2000, got %d-%d", runID, backtestConfig.StartTS, backtestConfig.EndTS)
+			}
+		} else if backtestConfig.StartTS != 1000 || backtestConfig.EndTS != 1750 {
+			t.Errorf("%s: expected in-sample window 1000-1750, got %d-%d", runID, backtestConfig.StartTS, backtestConfig.EndTS)
+		}
+	}
+
+	evolution, err := st.Evolution().Get("user-1", "evo-1")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if evolution.BestVersion != 1 {
+		t.Errorf("expected v1 to remain best after v2 regressed out-of-sample, got v%d", evolution.BestVersion)
+	}
+
+	iter, err := st.Evolution().GetIteration("evo-1", 2)
+	if err != nil {
+		t.Fatalf("GetIteration failed: %v", err)
+	}
+	if iter.Metrics == nil || iter.Metrics.TotalReturn != 10 {
+		t.Errorf("expected in-sample return 10, got %+v", iter.Metrics)
+	}
+	if iter.ValidationMetrics == nil || iter.ValidationMetrics.TotalReturn != -6 {
+		t.Errorf("expected out-of-sample return -6, got %+v", iter.ValidationMetrics)
+	}
+	if !strings.HasPrefix(iter.FailureReason, "rejected by validation: out-of-sample return") {
+		t.Errorf("expected validation rejection as failure reason, got %q", iter.FailureReason)
+	}
+}
+
+func TestValidationSplitDisabledByDefault(t *testing.T) {
+	evolver := &AutoEvolver{config: &EvolutionConfig{FixedParams: FixedParams{StartTS: 1000, EndTS: 2000}}}
+	if _, ok := evolver.validationSplitTS(); ok {
+		t.Error("validation should be disabled without a split")
+	}
+	evolver.config.FixedParams.ValidationSplit = 1
+	if _, ok := evolver.validationSplitTS(); ok {
+		t.Error("a split of 1 leaves no in-sample window and should be ignored")
+	}
+}
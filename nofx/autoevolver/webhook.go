@@ -0,0 +1,153 @@
+package autoevolver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"nofx/evotypes"
+	"nofx/logger"
+)
+
+// Webhook event types
+const (
+	WebhookEventIterationCompleted = "iteration_completed"
+	WebhookEventNewBest            = "new_best"
+	WebhookEventCompleted          = "evolution_completed"
+	WebhookEventStopped            = "evolution_stopped"
+	WebhookEventFailed             = "evolution_failed"
+)
+
+const (
+	webhookTimeout    = 5 * time.Second // Per delivery attempt
+	webhookRetries    = 2               // Extra attempts after a failed delivery
+	webhookRetryDelay = time.Second
+)
+
+// WebhookEvent is the JSON payload posted to EvolutionConfig.WebhookURL
+type WebhookEvent struct {
+	Event       string            `json:"event"`
+	EvolutionID string            `json:"evolution_id"`
+	Name        string            `json:"name"`
+	Version     int               `json:"version,omitempty"`
+	Metrics     *evotypes.Metrics `json:"metrics,omitempty"`
+	Summary     string            `json:"summary"`
+	Timestamp   int64             `json:"timestamp"` // Unix milliseconds
+}
+
+// webhookNotifier delivers evolution events best-effort. Each event is sent from its own
+// goroutine, so a slow or unreachable endpoint never blocks the evolution loop and events
+// may arrive out of order.
+type webhookNotifier struct {
+	url        string
+	client     *http.Client
+	retries    int
+	retryDelay time.Duration
+	wg         sync.WaitGroup
+}
+
+// newWebhookNotifier returns nil when url is empty, which disables notifications. A URL
+// that is not http(s) (e.g. from a config stored before it was validated) is refused too.
+func newWebhookNotifier(url string) *webhookNotifier {
+	if url == "" {
+		return nil
+	}
+	if err := evotypes.ValidateWebhookURL(url); err != nil {
+		logger.Warnf("Evolution webhook disabled: %v", err)
+		return nil
+	}
+	return &webhookNotifier{
+		url:        url,
+		client:     &http.Client{Timeout: webhookTimeout},
+		retries:    webhookRetries,
+		retryDelay: webhookRetryDelay,
+	}
+}
+
+// send queues the event for delivery and returns immediately
+func (n *webhookNotifier) send(event WebhookEvent) {
+	if n == nil {
+		return
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		logger.Warnf("Evolution %s: failed to encode %s webhook: %v", event.EvolutionID, event.Event, err)
+		return
+	}
+
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		var err error
+		for attempt := 0; attempt <= n.retries; attempt++ {
+			if attempt > 0 {
+				time.Sleep(n.retryDelay)
+			}
+			if err = n.post(body); err == nil {
+				return
+			}
+		}
+		logger.Warnf("Evolution %s: %s webhook not delivered after %d attempts: %v",
+			event.EvolutionID, event.Event, n.retries+1, err)
+	}()
+}
+
+// post makes a single delivery attempt; any non-2xx response is a failure
+func (n *webhookNotifier) post(body []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// wait blocks until every queued event was delivered or given up on
+func (n *webhookNotifier) wait() {
+	if n != nil {
+		n.wg.Wait()
+	}
+}
+
+// notify posts a lifecycle event for this evolution; it is a no-op without a webhook URL
+func (e *AutoEvolver) notify(event string, version int, metrics *evotypes.Metrics, summary string) {
+	e.webhook.send(WebhookEvent{
+		Event:       event,
+		EvolutionID: e.evolutionID,
+		Name:        e.config.Name,
+		Version:     version,
+		Metrics:     metrics,
+		Summary:     summary,
+		Timestamp:   time.Now().UnixMilli(),
+	})
+}
+
+// notifyIterationCompleted posts the metrics of a finished iteration and whether it was promoted
+func (e *AutoEvolver) notifyIterationCompleted(version int, metrics *evotypes.Metrics, improved bool, reason string) {
+	outcome := "new best"
+	if !improved {
+		outcome = "not promoted"
+	}
+	if reason != "" {
+		outcome += ": " + reason
+	}
+	e.notify(WebhookEventIterationCompleted, version, metrics, fmt.Sprintf(
+		"Evolution %s v%d completed: return %.2f%%, drawdown %.2f%%, %d trades (%s)",
+		e.config.Name, version, metrics.TotalReturn, metrics.MaxDrawdown, metrics.Trades, outcome))
+}
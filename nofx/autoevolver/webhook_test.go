@@ -0,0 +1,143 @@
+package autoevolver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// webhookRecorder is an HTTP endpoint recording the webhook events posted to it; the first
+// failFirst requests are answered with a server error to exercise retries
+type webhookRecorder struct {
+	mu        sync.Mutex
+	requests  int
+	failFirst int
+	events    []WebhookEvent
+}
+
+func (r *webhookRecorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests++
+	if r.requests <= r.failFirst {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	var event WebhookEvent
+	if req.Method != http.MethodPost || json.NewDecoder(req.Body).Decode(&event) != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	r.events = append(r.events, event)
+}
+
+// find returns the recorded event of the given type and version
+func (r *webhookRecorder) find(event string, version int) *WebhookEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.events {
+		if r.events[i].Event == event && r.events[i].Version == version {
+			return &r.events[i]
+		}
+	}
+	return nil
+}
+
+func TestWebhookNotifiesLifecycleEvents(t *testing.T) {
+	recorder := &webhookRecorder{failFirst: 1}
+	server := httptest.NewServer(recorder)
+	defer server.Close()
+
+	mgr := newStubBacktestManager(time.Millisecond, map[string]float64{
+		"base-prompt":     5,
+		mutationPrompt(1): 10,
+	})
+	cfg := &EvolutionConfig{
+		UserID:         "user-1",
+		Name:           "evo",
+		BaseStrategyID: "base",
+		MaxIterations:  2,
+		FixedParams:    FixedParams{AIModelID: "model-1"},
+		WebhookURL:     server.URL,
+	}
+	evolver, _ := newTestEvolver(t, cfg, mgr)
+	evolver.webhook.retryDelay = time.Millisecond
+
+	if err := evolver.Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	evolver.webhook.wait()
+
+	if len(recorder.events) != 5 {
+		t.Fatalf("expected 5 delivered events, got %d: %+v", len(recorder.events), recorder.events)
+	}
+	if recorder.requests != 6 {
+		t.Errorf("expected the failed delivery to be retried once, got %d requests", recorder.requests)
+	}
+	for _, event := range recorder.events {
+		if event.EvolutionID != "evo-1" || event.Name != "evo" || event.Summary == "" || event.Timestamp == 0 {
+			t.Errorf("incomplete event payload: %+v", event)
+		}
+	}
+
+	for version, wantReturn := range map[int]float64{1: 5, 2: 10} {
+		for _, kind := range []string{WebhookEventIterationCompleted, WebhookEventNewBest} {
+			event := recorder.find(kind, version)
+			if event == nil {
+				t.Fatalf("missing %s event for v%d", kind, version)
+			}
+			if event.Metrics == nil || event.Metrics.TotalReturn != wantReturn {
+				t.Errorf("%s v%d metrics = %+v, expected return %v", kind, version, event.Metrics, wantReturn)
+			}
+		}
+	}
+	if event := recorder.find(WebhookEventNewBest, 2); !strings.Contains(event.Summary, "higher return") {
+		t.Errorf("new best summary should carry the improvement reason, got %q", event.Summary)
+	}
+	if recorder.find(WebhookEventCompleted, 2) == nil {
+		t.Error("missing evolution_completed event")
+	}
+}
+
+func TestWebhookNotifiesStop(t *testing.T) {
+	recorder := &webhookRecorder{}
+	server := httptest.NewServer(recorder)
+	defer server.Close()
+
+	cfg := &EvolutionConfig{
+		UserID:         "user-1",
+		Name:           "evo",
+		BaseStrategyID: "base",
+		MaxIterations:  2,
+		FixedParams:    FixedParams{AIModelID: "model-1"},
+		WebhookURL:     server.URL,
+	}
+	evolver, _ := newTestEvolver(t, cfg, newStubBacktestManager(time.Millisecond, nil))
+	evolver.Stop()
+
+	if err := evolver.Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	evolver.webhook.wait()
+
+	if len(recorder.events) != 1 || recorder.events[0].Event != WebhookEventStopped {
+		t.Fatalf("expected a single evolution_stopped event, got %+v", recorder.events)
+	}
+}
+
+func TestWebhookDisabledWithoutURL(t *testing.T) {
+	if newWebhookNotifier("") != nil {
+		t.Fatal("expected no notifier without a webhook URL")
+	}
+	if newWebhookNotifier("file:///etc/passwd") != nil {
+		t.Fatal("expected no notifier for a non-http webhook URL")
+	}
+	var notifier *webhookNotifier
+	notifier.send(WebhookEvent{Event: WebhookEventCompleted})
+	notifier.wait()
+}
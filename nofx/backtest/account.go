@@ -151,6 +151,26 @@ func (acc *BacktestAccount) Close(symbol, side string, quantity float64, price f
 	return realized, totalFee, execPrice, nil
 }
 
+// ClosePercent closes a fraction (0-1] of the position. Fractions <= 0 or >= 1 close the whole position.
+// Returns the closed quantity along with the same values as Close.
+func (acc *BacktestAccount) ClosePercent(symbol, side string, fraction float64, price float64) (float64, float64, float64, float64, error) {
+	pos, ok := acc.positions[positionKey(symbol, side)]
+	if !ok || pos.Quantity <= epsilon {
+		return 0, 0, 0, 0, fmt.Errorf("no active %s position for %s", side, symbol)
+	}
+
+	quantity := pos.Quantity
+	if fraction > 0 && fraction < 1 {
+		quantity = pos.Quantity * fraction
+	}
+
+	realized, fee, execPrice, err := acc.Close(symbol, side, quantity, price)
+	if err != nil {
+		return 0, 0, 0, 0, err
+	}
+	return quantity, realized, fee, execPrice, nil
+}
+
 func (acc *BacktestAccount) TotalEquity(priceMap map[string]float64) (float64, float64, map[string]float64) {
 	unrealized := 0.0
 	margin := 0.0
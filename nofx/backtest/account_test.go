@@ -0,0 +1,36 @@
+package backtest
+
+import (
+	"math"
+	"testing"
+)
+
+func TestBacktestAccountClosePercent(t *testing.T) {
+	acc := NewBacktestAccount(10000, 0, 0)
+	if _, _, _, err := acc.Open("BTCUSDT", "long", 2, 5, 100, 0); err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+
+	closed, realized, _, _, err := acc.ClosePercent("BTCUSDT", "long", 0.5, 110)
+	if err != nil {
+		t.Fatalf("ClosePercent() error: %v", err)
+	}
+	if math.Abs(closed-1) > 1e-9 {
+		t.Errorf("closed quantity = %.4f, expected 1", closed)
+	}
+	if math.Abs(realized-10) > 1e-9 {
+		t.Errorf("realized = %.4f, expected 10", realized)
+	}
+	if positions := acc.Positions(); len(positions) != 1 || math.Abs(positions[0].Quantity-1) > 1e-9 {
+		t.Fatalf("expected 1 remaining unit, got %+v", positions)
+	}
+
+	// A zero fraction closes whatever is left
+	closed, _, _, _, err = acc.ClosePercent("BTCUSDT", "long", 0, 110)
+	if err != nil {
+		t.Fatalf("ClosePercent() error: %v", err)
+	}
+	if math.Abs(closed-1) > 1e-9 || len(acc.Positions()) != 0 {
+		t.Errorf("expected full close of remaining position, closed=%.4f positions=%d", closed, len(acc.Positions()))
+	}
+}
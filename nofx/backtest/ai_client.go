@@ -7,6 +7,12 @@ import (
 	"nofx/mcp"
 )
 
+// NewAIClient creates the MCP client of an AI configuration outside a backtest run, using the
+// same provider mapping as the runner
+func NewAIClient(ai AIConfig) (mcp.AIClient, error) {
+	return configureMCPClient(BacktestConfig{AICfg: ai}, mcp.NewClient())
+}
+
 // configureMCPClient creates/clones an MCP client based on configuration (returns mcp.AIClient interface).
 // Note: mcp.New() returns an interface type; here we convert to concrete implementation before copying to avoid concurrent shared state.
 func configureMCPClient(cfg BacktestConfig, base mcp.AIClient) (mcp.AIClient, error) {
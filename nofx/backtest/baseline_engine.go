@@ -1,10 +1,15 @@
 package backtest
 
 import (
+	"fmt"
+	"math"
 	"nofx/decision"
 	"nofx/logger"
 	"nofx/market"
 	"nofx/store"
+	"sort"
+	"strings"
+	"time"
 )
 
 // BaselineEngine 传统指标决策引擎（确定性）
@@ -12,6 +17,17 @@ import (
 type BaselineEngine struct {
 	config         *store.StrategyConfig
 	positionStates map[string]*BaselinePositionState // 持仓状态跟踪
+	cycle          int                               // 决策周期计数（每次 MakeDecision 加 1）
+	lastExitCycle  map[string]int                    // 每个币种最近一次平仓的周期（用于冷却期）
+	lastExitSide   map[string]string                 // 每个币种最近一次平仓的方向
+	correlations   map[string]map[string]float64     // 本周期各币种收益率的相关系数矩阵（启用相关性过滤时）
+	feeBps         float64                           // 单边手续费（基点），用于开仓最小预期收益检查
+	slippageBps    float64                           // 单边滑点（基点）
+	peakEquity     float64                           // 历史最高权益（跨周期保留，用于账户回撤熔断）
+	riskHalted     bool                              // 账户回撤熔断中：暂停开仓直到回撤恢复
+	btcEthLeverage int                               // 回测固定参数的主流币杠杆（0 表示未设置）
+	altLeverage    int                               // 回测固定参数的山寨币杠杆（0 表示未设置）
+	entryCadence   int                               // 覆盖配置的开仓评估间隔（0 表示使用 BaselineConfig.EntryCadenceBars）
 }
 
 // BaselinePositionState 持仓状态跟踪（用于移动止盈止损）
@@ -24,12 +40,18 @@ type BaselinePositionState struct {
 	TrailingTP    float64 // 当前移动止盈位
 	HardStopPrice float64 // 挂单硬止损价（开仓时设置，基于OHLC检查）
 	EntryCycle    int     // 开仓时的周期数（用于最小持仓周期检查）
+	EntryBarTime  int64   // 开仓时信号周期最新 K 线的时间（毫秒，用于判断 SAR 是否在开仓后翻转）
+	ScaleOutStage int     // 已完成的分批止盈阶段（0=未分批）
+	BreakevenSet  bool    // 是否已将止损移至保本位
+	PyramidAdds   int     // 已完成的金字塔加仓次数
+	LastAddPrice  float64 // 最近一次加仓价格（0=未加仓，以 EntryPrice 为准）
 }
 
 // ScoredDecision 带评分的决策（用于筛选最优开仓决策）
 type ScoredDecision struct {
 	Decision decision.Decision
-	Score    float64 // 综合评分（0-100）
+	Score    float64                // 综合评分（0-100）
+	state    *BaselinePositionState // 选中后由 commitEntry 写入的持仓状态
 }
 
 // NewBaselineEngine 创建传统指标引擎
@@ -37,18 +59,43 @@ func NewBaselineEngine(config *store.StrategyConfig) *BaselineEngine {
 	return &BaselineEngine{
 		config:         config,
 		positionStates: make(map[string]*BaselinePositionState),
+		lastExitCycle:  make(map[string]int),
+		lastExitSide:   make(map[string]string),
 	}
 }
 
+// SetTradingCosts 设置单边手续费和滑点（基点），开仓前要求预期波动足以覆盖往返成本
+func (e *BaselineEngine) SetTradingCosts(feeBps, slippageBps float64) {
+	e.feeBps = feeBps
+	e.slippageBps = slippageBps
+}
+
+// SetEntryCadenceBars 覆盖配置的开仓评估间隔（bar 数），0 表示恢复使用 BaselineConfig.EntryCadenceBars
+// 回测只在决策点调用 MakeDecision，已按 DecisionCadenceNBars 控制节奏，因此设为 1 避免重复限制开仓
+func (e *BaselineEngine) SetEntryCadenceBars(n int) {
+	e.entryCadence = n
+}
+
+// SetBacktestLeverage 设置回测固定参数中的分级杠杆，使 Baseline 与 AI 回测按相同杠杆开仓
+// 设置后优先于 RiskManagement 中的杠杆配置（不要求启用 EnableLeverageTiers）
+func (e *BaselineEngine) SetBacktestLeverage(btcEthLeverage, altcoinLeverage int) {
+	e.btcEthLeverage = btcEthLeverage
+	e.altLeverage = altcoinLeverage
+}
+
 // MakeDecision 基于技术指标生成确定性决策
-// 输入相同的市场数据，输出相同的决策（确定性）
+// 输入相同的市场数据，输出相同的决策（确定性）：在引擎状态相同的前提下，
+// 结果只取决于输入参数；币种按字母序遍历，候选决策按（评分降序，币种升序）排序，不受 map 遍历顺序影响
+// ts 为当前 bar 的时间（毫秒），用于交易时段过滤
 func (e *BaselineEngine) MakeDecision(
+	ts int64,
 	equity float64,
 	available float64,
 	marketData map[string]*market.Data,
 	positions []decision.PositionInfo,
 ) []decision.Decision {
 	finalDecisions := make([]decision.Decision, 0)
+	e.cycle++
 
 	// 1. 更新持仓状态（峰值价格）
 	for _, pos := range positions {
@@ -63,18 +110,62 @@ func (e *BaselineEngine) MakeDecision(
 		if data, ok := marketData[pos.Symbol]; ok {
 			if closeDecision := e.checkExitSignal(pos, data); closeDecision != nil {
 				closeDecisions = append(closeDecisions, *closeDecision)
-				// 清除持仓状态
-				delete(e.positionStates, pos.Symbol+"_"+pos.Side)
+				// 全部平仓时清除持仓状态（分批止盈保留剩余仓位的状态）
+				if closeDecision.CloseFraction == 0 {
+					delete(e.positionStates, pos.Symbol+"_"+pos.Side)
+					e.recordExit(pos.Symbol, pos.Side)
+				}
 			}
 		}
 	}
 	finalDecisions = append(finalDecisions, closeDecisions...)
 
+	// 账户回撤熔断：回撤超限时暂停开仓（平仓不受影响），并输出风控暂停状态
+	if halt := e.checkDrawdownHalt(equity); halt != nil {
+		finalDecisions = append(finalDecisions, *halt)
+		return finalDecisions
+	}
+
 	// 3. 生成所有候选开仓决策（不限制数量）
-	if available > 100 { // 至少 100 USDT 才考虑开仓
+	// 交易时段之外、非开仓节奏的 bar 只管理持仓，不开新仓
+	if available > 100 && e.inTradingHours(ts) && e.onEntryCadence() { // 至少 100 USDT 才考虑开仓
 		candidateDecisions := make([]ScoredDecision, 0)
 
-		for symbol, data := range marketData {
+		// 按币种排序遍历，保证同方向仓位限制等状态检查的顺序确定
+		symbols := make([]string, 0, len(marketData))
+		for symbol := range marketData {
+			symbols = append(symbols, symbol)
+		}
+		sort.Strings(symbols)
+
+		// 相关性过滤：计算本周期的收益率相关系数矩阵
+		e.correlations = nil
+		if cfg := e.config.BaselineConfig; cfg != nil && cfg.RiskManagement.MaxCorrelatedPositions > 0 {
+			window := cfg.RiskManagement.CorrelationWindow
+			if window <= 0 {
+				window = 50 // 默认值
+			}
+			e.correlations = e.buildCorrelationMatrix(marketData, cfg.SignalTimeframe, window)
+		}
+
+		// 金字塔加仓：盈利持仓的同方向信号再次触发时加仓（本周期有平仓或分批止盈的币种、不在交易范围内的币种除外）
+		closing := make(map[string]bool, len(closeDecisions))
+		for _, dec := range closeDecisions {
+			closing[dec.Symbol] = true
+		}
+		pyramidAdds := make(map[string]*pyramidAdd)
+		addDecisions := make([]decision.Decision, 0)
+		for _, pos := range positions {
+			if data, ok := marketData[pos.Symbol]; ok && !closing[pos.Symbol] && e.symbolAllowed(pos.Symbol) {
+				if add := e.checkPyramidAdd(pos, data, available); add != nil {
+					pyramidAdds[pos.Symbol] = add
+					addDecisions = append(addDecisions, add.decision)
+				}
+			}
+		}
+
+		for _, symbol := range symbols {
+			data := marketData[symbol]
 			if !e.hasPosition(positions, symbol) {
 				// 生成候选决策并计算评分
 				if scoredDec := e.generateScoredDecision(symbol, data, equity, available); scoredDec != nil {
@@ -85,7 +176,17 @@ func (e *BaselineEngine) MakeDecision(
 
 		// 4. 根据评分筛选最优的开仓决策
 		selectedDecisions := e.selectBestDecisions(candidateDecisions, len(positions))
-		finalDecisions = append(finalDecisions, selectedDecisions...)
+
+		// 5. 总保证金占用上限：超出 equity × MaxMarginUsage 的开仓被缩减或取消（加仓优先占用额度）
+		entryDecisions := e.applyMarginCap(append(addDecisions, selectedDecisions...), equity, positions)
+
+		// 加仓决策通过保证金检查后才更新持仓状态（均价和止损按实际加仓金额计算）
+		for i, dec := range entryDecisions {
+			if add, ok := pyramidAdds[dec.Symbol]; ok {
+				entryDecisions[i].StopLoss = e.commitPyramidAdd(add, dec.PositionSizeUSD)
+			}
+		}
+		finalDecisions = append(finalDecisions, entryDecisions...)
 	}
 
 	return finalDecisions
@@ -154,7 +255,7 @@ func (e *BaselineEngine) checkEntrySignal(
 
 	// Stoch RSI 信号
 	if indicators.EnableStochRSI {
-		k, d := e.getStochRSI(data)
+		k, d := e.getStochRSIForTF(data, e.signalTimeframe())
 		if k > 0 && d > 0 {
 			if k < 15 && k > d { // 超卖区金叉（优化：从 20 收紧到 15）
 				longSignals++
@@ -177,10 +278,7 @@ func (e *BaselineEngine) checkEntrySignal(
 	}
 
 	// 计算仓位大小（基于可用资金和最大持仓数）
-	leverage := baselineCfg.RiskManagement.Leverage
-	if leverage <= 0 {
-		leverage = 5 // 默认值
-	}
+	leverage := e.resolveLeverage(symbol)
 
 	// 计算单个仓位大小 = 可用资金 / 最大持仓数
 	// 这样可以确保有足够的资金开仓
@@ -200,7 +298,7 @@ func (e *BaselineEngine) checkEntrySignal(
 		hardStopLossPct = 3.0 // 默认 -3.0%
 	}
 
-	// 注意：总仓位限制（本金的 90%）在回测环境中由 runner 层面处理
+	// 注意：总保证金占用上限（MaxMarginUsage）由 MakeDecision 中的 applyMarginCap 统一处理
 
 	if longSignals >= minSignals {
 		// 🔧 BUG FIX: 检查是否已有相同方向的持仓状态
@@ -294,10 +392,12 @@ func (e *BaselineEngine) checkExitSignal(pos decision.PositionInfo, data *market
 	if !hasState {
 		// 如果没有状态记录,创建一个（兼容旧数据）
 		state = &BaselinePositionState{
-			Symbol:     pos.Symbol,
-			Side:       pos.Side,
-			EntryPrice: pos.EntryPrice,
-			PeakPrice:  currentPrice,
+			Symbol:       pos.Symbol,
+			Side:         pos.Side,
+			EntryPrice:   pos.EntryPrice,
+			PeakPrice:    currentPrice,
+			EntryCycle:   e.cycle,
+			EntryBarTime: e.signalBarTime(data),
 		}
 		if pos.Side == "long" {
 			state.TrailingStop = pos.EntryPrice * (1 - hardStopLossPct/100)
@@ -312,11 +412,28 @@ func (e *BaselineEngine) checkExitSignal(pos decision.PositionInfo, data *market
 		action = "close_short"
 	}
 
+	var exit *decision.Decision
 	if pos.Side == "long" {
-		return e.checkLongExit(pos, currentPrice, pnlPct, state, action, data, baselineCfg)
+		exit = e.checkLongExit(pos, currentPrice, pnlPct, state, action, data, baselineCfg)
 	} else {
-		return e.checkShortExit(pos, currentPrice, pnlPct, state, action, data, baselineCfg)
+		exit = e.checkShortExit(pos, currentPrice, pnlPct, state, action, data, baselineCfg)
+	}
+	if exit != nil {
+		return exit
+	}
+
+	// 最大持仓周期：超过持仓时限强制平仓，释放资金
+	maxHoldingBars := baselineCfg.RiskManagement.MaxHoldingBars
+	if maxHoldingBars > 0 && e.cycle > state.EntryCycle+maxHoldingBars {
+		return &decision.Decision{
+			Symbol:     pos.Symbol,
+			Action:     action,
+			Reasoning:  fmt.Sprintf("Baseline: Max holding period exceeded (%d bars)", maxHoldingBars),
+			ExitReason: decision.ExitReasonTimeExit,
+		}
 	}
+
+	return nil
 }
 
 // checkLongExit 检查多头出场信号（按优先级）
@@ -334,11 +451,16 @@ func (e *BaselineEngine) checkLongExit(
 	if hardStopLossPct <= 0 {
 		hardStopLossPct = 3.0 // 默认 -3.0%
 	}
-	if currentPrice <= state.EntryPrice*(1-hardStopLossPct/100) {
+	hardStopPrice := state.EntryPrice * (1 - hardStopLossPct/100)
+	if cfg.RiskManagement.EnableATRStop && state.HardStopPrice > 0 {
+		hardStopPrice = state.HardStopPrice // ATR 动态止损
+	}
+	if currentPrice <= hardStopPrice {
 		return &decision.Decision{
-			Symbol:    pos.Symbol,
-			Action:    action,
-			Reasoning: "Baseline: Hard stop loss (CRITICAL)",
+			Symbol:     pos.Symbol,
+			Action:     action,
+			Reasoning:  "Baseline: Hard stop loss (CRITICAL)",
+			ExitReason: decision.ExitReasonHardStop,
 		}
 	}
 
@@ -346,38 +468,62 @@ func (e *BaselineEngine) checkLongExit(
 	rsi7 := data.CurrentRSI7
 	if e.config.Indicators.EnableRSI && rsi7 > 70 {
 		return &decision.Decision{
-			Symbol:    pos.Symbol,
-			Action:    action,
-			Reasoning: "Baseline: RSI overbought exit (>70)",
+			Symbol:     pos.Symbol,
+			Action:     action,
+			Reasoning:  "Baseline: RSI overbought exit (>70)",
+			ExitReason: decision.ExitReasonRSIExit,
 		}
 	}
 
-	k, d := e.getStochRSI(data)
+	k, d := e.getStochRSIForTF(data, cfg.SignalTimeframe)
 	// StochRSI 出场：要求 K 值在超买区（>=70）且死叉
 	// 优化：提高触发门槛，减少频繁出场
 	if e.config.Indicators.EnableStochRSI && k >= 70 && k < d {
 		return &decision.Decision{
-			Symbol:    pos.Symbol,
-			Action:    action,
-			Reasoning: "Baseline: StochRSI death cross exit",
+			Symbol:     pos.Symbol,
+			Action:     action,
+			Reasoning:  "Baseline: StochRSI death cross exit",
+			ExitReason: decision.ExitReasonStochCross,
 		}
 	}
 
-	// 3. 移动止盈
+	// 3. 固定止盈目标（在分批止盈和移动止盈之前，达到目标直接平掉剩余仓位）
+	if tp := e.checkFixedTakeProfit(pos, pnlPct, action, cfg); tp != nil {
+		return tp
+	}
+
+	// 4. 分批止盈
+	if scaleOut := e.checkScaleOut(pos, pnlPct, state, action, cfg); scaleOut != nil {
+		return scaleOut
+	}
+
+	// 5. 移动止盈
 	if state.TrailingTP > 0 && currentPrice <= state.TrailingTP {
 		return &decision.Decision{
-			Symbol:    pos.Symbol,
-			Action:    action,
-			Reasoning: "Baseline: Trailing take profit triggered",
+			Symbol:     pos.Symbol,
+			Action:     action,
+			Reasoning:  "Baseline: Trailing take profit triggered",
+			ExitReason: decision.ExitReasonTrailingTP,
+		}
+	}
+
+	// 6. 移动止损（保本止损生效后不再要求盈利门槛）
+	if (pnlPct >= 3.0 || state.BreakevenSet) && currentPrice <= state.TrailingStop {
+		return &decision.Decision{
+			Symbol:     pos.Symbol,
+			Action:     action,
+			Reasoning:  "Baseline: Trailing stop loss triggered",
+			ExitReason: decision.ExitReasonTrailingStop,
 		}
 	}
 
-	// 4. 移动止损
-	if pnlPct >= 3.0 && currentPrice <= state.TrailingStop {
+	// 7. 抛物线 SAR 反转：价格穿越 SAR，趋势翻转到持仓的反方向
+	if e.config.Indicators.EnablePSAR && e.psarReversed(data, cfg, state) {
 		return &decision.Decision{
-			Symbol:    pos.Symbol,
-			Action:    action,
-			Reasoning: "Baseline: Trailing stop loss triggered",
+			Symbol:     pos.Symbol,
+			Action:     action,
+			Reasoning:  "Baseline: Parabolic SAR reversal exit",
+			ExitReason: decision.ExitReasonPSAR,
 		}
 	}
 
@@ -399,11 +545,16 @@ func (e *BaselineEngine) checkShortExit(
 	if hardStopLossPct <= 0 {
 		hardStopLossPct = 3.0 // 默认 -3.0%
 	}
-	if currentPrice >= state.EntryPrice*(1+hardStopLossPct/100) {
+	hardStopPrice := state.EntryPrice * (1 + hardStopLossPct/100)
+	if cfg.RiskManagement.EnableATRStop && state.HardStopPrice > 0 {
+		hardStopPrice = state.HardStopPrice // ATR 动态止损
+	}
+	if currentPrice >= hardStopPrice {
 		return &decision.Decision{
-			Symbol:    pos.Symbol,
-			Action:    action,
-			Reasoning: "Baseline: Hard stop loss (CRITICAL)",
+			Symbol:     pos.Symbol,
+			Action:     action,
+			Reasoning:  "Baseline: Hard stop loss (CRITICAL)",
+			ExitReason: decision.ExitReasonHardStop,
 		}
 	}
 
@@ -411,44 +562,153 @@ func (e *BaselineEngine) checkShortExit(
 	rsi7 := data.CurrentRSI7
 	if e.config.Indicators.EnableRSI && rsi7 < 30 {
 		return &decision.Decision{
-			Symbol:    pos.Symbol,
-			Action:    action,
-			Reasoning: "Baseline: RSI oversold exit (<30)",
+			Symbol:     pos.Symbol,
+			Action:     action,
+			Reasoning:  "Baseline: RSI oversold exit (<30)",
+			ExitReason: decision.ExitReasonRSIExit,
 		}
 	}
 
-	k, d := e.getStochRSI(data)
+	k, d := e.getStochRSIForTF(data, cfg.SignalTimeframe)
 	// StochRSI 出场：要求 K 值在超卖区（<=30）且金叉
 	// 优化：提高触发门槛，减少频繁出场
 	if e.config.Indicators.EnableStochRSI && k <= 30 && k > d {
 		return &decision.Decision{
-			Symbol:    pos.Symbol,
-			Action:    action,
-			Reasoning: "Baseline: StochRSI golden cross exit",
+			Symbol:     pos.Symbol,
+			Action:     action,
+			Reasoning:  "Baseline: StochRSI golden cross exit",
+			ExitReason: decision.ExitReasonStochCross,
 		}
 	}
 
-	// 3. 移动止盈
+	// 3. 固定止盈目标（在分批止盈和移动止盈之前，达到目标直接平掉剩余仓位）
+	if tp := e.checkFixedTakeProfit(pos, pnlPct, action, cfg); tp != nil {
+		return tp
+	}
+
+	// 4. 分批止盈
+	if scaleOut := e.checkScaleOut(pos, pnlPct, state, action, cfg); scaleOut != nil {
+		return scaleOut
+	}
+
+	// 5. 移动止盈
 	if state.TrailingTP > 0 && currentPrice >= state.TrailingTP {
 		return &decision.Decision{
-			Symbol:    pos.Symbol,
-			Action:    action,
-			Reasoning: "Baseline: Trailing take profit triggered",
+			Symbol:     pos.Symbol,
+			Action:     action,
+			Reasoning:  "Baseline: Trailing take profit triggered",
+			ExitReason: decision.ExitReasonTrailingTP,
+		}
+	}
+
+	// 6. 移动止损（保本止损生效后不再要求盈利门槛）
+	if (pnlPct >= 3.0 || state.BreakevenSet) && currentPrice >= state.TrailingStop {
+		return &decision.Decision{
+			Symbol:     pos.Symbol,
+			Action:     action,
+			Reasoning:  "Baseline: Trailing stop loss triggered",
+			ExitReason: decision.ExitReasonTrailingStop,
 		}
 	}
 
-	// 4. 移动止损
-	if pnlPct >= 3.0 && currentPrice >= state.TrailingStop {
+	// 7. 抛物线 SAR 反转：价格穿越 SAR，趋势翻转到持仓的反方向
+	if e.config.Indicators.EnablePSAR && e.psarReversed(data, cfg, state) {
 		return &decision.Decision{
-			Symbol:    pos.Symbol,
-			Action:    action,
-			Reasoning: "Baseline: Trailing stop loss triggered",
+			Symbol:     pos.Symbol,
+			Action:     action,
+			Reasoning:  "Baseline: Parabolic SAR reversal exit",
+			ExitReason: decision.ExitReasonPSAR,
 		}
 	}
 
 	return nil
 }
 
+// checkFixedTakeProfit 检查固定止盈目标：盈利达到 FixedTakeProfitPct 时平掉剩余仓位
+// 默认关闭（0），此时移动止盈仍是主要的止盈方式；与分批止盈同时启用时，低于目标的档位照常分批
+func (e *BaselineEngine) checkFixedTakeProfit(
+	pos decision.PositionInfo,
+	pnlPct float64,
+	action string,
+	cfg *store.BaselineConfig,
+) *decision.Decision {
+	target := cfg.RiskManagement.FixedTakeProfitPct
+	if target <= 0 || pnlPct < target {
+		return nil
+	}
+	return &decision.Decision{
+		Symbol:     pos.Symbol,
+		Action:     action,
+		Reasoning:  fmt.Sprintf("Baseline: Fixed take profit target %.1f%% reached (+%.1f%%)", target, pnlPct),
+		ExitReason: decision.ExitReasonTakeProfit,
+	}
+}
+
+// checkScaleOut 检查分批止盈
+// 盈利达到 TP1 / TP2 阈值时各平掉剩余仓位的 ScaleOutFraction，剩余仓位继续持有，
+// 并将移动止损上移到保本 + 对应档位的锁定利润
+func (e *BaselineEngine) checkScaleOut(
+	pos decision.PositionInfo,
+	pnlPct float64,
+	state *BaselinePositionState,
+	action string,
+	cfg *store.BaselineConfig,
+) *decision.Decision {
+	rm := cfg.RiskManagement
+	if !rm.EnableScaleOut || state.ScaleOutStage >= 2 {
+		return nil
+	}
+
+	fraction := rm.ScaleOutFraction
+	if fraction <= 0 || fraction >= 1 {
+		fraction = 0.5 // 默认平掉一半
+	}
+
+	// 阶段阈值与移动止盈档位保持一致
+	thresholds := []float64{rm.TrailingTP1Pct, rm.TrailingTP2Pct}
+	locks := []float64{rm.TrailingTP1Lock, rm.TrailingTP2Lock}
+	if thresholds[0] <= 0 {
+		thresholds[0] = 2.0
+	}
+	if thresholds[1] <= 0 {
+		thresholds[1] = 4.0
+	}
+	if locks[0] <= 0 {
+		locks[0] = 0.5
+	}
+	if locks[1] <= 0 {
+		locks[1] = 1.5
+	}
+
+	stage := state.ScaleOutStage
+	if pnlPct < thresholds[stage] {
+		return nil
+	}
+
+	state.ScaleOutStage++
+
+	// 剩余仓位的止损上移（只收紧不放松）
+	if pos.Side == "long" {
+		newStop := state.EntryPrice * (1 + locks[stage]/100)
+		if newStop > state.TrailingStop {
+			state.TrailingStop = newStop
+		}
+	} else {
+		newStop := state.EntryPrice * (1 - locks[stage]/100)
+		if state.TrailingStop <= 0 || newStop < state.TrailingStop {
+			state.TrailingStop = newStop
+		}
+	}
+
+	return &decision.Decision{
+		Symbol:        pos.Symbol,
+		Action:        action,
+		CloseFraction: fraction,
+		Reasoning:     fmt.Sprintf("Baseline: Scale-out stage %d (%.0f%%) at +%.1f%%", state.ScaleOutStage, fraction*100, pnlPct),
+		ExitReason:    decision.ExitReasonScaleOut,
+	}
+}
+
 // updatePositionState 更新持仓状态（峰值价格和移动止盈止损）
 func (e *BaselineEngine) updatePositionState(pos decision.PositionInfo, currentPrice float64) {
 	stateKey := pos.Symbol + "_" + pos.Side
@@ -509,6 +769,10 @@ func (e *BaselineEngine) updatePositionState(pos decision.PositionInfo, currentP
 	if sl2Lock <= 0 {
 		sl2Lock = 1.5
 	}
+	breakevenOffset := rm.BreakevenOffsetPct
+	if breakevenOffset <= 0 {
+		breakevenOffset = 0.1 // 默认覆盖手续费
+	}
 
 	if pos.Side == "long" {
 		// 更新峰值价格
@@ -537,6 +801,15 @@ func (e *BaselineEngine) updatePositionState(pos decision.PositionInfo, currentP
 				state.TrailingStop = newStop
 			}
 		}
+
+		// 保本止损（独立于 SL1/SL2，只收紧不放松）
+		if rm.BreakevenTriggerPct > 0 && pnlPct >= rm.BreakevenTriggerPct {
+			newStop := state.EntryPrice * (1 + breakevenOffset/100)
+			if newStop > state.TrailingStop {
+				state.TrailingStop = newStop
+			}
+			state.BreakevenSet = true
+		}
 	} else {
 		// 空头：更新峰值价格（最低价）
 		if currentPrice < state.PeakPrice || state.PeakPrice == state.EntryPrice {
@@ -564,11 +837,124 @@ func (e *BaselineEngine) updatePositionState(pos decision.PositionInfo, currentP
 				state.TrailingStop = newStop
 			}
 		}
+
+		// 保本止损（独立于 SL1/SL2，只收紧不放松）
+		if rm.BreakevenTriggerPct > 0 && pnlPct >= rm.BreakevenTriggerPct {
+			newStop := state.EntryPrice * (1 - breakevenOffset/100)
+			if state.TrailingStop <= 0 || newStop < state.TrailingStop {
+				state.TrailingStop = newStop
+			}
+			state.BreakevenSet = true
+		}
 	}
 }
 
 // 辅助方法
 
+// recordExit 记录币种的平仓周期和方向（用于冷却期）
+func (e *BaselineEngine) recordExit(symbol, side string) {
+	e.lastExitCycle[symbol] = e.cycle
+	e.lastExitSide[symbol] = side
+}
+
+// EntryCadenceBars 返回开仓评估间隔（bar 数），<= 1 表示每次调用都评估开仓
+// 大于 1 时调用方应每个 bar 调用 MakeDecision（实盘），由引擎按节奏评估开仓并在每个 bar 检查平仓
+func (e *BaselineEngine) EntryCadenceBars() int {
+	if e.entryCadence > 0 {
+		return e.entryCadence
+	}
+	if e.config.BaselineConfig == nil {
+		return 1
+	}
+	return e.config.BaselineConfig.EntryCadenceBars
+}
+
+// onEntryCadence 当前周期是否评估开仓：第 1 次调用及此后每 N 次调用
+func (e *BaselineEngine) onEntryCadence() bool {
+	n := e.EntryCadenceBars()
+	if n <= 1 {
+		return true
+	}
+	return (e.cycle-1)%n == 0
+}
+
+// symbolAllowed 检查币种是否在允许开仓的交易范围内，未配置 AllowedSymbols 时所有币种都允许
+// 只限制开仓和加仓，范围外币种的已有持仓照常检查平仓信号
+func (e *BaselineEngine) symbolAllowed(symbol string) bool {
+	cfg := e.config.BaselineConfig
+	if cfg == nil || len(cfg.AllowedSymbols) == 0 {
+		return true
+	}
+	symbol = market.Normalize(symbol)
+	for _, allowed := range cfg.AllowedSymbols {
+		if market.Normalize(strings.TrimSpace(allowed)) == symbol {
+			return true
+		}
+	}
+	return false
+}
+
+// inTradingHours 检查 bar 时间是否在允许开仓的交易时段内（UTC），未配置时段时始终允许
+func (e *BaselineEngine) inTradingHours(ts int64) bool {
+	cfg := e.config.BaselineConfig
+	if cfg == nil || len(cfg.TradingHours) == 0 {
+		return true
+	}
+	t := time.UnixMilli(ts).UTC()
+	for _, window := range cfg.TradingHours {
+		if tradingWindowContains(window, t) {
+			return true
+		}
+	}
+	logger.Debugf("[Baseline] %s outside trading hours, skip entries", t.Format("2006-01-02 15:04"))
+	return false
+}
+
+// tradingWindowContains 检查时间是否落在交易时段内
+// EndHour <= StartHour 的时段跨越午夜，午夜之后的部分属于前一天的时段（Weekdays 按开始日匹配）
+func tradingWindowContains(window store.BaselineTradingWindow, t time.Time) bool {
+	onDay := func(day time.Weekday) bool {
+		if len(window.Weekdays) == 0 {
+			return true
+		}
+		for _, d := range window.Weekdays {
+			if time.Weekday(d) == day {
+				return true
+			}
+		}
+		return false
+	}
+
+	hour := t.Hour()
+	if window.StartHour < window.EndHour {
+		return hour >= window.StartHour && hour < window.EndHour && onDay(t.Weekday())
+	}
+	if hour >= window.StartHour {
+		return onDay(t.Weekday())
+	}
+	if hour < window.EndHour {
+		return onDay((t.Weekday() + 6) % 7)
+	}
+	return false
+}
+
+// inCooldown 检查币种是否处于平仓冷却期
+// 返回是否禁止做多/做空；CooldownOppositeOnly 时只禁止与上次平仓方向相反的开仓
+func (e *BaselineEngine) inCooldown(symbol string, thresholds store.BaselineSignalThresholds) (blockLong, blockShort bool) {
+	if thresholds.CooldownBars <= 0 {
+		return false, false
+	}
+	exitCycle, ok := e.lastExitCycle[symbol]
+	if !ok || e.cycle-exitCycle >= thresholds.CooldownBars {
+		return false, false
+	}
+	if !thresholds.CooldownOppositeOnly {
+		return true, true
+	}
+	lastSide := e.lastExitSide[symbol]
+	return lastSide == "short", lastSide == "long"
+}
+
 func (e *BaselineEngine) hasPosition(positions []decision.PositionInfo, symbol string) bool {
 	for _, pos := range positions {
 		if pos.Symbol == symbol {
@@ -589,124 +975,808 @@ func (e *BaselineEngine) countSameDirectionPositions(side string) int {
 	return count
 }
 
-func (e *BaselineEngine) getATR(data *market.Data) float64 {
-	if data.TimeframeData != nil {
-		for _, tfData := range data.TimeframeData {
-			if tfData.ATR14 > 0 {
-				return tfData.ATR14
-			}
-		}
+// exceedsCorrelationLimit 检查新开仓是否会超过相关仓位数量限制
+// 统计与 symbol 收益率相关系数达到阈值的同方向仓位，达到 MaxCorrelatedPositions 时禁止开仓
+func (e *BaselineEngine) exceedsCorrelationLimit(symbol, side string, rm store.BaselineRiskManagement) bool {
+	if rm.MaxCorrelatedPositions <= 0 || e.correlations == nil {
+		return false
 	}
-	if data.IntradaySeries != nil && data.IntradaySeries.ATR14 > 0 {
-		return data.IntradaySeries.ATR14
+	threshold := rm.CorrelationThreshold
+	if threshold <= 0 {
+		threshold = 0.8 // 默认值
 	}
-	return 0
-}
 
-func (e *BaselineEngine) getStochRSI(data *market.Data) (k, d float64) {
-	if data.TimeframeData != nil {
-		for _, tfData := range data.TimeframeData {
-			if len(tfData.StochRSI_K) > 0 && len(tfData.StochRSI_D) > 0 {
-				k = tfData.StochRSI_K[len(tfData.StochRSI_K)-1]
-				d = tfData.StochRSI_D[len(tfData.StochRSI_D)-1]
-				return k, d
-			}
+	correlated := 0
+	for _, state := range e.positionStates {
+		if state.Side != side || state.Symbol == symbol {
+			continue
+		}
+		if corr, ok := e.correlations[symbol][state.Symbol]; ok && corr >= threshold {
+			correlated++
 		}
 	}
-	return 0, 0
-}
-
-// getVolumeRatio 获取当前成交量与平均成交量的比值
-// 返回值 > 1 表示成交量高于平均，< 1 表示低于平均
-// 返回 0 表示无法获取成交量数据
-func (e *BaselineEngine) getVolumeRatio(data *market.Data) float64 {
-	if data.TimeframeData == nil {
-		return 0
+	if correlated >= rm.MaxCorrelatedPositions {
+		logger.Debugf("[Baseline] %s: %d correlated %s positions, skip entry", symbol, correlated, side)
+		return true
 	}
+	return false
+}
 
-	// 遍历时间周期数据，优先使用较短周期的数据
-	for _, tfData := range data.TimeframeData {
-		if len(tfData.Klines) < 5 {
+// buildCorrelationMatrix 计算各币种最近 window 根 K 线收益率的两两相关系数
+// 数据不足或收益率无波动的币种对不在矩阵中（视为不相关）
+func (e *BaselineEngine) buildCorrelationMatrix(marketData map[string]*market.Data, timeframe string, window int) map[string]map[string]float64 {
+	returns := make(map[string][]float64, len(marketData))
+	for symbol, data := range marketData {
+		if data == nil {
 			continue
 		}
-
-		// 获取最近一根 K 线的成交量
-		currentVolume := tfData.Klines[len(tfData.Klines)-1].Volume
-		if currentVolume <= 0 {
-			continue
+		if tfData := e.getTimeframeSeries(data, timeframe); tfData != nil {
+			returns[symbol] = klineReturns(tfData.Klines, window)
 		}
+	}
 
-		// 计算前 N 根 K 线的平均成交量（排除最新一根）
-		lookback := 20
-		if len(tfData.Klines)-1 < lookback {
-			lookback = len(tfData.Klines) - 1
-		}
-		if lookback < 3 {
-			continue
+	matrix := make(map[string]map[string]float64, len(returns))
+	for a, ra := range returns {
+		for b, rb := range returns {
+			if a >= b {
+				continue
+			}
+			corr, ok := returnCorrelation(ra, rb)
+			if !ok {
+				continue
+			}
+			if matrix[a] == nil {
+				matrix[a] = make(map[string]float64)
+			}
+			if matrix[b] == nil {
+				matrix[b] = make(map[string]float64)
+			}
+			matrix[a][b] = corr
+			matrix[b][a] = corr
 		}
+	}
+	return matrix
+}
 
-		var sumVolume float64
-		for i := len(tfData.Klines) - 1 - lookback; i < len(tfData.Klines)-1; i++ {
-			sumVolume += tfData.Klines[i].Volume
+// klineReturns 计算最近 window 根 K 线的收盘价收益率
+func klineReturns(klines []market.KlineBar, window int) []float64 {
+	if len(klines) > window+1 {
+		klines = klines[len(klines)-window-1:]
+	}
+	returns := make([]float64, 0, len(klines))
+	for i := 1; i < len(klines); i++ {
+		if prev := klines[i-1].Close; prev > 0 {
+			returns = append(returns, (klines[i].Close-prev)/prev)
 		}
-		avgVolume := sumVolume / float64(lookback)
+	}
+	return returns
+}
 
-		if avgVolume > 0 {
-			return currentVolume / avgVolume
-		}
+// returnCorrelation 计算两个收益率序列（按末尾对齐）的皮尔逊相关系数
+// 重叠样本少于 3 个或任一序列无波动时 ok 为 false
+func returnCorrelation(a, b []float64) (corr float64, ok bool) {
+	n := len(a)
+	if len(b) < n {
+		n = len(b)
+	}
+	if n < 3 {
+		return 0, false
 	}
+	a, b = a[len(a)-n:], b[len(b)-n:]
 
-	return 0
+	var meanA, meanB float64
+	for i := 0; i < n; i++ {
+		meanA += a[i]
+		meanB += b[i]
+	}
+	meanA /= float64(n)
+	meanB /= float64(n)
+
+	var cov, varA, varB float64
+	for i := 0; i < n; i++ {
+		da, db := a[i]-meanA, b[i]-meanB
+		cov += da * db
+		varA += da * da
+		varB += db * db
+	}
+	if varA == 0 || varB == 0 {
+		return 0, false
+	}
+	return cov / math.Sqrt(varA*varB), true
 }
 
-// generateScoredDecision 生成带评分的开仓决策
-// 返回 nil 表示不满足开仓条件
-func (e *BaselineEngine) generateScoredDecision(
-	symbol string,
-	data *market.Data,
-	equity float64,
-	available float64,
-) *ScoredDecision {
-	if data == nil {
-		return nil
+// checkDrawdownHalt 更新历史最高权益并检查账户回撤熔断
+// 回撤超过 MaxAccountDrawdownPct 时进入熔断，回撤恢复到 DrawdownResumePct 以内后解除
+// 熔断中返回一条 wait 决策（说明风控暂停原因），否则返回 nil
+func (e *BaselineEngine) checkDrawdownHalt(equity float64) *decision.Decision {
+	if equity > e.peakEquity {
+		e.peakEquity = equity
 	}
 
-	// 获取配置参数
-	baselineCfg := e.config.BaselineConfig
-	if baselineCfg == nil {
+	cfg := e.config.BaselineConfig
+	if cfg == nil || cfg.RiskManagement.MaxAccountDrawdownPct <= 0 || e.peakEquity <= 0 {
+		e.riskHalted = false
 		return nil
 	}
+	limit := cfg.RiskManagement.MaxAccountDrawdownPct
+	resume := cfg.RiskManagement.DrawdownResumePct
+	if resume <= 0 {
+		resume = limit / 2 // 默认值
+	}
 
-	indicators := e.config.Indicators
-	price := data.CurrentPrice
-	ema20 := data.CurrentEMA20
-
-	// 计算信号强度和评分
-	longSignals := 0
-	shortSignals := 0
-	longScore := 0.0
-	shortScore := 0.0
+	drawdownPct := (e.peakEquity - equity) / e.peakEquity * 100
+	switch {
+	case drawdownPct > limit && !e.riskHalted:
+		e.riskHalted = true
+		logger.Infof("[Baseline] Risk halt: account drawdown %.2f%% exceeds %.2f%%, entries suspended", drawdownPct, limit)
+	case drawdownPct <= resume && e.riskHalted:
+		e.riskHalted = false
+		logger.Infof("[Baseline] Risk halt lifted: account drawdown recovered to %.2f%%", drawdownPct)
+	}
+	if !e.riskHalted {
+		return nil
+	}
 
-	// MACD 不参与开仓方向判断，仅作为参考指标
-	// （已移除 MACD 对 longSignals/shortSignals 的影响）
+	return &decision.Decision{
+		Action:    "wait",
+		Reasoning: fmt.Sprintf("Baseline: Risk halt (account drawdown %.1f%% from peak, limit %.1f%%, resumes below %.1f%%)", drawdownPct, limit, resume),
+	}
+}
 
-	// EMA 趋势信号及评分（降低权重：最高 20 分）
-	if indicators.EnableEMA && ema20 > 0 {
-		priceDiff := (price - ema20) / ema20 * 100
-		if price > ema20 {
-			longSignals++
-			// 价格高于 EMA 越多，评分越高
-			longScore += min(priceDiff*5, 20) // 最高 20 分
-		} else {
-			shortSignals++
-			// 价格低于 EMA 越多，评分越高
-			shortScore += min(-priceDiff*5, 20)
+// resolveLeverage 返回币种的开仓杠杆
+// 主流币（MajorSymbols，默认 BTC/ETH）使用主流币杠杆，其他币种使用山寨币杠杆：
+// 设置了回测固定参数的分级杠杆（SetBacktestLeverage）时使用它，与 AI 回测一致；
+// 否则启用杠杆分级时使用 RiskControl 的 BTCETHMaxLeverage / AltcoinMaxLeverage；
+// 都未启用或分级杠杆未设置时使用 RiskManagement.Leverage
+func (e *BaselineEngine) resolveLeverage(symbol string) int {
+	leverage := 0
+	if cfg := e.config.BaselineConfig; cfg != nil {
+		leverage = cfg.RiskManagement.Leverage
+		major, alt := 0, 0
+		switch {
+		case e.btcEthLeverage > 0 || e.altLeverage > 0:
+			major, alt = e.btcEthLeverage, e.altLeverage
+		case cfg.RiskManagement.EnableLeverageTiers:
+			major, alt = e.config.RiskControl.BTCETHMaxLeverage, e.config.RiskControl.AltcoinMaxLeverage
 		}
+		tier := alt
+		if e.isMajorSymbol(symbol) {
+			tier = major
+		}
+		if tier > 0 {
+			leverage = tier
+		}
+	}
+	if leverage <= 0 {
+		leverage = 5 // 默认值
 	}
+	return leverage
+}
 
-	// StochRSI 信号及评分（趋势跟随：最高 70 分，成为主导指标）
-	// 使用配置参数
-	stochOversold := baselineCfg.SignalThresholds.StochOversold
+// isMajorSymbol 是否为使用主流币杠杆的币种（MajorSymbols，默认 BTC/ETH）
+func (e *BaselineEngine) isMajorSymbol(symbol string) bool {
+	majors := e.config.BaselineConfig.RiskManagement.MajorSymbols
+	if len(majors) == 0 {
+		majors = []string{"BTCUSDT", "ETHUSDT"} // 默认值
+	}
+	for _, major := range majors {
+		if strings.EqualFold(major, symbol) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasMinimumEdge 检查预期波动是否覆盖往返交易成本
+// 以第一档移动止盈对应的价格波动作为预期波动，要求至少为（手续费 + 滑点）× 2 的 MinEdgeCostMultiple 倍。
+// TrailingTP1Pct 按保证金收益率计算，而成本按名义价值计算，需先除以杠杆换算为价格波动
+func (e *BaselineEngine) hasMinimumEdge(rm store.BaselineRiskManagement, leverage int) bool {
+	roundTripPct := 2 * (e.feeBps + e.slippageBps) / 100
+	if roundTripPct <= 0 {
+		return true
+	}
+	tp1Pct := rm.TrailingTP1Pct
+	if tp1Pct <= 0 {
+		tp1Pct = 2.0 // 与移动止盈默认值一致
+	}
+	expectedMovePct := tp1Pct / float64(max(leverage, 1))
+	multiple := rm.MinEdgeCostMultiple
+	if multiple <= 0 {
+		multiple = 1.5 // 默认值
+	}
+	if expectedMovePct < roundTripPct*multiple {
+		logger.Debugf("[Baseline] expected move %.2f%% below %.1f× round-trip cost %.2f%%, skip entry",
+			expectedMovePct, multiple, roundTripPct)
+		return false
+	}
+	return true
+}
+
+// maxMarginUsage 获取总保证金占用上限（占净值的比例）
+// 优先使用 Baseline 风控配置，未设置时回退到策略风控配置，返回 0 表示不限制
+func (e *BaselineEngine) maxMarginUsage() float64 {
+	if cfg := e.config.BaselineConfig; cfg != nil && cfg.RiskManagement.MaxMarginUsage > 0 {
+		return cfg.RiskManagement.MaxMarginUsage
+	}
+	return e.config.RiskControl.MaxMarginUsage
+}
+
+// positionMargin 计算持仓占用的保证金，缺少 MarginUsed 时按名义价值 / 杠杆估算
+func positionMargin(pos decision.PositionInfo) float64 {
+	if pos.MarginUsed > 0 {
+		return pos.MarginUsed
+	}
+	price := pos.MarkPrice
+	if price <= 0 {
+		price = pos.EntryPrice
+	}
+	leverage := pos.Leverage
+	if leverage <= 0 {
+		leverage = 1
+	}
+	return math.Abs(pos.Quantity) * price / float64(leverage)
+}
+
+// applyMarginCap 按评分顺序累计新开仓的保证金，使总占用不超过 equity × MaxMarginUsage
+// 超出上限的开仓缩减到剩余额度，剩余额度不足最小仓位时取消并清除其持仓状态（加仓取消时保留原持仓状态）
+func (e *BaselineEngine) applyMarginCap(
+	decisions []decision.Decision,
+	equity float64,
+	positions []decision.PositionInfo,
+) []decision.Decision {
+	maxUsage := e.maxMarginUsage()
+	if maxUsage <= 0 || len(decisions) == 0 {
+		return decisions
+	}
+
+	used := 0.0
+	for _, pos := range positions {
+		used += positionMargin(pos)
+	}
+	remaining := equity*maxUsage - used
+
+	result := make([]decision.Decision, 0, len(decisions))
+	for _, dec := range decisions {
+		leverage := dec.Leverage
+		if leverage <= 0 {
+			leverage = 1
+		}
+		if margin := dec.PositionSizeUSD / float64(leverage); margin > remaining {
+			dec.PositionSizeUSD = math.Max(remaining, 0) * float64(leverage)
+			if dec.PositionSizeUSD < 50 {
+				logger.Debugf("[Baseline] %s: margin cap %.0f%% reached, skip entry", dec.Symbol, maxUsage*100)
+				side := "long"
+				if dec.Action == "open_short" {
+					side = "short"
+				}
+				if !e.hasPosition(positions, dec.Symbol) {
+					delete(e.positionStates, dec.Symbol+"_"+side)
+				}
+				continue
+			}
+			dec.Reasoning += fmt.Sprintf(" (size reduced by %.0f%% margin cap)", maxUsage*100)
+		}
+		remaining -= dec.PositionSizeUSD / float64(leverage)
+		result = append(result, dec)
+	}
+	return result
+}
+
+// pyramidAdd 待确认的金字塔加仓（通过保证金检查后由 commitPyramidAdd 写入持仓状态）
+type pyramidAdd struct {
+	pos          decision.PositionInfo
+	state        *BaselinePositionState
+	price        float64
+	stopDistance float64
+	decision     decision.Decision
+}
+
+// checkPyramidAdd 检查持仓是否满足金字塔加仓条件：
+// 未达到最大加仓次数，价格较上次入场（开仓或加仓）有利移动超过 PyramidMinProfitPct，且同方向开仓信号再次触发
+func (e *BaselineEngine) checkPyramidAdd(pos decision.PositionInfo, data *market.Data, available float64) *pyramidAdd {
+	cfg := e.config.BaselineConfig
+	if cfg == nil || !cfg.RiskManagement.EnablePyramiding {
+		return nil
+	}
+	rm := cfg.RiskManagement
+
+	state, exists := e.positionStates[pos.Symbol+"_"+pos.Side]
+	if !exists {
+		return nil
+	}
+	maxAdds := rm.MaxPyramidEntries
+	if maxAdds <= 0 {
+		maxAdds = 2 // 默认值
+	}
+	if state.PyramidAdds >= maxAdds {
+		return nil
+	}
+
+	sig := e.evaluateEntry(pos.Symbol, data, available)
+	if sig == nil || sig.side != pos.Side {
+		return nil
+	}
+
+	// 只在盈利时加仓：以最近一次入场价计算有利移动幅度
+	lastEntry := state.LastAddPrice
+	if lastEntry <= 0 {
+		lastEntry = state.EntryPrice
+	}
+	if lastEntry <= 0 {
+		return nil
+	}
+	movePct := (sig.price - lastEntry) / lastEntry * 100
+	if pos.Side == "short" {
+		movePct = -movePct
+	}
+	minProfit := rm.PyramidMinProfitPct
+	if minProfit <= 0 {
+		minProfit = 2.0 // 默认值
+	}
+	if movePct < minProfit {
+		return nil
+	}
+
+	fraction := rm.PyramidSizeFraction
+	if fraction <= 0 {
+		fraction = 0.5 // 默认为正常开仓金额的一半
+	}
+	size := sig.positionValue * fraction
+	if size < 50 {
+		return nil
+	}
+
+	logger.Debugf("[Baseline] %s: pyramid add %d/%d, +%.2f%% since last entry", pos.Symbol, state.PyramidAdds+1, maxAdds, movePct)
+	return &pyramidAdd{
+		pos:          pos,
+		state:        state,
+		price:        sig.price,
+		stopDistance: sig.stopDistance,
+		decision: decision.Decision{
+			Symbol:          pos.Symbol,
+			Action:          "open_" + pos.Side,
+			Leverage:        sig.leverage,
+			PositionSizeUSD: size,
+			Confidence:      75,
+			Reasoning: regimeReasoning(fmt.Sprintf("Baseline: Pyramid add %d/%d (+%.1f%% since last entry)",
+				state.PyramidAdds+1, maxAdds, movePct), sig.regime),
+		},
+	}
+}
+
+// commitPyramidAdd 记录加仓：按数量加权更新持仓均价，并基于新均价重新计算止损（只收紧不放松）
+// 返回加仓后的止损价
+func (e *BaselineEngine) commitPyramidAdd(add *pyramidAdd, sizeUSD float64) float64 {
+	state := add.state
+	addQty := sizeUSD / add.price
+	if qty := add.pos.Quantity; qty > 0 {
+		state.EntryPrice = (state.EntryPrice*qty + add.price*addQty) / (qty + addQty)
+	}
+	state.LastAddPrice = add.price
+	state.PyramidAdds++
+
+	// 保本止损基于旧均价设置，新均价下需重新触发
+	state.BreakevenSet = false
+	if state.Side == "long" {
+		stop := state.EntryPrice - add.stopDistance
+		state.TrailingStop = math.Max(state.TrailingStop, stop)
+		state.HardStopPrice = math.Max(state.HardStopPrice, stop)
+		return state.TrailingStop
+	}
+	stop := state.EntryPrice + add.stopDistance
+	if state.TrailingStop <= 0 || stop < state.TrailingStop {
+		state.TrailingStop = stop
+	}
+	if state.HardStopPrice <= 0 || stop < state.HardStopPrice {
+		state.HardStopPrice = stop
+	}
+	return state.TrailingStop
+}
+
+// signalTimeframe 返回配置的决策周期，未配置时为空（由各指标按周期名排序取第一个有数据的周期）
+func (e *BaselineEngine) signalTimeframe() string {
+	if e.config == nil || e.config.BaselineConfig == nil {
+		return ""
+	}
+	return e.config.BaselineConfig.SignalTimeframe
+}
+
+// sortedTimeframeData 按周期名排序返回各周期数据，保证未指定周期时的选择与 map 遍历顺序无关
+func sortedTimeframeData(data *market.Data) []*market.TimeframeSeriesData {
+	if data == nil || len(data.TimeframeData) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(data.TimeframeData))
+	for tf := range data.TimeframeData {
+		keys = append(keys, tf)
+	}
+	sort.Strings(keys)
+	series := make([]*market.TimeframeSeriesData, 0, len(keys))
+	for _, tf := range keys {
+		if tfData := data.TimeframeData[tf]; tfData != nil {
+			series = append(series, tfData)
+		}
+	}
+	return series
+}
+
+// getATRForTF 获取指定周期的 ATR14
+// timeframe 为空时使用排序后第一个有 ATR 的周期；指定周期无数据时回退到主周期序列
+func (e *BaselineEngine) getATRForTF(data *market.Data, timeframe string) float64 {
+	if timeframe != "" {
+		if tfData := data.TimeframeData[timeframe]; tfData != nil && tfData.ATR14 > 0 {
+			return tfData.ATR14
+		}
+	} else {
+		for _, tfData := range sortedTimeframeData(data) {
+			if tfData.ATR14 > 0 {
+				return tfData.ATR14
+			}
+		}
+	}
+	if data.IntradaySeries != nil && data.IntradaySeries.ATR14 > 0 {
+		return data.IntradaySeries.ATR14
+	}
+	return 0
+}
+
+// stopDistance 计算止损距离（价格单位）
+// 启用 ATR 止损时为 ATR × 倍数，ATR 不可用时回退到固定百分比止损
+func (e *BaselineEngine) stopDistance(data *market.Data, price, hardStopLossPct float64, rm store.BaselineRiskManagement) float64 {
+	if rm.EnableATRStop {
+		multiple := rm.ATRStopMultiple
+		if multiple <= 0 {
+			multiple = 2.0 // 默认值
+		}
+		if atr := e.getATRForTF(data, e.signalTimeframe()); atr > 0 {
+			return atr * multiple
+		}
+	}
+	return price * hardStopLossPct / 100
+}
+
+// getADX 获取 ADX 趋势强度，返回 0 表示无法获取
+func (e *BaselineEngine) getADX(data *market.Data) float64 {
+	for _, tfData := range sortedTimeframeData(data) {
+		if tfData.ADX14 > 0 {
+			return tfData.ADX14
+		}
+	}
+	return 0
+}
+
+// getStochRSIForTF 获取指定周期的 StochRSI K/D 值
+// timeframe 为空时使用排序后第一个有数据的周期
+func (e *BaselineEngine) getStochRSIForTF(data *market.Data, timeframe string) (k, d float64) {
+	kSeries, dSeries := e.getStochRSISeriesForTF(data, timeframe)
+	if len(kSeries) == 0 || len(dSeries) == 0 {
+		return 0, 0
+	}
+	return kSeries[len(kSeries)-1], dSeries[len(dSeries)-1]
+}
+
+// getStochRSISeriesForTF 获取指定周期的 StochRSI K/D 序列，周期选择规则同 getStochRSIForTF
+func (e *BaselineEngine) getStochRSISeriesForTF(data *market.Data, timeframe string) (kSeries, dSeries []float64) {
+	if data.TimeframeData == nil {
+		return nil, nil
+	}
+	if timeframe != "" {
+		tfData, ok := data.TimeframeData[timeframe]
+		if !ok || tfData == nil || len(tfData.StochRSI_K) == 0 || len(tfData.StochRSI_D) == 0 {
+			return nil, nil
+		}
+		return tfData.StochRSI_K, tfData.StochRSI_D
+	}
+	for _, tfData := range sortedTimeframeData(data) {
+		if len(tfData.StochRSI_K) > 0 && len(tfData.StochRSI_D) > 0 {
+			return tfData.StochRSI_K, tfData.StochRSI_D
+		}
+	}
+	return nil, nil
+}
+
+// stochCrossHeld 判断 K/D 交叉是否已持续 bars 根 K 线（序列按末尾对齐）
+// 返回 1 表示 K 连续位于 D 上方，-1 表示连续位于 D 下方，0 表示未确认或数据不足
+func stochCrossHeld(kSeries, dSeries []float64, bars int) int {
+	if bars < 1 {
+		bars = 1
+	}
+	if len(kSeries) < bars || len(dSeries) < bars {
+		return 0
+	}
+	direction := 0
+	for i := 1; i <= bars; i++ {
+		k, d := kSeries[len(kSeries)-i], dSeries[len(dSeries)-i]
+		barDirection := 0
+		if k > d {
+			barDirection = 1
+		} else if k < d {
+			barDirection = -1
+		}
+		if barDirection == 0 || (direction != 0 && barDirection != direction) {
+			return 0
+		}
+		direction = barDirection
+	}
+	return direction
+}
+
+// getEMA 获取指定周期的最新收盘价和短周期 EMA
+// timeframe 为空或该周期无数据时，使用主周期的当前价格和 EMA20
+func (e *BaselineEngine) getEMA(data *market.Data, timeframe string) (price, ema float64) {
+	if timeframe != "" && data.TimeframeData != nil {
+		if tfData, ok := data.TimeframeData[timeframe]; ok && tfData != nil &&
+			len(tfData.Klines) > 0 && len(tfData.EMAShortValues) > 0 {
+			return tfData.Klines[len(tfData.Klines)-1].Close, tfData.EMAShortValues[len(tfData.EMAShortValues)-1]
+		}
+	}
+	return data.CurrentPrice, data.CurrentEMA20
+}
+
+// getEMATrend 获取指定周期的 EMA 趋势方向
+// 返回 1 表示上升趋势（短 EMA 在长 EMA 上方，无长 EMA 时比较收盘价与短 EMA），-1 表示下降趋势，0 表示无数据或无方向
+func (e *BaselineEngine) getEMATrend(data *market.Data, timeframe string) int {
+	if data.TimeframeData == nil {
+		return 0
+	}
+	tfData, ok := data.TimeframeData[timeframe]
+	if !ok || tfData == nil || len(tfData.EMAShortValues) == 0 {
+		return 0
+	}
+
+	fast := tfData.EMAShortValues[len(tfData.EMAShortValues)-1]
+	var slow float64
+	if len(tfData.EMALongValues) > 0 {
+		slow = tfData.EMALongValues[len(tfData.EMALongValues)-1]
+	} else if len(tfData.Klines) > 0 {
+		// 没有长周期 EMA 时，用收盘价与短 EMA 比较
+		fast, slow = tfData.Klines[len(tfData.Klines)-1].Close, fast
+	} else {
+		return 0
+	}
+
+	switch {
+	case fast > slow:
+		return 1
+	case fast < slow:
+		return -1
+	default:
+		return 0
+	}
+}
+
+// getVolumeRatio 获取当前成交量与平均成交量的比值
+// 返回值 > 1 表示成交量高于平均，< 1 表示低于平均
+// 返回 0 表示无法获取成交量数据
+func (e *BaselineEngine) getVolumeRatio(data *market.Data) float64 {
+	if data.TimeframeData == nil {
+		return 0
+	}
+
+	// 按周期名顺序遍历，使用第一个有足够成交量数据的周期
+	for _, tfData := range sortedTimeframeData(data) {
+		if len(tfData.Klines) < 5 {
+			continue
+		}
+
+		// 获取最近一根 K 线的成交量
+		currentVolume := tfData.Klines[len(tfData.Klines)-1].Volume
+		if !isFinite(currentVolume) || currentVolume <= 0 {
+			continue
+		}
+
+		// 计算前 N 根 K 线的平均成交量（排除最新一根）
+		lookback := 20
+		if len(tfData.Klines)-1 < lookback {
+			lookback = len(tfData.Klines) - 1
+		}
+		if lookback < 3 {
+			continue
+		}
+
+		var sumVolume float64
+		for i := len(tfData.Klines) - 1 - lookback; i < len(tfData.Klines)-1; i++ {
+			sumVolume += tfData.Klines[i].Volume
+		}
+		avgVolume := sumVolume / float64(lookback)
+
+		// 平均成交量为 0 或含 NaN/Inf 时该周期无效，继续尝试下一个周期
+		if isFinite(avgVolume) && avgVolume > 0 {
+			if ratio := currentVolume / avgVolume; isFinite(ratio) {
+				return ratio
+			}
+		}
+	}
+
+	return 0
+}
+
+// entrySignal 开仓信号评估结果（仅计算，不修改引擎状态）
+type entrySignal struct {
+	side          string // "long" / "short"
+	score         float64
+	price         float64
+	leverage      int
+	positionValue float64
+	stopDistance  float64 // 止损距离（价格单位）
+	regime        MarketRegime
+}
+
+// generateScoredDecision 生成带评分的候选开仓决策（不修改引擎状态）
+// 返回 nil 表示不满足开仓条件；持仓数量和相关性限制在 commitEntry 中按评分顺序检查
+func (e *BaselineEngine) generateScoredDecision(
+	symbol string,
+	data *market.Data,
+	equity float64,
+	available float64,
+) *ScoredDecision {
+	if !e.symbolAllowed(symbol) {
+		return nil
+	}
+	sig := e.evaluateEntry(symbol, data, available)
+	if sig == nil {
+		return nil
+	}
+
+	stopLossPrice := sig.price - sig.stopDistance
+	if sig.side == "short" {
+		stopLossPrice = sig.price + sig.stopDistance
+	}
+
+	return &ScoredDecision{
+		Decision: decision.Decision{
+			Symbol:          symbol,
+			Action:          "open_" + sig.side,
+			Leverage:        sig.leverage,
+			PositionSizeUSD: sig.positionValue,
+			StopLoss:        stopLossPrice,
+			TakeProfit:      0,
+			Confidence:      75,
+			Reasoning:       regimeReasoning("Baseline: Multiple "+sig.side+" signals", sig.regime),
+		},
+		Score: sig.score,
+		state: &BaselinePositionState{
+			Symbol:        symbol,
+			Side:          sig.side,
+			EntryPrice:    sig.price,
+			PeakPrice:     sig.price,
+			TrailingStop:  stopLossPrice,
+			TrailingTP:    0,
+			HardStopPrice: stopLossPrice, // 挂单止损价
+			EntryBarTime:  e.signalBarTime(data),
+		},
+	}
+}
+
+// commitEntry 为选中的候选开仓写入持仓状态
+// 同方向仓位数、相关性限制或已有同币种状态时返回 false（本周期先选中的开仓也计入限制）
+func (e *BaselineEngine) commitEntry(candidate ScoredDecision) bool {
+	pending := candidate.state
+	if pending == nil {
+		return true
+	}
+	rm := e.config.BaselineConfig.RiskManagement
+
+	// 获取同方向最大仓位数限制
+	maxSameDir := rm.MaxSameDirectionPositions
+	if maxSameDir <= 0 {
+		maxSameDir = 2 // 默认最多 2 个同方向仓位
+	}
+
+	// 检查同方向仓位数量限制
+	if e.countSameDirectionPositions(pending.Side) >= maxSameDir {
+		return false
+	}
+
+	// 相关性过滤：不与高度相关的同方向仓位叠加
+	if e.exceedsCorrelationLimit(pending.Symbol, pending.Side, rm) {
+		return false
+	}
+
+	// 检查是否已有相同币种的持仓状态
+	stateKey := pending.Symbol + "_" + pending.Side
+	if _, exists := e.positionStates[stateKey]; exists {
+		return false
+	}
+
+	state := *pending
+	state.EntryCycle = e.cycle
+	e.positionStates[stateKey] = &state
+	return true
+}
+
+// evaluateEntry 计算币种的开仓信号、评分和仓位参数
+// 返回 nil 表示信号不足；不检查持仓数量限制，也不创建持仓状态（开仓和加仓共用）
+func (e *BaselineEngine) evaluateEntry(
+	symbol string,
+	data *market.Data,
+	available float64,
+) *entrySignal {
+	if data == nil {
+		return nil
+	}
+
+	// 获取配置参数
+	baselineCfg := e.config.BaselineConfig
+	if baselineCfg == nil {
+		return nil
+	}
+
+	indicators := e.config.Indicators
+	signalTF := baselineCfg.SignalTimeframe
+	price, ema20 := e.getEMA(data, signalTF)
+
+	// 价格为 0/NaN/Inf 时各比例指标都无意义（且 NaN 会被 min 吞掉变成满分），跳过该币种
+	if !isFinite(price) || price <= 0 {
+		logger.Debugf("[Baseline] %s: invalid price %v, skip entry", symbol, price)
+		return nil
+	}
+
+	// 平仓冷却期：防止止损后立即报复性开仓
+	blockLong, blockShort := e.inCooldown(symbol, baselineCfg.SignalThresholds)
+	if blockLong && blockShort {
+		return nil
+	}
+
+	// ADX 趋势强度过滤：震荡行情（ADX 低于阈值）不开新仓
+	if indicators.EnableADX {
+		minADX := baselineCfg.SignalThresholds.MinADX
+		if minADX <= 0 {
+			minADX = 20 // 默认值
+		}
+		if adx := e.getADX(data); adx > 0 && adx < minADX {
+			logger.Debugf("[Baseline] %s: ADX %.1f < %.1f, skip entry (choppy market)", symbol, adx, minADX)
+			return nil
+		}
+	}
+
+	// 计算信号强度和评分
+	longSignals := 0
+	shortSignals := 0
+	longScore := 0.0
+	shortScore := 0.0
+
+	// 各指标评分权重（可配置，默认值即下方注释中的满分）
+	weights := baselineCfg.IndicatorWeights
+
+	// 市场状态：趋势行情放大趋势跟随信号（MACD/EMA/StochRSI），震荡行情放大均值回归信号（布林带）
+	regime := RegimeUnknown
+	if indicators.EnableRegime {
+		regime = e.detectRegime(data, baselineCfg)
+	}
+	trendScale, reversionScale := regimeScales(regime)
+
+	// MACD 不参与开仓方向判断（不计入 longSignals/shortSignals），
+	// 仅以柱状图动量加分（默认最高 10 分）：零轴上方扩张 -> 做多加分，零轴下方扩张 -> 做空加分
+	if indicators.EnableMACD {
+		if hist, prevHist, ok := e.getMACDHistogram(data, signalTF); ok {
+			macdScale := weightScale(weights.MACDWeight, 10) * trendScale
+			if hist > 0 && hist > prevHist {
+				longScore += macdMomentumScore(hist, prevHist) * macdScale
+			} else if hist < 0 && hist < prevHist {
+				shortScore += macdMomentumScore(hist, prevHist) * macdScale
+			}
+		}
+	}
+
+	// EMA 趋势信号及评分（降低权重：默认最高 20 分）
+	if indicators.EnableEMA && isFinite(ema20) && ema20 > 0 {
+		priceDiff := (price - ema20) / ema20 * 100
+		emaScale := weightScale(weights.EMAWeight, 20) * trendScale
+		if price > ema20 {
+			longSignals++
+			// 价格高于 EMA 越多，评分越高
+			longScore += min(priceDiff*5, 20) * emaScale // 最高 20 分
+		} else {
+			shortSignals++
+			// 价格低于 EMA 越多，评分越高
+			shortScore += min(-priceDiff*5, 20) * emaScale
+		}
+	}
+
+	// StochRSI 信号及评分（趋势跟随：默认最高 70 分，成为主导指标）
+	// 使用配置参数
+	stochOversold := baselineCfg.SignalThresholds.StochOversold
 	stochOverbought := baselineCfg.SignalThresholds.StochOverbought
 	if stochOversold <= 0 {
 		stochOversold = 15 // 默认值（优化：从 20 收紧到 15）
@@ -715,10 +1785,18 @@ func (e *BaselineEngine) generateScoredDecision(
 		stochOverbought = 85 // 默认值（优化：从 80 收紧到 85）
 	}
 
-	k, d := e.getStochRSI(data)
+	// 交叉确认：K 需连续 N 根 K 线位于 D 同一侧才计分，过滤单根 K 线的假交叉
+	confirmBars := baselineCfg.SignalThresholds.StochCrossConfirmBars
+	if confirmBars <= 0 {
+		confirmBars = 1 // 默认值：仅看最新一根
+	}
+	kSeries, dSeries := e.getStochRSISeriesForTF(data, signalTF)
+	k, d := e.getStochRSIForTF(data, signalTF)
+	crossHeld := stochCrossHeld(kSeries, dSeries, confirmBars)
+	stochScale := weightScale(weights.StochRSIWeight, 70) * trendScale
 	if indicators.EnableStochRSI && k > 0 && d > 0 {
 		// 做多信号：金叉且脱离超卖区（趋势确认）
-		if k > stochOversold && k > d && k < stochOverbought {
+		if k > stochOversold && crossHeld > 0 && k < stochOverbought {
 			longSignals++
 			// K 值在中间区域（20-80）且金叉，评分越高
 			// 位置评分：K值在40-60最佳，最高 35 分
@@ -730,10 +1808,10 @@ func (e *BaselineEngine) generateScoredDecision(
 			}
 			// 金叉强度评分：最高 35 分
 			crossScore := min((k-d)/20*35, 35)
-			longScore += positionScore + crossScore // 最高 70 分
+			longScore += (positionScore + crossScore) * stochScale // 最高 70 分
 		}
 		// 做空信号：死叉且脱离超买区（趋势确认）
-		if k < stochOverbought && k < d && k > stochOversold {
+		if k < stochOverbought && crossHeld < 0 && k > stochOversold {
 			shortSignals++
 			// K 值在中间区域（20-80）且死叉，评分越高
 			// 位置评分：K值在40-60最佳，最高 35 分
@@ -745,7 +1823,120 @@ func (e *BaselineEngine) generateScoredDecision(
 			}
 			// 死叉强度评分：最高 35 分
 			crossScore := min((d-k)/20*35, 35)
-			shortScore += positionScore + crossScore // 最高 70 分
+			shortScore += (positionScore + crossScore) * stochScale // 最高 70 分
+		}
+	}
+
+	// 布林带均值回归信号及评分（默认最高 20 分）
+	// 收盘价跌破下轨 -> 做多信号，突破上轨 -> 做空信号
+	if indicators.EnableBollinger {
+		period := baselineCfg.BollingerPeriod
+		if period <= 0 {
+			period = 20 // 默认值
+		}
+		stdDevMult := baselineCfg.BollingerStdDev
+		if stdDevMult <= 0 {
+			stdDevMult = 2.0 // 默认值
+		}
+		if upper, middle, lower, lastClose, ok := e.getBollingerBands(data, period, stdDevMult); ok {
+			// 标准差（用于衡量突破幅度）
+			stdDev := (upper - middle) / stdDevMult
+			bollingerScale := weightScale(weights.BollingerWeight, 20) * reversionScale
+			if lastClose < lower {
+				longSignals++
+				longScore += bollingerScore(lower-lastClose, stdDev) * bollingerScale
+			} else if lastClose > upper {
+				shortSignals++
+				shortScore += bollingerScore(lastClose-upper, stdDev) * bollingerScale
+			}
+		}
+	}
+
+	// RSI 背离信号及评分（最高 25 分）
+	// 底背离：价格创新低而 RSI 抬高 -> 做多；顶背离：价格创新高而 RSI 走低 -> 做空
+	if indicators.EnableRSIDivergence {
+		lookback := baselineCfg.RSIDivergenceLookback
+		if lookback <= 0 {
+			lookback = 14 // 默认值
+		}
+		minSwingPct := baselineCfg.RSIDivergenceMinSwingPct
+		if minSwingPct <= 0 {
+			minSwingPct = 1.0 // 默认值
+		}
+		if bullish, bearish := e.getRSIDivergence(data, signalTF, lookback, minSwingPct); bullish > 0 {
+			longSignals++
+			longScore += min(10+bullish, 25)
+		} else if bearish > 0 {
+			shortSignals++
+			shortScore += min(10+bearish, 25)
+		}
+	}
+
+	// VWAP 偏向及评分（最高 15 分）
+	// 价格低于会话 VWAP（相对便宜）-> 做多加分，高于 VWAP（相对昂贵）-> 做空加分
+	if indicators.EnableVWAP {
+		sessionHours := baselineCfg.VWAPSessionHours
+		if sessionHours <= 0 {
+			sessionHours = 24 // 默认按 UTC 自然日重置
+		}
+		if tfData := e.getTimeframeSeries(data, signalTF); tfData != nil {
+			if vwap, ok := sessionVWAP(tfData.Klines, int64(sessionHours)*3600*1000); ok {
+				distPct := (price - vwap) / vwap * 100
+				if distPct < 0 {
+					longScore += vwapBias(-distPct)
+				} else if distPct > 0 {
+					shortScore += vwapBias(distPct)
+				}
+			}
+		}
+	}
+
+	// OBV 量能方向确认（最高 10 分）：OBV 上升支持做多，OBV 下降支持做空
+	// 与成交量比值（只看量的大小）互补，衡量资金流向
+	if indicators.EnableOBV {
+		lookback := baselineCfg.OBVLookback
+		if lookback <= 0 {
+			lookback = 10 // 默认值
+		}
+		if tfData := e.getTimeframeSeries(data, signalTF); tfData != nil {
+			if slope, ok := obvSlope(tfData.Klines, lookback); ok {
+				if slope > 0 {
+					longScore += slope * 10
+				} else if slope < 0 {
+					shortScore += -slope * 10
+				}
+			}
+		}
+	}
+
+	// 一目均衡表云层信号及评分（趋势跟随：默认最高 20 分）
+	// 价格位于云层上方且转换线近期上穿基准线 -> 做多信号，价格位于云层下方且近期下穿 -> 做空信号
+	if indicators.EnableIchimoku {
+		tenkanPeriod := baselineCfg.IchimokuTenkanPeriod
+		if tenkanPeriod <= 0 {
+			tenkanPeriod = 9 // 默认值
+		}
+		kijunPeriod := baselineCfg.IchimokuKijunPeriod
+		if kijunPeriod <= 0 {
+			kijunPeriod = 26 // 默认值
+		}
+		senkouBPeriod := baselineCfg.IchimokuSenkouBPeriod
+		if senkouBPeriod <= 0 {
+			senkouBPeriod = 52 // 默认值
+		}
+		if tfData := e.getTimeframeSeries(data, signalTF); tfData != nil {
+			if cloud, ok := ichimoku(tfData.Klines, tenkanPeriod, kijunPeriod, senkouBPeriod); ok {
+				// 云层越厚，支撑/阻力越可靠
+				thicknessPct := (cloud.top - cloud.bottom) / price * 100
+				ichimokuScale := weightScale(weights.IchimokuWeight, 20) * trendScale
+				if price > cloud.top && cloud.cross > 0 {
+					longSignals++
+					longScore += ichimokuScore(thicknessPct) * ichimokuScale
+				} else if price < cloud.bottom && cloud.cross < 0 {
+					shortSignals++
+					shortScore += ichimokuScore(thicknessPct) * ichimokuScale
+				}
+			}
 		}
 	}
 
@@ -754,23 +1945,31 @@ func (e *BaselineEngine) generateScoredDecision(
 	if indicators.EnableVolume {
 		volumeRatio := e.getVolumeRatio(data)
 		if volumeRatio > 0 {
-			// 成交量调整系数：0.7 ~ 1.3
+			// 成交量调整系数：minMult ~ maxMult（默认 0.7 ~ 1.2），中间档位取与 1.0 的中点
+			minMult := weights.VolumeMultiplierCaps.Min
+			if minMult <= 0 {
+				minMult = 0.7
+			}
+			maxMult := weights.VolumeMultiplierCaps.Max
+			if maxMult <= 0 {
+				maxMult = 1.2
+			}
 			var volumeMultiplier float64
 			if volumeRatio < 0.5 {
 				// 成交量过低（<50% 平均值），大幅降低评分
-				volumeMultiplier = 0.7
+				volumeMultiplier = minMult
 			} else if volumeRatio < 0.8 {
 				// 成交量偏低（50%-80% 平均值），适度降低评分
-				volumeMultiplier = 0.85
+				volumeMultiplier = (1 + minMult) / 2
 			} else if volumeRatio > 2.0 {
 				// 成交量异常高（>200% 平均值），可能是异常波动，不加分
 				volumeMultiplier = 1.0
 			} else if volumeRatio > 1.5 {
 				// 成交量较高（150%-200% 平均值），增加评分
-				volumeMultiplier = 1.2
+				volumeMultiplier = maxMult
 			} else if volumeRatio > 1.2 {
 				// 成交量略高（120%-150% 平均值），略微增加评分
-				volumeMultiplier = 1.1
+				volumeMultiplier = (1 + maxMult) / 2
 			} else {
 				// 正常成交量（80%-120% 平均值）
 				volumeMultiplier = 1.0
@@ -787,10 +1986,13 @@ func (e *BaselineEngine) generateScoredDecision(
 	}
 
 	// 计算仓位参数
-	leverage := baselineCfg.RiskManagement.Leverage
-	if leverage <= 0 {
-		leverage = 5
+	leverage := e.resolveLeverage(symbol)
+
+	// 最小预期收益：预期波动不足以覆盖往返手续费和滑点时不开仓
+	if !e.hasMinimumEdge(baselineCfg.RiskManagement, leverage) {
+		return nil
 	}
+
 	maxPos := e.config.RiskControl.MaxPositions
 	if maxPos <= 0 {
 		maxPos = 3
@@ -805,91 +2007,428 @@ func (e *BaselineEngine) generateScoredDecision(
 		hardStopLossPct = 3.0 // 默认 -3.0%
 	}
 
-	// 获取同方向最大仓位数限制
-	maxSameDir := baselineCfg.RiskManagement.MaxSameDirectionPositions
-	if maxSameDir <= 0 {
-		maxSameDir = 2 // 默认最多 2 个同方向仓位
+	// 多周期确认：高周期 EMA 趋势必须与信号方向一致
+	if baselineCfg.RequireHigherTFAgreement && (longSignals >= minSignals || shortSignals >= minSignals) {
+		higherTF := baselineCfg.HigherTimeframe
+		if higherTF == "" {
+			higherTF = "4h" // 默认值
+		}
+		trend := e.getEMATrend(data, higherTF)
+		if trend <= 0 {
+			longSignals = 0 // 高周期非上升趋势，禁止做多
+		}
+		if trend >= 0 {
+			shortSignals = 0 // 高周期非下降趋势，禁止做空
+		}
 	}
 
-	// 生成做多决策
-	if longSignals >= minSignals && longScore > 0 {
-		// 检查同方向仓位数量限制
-		if e.countSameDirectionPositions("long") >= maxSameDir {
-			return nil
+	if blockLong {
+		longSignals = 0
+	}
+	if blockShort {
+		shortSignals = 0
+	}
+
+	// 退化指标数据产生的 NaN/Inf 评分视为无信号，避免污染候选排序
+	if !isFinite(longScore) {
+		longSignals = 0
+	}
+	if !isFinite(shortScore) {
+		shortSignals = 0
+	}
+
+	// 选择开仓方向（做多优先）
+	var sig entrySignal
+	switch {
+	case longSignals >= minSignals && longScore > 0:
+		sig.side, sig.score = "long", longScore
+	case shortSignals >= minSignals && shortScore > 0:
+		sig.side, sig.score = "short", shortScore
+	default:
+		return nil
+	}
+	sig.price = price
+	sig.leverage = leverage
+	sig.positionValue = positionValue
+	sig.stopDistance = e.stopDistance(data, price, hardStopLossPct, baselineCfg.RiskManagement)
+	if !isFinite(sig.stopDistance) || sig.stopDistance <= 0 {
+		return nil // ATR 异常，无法计算止损价
+	}
+	sig.regime = regime
+	return &sig
+}
+
+// getBollingerBands 基于 K 线收盘价计算布林带
+// 返回上轨、中轨、下轨和最新收盘价，K 线不足时 ok 为 false
+func (e *BaselineEngine) getBollingerBands(data *market.Data, period int, stdDevMult float64) (upper, middle, lower, lastClose float64, ok bool) {
+	if data.TimeframeData == nil {
+		return 0, 0, 0, 0, false
+	}
+
+	for _, tfData := range sortedTimeframeData(data) {
+		if len(tfData.Klines) < period {
+			continue
 		}
 
-		// 检查是否已有相同币种的持仓状态
-		stateKey := symbol + "_long"
-		if _, exists := e.positionStates[stateKey]; exists {
-			return nil
+		closes := tfData.Klines[len(tfData.Klines)-period:]
+		var sum float64
+		for _, k := range closes {
+			sum += k.Close
 		}
+		middle = sum / float64(period)
 
-		stopLossPrice := price * (1 - hardStopLossPct/100)
-		e.positionStates[stateKey] = &BaselinePositionState{
-			Symbol:        symbol,
-			Side:          "long",
-			EntryPrice:    price,
-			PeakPrice:     price,
-			TrailingStop:  stopLossPrice,
-			TrailingTP:    0,
-			HardStopPrice: stopLossPrice, // 挂单止损价
+		var variance float64
+		for _, k := range closes {
+			variance += (k.Close - middle) * (k.Close - middle)
 		}
+		stdDev := math.Sqrt(variance / float64(period))
 
-		return &ScoredDecision{
-			Decision: decision.Decision{
-				Symbol:          symbol,
-				Action:          "open_long",
-				Leverage:        leverage,
-				PositionSizeUSD: positionValue,
-				StopLoss:        stopLossPrice,
-				TakeProfit:      0,
-				Confidence:      75,
-				Reasoning:       "Baseline: Multiple long signals",
-			},
-			Score: longScore,
+		upper = middle + stdDevMult*stdDev
+		lower = middle - stdDevMult*stdDev
+		lastClose = closes[len(closes)-1].Close
+		return upper, middle, lower, lastClose, true
+	}
+
+	return 0, 0, 0, 0, false
+}
+
+// getRSIDivergence 检测 RSI 背离
+// 在最近 lookback 根 K 线（不含最新一根）中找到前一个最低/最高收盘价，
+// 最新收盘价需超出该位置至少 minSwingPct%，且 RSI 方向相反才算背离
+// 返回底背离/顶背离的 RSI 差值（点数），0 表示无背离
+func (e *BaselineEngine) getRSIDivergence(data *market.Data, timeframe string, lookback int, minSwingPct float64) (bullish, bearish float64) {
+	tfData := e.getTimeframeSeries(data, timeframe)
+	if tfData == nil {
+		return 0, 0
+	}
+
+	rsiValues := tfData.RSI14Values
+	if len(rsiValues) == 0 {
+		rsiValues = tfData.RSI7Values
+	}
+
+	// K 线与 RSI 序列按末尾对齐
+	n := lookback + 1
+	if len(tfData.Klines) < n {
+		n = len(tfData.Klines)
+	}
+	if len(rsiValues) < n {
+		n = len(rsiValues)
+	}
+	if n < 3 {
+		return 0, 0
+	}
+	klines := tfData.Klines[len(tfData.Klines)-n:]
+	rsi := rsiValues[len(rsiValues)-n:]
+
+	lastClose := klines[n-1].Close
+	lastRSI := rsi[n-1]
+
+	lowIdx, highIdx := 0, 0
+	for i := 1; i < n-1; i++ {
+		if klines[i].Close < klines[lowIdx].Close {
+			lowIdx = i
+		}
+		if klines[i].Close > klines[highIdx].Close {
+			highIdx = i
 		}
 	}
 
-	// 生成做空决策
-	if shortSignals >= minSignals && shortScore > 0 {
-		// 检查同方向仓位数量限制
-		if e.countSameDirectionPositions("short") >= maxSameDir {
-			return nil
+	prevLow := klines[lowIdx].Close
+	if prevLow > 0 && lastClose < prevLow*(1-minSwingPct/100) && lastRSI > rsi[lowIdx] {
+		bullish = lastRSI - rsi[lowIdx]
+	}
+	prevHigh := klines[highIdx].Close
+	if prevHigh > 0 && lastClose > prevHigh*(1+minSwingPct/100) && lastRSI < rsi[highIdx] {
+		bearish = rsi[highIdx] - lastRSI
+	}
+	return bullish, bearish
+}
+
+// getMACDHistogram 获取最新两根 K 线的 MACD 柱状图（MACD − 信号线）
+// timeframe 为空时使用排序后第一个有 MACD 数据的周期；MACD 与信号线序列按末尾对齐，信号线尚未形成时 ok 为 false
+func (e *BaselineEngine) getMACDHistogram(data *market.Data, timeframe string) (hist, prevHist float64, ok bool) {
+	if data.TimeframeData == nil {
+		return 0, 0, false
+	}
+	histogram := func(tfData *market.TimeframeSeriesData) (float64, float64, bool) {
+		if tfData == nil || len(tfData.MACDValues) < 2 || len(tfData.MACDSignal) < 2 {
+			return 0, 0, false
+		}
+		macd, signal := tfData.MACDValues, tfData.MACDSignal
+		lastSignal, prevSignal := signal[len(signal)-1], signal[len(signal)-2]
+		if lastSignal == 0 || prevSignal == 0 {
+			return 0, 0, false
+		}
+		return macd[len(macd)-1] - lastSignal, macd[len(macd)-2] - prevSignal, true
+	}
+	if timeframe != "" {
+		return histogram(data.TimeframeData[timeframe])
+	}
+	for _, tfData := range sortedTimeframeData(data) {
+		if hist, prevHist, ok = histogram(tfData); ok {
+			return hist, prevHist, true
 		}
+	}
+	return 0, 0, false
+}
 
-		// 检查是否已有相同币种的持仓状态
-		stateKey := symbol + "_short"
-		if _, exists := e.positionStates[stateKey]; exists {
-			return nil
+// macdMomentumScore MACD 柱状图动量评分：按本根扩张量占柱状图的比例计分，
+// 从零轴另一侧穿越过来时得满分 10 分
+func macdMomentumScore(hist, prevHist float64) float64 {
+	expansion := math.Abs(hist-prevHist) / math.Abs(hist)
+	return min(expansion, 1) * 10
+}
+
+// getTimeframeSeries 获取指定周期的序列数据
+// timeframe 为空时使用排序后第一个有 K 线数据的周期
+func (e *BaselineEngine) getTimeframeSeries(data *market.Data, timeframe string) *market.TimeframeSeriesData {
+	if data.TimeframeData == nil {
+		return nil
+	}
+	if timeframe != "" {
+		return data.TimeframeData[timeframe]
+	}
+	for _, tfData := range sortedTimeframeData(data) {
+		if len(tfData.Klines) > 0 {
+			return tfData
 		}
+	}
+	return nil
+}
 
-		stopLossPrice := price * (1 + hardStopLossPct/100)
-		e.positionStates[stateKey] = &BaselinePositionState{
-			Symbol:        symbol,
-			Side:          "short",
-			EntryPrice:    price,
-			PeakPrice:     price,
-			TrailingStop:  stopLossPrice,
-			TrailingTP:    0,
-			HardStopPrice: stopLossPrice, // 挂单止损价
+// signalBarTime 返回信号周期最新一根 K 线的时间（毫秒），没有 K 线时为 0
+func (e *BaselineEngine) signalBarTime(data *market.Data) int64 {
+	if data == nil || e.config.BaselineConfig == nil {
+		return 0
+	}
+	tfData := e.getTimeframeSeries(data, e.config.BaselineConfig.SignalTimeframe)
+	if tfData == nil || len(tfData.Klines) == 0 {
+		return 0
+	}
+	return tfData.Klines[len(tfData.Klines)-1].Time
+}
+
+// psarReversed 判断信号周期的抛物线 SAR 趋势是否与持仓方向相反，且最近一次翻转发生在开仓之后
+// 按翻转所在 K 线与开仓时 K 线的时间比较，决策节奏大于 1 或跳过 K 线时错过翻转那根 K 线也会触发；
+// 开仓时 SAR 趋势本就与持仓方向相反的不触发，避免开仓后立即被平
+func (e *BaselineEngine) psarReversed(data *market.Data, cfg *store.BaselineConfig, state *BaselinePositionState) bool {
+	tfData := e.getTimeframeSeries(data, cfg.SignalTimeframe)
+	if tfData == nil {
+		return false
+	}
+	step := cfg.PSARAcceleration
+	if step <= 0 {
+		step = 0.02 // 默认值
+	}
+	maxStep := cfg.PSARMaxAcceleration
+	if maxStep <= 0 {
+		maxStep = 0.2 // 默认值
+	}
+	_, uptrend := parabolicSAR(tfData.Klines, step, math.Max(step, maxStep))
+	last := len(uptrend) - 1
+	if last < 1 || uptrend[last] == (state.Side == "long") {
+		return false
+	}
+	flip := last
+	for flip > 0 && uptrend[flip-1] == uptrend[last] {
+		flip--
+	}
+	return flip > 0 && tfData.Klines[flip].Time > state.EntryBarTime
+}
+
+// parabolicSAR 计算 Wilder 抛物线 SAR 序列及每根 K 线的趋势方向（true 为上升趋势）
+// 初始趋势由前两根收盘价决定；每创新极值加速因子增加 step，最高 maxStep；
+// 价格穿越 SAR 时趋势翻转，SAR 重置为前一段趋势的极值。K 线少于 2 根时返回 nil
+func parabolicSAR(klines []market.KlineBar, step, maxStep float64) (sar []float64, uptrend []bool) {
+	if len(klines) < 2 {
+		return nil, nil
+	}
+	sar = make([]float64, len(klines))
+	uptrend = make([]bool, len(klines))
+
+	up := klines[1].Close >= klines[0].Close
+	af := step
+	var ep float64
+	if up {
+		sar[1] = klines[0].Low
+		ep = math.Max(klines[0].High, klines[1].High)
+	} else {
+		sar[1] = klines[0].High
+		ep = math.Min(klines[0].Low, klines[1].Low)
+	}
+	sar[0], uptrend[0], uptrend[1] = sar[1], up, up
+
+	for i := 2; i < len(klines); i++ {
+		k := klines[i]
+		next := sar[i-1] + af*(ep-sar[i-1])
+		if up {
+			// SAR 不得高于前两根 K 线的最低价
+			next = math.Min(next, math.Min(klines[i-1].Low, klines[i-2].Low))
+			if k.Low < next {
+				up, next, ep, af = false, ep, k.Low, step
+			} else if k.High > ep {
+				ep, af = k.High, math.Min(af+step, maxStep)
+			}
+		} else {
+			// SAR 不得低于前两根 K 线的最高价
+			next = math.Max(next, math.Max(klines[i-1].High, klines[i-2].High))
+			if k.High > next {
+				up, next, ep, af = true, ep, k.High, step
+			} else if k.Low < ep {
+				ep, af = k.Low, math.Min(af+step, maxStep)
+			}
 		}
+		sar[i], uptrend[i] = next, up
+	}
+	return sar, uptrend
+}
+
+// sessionVWAP 计算最新 K 线所在会话的成交量加权均价（典型价 (H+L+C)/3 × 成交量）
+// 会话起点按 sessionMs 对齐 Unix 时间，会话内没有成交量时 ok 为 false
+func sessionVWAP(klines []market.KlineBar, sessionMs int64) (vwap float64, ok bool) {
+	if len(klines) == 0 || sessionMs <= 0 {
+		return 0, false
+	}
+	last := klines[len(klines)-1].Time
+	sessionStart := last - last%sessionMs
 
-		return &ScoredDecision{
-			Decision: decision.Decision{
-				Symbol:          symbol,
-				Action:          "open_short",
-				Leverage:        leverage,
-				PositionSizeUSD: positionValue,
-				StopLoss:        stopLossPrice,
-				TakeProfit:      0,
-				Confidence:      75,
-				Reasoning:       "Baseline: Multiple short signals",
-			},
-			Score: shortScore,
+	var pv, volume float64
+	for i := len(klines) - 1; i >= 0 && klines[i].Time >= sessionStart; i-- {
+		k := klines[i]
+		pv += (k.High + k.Low + k.Close) / 3 * k.Volume
+		volume += k.Volume
+	}
+	if volume <= 0 || !isFinite(pv/volume) {
+		return 0, false
+	}
+	return pv / volume, true
+}
+
+// onBalanceVolume 计算 OBV 序列：收盘价上涨累加成交量，下跌扣减成交量，持平不变
+func onBalanceVolume(klines []market.KlineBar) []float64 {
+	obv := make([]float64, len(klines))
+	for i := 1; i < len(klines); i++ {
+		switch {
+		case klines[i].Close > klines[i-1].Close:
+			obv[i] = obv[i-1] + klines[i].Volume
+		case klines[i].Close < klines[i-1].Close:
+			obv[i] = obv[i-1] - klines[i].Volume
+		default:
+			obv[i] = obv[i-1]
 		}
 	}
+	return obv
+}
 
-	return nil
+// obvSlope 计算最近 lookback 根 K 线的 OBV 变化占同期总成交量的比例（-1 ~ 1）
+// K 线不足或同期无成交量时 ok 为 false
+func obvSlope(klines []market.KlineBar, lookback int) (slope float64, ok bool) {
+	if lookback <= 0 || len(klines) <= lookback {
+		return 0, false
+	}
+	obv := onBalanceVolume(klines)
+	last := len(klines) - 1
+
+	var volume float64
+	for i := last - lookback + 1; i <= last; i++ {
+		volume += klines[i].Volume
+	}
+	if volume <= 0 {
+		return 0, false
+	}
+	return (obv[last] - obv[last-lookback]) / volume, true
+}
+
+// ichimokuCloud 最新 K 线的一目均衡表状态
+type ichimokuCloud struct {
+	tenkan float64 // 转换线：tenkan 周期最高价与最低价的中点
+	kijun  float64 // 基准线：kijun 周期最高价与最低价的中点
+	top    float64 // 当前云层上沿（kijun 根 K 线前计算的先行带 A/B 中较高者）
+	bottom float64 // 当前云层下沿
+	cross  int     // 转换线在最近 tenkan 根 K 线内上穿（1）/下穿（-1）基准线且保持至今，否则 0
+}
+
+// ichimoku 计算最新 K 线的一目均衡表：先行带 A 为转换线与基准线的中点、先行带 B 为 senkouB 周期中点，
+// 均向前平移 kijun 根 K 线，因此当前云层取 kijun 根 K 线前的值。K 线不足 kijun+senkouB 根时 ok 为 false
+func ichimoku(klines []market.KlineBar, tenkanPeriod, kijunPeriod, senkouBPeriod int) (cloud ichimokuCloud, ok bool) {
+	if tenkanPeriod <= 0 || kijunPeriod <= 0 || senkouBPeriod <= 0 || len(klines) < kijunPeriod+senkouBPeriod {
+		return cloud, false
+	}
+	// midpoint 以 end 结尾的 period 根 K 线最高价与最低价的中点
+	midpoint := func(end, period int) float64 {
+		high, low := klines[end].High, klines[end].Low
+		for i := end - period + 1; i < end; i++ {
+			high = max(high, klines[i].High)
+			low = min(low, klines[i].Low)
+		}
+		return (high + low) / 2
+	}
+	tkSide := func(i int) int {
+		diff := midpoint(i, tenkanPeriod) - midpoint(i, kijunPeriod)
+		switch {
+		case diff > 0:
+			return 1
+		case diff < 0:
+			return -1
+		}
+		return 0
+	}
+
+	last := len(klines) - 1
+	cloud.tenkan = midpoint(last, tenkanPeriod)
+	cloud.kijun = midpoint(last, kijunPeriod)
+
+	shifted := last - kijunPeriod
+	spanA := (midpoint(shifted, tenkanPeriod) + midpoint(shifted, kijunPeriod)) / 2
+	spanB := midpoint(shifted, senkouBPeriod)
+	cloud.top, cloud.bottom = max(spanA, spanB), min(spanA, spanB)
+
+	// 向前查找转换线与基准线的相对位置何时改变
+	if side := tkSide(last); side != 0 {
+		earliest := max(last-tenkanPeriod, max(tenkanPeriod, kijunPeriod)-1)
+		for i := last - 1; i >= earliest; i-- {
+			if tkSide(i) != side {
+				cloud.cross = side
+				break
+			}
+		}
+	}
+	if !isFinite(cloud.top) || !isFinite(cloud.bottom) {
+		return cloud, false
+	}
+	return cloud, true
+}
+
+// ichimokuScore 云层信号评分：满足条件即得 10 分，云层厚度每占价格 1% 再加 2 分，最高 20 分
+func ichimokuScore(thicknessPct float64) float64 {
+	return min(10+thicknessPct*2, 20)
+}
+
+// vwapBias VWAP 偏离评分：偏离即得 5 分，每偏离 1% 再加 5 分，最高 15 分
+func vwapBias(distPct float64) float64 {
+	return min(5+distPct*5, 15)
+}
+
+// weightScale 计算配置权重相对默认满分的缩放比例，未配置（<= 0）时为 1
+func weightScale(weight, defaultWeight float64) float64 {
+	if weight <= 0 {
+		return 1
+	}
+	return weight / defaultWeight
+}
+
+// bollingerScore 布林带突破评分：突破即得 10 分，每超出 1 个标准差再加 10 分，最高 20 分
+func bollingerScore(penetration, stdDev float64) float64 {
+	if stdDev <= 0 {
+		return 10
+	}
+	return min(10+penetration/stdDev*10, 20)
+}
+
+// isFinite 判断数值既不是 NaN 也不是 ±Inf
+func isFinite(v float64) bool {
+	return !math.IsNaN(v) && !math.IsInf(v, 0)
 }
 
 func min(a, b float64) float64 {
@@ -899,7 +2438,7 @@ func min(a, b float64) float64 {
 	return b
 }
 
-// selectBestDecisions 根据评分筛选最优的开仓决策
+// selectBestDecisions 根据评分筛选最优的开仓决策，并只为选中的决策写入持仓状态
 // currentPositions: 当前持仓数量
 // 返回: 筛选后的决策列表（不超过 max_positions 限制）
 func (e *BaselineEngine) selectBestDecisions(
@@ -920,28 +2459,31 @@ func (e *BaselineEngine) selectBestDecisions(
 		return []decision.Decision{}
 	}
 
-	// 按评分从高到低排序
-	sortedCandidates := make([]ScoredDecision, len(candidates))
-	copy(sortedCandidates, candidates)
-
-	// 简单的冒泡排序（因为候选数量通常不多）
-	for i := 0; i < len(sortedCandidates)-1; i++ {
-		for j := 0; j < len(sortedCandidates)-i-1; j++ {
-			if sortedCandidates[j].Score < sortedCandidates[j+1].Score {
-				sortedCandidates[j], sortedCandidates[j+1] = sortedCandidates[j+1], sortedCandidates[j]
-			}
+	// 按评分从高到低排序，评分相同时按币种字母序（保证结果确定）
+	// NaN 评分会破坏排序的比较关系，先剔除非有限评分的候选
+	sortedCandidates := make([]ScoredDecision, 0, len(candidates))
+	for _, candidate := range candidates {
+		if isFinite(candidate.Score) {
+			sortedCandidates = append(sortedCandidates, candidate)
 		}
 	}
-
-	// 选择评分最高的前 N 个决策
-	selectedCount := availableSlots
-	if len(sortedCandidates) < selectedCount {
-		selectedCount = len(sortedCandidates)
-	}
-
-	result := make([]decision.Decision, selectedCount)
-	for i := 0; i < selectedCount; i++ {
-		result[i] = sortedCandidates[i].Decision
+	sort.SliceStable(sortedCandidates, func(i, j int) bool {
+		if sortedCandidates[i].Score != sortedCandidates[j].Score {
+			return sortedCandidates[i].Score > sortedCandidates[j].Score
+		}
+		return sortedCandidates[i].Decision.Symbol < sortedCandidates[j].Decision.Symbol
+	})
+
+	// 按评分顺序选择前 N 个决策，跳过超出同方向/相关性限制的候选
+	result := make([]decision.Decision, 0, availableSlots)
+	for _, candidate := range sortedCandidates {
+		if len(result) >= availableSlots {
+			break
+		}
+		if !e.commitEntry(candidate) {
+			continue
+		}
+		result = append(result, candidate.Decision)
 	}
 
 	return result
@@ -969,7 +2511,7 @@ func (e *BaselineEngine) CheckPendingStopLoss(
 		}
 
 		// 获取当前 bar 的 OHLC 数据
-		barLow, barHigh := e.getCurrentBarOHLC(data)
+		barOpen, barLow, barHigh := e.getCurrentBarOHLC(data)
 		if barLow <= 0 || barHigh <= 0 {
 			logger.Debugf("[Baseline] %s: OHLC not available (low=%.2f, high=%.2f)", pos.Symbol, barLow, barHigh)
 			continue
@@ -978,58 +2520,102 @@ func (e *BaselineEngine) CheckPendingStopLoss(
 		logger.Debugf("[Baseline] %s %s: entry=%.4f, stopPrice=%.4f, barHigh=%.4f, barLow=%.4f",
 			pos.Symbol, pos.Side, state.EntryPrice, state.HardStopPrice, barHigh, barLow)
 
-		triggered := false
-		action := ""
-
+		// 多头检查 bar 最低价是否触及止损价、最高价是否触及止盈目标；空头相反
+		action := "close_long"
+		stopHit, targetHit := barLow <= state.HardStopPrice, false
+		target := fixedTakeProfitPrice(pos, e.config.BaselineConfig)
 		if pos.Side == "long" {
-			// 多头：检查 bar 最低价是否触及止损价
-			if barLow <= state.HardStopPrice {
-				triggered = true
-				action = "close_long"
-			}
+			targetHit = target > 0 && barHigh >= target
 		} else {
-			// 空头：检查 bar 最高价是否触及止损价
-			if barHigh >= state.HardStopPrice {
-				triggered = true
-				action = "close_short"
-			}
+			action = "close_short"
+			stopHit = barHigh >= state.HardStopPrice
+			targetHit = target > 0 && barLow <= target
+		}
+		if !stopHit {
+			continue
 		}
 
-		if triggered {
-			stopDecisions = append(stopDecisions, decision.Decision{
-				Symbol:    pos.Symbol,
-				Action:    action,
-				Reasoning: "Baseline: Pending stop loss triggered (OHLC)",
-			})
-			// 清除持仓状态
-			delete(e.positionStates, stateKey)
+		// 同一根 bar 同时触及止损和止盈目标时，按配置的 bar 内顺序假设决定先成交的一方，并按该挂单价格成交
+		dec := decision.Decision{
+			Symbol:     pos.Symbol,
+			Action:     action,
+			Reasoning:  "Baseline: Pending stop loss triggered (OHLC)",
+			ExitReason: decision.ExitReasonPendingOHLCStop,
+			ExitPrice:  restingOrderFillPrice(pos.Side, state.HardStopPrice, barOpen, true),
+		}
+		if targetHit && e.targetFillsFirst(barOpen, state.HardStopPrice, target) {
+			dec.Reasoning = fmt.Sprintf("Baseline: Fixed take profit target %.4f hit before the pending stop %.4f (OHLC)", target, state.HardStopPrice)
+			dec.ExitReason = decision.ExitReasonTakeProfit
+			dec.ExitPrice = restingOrderFillPrice(pos.Side, target, barOpen, false)
 		}
+		stopDecisions = append(stopDecisions, dec)
+		// 清除持仓状态
+		delete(e.positionStates, stateKey)
+		e.recordExit(pos.Symbol, pos.Side)
 	}
 
 	return stopDecisions
 }
 
-// getCurrentBarOHLC 获取当前 bar 的最低价和最高价
-func (e *BaselineEngine) getCurrentBarOHLC(data *market.Data) (low, high float64) {
+// fixedTakeProfitPrice 返回固定止盈目标对应的价格，未启用固定止盈时为 0
+// FixedTakeProfitPct 按保证金收益率计算，价格变动需除以杠杆
+func fixedTakeProfitPrice(pos decision.PositionInfo, cfg *store.BaselineConfig) float64 {
+	if cfg == nil || cfg.RiskManagement.FixedTakeProfitPct <= 0 || pos.EntryPrice <= 0 {
+		return 0
+	}
+	move := cfg.RiskManagement.FixedTakeProfitPct / 100 / float64(max(pos.Leverage, 1))
+	if pos.Side == "long" {
+		return pos.EntryPrice * (1 + move)
+	}
+	return pos.EntryPrice * (1 - move)
+}
+
+// restingOrderFillPrice 返回 bar 内触发的平仓挂单（止损或止盈）的成交价
+// 开盘价已越过挂单价格（跳空）时按开盘价成交：止损成交更差，止盈成交更好
+func restingOrderFillPrice(side string, level, barOpen float64, isStop bool) float64 {
+	if barOpen <= 0 {
+		return level
+	}
+	// 多头止损、空头止盈在价格下跌时触发，其余在价格上涨时触发
+	if (side == "long") == isStop {
+		return math.Min(level, barOpen)
+	}
+	return math.Max(level, barOpen)
+}
+
+// targetFillsFirst 按 IntrabarOrder 判断 bar 内止盈目标是否先于止损成交
+// 悲观（默认）：止损先成交；乐观：止盈先成交；开盘价就近：离开盘价更近的一方先成交，
+// 距离相同或开盘价未知时按悲观处理
+func (e *BaselineEngine) targetFillsFirst(barOpen, stop, target float64) bool {
+	switch e.config.BaselineConfig.RiskManagement.IntrabarOrder {
+	case store.IntrabarOrderOptimistic:
+		return true
+	case store.IntrabarOrderOpenRelative:
+		return barOpen > 0 && math.Abs(target-barOpen) < math.Abs(stop-barOpen)
+	default:
+		return false
+	}
+}
+
+// getCurrentBarOHLC 获取当前 bar 的开盘价、最低价和最高价（开盘价未知时为 0）
+func (e *BaselineEngine) getCurrentBarOHLC(data *market.Data) (open, low, high float64) {
 	// 直接使用 market.Data 中的 OHLC 数据
 	// 这些数据来自 BuildDataFromKlines，代表当前 bar 的真实 OHLC
 	if data.Low > 0 && data.High > 0 {
-		return data.Low, data.High
+		return data.Open, data.Low, data.High
 	}
 
 	// 如果主数据没有，尝试从 TimeframeData 获取
-	if data.TimeframeData != nil {
-		for _, tfData := range data.TimeframeData {
-			if len(tfData.Klines) == 0 {
-				continue
-			}
-			// 获取最新一根 K 线
-			lastBar := tfData.Klines[len(tfData.Klines)-1]
-			if lastBar.Low > 0 && lastBar.High > 0 {
-				return lastBar.Low, lastBar.High
-			}
+	for _, tfData := range sortedTimeframeData(data) {
+		if len(tfData.Klines) == 0 {
+			continue
+		}
+		// 获取最新一根 K 线
+		lastBar := tfData.Klines[len(tfData.Klines)-1]
+		if lastBar.Low > 0 && lastBar.High > 0 {
+			return lastBar.Open, lastBar.Low, lastBar.High
 		}
 	}
 
-	return 0, 0
+	return 0, 0, 0
 }
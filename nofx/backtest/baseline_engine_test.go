@@ -0,0 +1,1854 @@
+package backtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"nofx/decision"
+	"nofx/market"
+	"nofx/store"
+)
+
+// newTestBaselineEngine creates an engine with EMA + StochRSI enabled and a two-signal entry threshold
+func newTestBaselineEngine(mutate func(cfg *store.StrategyConfig)) *BaselineEngine {
+	cfg := &store.StrategyConfig{
+		Indicators: store.IndicatorConfig{
+			EnableEMA:      true,
+			EnableStochRSI: true,
+		},
+		RiskControl: store.RiskControlConfig{
+			MaxPositions: 3,
+		},
+		BaselineConfig: &store.BaselineConfig{
+			SignalThresholds: store.BaselineSignalThresholds{
+				MinSignalCount: 2,
+			},
+		},
+	}
+	if mutate != nil {
+		mutate(cfg)
+	}
+	return NewBaselineEngine(cfg)
+}
+
+// longSetupData builds market data where price is above EMA20 and StochRSI has a golden cross
+func longSetupData(symbol string) *market.Data {
+	return &market.Data{
+		Symbol:       symbol,
+		CurrentPrice: 102,
+		CurrentEMA20: 100,
+		TimeframeData: map[string]*market.TimeframeSeriesData{
+			"1h": {
+				Timeframe:  "1h",
+				StochRSI_K: []float64{50},
+				StochRSI_D: []float64{40},
+			},
+		},
+	}
+}
+
+func TestGenerateScoredDecision_ADXFilter(t *testing.T) {
+	engine := newTestBaselineEngine(func(cfg *store.StrategyConfig) {
+		cfg.Indicators.EnableADX = true
+		cfg.BaselineConfig.SignalThresholds.MinADX = 25
+	})
+
+	choppy := longSetupData("BTCUSDT")
+	choppy.TimeframeData["1h"].ADX14 = 15
+	if dec := engine.generateScoredDecision("BTCUSDT", choppy, 1000, 1000); dec != nil {
+		t.Fatalf("expected entry to be suppressed when ADX is below threshold, got %+v", dec.Decision)
+	}
+
+	trending := longSetupData("ETHUSDT")
+	trending.TimeframeData["1h"].ADX14 = 35
+	dec := engine.generateScoredDecision("ETHUSDT", trending, 1000, 1000)
+	if dec == nil {
+		t.Fatal("expected entry when ADX is above threshold")
+	}
+	if dec.Decision.Action != "open_long" {
+		t.Errorf("Action = %s, expected open_long", dec.Decision.Action)
+	}
+}
+
+func TestGenerateScoredDecision_ADXFilterDisabled(t *testing.T) {
+	engine := newTestBaselineEngine(func(cfg *store.StrategyConfig) {
+		cfg.BaselineConfig.SignalThresholds.MinADX = 25
+	})
+
+	data := longSetupData("BTCUSDT")
+	data.TimeframeData["1h"].ADX14 = 10
+	if dec := engine.generateScoredDecision("BTCUSDT", data, 1000, 1000); dec == nil {
+		t.Fatal("expected ADX to be ignored when EnableADX is off")
+	}
+}
+
+// klinesWithLastClose builds flat klines at 100 with the final bar closing at lastClose
+func klinesWithLastClose(count int, lastClose float64) []market.KlineBar {
+	klines := make([]market.KlineBar, count)
+	for i := range klines {
+		klines[i] = market.KlineBar{Open: 100, High: 101, Low: 99, Close: 100, Volume: 1000}
+	}
+	klines[count-1].Close = lastClose
+	return klines
+}
+
+func TestGenerateScoredDecision_BollingerSignal(t *testing.T) {
+	newData := func() *market.Data {
+		data := longSetupData("BTCUSDT")
+		data.CurrentPrice = 90
+		data.CurrentEMA20 = 85
+		data.TimeframeData["1h"].Klines = klinesWithLastClose(20, 90)
+		return data
+	}
+
+	plain := newTestBaselineEngine(nil).generateScoredDecision("BTCUSDT", newData(), 1000, 1000)
+	if plain == nil {
+		t.Fatal("expected long entry without Bollinger")
+	}
+
+	engine := newTestBaselineEngine(func(cfg *store.StrategyConfig) {
+		cfg.Indicators.EnableBollinger = true
+	})
+	withBB := engine.generateScoredDecision("BTCUSDT", newData(), 1000, 1000)
+	if withBB == nil {
+		t.Fatal("expected long entry with Bollinger")
+	}
+
+	// closes: 19×100 + 90 -> mean 99.5, std ≈ 2.18, lower band ≈ 95.14
+	// penetration ≈ 2.36 std devs -> contribution capped at 20
+	if diff := withBB.Score - plain.Score; math.Abs(diff-20) > 1e-9 {
+		t.Errorf("Bollinger contribution = %.4f, expected 20", diff)
+	}
+}
+
+func TestGenerateScoredDecision_BollingerShortSignal(t *testing.T) {
+	engine := newTestBaselineEngine(func(cfg *store.StrategyConfig) {
+		cfg.Indicators.EnableBollinger = true
+		cfg.BaselineConfig.SignalThresholds.MinSignalCount = 1
+		cfg.Indicators.EnableEMA = false
+		cfg.Indicators.EnableStochRSI = false
+		cfg.BaselineConfig.BollingerStdDev = 4.0
+	})
+
+	data := &market.Data{
+		Symbol:       "BTCUSDT",
+		CurrentPrice: 100.5,
+		TimeframeData: map[string]*market.TimeframeSeriesData{
+			"1h": {Timeframe: "1h", Klines: klinesWithLastClose(20, 101)},
+		},
+	}
+	// closes: 19×100 + 101 -> mean 100.05, std ≈ 0.218, upper band (4σ) ≈ 100.92
+	// penetration ≈ 0.36 std devs -> contribution ≈ 13.6 (below the cap)
+	dec := engine.generateScoredDecision("BTCUSDT", data, 1000, 1000)
+	if dec == nil {
+		t.Fatal("expected short entry when close breaks above upper band")
+	}
+	if dec.Decision.Action != "open_short" {
+		t.Errorf("Action = %s, expected open_short", dec.Decision.Action)
+	}
+
+	mean := (19*100.0 + 101) / 20
+	std := math.Sqrt((19*(100-mean)*(100-mean) + (101-mean)*(101-mean)) / 20)
+	upper := mean + 4*std
+	expected := 10 + (101-upper)/std*10
+	if math.Abs(dec.Score-expected) > 1e-9 {
+		t.Errorf("Score = %.4f, expected %.4f", dec.Score, expected)
+	}
+}
+
+// multiTFData builds 1h signal data (long setup) plus a 4h series with the given EMA trend
+func multiTFData(symbol string, higherFast, higherSlow float64) *market.Data {
+	return &market.Data{
+		Symbol:       symbol,
+		CurrentPrice: 102,
+		TimeframeData: map[string]*market.TimeframeSeriesData{
+			"1h": {
+				Timeframe:      "1h",
+				Klines:         []market.KlineBar{{Close: 102}},
+				EMAShortValues: []float64{100},
+				StochRSI_K:     []float64{50},
+				StochRSI_D:     []float64{40},
+			},
+			"4h": {
+				Timeframe:      "4h",
+				Klines:         []market.KlineBar{{Close: 101}},
+				EMAShortValues: []float64{higherFast},
+				EMALongValues:  []float64{higherSlow},
+				// opposite StochRSI cross: must be ignored because the signal timeframe is 1h
+				StochRSI_K: []float64{40},
+				StochRSI_D: []float64{50},
+			},
+		},
+	}
+}
+
+func TestGenerateScoredDecision_HigherTFAgreement(t *testing.T) {
+	engine := newTestBaselineEngine(func(cfg *store.StrategyConfig) {
+		cfg.BaselineConfig.SignalTimeframe = "1h"
+		cfg.BaselineConfig.HigherTimeframe = "4h"
+		cfg.BaselineConfig.RequireHigherTFAgreement = true
+	})
+
+	for i := 0; i < 20; i++ { // map iteration order must not matter
+		dec := engine.generateScoredDecision("BTCUSDT", multiTFData("BTCUSDT", 110, 100), 1000, 1000)
+		if dec == nil || dec.Decision.Action != "open_long" {
+			t.Fatalf("expected open_long when 4h trend agrees, got %+v", dec)
+		}
+	}
+}
+
+func TestGenerateScoredDecision_HigherTFDisagreement(t *testing.T) {
+	engine := newTestBaselineEngine(func(cfg *store.StrategyConfig) {
+		cfg.BaselineConfig.SignalTimeframe = "1h"
+		cfg.BaselineConfig.HigherTimeframe = "4h"
+		cfg.BaselineConfig.RequireHigherTFAgreement = true
+	})
+
+	if dec := engine.generateScoredDecision("BTCUSDT", multiTFData("BTCUSDT", 90, 100), 1000, 1000); dec != nil {
+		t.Fatalf("expected entry to be blocked when 4h trend disagrees, got %+v", dec.Decision)
+	}
+
+	// Without the requirement the same data produces a long entry
+	engine.config.BaselineConfig.RequireHigherTFAgreement = false
+	if dec := engine.generateScoredDecision("BTCUSDT", multiTFData("BTCUSDT", 90, 100), 1000, 1000); dec == nil {
+		t.Fatal("expected entry when higher-TF agreement is not required")
+	}
+}
+
+func TestGenerateScoredDecision_ATRStop(t *testing.T) {
+	newEngine := func() *BaselineEngine {
+		return newTestBaselineEngine(func(cfg *store.StrategyConfig) {
+			cfg.BaselineConfig.RiskManagement.EnableATRStop = true
+			cfg.BaselineConfig.RiskManagement.ATRStopMultiple = 2
+			cfg.BaselineConfig.RiskManagement.HardStopLossPct = 3
+		})
+	}
+
+	stopFor := func(atr float64) float64 {
+		engine := newEngine()
+		data := longSetupData("BTCUSDT")
+		data.TimeframeData["1h"].ATR14 = atr
+		dec := engine.generateScoredDecision("BTCUSDT", data, 1000, 1000)
+		if dec == nil {
+			t.Fatalf("expected long entry (atr=%.2f)", atr)
+		}
+		if got := dec.state.HardStopPrice; got != dec.Decision.StopLoss {
+			t.Errorf("HardStopPrice = %.4f, expected it to match StopLoss %.4f", got, dec.Decision.StopLoss)
+		}
+		return dec.Decision.StopLoss
+	}
+
+	highATRStop := stopFor(3)
+	lowATRStop := stopFor(0.5)
+	if math.Abs(highATRStop-96) > 1e-9 {
+		t.Errorf("high-ATR stop = %.4f, expected 96 (102 - 2×3)", highATRStop)
+	}
+	if math.Abs(lowATRStop-101) > 1e-9 {
+		t.Errorf("low-ATR stop = %.4f, expected 101 (102 - 2×0.5)", lowATRStop)
+	}
+	if 102-highATRStop <= 102-lowATRStop {
+		t.Errorf("expected wider stop for high ATR: high=%.4f low=%.4f", highATRStop, lowATRStop)
+	}
+
+	// No ATR available -> falls back to the percentage stop
+	if fallback := stopFor(0); math.Abs(fallback-102*0.97) > 1e-9 {
+		t.Errorf("fallback stop = %.4f, expected %.4f", fallback, 102*0.97)
+	}
+}
+
+func TestMakeDecision_TwoStageScaleOut(t *testing.T) {
+	engine := newTestBaselineEngine(func(cfg *store.StrategyConfig) {
+		cfg.BaselineConfig.RiskManagement.EnableScaleOut = true
+		cfg.BaselineConfig.RiskManagement.ScaleOutFraction = 0.5
+		cfg.BaselineConfig.RiskManagement.TrailingTP1Pct = 2
+		cfg.BaselineConfig.RiskManagement.TrailingTP2Pct = 4
+	})
+	engine.positionStates["BTCUSDT_long"] = &BaselinePositionState{
+		Symbol:       "BTCUSDT",
+		Side:         "long",
+		EntryPrice:   100,
+		PeakPrice:    100,
+		TrailingStop: 97,
+	}
+
+	step := func(price, pnlPct float64) []decision.Decision {
+		marketData := map[string]*market.Data{"BTCUSDT": {Symbol: "BTCUSDT", CurrentPrice: price}}
+		positions := []decision.PositionInfo{{
+			Symbol:           "BTCUSDT",
+			Side:             "long",
+			EntryPrice:       100,
+			MarkPrice:        price,
+			UnrealizedPnLPct: pnlPct,
+		}}
+		// available below the entry floor: only exits are evaluated
+		return engine.MakeDecision(0, 1000, 0, marketData, positions)
+	}
+
+	if decs := step(100.2, 1.0); len(decs) != 0 {
+		t.Fatalf("expected no decision below TP1, got %+v", decs)
+	}
+
+	first := step(100.5, 2.5)
+	if len(first) != 1 || first[0].Action != "close_long" || first[0].CloseFraction != 0.5 {
+		t.Fatalf("expected 50%% scale-out at TP1, got %+v", first)
+	}
+	state, ok := engine.positionStates["BTCUSDT_long"]
+	if !ok {
+		t.Fatal("expected position state to survive a partial close")
+	}
+	if state.TrailingStop <= 100 {
+		t.Errorf("TrailingStop = %.4f, expected it raised above entry after stage 1", state.TrailingStop)
+	}
+
+	if decs := step(100.6, 2.8); len(decs) != 0 {
+		t.Fatalf("expected no repeat scale-out between TP1 and TP2, got %+v", decs)
+	}
+
+	second := step(101, 4.5)
+	if len(second) != 1 || second[0].CloseFraction != 0.5 {
+		t.Fatalf("expected second 50%% scale-out at TP2, got %+v", second)
+	}
+	if state.ScaleOutStage != 2 {
+		t.Errorf("ScaleOutStage = %d, expected 2", state.ScaleOutStage)
+	}
+}
+
+// hardStopLong runs one MakeDecision cycle in which an existing long on symbol hits its hard stop
+func hardStopLong(t *testing.T, engine *BaselineEngine, symbol string) {
+	t.Helper()
+	engine.positionStates[symbol+"_long"] = &BaselinePositionState{Symbol: symbol, Side: "long", EntryPrice: 100, PeakPrice: 100, TrailingStop: 97}
+	marketData := map[string]*market.Data{symbol: {Symbol: symbol, CurrentPrice: 96}}
+	positions := []decision.PositionInfo{{Symbol: symbol, Side: "long", EntryPrice: 100, MarkPrice: 96, UnrealizedPnLPct: -20}}
+	decs := engine.MakeDecision(0, 1000, 0, marketData, positions)
+	if len(decs) != 1 || decs[0].Action != "close_long" {
+		t.Fatalf("expected hard stop close, got %+v", decs)
+	}
+}
+
+func TestMakeDecision_CooldownAfterExit(t *testing.T) {
+	engine := newTestBaselineEngine(func(cfg *store.StrategyConfig) {
+		cfg.BaselineConfig.SignalThresholds.CooldownBars = 3
+	})
+	hardStopLong(t, engine, "BTCUSDT")
+
+	entry := func() []decision.Decision {
+		return engine.MakeDecision(0, 1000, 1000, map[string]*market.Data{"BTCUSDT": longSetupData("BTCUSDT")}, nil)
+	}
+
+	for i := 1; i < 3; i++ {
+		if decs := entry(); len(decs) != 0 {
+			t.Fatalf("cycle %d after exit: expected entry to be blocked by cooldown, got %+v", i, decs)
+		}
+	}
+	if decs := entry(); len(decs) != 1 || decs[0].Action != "open_long" {
+		t.Fatalf("expected entry to be allowed once cooldown elapsed, got %+v", decs)
+	}
+	if state := engine.positionStates["BTCUSDT_long"]; state == nil || state.EntryCycle != 4 {
+		t.Errorf("expected EntryCycle 4 to be recorded, got %+v", state)
+	}
+}
+
+func TestMakeDecision_CooldownOppositeOnly(t *testing.T) {
+	engine := newTestBaselineEngine(func(cfg *store.StrategyConfig) {
+		cfg.BaselineConfig.SignalThresholds.CooldownBars = 5
+		cfg.BaselineConfig.SignalThresholds.CooldownOppositeOnly = true
+	})
+	hardStopLong(t, engine, "BTCUSDT")
+
+	// Re-entering in the same direction is allowed
+	decs := engine.MakeDecision(0, 1000, 1000, map[string]*market.Data{"BTCUSDT": longSetupData("BTCUSDT")}, nil)
+	if len(decs) != 1 || decs[0].Action != "open_long" {
+		t.Fatalf("expected same-direction re-entry during cooldown, got %+v", decs)
+	}
+
+	// Flipping to short is blocked
+	delete(engine.positionStates, "BTCUSDT_long")
+	short := &market.Data{
+		Symbol:       "BTCUSDT",
+		CurrentPrice: 98,
+		CurrentEMA20: 100,
+		TimeframeData: map[string]*market.TimeframeSeriesData{
+			"1h": {Timeframe: "1h", StochRSI_K: []float64{40}, StochRSI_D: []float64{50}},
+		},
+	}
+	if decs := engine.MakeDecision(0, 1000, 1000, map[string]*market.Data{"BTCUSDT": short}, nil); len(decs) != 0 {
+		t.Fatalf("expected opposite-direction entry to be blocked during cooldown, got %+v", decs)
+	}
+}
+
+// divergenceData builds a single-timeframe series from closes and aligned RSI values
+func divergenceData(closes, rsi []float64) *market.Data {
+	klines := make([]market.KlineBar, len(closes))
+	for i, c := range closes {
+		klines[i] = market.KlineBar{Open: c, High: c, Low: c, Close: c}
+	}
+	return &market.Data{
+		Symbol:       "BTCUSDT",
+		CurrentPrice: closes[len(closes)-1],
+		TimeframeData: map[string]*market.TimeframeSeriesData{
+			"1h": {Timeframe: "1h", Klines: klines, RSI14Values: rsi},
+		},
+	}
+}
+
+func TestGenerateScoredDecision_RSIDivergence(t *testing.T) {
+	engine := newTestBaselineEngine(func(cfg *store.StrategyConfig) {
+		cfg.Indicators = store.IndicatorConfig{EnableRSIDivergence: true}
+		cfg.BaselineConfig.SignalThresholds.MinSignalCount = 1
+		cfg.BaselineConfig.RSIDivergenceLookback = 10
+		cfg.BaselineConfig.RSIDivergenceMinSwingPct = 1.0
+	})
+
+	tests := []struct {
+		name      string
+		closes    []float64
+		rsi       []float64
+		action    string
+		wantScore float64
+	}{
+		{
+			name:      "bullish: lower low in price, higher low in RSI",
+			closes:    []float64{100, 95, 98, 97, 93},
+			rsi:       []float64{50, 25, 40, 38, 35},
+			action:    "open_long",
+			wantScore: 20, // 10 + RSI diff 10
+		},
+		{
+			name:      "bearish: higher high in price, lower high in RSI",
+			closes:    []float64{100, 106, 103, 104, 108},
+			rsi:       []float64{50, 80, 60, 62, 72},
+			action:    "open_short",
+			wantScore: 18, // 10 + RSI diff 8
+		},
+		{
+			name:   "no divergence: RSI confirms the lower low",
+			closes: []float64{100, 95, 98, 97, 93},
+			rsi:    []float64{50, 25, 40, 38, 20},
+		},
+		{
+			name:   "no divergence: new low smaller than min swing",
+			closes: []float64{100, 95, 98, 97, 94.5},
+			rsi:    []float64{50, 25, 40, 38, 35},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine.positionStates = make(map[string]*BaselinePositionState)
+			dec := engine.generateScoredDecision("BTCUSDT", divergenceData(tt.closes, tt.rsi), 1000, 1000)
+			if tt.action == "" {
+				if dec != nil {
+					t.Fatalf("expected no entry, got %+v", dec.Decision)
+				}
+				return
+			}
+			if dec == nil {
+				t.Fatal("expected entry on divergence")
+			}
+			if dec.Decision.Action != tt.action {
+				t.Errorf("Action = %s, expected %s", dec.Decision.Action, tt.action)
+			}
+			if math.Abs(dec.Score-tt.wantScore) > 1e-9 {
+				t.Errorf("Score = %.2f, expected %.2f", dec.Score, tt.wantScore)
+			}
+		})
+	}
+}
+
+func TestUpdatePositionState_BreakevenStop(t *testing.T) {
+	engine := newTestBaselineEngine(func(cfg *store.StrategyConfig) {
+		cfg.BaselineConfig.RiskManagement.BreakevenTriggerPct = 1.5
+		cfg.BaselineConfig.RiskManagement.BreakevenOffsetPct = 0.1
+	})
+
+	tests := []struct {
+		name         string
+		side         string
+		initialStop  float64
+		price        float64
+		pnlPct       float64
+		expectedStop float64
+	}{
+		{"long below trigger", "long", 97, 100.2, 1.0, 97},
+		{"long snaps to breakeven", "long", 97, 100.4, 1.6, 100.1},
+		{"long never loosens", "long", 101, 101.5, 1.6, 101},
+		{"short below trigger", "short", 103, 99.8, 1.0, 103},
+		{"short snaps to breakeven", "short", 103, 99.6, 1.6, 99.9},
+		{"short never loosens", "short", 99, 98.5, 1.6, 99},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := "BTCUSDT_" + tt.side
+			engine.positionStates[key] = &BaselinePositionState{
+				Symbol: "BTCUSDT", Side: tt.side, EntryPrice: 100, PeakPrice: 100, TrailingStop: tt.initialStop,
+			}
+			engine.updatePositionState(decision.PositionInfo{
+				Symbol: "BTCUSDT", Side: tt.side, EntryPrice: 100, UnrealizedPnLPct: tt.pnlPct,
+			}, tt.price)
+
+			if got := engine.positionStates[key].TrailingStop; math.Abs(got-tt.expectedStop) > 1e-9 {
+				t.Errorf("TrailingStop = %.4f, expected %.4f", got, tt.expectedStop)
+			}
+		})
+	}
+}
+
+func TestMakeDecision_MaxHoldingBars(t *testing.T) {
+	engine := newTestBaselineEngine(func(cfg *store.StrategyConfig) {
+		cfg.BaselineConfig.RiskManagement.MaxHoldingBars = 3
+	})
+
+	// Cycle 1: open a long
+	decs := engine.MakeDecision(0, 1000, 1000, map[string]*market.Data{"BTCUSDT": longSetupData("BTCUSDT")}, nil)
+	if len(decs) != 1 || decs[0].Action != "open_long" {
+		t.Fatalf("expected open_long, got %+v", decs)
+	}
+
+	// Flat market: no exit signal fires until the holding horizon
+	flat := map[string]*market.Data{"BTCUSDT": {Symbol: "BTCUSDT", CurrentPrice: 102}}
+	positions := []decision.PositionInfo{{Symbol: "BTCUSDT", Side: "long", EntryPrice: 102, MarkPrice: 102}}
+	for cycle := 2; cycle <= 4; cycle++ {
+		if decs := engine.MakeDecision(0, 1000, 0, flat, positions); len(decs) != 0 {
+			t.Fatalf("cycle %d: expected no exit within holding horizon, got %+v", cycle, decs)
+		}
+	}
+
+	decs = engine.MakeDecision(0, 1000, 0, flat, positions)
+	if len(decs) != 1 || decs[0].Action != "close_long" {
+		t.Fatalf("cycle 5: expected forced close after 3 bars, got %+v", decs)
+	}
+	if _, ok := engine.positionStates["BTCUSDT_long"]; ok {
+		t.Error("expected position state to be cleared after forced close")
+	}
+}
+
+func TestMakeDecision_DeterministicTieBreaking(t *testing.T) {
+	symbols := []string{"SOLUSDT", "BTCUSDT", "XRPUSDT", "ETHUSDT", "ADAUSDT", "DOGEUSDT"}
+	newInputs := func() map[string]*market.Data {
+		marketData := make(map[string]*market.Data, len(symbols))
+		for _, symbol := range symbols {
+			marketData[symbol] = longSetupData(symbol) // identical scores for every symbol
+		}
+		return marketData
+	}
+	run := func() []byte {
+		engine := newTestBaselineEngine(func(cfg *store.StrategyConfig) {
+			cfg.RiskControl.MaxPositions = 2
+		})
+		out, err := json.Marshal(engine.MakeDecision(0, 1000, 1000, newInputs(), nil))
+		if err != nil {
+			t.Fatalf("marshal decisions: %v", err)
+		}
+		return out
+	}
+
+	first := run()
+	for i := 0; i < 50; i++ {
+		if got := run(); !bytes.Equal(first, got) {
+			t.Fatalf("run %d produced different decisions:\n%s\nvs\n%s", i, first, got)
+		}
+	}
+
+	var decisions []decision.Decision
+	if err := json.Unmarshal(first, &decisions); err != nil {
+		t.Fatalf("unmarshal decisions: %v", err)
+	}
+	if len(decisions) != 2 || decisions[0].Symbol != "ADAUSDT" || decisions[1].Symbol != "BTCUSDT" {
+		t.Errorf("expected ties broken by symbol (ADAUSDT, BTCUSDT), got %+v", decisions)
+	}
+}
+
+func TestSelectBestDecisions_TieBreakBySymbol(t *testing.T) {
+	engine := newTestBaselineEngine(nil)
+	candidates := []ScoredDecision{
+		{Decision: decision.Decision{Symbol: "ETHUSDT"}, Score: 50},
+		{Decision: decision.Decision{Symbol: "BTCUSDT"}, Score: 50},
+		{Decision: decision.Decision{Symbol: "SOLUSDT"}, Score: 70},
+	}
+
+	selected := engine.selectBestDecisions(candidates, 0)
+	got := []string{selected[0].Symbol, selected[1].Symbol, selected[2].Symbol}
+	want := []string{"SOLUSDT", "BTCUSDT", "ETHUSDT"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("selection order = %v, expected %v", got, want)
+		}
+	}
+}
+
+func TestMakeDecision_MarginCapSuppressesEntries(t *testing.T) {
+	engine := newTestBaselineEngine(func(cfg *store.StrategyConfig) {
+		cfg.BaselineConfig.RiskManagement.MaxMarginUsage = 0.5
+	})
+	marketData := map[string]*market.Data{"ETHUSDT": longSetupData("ETHUSDT")}
+	// An existing position already uses the whole 50% of equity
+	positions := []decision.PositionInfo{{Symbol: "SOLUSDT", Side: "long", EntryPrice: 100, MarkPrice: 100, MarginUsed: 500}}
+
+	if decs := engine.MakeDecision(0, 1000, 500, marketData, positions); len(decs) != 0 {
+		t.Fatalf("expected entries to be suppressed at the margin cap, got %+v", decs)
+	}
+	if _, exists := engine.positionStates["ETHUSDT_long"]; exists {
+		t.Fatal("expected the state of a suppressed entry to be cleared")
+	}
+}
+
+func TestMakeDecision_MarginCapShrinksEntries(t *testing.T) {
+	engine := newTestBaselineEngine(func(cfg *store.StrategyConfig) {
+		cfg.BaselineConfig.RiskManagement.Leverage = 5
+		cfg.RiskControl.MaxMarginUsage = 0.5 // falls back to the strategy-level cap
+	})
+	marketData := map[string]*market.Data{
+		"BTCUSDT": longSetupData("BTCUSDT"),
+		"ETHUSDT": longSetupData("ETHUSDT"),
+	}
+	// Margin estimated from notional / leverage: 3 × 100 / 1 = 300, leaving 200
+	positions := []decision.PositionInfo{{Symbol: "SOLUSDT", Side: "long", EntryPrice: 100, MarkPrice: 100, Quantity: 3, Leverage: 1}}
+
+	decs := engine.MakeDecision(0, 1000, 900, marketData, positions)
+	if len(decs) != 1 || decs[0].Symbol != "BTCUSDT" {
+		t.Fatalf("expected only the first entry to fit under the cap, got %+v", decs)
+	}
+	// 200 margin × 5x leverage, down from 900 / 3 × 5 = 1500
+	if math.Abs(decs[0].PositionSizeUSD-1000) > 1e-9 {
+		t.Errorf("PositionSizeUSD = %.2f, expected it shrunk to 1000", decs[0].PositionSizeUSD)
+	}
+	if _, exists := engine.positionStates["ETHUSDT_long"]; exists {
+		t.Error("expected the state of the entry over the cap to be cleared")
+	}
+}
+
+// correlationData builds long-setup data whose klines follow the given repeating return pattern (%)
+func correlationData(symbol string, pattern []float64, scale float64) *market.Data {
+	data := longSetupData(symbol)
+	klines := make([]market.KlineBar, 41)
+	price := 100.0
+	for i := range klines {
+		if i > 0 {
+			price *= 1 + pattern[(i-1)%len(pattern)]*scale/100
+		}
+		klines[i] = market.KlineBar{Open: price, High: price, Low: price, Close: price}
+	}
+	data.TimeframeData["1h"].Klines = klines
+	return data
+}
+
+func TestReturnCorrelation(t *testing.T) {
+	a := []float64{1, -1, 1, -1, 1, -1, 1, -1}
+	if corr, ok := returnCorrelation(a, []float64{2, -2, 2, -2, 2, -2, 2, -2}); !ok || math.Abs(corr-1) > 1e-9 {
+		t.Errorf("expected perfect correlation, got %.4f (ok=%v)", corr, ok)
+	}
+	if corr, ok := returnCorrelation(a, []float64{1, 1, -1, -1, 1, 1, -1, -1}); !ok || math.Abs(corr) > 1e-9 {
+		t.Errorf("expected no correlation, got %.4f (ok=%v)", corr, ok)
+	}
+	if _, ok := returnCorrelation(a, []float64{1, 1, 1, 1, 1, 1, 1, 1}); ok {
+		t.Error("expected a flat series to have no correlation")
+	}
+}
+
+func TestMakeDecision_CorrelationFilter(t *testing.T) {
+	alternating := []float64{1, -1}
+	marketData := func() map[string]*market.Data {
+		return map[string]*market.Data{
+			"BTCUSDT": correlationData("BTCUSDT", alternating, 1),
+			"ETHUSDT": correlationData("ETHUSDT", alternating, 1.2), // moves with BTC
+			"SOLUSDT": correlationData("SOLUSDT", []float64{1, 1, -1, -1}, 1),
+			"XRPUSDT": correlationData("XRPUSDT", []float64{1, -1, -1, 1}, 1),
+		}
+	}
+	run := func(maxCorrelated int) []string {
+		engine := newTestBaselineEngine(func(cfg *store.StrategyConfig) {
+			cfg.BaselineConfig.RiskManagement.MaxSameDirectionPositions = 5
+			cfg.BaselineConfig.RiskManagement.MaxCorrelatedPositions = maxCorrelated
+		})
+		engine.positionStates["BTCUSDT_long"] = &BaselinePositionState{Symbol: "BTCUSDT", Side: "long", EntryPrice: 102, PeakPrice: 102}
+		positions := []decision.PositionInfo{{Symbol: "BTCUSDT", Side: "long", EntryPrice: 102, MarkPrice: 102}}
+
+		var opened []string
+		for _, dec := range engine.MakeDecision(0, 10000, 10000, marketData(), positions) {
+			opened = append(opened, dec.Symbol)
+		}
+		return opened
+	}
+
+	// Equal scores tie-break by symbol, so ETHUSDT takes a slot when the filter is off
+	if got := run(0); len(got) != 2 || got[0] != "ETHUSDT" {
+		t.Fatalf("expected ETHUSDT to open with the filter disabled, got %v", got)
+	}
+	got := run(1)
+	if len(got) != 2 || got[0] != "SOLUSDT" || got[1] != "XRPUSDT" {
+		t.Fatalf("expected only the correlated ETHUSDT long to be blocked, got %v", got)
+	}
+}
+
+func TestSessionVWAP_ResetsAtSessionBoundary(t *testing.T) {
+	const hour = int64(3600 * 1000)
+	klines := []market.KlineBar{
+		// previous session: heavy volume at 200 must not leak into the new session
+		{Time: 22 * hour, High: 200, Low: 200, Close: 200, Volume: 1000},
+		{Time: 23 * hour, High: 200, Low: 200, Close: 200, Volume: 1000},
+		// new session starting at 24h
+		{Time: 24 * hour, High: 101, Low: 99, Close: 100, Volume: 300},
+		{Time: 25 * hour, High: 112, Low: 108, Close: 110, Volume: 100},
+	}
+
+	vwap, ok := sessionVWAP(klines, 24*hour)
+	if !ok {
+		t.Fatal("expected a VWAP for the current session")
+	}
+	// (100×300 + 110×100) / 400 = 102.5
+	if math.Abs(vwap-102.5) > 1e-9 {
+		t.Errorf("VWAP = %.4f, expected 102.5", vwap)
+	}
+
+	// With 4h sessions the 24h bar still opens the session; with 48h sessions all bars count
+	if vwap, _ := sessionVWAP(klines, 4*hour); math.Abs(vwap-102.5) > 1e-9 {
+		t.Errorf("4h session VWAP = %.4f, expected 102.5", vwap)
+	}
+	if vwap, _ := sessionVWAP(klines, 48*hour); math.Abs(vwap-(400000+30000+11000)/2400.0) > 1e-9 {
+		t.Errorf("48h session VWAP = %.4f, expected all bars to be included", vwap)
+	}
+
+	if _, ok := sessionVWAP([]market.KlineBar{{Time: hour, Close: 100}}, 24*hour); ok {
+		t.Error("expected no VWAP without volume")
+	}
+}
+
+func TestGenerateScoredDecision_VWAPBias(t *testing.T) {
+	// Price 102 against a session VWAP of 110 (cheap) or 95 (rich)
+	newData := func(vwapPrice float64) *market.Data {
+		data := longSetupData("BTCUSDT")
+		data.TimeframeData["1h"].Klines = []market.KlineBar{
+			{Time: 0, High: vwapPrice, Low: vwapPrice, Close: vwapPrice, Volume: 1000},
+		}
+		return data
+	}
+
+	plain := newTestBaselineEngine(nil).generateScoredDecision("BTCUSDT", newData(110), 1000, 1000)
+	if plain == nil {
+		t.Fatal("expected long entry without VWAP")
+	}
+
+	withVWAP := func(vwapPrice float64) *ScoredDecision {
+		engine := newTestBaselineEngine(func(cfg *store.StrategyConfig) {
+			cfg.Indicators.EnableVWAP = true
+		})
+		dec := engine.generateScoredDecision("BTCUSDT", newData(vwapPrice), 1000, 1000)
+		if dec == nil {
+			t.Fatal("expected long entry with VWAP")
+		}
+		return dec
+	}
+
+	// 102 is 7.27% below 110: bonus capped at 15
+	if cheap := withVWAP(110); math.Abs(cheap.Score-(plain.Score+15)) > 1e-9 {
+		t.Errorf("Score = %.4f, expected %.4f with the below-VWAP long bonus", cheap.Score, plain.Score+15)
+	}
+	// Above VWAP only shorts are favoured, the long score is unchanged
+	if rich := withVWAP(95); math.Abs(rich.Score-plain.Score) > 1e-9 {
+		t.Errorf("Score = %.4f, expected no long bonus above VWAP (%.4f)", rich.Score, plain.Score)
+	}
+}
+
+// macdData builds long-setup data whose MACD histogram moves from prevHist to hist
+func macdData(prevHist, hist float64) *market.Data {
+	data := longSetupData("BTCUSDT")
+	data.TimeframeData["1h"].MACDValues = []float64{2 + prevHist, 2 + hist}
+	data.TimeframeData["1h"].MACDSignal = []float64{2, 2}
+	return data
+}
+
+func TestGenerateScoredDecision_MACDHistogramMomentum(t *testing.T) {
+	plain := newTestBaselineEngine(nil).generateScoredDecision("BTCUSDT", macdData(0.5, 1), 1000, 1000)
+	if plain == nil {
+		t.Fatal("expected long entry without MACD")
+	}
+
+	score := func(prevHist, hist float64) float64 {
+		engine := newTestBaselineEngine(func(cfg *store.StrategyConfig) {
+			cfg.Indicators.EnableMACD = true
+		})
+		dec := engine.generateScoredDecision("BTCUSDT", macdData(prevHist, hist), 1000, 1000)
+		if dec == nil {
+			t.Fatal("expected long entry with MACD")
+		}
+		return dec.Score - plain.Score
+	}
+
+	// Expanding above zero: 0.5 → 1 expands by half the histogram -> 5 points
+	if got := score(0.5, 1); math.Abs(got-5) > 1e-9 {
+		t.Errorf("expanding histogram added %.4f, expected 5", got)
+	}
+	// Crossing up through zero -> full 10 points
+	if got := score(-0.2, 0.4); math.Abs(got-10) > 1e-9 {
+		t.Errorf("crossing histogram added %.4f, expected 10", got)
+	}
+	// Contracting above zero and falling below zero add nothing to longs
+	if got := score(1, 0.5); got != 0 {
+		t.Errorf("contracting histogram added %.4f, expected 0", got)
+	}
+	if got := score(-0.5, -1); got != 0 {
+		t.Errorf("falling histogram added %.4f to the long score, expected 0", got)
+	}
+}
+
+func TestGetMACDHistogram_NeedsSignalLine(t *testing.T) {
+	engine := newTestBaselineEngine(nil)
+	data := macdData(0.5, 1)
+	data.TimeframeData["1h"].MACDSignal = []float64{0, 2}
+	if _, _, ok := engine.getMACDHistogram(data, ""); ok {
+		t.Fatal("expected no histogram before the signal line has formed")
+	}
+}
+
+func TestGenerateScoredDecision_IndicatorWeights(t *testing.T) {
+	// longSetupData: EMA contributes 10 of 20 (price 2% above EMA), StochRSI 52.5 of 70
+	const emaScore, stochScore = 10.0, 52.5
+
+	tests := []struct {
+		name     string
+		weights  store.BaselineIndicatorWeights
+		expected float64
+	}{
+		{"defaults", store.BaselineIndicatorWeights{}, emaScore + stochScore},
+		{"double ema", store.BaselineIndicatorWeights{EMAWeight: 40}, emaScore*2 + stochScore},
+		{"half stochrsi", store.BaselineIndicatorWeights{StochRSIWeight: 35}, emaScore + stochScore/2},
+		{"explicit defaults", store.BaselineIndicatorWeights{EMAWeight: 20, StochRSIWeight: 70}, emaScore + stochScore},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := newTestBaselineEngine(func(cfg *store.StrategyConfig) {
+				cfg.BaselineConfig.IndicatorWeights = tt.weights
+			})
+			dec := engine.generateScoredDecision("BTCUSDT", longSetupData("BTCUSDT"), 1000, 1000)
+			if dec == nil {
+				t.Fatal("expected long entry")
+			}
+			if math.Abs(dec.Score-tt.expected) > 1e-9 {
+				t.Errorf("Score = %.4f, expected %.4f", dec.Score, tt.expected)
+			}
+		})
+	}
+}
+
+func TestGenerateScoredDecision_VolumeMultiplierCaps(t *testing.T) {
+	// The last bar trades 40% of the average volume: the lowest volume tier
+	newData := func() *market.Data {
+		data := longSetupData("BTCUSDT")
+		klines := klinesWithLastClose(21, 100)
+		klines[20].Volume = 400
+		data.TimeframeData["1h"].Klines = klines
+		return data
+	}
+	score := func(caps store.BaselineVolumeMultiplierCaps) float64 {
+		engine := newTestBaselineEngine(func(cfg *store.StrategyConfig) {
+			cfg.Indicators.EnableVolume = true
+			cfg.BaselineConfig.IndicatorWeights.VolumeMultiplierCaps = caps
+		})
+		dec := engine.generateScoredDecision("BTCUSDT", newData(), 1000, 1000)
+		if dec == nil {
+			t.Fatal("expected long entry")
+		}
+		return dec.Score
+	}
+
+	base := 62.5
+	if got := score(store.BaselineVolumeMultiplierCaps{}); math.Abs(got-base*0.7) > 1e-9 {
+		t.Errorf("Score = %.4f, expected the default 0.7 multiplier (%.4f)", got, base*0.7)
+	}
+	if got := score(store.BaselineVolumeMultiplierCaps{Min: 0.5}); math.Abs(got-base*0.5) > 1e-9 {
+		t.Errorf("Score = %.4f, expected the configured 0.5 multiplier (%.4f)", got, base*0.5)
+	}
+}
+
+func TestTradingWindowContains(t *testing.T) {
+	at := func(day, hour int) time.Time {
+		// 2024-01-07 is a Sunday
+		return time.Date(2024, 1, 7+day, hour, 30, 0, 0, time.UTC)
+	}
+	overnight := store.BaselineTradingWindow{StartHour: 22, EndHour: 2, Weekdays: []int{1}} // Monday 22:00 - Tuesday 02:00
+
+	tests := []struct {
+		name   string
+		window store.BaselineTradingWindow
+		t      time.Time
+		want   bool
+	}{
+		{"inside daytime window", store.BaselineTradingWindow{StartHour: 8, EndHour: 20}, at(1, 8), true},
+		{"end hour is exclusive", store.BaselineTradingWindow{StartHour: 8, EndHour: 20}, at(1, 20), false},
+		{"weekday not allowed", store.BaselineTradingWindow{StartHour: 8, EndHour: 20, Weekdays: []int{1, 2, 3, 4, 5}}, at(0, 12), false},
+		{"overnight before midnight", overnight, at(1, 23), true},
+		{"overnight after midnight belongs to the start day", overnight, at(2, 1), true},
+		{"overnight after midnight of another day", overnight, at(1, 1), false},
+		{"overnight gap", overnight, at(1, 12), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tradingWindowContains(tt.window, tt.t); got != tt.want {
+				t.Errorf("tradingWindowContains(%+v, %s) = %v, expected %v", tt.window, tt.t.Format(time.RFC3339), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMakeDecision_TradingHours(t *testing.T) {
+	engine := newTestBaselineEngine(func(cfg *store.StrategyConfig) {
+		cfg.BaselineConfig.TradingHours = []store.BaselineTradingWindow{{StartHour: 8, EndHour: 20}}
+	})
+	night := time.Date(2024, 1, 8, 3, 0, 0, 0, time.UTC).UnixMilli()
+	day := time.Date(2024, 1, 8, 12, 0, 0, 0, time.UTC).UnixMilli()
+
+	entry := map[string]*market.Data{"BTCUSDT": longSetupData("BTCUSDT")}
+	if decs := engine.MakeDecision(night, 1000, 1000, entry, nil); len(decs) != 0 {
+		t.Fatalf("expected entries to be blocked outside trading hours, got %+v", decs)
+	}
+	if decs := engine.MakeDecision(day, 1000, 1000, entry, nil); len(decs) != 1 || decs[0].Action != "open_long" {
+		t.Fatalf("expected entry inside trading hours, got %+v", decs)
+	}
+
+	// A hard stop still closes the position at night
+	marketData := map[string]*market.Data{"BTCUSDT": {Symbol: "BTCUSDT", CurrentPrice: 90}}
+	positions := []decision.PositionInfo{{Symbol: "BTCUSDT", Side: "long", EntryPrice: 102, MarkPrice: 90, UnrealizedPnLPct: -50}}
+	if decs := engine.MakeDecision(night, 1000, 1000, marketData, positions); len(decs) != 1 || decs[0].Action != "close_long" {
+		t.Fatalf("expected exits to proceed outside trading hours, got %+v", decs)
+	}
+}
+
+func TestMakeDecision_AllowedSymbols(t *testing.T) {
+	engine := newTestBaselineEngine(func(cfg *store.StrategyConfig) {
+		cfg.BaselineConfig.AllowedSymbols = []string{"eth"}
+	})
+	ts := time.Date(2024, 1, 8, 12, 0, 0, 0, time.UTC).UnixMilli()
+
+	// Both symbols show the same long setup; only the whitelisted one is entered
+	entry := map[string]*market.Data{
+		"BTCUSDT": longSetupData("BTCUSDT"),
+		"ETHUSDT": longSetupData("ETHUSDT"),
+	}
+	decs := engine.MakeDecision(ts, 1000, 1000, entry, nil)
+	if len(decs) != 1 || decs[0].Symbol != "ETHUSDT" || decs[0].Action != "open_long" {
+		t.Fatalf("expected a single entry on the allowed symbol, got %+v", decs)
+	}
+
+	// A hard stop still closes a position on the excluded symbol
+	marketData := map[string]*market.Data{"BTCUSDT": {Symbol: "BTCUSDT", CurrentPrice: 90}}
+	positions := []decision.PositionInfo{{Symbol: "BTCUSDT", Side: "long", EntryPrice: 102, MarkPrice: 90, UnrealizedPnLPct: -50}}
+	if decs := engine.MakeDecision(ts, 1000, 1000, marketData, positions); len(decs) != 1 || decs[0].Action != "close_long" {
+		t.Fatalf("expected the excluded symbol's position to exit, got %+v", decs)
+	}
+
+	// An empty list allows every symbol
+	engine = newTestBaselineEngine(nil)
+	if decs := engine.MakeDecision(ts, 1000, 1000, entry, nil); len(decs) != 2 {
+		t.Fatalf("expected entries on both symbols without a whitelist, got %+v", decs)
+	}
+}
+
+func TestGenerateScoredDecision_MinimumEdge(t *testing.T) {
+	entry := func(tp1Pct, feeBps, slippageBps float64) *ScoredDecision {
+		engine := newTestBaselineEngine(func(cfg *store.StrategyConfig) {
+			cfg.BaselineConfig.RiskManagement.TrailingTP1Pct = tp1Pct
+			cfg.BaselineConfig.RiskManagement.Leverage = 5
+		})
+		engine.SetTradingCosts(feeBps, slippageBps)
+		return engine.generateScoredDecision("BTCUSDT", longSetupData("BTCUSDT"), 1000, 1000)
+	}
+
+	// Round trip (5 + 5) × 2 = 20 bps = 0.2%; the default 1.5× margin needs a 0.3% price move.
+	// TP1 is a margin return: at 5× leverage a 1% TP1 is only a 0.2% price move
+	if dec := entry(1, 5, 5); dec != nil {
+		t.Fatalf("expected a 1%% TP1 (0.2%% move at 5x) to be rejected against 0.2%% costs, got %+v", dec.Decision)
+	}
+	if dec := entry(2.5, 5, 5); dec == nil {
+		t.Fatal("expected a 2.5% TP1 (0.5% move at 5x) to cover 0.2% costs")
+	}
+	// Without injected costs the gate is off
+	if dec := entry(0.25, 0, 0); dec == nil {
+		t.Fatal("expected entry when no trading costs are configured")
+	}
+}
+
+// obvKlines builds a rising price series of alternating +2 / -1 bars with the given volumes
+func obvKlines(upVolume, downVolume float64) []market.KlineBar {
+	klines := make([]market.KlineBar, 11)
+	price := 100.0
+	klines[0] = market.KlineBar{Close: price, Volume: upVolume}
+	for i := 1; i < len(klines); i++ {
+		volume := upVolume
+		if i%2 == 1 {
+			price += 2
+		} else {
+			price--
+			volume = downVolume
+		}
+		klines[i] = market.KlineBar{Close: price, Volume: volume}
+	}
+	return klines
+}
+
+func TestOBVSlope(t *testing.T) {
+	// Price rises in both cases; only the volume behind the moves differs
+	confirmed, ok := obvSlope(obvKlines(1000, 200), 10)
+	if !ok {
+		t.Fatal("expected an OBV slope")
+	}
+	// 5 up bars × 1000 − 5 down bars × 200 over 6000 total volume
+	if math.Abs(confirmed-4000.0/6000) > 1e-9 {
+		t.Errorf("slope = %.4f, expected %.4f for volume-backed rally", confirmed, 4000.0/6000)
+	}
+	diverging, _ := obvSlope(obvKlines(200, 1000), 10)
+	if diverging >= 0 {
+		t.Errorf("slope = %.4f, expected falling OBV when volume comes on down bars", diverging)
+	}
+	if _, ok := obvSlope(obvKlines(1000, 200), 11); ok {
+		t.Error("expected no slope without enough bars")
+	}
+}
+
+func TestGenerateScoredDecision_OBVConfirmation(t *testing.T) {
+	score := func(enable bool, klines []market.KlineBar) float64 {
+		engine := newTestBaselineEngine(func(cfg *store.StrategyConfig) {
+			cfg.Indicators.EnableOBV = enable
+		})
+		data := longSetupData("BTCUSDT")
+		data.TimeframeData["1h"].Klines = klines
+		dec := engine.generateScoredDecision("BTCUSDT", data, 1000, 1000)
+		if dec == nil {
+			t.Fatal("expected long entry")
+		}
+		return dec.Score
+	}
+
+	plain := score(false, obvKlines(1000, 200))
+	if got := score(true, obvKlines(1000, 200)) - plain; math.Abs(got-4000.0/6000*10) > 1e-9 {
+		t.Errorf("rising OBV added %.4f to the long score, expected %.4f", got, 4000.0/6000*10)
+	}
+	if got := score(true, obvKlines(200, 1000)) - plain; got != 0 {
+		t.Errorf("falling OBV added %.4f to the long score, expected 0", got)
+	}
+}
+
+// ichimokuKlines builds 86 bars: 40 flat at 100, a rally of +2 to 160, a pullback of -5 to 130
+// that pulls Tenkan below Kijun, then a recovery of +3 that crosses Tenkan back above on the last bar.
+// With the default 9/26/52 periods the cloud under the last bar spans 120-126.
+// mirror reflects the series around 200, turning it into a bearish setup below the cloud.
+func ichimokuKlines(mirror bool) []market.KlineBar {
+	var closes []float64
+	price := 100.0
+	for i := 0; i < 40; i++ {
+		closes = append(closes, price)
+	}
+	for _, leg := range []struct{ bars, step float64 }{{30, 2}, {6, -5}, {10, 3}} {
+		for i := 0.0; i < leg.bars; i++ {
+			price += leg.step
+			closes = append(closes, price)
+		}
+	}
+	klines := make([]market.KlineBar, len(closes))
+	for i, c := range closes {
+		if mirror {
+			c = 400 - c
+		}
+		klines[i] = market.KlineBar{Open: c, High: c, Low: c, Close: c, Volume: 1000}
+	}
+	return klines
+}
+
+func TestIchimoku(t *testing.T) {
+	bullish, ok := ichimoku(ichimokuKlines(false), 9, 26, 52)
+	if !ok {
+		t.Fatal("expected an Ichimoku cloud")
+	}
+	want := ichimokuCloud{tenkan: 148, kijun: 145, top: 126, bottom: 120, cross: 1}
+	if bullish != want {
+		t.Errorf("got %+v, expected %+v", bullish, want)
+	}
+
+	bearish, _ := ichimoku(ichimokuKlines(true), 9, 26, 52)
+	want = ichimokuCloud{tenkan: 252, kijun: 255, top: 280, bottom: 274, cross: -1}
+	if bearish != want {
+		t.Errorf("mirrored: got %+v, expected %+v", bearish, want)
+	}
+
+	// One bar earlier Tenkan and Kijun are level, which is no cross
+	if early, _ := ichimoku(ichimokuKlines(false)[:85], 9, 26, 52); early.cross != 0 {
+		t.Errorf("expected no cross while Tenkan equals Kijun, got %+v", early)
+	}
+	if _, ok := ichimoku(ichimokuKlines(false)[:77], 9, 26, 52); ok {
+		t.Error("expected no cloud without kijun+senkouB bars")
+	}
+}
+
+func TestGenerateScoredDecision_IchimokuCloud(t *testing.T) {
+	score := func(enable bool) float64 {
+		engine := newTestBaselineEngine(func(cfg *store.StrategyConfig) {
+			cfg.Indicators.EnableIchimoku = enable
+		})
+		data := longSetupData("BTCUSDT")
+		data.CurrentPrice, data.CurrentEMA20 = 160, 158
+		data.TimeframeData["1h"].Klines = ichimokuKlines(false)
+		dec := engine.generateScoredDecision("BTCUSDT", data, 1000, 1000)
+		if dec == nil {
+			t.Fatal("expected long entry")
+		}
+		return dec.Score
+	}
+
+	// Price 160 above a 6-point cloud (3.75% of price): 10 + 3.75 × 2
+	if got := score(true) - score(false); math.Abs(got-17.5) > 1e-9 {
+		t.Errorf("bullish cloud added %.4f to the long score, expected 17.5", got)
+	}
+}
+
+func TestMakeDecision_Pyramiding(t *testing.T) {
+	engine := newTestBaselineEngine(func(cfg *store.StrategyConfig) {
+		cfg.BaselineConfig.RiskManagement.Leverage = 5
+		cfg.BaselineConfig.RiskManagement.EnablePyramiding = true
+		cfg.BaselineConfig.RiskManagement.MaxPyramidEntries = 2
+	})
+	if decs := engine.MakeDecision(0, 1000, 900, map[string]*market.Data{"BTCUSDT": longSetupData("BTCUSDT")}, nil); len(decs) != 1 {
+		t.Fatalf("expected initial entry, got %+v", decs)
+	}
+	state := engine.positionStates["BTCUSDT_long"]
+	quantity := 10.0
+
+	// step re-fires the long signals at price and returns the add decision, if any
+	step := func(price float64) *decision.Decision {
+		data := longSetupData("BTCUSDT")
+		data.CurrentPrice = price
+		positions := []decision.PositionInfo{{
+			Symbol: "BTCUSDT", Side: "long", EntryPrice: state.EntryPrice, MarkPrice: price,
+			Quantity: quantity, Leverage: 5, UnrealizedPnLPct: 1,
+		}}
+		decs := engine.MakeDecision(0, 1000, 900, map[string]*market.Data{"BTCUSDT": data}, positions)
+		if len(decs) > 1 || (len(decs) == 1 && decs[0].Action != "open_long") {
+			t.Fatalf("expected at most one add at %.2f, got %+v", price, decs)
+		}
+		if len(decs) == 0 {
+			return nil
+		}
+		quantity += decs[0].PositionSizeUSD / price
+		return &decs[0]
+	}
+
+	if add := step(101); add != nil {
+		t.Fatalf("expected no add while the position is under water, got %+v", add)
+	}
+	if add := step(103); add != nil {
+		t.Fatalf("expected no add below the 2%% minimum profit, got %+v", add)
+	}
+
+	entryBefore, qtyBefore := state.EntryPrice, quantity
+	add := step(104.1)
+	if add == nil {
+		t.Fatal("expected an add after a 2% favorable move")
+	}
+	// Half of the normal 900 / 3 × 5 = 1500 entry
+	if math.Abs(add.PositionSizeUSD-750) > 1e-9 {
+		t.Errorf("PositionSizeUSD = %.2f, expected 750", add.PositionSizeUSD)
+	}
+	addQty := 750 / 104.1
+	blended := (entryBefore*qtyBefore + 104.1*addQty) / (qtyBefore + addQty)
+	if math.Abs(state.EntryPrice-blended) > 1e-9 {
+		t.Errorf("EntryPrice = %.4f, expected blended %.4f", state.EntryPrice, blended)
+	}
+	// The stop follows the blended entry: 3% of the add price below it
+	wantStop := blended - 104.1*0.03
+	if math.Abs(state.TrailingStop-wantStop) > 1e-9 || math.Abs(add.StopLoss-wantStop) > 1e-9 {
+		t.Errorf("TrailingStop = %.4f, StopLoss = %.4f, expected %.4f", state.TrailingStop, add.StopLoss, wantStop)
+	}
+
+	if add := step(104.1); add != nil {
+		t.Fatalf("expected the next add to need a new move from the last add price, got %+v", add)
+	}
+	if add := step(106.3); add == nil {
+		t.Fatal("expected a second add")
+	}
+	if add := step(110); add != nil {
+		t.Fatalf("expected adds to stop at MaxPyramidEntries, got %+v", add)
+	}
+	if state.PyramidAdds != 2 {
+		t.Errorf("PyramidAdds = %d, expected 2", state.PyramidAdds)
+	}
+}
+
+func TestMakeDecision_PyramidingDisabled(t *testing.T) {
+	engine := newTestBaselineEngine(nil)
+	engine.positionStates["BTCUSDT_long"] = &BaselinePositionState{Symbol: "BTCUSDT", Side: "long", EntryPrice: 100, PeakPrice: 100, TrailingStop: 97}
+	data := longSetupData("BTCUSDT")
+	data.CurrentPrice = 110
+	positions := []decision.PositionInfo{{Symbol: "BTCUSDT", Side: "long", EntryPrice: 100, MarkPrice: 110, Quantity: 1, UnrealizedPnLPct: 1}}
+	if decs := engine.MakeDecision(0, 1000, 900, map[string]*market.Data{"BTCUSDT": data}, positions); len(decs) != 0 {
+		t.Fatalf("expected no adds without pyramiding, got %+v", decs)
+	}
+}
+
+func TestCheckExitSignal_ExitReasons(t *testing.T) {
+	stochData := func(price, k, d float64) *market.Data {
+		return &market.Data{
+			Symbol:       "BTCUSDT",
+			CurrentPrice: price,
+			CurrentRSI7:  50,
+			TimeframeData: map[string]*market.TimeframeSeriesData{
+				"1h": {Timeframe: "1h", StochRSI_K: []float64{k}, StochRSI_D: []float64{d}},
+			},
+		}
+	}
+	tests := []struct {
+		name   string
+		side   string
+		pnlPct float64
+		data   *market.Data
+		setup  func(state *BaselinePositionState, rm *store.BaselineRiskManagement)
+		want   decision.ExitReason
+	}{
+		{"hard stop", "long", -4, stochData(96, 50, 40), nil, decision.ExitReasonHardStop},
+		{"rsi overbought", "long", 0, &market.Data{Symbol: "BTCUSDT", CurrentPrice: 100, CurrentRSI7: 75}, nil, decision.ExitReasonRSIExit},
+		{"rsi oversold", "short", 0, &market.Data{Symbol: "BTCUSDT", CurrentPrice: 100, CurrentRSI7: 25}, nil, decision.ExitReasonRSIExit},
+		{"stoch death cross", "long", 0, stochData(100, 80, 85), nil, decision.ExitReasonStochCross},
+		{"stoch golden cross", "short", 0, stochData(100, 20, 15), nil, decision.ExitReasonStochCross},
+		{"trailing take profit", "long", 0.5, stochData(100.5, 50, 40), func(state *BaselinePositionState, _ *store.BaselineRiskManagement) {
+			state.TrailingTP = 101
+		}, decision.ExitReasonTrailingTP},
+		{"trailing stop", "long", 0.1, stochData(100.1, 50, 40), func(state *BaselinePositionState, _ *store.BaselineRiskManagement) {
+			state.TrailingStop = 100.2
+			state.BreakevenSet = true
+		}, decision.ExitReasonTrailingStop},
+		{"max holding period", "long", 0, stochData(100, 50, 40), func(state *BaselinePositionState, rm *store.BaselineRiskManagement) {
+			rm.MaxHoldingBars = 5
+			state.EntryCycle = -10
+		}, decision.ExitReasonTimeExit},
+		{"scale-out", "long", 2.5, stochData(102.5, 50, 40), func(_ *BaselinePositionState, rm *store.BaselineRiskManagement) {
+			rm.EnableScaleOut = true
+		}, decision.ExitReasonScaleOut},
+		{"fixed take profit", "short", 5, stochData(95, 50, 40), func(_ *BaselinePositionState, rm *store.BaselineRiskManagement) {
+			rm.FixedTakeProfitPct = 4
+		}, decision.ExitReasonTakeProfit},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := newTestBaselineEngine(func(cfg *store.StrategyConfig) {
+				cfg.Indicators.EnableRSI = true
+			})
+			stop := 97.0
+			if tt.side == "short" {
+				stop = 103
+			}
+			state := &BaselinePositionState{Symbol: "BTCUSDT", Side: tt.side, EntryPrice: 100, PeakPrice: 100, TrailingStop: stop}
+			if tt.setup != nil {
+				tt.setup(state, &engine.config.BaselineConfig.RiskManagement)
+			}
+			engine.positionStates["BTCUSDT_"+tt.side] = state
+
+			pos := decision.PositionInfo{Symbol: "BTCUSDT", Side: tt.side, EntryPrice: 100, MarkPrice: tt.data.CurrentPrice, UnrealizedPnLPct: tt.pnlPct}
+			dec := engine.checkExitSignal(pos, tt.data)
+			if dec == nil {
+				t.Fatal("expected an exit")
+			}
+			if dec.ExitReason != tt.want || dec.Reasoning == "" {
+				t.Errorf("ExitReason = %q (%q), expected %q", dec.ExitReason, dec.Reasoning, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckPendingStopLoss_ExitReason(t *testing.T) {
+	engine := newTestBaselineEngine(nil)
+	engine.positionStates["BTCUSDT_long"] = &BaselinePositionState{Symbol: "BTCUSDT", Side: "long", EntryPrice: 100, HardStopPrice: 97}
+	marketData := map[string]*market.Data{"BTCUSDT": {Symbol: "BTCUSDT", CurrentPrice: 99, Low: 96.5, High: 100}}
+	positions := []decision.PositionInfo{{Symbol: "BTCUSDT", Side: "long", EntryPrice: 100}}
+
+	decs := engine.CheckPendingStopLoss(marketData, positions)
+	if len(decs) != 1 || decs[0].ExitReason != decision.ExitReasonPendingOHLCStop {
+		t.Fatalf("expected a pending OHLC stop, got %+v", decs)
+	}
+}
+
+func TestCheckPendingStopLoss_IntrabarOrder(t *testing.T) {
+	// 5x leverage with a 10% take-profit target: long target 102 / stop 97, short target 98 / stop 103.
+	// One unit is closed at the bar close of 100 unless the resting order's price is used.
+	tests := []struct {
+		name    string
+		order   string
+		side    string
+		bar     market.Data
+		want    decision.ExitReason
+		wantPnL float64
+	}{
+		{"default assumes stop first", "", "long", market.Data{Open: 101.5, Low: 96.5, High: 102.5}, decision.ExitReasonPendingOHLCStop, -3},
+		{"pessimistic", store.IntrabarOrderPessimistic, "long", market.Data{Open: 101.5, Low: 96.5, High: 102.5}, decision.ExitReasonPendingOHLCStop, -3},
+		{"optimistic", store.IntrabarOrderOptimistic, "long", market.Data{Open: 98, Low: 96.5, High: 102.5}, decision.ExitReasonTakeProfit, 2},
+		{"optimistic without target touch", store.IntrabarOrderOptimistic, "long", market.Data{Open: 101.5, Low: 96.5, High: 101}, decision.ExitReasonPendingOHLCStop, -3},
+		{"open closer to target", store.IntrabarOrderOpenRelative, "long", market.Data{Open: 101.5, Low: 96.5, High: 102.5}, decision.ExitReasonTakeProfit, 2},
+		{"open closer to stop", store.IntrabarOrderOpenRelative, "long", market.Data{Open: 98, Low: 96.5, High: 102.5}, decision.ExitReasonPendingOHLCStop, -3},
+		{"gap through the stop fills at the open", store.IntrabarOrderPessimistic, "long", market.Data{Open: 96, Low: 95, High: 102.5}, decision.ExitReasonPendingOHLCStop, -4},
+		{"short open closer to target", store.IntrabarOrderOpenRelative, "short", market.Data{Open: 98.5, Low: 97.5, High: 103.5}, decision.ExitReasonTakeProfit, 2},
+		{"short open closer to stop", store.IntrabarOrderOpenRelative, "short", market.Data{Open: 102, Low: 97.5, High: 103.5}, decision.ExitReasonPendingOHLCStop, -3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := newTestBaselineEngine(func(cfg *store.StrategyConfig) {
+				cfg.BaselineConfig.RiskManagement.FixedTakeProfitPct = 10
+				cfg.BaselineConfig.RiskManagement.IntrabarOrder = tt.order
+			})
+			stop := 97.0
+			if tt.side == "short" {
+				stop = 103
+			}
+			engine.positionStates["BTCUSDT_"+tt.side] = &BaselinePositionState{Symbol: "BTCUSDT", Side: tt.side, EntryPrice: 100, HardStopPrice: stop}
+			bar := tt.bar
+			bar.Symbol, bar.CurrentPrice = "BTCUSDT", 100
+			positions := []decision.PositionInfo{{Symbol: "BTCUSDT", Side: tt.side, EntryPrice: 100, Leverage: 5}}
+
+			decs := engine.CheckPendingStopLoss(map[string]*market.Data{"BTCUSDT": &bar}, positions)
+			if len(decs) != 1 || decs[0].Action != "close_"+tt.side {
+				t.Fatalf("expected one close_%s, got %+v", tt.side, decs)
+			}
+			if decs[0].ExitReason != tt.want {
+				t.Errorf("ExitReason = %q (%q), expected %q", decs[0].ExitReason, decs[0].Reasoning, tt.want)
+			}
+
+			acc := NewBacktestAccount(10000, 0, 0)
+			if _, _, _, err := acc.Open("BTCUSDT", tt.side, 1, 5, 100, 0); err != nil {
+				t.Fatalf("Open failed: %v", err)
+			}
+			_, pnl, _, _, err := acc.ClosePercent("BTCUSDT", tt.side, 0, baselineFillPrice(decs[0], map[string]float64{"BTCUSDT": 100}))
+			if err != nil {
+				t.Fatalf("ClosePercent failed: %v", err)
+			}
+			if math.Abs(pnl-tt.wantPnL) > 1e-9 {
+				t.Errorf("realized PnL = %.4f, expected %.4f", pnl, tt.wantPnL)
+			}
+		})
+	}
+}
+
+func TestMakeDecision_OnlySelectedEntriesKeepState(t *testing.T) {
+	engine := newTestBaselineEngine(func(cfg *store.StrategyConfig) {
+		cfg.RiskControl.MaxPositions = 1
+	})
+	marketData := map[string]*market.Data{
+		"ADAUSDT": longSetupData("ADAUSDT"),
+		"BTCUSDT": longSetupData("BTCUSDT"),
+		"ETHUSDT": longSetupData("ETHUSDT"),
+	}
+
+	decs := engine.MakeDecision(0, 1000, 1000, marketData, nil)
+	if len(decs) != 1 || decs[0].Symbol != "ADAUSDT" {
+		t.Fatalf("expected only ADAUSDT to be selected, got %+v", decs)
+	}
+	if len(engine.positionStates) != 1 || engine.positionStates["ADAUSDT_long"] == nil {
+		t.Fatalf("expected state only for the selected entry, got %v", engine.positionStates)
+	}
+
+	// Rejected candidates must not count toward the same-direction limit (default 2) next cycle
+	engine.config.RiskControl.MaxPositions = 3
+	positions := []decision.PositionInfo{{Symbol: "ADAUSDT", Side: "long", EntryPrice: 102, MarkPrice: 102}}
+	decs = engine.MakeDecision(0, 1000, 1000, marketData, positions)
+	if len(decs) != 1 || decs[0].Symbol != "BTCUSDT" {
+		t.Fatalf("expected BTCUSDT to fill the second long slot, got %+v", decs)
+	}
+}
+
+func TestMakeDecision_AccountDrawdownHalt(t *testing.T) {
+	engine := newTestBaselineEngine(func(cfg *store.StrategyConfig) {
+		cfg.BaselineConfig.RiskManagement.MaxAccountDrawdownPct = 10
+		cfg.BaselineConfig.RiskManagement.DrawdownResumePct = 5
+	})
+	// step runs one cycle at the given equity with fresh entry signals and no open positions
+	step := func(equity float64) []decision.Decision {
+		engine.positionStates = make(map[string]*BaselinePositionState)
+		return engine.MakeDecision(0, equity, equity, map[string]*market.Data{"BTCUSDT": longSetupData("BTCUSDT")}, nil)
+	}
+	isHalt := func(decs []decision.Decision) bool {
+		return len(decs) == 1 && decs[0].Action == "wait" && strings.Contains(decs[0].Reasoning, "Risk halt")
+	}
+
+	if decs := step(1000); len(decs) != 1 || decs[0].Action != "open_long" {
+		t.Fatalf("expected an entry at peak equity, got %+v", decs)
+	}
+	if decs := step(950); len(decs) != 1 || decs[0].Action != "open_long" {
+		t.Fatalf("expected entries within the drawdown limit, got %+v", decs)
+	}
+	if decs := step(880); !isHalt(decs) {
+		t.Fatalf("expected a risk halt at 12%% drawdown, got %+v", decs)
+	}
+	if decs := step(930); !isHalt(decs) {
+		t.Fatalf("expected the halt to hold until drawdown recovers below 5%%, got %+v", decs)
+	}
+	if decs := step(960); len(decs) != 1 || decs[0].Action != "open_long" {
+		t.Fatalf("expected entries to resume at 4%% drawdown, got %+v", decs)
+	}
+	if engine.peakEquity != 1000 {
+		t.Errorf("peakEquity = %.2f, expected it kept at 1000 across cycles", engine.peakEquity)
+	}
+}
+
+func TestMakeDecision_AccountDrawdownHaltAllowsExits(t *testing.T) {
+	engine := newTestBaselineEngine(func(cfg *store.StrategyConfig) {
+		cfg.BaselineConfig.RiskManagement.MaxAccountDrawdownPct = 10
+	})
+	engine.MakeDecision(0, 1000, 1000, nil, nil)
+	engine.positionStates["ETHUSDT_long"] = &BaselinePositionState{Symbol: "ETHUSDT", Side: "long", EntryPrice: 100, PeakPrice: 100, TrailingStop: 97}
+
+	marketData := map[string]*market.Data{
+		"BTCUSDT": longSetupData("BTCUSDT"),
+		"ETHUSDT": {Symbol: "ETHUSDT", CurrentPrice: 96},
+	}
+	positions := []decision.PositionInfo{{Symbol: "ETHUSDT", Side: "long", EntryPrice: 100, MarkPrice: 96, UnrealizedPnLPct: -20}}
+	decs := engine.MakeDecision(0, 800, 800, marketData, positions)
+	if len(decs) != 2 || decs[0].Action != "close_long" || decs[1].Action != "wait" {
+		t.Fatalf("expected the hard stop exit followed by the risk halt, got %+v", decs)
+	}
+}
+
+func TestGenerateScoredDecision_LeverageTiers(t *testing.T) {
+	newEngine := func(mutate func(rm *store.BaselineRiskManagement)) *BaselineEngine {
+		return newTestBaselineEngine(func(cfg *store.StrategyConfig) {
+			cfg.RiskControl.BTCETHMaxLeverage = 10
+			cfg.RiskControl.AltcoinMaxLeverage = 3
+			cfg.BaselineConfig.RiskManagement.Leverage = 5
+			cfg.BaselineConfig.RiskManagement.EnableLeverageTiers = true
+			if mutate != nil {
+				mutate(&cfg.BaselineConfig.RiskManagement)
+			}
+		})
+	}
+	// 1000 available / 3 max positions × leverage
+	check := func(engine *BaselineEngine, symbol string, leverage int) {
+		t.Helper()
+		dec := engine.generateScoredDecision(symbol, longSetupData(symbol), 1000, 1000)
+		if dec == nil {
+			t.Fatalf("expected %s entry", symbol)
+		}
+		if dec.Decision.Leverage != leverage {
+			t.Errorf("%s Leverage = %d, expected %d", symbol, dec.Decision.Leverage, leverage)
+		}
+		if want := 1000.0 / 3 * float64(leverage); math.Abs(dec.Decision.PositionSizeUSD-want) > 1e-9 {
+			t.Errorf("%s PositionSizeUSD = %.2f, expected %.2f", symbol, dec.Decision.PositionSizeUSD, want)
+		}
+	}
+
+	engine := newEngine(nil)
+	check(engine, "BTCUSDT", 10)
+	check(engine, "ETHUSDT", 10)
+	check(engine, "SOLUSDT", 3)
+
+	// Custom major set
+	engine = newEngine(func(rm *store.BaselineRiskManagement) { rm.MajorSymbols = []string{"SOLUSDT"} })
+	check(engine, "SOLUSDT", 10)
+	check(engine, "BTCUSDT", 3)
+
+	// Tiers disabled: one leverage for every symbol
+	engine = newEngine(func(rm *store.BaselineRiskManagement) { rm.EnableLeverageTiers = false })
+	check(engine, "BTCUSDT", 5)
+	check(engine, "SOLUSDT", 5)
+
+	// The backtest's leverage applies without opting into tiers and wins over the configured ones
+	engine = newEngine(func(rm *store.BaselineRiskManagement) { rm.EnableLeverageTiers = false })
+	engine.SetBacktestLeverage(8, 2)
+	check(engine, "BTCUSDT", 8)
+	check(engine, "SOLUSDT", 2)
+}
+
+func TestGenerateScoredDecision_ReadsConfiguredTimeframe(t *testing.T) {
+	twoTimeframes := func() *market.Data {
+		return &market.Data{
+			Symbol:       "BTCUSDT",
+			CurrentPrice: 102,
+			CurrentEMA20: 100,
+			TimeframeData: map[string]*market.TimeframeSeriesData{
+				// 15m sorts first: a death cross and a wide ATR that must be ignored
+				"15m": {Timeframe: "15m", StochRSI_K: []float64{40}, StochRSI_D: []float64{50}, ATR14: 3},
+				"1h":  {Timeframe: "1h", StochRSI_K: []float64{50}, StochRSI_D: []float64{40}, ATR14: 0.5},
+			},
+		}
+	}
+	engine := newTestBaselineEngine(func(cfg *store.StrategyConfig) {
+		cfg.BaselineConfig.SignalTimeframe = "1h"
+		cfg.BaselineConfig.RiskManagement.EnableATRStop = true
+		cfg.BaselineConfig.RiskManagement.ATRStopMultiple = 2
+	})
+
+	// Repeat to catch any dependence on map iteration order
+	for i := 0; i < 50; i++ {
+		data := twoTimeframes()
+		dec := engine.generateScoredDecision("BTCUSDT", data, 1000, 1000)
+		if dec == nil {
+			t.Fatalf("iteration %d: expected long entry from the 1h StochRSI cross", i)
+		}
+		if math.Abs(dec.Decision.StopLoss-101) > 1e-9 {
+			t.Fatalf("iteration %d: StopLoss = %.4f, expected 101 (102 - 2×1h ATR 0.5)", i, dec.Decision.StopLoss)
+		}
+		if k, d := engine.getStochRSIForTF(data, engine.signalTimeframe()); k != 50 || d != 40 {
+			t.Fatalf("iteration %d: StochRSI = %.0f/%.0f, expected 1h values 50/40", i, k, d)
+		}
+	}
+
+	// Without a configured timeframe the lexically first timeframe wins every time
+	unconfigured := newTestBaselineEngine(nil)
+	for i := 0; i < 50; i++ {
+		data := twoTimeframes()
+		if atr := unconfigured.getATRForTF(data, unconfigured.signalTimeframe()); atr != 3 {
+			t.Fatalf("iteration %d: ATR = %.2f, expected 15m value 3", i, atr)
+		}
+		if k, d := unconfigured.getStochRSIForTF(data, ""); k != 40 || d != 50 {
+			t.Fatalf("iteration %d: StochRSI = %.0f/%.0f, expected 15m values 40/50", i, k, d)
+		}
+	}
+}
+
+func TestGenerateScoredDecision_StochCrossConfirmation(t *testing.T) {
+	withStoch := func(k, d []float64) *market.Data {
+		data := longSetupData("BTCUSDT")
+		data.TimeframeData["1h"].StochRSI_K = k
+		data.TimeframeData["1h"].StochRSI_D = d
+		return data
+	}
+	// Single-bar fakeout: K only crossed above D on the latest bar
+	fakeout := withStoch([]float64{38, 39, 50}, []float64{42, 43, 40})
+	// Sustained cross: K has been above D for three bars
+	sustained := withStoch([]float64{46, 48, 50}, []float64{42, 41, 40})
+
+	confirmed := newTestBaselineEngine(func(cfg *store.StrategyConfig) {
+		cfg.BaselineConfig.SignalThresholds.StochCrossConfirmBars = 3
+	})
+	if dec := confirmed.generateScoredDecision("BTCUSDT", fakeout, 1000, 1000); dec != nil {
+		t.Errorf("single-bar fakeout should not score with 3-bar confirmation, got %+v", dec.Decision)
+	}
+	if dec := confirmed.generateScoredDecision("BTCUSDT", sustained, 1000, 1000); dec == nil || dec.Decision.Action != "open_long" {
+		t.Errorf("sustained cross should open long with 3-bar confirmation, got %+v", dec)
+	}
+
+	// Default confirmation of one bar keeps the latest-bar behavior
+	if dec := newTestBaselineEngine(nil).generateScoredDecision("BTCUSDT", fakeout, 1000, 1000); dec == nil {
+		t.Error("default confirmation should still score the latest-bar cross")
+	}
+}
+
+func TestStochCrossHeld(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		k, d []float64
+		bars int
+		want int
+	}{
+		{"held above", []float64{50, 52, 55}, []float64{40, 41, 42}, 3, 1},
+		{"held below", []float64{30, 29}, []float64{40, 41}, 2, -1},
+		{"flipped inside window", []float64{30, 52}, []float64{40, 41}, 2, 0},
+		{"touch is not a cross", []float64{50, 41}, []float64{40, 41}, 2, 0},
+		{"too few bars", []float64{55}, []float64{42}, 2, 0},
+		{"zero bars means one", []float64{30, 55}, []float64{40, 42}, 0, 1},
+	} {
+		if got := stochCrossHeld(tc.k, tc.d, tc.bars); got != tc.want {
+			t.Errorf("%s: stochCrossHeld = %d, expected %d", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestMakeDecision_FixedTakeProfit(t *testing.T) {
+	newEngine := func(scaleOut bool) *BaselineEngine {
+		engine := newTestBaselineEngine(func(cfg *store.StrategyConfig) {
+			rm := &cfg.BaselineConfig.RiskManagement
+			rm.FixedTakeProfitPct = 5
+			rm.EnableScaleOut = scaleOut
+			rm.ScaleOutFraction = 0.5
+			rm.TrailingTP1Pct = 2
+			rm.TrailingTP2Pct = 8 // above the target: only the first stage can fire
+		})
+		engine.positionStates["BTCUSDT_long"] = &BaselinePositionState{Symbol: "BTCUSDT", Side: "long", EntryPrice: 100, PeakPrice: 100, TrailingStop: 97}
+		return engine
+	}
+	step := func(engine *BaselineEngine, price, pnlPct float64) []decision.Decision {
+		marketData := map[string]*market.Data{"BTCUSDT": {Symbol: "BTCUSDT", CurrentPrice: price}}
+		positions := []decision.PositionInfo{{Symbol: "BTCUSDT", Side: "long", EntryPrice: 100, MarkPrice: price, UnrealizedPnLPct: pnlPct}}
+		return engine.MakeDecision(0, 1000, 0, marketData, positions)
+	}
+
+	// Target alone: nothing below it, a full close once reached
+	engine := newEngine(false)
+	if decs := step(engine, 104.5, 4.5); len(decs) != 0 {
+		t.Fatalf("expected no exit below the 5%% target, got %+v", decs)
+	}
+	decs := step(engine, 105, 5)
+	if len(decs) != 1 || decs[0].Action != "close_long" || decs[0].CloseFraction != 0 ||
+		decs[0].ExitReason != decision.ExitReasonTakeProfit {
+		t.Fatalf("expected a full take-profit close at 5%%, got %+v", decs)
+	}
+
+	// Combined with scale-out: TP1 scales out first, the target closes the remainder
+	engine = newEngine(true)
+	if decs := step(engine, 102.2, 2.2); len(decs) != 1 || decs[0].ExitReason != decision.ExitReasonScaleOut {
+		t.Fatalf("expected a scale-out at TP1, got %+v", decs)
+	}
+	decs = step(engine, 106, 6)
+	if len(decs) != 1 || decs[0].CloseFraction != 0 || decs[0].ExitReason != decision.ExitReasonTakeProfit {
+		t.Fatalf("expected the target to close the rest after scaling out, got %+v", decs)
+	}
+
+	// Disabled by default
+	engine = newTestBaselineEngine(nil)
+	engine.positionStates["BTCUSDT_long"] = &BaselinePositionState{Symbol: "BTCUSDT", Side: "long", EntryPrice: 100, PeakPrice: 100, TrailingStop: 97}
+	for _, dec := range step(engine, 107.5, 7.5) {
+		if dec.ExitReason == decision.ExitReasonTakeProfit {
+			t.Fatalf("fixed take profit should be off by default, got %+v", dec)
+		}
+	}
+}
+
+// psarKlines builds a steady uptrend of +2 hourly bars that reverses sharply on the last three
+// bars (the first of them breaks below the rising SAR)
+func psarKlines() []market.KlineBar {
+	klines := make([]market.KlineBar, 0, 13)
+	for i := 0; i < 10; i++ {
+		c := 100 + float64(i)*2
+		klines = append(klines, market.KlineBar{Open: c - 1, High: c + 1, Low: c - 1.5, Close: c})
+	}
+	klines = append(klines,
+		market.KlineBar{Open: 118, High: 118.5, Low: 108, Close: 108.5},
+		market.KlineBar{Open: 108.5, High: 109.5, Low: 107, Close: 107.5},
+		market.KlineBar{Open: 107.5, High: 108, Low: 106, Close: 106.5},
+	)
+	for i := range klines {
+		klines[i].Time = int64(i+1) * 3600_000
+	}
+	return klines
+}
+
+// mirrorKlines reflects prices around 200 so an uptrend becomes the equivalent downtrend
+func mirrorKlines(klines []market.KlineBar) []market.KlineBar {
+	mirrored := make([]market.KlineBar, len(klines))
+	for i, k := range klines {
+		mirrored[i] = market.KlineBar{Time: k.Time, Open: 200 - k.Open, High: 200 - k.Low, Low: 200 - k.High, Close: 200 - k.Close}
+	}
+	return mirrored
+}
+
+func TestParabolicSAR(t *testing.T) {
+	klines := psarKlines()
+	sar, uptrend := parabolicSAR(klines, 0.02, 0.2)
+	if len(sar) != len(klines) || len(uptrend) != len(klines) {
+		t.Fatalf("got %d SAR values and %d trends for %d bars", len(sar), len(uptrend), len(klines))
+	}
+	for i := 0; i < 10; i++ {
+		if !uptrend[i] || sar[i] > klines[i].Low {
+			t.Fatalf("bar %d: uptrend=%v sar=%.3f, expected a rising SAR at or below the low %.1f", i, uptrend[i], sar[i], klines[i].Low)
+		}
+		if i > 0 && sar[i] < sar[i-1] {
+			t.Fatalf("bar %d: SAR fell from %.3f to %.3f during the uptrend", i, sar[i-1], sar[i])
+		}
+	}
+	// The flip resets the SAR to the uptrend's extreme point (the 119 high)
+	if uptrend[10] || sar[10] != 119 {
+		t.Errorf("flip bar: uptrend=%v sar=%.3f, expected downtrend with SAR 119", uptrend[10], sar[10])
+	}
+	if uptrend[11] || uptrend[12] {
+		t.Error("expected the downtrend to hold after the flip")
+	}
+
+	_, mirrored := parabolicSAR(mirrorKlines(klines), 0.02, 0.2)
+	for i := range mirrored {
+		if mirrored[i] == uptrend[i] {
+			t.Fatalf("bar %d: mirrored trend should be the opposite of the original", i)
+		}
+	}
+	if sar, _ := parabolicSAR(klines[:1], 0.02, 0.2); sar != nil {
+		t.Error("expected no SAR for a single bar")
+	}
+}
+
+func TestMakeDecision_PSARExit(t *testing.T) {
+	// run feeds the bars after the entry on bar 9 (skipping bar skip) and returns the exit bar
+	run := func(enable bool, side string, klines []market.KlineBar, skip int) (exitBar int) {
+		engine := newTestBaselineEngine(func(cfg *store.StrategyConfig) {
+			cfg.Indicators.EnablePSAR = enable
+			cfg.BaselineConfig.RiskManagement.HardStopLossPct = 15 // leave room for the reversal bar
+		})
+		entry := klines[9].Close - 1
+		if side == "short" {
+			entry = klines[9].Close + 1
+		}
+		engine.positionStates["BTCUSDT_"+side] = &BaselinePositionState{
+			Symbol: "BTCUSDT", Side: side, EntryPrice: entry, PeakPrice: entry, EntryBarTime: klines[9].Time,
+		}
+		for bar := 9; bar < len(klines); bar++ {
+			if bar == skip {
+				continue
+			}
+			price := klines[bar].Close
+			pnlPct := (price - entry) / entry * 100
+			if side == "short" {
+				pnlPct = -pnlPct
+			}
+			marketData := map[string]*market.Data{"BTCUSDT": {
+				Symbol:       "BTCUSDT",
+				CurrentPrice: price,
+				TimeframeData: map[string]*market.TimeframeSeriesData{
+					"1h": {Timeframe: "1h", Klines: klines[:bar+1]},
+				},
+			}}
+			positions := []decision.PositionInfo{{Symbol: "BTCUSDT", Side: side, EntryPrice: entry, MarkPrice: price, UnrealizedPnLPct: pnlPct}}
+			for _, dec := range engine.MakeDecision(0, 1000, 0, marketData, positions) {
+				if dec.Action == "close_"+side {
+					if dec.ExitReason != decision.ExitReasonPSAR {
+						t.Fatalf("%s bar %d: unexpected exit %+v", side, bar, dec)
+					}
+					if exitBar != 0 {
+						t.Fatalf("%s: PSAR exit fired again on bar %d after bar %d", side, bar, exitBar)
+					}
+					exitBar = bar
+				}
+			}
+		}
+		return exitBar
+	}
+
+	if got := run(true, "long", psarKlines(), 0); got != 10 {
+		t.Errorf("long PSAR exit on bar %d, expected the flip bar 10", got)
+	}
+	if got := run(true, "short", mirrorKlines(psarKlines()), 0); got != 10 {
+		t.Errorf("short PSAR exit on bar %d, expected the flip bar 10", got)
+	}
+	// A skipped flip bar (decision cadence) still exits on the next evaluated bar
+	if got := run(true, "long", psarKlines(), 10); got != 11 {
+		t.Errorf("long PSAR exit on bar %d, expected bar 11 after the skipped flip", got)
+	}
+	if got := run(false, "long", psarKlines(), 0); got != 0 {
+		t.Errorf("PSAR exit fired on bar %d while disabled", got)
+	}
+
+	// A position opened after the flip is not closed by it
+	engine := newTestBaselineEngine(func(cfg *store.StrategyConfig) {
+		cfg.Indicators.EnablePSAR = true
+		cfg.BaselineConfig.RiskManagement.HardStopLossPct = 15
+	})
+	klines := psarKlines()
+	data := &market.Data{TimeframeData: map[string]*market.TimeframeSeriesData{"1h": {Timeframe: "1h", Klines: klines}}}
+	if engine.psarReversed(data, engine.config.BaselineConfig, &BaselinePositionState{Side: "long", EntryBarTime: klines[11].Time}) {
+		t.Error("expected no PSAR exit for a long opened after the flip")
+	}
+	if engine.psarReversed(data, engine.config.BaselineConfig, &BaselinePositionState{Side: "short", EntryBarTime: klines[11].Time}) {
+		t.Error("expected no PSAR exit for a short in the SAR's direction")
+	}
+}
+
+func TestGenerateScoredDecision_DegenerateIndicators(t *testing.T) {
+	engine := newTestBaselineEngine(func(cfg *store.StrategyConfig) {
+		cfg.Indicators.EnableVolume = true
+		cfg.Indicators.EnableVWAP = true
+	})
+
+	// Zero EMA: the EMA branch is skipped rather than dividing by zero
+	zeroEMA := longSetupData("BTCUSDT")
+	zeroEMA.CurrentEMA20 = 0
+	if dec := engine.generateScoredDecision("BTCUSDT", zeroEMA, 1000, 1000); dec != nil {
+		t.Fatalf("expected no entry without the EMA signal, got %+v", dec.Decision)
+	}
+
+	// NaN/zero prices and NaN EMA never produce a candidate
+	for name, mutate := range map[string]func(*market.Data){
+		"zero price": func(d *market.Data) { d.CurrentPrice = 0 },
+		"NaN price":  func(d *market.Data) { d.CurrentPrice = math.NaN() },
+		"Inf price":  func(d *market.Data) { d.CurrentPrice = math.Inf(1) },
+		"NaN EMA":    func(d *market.Data) { d.CurrentEMA20 = math.NaN() },
+	} {
+		data := longSetupData("BTCUSDT")
+		mutate(data)
+		if dec := engine.generateScoredDecision("BTCUSDT", data, 1000, 1000); dec != nil {
+			t.Errorf("%s: expected no entry, got %+v (score %v)", name, dec.Decision, dec.Score)
+		}
+	}
+
+	// Zero and NaN volumes leave the score untouched instead of turning it into NaN
+	for name, volume := range map[string]float64{"zero volume": 0, "NaN volume": math.NaN()} {
+		data := longSetupData("BTCUSDT")
+		klines := klinesWithLastClose(21, 100)
+		for i := range klines[:20] {
+			klines[i].Volume = volume
+		}
+		data.TimeframeData["1h"].Klines = klines
+		if ratio := engine.getVolumeRatio(data); ratio != 0 {
+			t.Errorf("%s: volume ratio = %v, expected 0", name, ratio)
+		}
+		dec := engine.generateScoredDecision("BTCUSDT", data, 1000, 1000)
+		if dec == nil {
+			t.Fatalf("%s: expected long entry", name)
+		}
+		if math.IsNaN(dec.Score) || math.IsInf(dec.Score, 0) {
+			t.Errorf("%s: score = %v, expected a finite score", name, dec.Score)
+		}
+	}
+}
+
+func TestSelectBestDecisions_SkipsNonFiniteScores(t *testing.T) {
+	engine := newTestBaselineEngine(nil)
+	candidates := []ScoredDecision{
+		{Decision: decision.Decision{Symbol: "ETHUSDT"}, Score: math.NaN()},
+		{Decision: decision.Decision{Symbol: "BTCUSDT"}, Score: 40},
+		{Decision: decision.Decision{Symbol: "SOLUSDT"}, Score: math.Inf(1)},
+		{Decision: decision.Decision{Symbol: "XRPUSDT"}, Score: 60},
+	}
+
+	selected := engine.selectBestDecisions(candidates, 0)
+	if len(selected) != 2 || selected[0].Symbol != "XRPUSDT" || selected[1].Symbol != "BTCUSDT" {
+		t.Fatalf("expected only finite-score candidates in score order, got %+v", selected)
+	}
+}
+
+func TestMakeDecision_EntryCadence(t *testing.T) {
+	engine := newTestBaselineEngine(func(cfg *store.StrategyConfig) {
+		cfg.BaselineConfig.EntryCadenceBars = 3
+	})
+	entry := map[string]*market.Data{"BTCUSDT": longSetupData("BTCUSDT")}
+
+	var entryCalls []int
+	for call := 1; call <= 7; call++ {
+		decs := engine.MakeDecision(0, 1000, 1000, entry, nil)
+		if len(decs) == 1 && decs[0].Action == "open_long" {
+			entryCalls = append(entryCalls, call)
+			delete(engine.positionStates, "BTCUSDT_long") // forget the fill so the next boundary can enter again
+		}
+	}
+	want := []int{1, 4, 7}
+	if len(entryCalls) != len(want) {
+		t.Fatalf("entries on calls %v, expected only cadence boundaries %v", entryCalls, want)
+	}
+	for i := range want {
+		if entryCalls[i] != want[i] {
+			t.Fatalf("entries on calls %v, expected only cadence boundaries %v", entryCalls, want)
+		}
+	}
+
+	// Exits are checked on off-cadence bars too (call 8)
+	engine.positionStates["BTCUSDT_long"] = &BaselinePositionState{Symbol: "BTCUSDT", Side: "long", EntryPrice: 100, PeakPrice: 100, TrailingStop: 97}
+	marketData := map[string]*market.Data{"BTCUSDT": {Symbol: "BTCUSDT", CurrentPrice: 96}}
+	positions := []decision.PositionInfo{{Symbol: "BTCUSDT", Side: "long", EntryPrice: 100, MarkPrice: 96, UnrealizedPnLPct: -20}}
+	if decs := engine.MakeDecision(0, 1000, 1000, marketData, positions); len(decs) != 1 || decs[0].Action != "close_long" {
+		t.Fatalf("expected the hard stop to close off-cadence, got %+v", decs)
+	}
+
+	// The backtest runner overrides the cadence: it only calls on decision bars
+	engine = newTestBaselineEngine(func(cfg *store.StrategyConfig) {
+		cfg.BaselineConfig.EntryCadenceBars = 3
+	})
+	engine.SetEntryCadenceBars(1)
+	for call := 1; call <= 3; call++ {
+		if decs := engine.MakeDecision(0, 1000, 1000, entry, nil); len(decs) != 1 {
+			t.Fatalf("call %d: expected an entry with the cadence overridden, got %+v", call, decs)
+		}
+		delete(engine.positionStates, "BTCUSDT_long")
+	}
+
+	// Without a cadence every call evaluates entries
+	engine = newTestBaselineEngine(nil)
+	for call := 1; call <= 3; call++ {
+		if decs := engine.MakeDecision(0, 1000, 1000, entry, nil); len(decs) != 1 {
+			t.Fatalf("call %d: expected an entry without cadence, got %+v", call, decs)
+		}
+		delete(engine.positionStates, "BTCUSDT_long")
+	}
+}
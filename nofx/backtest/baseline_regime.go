@@ -0,0 +1,101 @@
+package backtest
+
+import (
+	"nofx/market"
+	"nofx/store"
+)
+
+// MarketRegime 市场状态分类
+type MarketRegime string
+
+const (
+	RegimeUnknown  MarketRegime = "unknown"  // 指标数据不足
+	RegimeTrending MarketRegime = "trending" // 趋势行情：偏重趋势跟随信号
+	RegimeRanging  MarketRegime = "ranging"  // 震荡行情：偏重均值回归信号
+)
+
+// 市场状态对两类信号评分的缩放：占优信号放大，另一类减半
+const (
+	regimeFavoredScale    = 1.5
+	regimeDisfavoredScale = 0.5
+)
+
+// RegimeDetector 基于 ADX 和布林带宽度判断市场状态
+// ADX 高于 TrendADX 为趋势，低于 RangeADX 为震荡，介于两者之间时由布林带宽度决定
+type RegimeDetector struct {
+	TrendADX        float64 // ADX 达到此值视为趋势，默认 25
+	RangeADX        float64 // ADX 低于此值视为震荡，默认 20
+	MinBandwidthPct float64 // 布林带宽度（占中轨 %）低于此值视为震荡，默认 4.0
+}
+
+// NewRegimeDetector 根据 Baseline 配置创建市场状态检测器（未配置的阈值使用默认值）
+func NewRegimeDetector(cfg *store.BaselineConfig) RegimeDetector {
+	d := RegimeDetector{TrendADX: 25, RangeADX: 20, MinBandwidthPct: 4.0}
+	if cfg == nil {
+		return d
+	}
+	if cfg.RegimeTrendADX > 0 {
+		d.TrendADX = cfg.RegimeTrendADX
+	}
+	if cfg.RegimeRangeADX > 0 {
+		d.RangeADX = cfg.RegimeRangeADX
+	}
+	if cfg.RegimeMinBandwidthPct > 0 {
+		d.MinBandwidthPct = cfg.RegimeMinBandwidthPct
+	}
+	return d
+}
+
+// Classify 根据 ADX 和布林带宽度（%）分类，值为 0 表示该指标不可用
+func (d RegimeDetector) Classify(adx, bandwidthPct float64) MarketRegime {
+	switch {
+	case adx >= d.TrendADX:
+		return RegimeTrending
+	case adx > 0 && adx < d.RangeADX:
+		return RegimeRanging
+	case bandwidthPct <= 0:
+		return RegimeUnknown
+	case bandwidthPct >= d.MinBandwidthPct:
+		return RegimeTrending
+	default:
+		return RegimeRanging
+	}
+}
+
+// detectRegime 计算币种的 ADX 和布林带宽度并判断市场状态
+func (e *BaselineEngine) detectRegime(data *market.Data, cfg *store.BaselineConfig) MarketRegime {
+	period := cfg.BollingerPeriod
+	if period <= 0 {
+		period = 20 // 默认值
+	}
+	stdDevMult := cfg.BollingerStdDev
+	if stdDevMult <= 0 {
+		stdDevMult = 2.0 // 默认值
+	}
+
+	bandwidthPct := 0.0
+	if upper, middle, lower, _, ok := e.getBollingerBands(data, period, stdDevMult); ok && middle > 0 {
+		bandwidthPct = (upper - lower) / middle * 100
+	}
+	return NewRegimeDetector(cfg).Classify(e.getADX(data), bandwidthPct)
+}
+
+// regimeScales 返回趋势跟随信号和均值回归信号在该市场状态下的评分缩放
+func regimeScales(regime MarketRegime) (trendScale, reversionScale float64) {
+	switch regime {
+	case RegimeTrending:
+		return regimeFavoredScale, regimeDisfavoredScale
+	case RegimeRanging:
+		return regimeDisfavoredScale, regimeFavoredScale
+	default:
+		return 1, 1
+	}
+}
+
+// regimeReasoning 在决策理由中标注市场状态（未启用检测时保持原样）
+func regimeReasoning(reasoning string, regime MarketRegime) string {
+	if regime == RegimeUnknown {
+		return reasoning
+	}
+	return reasoning + " (regime: " + string(regime) + ")"
+}
@@ -0,0 +1,112 @@
+package backtest
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"nofx/market"
+	"nofx/store"
+)
+
+// regimeData builds market data whose 1h series is computed from closes like live data
+func regimeData(closes []float64) *market.Data {
+	klines := make([]market.Kline, len(closes))
+	for i, c := range closes {
+		klines[i] = market.Kline{OpenTime: int64(i) * 3600_000, Open: c, High: c * 1.005, Low: c * 0.995, Close: c, Volume: 1000}
+	}
+	return &market.Data{
+		Symbol:        "BTCUSDT",
+		CurrentPrice:  closes[len(closes)-1],
+		TimeframeData: map[string]*market.TimeframeSeriesData{"1h": market.BuildTimeframeSeriesData("1h", klines, nil)},
+	}
+}
+
+func TestDetectRegime(t *testing.T) {
+	trending := make([]float64, 60)
+	choppy := make([]float64, 60)
+	for i := range trending {
+		trending[i] = 100 * math.Pow(1.01, float64(i))
+		choppy[i] = 100 + float64(i%2)
+	}
+
+	engine := newTestBaselineEngine(nil)
+	cfg := engine.config.BaselineConfig
+	if got := engine.detectRegime(regimeData(trending), cfg); got != RegimeTrending {
+		t.Errorf("trending closes classified as %s", got)
+	}
+	if got := engine.detectRegime(regimeData(choppy), cfg); got != RegimeRanging {
+		t.Errorf("choppy closes classified as %s", got)
+	}
+	if got := engine.detectRegime(longSetupData("BTCUSDT"), cfg); got != RegimeUnknown {
+		t.Errorf("data without ADX or klines classified as %s", got)
+	}
+}
+
+func TestRegimeDetectorClassify(t *testing.T) {
+	d := NewRegimeDetector(&store.BaselineConfig{RegimeTrendADX: 30})
+	tests := []struct {
+		adx, bandwidth float64
+		want           MarketRegime
+	}{
+		{35, 0, RegimeTrending},
+		{25, 0, RegimeUnknown},  // undecided ADX, no bandwidth
+		{25, 6, RegimeTrending}, // undecided ADX, wide bands
+		{25, 2, RegimeRanging},  // undecided ADX, narrow bands
+		{15, 10, RegimeRanging}, // low ADX wins over bandwidth
+		{0, 2, RegimeRanging},   // bandwidth only
+	}
+	for _, tt := range tests {
+		if got := d.Classify(tt.adx, tt.bandwidth); got != tt.want {
+			t.Errorf("Classify(%g, %g) = %s, expected %s", tt.adx, tt.bandwidth, got, tt.want)
+		}
+	}
+}
+
+func TestGenerateScoredDecision_RegimeFavorsSignalSet(t *testing.T) {
+	// Long trend signals (EMA 10 + StochRSI 52.5) together with a Bollinger lower-band break (20)
+	newData := func(adx float64) *market.Data {
+		data := longSetupData("BTCUSDT")
+		data.TimeframeData["1h"].Klines = klinesWithLastClose(20, 90)
+		data.TimeframeData["1h"].ADX14 = adx
+		return data
+	}
+	const trendScore, bollingerScore = 62.5, 20.0
+
+	tests := []struct {
+		name   string
+		adx    float64
+		score  float64
+		regime string
+	}{
+		{"trending", 40, trendScore*regimeFavoredScale + bollingerScore*regimeDisfavoredScale, "(regime: trending)"},
+		{"ranging", 10, trendScore*regimeDisfavoredScale + bollingerScore*regimeFavoredScale, "(regime: ranging)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := newTestBaselineEngine(func(cfg *store.StrategyConfig) {
+				cfg.Indicators.EnableBollinger = true
+				cfg.Indicators.EnableRegime = true
+			})
+			dec := engine.generateScoredDecision("BTCUSDT", newData(tt.adx), 1000, 1000)
+			if dec == nil {
+				t.Fatal("expected long entry")
+			}
+			if math.Abs(dec.Score-tt.score) > 1e-9 {
+				t.Errorf("Score = %.4f, expected %.4f", dec.Score, tt.score)
+			}
+			if !strings.Contains(dec.Decision.Reasoning, tt.regime) {
+				t.Errorf("Reasoning = %q, expected it to mention %q", dec.Decision.Reasoning, tt.regime)
+			}
+		})
+	}
+
+	// Without regime detection both signal sets keep their plain weights
+	engine := newTestBaselineEngine(func(cfg *store.StrategyConfig) {
+		cfg.Indicators.EnableBollinger = true
+	})
+	dec := engine.generateScoredDecision("BTCUSDT", newData(40), 1000, 1000)
+	if dec == nil || math.Abs(dec.Score-(trendScore+bollingerScore)) > 1e-9 || strings.Contains(dec.Decision.Reasoning, "regime") {
+		t.Fatalf("expected unscaled score without regime detection, got %+v", dec)
+	}
+}
@@ -0,0 +1,44 @@
+package backtest
+
+import (
+	"context"
+	"sync"
+)
+
+// ConcurrencyLimiter is a counting semaphore bounding how many backtests run at once.
+// A nil limiter never blocks.
+type ConcurrencyLimiter struct {
+	slots chan struct{}
+}
+
+// NewConcurrencyLimiter returns a limiter allowing n concurrent holders, or nil
+// (unlimited) when n <= 0
+func NewConcurrencyLimiter(n int) *ConcurrencyLimiter {
+	if n <= 0 {
+		return nil
+	}
+	return &ConcurrencyLimiter{slots: make(chan struct{}, n)}
+}
+
+// Acquire blocks until a slot is free or ctx is done. The returned release is
+// safe to call more than once.
+func (l *ConcurrencyLimiter) Acquire(ctx context.Context) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+	select {
+	case l.slots <- struct{}{}:
+		var once sync.Once
+		return func() { once.Do(func() { <-l.slots }) }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// InUse reports how many slots are currently held
+func (l *ConcurrencyLimiter) InUse() int {
+	if l == nil {
+		return 0
+	}
+	return len(l.slots)
+}
@@ -0,0 +1,98 @@
+package backtest
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConcurrencyLimiterBoundsHolders(t *testing.T) {
+	limiter := NewConcurrencyLimiter(2)
+
+	var mu sync.Mutex
+	running, maxRunning := 0, 0
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := limiter.Acquire(context.Background())
+			if err != nil {
+				t.Errorf("Acquire failed: %v", err)
+				return
+			}
+			mu.Lock()
+			running++
+			maxRunning = max(maxRunning, running)
+			mu.Unlock()
+			time.Sleep(10 * time.Millisecond)
+			mu.Lock()
+			running--
+			mu.Unlock()
+			release()
+			release() // releasing twice must not free a second slot
+		}()
+	}
+	wg.Wait()
+
+	if maxRunning != 2 {
+		t.Errorf("max concurrent holders = %d, expected 2", maxRunning)
+	}
+	if n := limiter.InUse(); n != 0 {
+		t.Errorf("InUse = %d after all releases, expected 0", n)
+	}
+}
+
+func TestConcurrencyLimiterAcquireHonorsContext(t *testing.T) {
+	limiter := NewConcurrencyLimiter(1)
+	release, err := limiter.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := limiter.Acquire(ctx); err != context.DeadlineExceeded {
+		t.Errorf("expected deadline exceeded while the only slot is held, got %v", err)
+	}
+}
+
+func TestConcurrencyLimiterUnlimited(t *testing.T) {
+	limiter := NewConcurrencyLimiter(0)
+	for i := 0; i < 10; i++ {
+		if _, err := limiter.Acquire(context.Background()); err != nil {
+			t.Fatalf("unlimited Acquire failed: %v", err)
+		}
+	}
+}
+
+func TestManagerStartWaitsForSlot(t *testing.T) {
+	m := NewManager(nil)
+	m.SetMaxConcurrent(1)
+	release, err := m.acquireSlot(context.Background())
+	if err != nil {
+		t.Fatalf("acquireSlot failed: %v", err)
+	}
+
+	// Every Start, including API-started runs, queues behind the held slot
+	cfg := BacktestConfig{
+		RunID:   "queued-run",
+		Symbols: []string{"BTCUSDT"},
+		StartTS: 1700000000,
+		EndTS:   1700086400,
+		AICfg:   AIConfig{Provider: "openai", APIKey: "test-key"},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := m.Start(ctx, cfg); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected Start to wait for the held slot until the deadline, got %v", err)
+	}
+
+	release()
+	if n := m.limiter.InUse(); n != 0 {
+		t.Errorf("expected no slot held after the abandoned Start, got %d", n)
+	}
+}
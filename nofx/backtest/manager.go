@@ -21,6 +21,8 @@ type Manager struct {
 	cancels    map[string]context.CancelFunc
 	mcpClient  mcp.AIClient
 	aiResolver AIConfigResolver
+	limiter    *ConcurrencyLimiter // nil means unlimited
+	releases   map[*Runner]func()  // frees the backtest slot held by each active runner
 }
 
 type AIConfigResolver func(*BacktestConfig) error
@@ -30,6 +32,7 @@ func NewManager(defaultClient mcp.AIClient) *Manager {
 		runners:   make(map[string]*Runner),
 		metadata:  make(map[string]*RunMetadata),
 		cancels:   make(map[string]context.CancelFunc),
+		releases:  make(map[*Runner]func()),
 		mcpClient: defaultClient,
 	}
 }
@@ -40,6 +43,40 @@ func (m *Manager) SetAIResolver(resolver AIConfigResolver) {
 	m.aiResolver = resolver
 }
 
+// SetMaxConcurrent caps how many backtests run at once; n <= 0 removes the cap. Every
+// run started or resumed through this Manager (API and evolutions alike) shares the cap.
+func (m *Manager) SetMaxConcurrent(n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.limiter = NewConcurrencyLimiter(n)
+}
+
+// acquireSlot waits for a free backtest slot or until ctx is done
+func (m *Manager) acquireSlot(ctx context.Context) (func(), error) {
+	m.mu.RLock()
+	limiter := m.limiter
+	m.mu.RUnlock()
+	release, err := limiter.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("waiting for a backtest slot: %w", err)
+	}
+	return release, nil
+}
+
+// releaseSlot frees the backtest slot held by a runner, if any. Slots are tracked per runner
+// rather than per run ID, so a run restarted under the same ID keeps its new slot.
+func (m *Manager) releaseSlot(runner *Runner) {
+	m.mu.Lock()
+	release := m.releases[runner]
+	delete(m.releases, runner)
+	m.mu.Unlock()
+	if release != nil {
+		release()
+	}
+}
+
+// Start starts a backtest. When SetMaxConcurrent caps the number of runs it first waits for
+// a free slot, until ctx is done; the slot is held until the run finishes or is deleted.
 func (m *Manager) Start(ctx context.Context, cfg BacktestConfig) (*Runner, error) {
 	if err := cfg.Validate(); err != nil {
 		return nil, err
@@ -61,14 +98,21 @@ func (m *Manager) Start(ctx context.Context, cfg BacktestConfig) (*Runner, error
 	}
 	m.mu.Unlock()
 
+	release, err := m.acquireSlot(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	persistCfg := cfg
 	persistCfg.AICfg.APIKey = ""
 	if err := SaveConfig(cfg.RunID, &persistCfg); err != nil {
+		release()
 		return nil, err
 	}
 
 	runner, err := NewRunner(cfg, m.client())
 	if err != nil {
+		release()
 		return nil, err
 	}
 
@@ -78,10 +122,12 @@ func (m *Manager) Start(ctx context.Context, cfg BacktestConfig) (*Runner, error
 	if _, exists := m.runners[cfg.RunID]; exists {
 		m.mu.Unlock()
 		cancel()
+		release()
 		return nil, fmt.Errorf("run %s is already active", cfg.RunID)
 	}
 	m.runners[cfg.RunID] = runner
 	m.cancels[cfg.RunID] = cancel
+	m.releases[runner] = release
 	meta := runner.CurrentMetadata()
 	m.metadata[cfg.RunID] = meta
 	m.mu.Unlock()
@@ -93,6 +139,7 @@ func (m *Manager) Start(ctx context.Context, cfg BacktestConfig) (*Runner, error
 		delete(m.cancels, cfg.RunID)
 		delete(m.metadata, cfg.RunID)
 		m.mu.Unlock()
+		m.releaseSlot(runner)
 		runner.releaseLock()
 		return nil, err
 	}
@@ -209,11 +256,19 @@ func (m *Manager) Resume(runID string) error {
 		return err
 	}
 
+	// A run restored from its checkpoint takes a backtest slot like a new one
+	release, err := m.acquireSlot(context.Background())
+	if err != nil {
+		return err
+	}
+
 	restored, err := NewRunner(cfgCopy, m.client())
 	if err != nil {
+		release()
 		return err
 	}
 	if err := restored.RestoreFromCheckpoint(); err != nil {
+		release()
 		return err
 	}
 
@@ -223,10 +278,12 @@ func (m *Manager) Resume(runID string) error {
 	if _, exists := m.runners[runID]; exists {
 		m.mu.Unlock()
 		cancel()
+		release()
 		return fmt.Errorf("run %s is already active", runID)
 	}
 	m.runners[runID] = restored
 	m.cancels[runID] = cancel
+	m.releases[restored] = release
 	m.metadata[runID] = restored.CurrentMetadata()
 	m.mu.Unlock()
 
@@ -237,6 +294,7 @@ func (m *Manager) Resume(runID string) error {
 		delete(m.cancels, runID)
 		delete(m.metadata, runID)
 		m.mu.Unlock()
+		m.releaseSlot(restored)
 		restored.releaseLock()
 		return err
 	}
@@ -317,6 +375,9 @@ func (m *Manager) Delete(runID string) error {
 	delete(m.runners, runID)
 	delete(m.metadata, runID)
 	m.mu.Unlock()
+	if ok {
+		m.releaseSlot(runner)
+	}
 	if err := removeFromRunIndex(runID); err != nil {
 		return err
 	}
@@ -409,6 +470,7 @@ func (m *Manager) launchWatcher(runID string, runner *Runner) {
 		}
 		delete(m.runners, runID)
 		m.mu.Unlock()
+		m.releaseSlot(runner)
 	}()
 }
 
@@ -468,6 +530,10 @@ func (m *Manager) GetTrace(runID string, cycle int) (*store.DecisionRecord, erro
 	return LoadDecisionTrace(runID, cycle)
 }
 
+func (m *Manager) LoadDecisions(runID string, limit, offset int) ([]*store.DecisionRecord, error) {
+	return LoadDecisionRecords(runID, limit, offset)
+}
+
 func (m *Manager) ExportRun(runID string) (string, error) {
 	return CreateRunExport(runID)
 }
@@ -43,6 +43,8 @@ func CalculateMetrics(runID string, cfg *BacktestConfig, state *BacktestState) (
 
 	metrics.MaxDrawdownPct = maxDrawdown(points, state)
 	metrics.SharpeRatio = sharpeRatio(points)
+	metrics.SortinoRatio = sortinoRatio(points)
+	metrics.CalmarRatio = calmarRatio(metrics.TotalReturnPct, metrics.MaxDrawdownPct)
 
 	fillTradeMetrics(metrics, events)
 
@@ -91,23 +93,48 @@ func maxDrawdown(points []EquityPoint, state *BacktestState) float64 {
 	return maxDD
 }
 
-func sharpeRatio(points []EquityPoint) float64 {
+// Sharpe convention: every Sharpe and Sortino ratio reported for a backtest (Metrics, baseline
+// performance records and the evolution's iteration metrics) is annualized with a risk-free rate
+// of 0. The per-period ratio of the equity curve's returns is scaled by sqrt(periods per year),
+// where a period is the average spacing of the equity points, so values are comparable across
+// decision timeframes. A curve without volatility reports the sentinel ±999 instead, except in
+// baseline performance records, which store 0 (see baselineSharpeRatio).
+
+const (
+	yearMillis            = 365 * 24 * 60 * 60 * 1000
+	defaultPeriodsPerYear = 365 // daily, used when the equity points carry no usable timestamps
+)
+
+// periodsPerYear returns how many equity-curve periods fit in a year, from the average spacing of the points.
+func periodsPerYear(points []EquityPoint) float64 {
 	if len(points) < 2 {
-		return 0
+		return defaultPeriodsPerYear
 	}
+	span := points[len(points)-1].Timestamp - points[0].Timestamp
+	if span <= 0 {
+		return defaultPeriodsPerYear
+	}
+	return yearMillis / (float64(span) / float64(len(points)-1))
+}
 
-	returns := make([]float64, 0, len(points)-1)
-	prev := points[0].Equity
-	for i := 1; i < len(points); i++ {
-		curr := points[i].Equity
-		if prev <= 0 {
-			prev = curr
-			continue
-		}
-		ret := (curr - prev) / prev
-		returns = append(returns, ret)
-		prev = curr
+// sharpeRatio is the annualized Sharpe ratio of the equity curve (see the Sharpe convention above).
+func sharpeRatio(points []EquityPoint) float64 {
+	return annualizedSharpe(equityReturns(points), periodsPerYear(points))
+}
+
+// baselineSharpeRatio is sharpeRatio for baseline performance records, which keep 0 rather than
+// the ±999 sentinel for a curve without volatility (e.g. a baseline that never traded).
+func baselineSharpeRatio(points []EquityPoint) float64 {
+	sharpe := sharpeRatio(points)
+	if math.Abs(sharpe) == 999 {
+		return 0
 	}
+	return sharpe
+}
+
+// annualizedSharpe is the mean of the per-period returns over their population standard deviation
+// (risk-free rate 0), scaled to a year of periodsPerYear periods.
+func annualizedSharpe(returns []float64, periodsPerYear float64) float64 {
 	if len(returns) == 0 {
 		return 0
 	}
@@ -135,7 +162,67 @@ func sharpeRatio(points []EquityPoint) float64 {
 		}
 		return 0
 	}
-	return mean / std
+	return mean / std * math.Sqrt(periodsPerYear)
+}
+
+// equityReturns returns the per-period returns of the equity curve.
+func equityReturns(points []EquityPoint) []float64 {
+	if len(points) < 2 {
+		return nil
+	}
+
+	returns := make([]float64, 0, len(points)-1)
+	prev := points[0].Equity
+	for i := 1; i < len(points); i++ {
+		curr := points[i].Equity
+		if prev <= 0 {
+			prev = curr
+			continue
+		}
+		returns = append(returns, (curr-prev)/prev)
+		prev = curr
+	}
+	return returns
+}
+
+// sortinoRatio is the mean return divided by the downside deviation, so upside volatility is not
+// penalized. It is annualized like sharpeRatio so the two can be compared.
+func sortinoRatio(points []EquityPoint) float64 {
+	returns := equityReturns(points)
+	if len(returns) == 0 {
+		return 0
+	}
+
+	mean := 0.0
+	downside := 0.0
+	for _, r := range returns {
+		mean += r
+		if r < 0 {
+			downside += r * r
+		}
+	}
+	mean /= float64(len(returns))
+
+	downsideDev := math.Sqrt(downside / float64(len(returns)))
+	if downsideDev == 0 {
+		// No losing periods: same sentinel as sharpeRatio
+		if mean > 0 {
+			return 999
+		}
+		return 0
+	}
+	return mean / downsideDev * math.Sqrt(periodsPerYear(points))
+}
+
+// calmarRatio is the total return divided by the maximum drawdown (both in percent).
+func calmarRatio(totalReturnPct, maxDrawdownPct float64) float64 {
+	if maxDrawdownPct <= 0 {
+		if totalReturnPct > 0 {
+			return 999
+		}
+		return 0
+	}
+	return totalReturnPct / maxDrawdownPct
 }
 
 func fillTradeMetrics(metrics *Metrics, events []TradeEvent) {
@@ -148,7 +235,9 @@ func fillTradeMetrics(metrics *Metrics, events []TradeEvent) {
 	lossTrades := 0
 	totalWinAmount := 0.0
 	totalLossAmount := 0.0
+	lossStreak := 0
 
+	// events are in execution order, so loss streaks can be counted in a single pass
 	for _, evt := range events {
 		include := evt.LiquidationFlag || strings.HasPrefix(evt.Action, "close")
 		if evt.RealizedPnL != 0 {
@@ -173,6 +262,15 @@ func fillTradeMetrics(metrics *Metrics, events []TradeEvent) {
 			stats.LosingTrades++
 		}
 
+		if evt.RealizedPnL < 0 {
+			lossStreak++
+			if lossStreak > metrics.MaxConsecutiveLosses {
+				metrics.MaxConsecutiveLosses = lossStreak
+			}
+		} else {
+			lossStreak = 0
+		}
+
 		metrics.SymbolStats[evt.Symbol] = stats
 	}
 
@@ -186,6 +284,12 @@ func fillTradeMetrics(metrics *Metrics, events []TradeEvent) {
 	if lossTrades > 0 {
 		metrics.AvgLoss = -(totalLossAmount / float64(lossTrades))
 	}
+	if totalTrades > 0 {
+		// Expectancy per trade: winRate × avgWin − lossRate × |avgLoss|
+		winRate := float64(winTrades) / float64(totalTrades)
+		lossRate := float64(lossTrades) / float64(totalTrades)
+		metrics.Expectancy = winRate*metrics.AvgWin + lossRate*metrics.AvgLoss
+	}
 	if totalLossAmount > 0 {
 		metrics.ProfitFactor = totalWinAmount / totalLossAmount
 	} else if totalWinAmount > 0 {
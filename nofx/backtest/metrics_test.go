@@ -0,0 +1,141 @@
+package backtest
+
+import (
+	"math"
+	"testing"
+)
+
+const dayMillis = 24 * 60 * 60 * 1000
+
+// equitySeries builds a daily equity curve
+func equitySeries(values ...float64) []EquityPoint {
+	points := make([]EquityPoint, len(values))
+	for i, v := range values {
+		points[i] = EquityPoint{Timestamp: int64(i) * dayMillis, Equity: v}
+	}
+	return points
+}
+
+func TestSharpeRatioAnnualized(t *testing.T) {
+	// Returns alternate +1% and -0.5%: mean 0.25%, population std 0.75%, per-period Sharpe 1/3
+	values := []float64{100}
+	for i := 0; i < 20; i++ {
+		r := 0.01
+		if i%2 == 1 {
+			r = -0.005
+		}
+		values = append(values, values[len(values)-1]*(1+r))
+	}
+
+	daily := equitySeries(values...)
+	if got, expected := sharpeRatio(daily), math.Sqrt(365)/3; math.Abs(got-expected) > 1e-9 {
+		t.Errorf("daily sharpeRatio() = %.9f, expected %.9f", got, expected)
+	}
+
+	// The same returns on hourly bars annualize over 24× as many periods
+	hourly := equitySeries(values...)
+	for i := range hourly {
+		hourly[i].Timestamp /= 24
+	}
+	if got, expected := sharpeRatio(hourly), math.Sqrt(365*24)/3; math.Abs(got-expected) > 1e-9 {
+		t.Errorf("hourly sharpeRatio() = %.9f, expected %.9f", got, expected)
+	}
+
+	// Without usable timestamps the curve is treated as daily
+	for i := range hourly {
+		hourly[i].Timestamp = 0
+	}
+	if got, expected := sharpeRatio(hourly), math.Sqrt(365)/3; math.Abs(got-expected) > 1e-9 {
+		t.Errorf("sharpeRatio() without timestamps = %.9f, expected %.9f", got, expected)
+	}
+}
+
+func TestSortinoRatio(t *testing.T) {
+	// Returns: +10%, -10%, +10% -> mean 1/30, downside deviation sqrt(0.01/3), annualized daily
+	points := equitySeries(100, 110, 99, 108.9)
+	expected := (0.1 / 3) / math.Sqrt(0.01/3) * math.Sqrt(365)
+	if got := sortinoRatio(points); math.Abs(got-expected) > 1e-9 {
+		t.Errorf("sortinoRatio() = %.6f, expected %.6f", got, expected)
+	}
+
+	// Sortino ignores upside volatility, so it exceeds Sharpe on the same series
+	if sortinoRatio(points) <= sharpeRatio(points) {
+		t.Errorf("expected Sortino (%.4f) > Sharpe (%.4f)", sortinoRatio(points), sharpeRatio(points))
+	}
+}
+
+func TestSortinoRatioNoDownside(t *testing.T) {
+	// All-positive returns: downside deviation is zero
+	if got := sortinoRatio(equitySeries(100, 105, 110, 120)); got != 999 {
+		t.Errorf("sortinoRatio() with no losing periods = %.4f, expected 999", got)
+	}
+	if got := sortinoRatio(equitySeries(100, 100, 100)); got != 0 {
+		t.Errorf("sortinoRatio() on a flat curve = %.4f, expected 0", got)
+	}
+	if got := sortinoRatio(equitySeries(100)); got != 0 {
+		t.Errorf("sortinoRatio() with a single point = %.4f, expected 0", got)
+	}
+}
+
+func TestBaselineSharpeRatioKeepsZeroWithoutVolatility(t *testing.T) {
+	// Constant +100% per period: no volatility, which sharpeRatio reports as the sentinel
+	steady := equitySeries(100, 200, 400, 800)
+	if got := sharpeRatio(steady); got != 999 {
+		t.Fatalf("sharpeRatio() without volatility = %.4f, expected 999", got)
+	}
+	if got := baselineSharpeRatio(steady); got != 0 {
+		t.Errorf("baselineSharpeRatio() without volatility = %.4f, expected 0", got)
+	}
+
+	points := equitySeries(100, 110, 99, 108.9)
+	if got, expected := baselineSharpeRatio(points), sharpeRatio(points); got != expected {
+		t.Errorf("baselineSharpeRatio() = %.6f, expected sharpeRatio() %.6f", got, expected)
+	}
+}
+
+func TestCalmarRatio(t *testing.T) {
+	cases := []struct {
+		name               string
+		totalReturn, maxDD float64
+		expected           float64
+	}{
+		{"positive", 30, 10, 3},
+		{"negative", -12, 8, -1.5},
+		{"no drawdown", 5, 0, 999},
+		{"flat", 0, 0, 0},
+	}
+	for _, tc := range cases {
+		if got := calmarRatio(tc.totalReturn, tc.maxDD); math.Abs(got-tc.expected) > 1e-9 {
+			t.Errorf("%s: calmarRatio(%.1f, %.1f) = %.4f, expected %.4f", tc.name, tc.totalReturn, tc.maxDD, got, tc.expected)
+		}
+	}
+}
+
+func TestFillTradeMetricsStreakAndExpectancy(t *testing.T) {
+	pnls := []float64{10, -5, -5, -5, 0, -2, 20, -4}
+	events := []TradeEvent{{Symbol: "BTCUSDT", Action: "open_long"}}
+	for _, pnl := range pnls {
+		events = append(events, TradeEvent{Symbol: "BTCUSDT", Action: "close_long", RealizedPnL: pnl})
+	}
+
+	metrics := &Metrics{SymbolStats: make(map[string]SymbolMetrics)}
+	fillTradeMetrics(metrics, events)
+
+	if metrics.Trades != 8 {
+		t.Fatalf("Trades = %d, expected 8", metrics.Trades)
+	}
+	// The break-even close ends the three-loss streak
+	if metrics.MaxConsecutiveLosses != 3 {
+		t.Errorf("MaxConsecutiveLosses = %d, expected 3", metrics.MaxConsecutiveLosses)
+	}
+	if math.Abs(metrics.AvgWin-15) > 1e-9 {
+		t.Errorf("AvgWin = %.4f, expected 15", metrics.AvgWin)
+	}
+	if math.Abs(metrics.AvgLoss-(-4.2)) > 1e-9 {
+		t.Errorf("AvgLoss = %.4f, expected -4.2", metrics.AvgLoss)
+	}
+	// 2/8 × 15 − 5/8 × 4.2 = 1.125, the average PnL per trade
+	if math.Abs(metrics.Expectancy-1.125) > 1e-9 {
+		t.Errorf("Expectancy = %.4f, expected 1.125", metrics.Expectancy)
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"math"
 	"nofx/logger"
 	"os"
 	"path/filepath"
@@ -150,6 +149,11 @@ func NewRunner(cfg BacktestConfig, mcpClient mcp.AIClient) (*Runner, error) {
 		r.baselineEnabled = true
 		r.baselineAccount = NewBacktestAccount(cfg.InitialBalance, cfg.FeeBps, cfg.SlippageBps)
 		r.baselineEngine = NewBaselineEngine(strategyConfig)
+		r.baselineEngine.SetTradingCosts(cfg.FeeBps, cfg.SlippageBps)
+		r.baselineEngine.SetBacktestLeverage(cfg.Leverage.BTCETHLeverage, cfg.Leverage.AltcoinLeverage)
+		// Baseline decisions follow the backtest's decision cadence (DecisionCadenceNBars), same as
+		// the AI; the engine's own entry cadence (EntryCadenceBars) is for live runs only
+		r.baselineEngine.SetEntryCadenceBars(1)
 		r.baselineState = &BacktestState{
 			Positions:      make(map[string]PositionSnapshot),
 			Cash:           cfg.InitialBalance,
@@ -808,6 +812,9 @@ func (r *Runner) determineQuantity(dec decision.Decision, price float64) float64
 func (r *Runner) determineCloseQuantity(symbol, side string, dec decision.Decision) float64 {
 	for _, pos := range r.account.Positions() {
 		if pos.Symbol == strings.ToUpper(symbol) && pos.Side == side {
+			if dec.CloseFraction > 0 && dec.CloseFraction < 1 {
+				return pos.Quantity * dec.CloseFraction
+			}
 			return pos.Quantity
 		}
 	}
@@ -1546,7 +1553,7 @@ func (r *Runner) executeBaselineStep(ts int64, marketData map[string]*market.Dat
 	// 2. 在决策点执行常规决策
 	if shouldDecide {
 		// Get deterministic decisions from baseline engine
-		decisions := r.baselineEngine.MakeDecision(equity, available, marketData, positions)
+		decisions := r.baselineEngine.MakeDecision(ts, equity, available, marketData, positions)
 
 		// Record baseline decision
 		r.recordBaselineDecision(ts, cycle, equity, available, decisions, priceMap)
@@ -1600,7 +1607,7 @@ func (r *Runner) convertBaselinePositions(priceMap map[string]float64) []decisio
 
 // executeBaselineDecision executes a single baseline decision
 func (r *Runner) executeBaselineDecision(dec decision.Decision, priceMap map[string]float64, ts int64, cycle int) {
-	price := priceMap[dec.Symbol]
+	price := baselineFillPrice(dec, priceMap)
 	if price <= 0 {
 		return
 	}
@@ -1648,9 +1655,11 @@ func (r *Runner) executeBaselineDecision(dec decision.Decision, priceMap map[str
 		// Find the position to close
 		for _, pos := range r.baselineAccount.Positions() {
 			if pos.Symbol == dec.Symbol && pos.Side == "long" {
-				// Save quantity before closing (Close() will modify pos.Quantity)
-				closedQty := pos.Quantity
-				pnl, _, _, _ := r.baselineAccount.Close(dec.Symbol, "long", closedQty, price)
+				// CloseFraction supports partial scale-out (0 = close all)
+				closedQty, pnl, _, _, err := r.baselineAccount.ClosePercent(dec.Symbol, "long", dec.CloseFraction, price)
+				if err != nil {
+					break
+				}
 				event = &TradeEvent{
 					Timestamp:   ts,
 					Symbol:      dec.Symbol,
@@ -1667,9 +1676,11 @@ func (r *Runner) executeBaselineDecision(dec decision.Decision, priceMap map[str
 	case "close_short":
 		for _, pos := range r.baselineAccount.Positions() {
 			if pos.Symbol == dec.Symbol && pos.Side == "short" {
-				// Save quantity before closing (Close() will modify pos.Quantity)
-				closedQty := pos.Quantity
-				pnl, _, _, _ := r.baselineAccount.Close(dec.Symbol, "short", closedQty, price)
+				// CloseFraction supports partial scale-out (0 = close all)
+				closedQty, pnl, _, _, err := r.baselineAccount.ClosePercent(dec.Symbol, "short", dec.CloseFraction, price)
+				if err != nil {
+					break
+				}
 				event = &TradeEvent{
 					Timestamp:   ts,
 					Symbol:      dec.Symbol,
@@ -1691,6 +1702,15 @@ func (r *Runner) executeBaselineDecision(dec decision.Decision, priceMap map[str
 	}
 }
 
+// baselineFillPrice returns the fill price of a baseline decision: the price of the resting
+// stop or target order for a close hit within the bar, otherwise the current price
+func baselineFillPrice(dec decision.Decision, priceMap map[string]float64) float64 {
+	if dec.ExitPrice > 0 && strings.HasPrefix(dec.Action, "close_") {
+		return dec.ExitPrice
+	}
+	return priceMap[dec.Symbol]
+}
+
 // recordBaselineDecision records baseline decision for each cycle
 func (r *Runner) recordBaselineDecision(ts int64, cycle int, equity, available float64, decisions []decision.Decision, priceMap map[string]float64) {
 	// Build decision summary
@@ -1839,9 +1859,10 @@ func (r *Runner) saveBaselinePerformance() error {
 
 	// Calculate win rate
 	winRate := calculateWinRate(baselineTrades)
+	longTrades, shortTrades, symbolBreakdown := calculateTradeBreakdown(baselineTrades)
 
-	// Calculate Sharpe ratio (simplified)
-	sharpeRatio := calculateSharpeRatio(baselineEquity)
+	// Annualized Sharpe ratio, same convention as the backtest metrics (0 for a flat curve)
+	sharpe := baselineSharpeRatio(baselineEquity)
 
 	// Create performance record
 	perf := &store.BaselineStrategyPerformance{
@@ -1855,9 +1876,14 @@ func (r *Runner) saveBaselinePerformance() error {
 		FinalEquity:        finalEquity,
 		TotalReturnPct:     totalReturnPct,
 		MaxDrawdownPct:     r.baselineState.MaxDrawdownPct,
-		SharpeRatio:        sharpeRatio,
+		SharpeRatio:        sharpe,
 		WinRate:            winRate,
 		TotalTrades:        len(baselineTrades),
+		LongTrades:         longTrades.Trades,
+		LongWins:           longTrades.Wins,
+		ShortTrades:        shortTrades.Trades,
+		ShortWins:          shortTrades.Wins,
+		SymbolBreakdown:    symbolBreakdown,
 	}
 
 	// Save to database
@@ -1896,38 +1922,37 @@ func calculateWinRate(trades []TradeEvent) float64 {
 	return (float64(wins) / float64(closingTrades)) * 100
 }
 
-// calculateSharpeRatio calculates Sharpe ratio from equity curve
-func calculateSharpeRatio(equity []EquityPoint) float64 {
-	if len(equity) < 2 {
-		return 0
-	}
-
-	// Calculate returns
-	var returns []float64
-	for i := 1; i < len(equity); i++ {
-		ret := (equity[i].Equity - equity[i-1].Equity) / equity[i-1].Equity
-		returns = append(returns, ret)
-	}
-
-	// Calculate mean return
-	var sum float64
-	for _, ret := range returns {
-		sum += ret
-	}
-	meanReturn := sum / float64(len(returns))
+// calculateTradeBreakdown splits the closing trades counted by calculateWinRate into long and
+// short sides and per symbol. The side comes from the event, or the close action when unset.
+func calculateTradeBreakdown(trades []TradeEvent) (long, short store.TradeBreakdown, bySymbol map[string]store.TradeBreakdown) {
+	bySymbol = make(map[string]store.TradeBreakdown)
+	for _, trade := range trades {
+		isClosing := strings.HasPrefix(trade.Action, "close") || trade.LiquidationFlag
+		if !isClosing {
+			continue
+		}
+		win := 0
+		if trade.RealizedPnL > 0 {
+			win = 1
+		}
 
-	// Calculate standard deviation
-	var variance float64
-	for _, ret := range returns {
-		variance += (ret - meanReturn) * (ret - meanReturn)
-	}
-	stdDev := math.Sqrt(variance / float64(len(returns)))
+		side := trade.Side
+		if side == "" {
+			side = strings.TrimPrefix(trade.Action, "close_")
+		}
+		switch side {
+		case "long":
+			long.Trades++
+			long.Wins += win
+		case "short":
+			short.Trades++
+			short.Wins += win
+		}
 
-	if stdDev == 0 {
-		return 0
+		symbol := bySymbol[trade.Symbol]
+		symbol.Trades++
+		symbol.Wins += win
+		bySymbol[trade.Symbol] = symbol
 	}
-
-	// Annualized Sharpe ratio (assuming 365 periods per year)
-	return (meanReturn / stdDev) * math.Sqrt(365)
+	return long, short, bySymbol
 }
-
@@ -79,18 +79,22 @@ type TradeEvent struct {
 
 // Metrics summarizes backtest performance metrics.
 type Metrics struct {
-	TotalReturnPct float64                  `json:"total_return_pct"`
-	MaxDrawdownPct float64                  `json:"max_drawdown_pct"`
-	SharpeRatio    float64                  `json:"sharpe_ratio"`
-	ProfitFactor   float64                  `json:"profit_factor"`
-	WinRate        float64                  `json:"win_rate"`
-	Trades         int                      `json:"trades"`
-	AvgWin         float64                  `json:"avg_win"`
-	AvgLoss        float64                  `json:"avg_loss"`
-	BestSymbol     string                   `json:"best_symbol"`
-	WorstSymbol    string                   `json:"worst_symbol"`
-	SymbolStats    map[string]SymbolMetrics `json:"symbol_stats"`
-	Liquidated     bool                     `json:"liquidated"`
+	TotalReturnPct       float64                  `json:"total_return_pct"`
+	MaxDrawdownPct       float64                  `json:"max_drawdown_pct"`
+	SharpeRatio          float64                  `json:"sharpe_ratio"`  // annualized, risk-free rate 0 (see sharpeRatio)
+	SortinoRatio         float64                  `json:"sortino_ratio"` // annualized like SharpeRatio
+	CalmarRatio          float64                  `json:"calmar_ratio"`
+	ProfitFactor         float64                  `json:"profit_factor"`
+	WinRate              float64                  `json:"win_rate"`
+	Trades               int                      `json:"trades"`
+	AvgWin               float64                  `json:"avg_win"`
+	AvgLoss              float64                  `json:"avg_loss"`
+	Expectancy           float64                  `json:"expectancy"`
+	MaxConsecutiveLosses int                      `json:"max_consecutive_losses"`
+	BestSymbol           string                   `json:"best_symbol"`
+	WorstSymbol          string                   `json:"worst_symbol"`
+	SymbolStats          map[string]SymbolMetrics `json:"symbol_stats"`
+	Liquidated           bool                     `json:"liquidated"`
 }
 
 // SymbolMetrics records performance for a single symbol.
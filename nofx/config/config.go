@@ -28,15 +28,24 @@ type Config struct {
 	// Helps us understand product usage and improve the experience
 	// Set EXPERIENCE_IMPROVEMENT=false to disable
 	ExperienceImprovement bool
+
+	// DBBusyTimeoutMs is how long SQLite waits for a locked database before failing
+	DBBusyTimeoutMs int
+
+	// MaxConcurrentBacktests caps how many backtests run at once, whether started
+	// through the API or by evolutions (0 = unlimited)
+	MaxConcurrentBacktests int
 }
 
 // Init initializes global configuration (from .env)
 func Init() {
 	cfg := &Config{
-		APIServerPort:         8080,
-		RegistrationEnabled:   true,
-		MaxUsers:              5,    // Default: only 1 user allowed
-		ExperienceImprovement: true, // Default: enabled to help improve the product
+		APIServerPort:          8080,
+		RegistrationEnabled:    true,
+		MaxUsers:               5,    // Default: only 1 user allowed
+		ExperienceImprovement:  true, // Default: enabled to help improve the product
+		DBBusyTimeoutMs:        5000,
+		MaxConcurrentBacktests: 4,
 	}
 
 	// Load from environment variables
@@ -75,6 +84,18 @@ func Init() {
 		cfg.ExperienceImprovement = strings.ToLower(v) != "false"
 	}
 
+	if v := os.Getenv("DB_BUSY_TIMEOUT_MS"); v != "" {
+		if ms, err := strconv.Atoi(v); err == nil && ms >= 0 {
+			cfg.DBBusyTimeoutMs = ms
+		}
+	}
+
+	if v := os.Getenv("MAX_CONCURRENT_BACKTESTS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.MaxConcurrentBacktests = n
+		}
+	}
+
 	global = cfg
 
 	// Initialize experience improvement (installation ID will be set after database init)
@@ -136,12 +136,33 @@ type Decision struct {
 	StopLoss        float64 `json:"stop_loss,omitempty"`
 	TakeProfit      float64 `json:"take_profit,omitempty"`
 
+	// Closing position parameters
+	CloseFraction float64    `json:"close_fraction,omitempty"` // Fraction of the position to close (0-1), 0 means close all
+	ExitReason    ExitReason `json:"exit_reason,omitempty"`    // Structured reason code for rule-based closes; Reasoning stays the display text
+	ExitPrice     float64    `json:"exit_price,omitempty"`     // Fill price of a resting stop/target order hit within the bar, 0 means the current price
+
 	// Common parameters
 	Confidence int     `json:"confidence,omitempty"` // Confidence level (0-100)
 	RiskUSD    float64 `json:"risk_usd,omitempty"`   // Maximum USD risk
 	Reasoning  string  `json:"reasoning"`
 }
 
+// ExitReason structured reason code for a close decision
+type ExitReason string
+
+const (
+	ExitReasonHardStop        ExitReason = "hard_stop"         // Hard stop loss
+	ExitReasonTrailingStop    ExitReason = "trailing_stop"     // Trailing stop loss
+	ExitReasonTrailingTP      ExitReason = "trailing_tp"       // Trailing take profit
+	ExitReasonRSIExit         ExitReason = "rsi_exit"          // RSI overbought/oversold exit
+	ExitReasonStochCross      ExitReason = "stoch_cross"       // StochRSI cross against the position
+	ExitReasonPendingOHLCStop ExitReason = "pending_ohlc_stop" // Pending stop order hit by the bar's high/low
+	ExitReasonTimeExit        ExitReason = "time_exit"         // Max holding period exceeded
+	ExitReasonScaleOut        ExitReason = "scale_out"         // Partial take profit
+	ExitReasonTakeProfit      ExitReason = "take_profit"       // Fixed take-profit target reached
+	ExitReasonPSAR            ExitReason = "psar"              // Parabolic SAR flipped against the position
+)
+
 // FullDecision AI's complete decision (including chain of thought)
 type FullDecision struct {
 	SystemPrompt        string     `json:"system_prompt"`
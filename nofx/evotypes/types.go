@@ -1,6 +1,8 @@
 package evotypes
 
 import (
+	"fmt"
+	"net/url"
 	"time"
 )
 
@@ -21,6 +23,7 @@ const (
 	IterStatusOptimizing = "optimizing"
 	IterStatusCompleted  = "completed"
 	IterStatusFailed     = "failed"
+	IterStatusStalled    = "stalled" // Backtest made no progress and ran out of restarts
 )
 
 // EvolutionConfig defines the configuration for an evolution task
@@ -31,7 +34,78 @@ type EvolutionConfig struct {
 	MaxIterations        int         `json:"max_iterations"`
 	ConvergenceThreshold int         `json:"convergence_threshold"` // Stop after N iterations without improvement
 	FixedParams          FixedParams `json:"fixed_params"`
-	EvaluationModel      string      `json:"evaluation_model"` // AI model for evaluation (e.g., "claude-opus")
+	EvaluationModel      string      `json:"evaluation_model"`                 // AI model ID for evaluating backtests; empty or unknown uses FixedParams.AIModelID
+	ParallelCandidates   int         `json:"parallel_candidates,omitempty"`    // Candidate prompts backtested per generation (<= 1 runs one iteration at a time)
+	MaxParallelBacktests int         `json:"max_parallel_backtests,omitempty"` // Worker pool size for candidate backtests, defaults to ParallelCandidates
+	// SeedStrategyIDs switches the evolution to population mode: the base strategy and these
+	// seeds form the first generation, and every later generation keeps the EliteCount fittest
+	// members and refills the population with their mutations and crossovers
+	SeedStrategyIDs []string `json:"seed_strategy_ids,omitempty"`
+	PopulationSize  int      `json:"population_size,omitempty"` // Members per generation, defaults to max(seeds + 1, 4)
+	EliteCount      int      `json:"elite_count,omitempty"`     // Members surviving each generation, defaults to 2
+	// EnableCrossover adds an offspring of the best and current prompts as a parallel candidate
+	EnableCrossover bool `json:"enable_crossover,omitempty"`
+	// CrossoverMap maps dotted strategy-config JSON paths to the parent ("best" or "current")
+	// the offspring inherits them from; unmapped fields come from the current prompt
+	CrossoverMap map[string]string `json:"crossover_map,omitempty"`
+	// SelectionMode chooses how the best version is picked: "" uses the return/drawdown
+	// improvement rule, "pareto" promotes the Pareto frontier member with the highest
+	// FitnessWeights score, or the frontier's knee point when no weights are set
+	SelectionMode string `json:"selection_mode,omitempty"`
+	// LineageMode chooses the base strategy of the next iteration: "" always carries the
+	// optimized prompt forward, "hill_climb" continues from the best version after an iteration
+	// that did not improve (its mutation, or the best strategy when no mutation of it exists)
+	LineageMode string `json:"lineage_mode,omitempty"`
+	// FitnessWeights switches improvement checks to a weighted score; nil keeps the default rule
+	FitnessWeights *FitnessWeights `json:"fitness_weights,omitempty"`
+	// ReturnTolerancePct and DrawdownImprovementPct tune the default improvement rule: a
+	// candidate whose return is at most ReturnTolerancePct points below the best still
+	// improves when its drawdown is at least DrawdownImprovementPct points lower.
+	// nil uses the defaults of 3 and 5, so 0 can be set explicitly; ignored when FitnessWeights is set
+	ReturnTolerancePct     *float64 `json:"return_tolerance_pct,omitempty"`
+	DrawdownImprovementPct *float64 `json:"drawdown_improvement_pct,omitempty"`
+	// MaxParseRetries is how often the AI is re-prompted when its reply is not valid JSON;
+	// 0 uses the default and a negative value disables retries
+	MaxParseRetries int `json:"max_parse_retries,omitempty"`
+	// BacktestInactivityTimeoutSec is how long a backtest may go without progress before it
+	// counts as stalled; 0 uses the default of 5 minutes
+	BacktestInactivityTimeoutSec int `json:"backtest_inactivity_timeout_sec,omitempty"`
+	// MaxStallRestarts is how often a stalled backtest is restarted before the iteration is
+	// given up as stalled; 0 uses the default and a negative value disables restarts
+	MaxStallRestarts int `json:"max_stall_restarts,omitempty"`
+	// MaxTotalTokens stops the evolution once its AI calls consumed this many prompt plus
+	// completion tokens; 0 means no budget
+	MaxTotalTokens int64 `json:"max_total_tokens,omitempty"`
+	// WebhookURL receives a JSON POST on lifecycle events (iteration completed, new best,
+	// evolution completed/stopped/failed); empty disables notifications
+	WebhookURL string `json:"webhook_url,omitempty"`
+}
+
+// ValidateWebhookURL checks that a webhook URL is an absolute http or https URL with a
+// host; an empty URL (notifications disabled) is valid
+func ValidateWebhookURL(raw string) error {
+	if raw == "" {
+		return nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid webhook_url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("invalid webhook_url: scheme must be http or https, got %q", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return fmt.Errorf("invalid webhook_url: missing host")
+	}
+	return nil
+}
+
+// FitnessWeights weights the metrics of a weighted evolution fitness score
+type FitnessWeights struct {
+	ReturnWeight      float64 `json:"return_weight"`       // Multiplies total return (%)
+	DrawdownWeight    float64 `json:"drawdown_weight"`     // Multiplies max drawdown (%), subtracted
+	SharpeWeight      float64 `json:"sharpe_weight"`       // Multiplies Sharpe ratio
+	TradeCountPenalty float64 `json:"trade_count_penalty"` // Subtracted per trade
 }
 
 // FixedParams defines the fixed backtest parameters
@@ -49,6 +123,12 @@ type FixedParams struct {
 	AltcoinLeverage   int      `json:"altcoin_leverage"`
 	AIModelID         string   `json:"ai_model_id"`
 	CacheAI           bool     `json:"cache_ai"`
+	// ValidationSplit holds out the last fraction (0-1) of StartTS–EndTS as an
+	// out-of-sample window; prompts are optimized on the rest only
+	ValidationSplit float64 `json:"validation_split,omitempty"`
+	// ValidationTolerance is how many percentage points out-of-sample return or
+	// drawdown may degrade versus the best version before promotion is rejected, default 3
+	ValidationTolerance float64 `json:"validation_tolerance,omitempty"`
 }
 
 // Evolution represents an evolution task
@@ -79,35 +159,77 @@ type Evolution struct {
 
 // Iteration represents a single iteration in the evolution process
 type Iteration struct {
-	ID             int       `json:"id"`
-	EvolutionID    string    `json:"evolution_id"`
-	Version        int       `json:"version"`
-	StrategyID     string    `json:"strategy_id"`
-	BacktestRunID  string    `json:"backtest_run_id"`
-	Status         string    `json:"status"`
-	Metrics        *Metrics  `json:"metrics,omitempty"`
-	EvalReport     string    `json:"evaluation_report,omitempty"` // JSON string
-	ChangesSummary string    `json:"changes_summary,omitempty"`
-	PromptBefore   string    `json:"prompt_before,omitempty"`
-	PromptAfter    string    `json:"prompt_after,omitempty"`
-	CreatedAt      time.Time `json:"created_at"`
+	ID                int       `json:"id"`
+	EvolutionID       string    `json:"evolution_id"`
+	Version           int       `json:"version"`
+	StrategyID        string    `json:"strategy_id"`
+	BacktestRunID     string    `json:"backtest_run_id"`
+	Status            string    `json:"status"`
+	Metrics           *Metrics  `json:"metrics,omitempty"`
+	ValidationMetrics *Metrics  `json:"validation_metrics,omitempty"` // Out-of-sample metrics when a validation split is configured
+	OnParetoFrontier  bool      `json:"on_pareto_frontier"`           // Not dominated on return, drawdown and Sharpe by any other iteration
+	ImprovementReason string    `json:"improvement_reason,omitempty"` // Why the iteration was promoted to best, e.g. "higher return (12.30% vs 9.10%)"
+	FailureReason     string    `json:"failure_reason,omitempty"`     // Why the iteration was not promoted to best
+	EvalReport        string    `json:"evaluation_report,omitempty"`  // JSON string
+	ChangesSummary    string    `json:"changes_summary,omitempty"`
+	PromptBefore      string    `json:"prompt_before,omitempty"`
+	PromptAfter       string    `json:"prompt_after,omitempty"`
+	PromptTokens      int64     `json:"prompt_tokens"` // AI tokens consumed by the iteration
+	CompletionTokens  int64     `json:"completion_tokens"`
+	StallRestarts     int       `json:"stall_restarts"` // Restarts of a stalled backtest; > 0 on a completed iteration means it recovered
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+// IterationCheckpoint holds the artifacts of a finished evaluation stage so an
+// interrupted iteration can resume at optimization without re-running its backtest
+type IterationCheckpoint struct {
+	EvolutionID   string    `json:"evolution_id"`
+	Version       int       `json:"version"`
+	BacktestRunID string    `json:"backtest_run_id"`
+	EvalReport    string    `json:"evaluation_report"` // JSON of EvaluationReport
+	MetricsJSON   string    `json:"metrics"`           // JSON snapshot of the backtest metrics
+	TradesJSON    string    `json:"trades"`            // JSON snapshot of the analyzed trades
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// PopulationMember records that a version belonged to a population generation.
+// Rank 1 is the fittest member; elites survive into the next generation.
+type PopulationMember struct {
+	EvolutionID string    `json:"evolution_id"`
+	Generation  int       `json:"generation"`
+	Version     int       `json:"version"`
+	Rank        int       `json:"rank"`
+	Elite       bool      `json:"elite"`
+	CreatedAt   time.Time `json:"created_at"`
 }
 
 // Metrics holds backtest performance metrics
 type Metrics struct {
-	TotalReturn float64 `json:"total_return"`
-	MaxDrawdown float64 `json:"max_drawdown"`
-	WinRate     float64 `json:"win_rate"`
-	SharpeRatio float64 `json:"sharpe_ratio"`
-	Trades      int     `json:"trades"`
+	TotalReturn          float64 `json:"total_return"`
+	MaxDrawdown          float64 `json:"max_drawdown"`
+	WinRate              float64 `json:"win_rate"`
+	SharpeRatio          float64 `json:"sharpe_ratio"`  // Annualized, risk-free rate 0, as computed by the backtest
+	SortinoRatio         float64 `json:"sortino_ratio"` // Annualized like SharpeRatio
+	CalmarRatio          float64 `json:"calmar_ratio"`
+	Trades               int     `json:"trades"`
+	MaxConsecutiveLosses int     `json:"max_consecutive_losses"`
+	AvgWin               float64 `json:"avg_win"`
+	AvgLoss              float64 `json:"avg_loss"` // Negative, as in backtest metrics
+	Expectancy           float64 `json:"expectancy"`
+	ProfitFactor         float64 `json:"profit_factor"` // Gross profit / gross loss, 999 when there were no losses
+	AvgTradePnL          float64 `json:"avg_trade_pnl"` // Mean realized PnL per closed trade (USDT)
+	// PerPeriodSharpe marks SharpeRatio and SortinoRatio stored before the backtest annualized
+	// them; such values are per equity-curve period and not comparable with annualized ones
+	PerPeriodSharpe bool `json:"per_period_sharpe,omitempty"`
 }
 
 // EvaluationReport holds the AI evaluation results
 type EvaluationReport struct {
-	Strengths   []string `json:"strengths"`
-	Weaknesses  []string `json:"weaknesses"`
-	Suggestions []string `json:"suggestions"`
-	RawResponse string   `json:"raw_response,omitempty"`
+	Strengths    []string `json:"strengths"`
+	Weaknesses   []string `json:"weaknesses"`
+	Suggestions  []string `json:"suggestions"`
+	RawResponse  string   `json:"raw_response,omitempty"`
+	ParseRetries int      `json:"parse_retries,omitempty"` // Re-prompts needed to get valid JSON
 }
 
 // OptimizationResult holds the prompt optimization results
@@ -116,6 +238,8 @@ type OptimizationResult struct {
 	NewPrompt      string   `json:"new_prompt"`
 	ExpectedEffect string   `json:"expected_effect"`
 	RawResponse    string   `json:"raw_response,omitempty"`
+	ParseRetries   int      `json:"parse_retries,omitempty"`   // Corrective re-prompts before the reply parsed
+	RejectedReason string   `json:"rejected_reason,omitempty"` // Why the AI's new prompt was rejected, if it was
 }
 
 // DecisionSample represents a sampled trading decision for analysis
@@ -139,9 +263,44 @@ type IterationDetail struct {
 
 // PromptDiff shows the differences between prompts
 type PromptDiff struct {
-	Before  string   `json:"before"`
-	After   string   `json:"after"`
-	Changes []string `json:"changes"`
+	Before  string              `json:"before"`
+	After   string              `json:"after"`
+	Changes []string            `json:"changes"`          // One readable line per change
+	Format  string              `json:"format"`           // "json" when both prompts are JSON configs, otherwise "text"
+	Fields  []PromptFieldChange `json:"fields,omitempty"` // Changed config fields (json format)
+	Lines   []PromptLineChange  `json:"lines,omitempty"`  // Removed and added lines (text format)
+}
+
+// PromptFieldChange is one config field that differs between two strategy prompts
+type PromptFieldChange struct {
+	Path string `json:"path"`          // Dotted path, e.g. "risk_control.max_positions"
+	Kind string `json:"kind"`          // "added", "removed" or "changed"
+	Old  string `json:"old,omitempty"` // JSON-encoded value before, empty when added
+	New  string `json:"new,omitempty"` // JSON-encoded value after, empty when removed
+}
+
+// PromptLineChange is one removed ("-") or added ("+") line of a text prompt diff
+type PromptLineChange struct {
+	Op   string `json:"op"`
+	Line int    `json:"line"` // 1-based line number in the before (-) or after (+) prompt
+	Text string `json:"text"`
+}
+
+// IterationComparison puts two iterations of an evolution side by side
+type IterationComparison struct {
+	A          *Iteration    `json:"a"`
+	B          *Iteration    `json:"b"`
+	Delta      *MetricsDelta `json:"delta,omitempty"` // B minus A, nil unless both have metrics
+	PromptDiff *PromptDiff   `json:"prompt_diff"`     // Strategy config of A (before) vs B (after)
+}
+
+// MetricsDelta holds metric differences between two iterations
+type MetricsDelta struct {
+	TotalReturn float64 `json:"total_return"`
+	MaxDrawdown float64 `json:"max_drawdown"`
+	WinRate     float64 `json:"win_rate"`
+	SharpeRatio float64 `json:"sharpe_ratio"`
+	Trades      int     `json:"trades"`
 }
 
 // EquityPoint represents a point on the equity curve
@@ -158,4 +317,11 @@ type EvolutionStatus struct {
 	RecentIterations []*Iteration `json:"recent_iterations,omitempty"`
 	IsConverged      bool         `json:"is_converged"`
 	ConvergeReason   string       `json:"converge_reason,omitempty"`
+	// StagnantIterations counts the completed iterations since the best version; the
+	// evolution converges once it reaches ConvergenceThreshold (0 = convergence disabled)
+	StagnantIterations   int          `json:"stagnant_iterations"`
+	ConvergenceThreshold int          `json:"convergence_threshold"`
+	ParetoFrontier   []*Iteration `json:"pareto_frontier,omitempty"` // Non-dominated iterations across return, drawdown and Sharpe
+	PromptTokens     int64        `json:"prompt_tokens"`             // AI tokens consumed by all iterations
+	CompletionTokens int64        `json:"completion_tokens"`
 }
@@ -53,7 +53,7 @@ func main() {
 	}
 
 	logger.Infof("📋 Initializing database: %s", dbPath)
-	st, err := store.New(dbPath)
+	st, err := store.NewWithBusyTimeout(dbPath, time.Duration(cfg.DBBusyTimeoutMs)*time.Millisecond)
 	if err != nil {
 		logger.Fatalf("❌ Failed to initialize database: %v", err)
 	}
@@ -112,6 +112,7 @@ func main() {
 	traderManager := manager.NewTraderManager()
 	mcpClient := newSharedMCPClient()
 	backtestManager := backtest.NewManager(mcpClient)
+	backtestManager.SetMaxConcurrent(cfg.MaxConcurrentBacktests)
 	if err := backtestManager.RestoreRuns(); err != nil {
 		logger.Warnf("⚠️ Failed to restore backtest history: %v", err)
 	}
@@ -291,8 +291,11 @@ func calculateTimeframeSeries(klines []Kline, timeframe string, count int) *Time
 		}
 	}
 
+	data.MACDSignal = calculateMACDSignal(data.MACDValues, 9)
+
 	// Calculate ATR14
 	data.ATR14 = calculateATR(klines, 14)
+	data.ADX14 = calculateADX(klines, 14)
 
 	return data
 }
@@ -400,6 +403,31 @@ func calculateMACD(klines []Kline) float64 {
 	return ema12 - ema26
 }
 
+// calculateMACDSignal calculates the MACD signal line (EMA of the MACD series) for every point;
+// points before the first full period are 0
+func calculateMACDSignal(macd []float64, period int) []float64 {
+	signal := make([]float64, len(macd))
+	if len(macd) < period {
+		return signal
+	}
+
+	// Calculate SMA as initial EMA
+	sum := 0.0
+	for i := 0; i < period; i++ {
+		sum += macd[i]
+	}
+	ema := sum / float64(period)
+	signal[period-1] = ema
+
+	multiplier := 2.0 / float64(period+1)
+	for i := period; i < len(macd); i++ {
+		ema = (macd[i]-ema)*multiplier + ema
+		signal[i] = ema
+	}
+
+	return signal
+}
+
 // calculateRSI calculates RSI
 func calculateRSI(klines []Kline, period int) float64 {
 	if len(klines) <= period {
@@ -558,6 +586,74 @@ func calculateATR(klines []Kline, period int) float64 {
 	return atr
 }
 
+// calculateADX calculates ADX (Average Directional Index) with Wilder smoothing
+// Requires at least 2*period+1 bars, returns 0 otherwise
+func calculateADX(klines []Kline, period int) float64 {
+	if period <= 0 || len(klines) <= 2*period {
+		return 0
+	}
+
+	trs := make([]float64, len(klines))
+	plusDMs := make([]float64, len(klines))
+	minusDMs := make([]float64, len(klines))
+	for i := 1; i < len(klines); i++ {
+		high := klines[i].High
+		low := klines[i].Low
+		prevClose := klines[i-1].Close
+
+		trs[i] = math.Max(high-low, math.Max(math.Abs(high-prevClose), math.Abs(low-prevClose)))
+
+		upMove := high - klines[i-1].High
+		downMove := klines[i-1].Low - low
+		if upMove > downMove && upMove > 0 {
+			plusDMs[i] = upMove
+		}
+		if downMove > upMove && downMove > 0 {
+			minusDMs[i] = downMove
+		}
+	}
+
+	// Initial smoothed values
+	var trSum, plusSum, minusSum float64
+	for i := 1; i <= period; i++ {
+		trSum += trs[i]
+		plusSum += plusDMs[i]
+		minusSum += minusDMs[i]
+	}
+
+	dx := func() float64 {
+		if trSum == 0 {
+			return 0
+		}
+		plusDI := 100 * plusSum / trSum
+		minusDI := 100 * minusSum / trSum
+		if plusDI+minusDI == 0 {
+			return 0
+		}
+		return 100 * math.Abs(plusDI-minusDI) / (plusDI + minusDI)
+	}
+
+	// First ADX is the average of the first `period` DX values
+	dxSum := dx()
+	for i := period + 1; i < 2*period; i++ {
+		trSum = trSum - trSum/float64(period) + trs[i]
+		plusSum = plusSum - plusSum/float64(period) + plusDMs[i]
+		minusSum = minusSum - minusSum/float64(period) + minusDMs[i]
+		dxSum += dx()
+	}
+	adx := dxSum / float64(period)
+
+	// Wilder smoothing
+	for i := 2 * period; i < len(klines); i++ {
+		trSum = trSum - trSum/float64(period) + trs[i]
+		plusSum = plusSum - plusSum/float64(period) + plusDMs[i]
+		minusSum = minusSum - minusSum/float64(period) + minusDMs[i]
+		adx = (adx*float64(period-1) + dx()) / float64(period)
+	}
+
+	return adx
+}
+
 // calculateIntradaySeries calculates intraday series data
 func calculateIntradaySeries(klines []Kline) *IntradayData {
 	data := &IntradayData{
@@ -958,6 +1054,7 @@ func BuildDataFromKlines(symbol string, primary []Kline, longer []Kline) (*Data,
 	data := &Data{
 		Symbol:            symbol,
 		CurrentPrice:      currentPrice,
+		Open:              current.Open,
 		High:              current.High,
 		Low:               current.Low,
 		CurrentEMA20:      calculateEMA(primary, 20),
@@ -1024,6 +1121,12 @@ func BuildTimeframeSeriesData(tf string, klines []Kline, emaPeriods []int) *Time
 		allStochD = append(allStochD, stochRSI.D)
 	}
 
+	// MACD is 0 until 26 bars are available, so the signal line starts from there
+	allMACDSignal := make([]float64, len(klines))
+	if len(allMACD) > 25 {
+		copy(allMACDSignal[25:], calculateMACDSignal(allMACD[25:], 9))
+	}
+
 	// Determine output range (last maxOutputBars)
 	outputStart := 0
 	outputLen := len(klines)
@@ -1055,13 +1158,15 @@ func BuildTimeframeSeriesData(tf string, klines []Kline, emaPeriods []int) *Time
 	result.EMAShortValues = allEMAShort[outputStart:]
 	result.EMALongValues = allEMALong[outputStart:]
 	result.MACDValues = allMACD[outputStart:]
+	result.MACDSignal = allMACDSignal[outputStart:]
 	result.RSI7Values = allRSI7[outputStart:]
 	result.RSI14Values = allRSI14[outputStart:]
 	result.StochRSI_K = allStochK[outputStart:]
 	result.StochRSI_D = allStochD[outputStart:]
 
-	// Calculate ATR14 using full data
+	// Calculate ATR14 and ADX14 using full data
 	result.ATR14 = calculateATR(klines, 14)
+	result.ADX14 = calculateADX(klines, 14)
 
 	return result
 }
@@ -249,6 +249,61 @@ func TestCalculateATR(t *testing.T) {
 	}
 }
 
+// TestCalculateADX tests that ADX separates trending from choppy markets
+func TestCalculateADX(t *testing.T) {
+	trending := make([]Kline, 60)
+	choppy := make([]Kline, 60)
+	for i := range trending {
+		price := 100.0 + float64(i)
+		trending[i] = Kline{High: price + 1, Low: price - 1, Close: price + 0.5}
+
+		offset := 1.0
+		if i%2 == 0 {
+			offset = -1.0
+		}
+		choppy[i] = Kline{High: 100 + offset + 1, Low: 100 + offset - 1, Close: 100 + offset}
+	}
+
+	trendADX := calculateADX(trending, 14)
+	choppyADX := calculateADX(choppy, 14)
+
+	if trendADX < 50 {
+		t.Errorf("calculateADX(trending) = %.2f, expected strong trend (>= 50)", trendADX)
+	}
+	if choppyADX > 20 {
+		t.Errorf("calculateADX(choppy) = %.2f, expected weak trend (<= 20)", choppyADX)
+	}
+	if adx := calculateADX(trending[:28], 14); adx != 0 {
+		t.Errorf("calculateADX() = %.2f, expected 0 (insufficient data)", adx)
+	}
+}
+
+func TestCalculateMACDSignal(t *testing.T) {
+	macd := []float64{1, 2, 3, 4}
+	signal := calculateMACDSignal(macd, 3)
+
+	// SMA seed (1+2+3)/3 = 2, then EMA with multiplier 0.5: (4-2)*0.5+2 = 3
+	expected := []float64{0, 0, 2, 3}
+	for i := range expected {
+		if math.Abs(signal[i]-expected[i]) > 1e-9 {
+			t.Fatalf("calculateMACDSignal() = %v, expected %v", signal, expected)
+		}
+	}
+	if signal := calculateMACDSignal(macd[:2], 3); signal[0] != 0 || signal[1] != 0 {
+		t.Errorf("calculateMACDSignal() = %v, expected zeros (insufficient data)", signal)
+	}
+}
+
+func TestBuildTimeframeSeriesData_MACDSignal(t *testing.T) {
+	series := BuildTimeframeSeriesData("1h", generateTestKlines(60), nil)
+	if len(series.MACDSignal) != len(series.MACDValues) {
+		t.Fatalf("MACDSignal has %d values, expected it aligned with %d MACD values", len(series.MACDSignal), len(series.MACDValues))
+	}
+	if series.MACDSignal[len(series.MACDSignal)-1] == 0 {
+		t.Error("expected the signal line to be formed after 60 bars")
+	}
+}
+
 // TestCalculateATR_TrueRange tests ATR True Range calculation correctness
 func TestCalculateATR_TrueRange(t *testing.T) {
 	// Create a simple test case, manually calculate expected ATR
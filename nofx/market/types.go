@@ -6,6 +6,7 @@ import "time"
 type Data struct {
 	Symbol            string
 	CurrentPrice      float64
+	Open              float64 // Current bar open price
 	High              float64 // Current bar high price
 	Low               float64 // Current bar low price
 	PriceChange1h     float64 // 1-hour price change percentage
@@ -41,12 +42,14 @@ type TimeframeSeriesData struct {
 	EMAShortValues []float64  `json:"ema_short_values"` // EMA short series (configurable period)
 	EMALongValues  []float64  `json:"ema_long_values"`  // EMA long series (configurable period)
 	MACDValues     []float64  `json:"macd_values"`      // MACD series
+	MACDSignal     []float64  `json:"macd_signal"`      // MACD signal line series (EMA9 of MACD)
 	RSI7Values     []float64  `json:"rsi7_values"`      // RSI7 series
 	RSI14Values    []float64  `json:"rsi14_values"`     // RSI14 series
 	StochRSI_K     []float64  `json:"stoch_rsi_k"`      // Stoch RSI %K series
 	StochRSI_D     []float64  `json:"stoch_rsi_d"`      // Stoch RSI %D series
 	Volume         []float64  `json:"volume"`           // Volume series (deprecated, use Klines)
 	ATR14          float64    `json:"atr14"`            // ATR14
+	ADX14          float64    `json:"adx14"`            // ADX14 (trend strength)
 }
 
 // OIData Open Interest data
@@ -46,6 +46,8 @@ type Client struct {
 	logger     Logger // Logger (replaceable)
 	config     *Config // Config object (stores all configurations)
 
+	usage usageCounter // Cumulative token usage of successful calls
+
 	// hooks are used to implement dynamic dispatch (polymorphism)
 	// When DeepSeekClient embeds Client, hooks point to DeepSeekClient
 	// This way methods called in call() are automatically dispatched to the overridden version in subclass
@@ -374,6 +376,7 @@ func (client *Client) call(systemPrompt, userPrompt string) (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("fail to parse AI server response: %w", err)
 	}
+	client.usage.add(parseTokenUsage(body))
 
 	return result, nil
 }
@@ -507,6 +510,7 @@ func (client *Client) callWithRequest(req *Request) (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("fail to parse AI server response: %w", err)
 	}
+	client.usage.add(parseTokenUsage(body))
 
 	return result, nil
 }
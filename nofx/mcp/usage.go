@@ -0,0 +1,106 @@
+package mcp
+
+import (
+	"encoding/json"
+	"strings"
+	"sync/atomic"
+)
+
+// TokenUsage counts the tokens consumed by AI calls
+type TokenUsage struct {
+	PromptTokens     int64 `json:"prompt_tokens"`
+	CompletionTokens int64 `json:"completion_tokens"`
+}
+
+// Total returns prompt plus completion tokens
+func (u TokenUsage) Total() int64 {
+	return u.PromptTokens + u.CompletionTokens
+}
+
+// Sub returns the usage accumulated since an earlier snapshot
+func (u TokenUsage) Sub(earlier TokenUsage) TokenUsage {
+	return TokenUsage{
+		PromptTokens:     u.PromptTokens - earlier.PromptTokens,
+		CompletionTokens: u.CompletionTokens - earlier.CompletionTokens,
+	}
+}
+
+// UsageReporter is implemented by clients that account the tokens of their calls
+//
+// Usage example:
+//
+//	if reporter, ok := client.(mcp.UsageReporter); ok {
+//	    before := reporter.TokenUsage()
+//	    client.CallWithMessages(system, user)
+//	    used := reporter.TokenUsage().Sub(before)
+//	}
+type UsageReporter interface {
+	// TokenUsage returns the cumulative usage of all calls made by the client
+	TokenUsage() TokenUsage
+}
+
+// usageCounter accumulates token usage, safe for concurrent calls. It uses plain int64
+// fields with atomic operations so a Client can still be copied by value.
+type usageCounter struct {
+	promptTokens     int64
+	completionTokens int64
+}
+
+func (c *usageCounter) add(u TokenUsage) {
+	atomic.AddInt64(&c.promptTokens, u.PromptTokens)
+	atomic.AddInt64(&c.completionTokens, u.CompletionTokens)
+}
+
+func (c *usageCounter) snapshot() TokenUsage {
+	return TokenUsage{
+		PromptTokens:     atomic.LoadInt64(&c.promptTokens),
+		CompletionTokens: atomic.LoadInt64(&c.completionTokens),
+	}
+}
+
+// TokenUsage returns the cumulative token usage of all calls made by this client
+func (client *Client) TokenUsage() TokenUsage {
+	return client.usage.snapshot()
+}
+
+// parseTokenUsage reads the usage block of a response body. It understands the
+// OpenAI-compatible (prompt_tokens/completion_tokens) and Claude (input_tokens/output_tokens)
+// formats; for SSE streams the last chunk carrying usage wins. Missing usage counts as zero.
+func parseTokenUsage(body []byte) TokenUsage {
+	type usageBlock struct {
+		Usage *struct {
+			PromptTokens     int64 `json:"prompt_tokens"`
+			CompletionTokens int64 `json:"completion_tokens"`
+			InputTokens      int64 `json:"input_tokens"`
+			OutputTokens     int64 `json:"output_tokens"`
+		} `json:"usage"`
+	}
+	toUsage := func(b usageBlock) TokenUsage {
+		return TokenUsage{
+			PromptTokens:     b.Usage.PromptTokens + b.Usage.InputTokens,
+			CompletionTokens: b.Usage.CompletionTokens + b.Usage.OutputTokens,
+		}
+	}
+
+	bodyStr := strings.TrimSpace(string(body))
+	if !strings.HasPrefix(bodyStr, "data") && !strings.Contains(bodyStr, "\ndata") {
+		var block usageBlock
+		if err := json.Unmarshal(body, &block); err != nil || block.Usage == nil {
+			return TokenUsage{}
+		}
+		return toUsage(block)
+	}
+
+	var usage TokenUsage
+	for _, line := range strings.Split(bodyStr, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		var block usageBlock
+		if err := json.Unmarshal([]byte(strings.TrimSpace(strings.TrimPrefix(line, "data:"))), &block); err == nil && block.Usage != nil {
+			usage = toUsage(block)
+		}
+	}
+	return usage
+}
@@ -0,0 +1,71 @@
+package mcp
+
+import (
+	"testing"
+)
+
+func TestParseTokenUsage(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		expected TokenUsage
+	}{
+		{
+			name:     "openai format",
+			body:     `{"choices":[{"message":{"content":"hi"}}],"usage":{"prompt_tokens":120,"completion_tokens":30}}`,
+			expected: TokenUsage{PromptTokens: 120, CompletionTokens: 30},
+		},
+		{
+			name:     "claude format",
+			body:     `{"content":[{"type":"text","text":"hi"}],"usage":{"input_tokens":80,"output_tokens":15}}`,
+			expected: TokenUsage{PromptTokens: 80, CompletionTokens: 15},
+		},
+		{
+			name: "sse stream",
+			body: "data: {\"choices\":[{\"delta\":{\"content\":\"h\"}}]}\n" +
+				"data: {\"choices\":[],\"usage\":{\"prompt_tokens\":50,\"completion_tokens\":7}}\n" +
+				"data: [DONE]",
+			expected: TokenUsage{PromptTokens: 50, CompletionTokens: 7},
+		},
+		{
+			name:     "missing usage",
+			body:     `{"choices":[{"message":{"content":"hi"}}]}`,
+			expected: TokenUsage{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseTokenUsage([]byte(tt.body)); got != tt.expected {
+				t.Errorf("expected %+v, got %+v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestClient_TokenUsageAccumulates(t *testing.T) {
+	mockHTTP := NewMockHTTPClient()
+	mockHTTP.Response = `{"choices":[{"message":{"content":"ok"}}],"usage":{"prompt_tokens":100,"completion_tokens":20}}`
+
+	client := NewClient(
+		WithHTTPClient(mockHTTP.ToHTTPClient()),
+		WithLogger(NewMockLogger()),
+		WithAPIKey("test-key"),
+		WithBaseURL("https://api.test.com"),
+	)
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.CallWithMessages("system prompt", "user prompt"); err != nil {
+			t.Fatalf("should not error: %v", err)
+		}
+	}
+
+	reporter, ok := client.(UsageReporter)
+	if !ok {
+		t.Fatal("client should implement UsageReporter")
+	}
+	usage := reporter.TokenUsage()
+	if usage.PromptTokens != 200 || usage.CompletionTokens != 40 || usage.Total() != 240 {
+		t.Errorf("expected 200+40 tokens, got %+v", usage)
+	}
+}
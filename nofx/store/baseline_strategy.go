@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 )
 
@@ -35,7 +36,28 @@ type BaselineStrategyPerformance struct {
 	SharpeRatio        float64   `json:"sharpe_ratio"`
 	WinRate            float64   `json:"win_rate"`
 	TotalTrades        int       `json:"total_trades"`
+	LongTrades         int       `json:"long_trades"`  // closed long trades
+	LongWins           int       `json:"long_wins"`    // closed long trades with positive realized PnL
+	ShortTrades        int       `json:"short_trades"` // closed short trades
+	ShortWins          int       `json:"short_wins"`   // closed short trades with positive realized PnL
 	CreatedAt          time.Time `json:"created_at"`
+
+	// Closed trades and winners per symbol
+	SymbolBreakdown map[string]TradeBreakdown `json:"symbol_breakdown,omitempty"`
+}
+
+// TradeBreakdown counts closed trades and winning trades for one side or symbol
+type TradeBreakdown struct {
+	Trades int `json:"trades"`
+	Wins   int `json:"wins"`
+}
+
+// WinRate returns the percentage of winning trades, 0 without trades
+func (b TradeBreakdown) WinRate() float64 {
+	if b.Trades == 0 {
+		return 0
+	}
+	return float64(b.Wins) / float64(b.Trades) * 100
 }
 
 // AggregatedStats contains aggregated performance statistics for a baseline strategy
@@ -47,6 +69,22 @@ type AggregatedStats struct {
 	AvgWinRate     float64 `json:"avg_win_rate"`
 	BestReturnPct  float64 `json:"best_return_pct"`
 	WorstReturnPct float64 `json:"worst_return_pct"`
+
+	// Closed trades and winners per side summed over all runs, so a directional bias shows
+	// across many runs rather than in a single one
+	LongTrades   int     `json:"long_trades"`
+	LongWins     int     `json:"long_wins"`
+	ShortTrades  int     `json:"short_trades"`
+	ShortWins    int     `json:"short_wins"`
+	LongWinRate  float64 `json:"long_win_rate"`  // LongWins / LongTrades (%)
+	ShortWinRate float64 `json:"short_win_rate"` // ShortWins / ShortTrades (%)
+}
+
+// PerformanceFilter restricts which performance records are aggregated. Zero values do not filter.
+type PerformanceFilter struct {
+	StartTS int64  // only runs starting at or after this unix time (seconds)
+	EndTS   int64  // only runs ending at or before this unix time (seconds)
+	Symbol  string // only runs that traded this symbol
 }
 
 // BaselineStrategyWithStats combines strategy with its performance stats
@@ -65,8 +103,12 @@ func NewBaselineStrategyStore(db *sql.DB) *BaselineStrategyStore {
 	return &BaselineStrategyStore{db: db}
 }
 
-// Create creates a new baseline strategy
+// Create creates a new baseline strategy. An invalid config is rejected with
+// *InvalidBaselineConfigError.
 func (s *BaselineStrategyStore) Create(strategy *BaselineStrategy) error {
+	if errs := ValidateBaselineConfig(&strategy.Config); len(errs) > 0 {
+		return &InvalidBaselineConfigError{Errors: errs}
+	}
 	configJSON, err := json.Marshal(strategy.Config)
 	if err != nil {
 		return fmt.Errorf("failed to marshal config: %w", err)
@@ -80,8 +122,12 @@ func (s *BaselineStrategyStore) Create(strategy *BaselineStrategy) error {
 	return err
 }
 
-// Update updates an existing baseline strategy
+// Update updates an existing baseline strategy. An invalid config is rejected with
+// *InvalidBaselineConfigError.
 func (s *BaselineStrategyStore) Update(strategy *BaselineStrategy) error {
+	if errs := ValidateBaselineConfig(&strategy.Config); len(errs) > 0 {
+		return &InvalidBaselineConfigError{Errors: errs}
+	}
 	configJSON, err := json.Marshal(strategy.Config)
 	if err != nil {
 		return fmt.Errorf("failed to marshal config: %w", err)
@@ -130,6 +176,42 @@ func (s *BaselineStrategyStore) Delete(userID, id string) error {
 	return nil
 }
 
+// Clone copies a baseline strategy visible to the user (their own or a system default) into a
+// new strategy owned by the user. The copy is named "<name> (copy)", numbered when that name is
+// already taken, and is never a system default.
+func (s *BaselineStrategyStore) Clone(userID, sourceID, newID string) (*BaselineStrategy, error) {
+	source, err := s.Get(userID, sourceID)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := s.List(userID)
+	if err != nil {
+		return nil, err
+	}
+	taken := make(map[string]bool, len(existing))
+	for _, strategy := range existing {
+		taken[strategy.Name] = true
+	}
+	name := source.Name + " (copy)"
+	for n := 2; taken[name]; n++ {
+		name = fmt.Sprintf("%s (copy %d)", source.Name, n)
+	}
+
+	clone := &BaselineStrategy{
+		ID:              newID,
+		UserID:          userID,
+		Name:            name,
+		Description:     source.Description,
+		Config:          source.Config,
+		IsSystemDefault: false,
+	}
+	if err := s.Create(clone); err != nil {
+		return nil, err
+	}
+	return s.Get(userID, newID)
+}
+
 // Get retrieves a single baseline strategy by ID
 func (s *BaselineStrategyStore) Get(userID, id string) (*BaselineStrategy, error) {
 	var strategy BaselineStrategy
@@ -246,17 +328,27 @@ func (s *BaselineStrategyStore) SavePerformance(perf *BaselineStrategyPerformanc
 	if err != nil {
 		return fmt.Errorf("failed to marshal symbols: %w", err)
 	}
+	breakdown := perf.SymbolBreakdown
+	if breakdown == nil {
+		breakdown = map[string]TradeBreakdown{}
+	}
+	breakdownJSON, err := json.Marshal(breakdown)
+	if err != nil {
+		return fmt.Errorf("failed to marshal symbol breakdown: %w", err)
+	}
 
 	_, err = s.db.Exec(`
 		INSERT INTO baseline_strategy_performance (
 			baseline_strategy_id, run_id, symbols, timeframe, start_ts, end_ts,
 			initial_balance, final_equity, total_return_pct, max_drawdown_pct,
-			sharpe_ratio, win_rate, total_trades
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			sharpe_ratio, win_rate, total_trades,
+			long_trades, long_wins, short_trades, short_wins, symbol_breakdown
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`, perf.BaselineStrategyID, perf.RunID, string(symbolsJSON), perf.Timeframe,
 		perf.StartTS, perf.EndTS, perf.InitialBalance, perf.FinalEquity,
 		perf.TotalReturnPct, perf.MaxDrawdownPct, perf.SharpeRatio,
-		perf.WinRate, perf.TotalTrades)
+		perf.WinRate, perf.TotalTrades,
+		perf.LongTrades, perf.LongWins, perf.ShortTrades, perf.ShortWins, string(breakdownJSON))
 
 	return err
 }
@@ -270,7 +362,8 @@ func (s *BaselineStrategyStore) GetPerformanceHistory(baselineStrategyID string,
 	rows, err := s.db.Query(`
 		SELECT id, baseline_strategy_id, run_id, symbols, timeframe, start_ts, end_ts,
 			initial_balance, final_equity, total_return_pct, max_drawdown_pct,
-			sharpe_ratio, win_rate, total_trades, created_at
+			sharpe_ratio, win_rate, total_trades,
+			long_trades, long_wins, short_trades, short_wins, symbol_breakdown, created_at
 		FROM baseline_strategy_performance
 		WHERE baseline_strategy_id = ?
 		ORDER BY created_at DESC
@@ -284,45 +377,140 @@ func (s *BaselineStrategyStore) GetPerformanceHistory(baselineStrategyID string,
 
 	var performances []*BaselineStrategyPerformance
 	for rows.Next() {
-		var perf BaselineStrategyPerformance
-		var symbolsJSON string
-
-		if err := rows.Scan(
-			&perf.ID,
-			&perf.BaselineStrategyID,
-			&perf.RunID,
-			&symbolsJSON,
-			&perf.Timeframe,
-			&perf.StartTS,
-			&perf.EndTS,
-			&perf.InitialBalance,
-			&perf.FinalEquity,
-			&perf.TotalReturnPct,
-			&perf.MaxDrawdownPct,
-			&perf.SharpeRatio,
-			&perf.WinRate,
-			&perf.TotalTrades,
-			&perf.CreatedAt,
-		); err != nil {
+		perf, err := scanPerformance(rows)
+		if err != nil {
 			return nil, err
 		}
+		performances = append(performances, perf)
+	}
+
+	return performances, rows.Err()
+}
+
+// ForEachPerformance calls fn for every performance record of a baseline strategy, oldest
+// first, without loading the whole history into memory. It stops at the first error of fn.
+func (s *BaselineStrategyStore) ForEachPerformance(baselineStrategyID string, fn func(*BaselineStrategyPerformance) error) error {
+	rows, err := s.db.Query(`
+		SELECT id, baseline_strategy_id, run_id, symbols, timeframe, start_ts, end_ts,
+			initial_balance, final_equity, total_return_pct, max_drawdown_pct,
+			sharpe_ratio, win_rate, total_trades,
+			long_trades, long_wins, short_trades, short_wins, symbol_breakdown, created_at
+		FROM baseline_strategy_performance
+		WHERE baseline_strategy_id = ?
+		ORDER BY created_at ASC, id ASC
+	`, baselineStrategyID)
+
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
 
-		if err := json.Unmarshal([]byte(symbolsJSON), &perf.Symbols); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal symbols: %w", err)
+	for rows.Next() {
+		perf, err := scanPerformance(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(perf); err != nil {
+			return err
 		}
+	}
 
-		performances = append(performances, &perf)
+	return rows.Err()
+}
+
+// scanPerformance scans one baseline_strategy_performance row
+func scanPerformance(rows *sql.Rows) (*BaselineStrategyPerformance, error) {
+	var perf BaselineStrategyPerformance
+	var symbolsJSON string
+	var breakdownJSON sql.NullString
+
+	if err := rows.Scan(
+		&perf.ID,
+		&perf.BaselineStrategyID,
+		&perf.RunID,
+		&symbolsJSON,
+		&perf.Timeframe,
+		&perf.StartTS,
+		&perf.EndTS,
+		&perf.InitialBalance,
+		&perf.FinalEquity,
+		&perf.TotalReturnPct,
+		&perf.MaxDrawdownPct,
+		&perf.SharpeRatio,
+		&perf.WinRate,
+		&perf.TotalTrades,
+		&perf.LongTrades,
+		&perf.LongWins,
+		&perf.ShortTrades,
+		&perf.ShortWins,
+		&breakdownJSON,
+		&perf.CreatedAt,
+	); err != nil {
+		return nil, err
 	}
 
-	return performances, rows.Err()
+	if err := json.Unmarshal([]byte(symbolsJSON), &perf.Symbols); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal symbols: %w", err)
+	}
+	if breakdownJSON.Valid && breakdownJSON.String != "" {
+		if err := json.Unmarshal([]byte(breakdownJSON.String), &perf.SymbolBreakdown); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal symbol breakdown: %w", err)
+		}
+	}
+
+	return &perf, nil
+}
+
+// GetAggregatedStats calculates aggregated performance statistics for a baseline strategy over
+// the performance records matching filter. TotalRuns is the number of records that matched.
+func (s *BaselineStrategyStore) GetAggregatedStats(baselineStrategyID string, filter PerformanceFilter) (*AggregatedStats, error) {
+	return s.aggregateStats(baselineStrategyID, filter, 0)
 }
 
-// GetAggregatedStats calculates aggregated performance statistics for a baseline strategy
-func (s *BaselineStrategyStore) GetAggregatedStats(baselineStrategyID string) (*AggregatedStats, error) {
+// GetRecentStats calculates the same statistics as GetAggregatedStats over only the lastN most
+// recent performance records matching filter, so a recent regression is not averaged away by
+// older runs. A lastN <= 0 aggregates all matching records.
+func (s *BaselineStrategyStore) GetRecentStats(baselineStrategyID string, lastN int, filter PerformanceFilter) (*AggregatedStats, error) {
+	return s.aggregateStats(baselineStrategyID, filter, lastN)
+}
+
+// ReturnTrendPct is the recent average return minus the all-time average return: negative when
+// a strategy is doing worse lately than its record suggests. It is 0 without runs on either side.
+func ReturnTrendPct(recent, allTime *AggregatedStats) float64 {
+	if recent == nil || allTime == nil || recent.TotalRuns == 0 || allTime.TotalRuns == 0 {
+		return 0
+	}
+	return recent.AvgReturnPct - allTime.AvgReturnPct
+}
+
+// aggregateStats aggregates the performance records matching filter, limited to the lastN most
+// recent ones when lastN > 0
+func (s *BaselineStrategyStore) aggregateStats(baselineStrategyID string, filter PerformanceFilter, lastN int) (*AggregatedStats, error) {
 	var stats AggregatedStats
 	var totalRuns sql.NullInt64
 	var avgReturn, avgDrawdown, avgSharpe, avgWinRate sql.NullFloat64
 	var bestReturn, worstReturn sql.NullFloat64
+	var longTrades, longWins, shortTrades, shortWins sql.NullInt64
+
+	where := "baseline_strategy_id = ?"
+	args := []interface{}{baselineStrategyID}
+	if filter.StartTS > 0 {
+		where += " AND start_ts >= ?"
+		args = append(args, filter.StartTS)
+	}
+	if filter.EndTS > 0 {
+		where += " AND end_ts <= ?"
+		args = append(args, filter.EndTS)
+	}
+	if filter.Symbol != "" {
+		// symbols holds a JSON array of strings, so match the quoted symbol
+		where += " AND instr(symbols, ?) > 0"
+		args = append(args, `"`+filter.Symbol+`"`)
+	}
+	if lastN <= 0 {
+		lastN = -1 // SQLite: no limit
+	}
+	args = append(args, lastN)
 
 	err := s.db.QueryRow(`
 		SELECT
@@ -332,10 +520,19 @@ func (s *BaselineStrategyStore) GetAggregatedStats(baselineStrategyID string) (*
 			AVG(sharpe_ratio) as avg_sharpe_ratio,
 			AVG(win_rate) as avg_win_rate,
 			MAX(total_return_pct) as best_return_pct,
-			MIN(total_return_pct) as worst_return_pct
-		FROM baseline_strategy_performance
-		WHERE baseline_strategy_id = ?
-	`, baselineStrategyID).Scan(
+			MIN(total_return_pct) as worst_return_pct,
+			SUM(long_trades) as long_trades,
+			SUM(long_wins) as long_wins,
+			SUM(short_trades) as short_trades,
+			SUM(short_wins) as short_wins
+		FROM (
+			SELECT total_return_pct, max_drawdown_pct, sharpe_ratio, win_rate,
+				long_trades, long_wins, short_trades, short_wins
+			FROM baseline_strategy_performance
+			WHERE `+where+`
+			ORDER BY created_at DESC, id DESC
+			LIMIT ?
+		)`, args...).Scan(
 		&totalRuns,
 		&avgReturn,
 		&avgDrawdown,
@@ -343,6 +540,10 @@ func (s *BaselineStrategyStore) GetAggregatedStats(baselineStrategyID string) (*
 		&avgWinRate,
 		&bestReturn,
 		&worstReturn,
+		&longTrades,
+		&longWins,
+		&shortTrades,
+		&shortWins,
 	)
 
 	if err != nil {
@@ -370,12 +571,19 @@ func (s *BaselineStrategyStore) GetAggregatedStats(baselineStrategyID string) (*
 	if worstReturn.Valid {
 		stats.WorstReturnPct = worstReturn.Float64
 	}
+	stats.LongTrades = int(longTrades.Int64)
+	stats.LongWins = int(longWins.Int64)
+	stats.ShortTrades = int(shortTrades.Int64)
+	stats.ShortWins = int(shortWins.Int64)
+	stats.LongWinRate = TradeBreakdown{Trades: stats.LongTrades, Wins: stats.LongWins}.WinRate()
+	stats.ShortWinRate = TradeBreakdown{Trades: stats.ShortTrades, Wins: stats.ShortWins}.WinRate()
 
 	return &stats, nil
 }
 
-// ListWithPerformance retrieves all baseline strategies with their performance stats
-func (s *BaselineStrategyStore) ListWithPerformance(userID string) ([]*BaselineStrategyWithStats, error) {
+// ListWithPerformance retrieves all baseline strategies with their performance stats over the
+// performance records matching filter
+func (s *BaselineStrategyStore) ListWithPerformance(userID string, filter PerformanceFilter) ([]*BaselineStrategyWithStats, error) {
 	strategies, err := s.List(userID)
 	if err != nil {
 		return nil, err
@@ -383,7 +591,7 @@ func (s *BaselineStrategyStore) ListWithPerformance(userID string) ([]*BaselineS
 
 	var result []*BaselineStrategyWithStats
 	for _, strategy := range strategies {
-		stats, err := s.GetAggregatedStats(strategy.ID)
+		stats, err := s.GetAggregatedStats(strategy.ID, filter)
 		if err != nil {
 			// If no performance data exists, stats will be nil
 			stats = nil
@@ -397,3 +605,219 @@ func (s *BaselineStrategyStore) ListWithPerformance(userID string) ([]*BaselineS
 
 	return result, nil
 }
+
+// BaselineConfigError describes one invalid field of a BaselineConfig
+type BaselineConfigError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// InvalidBaselineConfigError is returned when a baseline strategy is saved with a config
+// that fails ValidateBaselineConfig
+type InvalidBaselineConfigError struct {
+	Errors []BaselineConfigError
+}
+
+func (e *InvalidBaselineConfigError) Error() string {
+	first := e.Errors[0]
+	if len(e.Errors) == 1 {
+		return fmt.Sprintf("invalid baseline config: %s %s", first.Field, first.Message)
+	}
+	return fmt.Sprintf("invalid baseline config: %s %s (and %d more)", first.Field, first.Message, len(e.Errors)-1)
+}
+
+// ValidateBaselineConfig checks a BaselineConfig for values the baseline engine cannot use.
+// Fields are named by their JSON path; an empty result means the config is valid.
+func ValidateBaselineConfig(cfg *BaselineConfig) []BaselineConfigError {
+	var errs []BaselineConfigError
+	add := func(field, format string, args ...interface{}) {
+		errs = append(errs, BaselineConfigError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	// Indicator periods
+	periods := []struct {
+		field string
+		value int
+	}{
+		{"rsi_period", cfg.RSIPeriod},
+		{"macd_fast", cfg.MACDFast},
+		{"macd_slow", cfg.MACDSlow},
+		{"macd_signal", cfg.MACDSignal},
+		{"ema_period", cfg.EMAPeriod},
+		{"stoch_rsi_period", cfg.StochRSIPeriod},
+		{"atr_period", cfg.ATRPeriod},
+	}
+	for _, p := range periods {
+		if p.value <= 0 {
+			add(p.field, "must be greater than 0")
+		}
+	}
+	if cfg.MACDFast > 0 && cfg.MACDSlow > 0 && cfg.MACDFast >= cfg.MACDSlow {
+		add("macd_fast", "must be less than macd_slow (%d)", cfg.MACDSlow)
+	}
+	// Optional indicators fall back to their defaults at 0
+	if cfg.BollingerPeriod < 0 {
+		add("bollinger_period", "must not be negative")
+	}
+	if cfg.BollingerStdDev < 0 {
+		add("bollinger_std_dev", "must not be negative")
+	}
+	if cfg.RSIDivergenceLookback < 0 {
+		add("rsi_divergence_lookback", "must not be negative")
+	}
+	if cfg.VWAPSessionHours < 0 {
+		add("vwap_session_hours", "must not be negative")
+	}
+	if cfg.OBVLookback < 0 {
+		add("obv_lookback", "must not be negative")
+	}
+	if cfg.IchimokuTenkanPeriod < 0 {
+		add("ichimoku_tenkan_period", "must not be negative")
+	}
+	if cfg.IchimokuKijunPeriod < 0 {
+		add("ichimoku_kijun_period", "must not be negative")
+	}
+	if cfg.IchimokuSenkouBPeriod < 0 {
+		add("ichimoku_senkou_b_period", "must not be negative")
+	}
+	if cfg.IchimokuTenkanPeriod > 0 && cfg.IchimokuKijunPeriod > 0 && cfg.IchimokuTenkanPeriod >= cfg.IchimokuKijunPeriod {
+		add("ichimoku_tenkan_period", "must be less than ichimoku_kijun_period (%d)", cfg.IchimokuKijunPeriod)
+	}
+	if cfg.PSARAcceleration < 0 {
+		add("psar_acceleration", "must not be negative")
+	}
+	if cfg.PSARMaxAcceleration < 0 {
+		add("psar_max_acceleration", "must not be negative")
+	}
+	if cfg.PSARAcceleration > 0 && cfg.PSARMaxAcceleration > 0 && cfg.PSARAcceleration > cfg.PSARMaxAcceleration {
+		add("psar_acceleration", "must not exceed psar_max_acceleration (%g)", cfg.PSARMaxAcceleration)
+	}
+	if cfg.RegimeTrendADX < 0 {
+		add("regime_trend_adx", "must not be negative")
+	}
+	if cfg.RegimeRangeADX < 0 {
+		add("regime_range_adx", "must not be negative")
+	}
+	if cfg.RegimeTrendADX > 0 && cfg.RegimeRangeADX > cfg.RegimeTrendADX {
+		add("regime_range_adx", "must not exceed regime_trend_adx (%g)", cfg.RegimeTrendADX)
+	}
+	if cfg.RegimeMinBandwidthPct < 0 {
+		add("regime_min_bandwidth_pct", "must not be negative")
+	}
+
+	// Trading hours
+	for i, window := range cfg.TradingHours {
+		field := fmt.Sprintf("trading_hours[%d]", i)
+		if window.StartHour < 0 || window.StartHour > 23 {
+			add(field+".start_hour", "must be between 0 and 23")
+		}
+		if window.EndHour < 0 || window.EndHour > 24 {
+			add(field+".end_hour", "must be between 0 and 24")
+		}
+		for _, day := range window.Weekdays {
+			if day < 0 || day > 6 {
+				add(field+".weekdays", "must be between 0 (Sunday) and 6 (Saturday)")
+				break
+			}
+		}
+	}
+
+	// Symbol universe
+	for i, symbol := range cfg.AllowedSymbols {
+		if strings.TrimSpace(symbol) == "" {
+			add(fmt.Sprintf("allowed_symbols[%d]", i), "must not be empty")
+		}
+	}
+
+	// Indicator weights
+	w := cfg.IndicatorWeights
+	weights := []struct {
+		field string
+		value float64
+	}{
+		{"indicator_weights.ema_weight", w.EMAWeight},
+		{"indicator_weights.stochrsi_weight", w.StochRSIWeight},
+		{"indicator_weights.bollinger_weight", w.BollingerWeight},
+		{"indicator_weights.macd_weight", w.MACDWeight},
+		{"indicator_weights.ichimoku_weight", w.IchimokuWeight},
+	}
+	for _, wt := range weights {
+		if wt.value < 0 {
+			add(wt.field, "must not be negative")
+		}
+	}
+	if caps := w.VolumeMultiplierCaps; caps.Min < 0 || caps.Min > 1 {
+		add("indicator_weights.volume_multiplier_caps.min", "must be between 0 and 1")
+	}
+	if caps := w.VolumeMultiplierCaps; caps.Max != 0 && caps.Max < 1 {
+		add("indicator_weights.volume_multiplier_caps.max", "must be at least 1")
+	}
+
+	// Signal thresholds
+	th := cfg.SignalThresholds
+	thresholds := []struct {
+		field string
+		value float64
+	}{
+		{"signal_thresholds.rsi_oversold", th.RSIOversold},
+		{"signal_thresholds.rsi_overbought", th.RSIOverbought},
+		{"signal_thresholds.stoch_oversold", th.StochOversold},
+		{"signal_thresholds.stoch_overbought", th.StochOverbought},
+	}
+	for _, t := range thresholds {
+		if t.value < 0 || t.value > 100 {
+			add(t.field, "must be between 0 and 100")
+		}
+	}
+	if th.RSIOversold >= th.RSIOverbought {
+		add("signal_thresholds.rsi_oversold", "must be less than rsi_overbought (%g)", th.RSIOverbought)
+	}
+	if th.StochOversold >= th.StochOverbought {
+		add("signal_thresholds.stoch_oversold", "must be less than stoch_overbought (%g)", th.StochOverbought)
+	}
+
+	// Risk management
+	if lev := cfg.RiskManagement.Leverage; lev < 1 || lev > 125 {
+		add("risk_management.leverage", "must be between 1 and 125")
+	}
+	if usage := cfg.RiskManagement.MaxMarginUsage; usage < 0 || usage > 1 {
+		add("risk_management.max_margin_usage", "must be between 0 and 1")
+	}
+	if dd := cfg.RiskManagement.MaxAccountDrawdownPct; dd < 0 || dd >= 100 {
+		add("risk_management.max_account_drawdown_pct", "must be between 0 and 100")
+	}
+	if resume := cfg.RiskManagement.DrawdownResumePct; resume < 0 {
+		add("risk_management.drawdown_resume_pct", "must not be negative")
+	} else if dd := cfg.RiskManagement.MaxAccountDrawdownPct; dd > 0 && resume >= dd {
+		add("risk_management.drawdown_resume_pct", "must be below max_account_drawdown_pct")
+	}
+	if cfg.RiskManagement.MinEdgeCostMultiple < 0 {
+		add("risk_management.min_edge_cost_multiple", "must not be negative")
+	}
+	if cfg.RiskManagement.MaxCorrelatedPositions < 0 {
+		add("risk_management.max_correlated_positions", "must not be negative")
+	}
+	if corr := cfg.RiskManagement.CorrelationThreshold; corr < 0 || corr > 1 {
+		add("risk_management.correlation_threshold", "must be between 0 and 1")
+	}
+	if cfg.RiskManagement.CorrelationWindow < 0 {
+		add("risk_management.correlation_window", "must not be negative")
+	}
+	if cfg.RiskManagement.MaxPyramidEntries < 0 {
+		add("risk_management.max_pyramid_entries", "must not be negative")
+	}
+	if cfg.RiskManagement.PyramidMinProfitPct < 0 {
+		add("risk_management.pyramid_min_profit_pct", "must not be negative")
+	}
+	if frac := cfg.RiskManagement.PyramidSizeFraction; frac < 0 || frac > 1 {
+		add("risk_management.pyramid_size_fraction", "must be between 0 and 1")
+	}
+	switch cfg.RiskManagement.IntrabarOrder {
+	case "", IntrabarOrderPessimistic, IntrabarOrderOptimistic, IntrabarOrderOpenRelative:
+	default:
+		add("risk_management.intrabar_order", "must be %q, %q or %q",
+			IntrabarOrderPessimistic, IntrabarOrderOptimistic, IntrabarOrderOpenRelative)
+	}
+
+	return errs
+}
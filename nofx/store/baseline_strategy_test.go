@@ -0,0 +1,370 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newTestBaselineStore creates a store with the baseline strategy tables, which are created
+// by the SQL migration rather than by the store itself
+func newTestBaselineStore(t *testing.T) *Store {
+	t.Helper()
+	st, err := New(filepath.Join(t.TempDir(), "store.db"))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	t.Cleanup(func() { st.Close() })
+
+	for _, name := range []string{"add_baseline_strategies.sql", "add_baseline_performance_breakdown.sql"} {
+		migration, err := os.ReadFile(filepath.Join("../../migrations", name))
+		if err != nil {
+			t.Fatalf("failed to read baseline migration %s: %v", name, err)
+		}
+		if _, err := st.DB().Exec(string(migration)); err != nil {
+			t.Fatalf("failed to apply baseline migration %s: %v", name, err)
+		}
+	}
+	return st
+}
+
+func validBaselineConfig() BaselineConfig {
+	return BaselineConfig{
+		RSIPeriod:      14,
+		MACDFast:       12,
+		MACDSlow:       26,
+		MACDSignal:     9,
+		EMAPeriod:      20,
+		StochRSIPeriod: 14,
+		ATRPeriod:      14,
+		SignalThresholds: BaselineSignalThresholds{
+			RSIOversold:     30,
+			RSIOverbought:   70,
+			StochOversold:   20,
+			StochOverbought: 80,
+		},
+		RiskManagement: BaselineRiskManagement{Leverage: 5},
+	}
+}
+
+func TestValidateBaselineConfigAcceptsDefaults(t *testing.T) {
+	cfg := validBaselineConfig()
+	if errs := ValidateBaselineConfig(&cfg); len(errs) != 0 {
+		t.Fatalf("expected default config to be valid, got %+v", errs)
+	}
+}
+
+func TestValidateBaselineConfigRejectsInvalidFields(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(cfg *BaselineConfig)
+		field  string
+	}{
+		{"zero rsi period", func(cfg *BaselineConfig) { cfg.RSIPeriod = 0 }, "rsi_period"},
+		{"zero macd signal", func(cfg *BaselineConfig) { cfg.MACDSignal = 0 }, "macd_signal"},
+		{"zero ema period", func(cfg *BaselineConfig) { cfg.EMAPeriod = 0 }, "ema_period"},
+		{"zero stoch rsi period", func(cfg *BaselineConfig) { cfg.StochRSIPeriod = 0 }, "stoch_rsi_period"},
+		{"zero atr period", func(cfg *BaselineConfig) { cfg.ATRPeriod = 0 }, "atr_period"},
+		{"macd fast not below slow", func(cfg *BaselineConfig) { cfg.MACDFast = 26 }, "macd_fast"},
+		{"negative bollinger period", func(cfg *BaselineConfig) { cfg.BollingerPeriod = -1 }, "bollinger_period"},
+		{"negative bollinger std dev", func(cfg *BaselineConfig) { cfg.BollingerStdDev = -2 }, "bollinger_std_dev"},
+		{"negative divergence lookback", func(cfg *BaselineConfig) { cfg.RSIDivergenceLookback = -5 }, "rsi_divergence_lookback"},
+		{"negative vwap session", func(cfg *BaselineConfig) { cfg.VWAPSessionHours = -1 }, "vwap_session_hours"},
+		{"ichimoku tenkan not below kijun", func(cfg *BaselineConfig) {
+			cfg.IchimokuTenkanPeriod = 30
+			cfg.IchimokuKijunPeriod = 26
+		}, "ichimoku_tenkan_period"},
+		{"trading hour out of range", func(cfg *BaselineConfig) {
+			cfg.TradingHours = []BaselineTradingWindow{{StartHour: 8, EndHour: 25}}
+		}, "trading_hours[0].end_hour"},
+		{"invalid weekday", func(cfg *BaselineConfig) {
+			cfg.TradingHours = []BaselineTradingWindow{{StartHour: 8, EndHour: 20, Weekdays: []int{1, 7}}}
+		}, "trading_hours[0].weekdays"},
+		{"empty allowed symbol", func(cfg *BaselineConfig) { cfg.AllowedSymbols = []string{"BTCUSDT", " "} }, "allowed_symbols[1]"},
+		{"negative ema weight", func(cfg *BaselineConfig) { cfg.IndicatorWeights.EMAWeight = -5 }, "indicator_weights.ema_weight"},
+		{"volume cap max below 1", func(cfg *BaselineConfig) { cfg.IndicatorWeights.VolumeMultiplierCaps.Max = 0.9 }, "indicator_weights.volume_multiplier_caps.max"},
+		{"inverted rsi thresholds", func(cfg *BaselineConfig) { cfg.SignalThresholds.RSIOversold = 75 }, "signal_thresholds.rsi_oversold"},
+		{"rsi threshold over 100", func(cfg *BaselineConfig) { cfg.SignalThresholds.RSIOverbought = 120 }, "signal_thresholds.rsi_overbought"},
+		{"inverted stoch thresholds", func(cfg *BaselineConfig) { cfg.SignalThresholds.StochOversold = 80 }, "signal_thresholds.stoch_oversold"},
+		{"negative stoch threshold", func(cfg *BaselineConfig) { cfg.SignalThresholds.StochOversold = -10 }, "signal_thresholds.stoch_oversold"},
+		{"zero leverage", func(cfg *BaselineConfig) { cfg.RiskManagement.Leverage = 0 }, "risk_management.leverage"},
+		{"leverage above 125", func(cfg *BaselineConfig) { cfg.RiskManagement.Leverage = 200 }, "risk_management.leverage"},
+		{"margin usage above 1", func(cfg *BaselineConfig) { cfg.RiskManagement.MaxMarginUsage = 1.5 }, "risk_management.max_margin_usage"},
+		{"drawdown resume above limit", func(cfg *BaselineConfig) {
+			cfg.RiskManagement.MaxAccountDrawdownPct = 10
+			cfg.RiskManagement.DrawdownResumePct = 12
+		}, "risk_management.drawdown_resume_pct"},
+		{"correlation threshold above 1", func(cfg *BaselineConfig) { cfg.RiskManagement.CorrelationThreshold = 1.2 }, "risk_management.correlation_threshold"},
+		{"unknown intrabar order", func(cfg *BaselineConfig) { cfg.RiskManagement.IntrabarOrder = "random" }, "risk_management.intrabar_order"},
+		{"pyramid size fraction above 1", func(cfg *BaselineConfig) { cfg.RiskManagement.PyramidSizeFraction = 1.5 }, "risk_management.pyramid_size_fraction"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validBaselineConfig()
+			tt.modify(&cfg)
+
+			errs := ValidateBaselineConfig(&cfg)
+			if len(errs) == 0 {
+				t.Fatalf("expected %s to be rejected", tt.field)
+			}
+			for _, e := range errs {
+				if e.Field == tt.field && e.Message != "" {
+					return
+				}
+			}
+			t.Fatalf("expected an error for %s, got %+v", tt.field, errs)
+		})
+	}
+}
+
+func TestGetAggregatedStatsFilters(t *testing.T) {
+	st := newTestBaselineStore(t)
+	baselines := st.BaselineStrategy()
+	if err := baselines.Create(&BaselineStrategy{ID: "trend", UserID: "user-1", Name: "Trend", Config: validBaselineConfig()}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	const day = int64(86400)
+	runs := []struct {
+		runID   string
+		symbols []string
+		start   int64
+		ret     float64
+	}{
+		{"old-btc", []string{"BTCUSDT"}, 10 * day, -10},
+		{"new-btc", []string{"BTCUSDT", "ETHUSDT"}, 100 * day, 20},
+		{"new-sol", []string{"SOLUSDT"}, 101 * day, 6},
+		{"new-btcdom", []string{"BTCDOMUSDT"}, 102 * day, 50},
+	}
+	for _, r := range runs {
+		// Performance rows reference the backtest run they came from
+		if _, err := st.DB().Exec(`INSERT INTO backtest_runs (run_id) VALUES (?)`, r.runID); err != nil {
+			t.Fatalf("failed to insert backtest run: %v", err)
+		}
+		if err := baselines.SavePerformance(&BaselineStrategyPerformance{
+			BaselineStrategyID: "trend",
+			RunID:              r.runID,
+			Symbols:            r.symbols,
+			Timeframe:          "1h",
+			StartTS:            r.start,
+			EndTS:              r.start + day,
+			TotalReturnPct:     r.ret,
+		}); err != nil {
+			t.Fatalf("SavePerformance failed: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		filter    PerformanceFilter
+		runs      int
+		avgReturn float64
+	}{
+		{"no filter", PerformanceFilter{}, 4, 16.5},
+		{"start only", PerformanceFilter{StartTS: 50 * day}, 3, 76.0 / 3},
+		{"end only", PerformanceFilter{EndTS: 50 * day}, 1, -10},
+		{"date range", PerformanceFilter{StartTS: 50 * day, EndTS: 102 * day}, 2, 13},
+		{"symbol", PerformanceFilter{Symbol: "BTCUSDT"}, 2, 5},
+		{"symbol and date", PerformanceFilter{StartTS: 50 * day, Symbol: "BTCUSDT"}, 1, 20},
+		{"no match", PerformanceFilter{Symbol: "DOGEUSDT"}, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats, err := baselines.GetAggregatedStats("trend", tt.filter)
+			if err != nil {
+				t.Fatalf("GetAggregatedStats failed: %v", err)
+			}
+			if stats.TotalRuns != tt.runs || math.Abs(stats.AvgReturnPct-tt.avgReturn) > 1e-9 {
+				t.Fatalf("expected %d runs averaging %.2f%%, got %d averaging %.2f%%",
+					tt.runs, tt.avgReturn, stats.TotalRuns, stats.AvgReturnPct)
+			}
+		})
+	}
+}
+
+func TestGetRecentStatsTracksTrend(t *testing.T) {
+	st := newTestBaselineStore(t)
+	baselines := st.BaselineStrategy()
+	if err := baselines.Create(&BaselineStrategy{ID: "trend", UserID: "user-1", Name: "Trend", Config: validBaselineConfig()}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	runs := 0
+	save := func(returns ...float64) {
+		t.Helper()
+		for _, ret := range returns {
+			runs++
+			runID := fmt.Sprintf("run-%d", runs)
+			if _, err := st.DB().Exec(`INSERT INTO backtest_runs (run_id) VALUES (?)`, runID); err != nil {
+				t.Fatalf("failed to insert backtest run: %v", err)
+			}
+			if err := baselines.SavePerformance(&BaselineStrategyPerformance{
+				BaselineStrategyID: "trend",
+				RunID:              runID,
+				Symbols:            []string{"BTCUSDT"},
+				Timeframe:          "1h",
+				TotalReturnPct:     ret,
+			}); err != nil {
+				t.Fatalf("SavePerformance failed: %v", err)
+			}
+		}
+	}
+	trend := func(lastN int) (*AggregatedStats, float64) {
+		t.Helper()
+		allTime, err := baselines.GetAggregatedStats("trend", PerformanceFilter{})
+		if err != nil {
+			t.Fatalf("GetAggregatedStats failed: %v", err)
+		}
+		recent, err := baselines.GetRecentStats("trend", lastN, PerformanceFilter{})
+		if err != nil {
+			t.Fatalf("GetRecentStats failed: %v", err)
+		}
+		return recent, ReturnTrendPct(recent, allTime)
+	}
+
+	// Improving: the latest runs beat the all-time average
+	save(1, 2, 3, 4, 5)
+	recent, delta := trend(2)
+	if recent.TotalRuns != 2 || math.Abs(recent.AvgReturnPct-4.5) > 1e-9 || math.Abs(delta-1.5) > 1e-9 {
+		t.Fatalf("improving: recent %d runs averaging %.2f%%, trend %.2f, want 2 runs, 4.5%%, +1.5",
+			recent.TotalRuns, recent.AvgReturnPct, delta)
+	}
+
+	// Degrading: the all-time average of 1.5% hides that the last three runs average -1%
+	save(2, -1, -4)
+	recent, delta = trend(3)
+	if recent.TotalRuns != 3 || math.Abs(recent.AvgReturnPct+1) > 1e-9 || math.Abs(delta+2.5) > 1e-9 {
+		t.Fatalf("degrading: recent %d runs averaging %.2f%%, trend %.2f, want 3 runs, -1%%, -2.5",
+			recent.TotalRuns, recent.AvgReturnPct, delta)
+	}
+	if recent.BestReturnPct != 2 || recent.WorstReturnPct != -4 {
+		t.Fatalf("recent best/worst = %.2f/%.2f, want 2/-4", recent.BestReturnPct, recent.WorstReturnPct)
+	}
+
+	// More runs requested than exist covers the whole history
+	if recent, delta = trend(100); recent.TotalRuns != 8 || delta != 0 {
+		t.Fatalf("expected all 8 runs and no trend, got %d runs and %.2f", recent.TotalRuns, delta)
+	}
+	if got := ReturnTrendPct(nil, recent); got != 0 {
+		t.Fatalf("expected no trend without recent stats, got %.2f", got)
+	}
+}
+
+func TestPerformanceWinRateBreakdownRoundTrip(t *testing.T) {
+	st := newTestBaselineStore(t)
+	baselines := st.BaselineStrategy()
+	if err := baselines.Create(&BaselineStrategy{ID: "trend", UserID: "user-1", Name: "Trend", Config: validBaselineConfig()}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	runs := []*BaselineStrategyPerformance{
+		{
+			RunID: "run-1", Symbols: []string{"BTCUSDT", "ETHUSDT"},
+			LongTrades: 6, LongWins: 5, ShortTrades: 4, ShortWins: 1,
+			SymbolBreakdown: map[string]TradeBreakdown{
+				"BTCUSDT": {Trades: 7, Wins: 4},
+				"ETHUSDT": {Trades: 3, Wins: 2},
+			},
+		},
+		{
+			RunID: "run-2", Symbols: []string{"BTCUSDT"},
+			LongTrades: 4, LongWins: 3, ShortTrades: 6, ShortWins: 2,
+			SymbolBreakdown: map[string]TradeBreakdown{"BTCUSDT": {Trades: 10, Wins: 5}},
+		},
+		// Saved without a breakdown, e.g. by an older runner
+		{RunID: "run-3", Symbols: []string{"SOLUSDT"}},
+	}
+	for _, perf := range runs {
+		if _, err := st.DB().Exec(`INSERT INTO backtest_runs (run_id) VALUES (?)`, perf.RunID); err != nil {
+			t.Fatalf("failed to insert backtest run: %v", err)
+		}
+		perf.BaselineStrategyID = "trend"
+		perf.Timeframe = "1h"
+		if err := baselines.SavePerformance(perf); err != nil {
+			t.Fatalf("SavePerformance failed: %v", err)
+		}
+	}
+
+	history, err := baselines.GetPerformanceHistory("trend", 0)
+	if err != nil {
+		t.Fatalf("GetPerformanceHistory failed: %v", err)
+	}
+	byRun := make(map[string]*BaselineStrategyPerformance)
+	for _, perf := range history {
+		byRun[perf.RunID] = perf
+	}
+	for _, want := range runs {
+		got := byRun[want.RunID]
+		if got == nil {
+			t.Fatalf("%s missing from history", want.RunID)
+		}
+		if got.LongTrades != want.LongTrades || got.LongWins != want.LongWins ||
+			got.ShortTrades != want.ShortTrades || got.ShortWins != want.ShortWins {
+			t.Errorf("%s: long %d/%d short %d/%d, want long %d/%d short %d/%d", want.RunID,
+				got.LongWins, got.LongTrades, got.ShortWins, got.ShortTrades,
+				want.LongWins, want.LongTrades, want.ShortWins, want.ShortTrades)
+		}
+		if len(got.SymbolBreakdown) != len(want.SymbolBreakdown) {
+			t.Fatalf("%s: symbol breakdown %+v, want %+v", want.RunID, got.SymbolBreakdown, want.SymbolBreakdown)
+		}
+		for symbol, b := range want.SymbolBreakdown {
+			if got.SymbolBreakdown[symbol] != b {
+				t.Errorf("%s: %s breakdown %+v, want %+v", want.RunID, symbol, got.SymbolBreakdown[symbol], b)
+			}
+		}
+	}
+
+	// Long wins 8 of 10 while short wins 3 of 10 across the runs
+	stats, err := baselines.GetAggregatedStats("trend", PerformanceFilter{})
+	if err != nil {
+		t.Fatalf("GetAggregatedStats failed: %v", err)
+	}
+	if stats.LongTrades != 10 || stats.LongWins != 8 || stats.ShortTrades != 10 || stats.ShortWins != 3 {
+		t.Fatalf("aggregated long %d/%d short %d/%d, want long 8/10 short 3/10",
+			stats.LongWins, stats.LongTrades, stats.ShortWins, stats.ShortTrades)
+	}
+	if stats.LongWinRate != 80 || stats.ShortWinRate != 30 {
+		t.Fatalf("aggregated win rates long %.2f%% short %.2f%%, want 80%% and 30%%", stats.LongWinRate, stats.ShortWinRate)
+	}
+
+	// No runs: zero counts and win rates rather than NULL scan errors
+	empty, err := baselines.GetAggregatedStats("trend", PerformanceFilter{Symbol: "DOGEUSDT"})
+	if err != nil {
+		t.Fatalf("GetAggregatedStats failed: %v", err)
+	}
+	if empty.LongTrades != 0 || empty.LongWinRate != 0 || empty.ShortWinRate != 0 {
+		t.Fatalf("expected an empty breakdown, got %+v", empty)
+	}
+}
+
+func TestBaselineStrategyWritesRejectInvalidConfig(t *testing.T) {
+	st := newTestBaselineStore(t)
+	baselines := st.BaselineStrategy()
+
+	invalid := validBaselineConfig()
+	invalid.MACDFast = 30
+	err := baselines.Create(&BaselineStrategy{ID: "broken", UserID: "user-1", Name: "Broken", Config: invalid})
+	var configErr *InvalidBaselineConfigError
+	if !errors.As(err, &configErr) || len(configErr.Errors) != 1 || configErr.Errors[0].Field != "macd_fast" {
+		t.Fatalf("expected a macd_fast config error from Create, got %v", err)
+	}
+
+	if err := baselines.Create(&BaselineStrategy{ID: "trend", UserID: "user-1", Name: "Trend", Config: validBaselineConfig()}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	err = baselines.Update(&BaselineStrategy{ID: "trend", UserID: "user-1", Name: "Trend", Config: invalid})
+	if !errors.As(err, &configErr) {
+		t.Fatalf("expected a config error from Update, got %v", err)
+	}
+	saved, err := baselines.Get("user-1", "trend")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if saved.Config.MACDFast != 12 {
+		t.Fatalf("expected the rejected update to leave the config unchanged, got macd_fast %d", saved.Config.MACDFast)
+	}
+}
@@ -2,12 +2,35 @@ package store
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"nofx/evotypes"
+
+	"github.com/google/uuid"
 )
 
+// ErrInvalidEvolution is returned when an evolution (or an imported bundle) is rejected
+// before it is stored; the message tells the caller what to fix
+var ErrInvalidEvolution = errors.New("invalid evolution")
+
+// validateEvolutionConfig checks the stored JSON config of an evolution. A config that is
+// not JSON is left alone; it is parsed with defaults when the evolution starts.
+func validateEvolutionConfig(config string) error {
+	var cfg evotypes.EvolutionConfig
+	if err := json.Unmarshal([]byte(config), &cfg); err != nil {
+		return nil
+	}
+	if err := evotypes.ValidateWebhookURL(cfg.WebhookURL); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidEvolution, err)
+	}
+	return nil
+}
+
 // EvolutionStore manages evolution task storage
 type EvolutionStore struct {
 	db *sql.DB
@@ -64,6 +87,39 @@ func (s *EvolutionStore) initTables() error {
 		return fmt.Errorf("create evolution_iterations table: %w", err)
 	}
 
+	// Create evolution_checkpoints table
+	_, err = s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS evolution_checkpoints (
+			evolution_id TEXT NOT NULL,
+			version INTEGER NOT NULL,
+			backtest_run_id TEXT NOT NULL,
+			evaluation_report TEXT,
+			metrics TEXT,
+			trades TEXT,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (evolution_id, version)
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("create evolution_checkpoints table: %w", err)
+	}
+
+	// Create evolution_population table
+	_, err = s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS evolution_population (
+			evolution_id TEXT NOT NULL,
+			generation INTEGER NOT NULL,
+			version INTEGER NOT NULL,
+			rank INTEGER NOT NULL,
+			elite INTEGER DEFAULT 0,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (evolution_id, generation, version)
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("create evolution_population table: %w", err)
+	}
+
 	// Create indexes
 	_, _ = s.db.Exec(`CREATE INDEX IF NOT EXISTS idx_evolutions_user ON evolutions(user_id)`)
 	_, _ = s.db.Exec(`CREATE INDEX IF NOT EXISTS idx_evolutions_status ON evolutions(status)`)
@@ -72,6 +128,59 @@ func (s *EvolutionStore) initTables() error {
 	// Migration: add best_drawdown column if not exists
 	_, _ = s.db.Exec(`ALTER TABLE evolutions ADD COLUMN best_drawdown REAL DEFAULT 0`)
 
+	// Migration: add out-of-sample validation metrics columns if not exist
+	_, _ = s.db.Exec(`ALTER TABLE evolution_iterations ADD COLUMN val_total_return REAL`)
+	_, _ = s.db.Exec(`ALTER TABLE evolution_iterations ADD COLUMN val_max_drawdown REAL`)
+	_, _ = s.db.Exec(`ALTER TABLE evolution_iterations ADD COLUMN val_win_rate REAL`)
+	_, _ = s.db.Exec(`ALTER TABLE evolution_iterations ADD COLUMN val_sharpe_ratio REAL`)
+	_, _ = s.db.Exec(`ALTER TABLE evolution_iterations ADD COLUMN val_trades INTEGER`)
+
+	// Migration: add Pareto frontier membership flag if not exists
+	_, _ = s.db.Exec(`ALTER TABLE evolution_iterations ADD COLUMN on_pareto_frontier INTEGER DEFAULT 0`)
+
+	// Migration: add downsampled equity curve column if not exists
+	_, _ = s.db.Exec(`ALTER TABLE evolution_iterations ADD COLUMN equity_curve TEXT`)
+
+	// Migration: add sampled decisions column if not exists
+	_, _ = s.db.Exec(`ALTER TABLE evolution_iterations ADD COLUMN decision_samples TEXT`)
+
+	// Migration: add downside-risk-adjusted ratio columns if not exist
+	_, _ = s.db.Exec(`ALTER TABLE evolution_iterations ADD COLUMN sortino_ratio REAL`)
+	_, _ = s.db.Exec(`ALTER TABLE evolution_iterations ADD COLUMN calmar_ratio REAL`)
+
+	// Migration: add trade streak and expectancy columns if not exist
+	_, _ = s.db.Exec(`ALTER TABLE evolution_iterations ADD COLUMN max_consecutive_losses INTEGER`)
+	_, _ = s.db.Exec(`ALTER TABLE evolution_iterations ADD COLUMN avg_win REAL`)
+	_, _ = s.db.Exec(`ALTER TABLE evolution_iterations ADD COLUMN avg_loss REAL`)
+	_, _ = s.db.Exec(`ALTER TABLE evolution_iterations ADD COLUMN expectancy REAL`)
+
+	// Migration: add profit factor and average trade PnL columns if not exist
+	_, _ = s.db.Exec(`ALTER TABLE evolution_iterations ADD COLUMN profit_factor REAL`)
+	_, _ = s.db.Exec(`ALTER TABLE evolution_iterations ADD COLUMN avg_trade_pnl REAL`)
+
+	// Migration: add promotion outcome reason columns if not exist
+	_, _ = s.db.Exec(`ALTER TABLE evolution_iterations ADD COLUMN improvement_reason TEXT`)
+	_, _ = s.db.Exec(`ALTER TABLE evolution_iterations ADD COLUMN failure_reason TEXT`)
+
+	// Migration: add AI token usage columns if not exist
+	_, _ = s.db.Exec(`ALTER TABLE evolution_iterations ADD COLUMN prompt_tokens INTEGER DEFAULT 0`)
+	_, _ = s.db.Exec(`ALTER TABLE evolution_iterations ADD COLUMN completion_tokens INTEGER DEFAULT 0`)
+
+	// Migration: count restarts of stalled backtests
+	_, _ = s.db.Exec(`ALTER TABLE evolution_iterations ADD COLUMN stall_restarts INTEGER DEFAULT 0`)
+
+	// Migration: flag Sharpe/Sortino ratios stored before they were annualized. The flag is
+	// only backfilled when the column is first added, so later rows keep the default of 0.
+	if _, err := s.db.Exec(`ALTER TABLE evolution_iterations ADD COLUMN per_period_sharpe INTEGER DEFAULT 0`); err == nil {
+		_, _ = s.db.Exec(`UPDATE evolution_iterations SET per_period_sharpe = 1 WHERE sharpe_ratio IS NOT NULL`)
+	}
+
+	// Migration: iterations used to be recorded as "backtest" while their backtest ran
+	_, _ = s.db.Exec(`UPDATE evolution_iterations SET status = ? WHERE status = 'backtest'`, evotypes.IterStatusBacktest)
+
+	// Migration: add soft-delete timestamp column if not exists
+	_, _ = s.db.Exec(`ALTER TABLE evolutions ADD COLUMN deleted_at DATETIME`)
+
 	// Create trigger for updated_at
 	_, err = s.db.Exec(`
 		CREATE TRIGGER IF NOT EXISTS update_evolutions_updated_at
@@ -84,8 +193,12 @@ func (s *EvolutionStore) initTables() error {
 	return err
 }
 
-// Create creates a new evolution task
+// Create creates a new evolution task. It returns ErrInvalidEvolution when the config
+// is rejected (e.g. a webhook_url that is not an http(s) URL).
 func (s *EvolutionStore) Create(evo *evotypes.Evolution) error {
+	if err := validateEvolutionConfig(evo.Config); err != nil {
+		return err
+	}
 	_, err := s.db.Exec(`
 		INSERT INTO evolutions (id, user_id, name, base_strategy_id, status,
 			current_iteration, max_iterations, convergence_threshold,
@@ -107,7 +220,7 @@ func (s *EvolutionStore) Get(userID, evolutionID string) (*evotypes.Evolution, e
 			max_iterations, convergence_threshold, best_version, best_return,
 			COALESCE(best_drawdown, 0), config, created_at, updated_at
 		FROM evolutions
-		WHERE id = ? AND user_id = ?
+		WHERE id = ? AND user_id = ? AND deleted_at IS NULL
 	`, evolutionID, userID).Scan(
 		&evo.ID, &evo.UserID, &evo.Name, &evo.BaseStrategyID, &evo.Status,
 		&evo.CurrentIteration, &evo.MaxIterations, &evo.ConvergenceThreshold,
@@ -123,14 +236,14 @@ func (s *EvolutionStore) Get(userID, evolutionID string) (*evotypes.Evolution, e
 	return &evo, nil
 }
 
-// List retrieves all evolution tasks for a user
+// List retrieves all evolution tasks for a user, excluding soft-deleted ones
 func (s *EvolutionStore) List(userID string) ([]*evotypes.Evolution, error) {
 	rows, err := s.db.Query(`
 		SELECT id, user_id, name, base_strategy_id, status, current_iteration,
 			max_iterations, convergence_threshold, best_version, best_return,
 			COALESCE(best_drawdown, 0), config, created_at, updated_at
 		FROM evolutions
-		WHERE user_id = ?
+		WHERE user_id = ? AND deleted_at IS NULL
 		ORDER BY created_at DESC
 	`, userID)
 	if err != nil {
@@ -205,8 +318,9 @@ func (s *EvolutionStore) UpdateBaseStrategy(evolutionID, strategyID string) erro
 
 // CreateIteration creates a new iteration record
 func (s *EvolutionStore) CreateIteration(iter *evotypes.Iteration) error {
-	var totalReturn, maxDrawdown, winRate, sharpeRatio sql.NullFloat64
+	var totalReturn, maxDrawdown, winRate, sharpeRatio, profitFactor, avgTradePnL sql.NullFloat64
 	var trades sql.NullInt64
+	var perPeriodSharpe bool
 
 	if iter.Metrics != nil {
 		totalReturn = sql.NullFloat64{Float64: iter.Metrics.TotalReturn, Valid: true}
@@ -214,27 +328,34 @@ func (s *EvolutionStore) CreateIteration(iter *evotypes.Iteration) error {
 		winRate = sql.NullFloat64{Float64: iter.Metrics.WinRate, Valid: true}
 		sharpeRatio = sql.NullFloat64{Float64: iter.Metrics.SharpeRatio, Valid: true}
 		trades = sql.NullInt64{Int64: int64(iter.Metrics.Trades), Valid: true}
+		profitFactor = sql.NullFloat64{Float64: iter.Metrics.ProfitFactor, Valid: true}
+		avgTradePnL = sql.NullFloat64{Float64: iter.Metrics.AvgTradePnL, Valid: true}
+		perPeriodSharpe = iter.Metrics.PerPeriodSharpe
 	}
 
-	_, err := s.db.Exec(`
-		INSERT INTO evolution_iterations (
-			evolution_id, version, strategy_id, backtest_run_id, status,
-			total_return, max_drawdown, win_rate, sharpe_ratio, trades,
-			evaluation_report, changes_summary, prompt_before, prompt_after
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`, iter.EvolutionID, iter.Version, iter.StrategyID, iter.BacktestRunID, iter.Status,
-		totalReturn, maxDrawdown, winRate, sharpeRatio, trades,
-		iter.EvalReport, iter.ChangesSummary, iter.PromptBefore, iter.PromptAfter)
-
-	return err
+	// Parallel backtests of several evolutions write iterations at the same time
+	return retryOnBusy(func() error {
+		_, err := s.db.Exec(`
+			INSERT INTO evolution_iterations (
+				evolution_id, version, strategy_id, backtest_run_id, status,
+				total_return, max_drawdown, win_rate, sharpe_ratio, trades,
+				profit_factor, avg_trade_pnl, per_period_sharpe,
+				evaluation_report, changes_summary, prompt_before, prompt_after,
+				improvement_reason, failure_reason
+			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, iter.EvolutionID, iter.Version, iter.StrategyID, iter.BacktestRunID, iter.Status,
+			totalReturn, maxDrawdown, winRate, sharpeRatio, trades,
+			profitFactor, avgTradePnL, perPeriodSharpe,
+			iter.EvalReport, iter.ChangesSummary, iter.PromptBefore, iter.PromptAfter,
+			iter.ImprovementReason, iter.FailureReason)
+		return err
+	})
 }
 
 // GetIterations retrieves all iterations for an evolution task
 func (s *EvolutionStore) GetIterations(evolutionID string) ([]*evotypes.Iteration, error) {
 	rows, err := s.db.Query(`
-		SELECT id, evolution_id, version, strategy_id, backtest_run_id, status,
-			total_return, max_drawdown, win_rate, sharpe_ratio, trades,
-			evaluation_report, changes_summary, prompt_before, prompt_after, created_at
+		SELECT `+iterationColumns+`
 		FROM evolution_iterations
 		WHERE evolution_id = ?
 		ORDER BY version ASC
@@ -256,15 +377,133 @@ func (s *EvolutionStore) GetIterations(evolutionID string) ([]*evotypes.Iteratio
 	return iterations, nil
 }
 
+// GetIterationsPaged retrieves a page of an evolution's iterations ordered by version, along
+// with the total number of iterations. A limit <= 0 returns all iterations from offset on.
+func (s *EvolutionStore) GetIterationsPaged(evolutionID string, limit, offset int) ([]*evotypes.Iteration, int, error) {
+	var total int
+	if err := s.db.QueryRow(`
+		SELECT COUNT(*) FROM evolution_iterations WHERE evolution_id = ?
+	`, evolutionID).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	if limit <= 0 {
+		limit = -1 // SQLite: no limit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	rows, err := s.db.Query(`
+		SELECT `+iterationColumns+`
+		FROM evolution_iterations
+		WHERE evolution_id = ?
+		ORDER BY version ASC
+		LIMIT ? OFFSET ?
+	`, evolutionID, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	iterations := []*evotypes.Iteration{}
+	for rows.Next() {
+		iter, err := s.scanIteration(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		iterations = append(iterations, iter)
+	}
+
+	return iterations, total, rows.Err()
+}
+
+// IterationStats aggregates all iterations of an evolution
+type IterationStats struct {
+	Count            int
+	PromptTokens     int64
+	CompletionTokens int64
+	// CompletedAfter counts the completed iterations newer than the version passed in
+	CompletedAfter int
+}
+
+// GetIterationStats aggregates an evolution's iterations in a single query, counting the
+// completed iterations after version separately
+func (s *EvolutionStore) GetIterationStats(evolutionID string, version int) (*IterationStats, error) {
+	var stats IterationStats
+	err := s.db.QueryRow(`
+		SELECT COUNT(*), COALESCE(SUM(prompt_tokens), 0), COALESCE(SUM(completion_tokens), 0),
+			COALESCE(SUM(CASE WHEN version > ? AND status = ? THEN 1 ELSE 0 END), 0)
+		FROM evolution_iterations
+		WHERE evolution_id = ?
+	`, version, evotypes.IterStatusCompleted, evolutionID).Scan(
+		&stats.Count, &stats.PromptTokens, &stats.CompletionTokens, &stats.CompletedAfter)
+	if err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// GetScoredIterations retrieves the completed iterations that have metrics, ordered by
+// version. Reports and prompts are left empty, which keeps ranking the whole evolution cheap.
+func (s *EvolutionStore) GetScoredIterations(evolutionID string) ([]*evotypes.Iteration, error) {
+	rows, err := s.db.Query(`
+		SELECT `+scoredIterationColumns+`
+		FROM evolution_iterations
+		WHERE evolution_id = ? AND status = ? AND total_return IS NOT NULL
+		ORDER BY version ASC
+	`, evolutionID, evotypes.IterStatusCompleted)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var iterations []*evotypes.Iteration
+	for rows.Next() {
+		iter, err := s.scanIteration(rows)
+		if err != nil {
+			return nil, err
+		}
+		iterations = append(iterations, iter)
+	}
+	return iterations, rows.Err()
+}
+
+// iterationColumns lists the columns read by scanIteration, in scan order
+const iterationColumns = `id, evolution_id, version, strategy_id, backtest_run_id, status,
+			total_return, max_drawdown, win_rate, sharpe_ratio, trades,
+			evaluation_report, changes_summary, prompt_before, prompt_after, created_at,
+			val_total_return, val_max_drawdown, val_win_rate, val_sharpe_ratio, val_trades,
+			COALESCE(on_pareto_frontier, 0), sortino_ratio, calmar_ratio,
+			max_consecutive_losses, avg_win, avg_loss, expectancy,
+			COALESCE(prompt_tokens, 0), COALESCE(completion_tokens, 0),
+			profit_factor, avg_trade_pnl, improvement_reason, failure_reason,
+			COALESCE(stall_restarts, 0), COALESCE(per_period_sharpe, 0)`
+
+// scoredIterationColumns is iterationColumns with the report and prompt columns nulled out
+const scoredIterationColumns = `id, evolution_id, version, strategy_id, backtest_run_id, status,
+			total_return, max_drawdown, win_rate, sharpe_ratio, trades,
+			NULL, NULL, NULL, NULL, created_at,
+			val_total_return, val_max_drawdown, val_win_rate, val_sharpe_ratio, val_trades,
+			COALESCE(on_pareto_frontier, 0), sortino_ratio, calmar_ratio,
+			max_consecutive_losses, avg_win, avg_loss, expectancy,
+			COALESCE(prompt_tokens, 0), COALESCE(completion_tokens, 0),
+			profit_factor, avg_trade_pnl, NULL, NULL,
+			COALESCE(stall_restarts, 0), COALESCE(per_period_sharpe, 0)`
+
 // scanIteration scans a row into an Iteration struct
 func (s *EvolutionStore) scanIteration(scanner interface {
 	Scan(dest ...interface{}) error
 }) (*evotypes.Iteration, error) {
 	var iter evotypes.Iteration
-	var totalReturn, maxDrawdown, winRate, sharpeRatio sql.NullFloat64
-	var trades sql.NullInt64
+	var totalReturn, maxDrawdown, winRate, sharpeRatio, sortinoRatio, calmarRatio sql.NullFloat64
+	var avgWin, avgLoss, expectancy, profitFactor, avgTradePnL sql.NullFloat64
+	var trades, maxConsecutiveLosses sql.NullInt64
+	var valTotalReturn, valMaxDrawdown, valWinRate, valSharpeRatio sql.NullFloat64
+	var valTrades sql.NullInt64
 	var createdAt string
 	var evalReport, changesSummary, promptBefore, promptAfter sql.NullString
+	var improvementReason, failureReason sql.NullString
+	var perPeriodSharpe bool
 
 	err := scanner.Scan(
 		&iter.ID, &iter.EvolutionID, &iter.Version, &iter.StrategyID,
@@ -272,6 +511,12 @@ func (s *EvolutionStore) scanIteration(scanner interface {
 		&totalReturn, &maxDrawdown, &winRate, &sharpeRatio, &trades,
 		&evalReport, &changesSummary,
 		&promptBefore, &promptAfter, &createdAt,
+		&valTotalReturn, &valMaxDrawdown, &valWinRate, &valSharpeRatio, &valTrades,
+		&iter.OnParetoFrontier, &sortinoRatio, &calmarRatio,
+		&maxConsecutiveLosses, &avgWin, &avgLoss, &expectancy,
+		&iter.PromptTokens, &iter.CompletionTokens,
+		&profitFactor, &avgTradePnL, &improvementReason, &failureReason,
+		&iter.StallRestarts, &perPeriodSharpe,
 	)
 	if err != nil {
 		return nil, err
@@ -282,15 +527,36 @@ func (s *EvolutionStore) scanIteration(scanner interface {
 	iter.ChangesSummary = changesSummary.String
 	iter.PromptBefore = promptBefore.String
 	iter.PromptAfter = promptAfter.String
+	iter.ImprovementReason = improvementReason.String
+	iter.FailureReason = failureReason.String
 
 	// Parse metrics if available
 	if totalReturn.Valid {
 		iter.Metrics = &evotypes.Metrics{
-			TotalReturn: totalReturn.Float64,
-			MaxDrawdown: maxDrawdown.Float64,
-			WinRate:     winRate.Float64,
-			SharpeRatio: sharpeRatio.Float64,
-			Trades:      int(trades.Int64),
+			TotalReturn:  totalReturn.Float64,
+			MaxDrawdown:  maxDrawdown.Float64,
+			WinRate:      winRate.Float64,
+			SharpeRatio:  sharpeRatio.Float64,
+			SortinoRatio: sortinoRatio.Float64,
+			CalmarRatio:  calmarRatio.Float64,
+			Trades:       int(trades.Int64),
+
+			MaxConsecutiveLosses: int(maxConsecutiveLosses.Int64),
+			AvgWin:               avgWin.Float64,
+			AvgLoss:              avgLoss.Float64,
+			Expectancy:           expectancy.Float64,
+			ProfitFactor:         profitFactor.Float64,
+			AvgTradePnL:          avgTradePnL.Float64,
+			PerPeriodSharpe:      perPeriodSharpe,
+		}
+	}
+	if valTotalReturn.Valid {
+		iter.ValidationMetrics = &evotypes.Metrics{
+			TotalReturn: valTotalReturn.Float64,
+			MaxDrawdown: valMaxDrawdown.Float64,
+			WinRate:     valWinRate.Float64,
+			SharpeRatio: valSharpeRatio.Float64,
+			Trades:      int(valTrades.Int64),
 		}
 	}
 
@@ -302,9 +568,7 @@ func (s *EvolutionStore) scanIteration(scanner interface {
 // GetIteration retrieves a specific iteration by version
 func (s *EvolutionStore) GetIteration(evolutionID string, version int) (*evotypes.Iteration, error) {
 	row := s.db.QueryRow(`
-		SELECT id, evolution_id, version, strategy_id, backtest_run_id, status,
-			total_return, max_drawdown, win_rate, sharpe_ratio, trades,
-			evaluation_report, changes_summary, prompt_before, prompt_after, created_at
+		SELECT `+iterationColumns+`
 		FROM evolution_iterations
 		WHERE evolution_id = ? AND version = ?
 	`, evolutionID, version)
@@ -312,6 +576,176 @@ func (s *EvolutionStore) GetIteration(evolutionID string, version int) (*evotype
 	return s.scanIteration(row)
 }
 
+// GetIterationComparison compares two iterations of an evolution: their metrics, the metric
+// deltas (B minus A) and the strategy config fields that differ between the prompts they were
+// backtested with. It returns sql.ErrNoRows if either iteration does not exist.
+func (s *EvolutionStore) GetIterationComparison(evolutionID string, versionA, versionB int) (*evotypes.IterationComparison, error) {
+	a, err := s.GetIteration(evolutionID, versionA)
+	if err != nil {
+		return nil, err
+	}
+	b, err := s.GetIteration(evolutionID, versionB)
+	if err != nil {
+		return nil, err
+	}
+
+	comparison := &evotypes.IterationComparison{
+		A:          a,
+		B:          b,
+		PromptDiff: ComputePromptDiff(a.PromptBefore, b.PromptBefore),
+	}
+	if a.Metrics != nil && b.Metrics != nil {
+		comparison.Delta = &evotypes.MetricsDelta{
+			TotalReturn: b.Metrics.TotalReturn - a.Metrics.TotalReturn,
+			MaxDrawdown: b.Metrics.MaxDrawdown - a.Metrics.MaxDrawdown,
+			WinRate:     b.Metrics.WinRate - a.Metrics.WinRate,
+			SharpeRatio: b.Metrics.SharpeRatio - a.Metrics.SharpeRatio,
+			Trades:      b.Metrics.Trades - a.Metrics.Trades,
+		}
+	}
+	return comparison, nil
+}
+
+// ComputePromptDiff lists the config fields that differ between two strategy prompts, sorted
+// by path, with one "path: before → after" line per field in Changes. Prompts that are not
+// both JSON objects fall back to a line-based diff.
+func ComputePromptDiff(before, after string) *evotypes.PromptDiff {
+	diff := &evotypes.PromptDiff{Before: before, After: after, Changes: []string{}, Format: "json"}
+
+	var beforeCfg, afterCfg map[string]interface{}
+	if json.Unmarshal([]byte(before), &beforeCfg) != nil || json.Unmarshal([]byte(after), &afterCfg) != nil {
+		diff.Format = "text"
+		diff.Lines = diffPromptLines(before, after)
+		for _, line := range diff.Lines {
+			diff.Changes = append(diff.Changes, fmt.Sprintf("%s %s", line.Op, line.Text))
+		}
+		return diff
+	}
+
+	beforeFields := make(map[string]string)
+	afterFields := make(map[string]string)
+	flattenJSON("", beforeCfg, beforeFields)
+	flattenJSON("", afterCfg, afterFields)
+
+	paths := make([]string, 0, len(beforeFields)+len(afterFields))
+	for path := range beforeFields {
+		paths = append(paths, path)
+	}
+	for path := range afterFields {
+		if _, ok := beforeFields[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		b, inBefore := beforeFields[path]
+		a, inAfter := afterFields[path]
+		if inBefore && inAfter && a == b {
+			continue
+		}
+		change := evotypes.PromptFieldChange{Path: path, Kind: "changed", Old: b, New: a}
+		if !inBefore {
+			b = "(unset)"
+			change.Kind = "added"
+		}
+		if !inAfter {
+			a = "(unset)"
+			change.Kind = "removed"
+		}
+		diff.Fields = append(diff.Fields, change)
+		diff.Changes = append(diff.Changes, fmt.Sprintf("%s: %s → %s", path, b, a))
+	}
+	return diff
+}
+
+// diffPromptLines returns the removed and added lines between two texts based on their
+// longest common subsequence of lines, in document order
+func diffPromptLines(before, after string) []evotypes.PromptLineChange {
+	if before == after {
+		return nil
+	}
+	a, b := strings.Split(before, "\n"), strings.Split(after, "\n")
+
+	// lcs[i][j] = length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var changes []evotypes.PromptLineChange
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			i++
+			j++
+		case j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+			changes = append(changes, evotypes.PromptLineChange{Op: "-", Line: i + 1, Text: a[i]})
+			i++
+		default:
+			changes = append(changes, evotypes.PromptLineChange{Op: "+", Line: j + 1, Text: b[j]})
+			j++
+		}
+	}
+	return changes
+}
+
+// flattenJSON collects the leaf values of a decoded JSON object keyed by their dotted path.
+// Arrays are compared as a whole.
+func flattenJSON(prefix string, value map[string]interface{}, out map[string]string) {
+	for key, v := range value {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+		if nested, ok := v.(map[string]interface{}); ok {
+			flattenJSON(path, nested, out)
+			continue
+		}
+		encoded, _ := json.Marshal(v)
+		out[path] = string(encoded)
+	}
+}
+
+// GetIterationDetail retrieves an iteration together with its parsed evaluation report, the
+// diff between the prompt it was backtested with and the optimized prompt, its sampled
+// decisions and its equity curve. It returns sql.ErrNoRows if the iteration does not exist.
+func (s *EvolutionStore) GetIterationDetail(evolutionID string, version int) (*evotypes.IterationDetail, error) {
+	iter, err := s.GetIteration(evolutionID, version)
+	if err != nil {
+		return nil, err
+	}
+
+	detail := &evotypes.IterationDetail{Iteration: *iter}
+	if iter.EvalReport != "" {
+		var report evotypes.EvaluationReport
+		if err := json.Unmarshal([]byte(iter.EvalReport), &report); err == nil {
+			detail.EvaluationReportParsed = &report
+		}
+	}
+	// The optimized prompt is only known once the iteration has been optimized
+	if iter.PromptAfter != "" {
+		detail.PromptDiff = ComputePromptDiff(iter.PromptBefore, iter.PromptAfter)
+	}
+	if detail.DecisionSamples, err = s.GetIterationDecisionSamples(evolutionID, version); err != nil {
+		return nil, err
+	}
+	if detail.EquityCurve, err = s.GetIterationEquityCurve(evolutionID, version); err != nil {
+		return nil, err
+	}
+	return detail, nil
+}
+
 // UpdateIterationStatus updates the status of an iteration
 func (s *EvolutionStore) UpdateIterationStatus(evolutionID string, version int, status string) error {
 	_, err := s.db.Exec(`
@@ -326,13 +760,52 @@ func (s *EvolutionStore) UpdateIterationStatus(evolutionID string, version int,
 func (s *EvolutionStore) UpdateIterationMetrics(evolutionID string, version int, metrics *evotypes.Metrics) error {
 	_, err := s.db.Exec(`
 		UPDATE evolution_iterations
-		SET total_return = ?, max_drawdown = ?, win_rate = ?, sharpe_ratio = ?, trades = ?
+		SET total_return = ?, max_drawdown = ?, win_rate = ?, sharpe_ratio = ?, trades = ?,
+			sortino_ratio = ?, calmar_ratio = ?,
+			max_consecutive_losses = ?, avg_win = ?, avg_loss = ?, expectancy = ?,
+			profit_factor = ?, avg_trade_pnl = ?, per_period_sharpe = ?
 		WHERE evolution_id = ? AND version = ?
 	`, metrics.TotalReturn, metrics.MaxDrawdown, metrics.WinRate, metrics.SharpeRatio, metrics.Trades,
+		metrics.SortinoRatio, metrics.CalmarRatio,
+		metrics.MaxConsecutiveLosses, metrics.AvgWin, metrics.AvgLoss, metrics.Expectancy,
+		metrics.ProfitFactor, metrics.AvgTradePnL, metrics.PerPeriodSharpe,
 		evolutionID, version)
 	return err
 }
 
+// UpdateIterationValidationMetrics updates the out-of-sample validation metrics of an iteration
+func (s *EvolutionStore) UpdateIterationValidationMetrics(evolutionID string, version int, metrics *evotypes.Metrics) error {
+	_, err := s.db.Exec(`
+		UPDATE evolution_iterations
+		SET val_total_return = ?, val_max_drawdown = ?, val_win_rate = ?, val_sharpe_ratio = ?, val_trades = ?
+		WHERE evolution_id = ? AND version = ?
+	`, metrics.TotalReturn, metrics.MaxDrawdown, metrics.WinRate, metrics.SharpeRatio, metrics.Trades,
+		evolutionID, version)
+	return err
+}
+
+// UpdateParetoFrontier marks exactly the given versions as members of the Pareto frontier
+func (s *EvolutionStore) UpdateParetoFrontier(evolutionID string, versions []int) error {
+	return withTx(s.db, func(tx *sql.Tx) error {
+		// first clear the flag on all iterations of the evolution
+		_, err := tx.Exec(`UPDATE evolution_iterations SET on_pareto_frontier = 0 WHERE evolution_id = ?`, evolutionID)
+		if err != nil {
+			return err
+		}
+
+		for _, version := range versions {
+			_, err = tx.Exec(`
+				UPDATE evolution_iterations SET on_pareto_frontier = 1
+				WHERE evolution_id = ? AND version = ?
+			`, evolutionID, version)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
 // UpdateIterationEvaluation updates the evaluation report of an iteration
 func (s *EvolutionStore) UpdateIterationEvaluation(evolutionID string, version int, evalReport, changesSummary string) error {
 	_, err := s.db.Exec(`
@@ -355,27 +828,290 @@ func (s *EvolutionStore) UpdateIterationPrompts(evolutionID string, version int,
 
 // UpdateIterationComplete updates all fields when iteration completes
 func (s *EvolutionStore) UpdateIterationComplete(evolutionID string, version int, metrics *evotypes.Metrics, evalReport, changesSummary, promptAfter string) error {
+	return retryOnBusy(func() error {
+		_, err := s.db.Exec(`
+			UPDATE evolution_iterations
+			SET status = 'completed',
+				total_return = ?, max_drawdown = ?, win_rate = ?, sharpe_ratio = ?, trades = ?,
+				sortino_ratio = ?, calmar_ratio = ?,
+				max_consecutive_losses = ?, avg_win = ?, avg_loss = ?, expectancy = ?,
+				profit_factor = ?, avg_trade_pnl = ?, per_period_sharpe = ?,
+				evaluation_report = ?, changes_summary = ?, prompt_after = ?
+			WHERE evolution_id = ? AND version = ?
+		`, metrics.TotalReturn, metrics.MaxDrawdown, metrics.WinRate, metrics.SharpeRatio, metrics.Trades,
+			metrics.SortinoRatio, metrics.CalmarRatio,
+			metrics.MaxConsecutiveLosses, metrics.AvgWin, metrics.AvgLoss, metrics.Expectancy,
+			metrics.ProfitFactor, metrics.AvgTradePnL, metrics.PerPeriodSharpe,
+			evalReport, changesSummary, promptAfter, evolutionID, version)
+		return err
+	})
+}
+
+// UpdateIterationOutcome records why an iteration was or was not promoted to best.
+// Exactly one of the reasons is expected to be set; the other is cleared.
+func (s *EvolutionStore) UpdateIterationOutcome(evolutionID string, version int, improvementReason, failureReason string) error {
+	return retryOnBusy(func() error {
+		_, err := s.db.Exec(`
+			UPDATE evolution_iterations
+			SET improvement_reason = ?, failure_reason = ?
+			WHERE evolution_id = ? AND version = ?
+		`, improvementReason, failureReason, evolutionID, version)
+		return err
+	})
+}
+
+// AddIterationTokenUsage adds AI token usage to an iteration, so retried attempts accumulate
+func (s *EvolutionStore) AddIterationTokenUsage(evolutionID string, version int, promptTokens, completionTokens int64) error {
 	_, err := s.db.Exec(`
 		UPDATE evolution_iterations
-		SET status = 'completed',
-			total_return = ?, max_drawdown = ?, win_rate = ?, sharpe_ratio = ?, trades = ?,
-			evaluation_report = ?, changes_summary = ?, prompt_after = ?
+		SET prompt_tokens = COALESCE(prompt_tokens, 0) + ?,
+			completion_tokens = COALESCE(completion_tokens, 0) + ?
 		WHERE evolution_id = ? AND version = ?
-	`, metrics.TotalReturn, metrics.MaxDrawdown, metrics.WinRate, metrics.SharpeRatio, metrics.Trades,
-		evalReport, changesSummary, promptAfter, evolutionID, version)
+	`, promptTokens, completionTokens, evolutionID, version)
 	return err
 }
 
-// Delete removes an evolution and its iterations from the database
+// UpdateIterationStallRestarts records how often the iteration's backtest was restarted after stalling
+func (s *EvolutionStore) UpdateIterationStallRestarts(evolutionID string, version int, restarts int) error {
+	_, err := s.db.Exec(`
+		UPDATE evolution_iterations SET stall_restarts = ?
+		WHERE evolution_id = ? AND version = ?
+	`, restarts, evolutionID, version)
+	return err
+}
+
+// UpdateIterationEquityCurve stores the (already downsampled) equity curve of an iteration
+func (s *EvolutionStore) UpdateIterationEquityCurve(evolutionID string, version int, points []evotypes.EquityPoint) error {
+	data, err := json.Marshal(points)
+	if err != nil {
+		return fmt.Errorf("marshal equity curve: %w", err)
+	}
+	_, err = s.db.Exec(`
+		UPDATE evolution_iterations
+		SET equity_curve = ?
+		WHERE evolution_id = ? AND version = ?
+	`, string(data), evolutionID, version)
+	return err
+}
+
+// GetIterationEquityCurve retrieves the stored equity curve of an iteration
+func (s *EvolutionStore) GetIterationEquityCurve(evolutionID string, version int) ([]evotypes.EquityPoint, error) {
+	var data sql.NullString
+	err := s.db.QueryRow(`
+		SELECT equity_curve FROM evolution_iterations
+		WHERE evolution_id = ? AND version = ?
+	`, evolutionID, version).Scan(&data)
+	if err != nil {
+		return nil, err
+	}
+
+	points := []evotypes.EquityPoint{}
+	if data.String == "" {
+		return points, nil
+	}
+	if err := json.Unmarshal([]byte(data.String), &points); err != nil {
+		return nil, fmt.Errorf("unmarshal equity curve: %w", err)
+	}
+	return points, nil
+}
+
+// UpdateIterationDecisionSamples stores the sampled trading decisions of an iteration
+func (s *EvolutionStore) UpdateIterationDecisionSamples(evolutionID string, version int, samples []evotypes.DecisionSample) error {
+	data, err := json.Marshal(samples)
+	if err != nil {
+		return fmt.Errorf("marshal decision samples: %w", err)
+	}
+	_, err = s.db.Exec(`
+		UPDATE evolution_iterations
+		SET decision_samples = ?
+		WHERE evolution_id = ? AND version = ?
+	`, string(data), evolutionID, version)
+	return err
+}
+
+// GetIterationDecisionSamples retrieves the sampled trading decisions of an iteration
+func (s *EvolutionStore) GetIterationDecisionSamples(evolutionID string, version int) ([]evotypes.DecisionSample, error) {
+	var data sql.NullString
+	err := s.db.QueryRow(`
+		SELECT decision_samples FROM evolution_iterations
+		WHERE evolution_id = ? AND version = ?
+	`, evolutionID, version).Scan(&data)
+	if err != nil {
+		return nil, err
+	}
+
+	samples := []evotypes.DecisionSample{}
+	if data.String == "" {
+		return samples, nil
+	}
+	if err := json.Unmarshal([]byte(data.String), &samples); err != nil {
+		return nil, fmt.Errorf("unmarshal decision samples: %w", err)
+	}
+	return samples, nil
+}
+
+// SaveIterationCheckpoint stores (or replaces) the evaluation checkpoint of an iteration
+func (s *EvolutionStore) SaveIterationCheckpoint(cp *evotypes.IterationCheckpoint) error {
+	_, err := s.db.Exec(`
+		INSERT OR REPLACE INTO evolution_checkpoints (
+			evolution_id, version, backtest_run_id, evaluation_report, metrics, trades
+		) VALUES (?, ?, ?, ?, ?, ?)
+	`, cp.EvolutionID, cp.Version, cp.BacktestRunID, cp.EvalReport, cp.MetricsJSON, cp.TradesJSON)
+	return err
+}
+
+// GetIterationCheckpoint retrieves the evaluation checkpoint of an iteration
+func (s *EvolutionStore) GetIterationCheckpoint(evolutionID string, version int) (*evotypes.IterationCheckpoint, error) {
+	var cp evotypes.IterationCheckpoint
+	var evalReport, metrics, trades sql.NullString
+	var createdAt string
+
+	err := s.db.QueryRow(`
+		SELECT evolution_id, version, backtest_run_id, evaluation_report, metrics, trades, created_at
+		FROM evolution_checkpoints
+		WHERE evolution_id = ? AND version = ?
+	`, evolutionID, version).Scan(
+		&cp.EvolutionID, &cp.Version, &cp.BacktestRunID, &evalReport, &metrics, &trades, &createdAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	cp.EvalReport = evalReport.String
+	cp.MetricsJSON = metrics.String
+	cp.TradesJSON = trades.String
+	cp.CreatedAt, _ = time.Parse("2006-01-02 15:04:05", createdAt)
+	return &cp, nil
+}
+
+// DeleteIterationCheckpoint removes the evaluation checkpoint of an iteration
+func (s *EvolutionStore) DeleteIterationCheckpoint(evolutionID string, version int) error {
+	_, err := s.db.Exec(`
+		DELETE FROM evolution_checkpoints WHERE evolution_id = ? AND version = ?
+	`, evolutionID, version)
+	return err
+}
+
+// SavePopulation stores the members of a population generation, replacing any earlier record of it
+func (s *EvolutionStore) SavePopulation(evolutionID string, generation int, members []*evotypes.PopulationMember) error {
+	return withTx(s.db, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`DELETE FROM evolution_population WHERE evolution_id = ? AND generation = ?`, evolutionID, generation)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range members {
+			_, err = tx.Exec(`
+				INSERT INTO evolution_population (evolution_id, generation, version, rank, elite)
+				VALUES (?, ?, ?, ?, ?)
+			`, evolutionID, generation, m.Version, m.Rank, m.Elite)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetPopulation retrieves the members of a population generation, fittest first
+func (s *EvolutionStore) GetPopulation(evolutionID string, generation int) ([]*evotypes.PopulationMember, error) {
+	rows, err := s.db.Query(`
+		SELECT evolution_id, generation, version, rank, elite, created_at
+		FROM evolution_population
+		WHERE evolution_id = ? AND generation = ?
+		ORDER BY rank
+	`, evolutionID, generation)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var members []*evotypes.PopulationMember
+	for rows.Next() {
+		var m evotypes.PopulationMember
+		var createdAt string
+		if err := rows.Scan(&m.EvolutionID, &m.Generation, &m.Version, &m.Rank, &m.Elite, &createdAt); err != nil {
+			return nil, err
+		}
+		m.CreatedAt, _ = time.Parse("2006-01-02 15:04:05", createdAt)
+		members = append(members, &m)
+	}
+	return members, rows.Err()
+}
+
+// GetLatestPopulationGeneration returns the last recorded population generation, 0 if there is none
+func (s *EvolutionStore) GetLatestPopulationGeneration(evolutionID string) (int, error) {
+	var generation sql.NullInt64
+	err := s.db.QueryRow(`
+		SELECT MAX(generation) FROM evolution_population WHERE evolution_id = ?
+	`, evolutionID).Scan(&generation)
+	if err != nil {
+		return 0, err
+	}
+	return int(generation.Int64), nil
+}
+
+// Delete soft-deletes an evolution: it is hidden from Get and List but keeps its iterations
+// until PurgeDeleted removes it, so it can be restored
 func (s *EvolutionStore) Delete(evolutionID string) error {
-	// Delete iterations first
-	_, err := s.db.Exec(`DELETE FROM evolution_iterations WHERE evolution_id = ?`, evolutionID)
+	_, err := s.db.Exec(`
+		UPDATE evolutions SET deleted_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND deleted_at IS NULL
+	`, evolutionID)
+	return err
+}
+
+// Restore undoes a soft delete. It returns sql.ErrNoRows if the user has no such deleted evolution.
+func (s *EvolutionStore) Restore(userID, evolutionID string) error {
+	result, err := s.db.Exec(`
+		UPDATE evolutions SET deleted_at = NULL
+		WHERE id = ? AND user_id = ? AND deleted_at IS NOT NULL
+	`, evolutionID, userID)
 	if err != nil {
 		return err
 	}
-	// Delete evolution
-	_, err = s.db.Exec(`DELETE FROM evolutions WHERE id = ?`, evolutionID)
-	return err
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// PurgeDeleted permanently removes evolutions soft-deleted before olderThan, together with
+// their iterations, checkpoints and population records. It returns the number of evolutions removed.
+//
+// The iterations' foreign key has no ON DELETE CASCADE, and SQLite cannot add one to an existing
+// table, so the children are deleted explicitly within the same transaction: a failure part-way
+// rolls everything back instead of leaving orphaned iterations.
+func (s *EvolutionStore) PurgeDeleted(olderThan time.Time) (int64, error) {
+	cutoff := olderThan.UTC().Format("2006-01-02 15:04:05")
+
+	var purged int64
+	err := withTx(s.db, func(tx *sql.Tx) error {
+		const deleted = `SELECT id FROM evolutions WHERE deleted_at IS NOT NULL AND deleted_at < ?`
+		if _, err := tx.Exec(`DELETE FROM evolution_checkpoints WHERE evolution_id IN (`+deleted+`)`, cutoff); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`DELETE FROM evolution_population WHERE evolution_id IN (`+deleted+`)`, cutoff); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`DELETE FROM evolution_iterations WHERE evolution_id IN (`+deleted+`)`, cutoff); err != nil {
+			return err
+		}
+		result, err := tx.Exec(`DELETE FROM evolutions WHERE deleted_at IS NOT NULL AND deleted_at < ?`, cutoff)
+		if err != nil {
+			return err
+		}
+		purged, err = result.RowsAffected()
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return purged, nil
 }
 
 // ResetRunningToPaused resets all running evolutions to paused state
@@ -384,10 +1120,373 @@ func (s *EvolutionStore) ResetRunningToPaused() (int64, error) {
 	result, err := s.db.Exec(`
 		UPDATE evolutions
 		SET status = 'paused', updated_at = CURRENT_TIMESTAMP
-		WHERE status = 'running'
+		WHERE status = 'running' AND deleted_at IS NULL
 	`)
 	if err != nil {
 		return 0, err
 	}
 	return result.RowsAffected()
 }
+
+// EvolutionBundle is a self-contained export of an evolution: the evolution row, all of its
+// iterations with their equity curves and decision samples, the population records and the
+// strategies they reference, so the lineage can be imported elsewhere
+type EvolutionBundle struct {
+	FormatVersion   int                               `json:"format_version"`
+	ExportedAt      time.Time                         `json:"exported_at"`
+	Evolution       *evotypes.Evolution               `json:"evolution"`
+	Iterations      []*evotypes.Iteration             `json:"iterations"`
+	EquityCurves    map[int][]evotypes.EquityPoint    `json:"equity_curves,omitempty"`    // By iteration version
+	DecisionSamples map[int][]evotypes.DecisionSample `json:"decision_samples,omitempty"` // By iteration version
+	Population      []*evotypes.PopulationMember      `json:"population,omitempty"`       // All generations
+	Strategies      []*Strategy                       `json:"strategies"`
+}
+
+// evolutionBundleFormat is the current EvolutionBundle format version. Format 2 added the
+// equity curves, decision samples and population; format 1 bundles still import without them.
+const evolutionBundleFormat = 2
+
+// ExportEvolution builds an EvolutionBundle of an evolution. Referenced strategies that no
+// longer exist are left out of the bundle.
+func (s *EvolutionStore) ExportEvolution(evolutionID string) (*EvolutionBundle, error) {
+	var userID string
+	err := s.db.QueryRow(`
+		SELECT user_id FROM evolutions WHERE id = ? AND deleted_at IS NULL
+	`, evolutionID).Scan(&userID)
+	if err != nil {
+		return nil, err
+	}
+	evo, err := s.Get(userID, evolutionID)
+	if err != nil {
+		return nil, err
+	}
+	iterations, err := s.GetIterations(evolutionID)
+	if err != nil {
+		return nil, fmt.Errorf("get iterations: %w", err)
+	}
+
+	bundle := &EvolutionBundle{
+		FormatVersion: evolutionBundleFormat,
+		ExportedAt:    time.Now().UTC(),
+		Evolution:     evo,
+		Iterations:    iterations,
+		Strategies:    []*Strategy{},
+	}
+	if bundle.Iterations == nil {
+		bundle.Iterations = []*evotypes.Iteration{}
+	}
+	if err := s.exportIterationArtifacts(bundle); err != nil {
+		return nil, err
+	}
+	if bundle.Population, err = s.getAllPopulation(evolutionID); err != nil {
+		return nil, fmt.Errorf("get population: %w", err)
+	}
+
+	strategies := &StrategyStore{db: s.db}
+	seen := make(map[string]bool)
+	strategyIDs := []string{evo.BaseStrategyID}
+	for _, iter := range iterations {
+		strategyIDs = append(strategyIDs, iter.StrategyID)
+	}
+	for _, id := range strategyIDs {
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		st, err := strategies.Get(userID, id)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("get strategy %s: %w", id, err)
+		}
+		bundle.Strategies = append(bundle.Strategies, st)
+	}
+	return bundle, nil
+}
+
+// exportIterationArtifacts adds the stored equity curves and decision samples of the
+// bundle's evolution, keyed by iteration version
+func (s *EvolutionStore) exportIterationArtifacts(bundle *EvolutionBundle) error {
+	rows, err := s.db.Query(`
+		SELECT version, equity_curve, decision_samples FROM evolution_iterations
+		WHERE evolution_id = ?
+	`, bundle.Evolution.ID)
+	if err != nil {
+		return fmt.Errorf("get iteration artifacts: %w", err)
+	}
+	defer rows.Close()
+
+	bundle.EquityCurves = make(map[int][]evotypes.EquityPoint)
+	bundle.DecisionSamples = make(map[int][]evotypes.DecisionSample)
+	for rows.Next() {
+		var version int
+		var curve, samples sql.NullString
+		if err := rows.Scan(&version, &curve, &samples); err != nil {
+			return err
+		}
+		if curve.String != "" {
+			var points []evotypes.EquityPoint
+			if err := json.Unmarshal([]byte(curve.String), &points); err != nil {
+				return fmt.Errorf("unmarshal equity curve of v%d: %w", version, err)
+			}
+			bundle.EquityCurves[version] = points
+		}
+		if samples.String != "" {
+			var decoded []evotypes.DecisionSample
+			if err := json.Unmarshal([]byte(samples.String), &decoded); err != nil {
+				return fmt.Errorf("unmarshal decision samples of v%d: %w", version, err)
+			}
+			bundle.DecisionSamples[version] = decoded
+		}
+	}
+	return rows.Err()
+}
+
+// getAllPopulation retrieves the population records of every generation, oldest generation first
+func (s *EvolutionStore) getAllPopulation(evolutionID string) ([]*evotypes.PopulationMember, error) {
+	rows, err := s.db.Query(`
+		SELECT evolution_id, generation, version, rank, elite, created_at
+		FROM evolution_population
+		WHERE evolution_id = ?
+		ORDER BY generation, rank
+	`, evolutionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var members []*evotypes.PopulationMember
+	for rows.Next() {
+		var m evotypes.PopulationMember
+		var createdAt string
+		if err := rows.Scan(&m.EvolutionID, &m.Generation, &m.Version, &m.Rank, &m.Elite, &createdAt); err != nil {
+			return nil, err
+		}
+		m.CreatedAt, _ = time.Parse("2006-01-02 15:04:05", createdAt)
+		members = append(members, &m)
+	}
+	return members, rows.Err()
+}
+
+// ImportEvolution recreates a bundled evolution for userID with fresh evolution and strategy
+// IDs, all within one transaction. Iterations are renumbered 1..n in version order so duplicate
+// versions in the bundle cannot collide, and the best-version pointer is remapped to match.
+// Equity curves, decision samples and population records follow the first iteration of
+// their version. A running evolution is imported as paused.
+func (s *EvolutionStore) ImportEvolution(userID string, bundle *EvolutionBundle) (*evotypes.Evolution, error) {
+	if bundle == nil || bundle.Evolution == nil {
+		return nil, fmt.Errorf("%w: bundle has no evolution", ErrInvalidEvolution)
+	}
+	if bundle.FormatVersion > evolutionBundleFormat {
+		return nil, fmt.Errorf("%w: unsupported bundle format version %d", ErrInvalidEvolution, bundle.FormatVersion)
+	}
+
+	strategyIDs := make(map[string]string, len(bundle.Strategies))
+	for _, st := range bundle.Strategies {
+		if st != nil && st.ID != "" {
+			strategyIDs[st.ID] = uuid.New().String()
+		}
+	}
+	remapStrategy := func(id string) string {
+		if newID, ok := strategyIDs[id]; ok {
+			return newID
+		}
+		return id
+	}
+
+	iterations := make([]*evotypes.Iteration, 0, len(bundle.Iterations))
+	for _, iter := range bundle.Iterations {
+		if iter != nil {
+			iterations = append(iterations, iter)
+		}
+	}
+	sort.SliceStable(iterations, func(i, j int) bool {
+		return iterations[i].Version < iterations[j].Version
+	})
+	versions := make(map[int]int, len(iterations))
+	for i, iter := range iterations {
+		if _, ok := versions[iter.Version]; !ok {
+			versions[iter.Version] = i + 1
+		}
+	}
+
+	src := bundle.Evolution
+	if err := validateEvolutionConfig(src.Config); err != nil {
+		return nil, err
+	}
+	evo := *src
+	evo.ID = uuid.New().String()
+	evo.UserID = userID
+	evo.BaseStrategyID = remapStrategy(src.BaseStrategyID)
+	evo.BestVersion = versions[src.BestVersion]
+	evo.CurrentIteration = len(iterations)
+	evo.Iterations = nil
+	if evo.Status == evotypes.StatusRunning {
+		evo.Status = evotypes.StatusPaused
+	}
+
+	// The config repeats the owner and base strategy, keep them in line with the new row
+	var cfg map[string]interface{}
+	if err := json.Unmarshal([]byte(src.Config), &cfg); err == nil {
+		cfg["user_id"] = userID
+		if base, ok := cfg["base_strategy_id"].(string); ok {
+			cfg["base_strategy_id"] = remapStrategy(base)
+		}
+		if data, err := json.Marshal(cfg); err == nil {
+			evo.Config = string(data)
+		}
+	}
+
+	err := withTx(s.db, func(tx *sql.Tx) error {
+		for _, st := range bundle.Strategies {
+			if st == nil || st.ID == "" {
+				continue
+			}
+			_, err := tx.Exec(`
+				INSERT INTO strategies (id, user_id, name, description, is_active, is_default, config)
+				VALUES (?, ?, ?, ?, 0, 0, ?)
+			`, strategyIDs[st.ID], userID, st.Name, st.Description, st.Config)
+			if err != nil {
+				return fmt.Errorf("insert strategy %s: %w", st.ID, err)
+			}
+		}
+
+		_, err := tx.Exec(`
+			INSERT INTO evolutions (id, user_id, name, base_strategy_id, status,
+				current_iteration, max_iterations, convergence_threshold,
+				best_version, best_return, best_drawdown, config)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, evo.ID, evo.UserID, evo.Name, evo.BaseStrategyID, evo.Status,
+			evo.CurrentIteration, evo.MaxIterations, evo.ConvergenceThreshold,
+			evo.BestVersion, evo.BestReturn, evo.BestDrawdown, evo.Config)
+		if err != nil {
+			return fmt.Errorf("insert evolution: %w", err)
+		}
+
+		for i, iter := range iterations {
+			imported := *iter
+			imported.EvolutionID = evo.ID
+			imported.Version = i + 1
+			imported.StrategyID = remapStrategy(iter.StrategyID)
+			if err := insertImportedIteration(tx, &imported); err != nil {
+				return fmt.Errorf("insert iteration %d: %w", iter.Version, err)
+			}
+			if versions[iter.Version] != imported.Version {
+				continue // artifacts belong to the first iteration of a duplicated version
+			}
+			if err := insertImportedArtifacts(tx, bundle, iter.Version, &imported); err != nil {
+				return fmt.Errorf("insert artifacts of iteration %d: %w", iter.Version, err)
+			}
+		}
+
+		for _, m := range bundle.Population {
+			if m == nil {
+				continue
+			}
+			version, ok := versions[m.Version]
+			if !ok {
+				continue
+			}
+			_, err := tx.Exec(`
+				INSERT OR REPLACE INTO evolution_population (evolution_id, generation, version, rank, elite)
+				VALUES (?, ?, ?, ?, ?)
+			`, evo.ID, m.Generation, version, m.Rank, m.Elite)
+			if err != nil {
+				return fmt.Errorf("insert population member %d of generation %d: %w", m.Version, m.Generation, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.Get(userID, evo.ID)
+}
+
+// insertImportedArtifacts stores the bundled equity curve and decision samples of the
+// iteration with the given bundle version
+func insertImportedArtifacts(tx *sql.Tx, bundle *EvolutionBundle, bundleVersion int, iter *evotypes.Iteration) error {
+	if points, ok := bundle.EquityCurves[bundleVersion]; ok {
+		data, err := json.Marshal(points)
+		if err != nil {
+			return fmt.Errorf("marshal equity curve: %w", err)
+		}
+		_, err = tx.Exec(`
+			UPDATE evolution_iterations SET equity_curve = ?
+			WHERE evolution_id = ? AND version = ?
+		`, string(data), iter.EvolutionID, iter.Version)
+		if err != nil {
+			return err
+		}
+	}
+	if samples, ok := bundle.DecisionSamples[bundleVersion]; ok {
+		data, err := json.Marshal(samples)
+		if err != nil {
+			return fmt.Errorf("marshal decision samples: %w", err)
+		}
+		_, err = tx.Exec(`
+			UPDATE evolution_iterations SET decision_samples = ?
+			WHERE evolution_id = ? AND version = ?
+		`, string(data), iter.EvolutionID, iter.Version)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// insertImportedIteration inserts an iteration with all of its metrics
+func insertImportedIteration(tx *sql.Tx, iter *evotypes.Iteration) error {
+	var totalReturn, maxDrawdown, winRate, sharpeRatio, sortinoRatio, calmarRatio sql.NullFloat64
+	var avgWin, avgLoss, expectancy, profitFactor, avgTradePnL sql.NullFloat64
+	var trades, maxConsecutiveLosses sql.NullInt64
+	var perPeriodSharpe bool
+	if m := iter.Metrics; m != nil {
+		totalReturn = sql.NullFloat64{Float64: m.TotalReturn, Valid: true}
+		maxDrawdown = sql.NullFloat64{Float64: m.MaxDrawdown, Valid: true}
+		winRate = sql.NullFloat64{Float64: m.WinRate, Valid: true}
+		sharpeRatio = sql.NullFloat64{Float64: m.SharpeRatio, Valid: true}
+		sortinoRatio = sql.NullFloat64{Float64: m.SortinoRatio, Valid: true}
+		calmarRatio = sql.NullFloat64{Float64: m.CalmarRatio, Valid: true}
+		trades = sql.NullInt64{Int64: int64(m.Trades), Valid: true}
+		maxConsecutiveLosses = sql.NullInt64{Int64: int64(m.MaxConsecutiveLosses), Valid: true}
+		avgWin = sql.NullFloat64{Float64: m.AvgWin, Valid: true}
+		avgLoss = sql.NullFloat64{Float64: m.AvgLoss, Valid: true}
+		expectancy = sql.NullFloat64{Float64: m.Expectancy, Valid: true}
+		profitFactor = sql.NullFloat64{Float64: m.ProfitFactor, Valid: true}
+		avgTradePnL = sql.NullFloat64{Float64: m.AvgTradePnL, Valid: true}
+		perPeriodSharpe = m.PerPeriodSharpe
+	}
+
+	var valTotalReturn, valMaxDrawdown, valWinRate, valSharpeRatio sql.NullFloat64
+	var valTrades sql.NullInt64
+	if m := iter.ValidationMetrics; m != nil {
+		valTotalReturn = sql.NullFloat64{Float64: m.TotalReturn, Valid: true}
+		valMaxDrawdown = sql.NullFloat64{Float64: m.MaxDrawdown, Valid: true}
+		valWinRate = sql.NullFloat64{Float64: m.WinRate, Valid: true}
+		valSharpeRatio = sql.NullFloat64{Float64: m.SharpeRatio, Valid: true}
+		valTrades = sql.NullInt64{Int64: int64(m.Trades), Valid: true}
+	}
+
+	_, err := tx.Exec(`
+		INSERT INTO evolution_iterations (
+			evolution_id, version, strategy_id, backtest_run_id, status,
+			total_return, max_drawdown, win_rate, sharpe_ratio, trades,
+			sortino_ratio, calmar_ratio,
+			max_consecutive_losses, avg_win, avg_loss, expectancy, profit_factor, avg_trade_pnl,
+			val_total_return, val_max_drawdown, val_win_rate, val_sharpe_ratio, val_trades,
+			on_pareto_frontier, evaluation_report, changes_summary, prompt_before, prompt_after,
+			prompt_tokens, completion_tokens, improvement_reason, failure_reason, stall_restarts,
+			per_period_sharpe
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, iter.EvolutionID, iter.Version, iter.StrategyID, iter.BacktestRunID, iter.Status,
+		totalReturn, maxDrawdown, winRate, sharpeRatio, trades,
+		sortinoRatio, calmarRatio,
+		maxConsecutiveLosses, avgWin, avgLoss, expectancy, profitFactor, avgTradePnL,
+		valTotalReturn, valMaxDrawdown, valWinRate, valSharpeRatio, valTrades,
+		iter.OnParetoFrontier, iter.EvalReport, iter.ChangesSummary, iter.PromptBefore, iter.PromptAfter,
+		iter.PromptTokens, iter.CompletionTokens, iter.ImprovementReason, iter.FailureReason, iter.StallRestarts,
+		perPeriodSharpe)
+	return err
+}
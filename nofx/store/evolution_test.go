@@ -0,0 +1,980 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"nofx/evotypes"
+)
+
+func newTestEvolutionStore(t *testing.T) *EvolutionStore {
+	t.Helper()
+	st, err := New(filepath.Join(t.TempDir(), "store.db"))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	t.Cleanup(func() { st.Close() })
+
+	if err := st.Evolution().Create(&evotypes.Evolution{
+		ID:             "evo-1",
+		UserID:         "user-1",
+		Name:           "evo",
+		BaseStrategyID: "base",
+		Status:         "created",
+		MaxIterations:  5,
+		Config:         "{}",
+	}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := st.Evolution().CreateIteration(&evotypes.Iteration{
+		EvolutionID: "evo-1",
+		Version:     1,
+		StrategyID:  "base",
+		Status:      evotypes.IterStatusBacktest,
+	}); err != nil {
+		t.Fatalf("CreateIteration failed: %v", err)
+	}
+	return st.Evolution()
+}
+
+func TestIterationEquityCurveRoundTrip(t *testing.T) {
+	s := newTestEvolutionStore(t)
+
+	curve := []evotypes.EquityPoint{
+		{Timestamp: 1000, Equity: 1000, Return: 0},
+		{Timestamp: 2000, Equity: 1030.5, Return: 3.05},
+		{Timestamp: 3000, Equity: 980, Return: -2},
+	}
+	if err := s.UpdateIterationEquityCurve("evo-1", 1, curve); err != nil {
+		t.Fatalf("UpdateIterationEquityCurve failed: %v", err)
+	}
+
+	got, err := s.GetIterationEquityCurve("evo-1", 1)
+	if err != nil {
+		t.Fatalf("GetIterationEquityCurve failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, curve) {
+		t.Fatalf("equity curve = %+v, want %+v", got, curve)
+	}
+}
+
+func TestIterationEquityCurveMissing(t *testing.T) {
+	s := newTestEvolutionStore(t)
+
+	got, err := s.GetIterationEquityCurve("evo-1", 1)
+	if err != nil {
+		t.Fatalf("GetIterationEquityCurve failed: %v", err)
+	}
+	if len(got) != 0 {
+		t.Fatalf("expected empty curve before it is stored, got %+v", got)
+	}
+
+	if _, err := s.GetIterationEquityCurve("evo-1", 2); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected sql.ErrNoRows for unknown iteration, got %v", err)
+	}
+}
+
+func TestIterationDecisionSamplesRoundTrip(t *testing.T) {
+	s := newTestEvolutionStore(t)
+
+	samples := []evotypes.DecisionSample{
+		{Timestamp: 1000, Symbol: "BTCUSDT", Action: "open_long", Reasoning: "breakout"},
+		{Timestamp: 2000, Symbol: "BTCUSDT", Action: "close_long", Reasoning: "target hit", PnL: 42.5, IsKeyEvent: true},
+	}
+	if err := s.UpdateIterationDecisionSamples("evo-1", 1, samples); err != nil {
+		t.Fatalf("UpdateIterationDecisionSamples failed: %v", err)
+	}
+
+	got, err := s.GetIterationDecisionSamples("evo-1", 1)
+	if err != nil {
+		t.Fatalf("GetIterationDecisionSamples failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, samples) {
+		t.Fatalf("decision samples = %+v, want %+v", got, samples)
+	}
+}
+
+func TestIterationCompletePersistsMetrics(t *testing.T) {
+	s := newTestEvolutionStore(t)
+
+	metrics := &evotypes.Metrics{
+		TotalReturn:  12,
+		MaxDrawdown:  4,
+		WinRate:      55,
+		SharpeRatio:  0.8,
+		SortinoRatio: 1.6,
+		CalmarRatio:  3,
+		Trades:       20,
+
+		MaxConsecutiveLosses: 4,
+		AvgWin:               15,
+		AvgLoss:              -6,
+		Expectancy:           5.55,
+		ProfitFactor:         1.8,
+		AvgTradePnL:          4.2,
+	}
+	if err := s.UpdateIterationComplete("evo-1", 1, metrics, "", "", ""); err != nil {
+		t.Fatalf("UpdateIterationComplete failed: %v", err)
+	}
+
+	iter, err := s.GetIteration("evo-1", 1)
+	if err != nil {
+		t.Fatalf("GetIteration failed: %v", err)
+	}
+	if !reflect.DeepEqual(iter.Metrics, metrics) {
+		t.Fatalf("metrics = %+v, want %+v", iter.Metrics, metrics)
+	}
+}
+
+func TestPerPeriodSharpeMigrationFlagsExistingRows(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.db")
+	st, err := New(path)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	s := st.Evolution()
+	if err := s.Create(&evotypes.Evolution{ID: "evo-1", UserID: "user-1", Name: "evo", Status: "created", Config: "{}"}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	for version := 1; version <= 2; version++ {
+		if err := s.CreateIteration(&evotypes.Iteration{EvolutionID: "evo-1", Version: version, Status: evotypes.IterStatusBacktest}); err != nil {
+			t.Fatalf("CreateIteration failed: %v", err)
+		}
+	}
+	if err := s.UpdateIterationMetrics("evo-1", 1, &evotypes.Metrics{TotalReturn: 5, SharpeRatio: 0.1}); err != nil {
+		t.Fatalf("UpdateIterationMetrics failed: %v", err)
+	}
+	// Simulate a database from before the column existed
+	if _, err := st.db.Exec(`ALTER TABLE evolution_iterations DROP COLUMN per_period_sharpe`); err != nil {
+		t.Fatalf("drop column failed: %v", err)
+	}
+	st.Close()
+
+	st, err = New(path)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer st.Close()
+	s = st.Evolution()
+
+	scored, err := s.GetIteration("evo-1", 1)
+	if err != nil {
+		t.Fatalf("GetIteration failed: %v", err)
+	}
+	if !scored.Metrics.PerPeriodSharpe {
+		t.Error("expected an iteration scored before the migration to be flagged per-period")
+	}
+
+	// Metrics written after the migration are annualized
+	if err := s.UpdateIterationComplete("evo-1", 2, &evotypes.Metrics{TotalReturn: 6, SharpeRatio: 1.9}, "", "", ""); err != nil {
+		t.Fatalf("UpdateIterationComplete failed: %v", err)
+	}
+	if err := s.UpdateIterationMetrics("evo-1", 1, &evotypes.Metrics{TotalReturn: 5, SharpeRatio: 1.4}); err != nil {
+		t.Fatalf("UpdateIterationMetrics failed: %v", err)
+	}
+	for _, version := range []int{1, 2} {
+		iter, err := s.GetIteration("evo-1", version)
+		if err != nil {
+			t.Fatalf("GetIteration failed: %v", err)
+		}
+		if iter.Metrics.PerPeriodSharpe {
+			t.Errorf("v%d: expected rescored metrics to be annualized", version)
+		}
+	}
+}
+
+func TestLegacyBacktestStatusMigration(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.db")
+	st, err := New(path)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if err := st.Evolution().Create(&evotypes.Evolution{ID: "evo-1", UserID: "user-1", Name: "evo", Status: "running", Config: "{}"}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := st.Evolution().CreateIteration(&evotypes.Iteration{EvolutionID: "evo-1", Version: 1, Status: "backtest"}); err != nil {
+		t.Fatalf("CreateIteration failed: %v", err)
+	}
+	st.Close()
+
+	st, err = New(path)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer st.Close()
+	iter, err := st.Evolution().GetIteration("evo-1", 1)
+	if err != nil {
+		t.Fatalf("GetIteration failed: %v", err)
+	}
+	if iter.Status != evotypes.IterStatusBacktest {
+		t.Errorf("expected legacy status to become %q, got %q", evotypes.IterStatusBacktest, iter.Status)
+	}
+}
+
+func TestCreateIterationPersistsProfitFactor(t *testing.T) {
+	s := newTestEvolutionStore(t)
+
+	metrics := &evotypes.Metrics{TotalReturn: 3, Trades: 8, ProfitFactor: 1.25, AvgTradePnL: -0.75}
+	if err := s.CreateIteration(&evotypes.Iteration{
+		EvolutionID: "evo-1",
+		Version:     2,
+		StrategyID:  "base",
+		Status:      "completed",
+		Metrics:     metrics,
+	}); err != nil {
+		t.Fatalf("CreateIteration failed: %v", err)
+	}
+
+	iter, err := s.GetIteration("evo-1", 2)
+	if err != nil {
+		t.Fatalf("GetIteration failed: %v", err)
+	}
+	if iter.Metrics == nil || iter.Metrics.ProfitFactor != 1.25 || iter.Metrics.AvgTradePnL != -0.75 {
+		t.Fatalf("metrics = %+v, want profit factor 1.25 and avg trade PnL -0.75", iter.Metrics)
+	}
+
+	// UpdateIterationMetrics overwrites them like the other metrics
+	metrics.ProfitFactor, metrics.AvgTradePnL = 2.5, 1.5
+	if err := s.UpdateIterationMetrics("evo-1", 2, metrics); err != nil {
+		t.Fatalf("UpdateIterationMetrics failed: %v", err)
+	}
+	iter, err = s.GetIteration("evo-1", 2)
+	if err != nil {
+		t.Fatalf("GetIteration failed: %v", err)
+	}
+	if !reflect.DeepEqual(iter.Metrics, metrics) {
+		t.Fatalf("metrics = %+v, want %+v", iter.Metrics, metrics)
+	}
+}
+
+func TestIterationOutcomeReasonsRoundTrip(t *testing.T) {
+	s := newTestEvolutionStore(t)
+
+	if err := s.CreateIteration(&evotypes.Iteration{
+		EvolutionID:   "evo-1",
+		Version:       2,
+		StrategyID:    "base",
+		Status:        "completed",
+		FailureReason: "no improvement",
+	}); err != nil {
+		t.Fatalf("CreateIteration failed: %v", err)
+	}
+	iter, err := s.GetIteration("evo-1", 2)
+	if err != nil {
+		t.Fatalf("GetIteration failed: %v", err)
+	}
+	if iter.FailureReason != "no improvement" || iter.ImprovementReason != "" {
+		t.Fatalf("reasons = %q/%q, want failure reason from CreateIteration", iter.ImprovementReason, iter.FailureReason)
+	}
+
+	// Recording a promotion replaces the earlier failure reason
+	if err := s.UpdateIterationOutcome("evo-1", 2, "higher return (12.30% vs 9.10%)", ""); err != nil {
+		t.Fatalf("UpdateIterationOutcome failed: %v", err)
+	}
+	iter, err = s.GetIteration("evo-1", 2)
+	if err != nil {
+		t.Fatalf("GetIteration failed: %v", err)
+	}
+	if iter.ImprovementReason != "higher return (12.30% vs 9.10%)" || iter.FailureReason != "" {
+		t.Errorf("reasons = %q/%q, want only the improvement reason", iter.ImprovementReason, iter.FailureReason)
+	}
+}
+
+func TestEvolutionSoftDeleteAndRestore(t *testing.T) {
+	s := newTestEvolutionStore(t)
+
+	if err := s.Delete("evo-1"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := s.Get("user-1", "evo-1"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected soft-deleted evolution to be hidden from Get, got %v", err)
+	}
+	if list, err := s.List("user-1"); err != nil || len(list) != 0 {
+		t.Fatalf("expected soft-deleted evolution to be hidden from List, got %d (%v)", len(list), err)
+	}
+
+	if err := s.Restore("user-2", "evo-1"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected another user's restore to fail, got %v", err)
+	}
+	if err := s.Restore("user-1", "evo-1"); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if list, err := s.List("user-1"); err != nil || len(list) != 1 {
+		t.Fatalf("expected restored evolution in List, got %d (%v)", len(list), err)
+	}
+	if iterations, err := s.GetIterations("evo-1"); err != nil || len(iterations) != 1 {
+		t.Fatalf("expected iterations to survive the soft delete, got %d (%v)", len(iterations), err)
+	}
+	if err := s.Restore("user-1", "evo-1"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected restoring a live evolution to fail, got %v", err)
+	}
+}
+
+func TestResetRunningToPausedSkipsDeleted(t *testing.T) {
+	s := newTestEvolutionStore(t)
+	if err := s.Create(&evotypes.Evolution{
+		ID: "evo-2", UserID: "user-1", Name: "evo", BaseStrategyID: "base", Status: "created", MaxIterations: 5, Config: "{}",
+	}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	for _, id := range []string{"evo-1", "evo-2"} {
+		if err := s.UpdateStatus(id, "running"); err != nil {
+			t.Fatalf("UpdateStatus failed: %v", err)
+		}
+	}
+	if err := s.Delete("evo-1"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	if n, err := s.ResetRunningToPaused(); err != nil || n != 1 {
+		t.Fatalf("expected only the live evolution to be paused, got %d (%v)", n, err)
+	}
+	if err := s.Restore("user-1", "evo-1"); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if evolution, err := s.Get("user-1", "evo-1"); err != nil || evolution.Status != "running" {
+		t.Fatalf("expected the deleted evolution to keep its status, got %+v (%v)", evolution, err)
+	}
+}
+
+func TestEvolutionPurgeDeleted(t *testing.T) {
+	s := newTestEvolutionStore(t)
+	if err := s.Create(&evotypes.Evolution{
+		ID: "evo-2", UserID: "user-1", Name: "kept", BaseStrategyID: "base", Status: "created", Config: "{}",
+	}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := s.Delete("evo-1"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	// Nothing was deleted before an hour ago
+	if purged, err := s.PurgeDeleted(time.Now().Add(-time.Hour)); err != nil || purged != 0 {
+		t.Fatalf("expected nothing to purge yet, got %d (%v)", purged, err)
+	}
+	if err := s.Restore("user-1", "evo-1"); err != nil {
+		t.Fatalf("expected evolution to be restorable before purge: %v", err)
+	}
+	if err := s.Delete("evo-1"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	purged, err := s.PurgeDeleted(time.Now().Add(time.Minute))
+	if err != nil || purged != 1 {
+		t.Fatalf("expected 1 evolution purged, got %d (%v)", purged, err)
+	}
+	if err := s.Restore("user-1", "evo-1"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected purged evolution to be gone, got %v", err)
+	}
+	if iterations, err := s.GetIterations("evo-1"); err != nil || len(iterations) != 0 {
+		t.Fatalf("expected iterations to be purged, got %d (%v)", len(iterations), err)
+	}
+	if _, err := s.Get("user-1", "evo-2"); err != nil {
+		t.Fatalf("expected live evolution to survive the purge: %v", err)
+	}
+}
+
+func TestPopulationRoundTrip(t *testing.T) {
+	s := newTestEvolutionStore(t)
+
+	if latest, err := s.GetLatestPopulationGeneration("evo-1"); err != nil || latest != 0 {
+		t.Fatalf("expected no population yet, got generation %d (%v)", latest, err)
+	}
+	if err := s.SavePopulation("evo-1", 1, []*evotypes.PopulationMember{
+		{Version: 1, Rank: 2, Elite: true},
+		{Version: 2, Rank: 1, Elite: true},
+		{Version: 3, Rank: 3},
+	}); err != nil {
+		t.Fatalf("SavePopulation failed: %v", err)
+	}
+	// Saving a generation again replaces its members
+	if err := s.SavePopulation("evo-1", 2, []*evotypes.PopulationMember{{Version: 4, Rank: 1, Elite: true}}); err != nil {
+		t.Fatalf("SavePopulation failed: %v", err)
+	}
+	if err := s.SavePopulation("evo-1", 2, []*evotypes.PopulationMember{
+		{Version: 2, Rank: 1, Elite: true},
+		{Version: 5, Rank: 2},
+	}); err != nil {
+		t.Fatalf("SavePopulation failed: %v", err)
+	}
+
+	if latest, err := s.GetLatestPopulationGeneration("evo-1"); err != nil || latest != 2 {
+		t.Fatalf("expected latest generation 2, got %d (%v)", latest, err)
+	}
+	first, err := s.GetPopulation("evo-1", 1)
+	if err != nil {
+		t.Fatalf("GetPopulation failed: %v", err)
+	}
+	if len(first) != 3 || first[0].Version != 2 || first[1].Version != 1 || !first[1].Elite || first[2].Elite {
+		t.Fatalf("generation 1 = %+v, want v2, v1 (elites) then v3", first)
+	}
+	second, err := s.GetPopulation("evo-1", 2)
+	if err != nil {
+		t.Fatalf("GetPopulation failed: %v", err)
+	}
+	if len(second) != 2 || second[0].Version != 2 || second[1].Version != 5 {
+		t.Fatalf("generation 2 = %+v, want v2 then v5", second)
+	}
+}
+
+func TestGetIterationsPaged(t *testing.T) {
+	s := newTestEvolutionStore(t)
+	for v := 2; v <= 7; v++ {
+		if err := s.CreateIteration(&evotypes.Iteration{EvolutionID: "evo-1", Version: v, StrategyID: "base", Status: "completed"}); err != nil {
+			t.Fatalf("CreateIteration failed: %v", err)
+		}
+	}
+
+	tests := []struct {
+		limit, offset int
+		versions      []int
+	}{
+		{3, 0, []int{1, 2, 3}},
+		{3, 3, []int{4, 5, 6}},
+		{3, 6, []int{7}},
+		{3, 9, []int{}},
+		{0, 5, []int{6, 7}},
+	}
+	for _, tt := range tests {
+		page, total, err := s.GetIterationsPaged("evo-1", tt.limit, tt.offset)
+		if err != nil {
+			t.Fatalf("GetIterationsPaged(%d, %d) failed: %v", tt.limit, tt.offset, err)
+		}
+		if total != 7 {
+			t.Fatalf("expected total 7, got %d", total)
+		}
+		versions := []int{}
+		for _, iter := range page {
+			versions = append(versions, iter.Version)
+		}
+		if !reflect.DeepEqual(versions, tt.versions) {
+			t.Fatalf("GetIterationsPaged(%d, %d): expected versions %v, got %v", tt.limit, tt.offset, tt.versions, versions)
+		}
+	}
+}
+
+func TestGetIterationStatsAndScoredIterations(t *testing.T) {
+	s := newTestEvolutionStore(t) // v1 is still backtesting
+	for v := 2; v <= 4; v++ {
+		if err := s.CreateIteration(&evotypes.Iteration{
+			EvolutionID:  "evo-1",
+			Version:      v,
+			StrategyID:   "base",
+			Status:       "completed",
+			Metrics:      &evotypes.Metrics{TotalReturn: float64(v)},
+			PromptBefore: "prompt",
+		}); err != nil {
+			t.Fatalf("CreateIteration failed: %v", err)
+		}
+		if err := s.AddIterationTokenUsage("evo-1", v, 100, 10); err != nil {
+			t.Fatalf("AddIterationTokenUsage failed: %v", err)
+		}
+	}
+
+	stats, err := s.GetIterationStats("evo-1", 2)
+	if err != nil {
+		t.Fatalf("GetIterationStats failed: %v", err)
+	}
+	want := IterationStats{Count: 4, PromptTokens: 300, CompletionTokens: 30, CompletedAfter: 2}
+	if *stats != want {
+		t.Errorf("expected %+v, got %+v", want, *stats)
+	}
+
+	scored, err := s.GetScoredIterations("evo-1")
+	if err != nil {
+		t.Fatalf("GetScoredIterations failed: %v", err)
+	}
+	if len(scored) != 3 || scored[0].Version != 2 || scored[2].Version != 4 {
+		t.Fatalf("expected completed v2..v4, got %d iterations", len(scored))
+	}
+	if scored[2].Metrics == nil || scored[2].Metrics.TotalReturn != 4 || scored[2].PromptBefore != "" {
+		t.Errorf("expected metrics without the prompt, got %+v", scored[2])
+	}
+}
+
+func TestEvolutionPurgeDeletedIsAtomic(t *testing.T) {
+	s := newTestEvolutionStore(t)
+	if err := s.SaveIterationCheckpoint(&evotypes.IterationCheckpoint{EvolutionID: "evo-1", Version: 1, BacktestRunID: "run-1"}); err != nil {
+		t.Fatalf("SaveIterationCheckpoint failed: %v", err)
+	}
+	if err := s.Delete("evo-1"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	countRows := func(query string) int {
+		t.Helper()
+		var n int
+		if err := s.db.QueryRow(query).Scan(&n); err != nil {
+			t.Fatalf("count query failed: %v", err)
+		}
+		return n
+	}
+
+	// Simulate a failure after the iterations were deleted but before the evolution is
+	_, err := s.db.Exec(`CREATE TRIGGER fail_evolution_delete BEFORE DELETE ON evolutions
+		BEGIN SELECT RAISE(ABORT, 'simulated failure'); END`)
+	if err != nil {
+		t.Fatalf("failed to create trigger: %v", err)
+	}
+	if _, err := s.PurgeDeleted(time.Now().Add(time.Minute)); err == nil {
+		t.Fatal("expected the simulated failure to abort the purge")
+	}
+	if n := countRows(`SELECT COUNT(*) FROM evolution_iterations WHERE evolution_id = 'evo-1'`); n != 1 {
+		t.Fatalf("expected the failed purge to keep the iterations, got %d", n)
+	}
+	if n := countRows(`SELECT COUNT(*) FROM evolution_checkpoints WHERE evolution_id = 'evo-1'`); n != 1 {
+		t.Fatalf("expected the failed purge to keep the checkpoints, got %d", n)
+	}
+	if err := s.Restore("user-1", "evo-1"); err != nil {
+		t.Fatalf("expected the evolution to survive the failed purge: %v", err)
+	}
+
+	if _, err := s.db.Exec(`DROP TRIGGER fail_evolution_delete`); err != nil {
+		t.Fatalf("failed to drop trigger: %v", err)
+	}
+	if err := s.Delete("evo-1"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if purged, err := s.PurgeDeleted(time.Now().Add(time.Minute)); err != nil || purged != 1 {
+		t.Fatalf("expected 1 evolution purged, got %d (%v)", purged, err)
+	}
+	orphans := countRows(`SELECT
+		(SELECT COUNT(*) FROM evolution_iterations WHERE evolution_id NOT IN (SELECT id FROM evolutions)) +
+		(SELECT COUNT(*) FROM evolution_checkpoints WHERE evolution_id NOT IN (SELECT id FROM evolutions))`)
+	if orphans != 0 {
+		t.Fatalf("expected no orphan rows after purge, got %d", orphans)
+	}
+}
+
+func TestGetIterationComparison(t *testing.T) {
+	s := newTestEvolutionStore(t)
+	if err := s.CreateIteration(&evotypes.Iteration{EvolutionID: "evo-1", Version: 2, StrategyID: "v2", Status: "completed"}); err != nil {
+		t.Fatalf("CreateIteration failed: %v", err)
+	}
+
+	promptA := `{"coin_source":{"source_type":"static"},"risk_control":{"max_positions":3,"min_confidence":70},"custom_prompt":"trend"}`
+	promptB := `{"coin_source":{"source_type":"static"},"risk_control":{"max_positions":4,"min_confidence":70,"max_margin_usage":0.8},"custom_prompt":"trend"}`
+	for _, it := range []struct {
+		version int
+		prompt  string
+		metrics *evotypes.Metrics
+	}{
+		{1, promptA, &evotypes.Metrics{TotalReturn: 5, MaxDrawdown: 12, WinRate: 48, SharpeRatio: 0.8, Trades: 40}},
+		{2, promptB, &evotypes.Metrics{TotalReturn: 8.5, MaxDrawdown: 9, WinRate: 52, SharpeRatio: 1.1, Trades: 35}},
+	} {
+		if err := s.UpdateIterationPrompts("evo-1", it.version, it.prompt, ""); err != nil {
+			t.Fatalf("UpdateIterationPrompts failed: %v", err)
+		}
+		if err := s.UpdateIterationMetrics("evo-1", it.version, it.metrics); err != nil {
+			t.Fatalf("UpdateIterationMetrics failed: %v", err)
+		}
+	}
+
+	cmp, err := s.GetIterationComparison("evo-1", 1, 2)
+	if err != nil {
+		t.Fatalf("GetIterationComparison failed: %v", err)
+	}
+	if cmp.A.Version != 1 || cmp.B.Version != 2 {
+		t.Fatalf("unexpected iterations: v%d vs v%d", cmp.A.Version, cmp.B.Version)
+	}
+	const eps = 1e-9
+	d := cmp.Delta
+	if d == nil || math.Abs(d.TotalReturn-3.5) > eps || math.Abs(d.MaxDrawdown+3) > eps ||
+		math.Abs(d.WinRate-4) > eps || math.Abs(d.SharpeRatio-0.3) > eps || d.Trades != -5 {
+		t.Fatalf("unexpected metric deltas: %+v", d)
+	}
+
+	want := []string{
+		"risk_control.max_margin_usage: (unset) → 0.8",
+		"risk_control.max_positions: 3 → 4",
+	}
+	if !reflect.DeepEqual(cmp.PromptDiff.Changes, want) {
+		t.Fatalf("expected only the changed fields %v, got %v", want, cmp.PromptDiff.Changes)
+	}
+
+	if _, err := s.GetIterationComparison("evo-1", 1, 9); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected sql.ErrNoRows for an unknown version, got %v", err)
+	}
+}
+
+func TestComputePromptDiffJSONFields(t *testing.T) {
+	before := `{"risk_control":{"max_positions":3,"min_confidence":70},"custom_prompt":"trend","coin_source":{"use_oi_top":true}}`
+	after := `{"risk_control":{"max_positions":4,"min_confidence":70,"max_margin_usage":0.8},"custom_prompt":"trend"}`
+
+	diff := ComputePromptDiff(before, after)
+	if diff.Format != "json" || len(diff.Lines) != 0 {
+		t.Fatalf("expected a field-level diff, got format %q with %d lines", diff.Format, len(diff.Lines))
+	}
+	want := []evotypes.PromptFieldChange{
+		{Path: "coin_source.use_oi_top", Kind: "removed", Old: "true"},
+		{Path: "risk_control.max_margin_usage", Kind: "added", New: "0.8"},
+		{Path: "risk_control.max_positions", Kind: "changed", Old: "3", New: "4"},
+	}
+	if !reflect.DeepEqual(diff.Fields, want) {
+		t.Fatalf("fields = %+v, want %+v", diff.Fields, want)
+	}
+	if len(diff.Changes) != len(want) || diff.Changes[2] != "risk_control.max_positions: 3 → 4" {
+		t.Fatalf("unexpected readable changes: %v", diff.Changes)
+	}
+
+	if same := ComputePromptDiff(before, before); len(same.Fields) != 0 || len(same.Changes) != 0 {
+		t.Fatalf("identical prompts should have no changes, got %+v", same)
+	}
+}
+
+func TestComputePromptDiffTextFallback(t *testing.T) {
+	before := "Trade with the trend.\nRisk 1% per trade.\nAvoid news events."
+	after := "Trade with the trend.\nRisk 0.5% per trade.\nAvoid news events.\nSkip weekends."
+
+	diff := ComputePromptDiff(before, after)
+	if diff.Format != "text" || len(diff.Fields) != 0 {
+		t.Fatalf("expected a text diff, got format %q with %d fields", diff.Format, len(diff.Fields))
+	}
+	want := []evotypes.PromptLineChange{
+		{Op: "-", Line: 2, Text: "Risk 1% per trade."},
+		{Op: "+", Line: 2, Text: "Risk 0.5% per trade."},
+		{Op: "+", Line: 4, Text: "Skip weekends."},
+	}
+	if !reflect.DeepEqual(diff.Lines, want) {
+		t.Fatalf("lines = %+v, want %+v", diff.Lines, want)
+	}
+	if diff.Changes[0] != "- Risk 1% per trade." || diff.Changes[2] != "+ Skip weekends." {
+		t.Fatalf("unexpected readable changes: %v", diff.Changes)
+	}
+
+	// One JSON side is not enough for a field-level diff
+	if mixed := ComputePromptDiff(`{"custom_prompt":"trend"}`, "trend"); mixed.Format != "text" || len(mixed.Lines) != 2 {
+		t.Fatalf("expected a text diff of JSON against plain text, got %+v", mixed)
+	}
+}
+
+func TestGetIterationDetail(t *testing.T) {
+	s := newTestEvolutionStore(t)
+
+	if err := s.UpdateIterationPrompts("evo-1", 1, `{"risk_control":{"max_positions":3}}`, `{"risk_control":{"max_positions":2}}`); err != nil {
+		t.Fatalf("UpdateIterationPrompts failed: %v", err)
+	}
+	if err := s.UpdateIterationEvaluation("evo-1", 1, `{"weaknesses":["too many trades"]}`, "fewer positions"); err != nil {
+		t.Fatalf("UpdateIterationEvaluation failed: %v", err)
+	}
+	curve := []evotypes.EquityPoint{{Timestamp: 1, Equity: 100}, {Timestamp: 2, Equity: 104, Return: 4}}
+	if err := s.UpdateIterationEquityCurve("evo-1", 1, curve); err != nil {
+		t.Fatalf("UpdateIterationEquityCurve failed: %v", err)
+	}
+	samples := []evotypes.DecisionSample{{Timestamp: 2, Symbol: "ETHUSDT", Action: "close_short", Reasoning: "stop hit", PnL: -12, IsKeyEvent: true}}
+	if err := s.UpdateIterationDecisionSamples("evo-1", 1, samples); err != nil {
+		t.Fatalf("UpdateIterationDecisionSamples failed: %v", err)
+	}
+
+	detail, err := s.GetIterationDetail("evo-1", 1)
+	if err != nil {
+		t.Fatalf("GetIterationDetail failed: %v", err)
+	}
+	if detail.Version != 1 || detail.ChangesSummary != "fewer positions" {
+		t.Fatalf("unexpected iteration: %+v", detail.Iteration)
+	}
+	if r := detail.EvaluationReportParsed; r == nil || len(r.Weaknesses) != 1 || r.Weaknesses[0] != "too many trades" {
+		t.Fatalf("expected the parsed evaluation report, got %+v", detail.EvaluationReportParsed)
+	}
+	if detail.PromptDiff == nil || len(detail.PromptDiff.Fields) != 1 || detail.PromptDiff.Fields[0].Path != "risk_control.max_positions" {
+		t.Fatalf("expected the max_positions change, got %+v", detail.PromptDiff)
+	}
+	if !reflect.DeepEqual(detail.EquityCurve, curve) {
+		t.Fatalf("equity curve = %+v, want %+v", detail.EquityCurve, curve)
+	}
+	if !reflect.DeepEqual(detail.DecisionSamples, samples) {
+		t.Fatalf("decision samples = %+v, want %+v", detail.DecisionSamples, samples)
+	}
+
+	if _, err := s.GetIterationDetail("evo-1", 9); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected sql.ErrNoRows for an unknown version, got %v", err)
+	}
+}
+
+func TestConcurrentIterationWritesAreNotLost(t *testing.T) {
+	// Two stores on the same file stand in for evolvers writing through separate connections,
+	// which is where SQLite reports "database is locked"
+	dbPath := filepath.Join(t.TempDir(), "store.db")
+	stores := make([]*Store, 2)
+	for i := range stores {
+		st, err := New(dbPath)
+		if err != nil {
+			t.Fatalf("New failed: %v", err)
+		}
+		t.Cleanup(func() { st.Close() })
+		stores[i] = st
+	}
+
+	const evolutionsPerStore, iterations = 3, 10
+	var wg sync.WaitGroup
+	errs := make(chan error, len(stores)*evolutionsPerStore*iterations*2)
+	for i, st := range stores {
+		for e := 0; e < evolutionsPerStore; e++ {
+			evolutionID := fmt.Sprintf("evo-%d-%d", i, e)
+			if err := st.Evolution().Create(&evotypes.Evolution{
+				ID: evolutionID, UserID: "user-1", Name: evolutionID, BaseStrategyID: "base",
+				Status: evotypes.StatusRunning, MaxIterations: iterations, Config: "{}",
+			}); err != nil {
+				t.Fatalf("Create failed: %v", err)
+			}
+
+			wg.Add(1)
+			go func(evolutions *EvolutionStore) {
+				defer wg.Done()
+				for v := 1; v <= iterations; v++ {
+					if err := evolutions.CreateIteration(&evotypes.Iteration{
+						EvolutionID: evolutionID, Version: v, StrategyID: "base", Status: evotypes.IterStatusBacktest,
+					}); err != nil {
+						errs <- err
+						continue
+					}
+					metrics := &evotypes.Metrics{TotalReturn: float64(v), Trades: v}
+					if err := evolutions.UpdateIterationComplete(evolutionID, v, metrics, "report", "changes", "prompt"); err != nil {
+						errs <- err
+					}
+				}
+			}(st.Evolution())
+		}
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("concurrent write failed: %v", err)
+	}
+
+	for i := range stores {
+		for e := 0; e < evolutionsPerStore; e++ {
+			evolutionID := fmt.Sprintf("evo-%d-%d", i, e)
+			got, err := stores[0].Evolution().GetIterations(evolutionID)
+			if err != nil {
+				t.Fatalf("GetIterations failed: %v", err)
+			}
+			if len(got) != iterations {
+				t.Fatalf("expected %d iterations for %s, got %d", iterations, evolutionID, len(got))
+			}
+			for _, iter := range got {
+				if iter.Status != evotypes.IterStatusCompleted || iter.Metrics == nil || iter.Metrics.Trades != iter.Version {
+					t.Fatalf("expected iteration %d of %s to be completed with its metrics, got %+v", iter.Version, evolutionID, iter)
+				}
+			}
+		}
+	}
+}
+
+func TestEvolutionExportImportRoundTrip(t *testing.T) {
+	st, err := New(filepath.Join(t.TempDir(), "store.db"))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	t.Cleanup(func() { st.Close() })
+	evolutions := st.Evolution()
+
+	for _, id := range []string{"base", "v2"} {
+		if err := st.Strategy().Create(&Strategy{ID: id, UserID: "user-1", Name: id, Config: `{"name":"` + id + `"}`}); err != nil {
+			t.Fatalf("Create strategy failed: %v", err)
+		}
+	}
+	if err := evolutions.Create(&evotypes.Evolution{
+		ID:             "evo-1",
+		UserID:         "user-1",
+		Name:           "evo",
+		BaseStrategyID: "v2",
+		Status:         evotypes.StatusRunning,
+		MaxIterations:  5,
+		Config:         `{"user_id":"user-1","base_strategy_id":"base"}`,
+	}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	metrics := map[int]*evotypes.Metrics{
+		1: {TotalReturn: 5, MaxDrawdown: 3, WinRate: 50, SharpeRatio: 0.5, Trades: 10},
+		2: {TotalReturn: 9, MaxDrawdown: 2, WinRate: 60, SharpeRatio: 1.1, SortinoRatio: 1.4, Trades: 12, Expectancy: 1.5},
+	}
+	for version, strategyID := range map[int]string{1: "base", 2: "v2"} {
+		if err := evolutions.CreateIteration(&evotypes.Iteration{
+			EvolutionID: "evo-1", Version: version, StrategyID: strategyID, Status: evotypes.IterStatusBacktest,
+		}); err != nil {
+			t.Fatalf("CreateIteration failed: %v", err)
+		}
+		if err := evolutions.UpdateIterationComplete("evo-1", version, metrics[version], "{}", "changes", "prompt"); err != nil {
+			t.Fatalf("UpdateIterationComplete failed: %v", err)
+		}
+	}
+	if err := evolutions.UpdateBestVersion("evo-1", 2, 9, 2); err != nil {
+		t.Fatalf("UpdateBestVersion failed: %v", err)
+	}
+	curve := []evotypes.EquityPoint{{Timestamp: 1000, Equity: 100}, {Timestamp: 2000, Equity: 109, Return: 9}}
+	if err := evolutions.UpdateIterationEquityCurve("evo-1", 2, curve); err != nil {
+		t.Fatalf("UpdateIterationEquityCurve failed: %v", err)
+	}
+	samples := []evotypes.DecisionSample{{Timestamp: 1500, Symbol: "BTCUSDT", Action: "open_long", Reasoning: "breakout", PnL: 9, IsKeyEvent: true}}
+	if err := evolutions.UpdateIterationDecisionSamples("evo-1", 2, samples); err != nil {
+		t.Fatalf("UpdateIterationDecisionSamples failed: %v", err)
+	}
+	if err := evolutions.SavePopulation("evo-1", 1, []*evotypes.PopulationMember{
+		{Version: 2, Rank: 1, Elite: true},
+		{Version: 1, Rank: 2},
+	}); err != nil {
+		t.Fatalf("SavePopulation failed: %v", err)
+	}
+
+	bundle, err := evolutions.ExportEvolution("evo-1")
+	if err != nil {
+		t.Fatalf("ExportEvolution failed: %v", err)
+	}
+	if len(bundle.Iterations) != 2 || len(bundle.Strategies) != 2 {
+		t.Fatalf("bundle has %d iterations and %d strategies, want 2 and 2", len(bundle.Iterations), len(bundle.Strategies))
+	}
+
+	// The bundle travels as JSON between environments
+	data, err := json.Marshal(bundle)
+	if err != nil {
+		t.Fatalf("marshal bundle: %v", err)
+	}
+	var decoded EvolutionBundle
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("unmarshal bundle: %v", err)
+	}
+
+	imported, err := evolutions.ImportEvolution("user-2", &decoded)
+	if err != nil {
+		t.Fatalf("ImportEvolution failed: %v", err)
+	}
+	if imported.ID == "evo-1" || imported.UserID != "user-2" {
+		t.Fatalf("imported evolution %s owned by %s, want a fresh ID owned by user-2", imported.ID, imported.UserID)
+	}
+	if imported.BestVersion != 2 || imported.BestReturn != 9 || imported.Status != evotypes.StatusPaused {
+		t.Fatalf("imported evolution = %+v", imported)
+	}
+	if imported.BaseStrategyID == "v2" {
+		t.Fatal("base strategy ID was not remapped")
+	}
+	if _, err := st.Strategy().Get("user-2", imported.BaseStrategyID); err != nil {
+		t.Fatalf("imported base strategy not found: %v", err)
+	}
+
+	var cfg evotypes.EvolutionConfig
+	if err := json.Unmarshal([]byte(imported.Config), &cfg); err != nil {
+		t.Fatalf("unmarshal config: %v", err)
+	}
+	if cfg.UserID != "user-2" || cfg.BaseStrategyID == "base" || cfg.BaseStrategyID == "" {
+		t.Fatalf("config not remapped: %+v", cfg)
+	}
+
+	iterations, err := evolutions.GetIterations(imported.ID)
+	if err != nil {
+		t.Fatalf("GetIterations failed: %v", err)
+	}
+	if len(iterations) != 2 {
+		t.Fatalf("imported %d iterations, want 2", len(iterations))
+	}
+	for _, iter := range iterations {
+		if !reflect.DeepEqual(iter.Metrics, metrics[iter.Version]) {
+			t.Fatalf("v%d metrics = %+v, want %+v", iter.Version, iter.Metrics, metrics[iter.Version])
+		}
+		if iter.ChangesSummary != "changes" || iter.PromptAfter != "prompt" {
+			t.Fatalf("v%d lost its texts: %+v", iter.Version, iter)
+		}
+		if _, err := st.Strategy().Get("user-2", iter.StrategyID); err != nil {
+			t.Fatalf("v%d strategy %s not imported: %v", iter.Version, iter.StrategyID, err)
+		}
+	}
+
+	gotCurve, err := evolutions.GetIterationEquityCurve(imported.ID, 2)
+	if err != nil || !reflect.DeepEqual(gotCurve, curve) {
+		t.Fatalf("imported equity curve = %+v (%v), want %+v", gotCurve, err, curve)
+	}
+	gotSamples, err := evolutions.GetIterationDecisionSamples(imported.ID, 2)
+	if err != nil || !reflect.DeepEqual(gotSamples, samples) {
+		t.Fatalf("imported decision samples = %+v (%v), want %+v", gotSamples, err, samples)
+	}
+	if emptyCurve, err := evolutions.GetIterationEquityCurve(imported.ID, 1); err != nil || len(emptyCurve) != 0 {
+		t.Fatalf("v1 equity curve = %+v (%v), want none", emptyCurve, err)
+	}
+	population, err := evolutions.GetPopulation(imported.ID, 1)
+	if err != nil {
+		t.Fatalf("GetPopulation failed: %v", err)
+	}
+	if len(population) != 2 || population[0].Version != 2 || !population[0].Elite || population[1].Version != 1 {
+		t.Fatalf("imported population = %+v, want v2 (elite) then v1", population)
+	}
+}
+
+func TestImportEvolutionRenumbersCollidingVersions(t *testing.T) {
+	s := newTestEvolutionStore(t)
+
+	bundle := &EvolutionBundle{
+		FormatVersion: 1,
+		Evolution:     &evotypes.Evolution{Name: "shared", BaseStrategyID: "base", BestVersion: 7, Config: "{}"},
+		Iterations: []*evotypes.Iteration{
+			{Version: 7, StrategyID: "base", Status: evotypes.IterStatusCompleted, Metrics: &evotypes.Metrics{TotalReturn: 7}},
+			{Version: 3, StrategyID: "base", Status: evotypes.IterStatusCompleted, Metrics: &evotypes.Metrics{TotalReturn: 3}},
+			{Version: 3, StrategyID: "base", Status: evotypes.IterStatusFailed},
+		},
+	}
+	imported, err := s.ImportEvolution("user-1", bundle)
+	if err != nil {
+		t.Fatalf("ImportEvolution failed: %v", err)
+	}
+	if imported.BestVersion != 3 || imported.CurrentIteration != 3 {
+		t.Fatalf("best version %d, current iteration %d, want 3 and 3", imported.BestVersion, imported.CurrentIteration)
+	}
+
+	best, err := s.GetIteration(imported.ID, imported.BestVersion)
+	if err != nil {
+		t.Fatalf("GetIteration failed: %v", err)
+	}
+	if best.Metrics == nil || best.Metrics.TotalReturn != 7 {
+		t.Fatalf("best version points at %+v, want the former v7", best.Metrics)
+	}
+
+	if _, err := s.ImportEvolution("user-1", &EvolutionBundle{}); !errors.Is(err, ErrInvalidEvolution) {
+		t.Fatalf("expected ErrInvalidEvolution for a bundle without evolution, got %v", err)
+	}
+	bundle.FormatVersion = evolutionBundleFormat + 1
+	if _, err := s.ImportEvolution("user-1", bundle); !errors.Is(err, ErrInvalidEvolution) {
+		t.Fatalf("expected ErrInvalidEvolution for an unsupported format version, got %v", err)
+	}
+}
+
+func TestCreateEvolutionRejectsInvalidWebhookURL(t *testing.T) {
+	s := newTestEvolutionStore(t)
+
+	tests := []struct {
+		url     string
+		wantErr bool
+	}{
+		{url: "", wantErr: false},
+		{url: "https://hooks.example.com/evolution", wantErr: false},
+		{url: "http://10.0.0.5:8080/hook", wantErr: false},
+		{url: "file:///etc/passwd", wantErr: true},
+		{url: "gopher://internal:70/", wantErr: true},
+		{url: "https://", wantErr: true},
+		{url: "hooks.example.com/evolution", wantErr: true},
+	}
+	for i, tt := range tests {
+		config, _ := json.Marshal(evotypes.EvolutionConfig{WebhookURL: tt.url})
+		err := s.Create(&evotypes.Evolution{
+			ID: fmt.Sprintf("evo-webhook-%d", i), UserID: "user-1", Name: "evo", BaseStrategyID: "base",
+			Status: evotypes.StatusCreated, Config: string(config),
+		})
+		if tt.wantErr != errors.Is(err, ErrInvalidEvolution) {
+			t.Errorf("%q: got error %v, want rejection %v", tt.url, err, tt.wantErr)
+		}
+	}
+
+	bundle := &EvolutionBundle{
+		FormatVersion: 1,
+		Evolution:     &evotypes.Evolution{Name: "imported", BaseStrategyID: "base", Config: `{"webhook_url":"ftp://example.com"}`},
+	}
+	if _, err := s.ImportEvolution("user-1", bundle); !errors.Is(err, ErrInvalidEvolution) {
+		t.Errorf("expected the import to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,62 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// DefaultBusyTimeout is how long SQLite waits for a lock held by another connection
+// before failing with SQLITE_BUSY
+const DefaultBusyTimeout = 5 * time.Second
+
+const (
+	maxBusyRetries = 5
+	busyRetryDelay = 50 * time.Millisecond
+)
+
+// isBusyError reports whether err means the database was locked by another writer
+func isBusyError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		code := sqliteErr.Code() & 0xff // strip the extended result code
+		return code == sqlite3.SQLITE_BUSY || code == sqlite3.SQLITE_LOCKED
+	}
+	return strings.Contains(err.Error(), "database is locked")
+}
+
+// retryOnBusy runs fn again with a growing delay while it fails with SQLITE_BUSY. The
+// busy_timeout pragma covers most contention, but SQLite gives up immediately when two
+// transactions deadlock on upgrading their locks, and only a full retry resolves that.
+func retryOnBusy(fn func() error) error {
+	err := fn()
+	for attempt := 1; attempt <= maxBusyRetries && isBusyError(err); attempt++ {
+		time.Sleep(busyRetryDelay * time.Duration(attempt))
+		err = fn()
+	}
+	return err
+}
+
+// withTx runs fn in a transaction, committing on success and retrying the whole
+// transaction when it fails with SQLITE_BUSY
+func withTx(db *sql.DB, fn func(tx *sql.Tx) error) error {
+	return retryOnBusy(func() error {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		if err := fn(tx); err != nil {
+			return err
+		}
+		return tx.Commit()
+	})
+}
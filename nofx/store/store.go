@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"nofx/logger"
 	"sync"
+	"time"
 
 	_ "modernc.org/sqlite"
 )
@@ -45,6 +46,12 @@ type Store struct {
 
 // New creates new Store instance
 func New(dbPath string) (*Store, error) {
+	return NewWithBusyTimeout(dbPath, DefaultBusyTimeout)
+}
+
+// NewWithBusyTimeout creates new Store instance whose connection waits up to busyTimeout
+// for locks held by other writers
+func NewWithBusyTimeout(dbPath string, busyTimeout time.Duration) (*Store, error) {
 	db, err := sql.Open("sqlite", dbPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
@@ -74,7 +81,7 @@ func New(dbPath string) (*Store, error) {
 	}
 
 	// Set busy_timeout
-	if _, err := db.Exec("PRAGMA busy_timeout = 5000"); err != nil {
+	if _, err := db.Exec(fmt.Sprintf("PRAGMA busy_timeout = %d", busyTimeout.Milliseconds())); err != nil {
 		db.Close()
 		return nil, fmt.Errorf("failed to set busy_timeout: %w", err)
 	}
@@ -341,20 +348,7 @@ func (s *Store) SetSystemConfig(key, value string) error {
 	return err
 }
 
-// Transaction executes transaction
+// Transaction executes transaction, retrying it when the database is locked by another writer
 func (s *Store) Transaction(fn func(tx *sql.Tx) error) error {
-	tx, err := s.db.Begin()
-	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
-	}
-
-	if err := fn(tx); err != nil {
-		tx.Rollback()
-		return err
-	}
-
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
-	}
-	return nil
+	return withTx(s.db, fn)
 }
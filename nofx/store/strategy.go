@@ -90,14 +90,22 @@ type IndicatorConfig struct {
 	// raw kline data (OHLCV) - always enabled, required for AI analysis
 	EnableRawKlines bool `json:"enable_raw_klines"`
 	// technical indicator switches
-	EnableEMA         bool `json:"enable_ema"`
-	EnableMACD        bool `json:"enable_macd"`
-	EnableRSI         bool `json:"enable_rsi"`
-	EnableStochRSI    bool `json:"enable_stoch_rsi"`    // Stochastic RSI
-	EnableATR         bool `json:"enable_atr"`
-	EnableVolume      bool `json:"enable_volume"`
-	EnableOI          bool `json:"enable_oi"`           // open interest
-	EnableFundingRate bool `json:"enable_funding_rate"` // funding rate
+	EnableEMA           bool `json:"enable_ema"`
+	EnableMACD          bool `json:"enable_macd"`
+	EnableRSI           bool `json:"enable_rsi"`
+	EnableStochRSI      bool `json:"enable_stoch_rsi"` // Stochastic RSI
+	EnableATR           bool `json:"enable_atr"`
+	EnableVolume        bool `json:"enable_volume"`
+	EnableOI            bool `json:"enable_oi"`             // open interest
+	EnableFundingRate   bool `json:"enable_funding_rate"`   // funding rate
+	EnableADX           bool `json:"enable_adx"`            // ADX trend-strength filter (baseline engine)
+	EnableBollinger     bool `json:"enable_bollinger"`      // Bollinger Band mean-reversion signals (baseline engine)
+	EnableRSIDivergence bool `json:"enable_rsi_divergence"` // RSI divergence reversal signals (baseline engine)
+	EnableVWAP          bool `json:"enable_vwap"`           // session VWAP entry bias (baseline engine)
+	EnableRegime        bool `json:"enable_regime"`         // trending/ranging regime detection weighting trend vs mean-reversion signals (baseline engine)
+	EnableOBV           bool `json:"enable_obv"`            // On-Balance Volume direction confirmation (baseline engine)
+	EnablePSAR          bool `json:"enable_psar"`           // Parabolic SAR reversal exit (baseline engine)
+	EnableIchimoku      bool `json:"enable_ichimoku"`       // Ichimoku cloud trend signals (baseline engine)
 	// EMA period configuration
 	EMAPeriods []int `json:"ema_periods,omitempty"` // default [20, 50]
 	// RSI period configuration
@@ -489,26 +497,17 @@ func (s *StrategyStore) GetDefault() (*Strategy, error) {
 
 // SetActive set active strategy (will first deactivate other strategies)
 func (s *StrategyStore) SetActive(userID, strategyID string) error {
-	// begin transaction
-	tx, err := s.db.Begin()
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback()
-
-	// first deactivate all strategies for the user
-	_, err = tx.Exec(`UPDATE strategies SET is_active = 0 WHERE user_id = ?`, userID)
-	if err != nil {
-		return err
-	}
+	return withTx(s.db, func(tx *sql.Tx) error {
+		// first deactivate all strategies for the user
+		_, err := tx.Exec(`UPDATE strategies SET is_active = 0 WHERE user_id = ?`, userID)
+		if err != nil {
+			return err
+		}
 
-	// activate specified strategy
-	_, err = tx.Exec(`UPDATE strategies SET is_active = 1 WHERE id = ? AND (user_id = ? OR is_default = 1)`, strategyID, userID)
-	if err != nil {
+		// activate specified strategy
+		_, err = tx.Exec(`UPDATE strategies SET is_active = 1 WHERE id = ? AND (user_id = ? OR is_default = 1)`, strategyID, userID)
 		return err
-	}
-
-	return tx.Commit()
+	})
 }
 
 // Duplicate duplicate a strategy (used to create custom strategy based on default strategy)
@@ -563,6 +562,53 @@ type BaselineConfig struct {
 	StochRSIPeriod int `json:"stoch_rsi_period"` // StochRSI period, default 14
 	ATRPeriod      int `json:"atr_period"`       // ATR period, default 14
 
+	// Bollinger Bands (requires EnableBollinger)
+	BollingerPeriod int     `json:"bollinger_period"`  // Bollinger Band period, default 20
+	BollingerStdDev float64 `json:"bollinger_std_dev"` // standard deviation multiplier, default 2.0
+
+	// RSI divergence (requires EnableRSIDivergence)
+	RSIDivergenceLookback    int     `json:"rsi_divergence_lookback"`      // bars scanned for the previous swing, default 14
+	RSIDivergenceMinSwingPct float64 `json:"rsi_divergence_min_swing_pct"` // minimum price move beyond the previous swing (%), default 1.0
+
+	// VWAP entry bias (requires EnableVWAP)
+	VWAPSessionHours int `json:"vwap_session_hours"` // session length in hours, VWAP resets at each UTC-aligned session start, default 24
+
+	// On-Balance Volume (requires EnableOBV)
+	OBVLookback int `json:"obv_lookback"` // bars over which the OBV slope is measured, default 10
+
+	// Ichimoku cloud (requires EnableIchimoku)
+	IchimokuTenkanPeriod  int `json:"ichimoku_tenkan_period"`   // Tenkan-sen (conversion line) period, default 9
+	IchimokuKijunPeriod   int `json:"ichimoku_kijun_period"`    // Kijun-sen (base line) period and cloud displacement, default 26
+	IchimokuSenkouBPeriod int `json:"ichimoku_senkou_b_period"` // Senkou Span B period, default 52
+
+	// Parabolic SAR exit (requires EnablePSAR)
+	PSARAcceleration    float64 `json:"psar_acceleration"`     // acceleration factor step, default 0.02
+	PSARMaxAcceleration float64 `json:"psar_max_acceleration"` // acceleration factor cap, default 0.2
+
+	// Market regime detection (requires EnableRegime)
+	RegimeTrendADX        float64 `json:"regime_trend_adx"`         // ADX at or above which the market is trending, default 25
+	RegimeRangeADX        float64 `json:"regime_range_adx"`         // ADX below which the market is ranging, default 20
+	RegimeMinBandwidthPct float64 `json:"regime_min_bandwidth_pct"` // Bollinger bandwidth (% of middle band) below which an undecided market is ranging, default 4.0
+
+	// Multi-timeframe confirmation
+	SignalTimeframe          string `json:"signal_timeframe,omitempty"`  // timeframe for StochRSI/EMA entry signals (e.g. "1h"), default first available
+	RequireHigherTFAgreement bool   `json:"require_higher_tf_agreement"` // only enter when the higher-timeframe EMA trend agrees
+	HigherTimeframe          string `json:"higher_timeframe,omitempty"`  // higher timeframe for trend confirmation, default "4h"
+
+	// Trading session filter: new entries only inside these UTC windows, exits are always managed
+	TradingHours []BaselineTradingWindow `json:"trading_hours,omitempty"` // empty = trade around the clock
+
+	// Entry cadence: new entries are only evaluated every N bars (MakeDecision calls), exits every bar.
+	// Live only: backtests run the baseline on their decision cadence (decision_cadence_nbars) instead,
+	// so set both to the same value to compare live and backtest behavior.
+	EntryCadenceBars int `json:"entry_cadence_bars,omitempty"` // 0 or 1 = evaluate entries on every bar
+
+	// Symbol universe: new entries only on these symbols, positions on other symbols are still managed
+	AllowedSymbols []string `json:"allowed_symbols,omitempty"` // empty = every symbol in the market data
+
+	// Score weights of the individual indicators
+	IndicatorWeights BaselineIndicatorWeights `json:"indicator_weights"`
+
 	// Signal thresholds
 	SignalThresholds BaselineSignalThresholds `json:"signal_thresholds"`
 
@@ -570,6 +616,30 @@ type BaselineConfig struct {
 	RiskManagement BaselineRiskManagement `json:"risk_management"`
 }
 
+// BaselineTradingWindow UTC time-of-day window in which the baseline engine may open positions
+type BaselineTradingWindow struct {
+	StartHour int   `json:"start_hour"`         // first hour of the window (inclusive), 0-23
+	EndHour   int   `json:"end_hour"`           // hour the window closes (exclusive), 0-24; at or before StartHour wraps past midnight
+	Weekdays  []int `json:"weekdays,omitempty"` // days the window opens on (0 = Sunday), empty = every day
+}
+
+// BaselineIndicatorWeights maximum entry score each indicator can contribute; 0 keeps the default
+type BaselineIndicatorWeights struct {
+	EMAWeight       float64 `json:"ema_weight"`       // max EMA trend score, default 20
+	StochRSIWeight  float64 `json:"stochrsi_weight"`  // max StochRSI score, default 70
+	BollingerWeight float64 `json:"bollinger_weight"` // max Bollinger Band score, default 20
+	MACDWeight      float64 `json:"macd_weight"`      // max MACD histogram momentum score, default 10
+	IchimokuWeight  float64 `json:"ichimoku_weight"`  // max Ichimoku cloud score, default 20
+	// Volume confirmation scales the whole score between these multipliers
+	VolumeMultiplierCaps BaselineVolumeMultiplierCaps `json:"volume_multiplier_caps"`
+}
+
+// BaselineVolumeMultiplierCaps bounds of the volume confirmation score multiplier
+type BaselineVolumeMultiplierCaps struct {
+	Min float64 `json:"min"` // multiplier when volume is below 50% of average, default 0.7
+	Max float64 `json:"max"` // multiplier when volume is 150%-200% of average, default 1.2
+}
+
 // BaselineSignalThresholds signal thresholds for baseline strategy
 type BaselineSignalThresholds struct {
 	RSIOversold      float64 `json:"rsi_oversold"`       // RSI oversold, default 30
@@ -577,9 +647,16 @@ type BaselineSignalThresholds struct {
 	StochOversold    float64 `json:"stoch_oversold"`     // StochRSI oversold, default 20
 	StochOverbought  float64 `json:"stoch_overbought"`   // StochRSI overbought, default 80
 	MinSignalCount   int     `json:"min_signal_count"`   // minimum signal count for entry, default 2
+	// StochRSI entry confirmation: K must have stayed above (long) / below (short) D for this many bars
+	StochCrossConfirmBars int `json:"stoch_cross_confirm_bars"` // default 1 (latest bar only)
 	// StochRSI exit confirmation
 	StochExitRequireExtreme bool `json:"stoch_exit_require_extreme"` // require K in extreme zone for exit, default true
 	MinHoldingCycles        int  `json:"min_holding_cycles"`         // minimum cycles before StochRSI exit, default 2
+	// Post-exit cooldown
+	CooldownBars         int  `json:"cooldown_bars"`          // cycles to wait after a close before re-entering the symbol, 0 = disabled
+	CooldownOppositeOnly bool `json:"cooldown_opposite_only"` // only block re-entry in the opposite direction during cooldown
+	// ADX trend-strength filter (requires EnableADX)
+	MinADX float64 `json:"min_adx"` // minimum ADX for new entries, default 20
 }
 
 // BaselineRiskManagement risk management for baseline strategy
@@ -588,12 +665,41 @@ type BaselineRiskManagement struct {
 	EquityMultiplier float64 `json:"equity_multiplier"` // position size = equity × multiplier, default 5.0
 	Leverage         int     `json:"leverage"`          // leverage, default 5
 
+	// Leverage tiers: use risk_control.btc_eth_max_leverage for major pairs and
+	// risk_control.altcoin_max_leverage for the rest (each falls back to Leverage when unset).
+	// Backtests always size with the backtest's own BTC/ETH and altcoin leverage instead.
+	EnableLeverageTiers bool     `json:"enable_leverage_tiers"`
+	MajorSymbols        []string `json:"major_symbols,omitempty"` // symbols using the BTC/ETH tier, default BTCUSDT and ETHUSDT
+
 	// Position limits
-	MaxSameDirectionPositions int `json:"max_same_direction_positions"` // max positions in same direction, default 2
+	MaxSameDirectionPositions int     `json:"max_same_direction_positions"` // max positions in same direction, default 2
+	MaxMarginUsage            float64 `json:"max_margin_usage"`             // max total margin as a fraction of equity, 0 = use risk_control.max_margin_usage
+
+	// Account drawdown kill-switch: halt new entries while equity is too far below its peak (exits continue)
+	MaxAccountDrawdownPct float64 `json:"max_account_drawdown_pct"` // drawdown from peak equity (%) that halts entries, 0 = disabled
+	DrawdownResumePct     float64 `json:"drawdown_resume_pct"`      // entries resume once drawdown recovers below this (%), default half the limit
+
+	// Minimum edge: the price move to trailing TP1 (TP1 / leverage) must cover round-trip fees and slippage by this multiple
+	MinEdgeCostMultiple float64 `json:"min_edge_cost_multiple"` // default 1.5
+
+	// Correlation filter: limit same-direction positions whose recent returns move together
+	MaxCorrelatedPositions int     `json:"max_correlated_positions"` // max same-direction positions correlated above the threshold, 0 = disabled
+	CorrelationThreshold   float64 `json:"correlation_threshold"`    // return correlation treated as correlated, default 0.8
+	CorrelationWindow      int     `json:"correlation_window"`       // bars of returns used for the correlation, default 50
 
 	// Hard stop loss (highest priority)
 	HardStopLossPct float64 `json:"hard_stop_loss_pct"` // hard stop loss percentage, default 3.0 (means -3%)
 
+	// ATR-based dynamic stop (falls back to HardStopLossPct when ATR is unavailable)
+	EnableATRStop   bool    `json:"enable_atr_stop"`   // stop distance = ATR × ATRStopMultiple
+	ATRStopMultiple float64 `json:"atr_stop_multiple"` // ATR multiple for stop distance, default 2.0
+
+	// Fixed take-profit target: close the (remaining) position once profit reaches it, before trailing exits
+	FixedTakeProfitPct float64 `json:"fixed_take_profit_pct"` // profit percentage that closes the position, 0 = disabled
+
+	// Intrabar order: which exit filled first when one bar's range spans both the pending stop and the fixed take-profit
+	IntrabarOrder string `json:"intrabar_order,omitempty"` // one of the IntrabarOrder* constants, default pessimistic
+
 	// Trailing take profit tiers
 	TrailingTP1Pct    float64 `json:"trailing_tp1_pct"`    // profit threshold for tier 1, default 2.0
 	TrailingTP1Lock   float64 `json:"trailing_tp1_lock"`   // lock profit for tier 1, default 0.5
@@ -602,9 +708,33 @@ type BaselineRiskManagement struct {
 	TrailingTP3Pct    float64 `json:"trailing_tp3_pct"`    // profit threshold for tier 3, default 6.0
 	TrailingTP3Lock   float64 `json:"trailing_tp3_lock"`   // lock profit for tier 3, default 1.5
 
+	// Time-based exit
+	MaxHoldingBars int `json:"max_holding_bars"` // force close after this many decision cycles, 0 = disabled
+
+	// Breakeven stop: once profit reaches the trigger, move the stop to entry ± offset (covers fees)
+	BreakevenTriggerPct float64 `json:"breakeven_trigger_pct"` // profit threshold to arm the breakeven stop, 0 = disabled
+	BreakevenOffsetPct  float64 `json:"breakeven_offset_pct"`  // offset from entry price (%), default 0.1
+
+	// Partial scale-out: close ScaleOutFraction of the remaining position at TP1 and again at TP2
+	EnableScaleOut   bool    `json:"enable_scale_out"`   // enable two-stage partial take-profit
+	ScaleOutFraction float64 `json:"scale_out_fraction"` // fraction of remaining position closed per stage, default 0.5
+
+	// Pyramiding: add to a winning position when entry signals fire again
+	EnablePyramiding    bool    `json:"enable_pyramiding"`      // allow adds to an existing position in profit
+	MaxPyramidEntries   int     `json:"max_pyramid_entries"`    // max adds per position, default 2
+	PyramidMinProfitPct float64 `json:"pyramid_min_profit_pct"` // min favorable move since the last entry or add (%), default 2.0
+	PyramidSizeFraction float64 `json:"pyramid_size_fraction"`  // add size as a fraction of a normal entry, default 0.5
+
 	// Trailing stop loss
 	TrailingSL1Pct    float64 `json:"trailing_sl1_pct"`    // profit threshold for trailing SL tier 1, default 3.0
 	TrailingSL1Lock   float64 `json:"trailing_sl1_lock"`   // lock profit for trailing SL tier 1, default 1.0
 	TrailingSL2Pct    float64 `json:"trailing_sl2_pct"`    // profit threshold for trailing SL tier 2, default 5.0
 	TrailingSL2Lock   float64 `json:"trailing_sl2_lock"`   // lock profit for trailing SL tier 2, default 1.5
 }
+
+// Intrabar order assumptions for BaselineRiskManagement.IntrabarOrder
+const (
+	IntrabarOrderPessimistic  = "pessimistic"   // the stop filled first
+	IntrabarOrderOptimistic   = "optimistic"    // the take-profit target filled first
+	IntrabarOrderOpenRelative = "open_relative" // the level closer to the bar open filled first
+)
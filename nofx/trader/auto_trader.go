@@ -1112,8 +1112,14 @@ func (at *AutoTrader) executeCloseLongWithRecord(decision *decision.Decision, ac
 		}
 	}
 
-	// Close position
-	order, err := at.trader.CloseLong(decision.Symbol, 0) // 0 = close all
+	// Close position (partial close when CloseFraction is set)
+	closeQty := 0.0 // 0 = close all
+	if decision.CloseFraction > 0 && decision.CloseFraction < 1 && quantity > 0 {
+		closeQty = quantity * decision.CloseFraction
+		quantity = closeQty
+		logger.Infof("  ✂️ Partial close: %.0f%% of position (%.6f)", decision.CloseFraction*100, closeQty)
+	}
+	order, err := at.trader.CloseLong(decision.Symbol, closeQty)
 	if err != nil {
 		return err
 	}
@@ -1159,8 +1165,14 @@ func (at *AutoTrader) executeCloseShortWithRecord(decision *decision.Decision, a
 		}
 	}
 
-	// Close position
-	order, err := at.trader.CloseShort(decision.Symbol, 0) // 0 = close all
+	// Close position (partial close when CloseFraction is set)
+	closeQty := 0.0 // 0 = close all
+	if decision.CloseFraction > 0 && decision.CloseFraction < 1 && quantity > 0 {
+		closeQty = quantity * decision.CloseFraction
+		quantity = closeQty
+		logger.Infof("  ✂️ Partial close: %.0f%% of position (%.6f)", decision.CloseFraction*100, closeQty)
+	}
+	order, err := at.trader.CloseShort(decision.Symbol, closeQty)
 	if err != nil {
 		return err
 	}
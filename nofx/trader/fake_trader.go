@@ -0,0 +1,462 @@
+package trader
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FakeTrader In-memory Trader for deterministic strategy and integration tests
+// Market orders fill immediately at the price set with SetPrice, margin is notional / leverage,
+// fees are charged on both sides at a flat rate, and stop-loss / take-profit orders trigger
+// when SetPrice crosses them. It never talks to an exchange.
+type FakeTrader struct {
+	mu sync.Mutex
+
+	walletBalance float64 // Initial balance + realized PnL - fees
+	feeRate       float64 // Fee rate per side (e.g. 0.0004 = 4 bps)
+
+	prices     map[string]float64
+	leverage   map[string]int
+	positions  map[string]*fakePosition // symbol_side -> position
+	orders     map[string]map[string]interface{}
+	closedPnL  []ClosedPnLRecord
+	orderSeq   int
+	clock      func() time.Time
+	marginMode map[string]bool // symbol -> cross margin
+}
+
+// fakePosition An open position held by FakeTrader
+type fakePosition struct {
+	symbol     string
+	side       string // "long" / "short"
+	quantity   float64
+	entryPrice float64
+	leverage   int
+	openedAt   time.Time
+	stopLoss   float64 // 0 = not set
+	takeProfit float64 // 0 = not set
+}
+
+// NewFakeTrader Create a FakeTrader holding initialBalance USDT
+func NewFakeTrader(initialBalance float64) *FakeTrader {
+	return &FakeTrader{
+		walletBalance: initialBalance,
+		prices:        make(map[string]float64),
+		leverage:      make(map[string]int),
+		positions:     make(map[string]*fakePosition),
+		orders:        make(map[string]map[string]interface{}),
+		marginMode:    make(map[string]bool),
+		clock:         time.Now,
+	}
+}
+
+// SetFeeRate Set the fee rate charged on every fill (fraction of notional)
+func (f *FakeTrader) SetFeeRate(rate float64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.feeRate = rate
+}
+
+// SetClock Replace the time source used for order and PnL timestamps
+func (f *FakeTrader) SetClock(clock func() time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.clock = clock
+}
+
+// SetPrice Move the market price of symbol; stop-loss and take-profit orders crossed by the
+// new price are filled at their trigger price
+func (f *FakeTrader) SetPrice(symbol string, price float64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.prices[symbol] = price
+
+	for _, side := range []string{"long", "short"} {
+		pos, ok := f.positions[fakePositionKey(symbol, side)]
+		if !ok {
+			continue
+		}
+		switch {
+		case pos.stopLoss > 0 && ((side == "long" && price <= pos.stopLoss) || (side == "short" && price >= pos.stopLoss)):
+			f.closeLocked(pos, pos.quantity, pos.stopLoss, "stop_loss")
+		case pos.takeProfit > 0 && ((side == "long" && price >= pos.takeProfit) || (side == "short" && price <= pos.takeProfit)):
+			f.closeLocked(pos, pos.quantity, pos.takeProfit, "take_profit")
+		}
+	}
+}
+
+// GetBalance Get account balance
+func (f *FakeTrader) GetBalance() (map[string]interface{}, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var unrealized, marginUsed float64
+	for _, pos := range f.positions {
+		unrealized += f.unrealizedLocked(pos)
+		marginUsed += pos.quantity * pos.entryPrice / float64(pos.leverage)
+	}
+	equity := f.walletBalance + unrealized
+
+	return map[string]interface{}{
+		"totalEquity":           equity,
+		"totalWalletBalance":    f.walletBalance,
+		"availableBalance":      equity - marginUsed,
+		"totalUnrealizedProfit": unrealized,
+		"balance":               equity,
+	}, nil
+}
+
+// GetPositions Get all positions, ordered by symbol and side
+func (f *FakeTrader) GetPositions() ([]map[string]interface{}, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	keys := make([]string, 0, len(f.positions))
+	for key := range f.positions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	positions := make([]map[string]interface{}, 0, len(keys))
+	for _, key := range keys {
+		pos := f.positions[key]
+		positionAmt := pos.quantity
+		if pos.side == "short" {
+			positionAmt = -pos.quantity
+		}
+		marginType := "isolated"
+		if cross, ok := f.marginMode[pos.symbol]; !ok || cross {
+			marginType = "crossed"
+		}
+		unrealized := f.unrealizedLocked(pos)
+		positions = append(positions, map[string]interface{}{
+			"symbol":           pos.symbol,
+			"side":             pos.side,
+			"positionAmt":      positionAmt,
+			"entryPrice":       pos.entryPrice,
+			"markPrice":        f.markPriceLocked(pos),
+			"unRealizedProfit": unrealized,
+			"unrealizedPnL":    unrealized,
+			"liquidationPrice": fakeLiquidationPrice(pos),
+			"leverage":         float64(pos.leverage),
+			"margin_type":      marginType,
+			"stop_loss":        pos.stopLoss,
+			"take_profit":      pos.takeProfit,
+		})
+	}
+	return positions, nil
+}
+
+// OpenLong Open long position
+func (f *FakeTrader) OpenLong(symbol string, quantity float64, leverage int) (map[string]interface{}, error) {
+	return f.open(symbol, "long", quantity, leverage)
+}
+
+// OpenShort Open short position
+func (f *FakeTrader) OpenShort(symbol string, quantity float64, leverage int) (map[string]interface{}, error) {
+	return f.open(symbol, "short", quantity, leverage)
+}
+
+// CloseLong Close long position (quantity=0 means close all)
+func (f *FakeTrader) CloseLong(symbol string, quantity float64) (map[string]interface{}, error) {
+	return f.close(symbol, "long", quantity)
+}
+
+// CloseShort Close short position (quantity=0 means close all)
+func (f *FakeTrader) CloseShort(symbol string, quantity float64) (map[string]interface{}, error) {
+	return f.close(symbol, "short", quantity)
+}
+
+// SetLeverage Set leverage used by later opens that pass leverage <= 0
+func (f *FakeTrader) SetLeverage(symbol string, leverage int) error {
+	if leverage <= 0 {
+		return fmt.Errorf("invalid leverage %d", leverage)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.leverage[symbol] = leverage
+	return nil
+}
+
+// SetMarginMode Set position mode (true=cross margin, false=isolated margin)
+func (f *FakeTrader) SetMarginMode(symbol string, isCrossMargin bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.marginMode[symbol] = isCrossMargin
+	return nil
+}
+
+// GetMarketPrice Get the price last set with SetPrice
+func (f *FakeTrader) GetMarketPrice(symbol string) (float64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	price, ok := f.prices[symbol]
+	if !ok {
+		return 0, fmt.Errorf("no price for %s", symbol)
+	}
+	return price, nil
+}
+
+// SetStopLoss Set stop-loss order on an open position
+func (f *FakeTrader) SetStopLoss(symbol string, positionSide string, quantity, stopPrice float64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	pos, err := f.positionLocked(symbol, positionSide)
+	if err != nil {
+		return err
+	}
+	pos.stopLoss = stopPrice
+	return nil
+}
+
+// SetTakeProfit Set take-profit order on an open position
+func (f *FakeTrader) SetTakeProfit(symbol string, positionSide string, quantity, takeProfitPrice float64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	pos, err := f.positionLocked(symbol, positionSide)
+	if err != nil {
+		return err
+	}
+	pos.takeProfit = takeProfitPrice
+	return nil
+}
+
+// CancelStopLossOrders Cancel only stop-loss orders
+func (f *FakeTrader) CancelStopLossOrders(symbol string) error {
+	f.clearStops(symbol, true, false)
+	return nil
+}
+
+// CancelTakeProfitOrders Cancel only take-profit orders
+func (f *FakeTrader) CancelTakeProfitOrders(symbol string) error {
+	f.clearStops(symbol, false, true)
+	return nil
+}
+
+// CancelAllOrders Cancel all pending orders for this symbol
+func (f *FakeTrader) CancelAllOrders(symbol string) error {
+	f.clearStops(symbol, true, true)
+	return nil
+}
+
+// CancelStopOrders Cancel stop-loss/take-profit orders for this symbol
+func (f *FakeTrader) CancelStopOrders(symbol string) error {
+	f.clearStops(symbol, true, true)
+	return nil
+}
+
+// FormatQuantity Format quantity to correct precision
+func (f *FakeTrader) FormatQuantity(symbol string, quantity float64) (string, error) {
+	return strconv.FormatFloat(quantity, 'f', -1, 64), nil
+}
+
+// GetOrderStatus Get order status
+func (f *FakeTrader) GetOrderStatus(symbol string, orderID string) (map[string]interface{}, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	order, ok := f.orders[orderID]
+	if !ok || order["symbol"] != symbol {
+		return nil, fmt.Errorf("order %s not found", orderID)
+	}
+	status := make(map[string]interface{}, len(order))
+	for k, v := range order {
+		status[k] = v
+	}
+	return status, nil
+}
+
+// GetClosedPnL Get closed position records since startTime, at most limit (most recent kept)
+func (f *FakeTrader) GetClosedPnL(startTime time.Time, limit int) ([]ClosedPnLRecord, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var records []ClosedPnLRecord
+	for _, record := range f.closedPnL {
+		if !record.ExitTime.Before(startTime) {
+			records = append(records, record)
+		}
+	}
+	if limit > 0 && len(records) > limit {
+		records = records[len(records)-limit:]
+	}
+	return records, nil
+}
+
+// open Fill a market open at the current price, adding to an existing position of the same side
+func (f *FakeTrader) open(symbol, side string, quantity float64, leverage int) (map[string]interface{}, error) {
+	if quantity <= 0 {
+		return nil, fmt.Errorf("invalid quantity %v", quantity)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	price, ok := f.prices[symbol]
+	if !ok || price <= 0 {
+		return nil, fmt.Errorf("no price for %s", symbol)
+	}
+	if leverage <= 0 {
+		leverage = f.leverage[symbol]
+	}
+	if leverage <= 0 {
+		leverage = 1
+	}
+
+	// Margin check against available balance (equity minus margin already in use)
+	notional := quantity * price
+	fee := notional * f.feeRate
+	var unrealized, marginUsed float64
+	for _, pos := range f.positions {
+		unrealized += f.unrealizedLocked(pos)
+		marginUsed += pos.quantity * pos.entryPrice / float64(pos.leverage)
+	}
+	available := f.walletBalance + unrealized - marginUsed
+	if required := notional/float64(leverage) + fee; required > available {
+		return nil, fmt.Errorf("insufficient margin: need %.2f, available %.2f", required, available)
+	}
+
+	key := fakePositionKey(symbol, side)
+	pos, exists := f.positions[key]
+	if !exists {
+		pos = &fakePosition{symbol: symbol, side: side, openedAt: f.clock()}
+		f.positions[key] = pos
+	}
+	pos.entryPrice = (pos.entryPrice*pos.quantity + price*quantity) / (pos.quantity + quantity)
+	pos.quantity += quantity
+	pos.leverage = leverage
+	f.walletBalance -= fee
+
+	return f.recordOrderLocked(symbol, price, quantity, fee), nil
+}
+
+// close Fill a market close at the current price
+func (f *FakeTrader) close(symbol, side string, quantity float64) (map[string]interface{}, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	pos, ok := f.positions[fakePositionKey(symbol, side)]
+	if !ok {
+		return nil, fmt.Errorf("no %s position for %s", side, symbol)
+	}
+	price, ok := f.prices[symbol]
+	if !ok || price <= 0 {
+		return nil, fmt.Errorf("no price for %s", symbol)
+	}
+	if quantity <= 0 || quantity > pos.quantity {
+		quantity = pos.quantity
+	}
+	return f.closeLocked(pos, quantity, price, "manual"), nil
+}
+
+// closeLocked Realize PnL on quantity of pos filled at price; the caller holds f.mu
+func (f *FakeTrader) closeLocked(pos *fakePosition, quantity, price float64, closeType string) map[string]interface{} {
+	pnl := (price - pos.entryPrice) * quantity
+	if pos.side == "short" {
+		pnl = -pnl
+	}
+	fee := quantity * price * f.feeRate
+	f.walletBalance += pnl - fee
+
+	pos.quantity -= quantity
+	if pos.quantity <= 1e-12 {
+		delete(f.positions, fakePositionKey(pos.symbol, pos.side))
+	}
+
+	order := f.recordOrderLocked(pos.symbol, price, quantity, fee)
+	f.closedPnL = append(f.closedPnL, ClosedPnLRecord{
+		Symbol:      pos.symbol,
+		Side:        pos.side,
+		EntryPrice:  pos.entryPrice,
+		ExitPrice:   price,
+		Quantity:    quantity,
+		RealizedPnL: pnl,
+		Fee:         fee,
+		Leverage:    pos.leverage,
+		EntryTime:   pos.openedAt,
+		ExitTime:    f.clock(),
+		OrderID:     order["orderId"].(string),
+		CloseType:   closeType,
+	})
+	return order
+}
+
+// recordOrderLocked Store a filled order for GetOrderStatus and return it; the caller holds f.mu
+func (f *FakeTrader) recordOrderLocked(symbol string, price, quantity, fee float64) map[string]interface{} {
+	f.orderSeq++
+	orderID := fmt.Sprintf("fake-%d", f.orderSeq)
+	f.orders[orderID] = map[string]interface{}{
+		"orderId":     orderID,
+		"symbol":      symbol,
+		"status":      "FILLED",
+		"avgPrice":    price,
+		"executedQty": quantity,
+		"commission":  fee,
+	}
+	return map[string]interface{}{
+		"orderId":     orderID,
+		"symbol":      symbol,
+		"status":      "FILLED",
+		"avgPrice":    price,
+		"executedQty": quantity,
+	}
+}
+
+// clearStops Remove stop-loss and/or take-profit orders of both sides of symbol
+func (f *FakeTrader) clearStops(symbol string, stopLoss, takeProfit bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, side := range []string{"long", "short"} {
+		if pos, ok := f.positions[fakePositionKey(symbol, side)]; ok {
+			if stopLoss {
+				pos.stopLoss = 0
+			}
+			if takeProfit {
+				pos.takeProfit = 0
+			}
+		}
+	}
+}
+
+// positionLocked Look up the position for an exchange-style side ("LONG"/"SHORT"); the caller holds f.mu
+func (f *FakeTrader) positionLocked(symbol, positionSide string) (*fakePosition, error) {
+	side := strings.ToLower(strings.TrimSpace(positionSide))
+	pos, ok := f.positions[fakePositionKey(symbol, side)]
+	if !ok {
+		return nil, fmt.Errorf("no %s position for %s", side, symbol)
+	}
+	return pos, nil
+}
+
+// markPriceLocked Current price of the position, falling back to its entry price
+func (f *FakeTrader) markPriceLocked(pos *fakePosition) float64 {
+	if price, ok := f.prices[pos.symbol]; ok && price > 0 {
+		return price
+	}
+	return pos.entryPrice
+}
+
+// unrealizedLocked Unrealized PnL of the position at the current price
+func (f *FakeTrader) unrealizedLocked(pos *fakePosition) float64 {
+	pnl := (f.markPriceLocked(pos) - pos.entryPrice) * pos.quantity
+	if pos.side == "short" {
+		return -pnl
+	}
+	return pnl
+}
+
+// fakeLiquidationPrice Price at which the position's margin is fully lost (maintenance margin ignored)
+func fakeLiquidationPrice(pos *fakePosition) float64 {
+	move := pos.entryPrice / float64(pos.leverage)
+	if pos.side == "short" {
+		return pos.entryPrice + move
+	}
+	return pos.entryPrice - move
+}
+
+func fakePositionKey(symbol, side string) string {
+	return symbol + "_" + side
+}
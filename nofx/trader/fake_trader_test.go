@@ -0,0 +1,129 @@
+package trader
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	_ Trader = (*FakeTrader)(nil)
+	_ Trader = (*WeexTrader)(nil)
+)
+
+func newTestFakeTrader() *FakeTrader {
+	fake := NewFakeTrader(1000)
+	fake.SetFeeRate(0.001)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	fake.SetClock(func() time.Time {
+		now = now.Add(time.Minute)
+		return now
+	})
+	return fake
+}
+
+func TestFakeTraderLongOpenCloseCycle(t *testing.T) {
+	fake := newTestFakeTrader()
+	fake.SetPrice("BTCUSDT", 100)
+
+	order, err := fake.OpenLong("BTCUSDT", 10, 5)
+	require.NoError(t, err)
+	assert.Equal(t, "FILLED", order["status"])
+
+	// 1000 notional at 5x locks 200 margin; the 1 USDT open fee is paid from the wallet
+	balance, err := fake.GetBalance()
+	require.NoError(t, err)
+	assert.InDelta(t, 999, balance["totalWalletBalance"], 1e-9)
+	assert.InDelta(t, 799, balance["availableBalance"], 1e-9)
+
+	fake.SetPrice("BTCUSDT", 110)
+	positions, err := fake.GetPositions()
+	require.NoError(t, err)
+	require.Len(t, positions, 1)
+	assert.Equal(t, "long", positions[0]["side"])
+	assert.InDelta(t, 10, positions[0]["positionAmt"], 1e-9)
+	assert.InDelta(t, 100, positions[0]["unrealizedPnL"], 1e-9)
+	assert.InDelta(t, 80, positions[0]["liquidationPrice"], 1e-9)
+
+	// Partial close realizes half the gain, the rest closes with quantity 0
+	_, err = fake.CloseLong("BTCUSDT", 5)
+	require.NoError(t, err)
+	closeOrder, err := fake.CloseLong("BTCUSDT", 0)
+	require.NoError(t, err)
+
+	positions, err = fake.GetPositions()
+	require.NoError(t, err)
+	assert.Empty(t, positions)
+
+	// +100 PnL, fees 1 (open) + 0.55 + 0.55 (closes)
+	balance, err = fake.GetBalance()
+	require.NoError(t, err)
+	assert.InDelta(t, 1097.9, balance["totalEquity"], 1e-9)
+	assert.InDelta(t, 1097.9, balance["availableBalance"], 1e-9)
+
+	status, err := fake.GetOrderStatus("BTCUSDT", closeOrder["orderId"].(string))
+	require.NoError(t, err)
+	assert.Equal(t, "FILLED", status["status"])
+	assert.InDelta(t, 110, status["avgPrice"], 1e-9)
+
+	records, err := fake.GetClosedPnL(time.Time{}, 10)
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.InDelta(t, 50, records[1].RealizedPnL, 1e-9)
+	assert.Equal(t, "manual", records[1].CloseType)
+}
+
+func TestFakeTraderShortStopLossTriggers(t *testing.T) {
+	fake := newTestFakeTrader()
+	fake.SetPrice("ETHUSDT", 2000)
+
+	_, err := fake.OpenShort("ETHUSDT", 1, 10)
+	require.NoError(t, err)
+	require.NoError(t, fake.SetStopLoss("ETHUSDT", "SHORT", 1, 2100))
+	require.NoError(t, fake.SetTakeProfit("ETHUSDT", "SHORT", 1, 1800))
+
+	fake.SetPrice("ETHUSDT", 2050)
+	positions, err := fake.GetPositions()
+	require.NoError(t, err)
+	require.Len(t, positions, 1)
+	assert.InDelta(t, -1, positions[0]["positionAmt"], 1e-9)
+	assert.InDelta(t, -50, positions[0]["unrealizedPnL"], 1e-9)
+
+	// The wick through the stop fills at the stop price
+	fake.SetPrice("ETHUSDT", 2150)
+	positions, err = fake.GetPositions()
+	require.NoError(t, err)
+	assert.Empty(t, positions)
+
+	records, err := fake.GetClosedPnL(time.Time{}, 0)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, "stop_loss", records[0].CloseType)
+	assert.InDelta(t, 2100, records[0].ExitPrice, 1e-9)
+	assert.InDelta(t, -100, records[0].RealizedPnL, 1e-9)
+}
+
+func TestFakeTraderRejectsInvalidOrders(t *testing.T) {
+	fake := newTestFakeTrader()
+
+	_, err := fake.OpenLong("BTCUSDT", 1, 5)
+	assert.Error(t, err, "no price set")
+
+	fake.SetPrice("BTCUSDT", 100)
+	_, err = fake.OpenLong("BTCUSDT", 100, 5)
+	assert.ErrorContains(t, err, "insufficient margin")
+
+	_, err = fake.CloseShort("BTCUSDT", 0)
+	assert.Error(t, err, "no position to close")
+	assert.Error(t, fake.SetStopLoss("BTCUSDT", "LONG", 1, 90))
+
+	// SetLeverage applies to opens that pass no leverage
+	require.NoError(t, fake.SetLeverage("BTCUSDT", 20))
+	_, err = fake.OpenLong("BTCUSDT", 100, 0)
+	require.NoError(t, err)
+	positions, err := fake.GetPositions()
+	require.NoError(t, err)
+	assert.InDelta(t, 20, positions[0]["leverage"], 1e-9)
+}
@@ -19,6 +19,14 @@ type ClosedPnLRecord struct {
 	ExchangeID   string    // Exchange-specific position ID
 }
 
+// HoldingDuration Time the position was held (zero when the entry time is unknown)
+func (r ClosedPnLRecord) HoldingDuration() time.Duration {
+	if r.EntryTime.IsZero() || r.ExitTime.Before(r.EntryTime) {
+		return 0
+	}
+	return r.ExitTime.Sub(r.EntryTime)
+}
+
 // TradeRecord represents a single trade/fill from exchange
 // Used for reconstructing position history with unified algorithm
 type TradeRecord struct {
@@ -26,6 +34,7 @@ type TradeRecord struct {
 	Symbol       string    // Trading pair (e.g., "BTCUSDT")
 	Side         string    // "BUY" or "SELL"
 	PositionSide string    // "LONG", "SHORT", or "BOTH" (for one-way mode)
+	Action       string    // "open" or "close" when the exchange reports it; empty = inferred from RealizedPnL
 	Price        float64   // Execution price
 	Quantity     float64   // Executed quantity
 	RealizedPnL  float64   // Realized PnL (non-zero for closing trades)
@@ -66,7 +66,7 @@ func RebuildPositionsFromTrades(trades []TradeRecord) []ClosedPnLRecord {
 		}
 		state := positions[key]
 
-		if trade.RealizedPnL == 0 {
+		if !isClosingTrade(trade) {
 			// Opening trade: add to open trades list
 			state.OpenTrades = append(state.OpenTrades, openTradeEntry{
 				Price:    trade.Price,
@@ -98,8 +98,8 @@ func determinePositionSide(trade TradeRecord) string {
 		return "short"
 	}
 
-	// One-way mode (BOTH or empty): determine from trade direction and RealizedPnL
-	if trade.RealizedPnL == 0 {
+	// One-way mode (BOTH or empty): determine from trade direction and whether it closes
+	if !isClosingTrade(trade) {
 		// Opening trade
 		if trade.Side == "BUY" || trade.Side == "Buy" {
 			return "long"
@@ -118,6 +118,18 @@ func determinePositionSide(trade TradeRecord) string {
 	return ""
 }
 
+// isClosingTrade reports whether a trade reduces a position: the exchange-reported Action when
+// present, otherwise a non-zero RealizedPnL (which misses closes at exactly breakeven)
+func isClosingTrade(trade TradeRecord) bool {
+	switch trade.Action {
+	case "close":
+		return true
+	case "open":
+		return false
+	}
+	return trade.RealizedPnL != 0
+}
+
 // buildClosedPosition builds a closed position record from a closing trade
 func buildClosedPosition(trade TradeRecord, side string, state *positionState) *ClosedPnLRecord {
 	var entryPrice float64
@@ -193,3 +205,199 @@ func buildClosedPosition(trade TradeRecord, side string, state *positionState) *
 		CloseType:   "unknown",
 	}
 }
+
+// roundTrip accumulates the fills of one position from its first opening fill until it is flat
+type roundTrip struct {
+	symbol string
+	side   string
+
+	openedQty   float64 // Total quantity opened (for the average entry price)
+	openedValue float64
+	openFee     float64
+	remaining   float64 // Quantity still open
+	entryTime   time.Time
+
+	closedQty   float64
+	closedValue float64
+	closeFee    float64
+	realizedPnL float64
+	exitTime    time.Time
+	lastTradeID string
+}
+
+// AggregateRoundTrips pairs opening and closing fills per symbol+side into round-trip trades
+//
+// Unlike RebuildPositionsFromTrades, which emits one record per closing fill, a position closed
+// in several pieces yields a single record once it is flat again: entry and exit prices are the
+// quantity-weighted averages of the opening and closing fills, EntryTime is the first opening
+// fill and ExitTime the last closing fill. Positions that are only partly closed at the end of
+// the history are emitted with the quantity closed so far. Closing fills without a known
+// opening fill (history starts mid-position) fall back to an entry price derived from their PnL.
+// Records are returned ordered by exit time.
+func AggregateRoundTrips(trades []TradeRecord) []ClosedPnLRecord {
+	sorted := make([]TradeRecord, len(trades))
+	copy(sorted, trades)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Time.Before(sorted[j].Time)
+	})
+
+	const epsilon = 0.00000001
+	trips := make(map[string]*roundTrip)
+	var keys []string // First-seen order, so leftover partial trips are emitted deterministically
+	var records []ClosedPnLRecord
+
+	for _, trade := range sorted {
+		side := determinePositionSide(trade)
+		if side == "" || trade.Quantity <= 0 {
+			continue
+		}
+		key := trade.Symbol + "_" + side
+		trip := trips[key]
+		if trip == nil {
+			trip = &roundTrip{symbol: trade.Symbol, side: side}
+			trips[key] = trip
+			keys = append(keys, key)
+		}
+
+		if !isClosingTrade(trade) {
+			if trip.openedQty == 0 {
+				trip.entryTime = trade.Time
+			}
+			trip.openedQty += trade.Quantity
+			trip.openedValue += trade.Price * trade.Quantity
+			trip.openFee += trade.Fee
+			trip.remaining += trade.Quantity
+			continue
+		}
+
+		if trip.remaining <= epsilon {
+			// No known opening fill: report this fill on its own
+			if record := buildClosedPosition(trade, side, &positionState{}); record != nil {
+				records = append(records, *record)
+			}
+			continue
+		}
+
+		trip.closedQty += trade.Quantity
+		trip.closedValue += trade.Price * trade.Quantity
+		trip.closeFee += trade.Fee
+		trip.realizedPnL += trade.RealizedPnL
+		trip.exitTime = trade.Time
+		trip.lastTradeID = trade.TradeID
+		trip.remaining -= trade.Quantity
+
+		if trip.remaining <= epsilon {
+			records = append(records, trip.record())
+			trips[key] = &roundTrip{symbol: trade.Symbol, side: side}
+		}
+	}
+
+	// Positions still open but partly closed: report what has been realized so far
+	for _, key := range keys {
+		if trip := trips[key]; trip.closedQty > 0 {
+			records = append(records, trip.record())
+		}
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].ExitTime.Before(records[j].ExitTime)
+	})
+	return records
+}
+
+// record converts the accumulated fills into a closed position record; the opening fee is
+// charged in proportion to the quantity closed
+func (rt *roundTrip) record() ClosedPnLRecord {
+	openFee := rt.openFee
+	if rt.closedQty < rt.openedQty {
+		openFee *= rt.closedQty / rt.openedQty
+	}
+	return ClosedPnLRecord{
+		Symbol:      rt.symbol,
+		Side:        rt.side,
+		EntryPrice:  rt.openedValue / rt.openedQty,
+		ExitPrice:   rt.closedValue / rt.closedQty,
+		Quantity:    rt.closedQty,
+		RealizedPnL: rt.realizedPnL,
+		Fee:         rt.closeFee + openFee,
+		EntryTime:   rt.entryTime,
+		ExitTime:    rt.exitTime,
+		OrderID:     rt.lastTradeID,
+		ExchangeID:  rt.lastTradeID,
+		CloseType:   "unknown",
+	}
+}
+
+// PnLTotals sums the closed positions of a PnLSummary
+type PnLTotals struct {
+	Trades   int     // Closed positions
+	Wins     int     // Positions with a positive net PnL
+	Losses   int     // Positions with a negative net PnL
+	GrossPnL float64 // Realized PnL before fees
+	Fees     float64 // Trading fees
+	NetPnL   float64 // GrossPnL - Fees
+}
+
+// PnLSummary aggregates the positions closed in [StartTime, EndTime)
+type PnLSummary struct {
+	PnLTotals
+	StartTime time.Time
+	EndTime   time.Time
+	BySymbol  map[string]PnLTotals
+}
+
+func (t *PnLTotals) add(record ClosedPnLRecord) {
+	net := record.RealizedPnL - record.Fee
+	t.Trades++
+	switch {
+	case net > 0:
+		t.Wins++
+	case net < 0:
+		t.Losses++
+	}
+	t.GrossPnL += record.RealizedPnL
+	t.Fees += record.Fee
+	t.NetPnL += net
+}
+
+// ChargeFillFees replaces the summary's fees with the fees of all the given fills, including
+// opening fills of positions that are still open, and recomputes NetPnL. Summaries of adjacent
+// windows then add up to the fees actually paid, as every fee is counted in the window of its
+// fill. Win and loss counts keep using each position's own fees. Symbols with fills but no
+// closed position appear in BySymbol with zero trades.
+func (s *PnLSummary) ChargeFillFees(trades []TradeRecord) {
+	s.Fees = 0
+	for symbol, totals := range s.BySymbol {
+		totals.Fees = 0
+		s.BySymbol[symbol] = totals
+	}
+	for _, trade := range trades {
+		s.Fees += trade.Fee
+		totals := s.BySymbol[trade.Symbol]
+		totals.Fees += trade.Fee
+		s.BySymbol[trade.Symbol] = totals
+	}
+
+	s.NetPnL = s.GrossPnL - s.Fees
+	for symbol, totals := range s.BySymbol {
+		totals.NetPnL = totals.GrossPnL - totals.Fees
+		s.BySymbol[symbol] = totals
+	}
+}
+
+// SummarizePnL totals closed positions overall and per symbol. A position counts as a win or
+// loss by its PnL after fees; breakeven positions count as neither.
+func SummarizePnL(records []ClosedPnLRecord, startTime, endTime time.Time) *PnLSummary {
+	summary := &PnLSummary{
+		StartTime: startTime,
+		EndTime:   endTime,
+		BySymbol:  make(map[string]PnLTotals),
+	}
+	for _, record := range records {
+		summary.add(record)
+		symbolTotals := summary.BySymbol[record.Symbol]
+		symbolTotals.add(record)
+		summary.BySymbol[record.Symbol] = symbolTotals
+	}
+	return summary
+}
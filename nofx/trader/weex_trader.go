@@ -1,18 +1,22 @@
 package trader
 
 import (
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"math"
 	"net/http"
 	"nofx/logger"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -41,37 +45,199 @@ type WeexTrader struct {
 	marginModeCache      map[string]int
 	marginModeCacheMutex sync.RWMutex
 
+	// 交易对杠杆与保证金模式缓存 (symbol -> 查询结果)，短TTL
+	leverageMarginCache      map[string]*weexLeverageMarginEntry
+	leverageMarginCacheMutex sync.RWMutex
+	leverageMarginCacheTTL   time.Duration
+
+	// 交易对手续费率缓存 (symbol -> maker/taker费率)
+	feeRateCache      map[string]*weexFeeRateEntry
+	feeRateCacheMutex sync.RWMutex
+	feeRateCacheTTL   time.Duration
+
 	// 待设置的止盈止损价格 (symbol -> price)
 	// WEEX需要在开仓时直接设置止盈止损，而不是开仓后单独设置
 	pendingStopLoss      map[string]float64
 	pendingTakeProfit    map[string]float64
 	pendingPricesMutex   sync.RWMutex
 
+	// 近期开仓意图 (symbol|side -> 开仓时间)，拦截窗口内的重复开仓（如超时重试导致的二次下单）
+	recentOpens      map[string]time.Time
+	recentOpensMutex sync.Mutex
+	openDedupWindow  time.Duration
+
+	// 止盈止损计划委托的默认触发价格类型
+	triggerPriceType WeexTriggerPriceType
+
 	// 缓存时长（15秒）
 	cacheDuration time.Duration
 
+	// 批量获取行情时的最大并发数
+	priceFetchConcurrency int
+
 	// HTTP 客户端
 	httpClient *http.Client
+
+	// 日志输出（默认使用全局 logger）
+	logger WeexLogger
+
+	// 最小名义价值下限（USDT），0 表示只使用合约/默认值
+	minNotionalFloor float64
+
+	// 模拟模式：写操作（下单、撤单、改杠杆等）只记录不发送，返回合成的成功响应
+	dryRun    bool
+	dryRunSeq atomic.Uint64
+
+	// 服务器时钟偏差（纳秒，服务器时间 - 本地时间），由 Start 启动的时钟同步维护，用于校正签名时间戳
+	clockOffset       atomic.Int64
+	clockSyncInterval time.Duration
+
+	// 后台任务生命周期（Start / Close）
+	lifecycleMutex sync.Mutex
+	stopBackground context.CancelFunc // nil 表示后台任务未启动
+	backgroundWG   sync.WaitGroup
+	closed         bool
+}
+
+// WeexLogger WEEX 交易器的日志接口（Printf 风格，便于接入其他日志库或在测试中记录）
+type WeexLogger interface {
+	Debugf(format string, args ...any)
+	Infof(format string, args ...any)
+	Warnf(format string, args ...any)
+	Errorf(format string, args ...any)
+}
+
+// WeexTriggerPriceType 计划委托（止盈止损）的触发价格类型
+type WeexTriggerPriceType string
+
+const (
+	WeexTriggerLastPrice  WeexTriggerPriceType = "last"  // 最新成交价，易被插针触发
+	WeexTriggerMarkPrice  WeexTriggerPriceType = "mark"  // 标记价格（默认），过滤单一交易所的插针
+	WeexTriggerIndexPrice WeexTriggerPriceType = "index" // 指数价格（多交易所现货加权）
+)
+
+// valid 是否为支持的触发价格类型
+func (p WeexTriggerPriceType) valid() bool {
+	switch p {
+	case WeexTriggerLastPrice, WeexTriggerMarkPrice, WeexTriggerIndexPrice:
+		return true
+	}
+	return false
+}
+
+// WeexOption WEEX 交易器选项
+type WeexOption func(*WeexTrader)
+
+// WithWeexLogger 设置 WEEX 交易器的日志输出（例如为 WEEX 日志加标签或在测试中静默）
+func WithWeexLogger(l WeexLogger) WeexOption {
+	return func(t *WeexTrader) {
+		if l != nil {
+			t.logger = l
+		}
+	}
+}
+
+// WithWeexHTTPClient 使用自定义 HTTP 客户端（代理、连接池、超时等由调用方控制）
+func WithWeexHTTPClient(client *http.Client) WeexOption {
+	return func(t *WeexTrader) {
+		if client != nil {
+			t.httpClient = client
+		}
+	}
+}
+
+// WithWeexTransport 使用自定义 RoundTripper（如代理或自定义 TLS 配置），保留当前客户端的超时设置
+func WithWeexTransport(transport http.RoundTripper) WeexOption {
+	return func(t *WeexTrader) {
+		if transport == nil {
+			return
+		}
+		client := *t.httpClient // 复制一份，避免修改调用方传入的客户端
+		client.Transport = transport
+		t.httpClient = &client
+	}
+}
+
+// WithWeexDryRun 开启模拟模式：所有写请求（POST）在发送前拦截，记录签名后的完整请求并返回
+// 合成的成功响应（假订单ID）；余额、持仓、行情等只读请求仍访问真实接口。
+// 用于在实时行情上验证策略的下单流程而不动用资金
+func WithWeexDryRun(enabled bool) WeexOption {
+	return func(t *WeexTrader) {
+		t.dryRun = enabled
+	}
+}
+
+// WithWeexMinNotionalFloor 设置最小名义价值下限（USDT），高于合约最小值时下单金额须不低于该下限
+func WithWeexMinNotionalFloor(usdt float64) WeexOption {
+	return func(t *WeexTrader) {
+		if usdt > 0 {
+			t.minNotionalFloor = usdt
+		}
+	}
+}
+
+// WithWeexOpenDedupWindow 设置重复开仓拦截窗口：同一交易对同一方向在窗口内只允许开仓一次，0 表示关闭
+func WithWeexOpenDedupWindow(window time.Duration) WeexOption {
+	return func(t *WeexTrader) {
+		if window >= 0 {
+			t.openDedupWindow = window
+		}
+	}
+}
+
+// WithWeexClockSyncInterval 设置 Start 启动的后台时钟同步间隔（默认 10 分钟），非正值忽略
+func WithWeexClockSyncInterval(interval time.Duration) WeexOption {
+	return func(t *WeexTrader) {
+		if interval > 0 {
+			t.clockSyncInterval = interval
+		}
+	}
+}
+
+// WithWeexTriggerPriceType 设置 SetStopLoss/SetTakeProfit 计划委托的默认触发价格类型，无效值忽略
+func WithWeexTriggerPriceType(priceType WeexTriggerPriceType) WeexOption {
+	return func(t *WeexTrader) {
+		if priceType.valid() {
+			t.triggerPriceType = priceType
+		}
+	}
 }
 
 // NewWeexTrader 创建 WEEX 交易器
-func NewWeexTrader(apiKey, secretKey, accessPassphrase string) *WeexTrader {
+// 交易器本身不启动 goroutine；调用 Start 启动后台任务后，丢弃交易器前必须调用 Close
+func NewWeexTrader(apiKey, secretKey, accessPassphrase string, opts ...WeexOption) *WeexTrader {
 	trader := &WeexTrader{
-		apiKey:            apiKey,
-		secretKey:         secretKey,
-		accessPassphrase:  accessPassphrase,
-		baseURL:           "https://api-contract.weex.com",
-		cacheDuration:     15 * time.Second,
-		qtyStepCache:      make(map[string]float64),
-		marginModeCache:   make(map[string]int),
-		pendingStopLoss:   make(map[string]float64),
-		pendingTakeProfit: make(map[string]float64),
+		apiKey:                 apiKey,
+		secretKey:              secretKey,
+		accessPassphrase:       accessPassphrase,
+		baseURL:                "https://api-contract.weex.com",
+		cacheDuration:          15 * time.Second,
+		priceFetchConcurrency:  5,
+		qtyStepCache:           make(map[string]float64),
+		marginModeCache:        make(map[string]int),
+		leverageMarginCache:    make(map[string]*weexLeverageMarginEntry),
+		leverageMarginCacheTTL: 10 * time.Second,
+		feeRateCache:           make(map[string]*weexFeeRateEntry),
+		feeRateCacheTTL:        time.Hour,
+		pendingStopLoss:        make(map[string]float64),
+		pendingTakeProfit:      make(map[string]float64),
+		recentOpens:            make(map[string]time.Time),
+		openDedupWindow:        10 * time.Second,
+		triggerPriceType:       WeexTriggerMarkPrice,
+		clockSyncInterval:      weexClockSyncInterval,
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
+		logger: logger.NewMCPLogger(),
+	}
+	for _, opt := range opts {
+		opt(trader)
 	}
 
-	logger.Infof("🟢 [WEEX] 交易器初始化完成")
+	if trader.dryRun {
+		trader.logger.Infof("🧪 [WEEX] 模拟模式已开启：写操作不会发送到交易所")
+	}
+	trader.logger.Infof("🟢 [WEEX] 交易器初始化完成")
 
 	return trader
 }
@@ -105,14 +271,14 @@ func (t *WeexTrader) sendRequest(method, requestPath, queryString string, body i
 
 // sendRequestRaw 发送 HTTP 请求到 WEEX API 并返回原始响应体
 func (t *WeexTrader) sendRequestRaw(method, requestPath, queryString string, body interface{}) ([]byte, error) {
-	timestamp := fmt.Sprintf("%d", time.Now().UnixMilli())
+	timestamp := fmt.Sprintf("%d", t.now().UnixMilli())
 
 	// 构建请求体
 	var bodyStr string
 	if body != nil {
 		bodyBytes, err := json.Marshal(body)
 		if err != nil {
-			return nil, fmt.Errorf("序列化请求体失败: %w", err)
+			return nil, fmt.Errorf("序列化请求体失败: %v: %w", err, errWeexRequestNotSent)
 		}
 		bodyStr = string(bodyBytes)
 	}
@@ -125,11 +291,11 @@ func (t *WeexTrader) sendRequestRaw(method, requestPath, queryString string, bod
 		h := hmac.New(sha256.New, []byte(t.secretKey))
 		h.Write([]byte(message))
 		signature = base64.StdEncoding.EncodeToString(h.Sum(nil))
-		logger.Debugf("🔍 [WEEX] GET签名消息: %s", message)
+		t.logger.Debugf("🔍 [WEEX] GET签名消息: %s", message)
 	} else {
 		// POST请求：签名消息 = timestamp + method + request_path + query_string + body
 		signature = t.generateSignature(timestamp, method, requestPath, queryString, bodyStr)
-		logger.Debugf("🔍 [WEEX] POST签名消息: %s", timestamp+strings.ToUpper(method)+requestPath+queryString+bodyStr)
+		t.logger.Debugf("🔍 [WEEX] POST签名消息: %s", timestamp+strings.ToUpper(method)+requestPath+queryString+bodyStr)
 	}
 
 	// 构建完整 URL
@@ -146,11 +312,11 @@ func (t *WeexTrader) sendRequestRaw(method, requestPath, queryString string, bod
 	} else if method == "POST" {
 		req, err = http.NewRequest("POST", url, strings.NewReader(bodyStr))
 	} else {
-		return nil, fmt.Errorf("不支持的请求方法: %s", method)
+		return nil, fmt.Errorf("不支持的请求方法 %s: %w", method, errWeexRequestNotSent)
 	}
 
 	if err != nil {
-		return nil, fmt.Errorf("创建请求失败: %w", err)
+		return nil, fmt.Errorf("创建请求失败: %v: %w", err, errWeexRequestNotSent)
 	}
 
 	// 设置请求头
@@ -161,6 +327,13 @@ func (t *WeexTrader) sendRequestRaw(method, requestPath, queryString string, bod
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("locale", "zh-CN")
 
+	// 模拟模式：写请求到此为止，只记录已签名的请求
+	if t.dryRun && method != "GET" {
+		t.logger.Infof("🧪 [WEEX] 模拟模式拦截: %s %s body=%s ACCESS-TIMESTAMP=%s ACCESS-SIGN=%s",
+			method, url, bodyStr, timestamp, signature)
+		return t.dryRunResponse(requestPath, body)
+	}
+
 	// 发送请求
 	resp, err := t.httpClient.Do(req)
 	if err != nil {
@@ -174,14 +347,187 @@ func (t *WeexTrader) sendRequestRaw(method, requestPath, queryString string, bod
 		return nil, fmt.Errorf("读取响应失败: %w", err)
 	}
 
-	// 检查响应状态码
+	// 检查响应状态码：4xx 表示交易所已拒绝请求，5xx 等其它状态无法确定请求是否已处理
 	if resp.StatusCode != http.StatusOK {
+		if resp.StatusCode >= 400 && resp.StatusCode < 500 {
+			return nil, weexRejection(resp.StatusCode, respBody)
+		}
 		return nil, fmt.Errorf("API 返回错误状态码: %d, 响应: %s", resp.StatusCode, string(respBody))
 	}
 
 	return respBody, nil
 }
 
+// dryRunResponse 按接口合成与真实响应同结构的成功响应
+func (t *WeexTrader) dryRunResponse(requestPath string, body interface{}) ([]byte, error) {
+	params, _ := body.(map[string]interface{})
+	var resp map[string]interface{}
+	switch requestPath {
+	case "/capi/v2/order/placeOrder", "/capi/v2/order/plan_order":
+		resp = map[string]interface{}{
+			"order_id":   fmt.Sprintf("DRYRUN-%d", t.dryRunSeq.Add(1)),
+			"client_oid": params["client_oid"],
+		}
+	case "/capi/v2/order/cancel_order", "/capi/v2/order/cancel_plan":
+		resp = map[string]interface{}{
+			"order_id": params["orderId"],
+			"result":   true,
+			"err_msg":  "",
+		}
+	case "/capi/v2/account/leverage":
+		resp = map[string]interface{}{"code": "200", "msg": "success"}
+	case "/capi/v2/order/cancelAllOrders":
+		return t.dryRunCancelAllResponse(params)
+	default:
+		resp = map[string]interface{}{"code": "00000", "msg": "success"}
+	}
+	return json.Marshal(resp)
+}
+
+// dryRunCancelAllResponse 合成 cancelAllOrders 的数组响应：查询当前订单（只读请求照常发出），
+// 逐个报告撤销成功，使批量撤单路径在模拟模式下同样生效
+func (t *WeexTrader) dryRunCancelAllResponse(params map[string]interface{}) ([]byte, error) {
+	target := weexNormalOrders
+	if params["cancelOrderType"] == weexPlanOrders.batchType {
+		target = weexPlanOrders
+	}
+	queryString := fmt.Sprintf("?symbol=%s", weexStringValue(params["symbol"]))
+	respBody, err := t.sendRequestRaw("GET", target.listPath, queryString, nil)
+	if err != nil {
+		return nil, err
+	}
+	orders, err := decodeWeexList(respBody)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]map[string]interface{}, 0, len(orders))
+	for _, order := range orders {
+		results = append(results, map[string]interface{}{"orderId": weexStringValue(order["order_id"]), "success": true})
+	}
+	return json.Marshal(results)
+}
+
+// WeexAPIError WEEX 接口返回的业务错误（{code, msg} 包装格式，或 4xx 状态码的拒绝响应）
+// 出现该错误说明交易所已明确拒绝请求，请求未生效
+type WeexAPIError struct {
+	Code       string
+	Msg        string
+	HTTPStatus int // 非 200 状态码（业务错误随 200 返回时为 0）
+}
+
+func (e *WeexAPIError) Error() string {
+	if e.HTTPStatus != 0 {
+		return fmt.Sprintf("WEEX API 错误 (status=%d, code=%s): %s", e.HTTPStatus, e.Code, e.Msg)
+	}
+	return fmt.Sprintf("WEEX API 错误 (code=%s): %s", e.Code, e.Msg)
+}
+
+// errWeexRequestNotSent 请求在发出前失败（序列化、构造请求等），交易所一定没有收到
+var errWeexRequestNotSent = errors.New("请求未发出")
+
+// weexRejection 把 4xx 响应转换为 *WeexAPIError，响应体有 {code, msg} 时带上交易所的错误码
+func weexRejection(status int, body []byte) *WeexAPIError {
+	apiErr := &WeexAPIError{Code: strconv.Itoa(status), Msg: string(body), HTTPStatus: status}
+	var wrapped struct {
+		Code interface{} `json:"code"`
+		Msg  string      `json:"msg"`
+	}
+	if err := json.Unmarshal(body, &wrapped); err == nil {
+		if code := weexStringValue(wrapped.Code); code != "" {
+			apiErr.Code, apiErr.Msg = code, wrapped.Msg
+		}
+	}
+	return apiErr
+}
+
+// weexOrderResultError 检查下单响应：没有订单ID且 code 表示失败时返回 *WeexAPIError
+func weexOrderResultError(result map[string]interface{}) error {
+	if orderID, _ := result["order_id"].(string); orderID != "" {
+		return nil
+	}
+	switch code := weexStringValue(result["code"]); code {
+	case "", "0", "00000", "200":
+		return nil
+	default:
+		msg, _ := result["msg"].(string)
+		return &WeexAPIError{Code: code, Msg: msg}
+	}
+}
+
+// weexRequestRejected 判断失败的请求是否确定没有生效：请求未发出，或交易所已明确拒绝。
+// 网络错误、响应丢失、5xx 等结果未知的情况返回 false
+func weexRequestRejected(err error) bool {
+	var apiErr *WeexAPIError
+	return errors.As(err, &apiErr) || errors.Is(err, errWeexRequestNotSent)
+}
+
+// ErrWeexOrderNotFound 按 client_oid 查找时没有对应的挂单（未创建，或已成交/已撤销）
+var ErrWeexOrderNotFound = errors.New("WEEX 挂单不存在")
+
+// ErrWeexDuplicateOpen 同一交易对同一方向在拦截窗口内重复开仓，请求未发送
+var ErrWeexDuplicateOpen = errors.New("WEEX 重复开仓已拦截")
+
+// WeexOrderError 下单请求失败，携带本地生成的 client_oid。
+// 网络错误时订单可能已在交易所创建，可凭 ClientOid 查询或撤销
+type WeexOrderError struct {
+	Op        string
+	ClientOid string
+	Err       error
+}
+
+func (e *WeexOrderError) Error() string {
+	return fmt.Sprintf("%s失败 (client_oid=%s): %v", e.Op, e.ClientOid, e.Err)
+}
+
+func (e *WeexOrderError) Unwrap() error {
+	return e.Err
+}
+
+// decodeWeexList 解析列表接口（currentPlan、current、contracts 等）的响应
+// WEEX 通常直接返回数组，但出错或高负载时可能包装为 {code, msg, data:[...]}（或 list 字段）：
+// 先按数组解析，失败后按包装格式解析并取出 data/list；code 表示失败时返回 *WeexAPIError。
+// 既不是数组也没有 data/list 字段的对象（如 {} 或不带 code 的错误对象）视为无法识别的响应，
+// 返回错误而不是空列表，以免把错误响应当成"没有挂单/持仓"
+func decodeWeexList(body []byte) ([]map[string]interface{}, error) {
+	var list []map[string]interface{}
+	arrayErr := json.Unmarshal(body, &list)
+	if arrayErr == nil {
+		return list, nil
+	}
+
+	var wrapped struct {
+		Code interface{}     `json:"code"`
+		Msg  string          `json:"msg"`
+		Data json.RawMessage `json:"data"`
+		List json.RawMessage `json:"list"`
+	}
+	if err := json.Unmarshal(body, &wrapped); err != nil {
+		return nil, arrayErr
+	}
+
+	switch code := weexStringValue(wrapped.Code); code {
+	case "", "0", "00000", "200":
+	default:
+		return nil, &WeexAPIError{Code: code, Msg: wrapped.Msg}
+	}
+
+	items := wrapped.Data
+	if len(items) == 0 {
+		items = wrapped.List
+	}
+	if len(items) == 0 {
+		return nil, fmt.Errorf("无法识别的列表响应: %s", string(body))
+	}
+	if string(items) == "null" {
+		return []map[string]interface{}{}, nil
+	}
+	if err := json.Unmarshal(items, &list); err != nil {
+		return nil, fmt.Errorf("解析列表字段失败: %w", err)
+	}
+	return list, nil
+}
+
 // GetBalance 获取账户余额
 func (t *WeexTrader) GetBalance() (map[string]interface{}, error) {
 	// 检查缓存
@@ -203,8 +549,8 @@ func (t *WeexTrader) GetBalance() (map[string]interface{}, error) {
 
 	// 解析响应数据（返回的是数组）
 	// 响应格式: [{coinName, available, equity, frozen, unrealizePnl}]
-	var assets []map[string]interface{}
-	if err := json.Unmarshal(respBody, &assets); err != nil {
+	assets, err := decodeWeexList(respBody)
+	if err != nil {
 		return nil, fmt.Errorf("解析账户余额失败: %w", err)
 	}
 
@@ -241,7 +587,7 @@ func (t *WeexTrader) GetBalance() (map[string]interface{}, error) {
 	t.balanceCacheTime = time.Now()
 	t.balanceCacheMutex.Unlock()
 
-	logger.Infof("✓ [WEEX] 获取账户余额成功: 总权益=%.2f, 可用=%.2f, 未实现盈亏=%.2f",
+	t.logger.Infof("✓ [WEEX] 获取账户余额成功: 总权益=%.2f, 可用=%.2f, 未实现盈亏=%.2f",
 		totalEquity, availableBalance, unrealizedPnl)
 
 	return balance, nil
@@ -266,11 +612,26 @@ func (t *WeexTrader) GetPositions() ([]map[string]interface{}, error) {
 	}
 
 	// 解析响应数据（返回的是数组）
-	var rawPositions []map[string]interface{}
-	if err := json.Unmarshal(respBody, &rawPositions); err != nil {
+	rawPositions, err := decodeWeexList(respBody)
+	if err != nil {
 		return nil, fmt.Errorf("解析持仓数据失败: %w", err)
 	}
 
+	// 批量获取所有持仓交易对的市场价格，避免逐个串行请求
+	var priceSymbols []string
+	for _, rawPos := range rawPositions {
+		sizeStr, _ := rawPos["size"].(string)
+		if size, _ := strconv.ParseFloat(sizeStr, 64); size == 0 {
+			continue
+		}
+		symbol, _ := rawPos["symbol"].(string)
+		priceSymbols = append(priceSymbols, symbol)
+	}
+	markPrices, err := t.GetMarketPrices(priceSymbols)
+	if err != nil {
+		t.logger.Infof("⚠️ [WEEX] 部分交易对市场价格获取失败: %v", err)
+	}
+
 	// 转换为统一格式
 	var positions []map[string]interface{}
 	for _, rawPos := range rawPositions {
@@ -301,10 +662,9 @@ func (t *WeexTrader) GetPositions() ([]map[string]interface{}, error) {
 			entryPrice = openValue / size
 		}
 
-		// 获取当前市场价格作为标记价格
-		markPrice, err := t.GetMarketPrice(symbol)
-		if err != nil {
-			logger.Infof("⚠️ [WEEX] 获取 %s 市场价格失败: %v", symbol, err)
+		// 使用当前市场价格作为标记价格
+		markPrice, ok := markPrices[symbol]
+		if !ok {
 			markPrice = entryPrice // 使用入场价格作为备用
 		}
 
@@ -334,7 +694,7 @@ func (t *WeexTrader) GetPositions() ([]map[string]interface{}, error) {
 		}
 
 		// 查询止损止盈订单
-		stopLoss, takeProfit := t.getStopOrders(symbol, positionSide)
+		stops := t.getStopOrders(symbol, positionSide)
 
 		// 将WEEX格式的symbol转换为标准格式（去掉cmt_前缀，转大写）
 		// 例如: "cmt_btcusdt" -> "BTCUSDT"
@@ -352,9 +712,12 @@ func (t *WeexTrader) GetPositions() ([]map[string]interface{}, error) {
 			"unrealizedPnL":    unrealizePnl,
 			"liquidationPrice": liquidatePrice,
 			"leverage":         leverage,
-			"margin_type":      marginMode,  // CROSSED 或 ISOLATED
-			"stop_loss":        stopLoss,    // 止损价格
-			"take_profit":      takeProfit,  // 止盈价格
+			"margin_type":      marginMode,       // CROSSED 或 ISOLATED
+			"stop_loss":        stops.stopLoss,   // 止损价格
+			"take_profit":      stops.takeProfit, // 止盈价格
+
+			"stop_loss_trigger_type":   string(stops.stopLossTrigger),   // 止损触发价格类型（last/mark/index）
+			"take_profit_trigger_type": string(stops.takeProfitTrigger), // 止盈触发价格类型
 		}
 
 		positions = append(positions, position)
@@ -366,7 +729,7 @@ func (t *WeexTrader) GetPositions() ([]map[string]interface{}, error) {
 	t.positionsCacheTime = time.Now()
 	t.positionsCacheMutex.Unlock()
 
-	logger.Infof("✓ [WEEX] 获取持仓成功，共 %d 个持仓", len(positions))
+	t.logger.Infof("✓ [WEEX] 获取持仓成功，共 %d 个持仓", len(positions))
 
 	return positions, nil
 }
@@ -514,33 +877,40 @@ func weexPlanOrderCloseSide(orderType string) (string, bool) {
 	}
 }
 
+// weexStopOrders 持仓当前生效的止损止盈价格及其触发价格类型（价格 0 表示未设置）
+type weexStopOrders struct {
+	stopLoss          float64
+	takeProfit        float64
+	stopLossTrigger   WeexTriggerPriceType
+	takeProfitTrigger WeexTriggerPriceType
+}
+
 // getStopOrders 查询止损止盈订单（简化版）
-// 返回: stopLoss价格（0表示未设置），takeProfit价格（0表示未设置）
-func (t *WeexTrader) getStopOrders(symbol string, positionSide string) (float64, float64) {
-	var stopLoss, takeProfit float64
+func (t *WeexTrader) getStopOrders(symbol string, positionSide string) weexStopOrders {
+	var stops weexStopOrders
 	positionSide = strings.ToUpper(strings.TrimSpace(positionSide))
 
 	// 查询当前所有计划委托订单
 	queryString := fmt.Sprintf("?symbol=%s", symbol)
 	respBody, err := t.sendRequestRaw("GET", "/capi/v2/order/currentPlan", queryString, nil)
 	if err != nil {
-		return 0, 0
+		return stops
 	}
 
 	// 解析订单列表
-	var orders []map[string]interface{}
-	if err := json.Unmarshal(respBody, &orders); err != nil {
-		return 0, 0
+	orders, err := decodeWeexList(respBody)
+	if err != nil {
+		return stops
 	}
 
 	if len(orders) == 0 {
-		return 0, 0
+		return stops
 	}
 
 	// 获取当前市场价格，用于判断是止损还是止盈
 	marketPrice, err := t.GetMarketPrice(symbol)
 	if err != nil {
-		return 0, 0
+		return stops
 	}
 
 	// 遍历所有计划委托，筛选出止损止盈单
@@ -562,67 +932,98 @@ func (t *WeexTrader) getStopOrders(symbol string, positionSide string) (float64,
 		if !ok {
 			continue
 		}
+		triggerType := WeexTriggerPriceType(strings.ToLower(weexMapString(order, "triggerPriceType", "trigger_price_type")))
 
 		// 根据持仓方向和触发价格判断止损/止盈
 		if positionSide == "LONG" {
 			if triggerPrice < marketPrice {
 				// 止损单：取最高的止损价格
-				if stopLoss == 0 || triggerPrice > stopLoss {
-					stopLoss = triggerPrice
+				if stops.stopLoss == 0 || triggerPrice > stops.stopLoss {
+					stops.stopLoss, stops.stopLossTrigger = triggerPrice, triggerType
 				}
 			} else {
 				// 止盈单：取最低的止盈价格
-				if takeProfit == 0 || triggerPrice < takeProfit {
-					takeProfit = triggerPrice
+				if stops.takeProfit == 0 || triggerPrice < stops.takeProfit {
+					stops.takeProfit, stops.takeProfitTrigger = triggerPrice, triggerType
 				}
 			}
 		} else if positionSide == "SHORT" {
 			if triggerPrice > marketPrice {
 				// 止损单：取最低的止损价格
-				if stopLoss == 0 || triggerPrice < stopLoss {
-					stopLoss = triggerPrice
+				if stops.stopLoss == 0 || triggerPrice < stops.stopLoss {
+					stops.stopLoss, stops.stopLossTrigger = triggerPrice, triggerType
 				}
 			} else {
 				// 止盈单：取最高的止盈价格
-				if takeProfit == 0 || triggerPrice > takeProfit {
-					takeProfit = triggerPrice
+				if stops.takeProfit == 0 || triggerPrice > stops.takeProfit {
+					stops.takeProfit, stops.takeProfitTrigger = triggerPrice, triggerType
 				}
 			}
 		}
 	}
 
-	return stopLoss, takeProfit
+	return stops
+}
+
+// reserveOpen 登记一次开仓意图，窗口内已有同方向开仓时返回 ErrWeexDuplicateOpen
+func (t *WeexTrader) reserveOpen(symbol, side string) error {
+	if t.openDedupWindow <= 0 {
+		return nil
+	}
+	key := symbol + "|" + side
+
+	t.recentOpensMutex.Lock()
+	defer t.recentOpensMutex.Unlock()
+	if last, ok := t.recentOpens[key]; ok && time.Since(last) < t.openDedupWindow {
+		return fmt.Errorf("%s %s %.0f秒内已开仓: %w", symbol, side, t.openDedupWindow.Seconds(), ErrWeexDuplicateOpen)
+	}
+	t.recentOpens[key] = time.Now()
+	return nil
+}
+
+// releaseOpen 撤销开仓意图登记（仅用于请求未发出或被交易所拒绝的情况，下单超时等结果未知时保留登记）
+func (t *WeexTrader) releaseOpen(symbol, side string) {
+	t.recentOpensMutex.Lock()
+	delete(t.recentOpens, symbol+"|"+side)
+	t.recentOpensMutex.Unlock()
 }
 
 // OpenLong 开多仓
 func (t *WeexTrader) OpenLong(symbol string, quantity float64, leverage int) (map[string]interface{}, error) {
 	// 转换交易对格式为WEEX格式
 	symbol = t.normalizeSymbol(symbol)
-	logger.Infof("[WEEX] 开多仓: %s 数量: %.6f 杠杆: %dx", symbol, quantity, leverage)
+	t.logger.Infof("[WEEX] 开多仓: %s 数量: %.6f 杠杆: %dx", symbol, quantity, leverage)
+
+	// 0. 拦截短时间内的重复开仓（须在撤单之前，避免撤掉上一笔开仓的止盈止损）
+	if err := t.reserveOpen(symbol, "long"); err != nil {
+		t.logger.Warnf("  ⚠️ [WEEX] %v", err)
+		return nil, err
+	}
 
 	// 1. 取消所有挂单（清理旧订单）
 	if err := t.CancelAllOrders(symbol); err != nil {
-		logger.Infof("  ⚠️ 取消旧挂单失败: %v", err)
+		t.logger.Infof("  ⚠️ 取消旧挂单失败: %v", err)
 	}
 
 	// 2. 取消所有计划委托订单（包括止损止盈）
 	if err := t.CancelPlanOrders(symbol); err != nil {
-		logger.Infof("  ⚠️ 取消计划委托订单失败: %v", err)
+		t.logger.Infof("  ⚠️ 取消计划委托订单失败: %v", err)
 	}
 
 	// 3. 设置保证金模式为逐仓
 	if err := t.SetMarginMode(symbol, false); err != nil {
-		logger.Infof("  ⚠️ 设置保证金模式失败: %v", err)
+		t.logger.Infof("  ⚠️ 设置保证金模式失败: %v", err)
 	}
 
 	// 4. 设置杠杆
 	if err := t.SetLeverage(symbol, leverage); err != nil {
-		logger.Infof("  ⚠️ 设置杠杆失败: %v", err)
+		t.logger.Infof("  ⚠️ 设置杠杆失败: %v", err)
 	}
 
 	// 格式化数量
 	quantityStr, err := t.FormatQuantity(symbol, quantity)
 	if err != nil {
+		t.releaseOpen(symbol, "long")
 		return nil, err
 	}
 
@@ -658,24 +1059,32 @@ func (t *WeexTrader) OpenLong(symbol string, quantity float64, leverage int) (ma
 		// 格式化为字符串以避免浮点精度问题
 		priceStr := fmt.Sprintf(fmt.Sprintf("%%.%df", priceDecimals), stopLoss)
 		body["presetStopLossPrice"] = priceStr
-		logger.Infof("  ✓ [WEEX] 开仓时设置止损价格: %s", priceStr)
+		t.logger.Infof("  ✓ [WEEX] 开仓时设置止损价格: %s", priceStr)
 	}
 	if takeProfit, ok := t.pendingTakeProfit[symbol]; ok && takeProfit > 0 {
 		// 格式化为字符串以避免浮点精度问题
 		priceStr := fmt.Sprintf(fmt.Sprintf("%%.%df", priceDecimals), takeProfit)
 		body["presetTakeProfitPrice"] = priceStr
-		logger.Infof("  ✓ [WEEX] 开仓时设置止盈价格: %s", priceStr)
+		t.logger.Infof("  ✓ [WEEX] 开仓时设置止盈价格: %s", priceStr)
 	}
 	t.pendingPricesMutex.RUnlock()
 
 	result, err := t.sendRequest("POST", "/capi/v2/order/placeOrder", "", body)
+	if err == nil {
+		err = weexOrderResultError(result)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("开多仓失败: %w", err)
+		// 请求未发出或被拒绝时撤销登记以便重试；响应丢失时订单可能已创建，保留登记，
+		// 并带上 client_oid 以便调用方用 CancelOrderByClientOid 对账
+		if weexRequestRejected(err) {
+			t.releaseOpen(symbol, "long")
+		}
+		return nil, &WeexOrderError{Op: "开多仓", ClientOid: clientOid, Err: err}
 	}
 
 	// 解析返回结果
 	orderID, _ := result["order_id"].(string)
-	logger.Infof("✓ [WEEX] 开多仓成功: %s 数量: %s, 订单ID: %s", symbol, quantityStr, orderID)
+	t.logger.Infof("✓ [WEEX] 开多仓成功: %s 数量: %s, 订单ID: %s", symbol, quantityStr, orderID)
 
 	// 清除缓存
 	t.clearCache()
@@ -687,9 +1096,10 @@ func (t *WeexTrader) OpenLong(symbol string, quantity float64, leverage int) (ma
 	t.pendingPricesMutex.Unlock()
 
 	return map[string]interface{}{
-		"orderId": orderID,
-		"symbol":  symbol,
-		"status":  "NEW",
+		"orderId":   orderID,
+		"clientOid": clientOid,
+		"symbol":    symbol,
+		"status":    "NEW",
 	}, nil
 }
 
@@ -697,31 +1107,38 @@ func (t *WeexTrader) OpenLong(symbol string, quantity float64, leverage int) (ma
 func (t *WeexTrader) OpenShort(symbol string, quantity float64, leverage int) (map[string]interface{}, error) {
 	// 转换交易对格式为WEEX格式
 	symbol = t.normalizeSymbol(symbol)
-	logger.Infof("[WEEX] 开空仓: %s 数量: %.6f 杠杆: %dx", symbol, quantity, leverage)
+	t.logger.Infof("[WEEX] 开空仓: %s 数量: %.6f 杠杆: %dx", symbol, quantity, leverage)
+
+	// 0. 拦截短时间内的重复开仓（须在撤单之前，避免撤掉上一笔开仓的止盈止损）
+	if err := t.reserveOpen(symbol, "short"); err != nil {
+		t.logger.Warnf("  ⚠️ [WEEX] %v", err)
+		return nil, err
+	}
 
 	// 1. 取消所有挂单（清理旧订单）
 	if err := t.CancelAllOrders(symbol); err != nil {
-		logger.Infof("  ⚠️ 取消旧挂单失败: %v", err)
+		t.logger.Infof("  ⚠️ 取消旧挂单失败: %v", err)
 	}
 
 	// 2. 取消所有计划委托订单（包括止损止盈）
 	if err := t.CancelPlanOrders(symbol); err != nil {
-		logger.Infof("  ⚠️ 取消计划委托订单失败: %v", err)
+		t.logger.Infof("  ⚠️ 取消计划委托订单失败: %v", err)
 	}
 
 	// 3. 设置保证金模式为逐仓
 	if err := t.SetMarginMode(symbol, false); err != nil {
-		logger.Infof("  ⚠️ 设置保证金模式失败: %v", err)
+		t.logger.Infof("  ⚠️ 设置保证金模式失败: %v", err)
 	}
 
 	// 4. 设置杠杆
 	if err := t.SetLeverage(symbol, leverage); err != nil {
-		logger.Infof("  ⚠️ 设置杠杆失败: %v", err)
+		t.logger.Infof("  ⚠️ 设置杠杆失败: %v", err)
 	}
 
 	// 格式化数量
 	quantityStr, err := t.FormatQuantity(symbol, quantity)
 	if err != nil {
+		t.releaseOpen(symbol, "short")
 		return nil, err
 	}
 
@@ -757,24 +1174,32 @@ func (t *WeexTrader) OpenShort(symbol string, quantity float64, leverage int) (m
 		// 格式化为字符串以避免浮点精度问题
 		priceStr := fmt.Sprintf(fmt.Sprintf("%%.%df", priceDecimals), stopLoss)
 		body["presetStopLossPrice"] = priceStr
-		logger.Infof("  ✓ [WEEX] 开仓时设置止损价格: %s", priceStr)
+		t.logger.Infof("  ✓ [WEEX] 开仓时设置止损价格: %s", priceStr)
 	}
 	if takeProfit, ok := t.pendingTakeProfit[symbol]; ok && takeProfit > 0 {
 		// 格式化为字符串以避免浮点精度问题
 		priceStr := fmt.Sprintf(fmt.Sprintf("%%.%df", priceDecimals), takeProfit)
 		body["presetTakeProfitPrice"] = priceStr
-		logger.Infof("  ✓ [WEEX] 开仓时设置止盈价格: %s", priceStr)
+		t.logger.Infof("  ✓ [WEEX] 开仓时设置止盈价格: %s", priceStr)
 	}
 	t.pendingPricesMutex.RUnlock()
 
 	result, err := t.sendRequest("POST", "/capi/v2/order/placeOrder", "", body)
+	if err == nil {
+		err = weexOrderResultError(result)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("开空仓失败: %w", err)
+		// 请求未发出或被拒绝时撤销登记以便重试；响应丢失时订单可能已创建，保留登记，
+		// 并带上 client_oid 以便调用方用 CancelOrderByClientOid 对账
+		if weexRequestRejected(err) {
+			t.releaseOpen(symbol, "short")
+		}
+		return nil, &WeexOrderError{Op: "开空仓", ClientOid: clientOid, Err: err}
 	}
 
 	// 解析返回结果
 	orderID, _ := result["order_id"].(string)
-	logger.Infof("✓ [WEEX] 开空仓成功: %s 数量: %s, 订单ID: %s", symbol, quantityStr, orderID)
+	t.logger.Infof("✓ [WEEX] 开空仓成功: %s 数量: %s, 订单ID: %s", symbol, quantityStr, orderID)
 
 	// 清除缓存
 	t.clearCache()
@@ -786,14 +1211,38 @@ func (t *WeexTrader) OpenShort(symbol string, quantity float64, leverage int) (m
 	t.pendingPricesMutex.Unlock()
 
 	return map[string]interface{}{
-		"orderId": orderID,
-		"symbol":  symbol,
-		"status":  "NEW",
+		"orderId":   orderID,
+		"clientOid": clientOid,
+		"symbol":    symbol,
+		"status":    "NEW",
 	}, nil
 }
 
-// CloseLong 平多仓
+// CloseLong 平多仓（默认只减仓）
 func (t *WeexTrader) CloseLong(symbol string, quantity float64) (map[string]interface{}, error) {
+	return t.ClosePosition(symbol, "long", quantity, true)
+}
+
+// CloseShort 平空仓（默认只减仓）
+func (t *WeexTrader) CloseShort(symbol string, quantity float64) (map[string]interface{}, error) {
+	return t.ClosePosition(symbol, "short", quantity, true)
+}
+
+// ClosePosition 平仓
+// side: "long" 或 "short"
+// reduceOnly: 只减仓，由交易所保证订单只能减少持仓，不会因数量过大（如持仓数据过期）而反向开仓
+func (t *WeexTrader) ClosePosition(symbol string, side string, quantity float64, reduceOnly bool) (map[string]interface{}, error) {
+	side = strings.ToLower(side)
+	var orderType, sideName string
+	switch side {
+	case "long":
+		orderType, sideName = "3", "多" // 3:平多
+	case "short":
+		orderType, sideName = "4", "空" // 4:平空
+	default:
+		return nil, fmt.Errorf("无效的持仓方向: %s", side)
+	}
+
 	// 保存原始symbol用于查找持仓（GetPositions返回的是标准格式）
 	originalSymbol := strings.ToUpper(symbol)
 
@@ -807,18 +1256,18 @@ func (t *WeexTrader) CloseLong(symbol string, quantity float64) (map[string]inte
 			return nil, err
 		}
 		for _, pos := range positions {
-			side, _ := pos["side"].(string)
+			posSide, _ := pos["side"].(string)
 			posSymbol, _ := pos["symbol"].(string)
 			// 使用标准格式比较（GetPositions返回的symbol是标准格式）
-			if posSymbol == originalSymbol && strings.ToLower(side) == "long" {
-				quantity = pos["positionAmt"].(float64)
+			if posSymbol == originalSymbol && strings.ToLower(posSide) == side {
+				quantity = math.Abs(pos["positionAmt"].(float64)) // 空仓是负数
 				break
 			}
 		}
 	}
 
 	if quantity <= 0 {
-		return nil, fmt.Errorf("没有多仓可平")
+		return nil, fmt.Errorf("没有%s仓可平", sideName)
 	}
 
 	// 格式化数量
@@ -838,102 +1287,88 @@ func (t *WeexTrader) CloseLong(symbol string, quantity float64) (map[string]inte
 		"symbol":      symbol,
 		"client_oid":  clientOid,
 		"size":        quantityStr,
-		"type":        "3",        // 3:平多
+		"type":        orderType,
 		"order_type":  "3",        // 3:立即成交并取消剩余（IOC）
 		"match_price": "1",        // 1:市价
 		"price":       "0",        // 市价单价格填0
 		"marginMode":  marginMode, // 必须与开仓时一致
+		"reduceOnly":  reduceOnly, // 只减仓
 	}
 
 	result, err := t.sendRequest("POST", "/capi/v2/order/placeOrder", "", body)
 	if err != nil {
-		return nil, fmt.Errorf("平多仓失败: %w", err)
+		return nil, fmt.Errorf("平%s仓失败: %w", sideName, err)
 	}
 
 	// 解析返回结果
 	orderID, _ := result["order_id"].(string)
-	logger.Infof("✓ [WEEX] 平多仓成功: %s 数量: %s, 订单ID: %s", symbol, quantityStr, orderID)
+	t.logger.Infof("✓ [WEEX] 平%s仓成功: %s 数量: %s, 订单ID: %s", sideName, symbol, quantityStr, orderID)
 
 	// 清除缓存
 	t.clearCache()
 
 	return map[string]interface{}{
-		"orderId": orderID,
-		"symbol":  symbol,
-		"status":  "NEW",
+		"orderId":   orderID,
+		"clientOid": clientOid,
+		"symbol":    symbol,
+		"status":    "NEW",
 	}, nil
 }
 
-// CloseShort 平空仓
-func (t *WeexTrader) CloseShort(symbol string, quantity float64) (map[string]interface{}, error) {
-	// 保存原始symbol用于查找持仓（GetPositions返回的是标准格式）
-	originalSymbol := strings.ToUpper(symbol)
-
-	// 转换交易对格式为WEEX格式
-	symbol = t.normalizeSymbol(symbol)
-
-	// 如果 quantity = 0，获取当前持仓数量
-	if quantity == 0 {
-		positions, err := t.GetPositions()
-		if err != nil {
-			return nil, err
-		}
-		for _, pos := range positions {
-			side, _ := pos["side"].(string)
-			posSymbol, _ := pos["symbol"].(string)
-			// 使用标准格式比较（GetPositions返回的symbol是标准格式）
-			if posSymbol == originalSymbol && strings.ToLower(side) == "short" {
-				quantity = -pos["positionAmt"].(float64) // 空仓是负数
-				break
-			}
-		}
-	}
-
-	if quantity <= 0 {
-		return nil, fmt.Errorf("没有空仓可平")
-	}
-
-	// 格式化数量
-	quantityStr, err := t.FormatQuantity(symbol, quantity)
+// CloseAllPositions 一键平仓（紧急止损开关）
+// 先撤销每个持仓交易对的计划委托和普通挂单，再以只减仓市价单平掉所有持仓
+// 单个交易对失败不会中断其余平仓：返回 symbol -> 错误（nil 表示已提交平仓单），失败信息同时汇总在返回的error中
+func (t *WeexTrader) CloseAllPositions() (map[string]error, error) {
+	// 紧急平仓必须使用最新持仓，不使用缓存
+	t.clearCache()
+	positions, err := t.GetPositions()
 	if err != nil {
 		return nil, err
 	}
 
-	// 生成唯一的订单ID
-	clientOid := t.generateOrderID()
+	results := make(map[string]error, len(positions))
+	canceled := make(map[string]bool, len(positions))
+	var errs []error
+	for _, pos := range positions {
+		symbol, _ := pos["symbol"].(string)
+		side, _ := pos["side"].(string)
+		positionAmt, _ := pos["positionAmt"].(float64)
+		quantity := math.Abs(positionAmt) // 空仓是负数
+		if symbol == "" || quantity == 0 {
+			continue
+		}
 
-	// 获取保证金模式（必须与开仓时一致）
-	marginMode := t.getMarginMode(symbol)
+		var symbolErrs []error
+		if !canceled[symbol] {
+			canceled[symbol] = true
+			// 撤单失败不阻止平仓，只记录
+			if err := t.CancelPlanOrders(symbol); err != nil {
+				symbolErrs = append(symbolErrs, fmt.Errorf("取消计划委托失败: %w", err))
+			}
+			if err := t.CancelAllOrders(symbol); err != nil {
+				symbolErrs = append(symbolErrs, fmt.Errorf("取消挂单失败: %w", err))
+			}
+		}
 
-	// 调用 WEEX API 平仓
-	body := map[string]interface{}{
-		"symbol":      symbol,
-		"client_oid":  clientOid,
-		"size":        quantityStr,
-		"type":        "4",        // 4:平空
-		"order_type":  "3",        // 3:立即成交并取消剩余（IOC）
-		"match_price": "1",        // 1:市价
-		"price":       "0",        // 市价单价格填0
-		"marginMode":  marginMode, // 必须与开仓时一致
-	}
+		if _, err := t.ClosePosition(symbol, side, quantity, true); err != nil {
+			symbolErrs = append(symbolErrs, err)
+		}
 
-	result, err := t.sendRequest("POST", "/capi/v2/order/placeOrder", "", body)
-	if err != nil {
-		return nil, fmt.Errorf("平空仓失败: %w", err)
+		if len(symbolErrs) > 0 {
+			symbolErr := fmt.Errorf("%s %s: %w", symbol, side, errors.Join(symbolErrs...))
+			results[symbol] = errors.Join(results[symbol], symbolErr)
+			errs = append(errs, symbolErr)
+			t.logger.Infof("⚠️ [WEEX] 一键平仓 %s %s 失败: %v", symbol, side, symbolErr)
+		} else if _, ok := results[symbol]; !ok {
+			results[symbol] = nil
+		}
 	}
 
-	// 解析返回结果
-	orderID, _ := result["order_id"].(string)
-	logger.Infof("✓ [WEEX] 平空仓成功: %s 数量: %s, 订单ID: %s", symbol, quantityStr, orderID)
-
-	// 清除缓存
-	t.clearCache()
-
-	return map[string]interface{}{
-		"orderId": orderID,
-		"symbol":  symbol,
-		"status":  "NEW",
-	}, nil
+	if len(errs) > 0 {
+		return results, fmt.Errorf("%d 个持仓平仓失败: %w", len(errs), errors.Join(errs...))
+	}
+	t.logger.Infof("✓ [WEEX] 一键平仓完成，共 %d 个交易对", len(results))
+	return results, nil
 }
 
 // SetLeverage 设置杠杆
@@ -958,7 +1393,7 @@ func (t *WeexTrader) SetLeverage(symbol string, leverage int) error {
 	if err != nil {
 		// 如果杠杆已经是目标值，忽略错误
 		if strings.Contains(err.Error(), "No need to change") || strings.Contains(err.Error(), "leverage not modified") {
-			logger.Infof("  ✓ [WEEX] %s 杠杆已经是 %dx", symbol, leverage)
+			t.logger.Infof("  ✓ [WEEX] %s 杠杆已经是 %dx", symbol, leverage)
 			return nil
 		}
 		return fmt.Errorf("设置杠杆失败: %w", err)
@@ -966,7 +1401,8 @@ func (t *WeexTrader) SetLeverage(symbol string, leverage int) error {
 
 	// 检查返回码
 	if code, ok := result["code"].(string); ok && code == "200" {
-		logger.Infof("  ✓ [WEEX] %s 杠杆设置为 %dx", symbol, leverage)
+		t.invalidateLeverageMargin(symbol)
+		t.logger.Infof("  ✓ [WEEX] %s 杠杆设置为 %dx", symbol, leverage)
 		return nil
 	}
 
@@ -991,27 +1427,34 @@ func (t *WeexTrader) SetMarginMode(symbol string, isCrossMargin bool) error {
 		targetModeStr = "全仓"
 	}
 
-	// 先查询账户实际使用的保证金模式
-	actualMode := t.queryActualMarginMode()
+	// 先查询该交易对实际配置的保证金模式，查询失败时从持仓推断
+	var actualMode int
+	var configuredLeverage int
+	if info, err := t.QueryLeverageMargin(symbol); err == nil && info.MarginMode != 0 {
+		actualMode = info.MarginMode
+		configuredLeverage = int(info.LongLeverage)
+	} else {
+		actualMode = t.queryActualMarginMode()
+	}
 	actualModeStr := "逐仓"
 	if actualMode == 1 {
 		actualModeStr = "全仓"
 	}
-	logger.Infof("  ℹ️ [WEEX] 账户当前保证金模式: %s (mode=%d)", actualModeStr, actualMode)
+	t.logger.Infof("  ℹ️ [WEEX] 账户当前保证金模式: %s (mode=%d)", actualModeStr, actualMode)
 
 	// 如果账户当前模式与目标模式一致，直接缓存并返回
 	if actualMode == targetMode {
 		t.marginModeCacheMutex.Lock()
 		t.marginModeCache[symbol] = actualMode
 		t.marginModeCacheMutex.Unlock()
-		logger.Infof("  ✓ [WEEX] %s 保证金模式已是 %s，无需切换", symbol, actualModeStr)
+		t.logger.Infof("  ✓ [WEEX] %s 保证金模式已是 %s，无需切换", symbol, actualModeStr)
 		return nil
 	}
 
 	// 尝试切换保证金模式
 	positions, err := t.GetPositions()
 	if err != nil {
-		logger.Infof("  ⚠️ [WEEX] 获取持仓信息失败: %v，使用账户当前模式", err)
+		t.logger.Infof("  ⚠️ [WEEX] 获取持仓信息失败: %v，使用账户当前模式", err)
 		// 使用账户当前的模式
 		t.marginModeCacheMutex.Lock()
 		t.marginModeCache[symbol] = actualMode
@@ -1019,8 +1462,11 @@ func (t *WeexTrader) SetMarginMode(symbol string, isCrossMargin bool) error {
 		return nil
 	}
 
-	// 查找该交易对的持仓，获取当前杠杆
+	// 查找该交易对的持仓，获取当前杠杆（优先使用账户配置中的杠杆）
 	currentLeverage := 10 // 默认杠杆
+	if configuredLeverage > 0 {
+		currentLeverage = configuredLeverage
+	}
 	for _, pos := range positions {
 		posSymbol, _ := pos["symbol"].(string)
 		standardSymbol := strings.ToUpper(strings.TrimPrefix(symbol, "cmt_"))
@@ -1035,7 +1481,7 @@ func (t *WeexTrader) SetMarginMode(symbol string, isCrossMargin bool) error {
 	// 调用杠杆接口尝试切换保证金模式
 	err = t.setMarginModeWithLeverage(symbol, targetMode, currentLeverage)
 	if err != nil {
-		logger.Infof("  ⚠️ [WEEX] 切换保证金模式失败: %v，使用账户当前模式 %s", err, actualModeStr)
+		t.logger.Infof("  ⚠️ [WEEX] 切换保证金模式失败: %v，使用账户当前模式 %s", err, actualModeStr)
 		// 切换失败，使用账户当前的模式
 		t.marginModeCacheMutex.Lock()
 		t.marginModeCache[symbol] = actualMode
@@ -1044,41 +1490,181 @@ func (t *WeexTrader) SetMarginMode(symbol string, isCrossMargin bool) error {
 	}
 
 	// 切换成功，缓存目标模式
+	t.invalidateLeverageMargin(symbol)
 	t.marginModeCacheMutex.Lock()
 	t.marginModeCache[symbol] = targetMode
 	t.marginModeCacheMutex.Unlock()
-	logger.Infof("  ✓ [WEEX] %s 保证金模式切换成功: %s", symbol, targetModeStr)
+	t.logger.Infof("  ✓ [WEEX] %s 保证金模式切换成功: %s", symbol, targetModeStr)
 	return nil
 }
 
-// queryActualMarginMode 直接查询账户的实际保证金模式（不使用缓存）
-// 从持仓信息中获取，如果没有持仓则默认返回3（逐仓）
-func (t *WeexTrader) queryActualMarginMode() int {
-	// 直接从持仓查询，不使用缓存
-	positions, err := t.GetPositions()
-	if err == nil && len(positions) > 0 {
-		// 从第一个持仓获取保证金模式（假设账户所有币对使用相同模式）
-		for _, pos := range positions {
-			if marginType, ok := pos["margin_type"].(string); ok {
-				if marginType == "crossed" {
-					return 1 // 全仓
-				} else if marginType == "isolated" {
-					return 3 // 逐仓
-				}
-			}
-		}
-	}
-	// 如果没有持仓，默认返回3（逐仓）
-	return 3
+// WeexLeverageMargin 交易对在交易所上实际配置的杠杆与保证金模式
+type WeexLeverageMargin struct {
+	Symbol        string
+	MarginMode    int // 1=全仓, 3=逐仓, 0=未知
+	LongLeverage  float64
+	ShortLeverage float64
 }
 
-// getMarginModeSimple 简化版保证金模式获取
-// 优先级：1.缓存（SetMarginMode设置的） 2.持仓信息 3.默认值（全仓）
-func (t *WeexTrader) getMarginModeSimple(symbol string) int {
-	// 1. 优先从缓存获取（SetMarginMode会缓存目标模式）
-	t.marginModeCacheMutex.RLock()
-	if mode, ok := t.marginModeCache[symbol]; ok {
-		t.marginModeCacheMutex.RUnlock()
+// QueryLeverageMargin 查询交易对实际配置的多/空杠杆和保证金模式
+// 与持仓无关，首次在新交易对上开仓时也能拿到正确的保证金模式；结果按 leverageMarginCacheTTL 缓存
+func (t *WeexTrader) QueryLeverageMargin(symbol string) (*WeexLeverageMargin, error) {
+	symbol = t.normalizeSymbol(symbol)
+
+	t.leverageMarginCacheMutex.RLock()
+	if info, ok := t.leverageMarginCache[symbol]; ok && time.Since(info.fetchedAt) < t.leverageMarginCacheTTL {
+		t.leverageMarginCacheMutex.RUnlock()
+		return &info.WeexLeverageMargin, nil
+	}
+	t.leverageMarginCacheMutex.RUnlock()
+
+	// GET /capi/v2/account/settings?symbol=cmt_btcusdt
+	// 响应格式: {"cmt_btcusdt": {marginMode, isolated_long_leverage, isolated_short_leverage, cross_leverage}}
+	queryString := fmt.Sprintf("?symbol=%s", symbol)
+	result, err := t.sendRequest("GET", "/capi/v2/account/settings", queryString, nil)
+	if err != nil {
+		return nil, fmt.Errorf("查询杠杆与保证金模式失败: %w", err)
+	}
+
+	settings := result
+	if nested, ok := result[symbol].(map[string]interface{}); ok {
+		settings = nested
+	}
+
+	info := WeexLeverageMargin{
+		Symbol:     symbol,
+		MarginMode: weexParseMarginMode(weexMapString(settings, "marginMode", "margin_mode")),
+	}
+	if info.MarginMode == 3 {
+		info.LongLeverage, _ = weexMapFloat(settings, "isolated_long_leverage", "longLeverage", "long_leverage")
+		info.ShortLeverage, _ = weexMapFloat(settings, "isolated_short_leverage", "shortLeverage", "short_leverage")
+	} else {
+		info.LongLeverage, _ = weexMapFloat(settings, "cross_leverage", "longLeverage", "long_leverage")
+		info.ShortLeverage, _ = weexMapFloat(settings, "cross_leverage", "shortLeverage", "short_leverage")
+	}
+
+	t.leverageMarginCacheMutex.Lock()
+	t.leverageMarginCache[symbol] = &weexLeverageMarginEntry{WeexLeverageMargin: info, fetchedAt: time.Now()}
+	t.leverageMarginCacheMutex.Unlock()
+
+	return &info, nil
+}
+
+// weexLeverageMarginEntry 杠杆与保证金模式缓存条目
+type weexLeverageMarginEntry struct {
+	WeexLeverageMargin
+	fetchedAt time.Time
+}
+
+// WeexFeeRates 交易对的挂单（maker）与吃单（taker）基础手续费率，小数形式（0.0002 即 0.02%）
+type WeexFeeRates struct {
+	Symbol string
+	Maker  float64
+	Taker  float64
+}
+
+// weexFeeRateEntry 手续费率缓存条目
+type weexFeeRateEntry struct {
+	WeexFeeRates
+	fetchedAt time.Time
+}
+
+// GetContractFeeRates 查询交易对的 maker/taker 基础手续费率，结果按 feeRateCacheTTL 缓存
+// 费率来自合约信息接口的 makerFeeRate/takerFeeRate 字段，是合约的公开基础费率，
+// 不反映账户的 VIP 等级或返佣折扣：有费率优惠的账户实际手续费会更低，结果只能作为上限估算
+func (t *WeexTrader) GetContractFeeRates(symbol string) (*WeexFeeRates, error) {
+	symbol = t.normalizeSymbol(symbol)
+
+	t.feeRateCacheMutex.RLock()
+	if entry, ok := t.feeRateCache[symbol]; ok && time.Since(entry.fetchedAt) < t.feeRateCacheTTL {
+		t.feeRateCacheMutex.RUnlock()
+		rates := entry.WeexFeeRates
+		return &rates, nil
+	}
+	t.feeRateCacheMutex.RUnlock()
+
+	// GET /capi/v2/market/contracts?symbol=cmt_btcusdt
+	// 响应格式: [{"symbol":"cmt_btcusdt", "makerFeeRate":"0.0002", "takerFeeRate":"0.0008", ...}]
+	contract, err := t.GetContractInfo(symbol)
+	if err != nil {
+		return nil, fmt.Errorf("查询手续费率失败: %w", err)
+	}
+
+	maker, makerOK := weexMapFloat(contract, "makerFeeRate", "maker_fee_rate", "makerFee", "maker_fee")
+	taker, takerOK := weexMapFloat(contract, "takerFeeRate", "taker_fee_rate", "takerFee", "taker_fee")
+	if !makerOK || !takerOK {
+		return nil, fmt.Errorf("%s 合约信息中没有手续费率", symbol)
+	}
+
+	rates := WeexFeeRates{Symbol: symbol, Maker: maker, Taker: taker}
+	t.feeRateCacheMutex.Lock()
+	t.feeRateCache[symbol] = &weexFeeRateEntry{WeexFeeRates: rates, fetchedAt: time.Now()}
+	t.feeRateCacheMutex.Unlock()
+
+	return &rates, nil
+}
+
+// EstimateFees 按合约基础费率估算成交名义价值 notional（USDT）的手续费，isMaker 为 true 时按挂单费率计算
+// 不考虑账户 VIP 等级（见 GetContractFeeRates）
+func (t *WeexTrader) EstimateFees(symbol string, notional float64, isMaker bool) (float64, error) {
+	rates, err := t.GetContractFeeRates(symbol)
+	if err != nil {
+		return 0, err
+	}
+	rate := rates.Taker
+	if isMaker {
+		rate = rates.Maker
+	}
+	return math.Abs(notional) * rate, nil
+}
+
+// invalidateLeverageMargin 清除交易对的杠杆与保证金模式缓存（修改杠杆/保证金模式后调用）
+func (t *WeexTrader) invalidateLeverageMargin(symbol string) {
+	t.leverageMarginCacheMutex.Lock()
+	delete(t.leverageMarginCache, symbol)
+	t.leverageMarginCacheMutex.Unlock()
+}
+
+// weexParseMarginMode 解析保证金模式，兼容数字（1/3）和名称（SHARED/ISOLATED）两种格式
+func weexParseMarginMode(v string) int {
+	switch strings.ToUpper(v) {
+	case "1", "SHARED", "CROSSED", "CROSS":
+		return 1
+	case "3", "ISOLATED":
+		return 3
+	default:
+		return 0
+	}
+}
+
+// queryActualMarginMode 直接查询账户的实际保证金模式（不使用缓存）
+// 从持仓信息中获取，如果没有持仓则默认返回3（逐仓）
+func (t *WeexTrader) queryActualMarginMode() int {
+	// 直接从持仓查询，不使用缓存
+	positions, err := t.GetPositions()
+	if err == nil && len(positions) > 0 {
+		// 从第一个持仓获取保证金模式（假设账户所有币对使用相同模式）
+		for _, pos := range positions {
+			if marginType, ok := pos["margin_type"].(string); ok {
+				if marginType == "crossed" {
+					return 1 // 全仓
+				} else if marginType == "isolated" {
+					return 3 // 逐仓
+				}
+			}
+		}
+	}
+	// 如果没有持仓，默认返回3（逐仓）
+	return 3
+}
+
+// getMarginModeSimple 简化版保证金模式获取
+// 优先级：1.缓存（SetMarginMode设置的） 2.持仓信息 3.默认值（全仓）
+func (t *WeexTrader) getMarginModeSimple(symbol string) int {
+	// 1. 优先从缓存获取（SetMarginMode会缓存目标模式）
+	t.marginModeCacheMutex.RLock()
+	if mode, ok := t.marginModeCache[symbol]; ok {
+		t.marginModeCacheMutex.RUnlock()
 		return mode
 	}
 	t.marginModeCacheMutex.RUnlock()
@@ -1113,24 +1699,33 @@ func (t *WeexTrader) getMarginModeSimple(symbol string) int {
 }
 
 // getMarginMode 智能获取保证金模式（保留用于兼容性）
-// 优先级: 1.缓存 2.该交易对持仓 3.其他交易对持仓 4.默认值(全仓)
+// 优先级: 1.缓存 2.账户配置查询 3.该交易对持仓 4.其他交易对持仓 5.默认值(全仓)
+// 账户配置查询结果只走 QueryLeverageMargin 的TTL缓存，不写入永久缓存，以便感知交易所上的模式变更
 func (t *WeexTrader) getMarginMode(symbol string) int {
 	// 1. 优先从缓存中获取
 	t.marginModeCacheMutex.RLock()
 	if mode, ok := t.marginModeCache[symbol]; ok {
 		t.marginModeCacheMutex.RUnlock()
-		logger.Infof("  [WEEX] 从缓存获取保证金模式: %s (mode=%d)", symbol, mode)
+		t.logger.Infof("  [WEEX] 从缓存获取保证金模式: %s (mode=%d)", symbol, mode)
 		return mode
 	}
 	t.marginModeCacheMutex.RUnlock()
 
-	// 2. 从持仓中检测
+	// 2. 查询交易所上该交易对实际配置的保证金模式（权威来源，与是否有持仓无关）
+	if info, err := t.QueryLeverageMargin(symbol); err == nil && info.MarginMode != 0 {
+		t.logger.Infof("  [WEEX] 从账户配置获取保证金模式: %s (mode=%d)", symbol, info.MarginMode)
+		return info.MarginMode
+	} else if err != nil {
+		t.logger.Infof("  ⚠️ [WEEX] 查询 %s 杠杆与保证金配置失败: %v，从持仓推断", symbol, err)
+	}
+
+	// 3. 从持仓中检测
 	positions, err := t.GetPositions()
 	if err == nil && len(positions) > 0 {
 		// 将symbol转换为标准格式用于比较（GetPositions返回的是标准格式）
 		standardSymbol := strings.ToUpper(strings.TrimPrefix(symbol, "cmt_"))
 
-		// 2.1 优先查找该交易对的持仓
+		// 3.1 优先查找该交易对的持仓
 		for _, pos := range positions {
 			posSymbol, _ := pos["symbol"].(string)
 			if posSymbol == standardSymbol {
@@ -1139,7 +1734,7 @@ func (t *WeexTrader) getMarginMode(symbol string) int {
 					if marginType == "isolated" { // 小写，与GetPositions返回的格式一致
 						mode = 3 // 逐仓
 					}
-					logger.Infof("  [WEEX] 从持仓获取保证金模式: %s (mode=%d, marginType=%s)", symbol, mode, marginType)
+					t.logger.Infof("  [WEEX] 从持仓获取保证金模式: %s (mode=%d, marginType=%s)", symbol, mode, marginType)
 
 					// 缓存检测到的保证金模式
 					t.marginModeCacheMutex.Lock()
@@ -1151,7 +1746,7 @@ func (t *WeexTrader) getMarginMode(symbol string) int {
 			}
 		}
 
-		// 2.2 如果该交易对没有持仓，从其他交易对的持仓中推断
+		// 3.2 如果该交易对没有持仓，从其他交易对的持仓中推断
 		// 假设所有交易对使用相同的保证金模式
 		for _, pos := range positions {
 			if marginType, ok := pos["margin_type"].(string); ok {
@@ -1160,7 +1755,7 @@ func (t *WeexTrader) getMarginMode(symbol string) int {
 					mode = 3 // 逐仓
 				}
 				otherSymbol, _ := pos["symbol"].(string)
-				logger.Infof("  [WEEX] 从其他交易对(%s)推断保证金模式: %s (mode=%d, marginType=%s)", otherSymbol, symbol, mode, marginType)
+				t.logger.Infof("  [WEEX] 从其他交易对(%s)推断保证金模式: %s (mode=%d, marginType=%s)", otherSymbol, symbol, mode, marginType)
 
 				// 缓存推断的保证金模式
 				t.marginModeCacheMutex.Lock()
@@ -1172,8 +1767,8 @@ func (t *WeexTrader) getMarginMode(symbol string) int {
 		}
 	}
 
-	// 3. 使用默认值（全仓）
-	logger.Infof("  [WEEX] 使用默认保证金模式: %s (mode=1, 全仓)", symbol)
+	// 4. 使用默认值（全仓）
+	t.logger.Infof("  [WEEX] 使用默认保证金模式: %s (mode=1, 全仓)", symbol)
 	return 1
 }
 
@@ -1240,11 +1835,239 @@ func (t *WeexTrader) GetMarketPrice(symbol string) (float64, error) {
 	return price, nil
 }
 
-// SetStopLoss 设置止损单
+// GetMarketPrices 批量获取市场价格
+// 以有限并发（priceFetchConcurrency）逐个查询ticker，返回 symbol -> price（key与传入的symbol一致）
+// 单个交易对失败不会中断整体请求，失败信息汇总在返回的error中，成功的价格仍然返回
+func (t *WeexTrader) GetMarketPrices(symbols []string) (map[string]float64, error) {
+	prices := make(map[string]float64, len(symbols))
+	if len(symbols) == 0 {
+		return prices, nil
+	}
+
+	concurrency := t.priceFetchConcurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		errs []error
+		seen = make(map[string]bool, len(symbols))
+		sem  = make(chan struct{}, concurrency)
+	)
+
+	for _, symbol := range symbols {
+		if seen[symbol] {
+			continue
+		}
+		seen[symbol] = true
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(symbol string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			price, err := t.GetMarketPrice(symbol)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", symbol, err))
+				return
+			}
+			prices[symbol] = price
+		}(symbol)
+	}
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return prices, fmt.Errorf("%d/%d 个交易对获取价格失败: %w", len(errs), len(seen), errors.Join(errs...))
+	}
+	return prices, nil
+}
+
+// WeexBookLevel 订单簿的一档报价
+type WeexBookLevel struct {
+	Price    float64
+	Quantity float64
+}
+
+// WeexOrderBook 订单簿快照，Bids 按价格从高到低、Asks 按价格从低到高排列（第一档为最优价）
+type WeexOrderBook struct {
+	Symbol    string
+	Bids      []WeexBookLevel
+	Asks      []WeexBookLevel
+	Timestamp time.Time
+}
+
+// WeexFillEstimate 按订单簿估算的市价单成交结果
+type WeexFillEstimate struct {
+	Side           string  // buy 或 sell
+	Quantity       float64 // 请求数量
+	FilledQuantity float64 // 订单簿可以吃下的数量，深度不足时小于 Quantity
+	AvgPrice       float64 // 成交均价
+	BestPrice      float64 // 第一档价格
+	WorstPrice     float64 // 吃到的最后一档价格
+	SlippagePct    float64 // 成交均价相对第一档的不利偏离（%），始终 >= 0
+	Complete       bool    // 订单簿深度足以成交全部数量
+}
+
+// weexDepthLimits WEEX 深度接口支持的档位数
+var weexDepthLimits = []int{15, 200}
+
+// GetOrderBook 获取交易对的订单簿，depth 为每侧返回的档位数（<= 0 时为 15）
+// WEEX 只支持 15 档和 200 档，按不小于 depth 的最小档位请求后截断
+func (t *WeexTrader) GetOrderBook(symbol string, depth int) (*WeexOrderBook, error) {
+	symbol = t.normalizeSymbol(symbol)
+	if depth <= 0 {
+		depth = weexDepthLimits[0]
+	}
+	limit := weexDepthLimits[len(weexDepthLimits)-1]
+	for _, l := range weexDepthLimits {
+		if depth <= l {
+			limit = l
+			break
+		}
+	}
+
+	// GET /capi/v2/market/depth?symbol=cmt_btcusdt&limit=15
+	// 响应格式: {"asks":[["price","qty"],...], "bids":[["price","qty"],...], "timestamp":"1716710918113"}
+	queryString := fmt.Sprintf("?symbol=%s&limit=%d", symbol, limit)
+	result, err := t.sendRequest("GET", "/capi/v2/market/depth", queryString, nil)
+	if err != nil {
+		return nil, fmt.Errorf("获取订单簿失败: %w", err)
+	}
+
+	bids, err := weexParseBookLevels(result["bids"])
+	if err != nil {
+		return nil, fmt.Errorf("解析买盘失败: %w", err)
+	}
+	asks, err := weexParseBookLevels(result["asks"])
+	if err != nil {
+		return nil, fmt.Errorf("解析卖盘失败: %w", err)
+	}
+	sort.Slice(bids, func(i, j int) bool { return bids[i].Price > bids[j].Price })
+	sort.Slice(asks, func(i, j int) bool { return asks[i].Price < asks[j].Price })
+	if len(bids) > depth {
+		bids = bids[:depth]
+	}
+	if len(asks) > depth {
+		asks = asks[:depth]
+	}
+
+	book := &WeexOrderBook{Symbol: symbol, Bids: bids, Asks: asks}
+	if ms, ok := weexMapFloat(result, "timestamp", "ts"); ok && ms > 0 {
+		book.Timestamp = time.UnixMilli(int64(ms))
+	}
+	return book, nil
+}
+
+// weexParseBookLevels 解析 [[price, qty], ...] 格式的档位，价格和数量可以是字符串或数字
+func weexParseBookLevels(raw interface{}) ([]WeexBookLevel, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	rows, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("档位格式错误: %v", raw)
+	}
+	levels := make([]WeexBookLevel, 0, len(rows))
+	for _, row := range rows {
+		pair, ok := row.([]interface{})
+		if !ok || len(pair) < 2 {
+			return nil, fmt.Errorf("档位格式错误: %v", row)
+		}
+		price, priceOK := weexFloatValue(pair[0])
+		qty, qtyOK := weexFloatValue(pair[1])
+		if !priceOK || !qtyOK {
+			return nil, fmt.Errorf("档位价格或数量无效: %v", row)
+		}
+		if price <= 0 || qty <= 0 {
+			continue
+		}
+		levels = append(levels, WeexBookLevel{Price: price, Quantity: qty})
+	}
+	return levels, nil
+}
+
+// EstimateMarketFillPrice 按当前订单簿估算市价单的成交均价与滑点
+// side 为 buy/long（吃卖盘）或 sell/short（吃买盘）；订单簿深度不足时按可成交部分估算，Complete 为 false
+func (t *WeexTrader) EstimateMarketFillPrice(symbol, side string, quantity float64) (*WeexFillEstimate, error) {
+	if quantity <= 0 {
+		return nil, fmt.Errorf("数量必须大于 0: %v", quantity)
+	}
+	side = strings.ToLower(side)
+	switch side {
+	case "buy", "long":
+		side = "buy"
+	case "sell", "short":
+		side = "sell"
+	default:
+		return nil, fmt.Errorf("不支持的方向: %s", side)
+	}
+
+	book, err := t.GetOrderBook(symbol, weexDepthLimits[len(weexDepthLimits)-1])
+	if err != nil {
+		return nil, err
+	}
+	return book.EstimateFill(side, quantity)
+}
+
+// EstimateFill 从最优价开始逐档吃单，估算 quantity 的成交均价与滑点
+// side 为 buy（吃 Asks）或 sell（吃 Bids）
+func (b *WeexOrderBook) EstimateFill(side string, quantity float64) (*WeexFillEstimate, error) {
+	levels := b.Asks
+	if side == "sell" {
+		levels = b.Bids
+	} else if side != "buy" {
+		return nil, fmt.Errorf("不支持的方向: %s", side)
+	}
+	if len(levels) == 0 {
+		return nil, fmt.Errorf("%s 订单簿%s侧为空", b.Symbol, side)
+	}
+
+	estimate := &WeexFillEstimate{Side: side, Quantity: quantity, BestPrice: levels[0].Price}
+	// 剩余数量小于该容差视为已全部成交，避免浮点误差多吃一档
+	epsilon := quantity * 1e-9
+	remaining := quantity
+	var notional float64
+	for _, level := range levels {
+		if remaining <= epsilon {
+			break
+		}
+		take := math.Min(remaining, level.Quantity)
+		remaining -= take
+		notional += take * level.Price
+		estimate.WorstPrice = level.Price
+	}
+
+	estimate.Complete = remaining <= epsilon
+	estimate.FilledQuantity = quantity - math.Max(remaining, 0)
+	if estimate.Complete {
+		estimate.FilledQuantity = quantity
+	}
+	estimate.AvgPrice = notional / estimate.FilledQuantity
+	estimate.SlippagePct = math.Abs(estimate.AvgPrice-estimate.BestPrice) / estimate.BestPrice * 100
+	return estimate, nil
+}
+
+// SetStopLoss 设置止损单，按默认触发价格类型（见 WithWeexTriggerPriceType）触发
+func (t *WeexTrader) SetStopLoss(symbol string, positionSide string, quantity, stopPrice float64) error {
+	return t.SetStopLossWithTrigger(symbol, positionSide, quantity, stopPrice, t.triggerPriceType)
+}
+
+// SetStopLossWithTrigger 设置止损单，triggerPriceType 指定按最新价/标记价/指数价触发（空值使用默认类型）
 // ✅ WEEX特殊处理：
 // - 如果有持仓：创建计划委托订单
-// - 如果无持仓：存储到pending map，开仓时通过presetStopLossPrice参数设置
-func (t *WeexTrader) SetStopLoss(symbol string, positionSide string, quantity, stopPrice float64) error {
+// - 如果无持仓：存储到pending map，开仓时通过presetStopLossPrice参数设置（预设止损不支持指定触发类型）
+func (t *WeexTrader) SetStopLossWithTrigger(symbol string, positionSide string, quantity, stopPrice float64, triggerPriceType WeexTriggerPriceType) error {
+	triggerPriceType, err := t.resolveTriggerPriceType(triggerPriceType)
+	if err != nil {
+		return err
+	}
+
 	// 转换交易对格式为WEEX格式
 	symbol = t.normalizeSymbol(symbol)
 
@@ -1304,7 +2127,7 @@ func (t *WeexTrader) SetStopLoss(symbol string, positionSide string, quantity, s
 
 	// 如果有持仓，创建计划委托订单
 	if hasPosition {
-		return t.createStopLossPlanOrder(symbol, positionSide, quantity, alignedPrice, priceDecimals)
+		return t.createStopLossPlanOrder(symbol, positionSide, quantity, alignedPrice, priceDecimals, triggerPriceType)
 	}
 
 	// 如果没有持仓，存储止损价格，在开仓时使用
@@ -1312,12 +2135,12 @@ func (t *WeexTrader) SetStopLoss(symbol string, positionSide string, quantity, s
 	t.pendingStopLoss[symbol] = alignedPrice
 	t.pendingPricesMutex.Unlock()
 
-	logger.Infof("  ✓ [WEEX] 止损价格已存储: %s @ %.4f (将在开仓时设置)", symbol, alignedPrice)
+	t.logger.Infof("  ✓ [WEEX] 止损价格已存储: %s @ %.4f (将在开仓时设置)", symbol, alignedPrice)
 	return nil
 }
 
 // createStopLossPlanOrder 创建计划委托止损单（用于已有持仓）
-func (t *WeexTrader) createStopLossPlanOrder(symbol string, positionSide string, quantity, triggerPrice float64, priceDecimals int) error {
+func (t *WeexTrader) createStopLossPlanOrder(symbol string, positionSide string, quantity, triggerPrice float64, priceDecimals int, triggerPriceType WeexTriggerPriceType) error {
 	// 格式化数量
 	quantityStr, err := t.FormatQuantity(symbol, quantity)
 	if err != nil {
@@ -1349,6 +2172,8 @@ func (t *WeexTrader) createStopLossPlanOrder(symbol string, positionSide string,
 		"execute_price": triggerPriceStr,  // 执行价格=触发价格
 		"trigger_price": triggerPriceStr,  // 触发价格
 		"marginMode":    marginMode,
+
+		"trigger_price_type": string(triggerPriceType), // 触发价格类型：last/mark/index
 	}
 
 	result, err := t.sendRequest("POST", "/capi/v2/order/plan_order", "", body)
@@ -1358,16 +2183,26 @@ func (t *WeexTrader) createStopLossPlanOrder(symbol string, positionSide string,
 
 	// 解析返回结果
 	orderID, _ := result["order_id"].(string)
-	logger.Infof("  ✓ [WEEX] 计划委托止损单创建成功: %s @ %s, 订单ID: %s", symbol, triggerPriceStr, orderID)
+	t.logger.Infof("  ✓ [WEEX] 计划委托止损单创建成功: %s @ %s (%s), 订单ID: %s", symbol, triggerPriceStr, triggerPriceType, orderID)
 
 	return nil
 }
 
-// SetTakeProfit 设置止盈单
+// SetTakeProfit 设置止盈单，按默认触发价格类型（见 WithWeexTriggerPriceType）触发
+func (t *WeexTrader) SetTakeProfit(symbol string, positionSide string, quantity, takeProfitPrice float64) error {
+	return t.SetTakeProfitWithTrigger(symbol, positionSide, quantity, takeProfitPrice, t.triggerPriceType)
+}
+
+// SetTakeProfitWithTrigger 设置止盈单，triggerPriceType 指定按最新价/标记价/指数价触发（空值使用默认类型）
 // ✅ WEEX特殊处理：
 // - 如果有持仓：创建计划委托订单
-// - 如果无持仓：存储到pending map，开仓时通过presetTakeProfitPrice参数设置
-func (t *WeexTrader) SetTakeProfit(symbol string, positionSide string, quantity, takeProfitPrice float64) error {
+// - 如果无持仓：存储到pending map，开仓时通过presetTakeProfitPrice参数设置（预设止盈不支持指定触发类型）
+func (t *WeexTrader) SetTakeProfitWithTrigger(symbol string, positionSide string, quantity, takeProfitPrice float64, triggerPriceType WeexTriggerPriceType) error {
+	triggerPriceType, err := t.resolveTriggerPriceType(triggerPriceType)
+	if err != nil {
+		return err
+	}
+
 	// 转换交易对格式为WEEX格式
 	symbol = t.normalizeSymbol(symbol)
 
@@ -1427,7 +2262,7 @@ func (t *WeexTrader) SetTakeProfit(symbol string, positionSide string, quantity,
 
 	// 如果有持仓，创建计划委托订单
 	if hasPosition {
-		return t.createTakeProfitPlanOrder(symbol, positionSide, quantity, alignedPrice, priceDecimals)
+		return t.createTakeProfitPlanOrder(symbol, positionSide, quantity, alignedPrice, priceDecimals, triggerPriceType)
 	}
 
 	// 如果没有持仓，存储止盈价格，在开仓时使用
@@ -1435,12 +2270,12 @@ func (t *WeexTrader) SetTakeProfit(symbol string, positionSide string, quantity,
 	t.pendingTakeProfit[symbol] = alignedPrice
 	t.pendingPricesMutex.Unlock()
 
-	logger.Infof("  ✓ [WEEX] 止盈价格已存储: %s @ %.4f (将在开仓时设置)", symbol, alignedPrice)
+	t.logger.Infof("  ✓ [WEEX] 止盈价格已存储: %s @ %.4f (将在开仓时设置)", symbol, alignedPrice)
 	return nil
 }
 
 // createTakeProfitPlanOrder 创建计划委托止盈单（用于已有持仓）
-func (t *WeexTrader) createTakeProfitPlanOrder(symbol string, positionSide string, quantity, triggerPrice float64, priceDecimals int) error {
+func (t *WeexTrader) createTakeProfitPlanOrder(symbol string, positionSide string, quantity, triggerPrice float64, priceDecimals int, triggerPriceType WeexTriggerPriceType) error {
 	// 格式化数量
 	quantityStr, err := t.FormatQuantity(symbol, quantity)
 	if err != nil {
@@ -1472,6 +2307,8 @@ func (t *WeexTrader) createTakeProfitPlanOrder(symbol string, positionSide strin
 		"execute_price": triggerPriceStr,  // 执行价格=触发价格
 		"trigger_price": triggerPriceStr,  // 触发价格
 		"marginMode":    marginMode,
+
+		"trigger_price_type": string(triggerPriceType), // 触发价格类型：last/mark/index
 	}
 
 	result, err := t.sendRequest("POST", "/capi/v2/order/plan_order", "", body)
@@ -1481,11 +2318,22 @@ func (t *WeexTrader) createTakeProfitPlanOrder(symbol string, positionSide strin
 
 	// 解析返回结果
 	orderID, _ := result["order_id"].(string)
-	logger.Infof("  ✓ [WEEX] 计划委托止盈单创建成功: %s @ %s, 订单ID: %s", symbol, triggerPriceStr, orderID)
+	t.logger.Infof("  ✓ [WEEX] 计划委托止盈单创建成功: %s @ %s (%s), 订单ID: %s", symbol, triggerPriceStr, triggerPriceType, orderID)
 
 	return nil
 }
 
+// resolveTriggerPriceType 校验触发价格类型，空值使用交易器默认类型
+func (t *WeexTrader) resolveTriggerPriceType(priceType WeexTriggerPriceType) (WeexTriggerPriceType, error) {
+	if priceType == "" {
+		return t.triggerPriceType, nil
+	}
+	if !priceType.valid() {
+		return "", fmt.Errorf("不支持的触发价格类型: %q（可选 last/mark/index）", priceType)
+	}
+	return priceType, nil
+}
+
 // CancelStopLossOrders 取消止损单
 func (t *WeexTrader) CancelStopLossOrders(symbol string) error {
 	// 转换交易对格式为WEEX格式
@@ -1500,21 +2348,21 @@ func (t *WeexTrader) CancelStopLossOrders(symbol string) error {
 	}
 
 	// 解析订单列表（返回的是数组）
-	var orders []map[string]interface{}
-	if err := json.Unmarshal(respBody, &orders); err != nil {
+	orders, err := decodeWeexList(respBody)
+	if err != nil {
 		return fmt.Errorf("解析计划委托列表失败: %w", err)
 	}
 
 	// 如果没有计划委托，直接返回
 	if len(orders) == 0 {
-		logger.Infof("  ℹ [WEEX] %s 没有计划委托需要取消", symbol)
+		t.logger.Infof("  ℹ [WEEX] %s 没有计划委托需要取消", symbol)
 		return nil
 	}
 
 	// 获取当前市场价格，用于判断是止损还是止盈
 	marketPrice, err := t.GetMarketPrice(symbol)
 	if err != nil {
-		logger.Infof("⚠️ [WEEX] 获取市场价格失败: %v", err)
+		t.logger.Infof("⚠️ [WEEX] 获取市场价格失败: %v", err)
 		return err
 	}
 
@@ -1559,22 +2407,22 @@ func (t *WeexTrader) CancelStopLossOrders(symbol string) error {
 
 		result, err := t.sendRequest("POST", "/capi/v2/order/cancel_plan", "", body)
 		if err != nil {
-			logger.Infof("  ⚠️ [WEEX] 取消止损单 %s 失败: %v", orderID, err)
+			t.logger.Infof("  ⚠️ [WEEX] 取消止损单 %s 失败: %v", orderID, err)
 			continue
 		}
 
 		// 检查取消结果
 		if resultBool, ok := result["result"].(bool); ok && resultBool {
 			canceledCount++
-			logger.Infof("  ✓ [WEEX] 取消止损单成功: %s @ %.2f", orderID, triggerPrice)
+			t.logger.Infof("  ✓ [WEEX] 取消止损单成功: %s @ %.2f", orderID, triggerPrice)
 		} else {
 			errMsg, _ := result["err_msg"].(string)
-			logger.Infof("  ⚠️ [WEEX] 取消止损单 %s 失败: %s", orderID, errMsg)
+			t.logger.Infof("  ⚠️ [WEEX] 取消止损单 %s 失败: %s", orderID, errMsg)
 		}
 	}
 
 	if canceledCount > 0 {
-		logger.Infof("  ✓ [WEEX] 取消了 %d 个止损单", canceledCount)
+		t.logger.Infof("  ✓ [WEEX] 取消了 %d 个止损单", canceledCount)
 	}
 	return nil
 }
@@ -1593,21 +2441,21 @@ func (t *WeexTrader) CancelTakeProfitOrders(symbol string) error {
 	}
 
 	// 解析订单列表（返回的是数组）
-	var orders []map[string]interface{}
-	if err := json.Unmarshal(respBody, &orders); err != nil {
+	orders, err := decodeWeexList(respBody)
+	if err != nil {
 		return fmt.Errorf("解析计划委托列表失败: %w", err)
 	}
 
 	// 如果没有计划委托，直接返回
 	if len(orders) == 0 {
-		logger.Infof("  ℹ [WEEX] %s 没有计划委托需要取消", symbol)
+		t.logger.Infof("  ℹ [WEEX] %s 没有计划委托需要取消", symbol)
 		return nil
 	}
 
 	// 获取当前市场价格，用于判断是止损还是止盈
 	marketPrice, err := t.GetMarketPrice(symbol)
 	if err != nil {
-		logger.Infof("⚠️ [WEEX] 获取市场价格失败: %v", err)
+		t.logger.Infof("⚠️ [WEEX] 获取市场价格失败: %v", err)
 		return err
 	}
 
@@ -1652,155 +2500,524 @@ func (t *WeexTrader) CancelTakeProfitOrders(symbol string) error {
 
 		result, err := t.sendRequest("POST", "/capi/v2/order/cancel_plan", "", body)
 		if err != nil {
-			logger.Infof("  ⚠️ [WEEX] 取消止盈单 %s 失败: %v", orderID, err)
+			t.logger.Infof("  ⚠️ [WEEX] 取消止盈单 %s 失败: %v", orderID, err)
 			continue
 		}
 
 		// 检查取消结果
 		if resultBool, ok := result["result"].(bool); ok && resultBool {
 			canceledCount++
-			logger.Infof("  ✓ [WEEX] 取消止盈单成功: %s @ %.2f", orderID, triggerPrice)
+			t.logger.Infof("  ✓ [WEEX] 取消止盈单成功: %s @ %.2f", orderID, triggerPrice)
 		} else {
 			errMsg, _ := result["err_msg"].(string)
-			logger.Infof("  ⚠️ [WEEX] 取消止盈单 %s 失败: %s", orderID, errMsg)
+			t.logger.Infof("  ⚠️ [WEEX] 取消止盈单 %s 失败: %s", orderID, errMsg)
 		}
 	}
 
 	if canceledCount > 0 {
-		logger.Infof("  ✓ [WEEX] 取消了 %d 个止盈单", canceledCount)
+		t.logger.Infof("  ✓ [WEEX] 取消了 %d 个止盈单", canceledCount)
 	}
 	return nil
 }
 
+// weexCancelTarget 一类挂单的查询接口、逐个撤单接口和批量撤单类型
+type weexCancelTarget struct {
+	name       string // 日志和错误中的订单类型名称
+	listPath   string // 查询当前订单
+	cancelPath string // 逐个撤单
+	batchType  string // cancelAllOrders 的 cancelOrderType
+}
+
+var (
+	weexNormalOrders = weexCancelTarget{
+		name:       "挂单",
+		listPath:   "/capi/v2/order/current",
+		cancelPath: "/capi/v2/order/cancel_order",
+		batchType:  "normal",
+	}
+	weexPlanOrders = weexCancelTarget{
+		name:       "计划委托订单",
+		listPath:   "/capi/v2/order/currentPlan",
+		cancelPath: "/capi/v2/order/cancel_plan",
+		batchType:  "plan",
+	}
+)
+
 // CancelAllOrders 取消所有挂单
+// 注意：只要有一个订单撤销失败就返回错误（以前逐单失败只记日志、仍返回 nil），
+// 此时其余订单可能已经撤销，需要撤销数量时使用 CancelAllOrdersCount
 func (t *WeexTrader) CancelAllOrders(symbol string) error {
+	_, err := t.CancelAllOrdersCount(symbol)
+	return err
+}
+
+// CancelAllOrdersCount 取消所有挂单，返回成功撤销的数量；部分失败时数量和错误同时返回
+func (t *WeexTrader) CancelAllOrdersCount(symbol string) (int, error) {
+	return t.cancelOrders(symbol, weexNormalOrders)
+}
+
+// cancelOrders 取消某交易对的一类挂单，返回成功撤销的数量和逐单错误（errors.Join）。
+// 先用 cancelAllOrders 一次撤销全部；批量接口失败时逐个撤单，
+// 批量结果中没有出现的订单同样逐个撤销
+func (t *WeexTrader) cancelOrders(symbol string, target weexCancelTarget) (int, error) {
 	// 转换交易对格式为WEEX格式
 	symbol = t.normalizeSymbol(symbol)
 
-	// 先获取当前所有挂单
-	// GET /capi/v2/order/current?symbol=xxx
+	// 先获取当前订单，没有订单时不发撤单请求
+	// GET {listPath}?symbol=xxx
 	queryString := fmt.Sprintf("?symbol=%s", symbol)
-	respBody, err := t.sendRequestRaw("GET", "/capi/v2/order/current", queryString, nil)
+	respBody, err := t.sendRequestRaw("GET", target.listPath, queryString, nil)
 	if err != nil {
-		return fmt.Errorf("获取当前挂单失败: %w", err)
+		return 0, fmt.Errorf("获取当前%s失败: %w", target.name, err)
 	}
 
-	// 解析订单列表（返回的是数组）
-	var orders []map[string]interface{}
-	if err := json.Unmarshal(respBody, &orders); err != nil {
-		return fmt.Errorf("解析订单列表失败: %w", err)
+	orders, err := decodeWeexList(respBody)
+	if err != nil {
+		return 0, fmt.Errorf("解析%s列表失败: %w", target.name, err)
 	}
 
-	// 如果没有挂单，直接返回
-	if len(orders) == 0 {
-		logger.Infof("  ℹ [WEEX] %s 没有挂单需要取消", symbol)
-		return nil
+	var orderIDs []string
+	for _, order := range orders {
+		if orderID := weexStringValue(order["order_id"]); orderID != "" {
+			orderIDs = append(orderIDs, orderID)
+		}
+	}
+	if len(orderIDs) == 0 {
+		t.logger.Infof("  ℹ [WEEX] %s 没有%s需要取消", symbol, target.name)
+		return 0, nil
 	}
 
-	// 遍历所有订单，逐个取消
 	canceledCount := 0
-	for _, order := range orders {
-		orderID, _ := order["order_id"].(string)
-		if orderID == "" {
-			continue
+	var errs []error
+	pending := orderIDs
+	if results, err := t.batchCancelOrders(symbol, target); err != nil {
+		t.logger.Warnf("  ⚠️ [WEEX] 批量取消%s失败，改为逐个取消: %v", target.name, err)
+	} else {
+		pending = nil
+		for _, orderID := range orderIDs {
+			success, reported := results[orderID]
+			switch {
+			case !reported:
+				pending = append(pending, orderID)
+			case success:
+				canceledCount++
+			default:
+				errs = append(errs, fmt.Errorf("取消%s %s 失败", target.name, orderID))
+			}
 		}
+	}
 
-		// 调用撤单接口
-		// POST /capi/v2/order/cancel_order
-		body := map[string]interface{}{
-			"orderId": orderID,
+	for _, orderID := range pending {
+		if err := t.cancelOrderByID(target.cancelPath, orderID); err != nil {
+			t.logger.Infof("  ⚠️ [WEEX] 取消%s %s 失败: %v", target.name, orderID, err)
+			errs = append(errs, fmt.Errorf("取消%s %s 失败: %w", target.name, orderID, err))
+			continue
 		}
+		canceledCount++
+		t.logger.Infof("  ✓ [WEEX] 取消%s成功: %s", target.name, orderID)
+	}
 
-		result, err := t.sendRequest("POST", "/capi/v2/order/cancel_order", "", body)
-		if err != nil {
-			logger.Infof("  ⚠️ [WEEX] 取消订单 %s 失败: %v", orderID, err)
-			continue
+	t.logger.Infof("  ✓ [WEEX] 取消了 %d/%d 个%s", canceledCount, len(orderIDs), target.name)
+	return canceledCount, errors.Join(errs...)
+}
+
+// batchCancelOrders 调用 cancelAllOrders 一次撤销交易对的一类订单，返回 order_id -> 是否撤销成功
+func (t *WeexTrader) batchCancelOrders(symbol string, target weexCancelTarget) (map[string]bool, error) {
+	// POST /capi/v2/order/cancelAllOrders
+	body := map[string]interface{}{
+		"symbol":          symbol,
+		"cancelOrderType": target.batchType,
+	}
+	respBody, err := t.sendRequestRaw("POST", "/capi/v2/order/cancelAllOrders", "", body)
+	if err != nil {
+		return nil, err
+	}
+
+	// 返回数组: [{"orderId": "...", "success": true}]
+	entries, err := decodeWeexList(respBody)
+	if err != nil {
+		return nil, fmt.Errorf("解析批量撤单结果失败: %w", err)
+	}
+	results := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		success, _ := entry["success"].(bool)
+		results[weexStringValue(entry["orderId"])] = success
+	}
+	return results, nil
+}
+
+// cancelOrderByID 按 order_id 撤销单个订单
+func (t *WeexTrader) cancelOrderByID(cancelPath, orderID string) error {
+	body := map[string]interface{}{
+		"orderId": orderID,
+	}
+	result, err := t.sendRequest("POST", cancelPath, "", body)
+	if err != nil {
+		return err
+	}
+	if resultBool, ok := result["result"].(bool); !ok || !resultBool {
+		errMsg, _ := result["err_msg"].(string)
+		if errMsg == "" {
+			errMsg = "交易所未确认撤单"
 		}
+		return errors.New(errMsg)
+	}
+	return nil
+}
 
-		// 检查取消结果
-		if resultBool, ok := result["result"].(bool); ok && resultBool {
-			canceledCount++
-			logger.Infof("  ✓ [WEEX] 取消订单成功: %s", orderID)
-		} else {
-			errMsg, _ := result["err_msg"].(string)
-			logger.Infof("  ⚠️ [WEEX] 取消订单 %s 失败: %s", orderID, errMsg)
+// CancelOrderByClientOid 按 client_oid 撤销挂单
+// 下单响应丢失时只知道本地的 client_oid：从当前挂单中找到对应订单后按 order_id 撤销。
+// 没有对应挂单时返回 ErrWeexOrderNotFound（订单未创建，或已成交/已撤销，需结合持仓判断）
+func (t *WeexTrader) CancelOrderByClientOid(symbol, clientOid string) error {
+	// 转换交易对格式为WEEX格式
+	symbol = t.normalizeSymbol(symbol)
+
+	// GET /capi/v2/order/current?symbol=xxx
+	queryString := fmt.Sprintf("?symbol=%s", symbol)
+	respBody, err := t.sendRequestRaw("GET", "/capi/v2/order/current", queryString, nil)
+	if err != nil {
+		return fmt.Errorf("获取当前挂单失败: %w", err)
+	}
+
+	orders, err := decodeWeexList(respBody)
+	if err != nil {
+		return fmt.Errorf("解析订单列表失败: %w", err)
+	}
+
+	orderID := ""
+	for _, order := range orders {
+		if weexStringValue(order["client_oid"]) == clientOid {
+			orderID = weexStringValue(order["order_id"])
+			break
 		}
 	}
+	if orderID == "" {
+		return fmt.Errorf("%s client_oid=%s: %w", symbol, clientOid, ErrWeexOrderNotFound)
+	}
+
+	// POST /capi/v2/order/cancel_order
+	body := map[string]interface{}{
+		"orderId": orderID,
+	}
+	result, err := t.sendRequest("POST", "/capi/v2/order/cancel_order", "", body)
+	if err != nil {
+		return fmt.Errorf("取消订单 %s 失败: %w", orderID, err)
+	}
+	if resultBool, ok := result["result"].(bool); !ok || !resultBool {
+		errMsg, _ := result["err_msg"].(string)
+		return fmt.Errorf("取消订单 %s 失败: %s", orderID, errMsg)
+	}
 
-	logger.Infof("  ✓ [WEEX] 取消了 %d/%d 个挂单", canceledCount, len(orders))
+	t.logger.Infof("  ✓ [WEEX] 按 client_oid 取消订单成功: %s (订单ID: %s)", clientOid, orderID)
+	t.clearCache()
 	return nil
 }
 
 // CancelStopOrders 取消止损止盈单
 func (t *WeexTrader) CancelStopOrders(symbol string) error {
 	if err := t.CancelStopLossOrders(symbol); err != nil {
-		logger.Infof("⚠️ [WEEX] 取消止损单失败: %v", err)
+		t.logger.Infof("⚠️ [WEEX] 取消止损单失败: %v", err)
 	}
 	if err := t.CancelTakeProfitOrders(symbol); err != nil {
-		logger.Infof("⚠️ [WEEX] 取消止盈单失败: %v", err)
+		t.logger.Infof("⚠️ [WEEX] 取消止盈单失败: %v", err)
 	}
 	return nil
 }
 
 // CancelPlanOrders 取消所有计划委托订单（包括止损止盈）
+// 与 CancelAllOrders 相同，任一订单撤销失败即返回错误，需要撤销数量时使用 CancelPlanOrdersCount
 func (t *WeexTrader) CancelPlanOrders(symbol string) error {
+	_, err := t.CancelPlanOrdersCount(symbol)
+	return err
+}
+
+// CancelPlanOrdersCount 取消所有计划委托订单，返回成功撤销的数量；部分失败时数量和错误同时返回
+func (t *WeexTrader) CancelPlanOrdersCount(symbol string) (int, error) {
+	return t.cancelOrders(symbol, weexPlanOrders)
+}
+
+// WeexReconcileReport Reconcile 的对账结果
+type WeexReconcileReport struct {
+	Symbol        string   // 标准格式交易对（如 BTCUSDT）
+	PositionSides []string // 交易所上的持仓方向（LONG/SHORT）
+	Discrepancies []string // 发现的不一致
+	Actions       []string // 已执行的修复动作
+}
+
+// Reconcile 对账：以交易所持仓为准，检查计划委托、普通挂单与本地待设置止盈止损是否一致
+// - 没有对应持仓的计划委托（止损止盈）会被撤销
+// - 没有持仓时残留的 pendingStopLoss/pendingTakeProfit 会被清除
+// - 没有持仓时仍挂着的普通订单只报告，不自动撤销（可能是正在进行的开仓）
+// 用于崩溃重启或响应丢失之后，不要与该交易对的开仓并发调用（开仓前的待设置价格也会被清除）
+func (t *WeexTrader) Reconcile(symbol string) (*WeexReconcileReport, error) {
 	// 转换交易对格式为WEEX格式
 	symbol = t.normalizeSymbol(symbol)
+	report := &WeexReconcileReport{Symbol: strings.ToUpper(strings.TrimPrefix(symbol, "cmt_"))}
 
-	// 查询当前计划委托订单
-	// GET /capi/v2/order/currentPlan?symbol=xxx
-	queryString := fmt.Sprintf("?symbol=%s", symbol)
-	respBody, err := t.sendRequestRaw("GET", "/capi/v2/order/currentPlan", queryString, nil)
+	// 对账必须使用最新持仓，不使用缓存
+	t.clearCache()
+	positions, err := t.GetPositions()
 	if err != nil {
-		return fmt.Errorf("获取计划委托订单失败: %w", err)
+		return nil, err
 	}
-
-	// 解析订单列表（返回的是数组）
-	var orders []map[string]interface{}
-	if err := json.Unmarshal(respBody, &orders); err != nil {
-		return fmt.Errorf("解析计划委托订单列表失败: %w", err)
+	hasSide := make(map[string]bool)
+	for _, pos := range positions {
+		if posSymbol, _ := pos["symbol"].(string); posSymbol != report.Symbol {
+			continue
+		}
+		side, _ := pos["side"].(string)
+		side = strings.ToUpper(side)
+		if !hasSide[side] {
+			hasSide[side] = true
+			report.PositionSides = append(report.PositionSides, side)
+		}
 	}
 
-	// 如果没有计划委托订单，直接返回
-	if len(orders) == 0 {
-		logger.Infof("  ℹ [WEEX] %s 没有计划委托订单需要取消", symbol)
-		return nil
-	}
+	var errs []error
 
-	// 遍历所有订单，逐个取消
-	canceledCount := 0
-	for _, order := range orders {
-		orderID, _ := order["order_id"].(string)
-		if orderID == "" {
+	// 1. 计划委托：平仓方向没有对应持仓的视为孤儿单，撤销
+	queryString := fmt.Sprintf("?symbol=%s", symbol)
+	respBody, err := t.sendRequestRaw("GET", "/capi/v2/order/currentPlan", queryString, nil)
+	if err != nil {
+		return nil, fmt.Errorf("获取计划委托订单失败: %w", err)
+	}
+	planOrders, err := decodeWeexList(respBody)
+	if err != nil {
+		return nil, fmt.Errorf("解析计划委托订单列表失败: %w", err)
+	}
+	for _, order := range planOrders {
+		orderID := weexStringValue(order["order_id"])
+		if orderID == "" || !weexPlanOrderIsActive(order["status"]) {
+			continue
+		}
+		closeSide, ok := weexPlanOrderCloseSide(weexMapString(order, "type"))
+		if !ok || hasSide[closeSide] {
 			continue
 		}
 
-		// 调用撤单接口
-		// POST /capi/v2/order/cancel_plan
+		report.Discrepancies = append(report.Discrepancies,
+			fmt.Sprintf("计划委托 %s 平%s仓，但没有%s持仓", orderID, closeSide, closeSide))
 		body := map[string]interface{}{
 			"orderId": orderID,
 		}
-
 		result, err := t.sendRequest("POST", "/capi/v2/order/cancel_plan", "", body)
 		if err != nil {
-			logger.Infof("  ⚠️ [WEEX] 取消计划委托订单 %s 失败: %v", orderID, err)
+			errs = append(errs, fmt.Errorf("取消计划委托订单 %s 失败: %w", orderID, err))
 			continue
 		}
-
-		// 检查取消结果
-		if resultBool, ok := result["result"].(bool); ok && resultBool {
-			canceledCount++
-			logger.Infof("  ✓ [WEEX] 取消计划委托订单成功: %s", orderID)
-		} else {
+		if resultBool, ok := result["result"].(bool); !ok || !resultBool {
 			errMsg, _ := result["err_msg"].(string)
-			logger.Infof("  ⚠️ [WEEX] 取消计划委托订单 %s 失败: %s", orderID, errMsg)
+			errs = append(errs, fmt.Errorf("取消计划委托订单 %s 失败: %s", orderID, errMsg))
+			continue
+		}
+		report.Actions = append(report.Actions, fmt.Sprintf("已撤销计划委托 %s", orderID))
+	}
+
+	if len(report.PositionSides) == 0 {
+		// 2. 普通挂单：没有持仓时只报告
+		respBody, err := t.sendRequestRaw("GET", "/capi/v2/order/current", queryString, nil)
+		if err != nil {
+			return nil, fmt.Errorf("获取当前挂单失败: %w", err)
+		}
+		orders, err := decodeWeexList(respBody)
+		if err != nil {
+			return nil, fmt.Errorf("解析订单列表失败: %w", err)
 		}
+		for _, order := range orders {
+			if orderID := weexStringValue(order["order_id"]); orderID != "" {
+				report.Discrepancies = append(report.Discrepancies,
+					fmt.Sprintf("挂单 %s (client_oid=%s) 没有对应持仓，未自动撤销", orderID, weexStringValue(order["client_oid"])))
+			}
+		}
+
+		// 3. 本地待设置的止盈止损：没有持仓时清除
+		t.pendingPricesMutex.Lock()
+		if price, ok := t.pendingStopLoss[symbol]; ok {
+			delete(t.pendingStopLoss, symbol)
+			report.Discrepancies = append(report.Discrepancies, fmt.Sprintf("残留待设置止损 %.4f", price))
+			report.Actions = append(report.Actions, "已清除待设置止损")
+		}
+		if price, ok := t.pendingTakeProfit[symbol]; ok {
+			delete(t.pendingTakeProfit, symbol)
+			report.Discrepancies = append(report.Discrepancies, fmt.Sprintf("残留待设置止盈 %.4f", price))
+			report.Actions = append(report.Actions, "已清除待设置止盈")
+		}
+		t.pendingPricesMutex.Unlock()
+	}
+
+	t.logger.Infof("✓ [WEEX] %s 对账完成: 发现 %d 处不一致, 执行 %d 项修复",
+		report.Symbol, len(report.Discrepancies), len(report.Actions))
+	return report, errors.Join(errs...)
+}
+
+// weexMaxClockOffset 本地时钟与 WEEX 服务器时间允许的最大偏差，超过后签名时间戳可能被拒绝
+const weexMaxClockOffset = 30 * time.Second
+
+// WeexHealthReport Ping 的自检结果
+type WeexHealthReport struct {
+	Reachable     bool          // 服务器时间接口可访问
+	Latency       time.Duration // 服务器时间请求的往返耗时
+	ServerTime    time.Time     // 服务器时间
+	ClockOffset   time.Duration // 服务器时间 - 本地时间（按往返中点估算），正数表示本地时钟偏慢
+	ClockSkewed   bool          // |ClockOffset| 超过 weexMaxClockOffset
+	Authenticated bool          // API Key / Secret / Passphrase 通过签名校验（账户资产查询成功）
+	Equity        float64       // 账户总权益（USDT），认证成功时有效
+	ConnError     error         // 服务器时间请求失败的原因
+	AuthError     error         // 账户资产查询失败的原因（认证失败、权限不足等）
+}
+
+// Healthy 连接、认证与时钟偏差均正常，可以开始交易
+func (r *WeexHealthReport) Healthy() bool {
+	return r.Reachable && r.Authenticated && !r.ClockSkewed
+}
+
+// Err 汇总自检中发现的问题，健康时返回 nil
+func (r *WeexHealthReport) Err() error {
+	var errs []error
+	if r.ConnError != nil {
+		errs = append(errs, fmt.Errorf("服务器不可达: %w", r.ConnError))
+	}
+	if r.AuthError != nil {
+		errs = append(errs, fmt.Errorf("认证失败: %w", r.AuthError))
+	}
+	if r.ClockSkewed {
+		errs = append(errs, fmt.Errorf("本地时钟偏差 %v 超过 %v", r.ClockOffset, weexMaxClockOffset))
 	}
+	return errors.Join(errs...)
+}
+
+// Ping 交易前自检：获取服务器时间（测量延迟与时钟偏差），并查询账户资产验证 API 凭证
+// 不依赖持仓，不发送任何写请求；两项检查相互独立，一项失败不影响另一项的结果
+func (t *WeexTrader) Ping() *WeexHealthReport {
+	report := &WeexHealthReport{}
 
-	logger.Infof("  ✓ [WEEX] 取消了 %d/%d 个计划委托订单", canceledCount, len(orders))
+	// 1. 服务器时间（公共接口，不需要签名）
+	start := time.Now()
+	serverTime, err := t.getServerTime()
+	report.Latency = time.Since(start)
+	if err != nil {
+		report.ConnError = err
+	} else {
+		report.Reachable = true
+		report.ServerTime = serverTime
+		report.ClockOffset = serverTime.Sub(start.Add(report.Latency / 2))
+		report.ClockSkewed = report.ClockOffset > weexMaxClockOffset || report.ClockOffset < -weexMaxClockOffset
+	}
+
+	// 2. 认证：必须真正发出签名请求，不使用余额缓存
+	t.balanceCacheMutex.Lock()
+	t.cachedBalance = nil
+	t.balanceCacheMutex.Unlock()
+	balance, err := t.GetBalance()
+	if err != nil {
+		report.AuthError = err
+	} else {
+		report.Authenticated = true
+		report.Equity, _ = balance["totalEquity"].(float64)
+	}
+
+	if report.Healthy() {
+		t.logger.Infof("✓ [WEEX] 自检通过: 延迟=%v, 时钟偏差=%v, 总权益=%.2f",
+			report.Latency, report.ClockOffset, report.Equity)
+	} else {
+		t.logger.Warnf("⚠️ [WEEX] 自检未通过: %v", report.Err())
+	}
+	return report
+}
+
+// getServerTime 获取 WEEX 服务器时间
+// 响应格式: {"epoch":"1716710918.113","iso":"2024-05-26T08:08:38.113Z","timestamp":1716710918113}
+func (t *WeexTrader) getServerTime() (time.Time, error) {
+	result, err := t.sendRequest("GET", "/capi/v2/market/time", "", nil)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("获取服务器时间失败: %w", err)
+	}
+	if ms, ok := weexMapFloat(result, "timestamp"); ok && ms > 0 {
+		return time.UnixMilli(int64(ms)), nil
+	}
+	if epoch, ok := weexMapFloat(result, "epoch"); ok && epoch > 0 {
+		return time.UnixMilli(int64(math.Round(epoch * 1000))), nil
+	}
+	return time.Time{}, fmt.Errorf("服务器时间响应缺少 timestamp: %v", result)
+}
+
+// weexClockSyncInterval 后台时钟同步的默认间隔
+const weexClockSyncInterval = 10 * time.Minute
+
+// Start 启动后台任务：立即并定期（见 WithWeexClockSyncInterval）与服务器同步时钟，校正签名时间戳
+// ctx 取消或调用 Close 时后台任务退出。启动后必须调用 Close，否则 goroutine 泄漏；
+// 重复启动或 Close 之后再启动返回错误
+func (t *WeexTrader) Start(ctx context.Context) error {
+	t.lifecycleMutex.Lock()
+	defer t.lifecycleMutex.Unlock()
+	if t.closed {
+		return errors.New("WEEX 交易器已关闭")
+	}
+	if t.stopBackground != nil {
+		return errors.New("WEEX 交易器后台任务已启动")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	t.stopBackground = cancel
+	t.backgroundWG.Add(1)
+	go func() {
+		defer t.backgroundWG.Done()
+		t.clockSyncLoop(ctx)
+	}()
 	return nil
 }
 
+// Close 停止 Start 启动的后台任务并等待其退出，之后交易器不能再 Start
+// 可重复调用，也可并发调用：每个调用都在后台任务退出后返回；未 Start 时直接返回
+func (t *WeexTrader) Close() error {
+	t.lifecycleMutex.Lock()
+	t.closed = true
+	cancel := t.stopBackground
+	t.lifecycleMutex.Unlock()
+
+	if cancel != nil {
+		cancel()
+	}
+	t.backgroundWG.Wait()
+	return nil
+}
+
+// clockSyncLoop 立即同步一次时钟，之后按 clockSyncInterval 定期同步，直到 ctx 取消
+func (t *WeexTrader) clockSyncLoop(ctx context.Context) {
+	ticker := time.NewTicker(t.clockSyncInterval)
+	defer ticker.Stop()
+	for {
+		t.syncClock()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// syncClock 测量服务器时钟偏差（按往返中点估算）并用于校正签名时间戳，失败时保留上次的偏差
+func (t *WeexTrader) syncClock() {
+	start := time.Now()
+	serverTime, err := t.getServerTime()
+	if err != nil {
+		t.logger.Warnf("⚠️ [WEEX] 时钟同步失败: %v", err)
+		return
+	}
+	offset := serverTime.Sub(start.Add(time.Since(start) / 2))
+	t.clockOffset.Store(int64(offset))
+	if offset > weexMaxClockOffset || offset < -weexMaxClockOffset {
+		t.logger.Warnf("⚠️ [WEEX] 本地时钟偏差 %v，签名时间戳已按服务器时间校正", offset)
+	} else {
+		t.logger.Debugf("[WEEX] 时钟同步完成: 偏差=%v", offset)
+	}
+}
+
+// now 返回按服务器时钟偏差校正后的当前时间（未同步时即本地时间）
+func (t *WeexTrader) now() time.Time {
+	return time.Now().Add(time.Duration(t.clockOffset.Load()))
+}
+
 // FormatQuantity 格式化数量到正确精度
 func (t *WeexTrader) FormatQuantity(symbol string, quantity float64) (string, error) {
 	// 转换交易对格式为WEEX格式
@@ -1842,8 +3059,8 @@ func (t *WeexTrader) GetOrderStatus(symbol string, orderID string) (map[string]i
 	}
 
 	// 解析订单列表（返回的是数组）
-	var orders []map[string]interface{}
-	if err := json.Unmarshal(respBody, &orders); err != nil {
+	orders, err := decodeWeexList(respBody)
+	if err != nil {
 		return nil, fmt.Errorf("解析订单数据失败: %w", err)
 	}
 
@@ -1864,49 +3081,270 @@ func (t *WeexTrader) GetOrderStatus(symbol string, orderID string) (map[string]i
 	feeStr, _ := order["fee"].(string)
 	fee, _ := strconv.ParseFloat(feeStr, 64)
 
-	// 转换状态为统一格式
-	// WEEX: pending, open, filled, canceling, canceled, untriggered
-	// 统一: NEW, FILLED, CANCELED, PARTIALLY_FILLED
-	unifiedStatus := "NEW"
+	result := map[string]interface{}{
+		"orderId":     orderID,
+		"symbol":      order["symbol"],
+		"status":      weexUnifiedOrderStatus(status, filledQty),
+		"avgPrice":    priceAvg,
+		"executedQty": filledQty,
+		"commission":  fee,
+	}
+
+	return result, nil
+}
+
+// weexUnifiedOrderStatus 将订单状态转换为统一格式
+// WEEX: pending, open, filled, canceling, canceled, untriggered
+// 统一: NEW, FILLED, CANCELED, PARTIALLY_FILLED
+func weexUnifiedOrderStatus(status string, filledQty float64) string {
 	switch status {
 	case "filled":
-		unifiedStatus = "FILLED"
+		return "FILLED"
 	case "canceled":
-		unifiedStatus = "CANCELED"
+		return "CANCELED"
 	case "open":
 		if filledQty > 0 {
-			unifiedStatus = "PARTIALLY_FILLED"
-		} else {
-			unifiedStatus = "NEW"
+			return "PARTIALLY_FILLED"
 		}
-	case "pending":
-		unifiedStatus = "NEW"
 	}
+	return "NEW"
+}
 
-	result := map[string]interface{}{
-		"orderId":     orderID,
-		"symbol":      order["symbol"],
-		"status":      unifiedStatus,
-		"avgPrice":    priceAvg,
-		"executedQty": filledQty,
-		"commission":  fee,
+// WeexOrder 当前挂单（普通委托）
+type WeexOrder struct {
+	OrderID      string
+	ClientOid    string
+	Symbol       string  // 标准格式交易对（如 BTCUSDT）
+	Side         string  // BUY 或 SELL，Type 无法识别时为空
+	PositionSide string  // LONG 或 SHORT，Type 无法识别时为空
+	Type         string  // 开平仓类型：OPEN_LONG/OPEN_SHORT/CLOSE_LONG/CLOSE_SHORT，无法识别时为交易所原值
+	Price        float64 // 委托价格，市价单为 0
+	Size         float64 // 委托数量
+	Filled       float64 // 已成交数量
+	Status       string  // 统一状态：NEW/PARTIALLY_FILLED/FILLED/CANCELED
+}
+
+// weexOrderTypes 下单接口 type 字段的数字取值
+var weexOrderTypes = map[string]string{
+	"1": "OPEN_LONG",
+	"2": "OPEN_SHORT",
+	"3": "CLOSE_LONG",
+	"4": "CLOSE_SHORT",
+}
+
+// GetOpenOrders 获取交易对的全部当前挂单（普通委托，不含计划委托），用于对账和挂单展示
+func (t *WeexTrader) GetOpenOrders(symbol string) ([]WeexOrder, error) {
+	// 转换交易对格式为WEEX格式
+	symbol = t.normalizeSymbol(symbol)
+
+	// GET /capi/v2/order/current?symbol=xxx
+	queryString := fmt.Sprintf("?symbol=%s", symbol)
+	respBody, err := t.sendRequestRaw("GET", "/capi/v2/order/current", queryString, nil)
+	if err != nil {
+		return nil, fmt.Errorf("获取当前挂单失败: %w", err)
+	}
+
+	orders, err := decodeWeexList(respBody)
+	if err != nil {
+		return nil, fmt.Errorf("解析订单列表失败: %w", err)
 	}
 
+	result := make([]WeexOrder, 0, len(orders))
+	for _, order := range orders {
+		typ := strings.ToUpper(weexMapString(order, "type"))
+		if name, ok := weexOrderTypes[typ]; ok {
+			typ = name
+		}
+		// 无法识别的类型不猜测方向，Side/PositionSide 留空
+		var side, positionSide string
+		switch typ {
+		case "OPEN_LONG", "OPEN_SHORT", "CLOSE_LONG", "CLOSE_SHORT":
+			var action string
+			positionSide, action = weexFillDirection(typ)
+			side = "BUY"
+			if (positionSide == "LONG") == (action == "close") {
+				side = "SELL" // 平多、开空为卖出
+			}
+		}
+
+		orderSymbol := weexMapString(order, "symbol")
+		if orderSymbol == "" {
+			orderSymbol = symbol
+		}
+		price, _ := weexMapFloat(order, "price")
+		size, _ := weexMapFloat(order, "size")
+		filled, _ := weexMapFloat(order, "filled_qty")
+
+		result = append(result, WeexOrder{
+			OrderID:      weexMapString(order, "order_id"),
+			ClientOid:    weexMapString(order, "client_oid"),
+			Symbol:       strings.ToUpper(strings.TrimPrefix(orderSymbol, "cmt_")),
+			Side:         side,
+			PositionSide: positionSide,
+			Type:         typ,
+			Price:        price,
+			Size:         size,
+			Filled:       filled,
+			Status:       weexUnifiedOrderStatus(weexMapString(order, "status"), filled),
+		})
+	}
 	return result, nil
 }
 
-// GetClosedPnL 获取已平仓盈亏记录
+// WaitForFill 轮询订单状态直到订单终结（FILLED/CANCELED）或 ctx 结束
+// acceptPartial 为 true 时部分成交（PARTIALLY_FILLED）也视为终结，否则继续等待（IOC 订单可能只部分成交）
+// 返回最后一次查询到的统一状态和成交均价；ctx 结束时同时返回 ctx 的错误
+func (t *WeexTrader) WaitForFill(ctx context.Context, symbol, orderID string, pollInterval time.Duration, acceptPartial bool) (string, float64, error) {
+	if pollInterval <= 0 {
+		pollInterval = 500 * time.Millisecond // 默认值
+	}
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	var status string
+	var avgPrice float64
+	var lastErr error
+	for {
+		order, err := t.GetOrderStatus(symbol, orderID)
+		if err != nil {
+			// 查询失败（如订单尚未可查）不中断等待，超时时一并返回
+			lastErr = err
+			t.logger.Debugf("[WEEX] 查询订单 %s 状态失败: %v", orderID, err)
+		} else {
+			lastErr = nil
+			status, _ = order["status"].(string)
+			avgPrice, _ = order["avgPrice"].(float64)
+			switch status {
+			case "FILLED", "CANCELED":
+				return status, avgPrice, nil
+			case "PARTIALLY_FILLED":
+				if acceptPartial {
+					return status, avgPrice, nil
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			if lastErr != nil {
+				return status, avgPrice, fmt.Errorf("等待订单 %s 成交超时: %w", orderID, errors.Join(ctx.Err(), lastErr))
+			}
+			return status, avgPrice, fmt.Errorf("等待订单 %s 成交超时（状态: %s）: %w", orderID, status, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// GetClosedPnL 获取已平仓记录
+// 按 direction 字段配对开仓与平仓成交，还原完整的一次开平仓（真实入场价、入场时间和持仓时长），
+// 分多笔平仓的持仓合并为一条记录
 func (t *WeexTrader) GetClosedPnL(startTime time.Time, limit int) ([]ClosedPnLRecord, error) {
+	trades, err := t.GetTrades(startTime, limit)
+	if err != nil {
+		return nil, err
+	}
+	return AggregateRoundTrips(trades), nil
+}
+
+// GetClosedPnLRange 获取 [startTime, endTime) 内平仓的完整开平仓记录，成交明细自动翻页拉取
+func (t *WeexTrader) GetClosedPnLRange(startTime, endTime time.Time) ([]ClosedPnLRecord, error) {
+	trades, err := t.GetTradesRange(startTime, endTime)
+	if err != nil {
+		return nil, err
+	}
+	return AggregateRoundTrips(trades), nil
+}
+
+// GetPnLSummary 汇总 [startTime, endTime) 内平仓的已实现盈亏、手续费、净盈亏、盈亏次数及各交易对明细
+// 时间按毫秒时间戳发给交易所，与时区无关：按本地自然日统计时传入本地零点即可；
+// 结束时间不含在内，相邻窗口不会重复统计。交易对使用标准格式（如 BTCUSDT）
+// 手续费按窗口内全部成交统计（含仍持仓的开仓成交，见 PnLSummary.ChargeFillFees），
+// 盈亏次数仍按每笔开平仓自身的手续费判断。
+// 注意：窗口结束时只平了一部分的持仓按已平数量计为一笔交易，剩余部分在之后的窗口平仓时
+// 找不到开仓成交，会按平仓成交单独再计一笔，因此跨窗口分批平仓的持仓会被计为多笔交易
+func (t *WeexTrader) GetPnLSummary(startTime, endTime time.Time) (*PnLSummary, error) {
+	trades, err := t.GetTradesRange(startTime, endTime)
+	if err != nil {
+		return nil, err
+	}
+	for i := range trades {
+		trades[i].Symbol = strings.ToUpper(strings.TrimPrefix(trades[i].Symbol, "cmt_"))
+	}
+	summary := SummarizePnL(AggregateRoundTrips(trades), startTime, endTime)
+	summary.ChargeFillFees(trades)
+	return summary, nil
+}
+
+// weexFillsPageLimit 成交明细接口单页最大条数
+const weexFillsPageLimit = 100
+
+// GetTrades 获取成交明细（开仓和平仓成交）
+func (t *WeexTrader) GetTrades(startTime time.Time, limit int) ([]TradeRecord, error) {
 	// 调用 WEEX API 获取成交明细
 	// GET /capi/v2/order/fills?startTime=xxx&limit=xxx
 	if limit <= 0 {
-		limit = 100
+		limit = weexFillsPageLimit
+	}
+	if limit > weexFillsPageLimit {
+		limit = weexFillsPageLimit
+	}
+
+	return t.fetchFills(fmt.Sprintf("?startTime=%d&limit=%d", startTime.UnixMilli(), limit))
+}
+
+// GetTradesRange 获取 [startTime, endTime) 内的全部成交明细，逐页拉取直到取完
+// 交易所的 endTime 包含在内，这里按毫秒减一；翻页边界上同一毫秒的成交按 tradeId 去重
+func (t *WeexTrader) GetTradesRange(startTime, endTime time.Time) ([]TradeRecord, error) {
+	if !endTime.After(startTime) {
+		return nil, fmt.Errorf("结束时间 %s 必须晚于开始时间 %s", endTime.Format(time.RFC3339), startTime.Format(time.RFC3339))
 	}
-	if limit > 100 {
-		limit = 100
+	startMs, endMs := startTime.UnixMilli(), endTime.UnixMilli()-1
+
+	var trades []TradeRecord
+	seen := make(map[string]bool)
+	for {
+		// GET /capi/v2/order/fills?startTime=xxx&endTime=xxx&limit=100
+		page, err := t.fetchFills(fmt.Sprintf("?startTime=%d&endTime=%d&limit=%d", startMs, endMs, weexFillsPageLimit))
+		if err != nil {
+			return nil, err
+		}
+
+		added := 0
+		for _, trade := range page {
+			if ms := trade.Time.UnixMilli(); ms < startMs || ms > endMs {
+				continue
+			}
+			if trade.TradeID != "" {
+				if seen[trade.TradeID] {
+					continue
+				}
+				seen[trade.TradeID] = true
+			}
+			trades = append(trades, trade)
+			added++
+		}
+
+		if len(page) < weexFillsPageLimit {
+			return trades, nil
+		}
+		if added == 0 {
+			t.logger.Warnf("  ⚠️ [WEEX] 同一毫秒内的成交超过单页上限 %d 条，停止翻页", weexFillsPageLimit)
+			return trades, nil
+		}
+
+		// 页内按时间升序时推进开始时间，降序时收缩结束时间
+		first, last := page[0].Time.UnixMilli(), page[len(page)-1].Time.UnixMilli()
+		if first <= last {
+			startMs = last
+		} else {
+			endMs = last
+		}
 	}
+}
 
-	queryString := fmt.Sprintf("?startTime=%d&limit=%d", startTime.UnixMilli(), limit)
+// fetchFills 查询一页成交明细并解析为统一的成交记录
+func (t *WeexTrader) fetchFills(queryString string) ([]TradeRecord, error) {
 	respBody, err := t.sendRequestRaw("GET", "/capi/v2/order/fills", queryString, nil)
 	if err != nil {
 		return nil, fmt.Errorf("获取成交明细失败: %w", err)
@@ -1924,32 +3362,18 @@ func (t *WeexTrader) GetClosedPnL(startTime time.Time, limit int) ([]ClosedPnLRe
 		return nil, fmt.Errorf("成交明细格式错误")
 	}
 
-	// 转换为 ClosedPnLRecord 格式
-	var records []ClosedPnLRecord
+	trades := make([]TradeRecord, 0, len(list))
 	for _, item := range list {
 		fill, ok := item.(map[string]interface{})
 		if !ok {
 			continue
 		}
 
-		// 只处理有已实现盈亏的记录（平仓记录）
-		realizePnlStr, _ := fill["realizePnl"].(string)
-		realizePnl, _ := strconv.ParseFloat(realizePnlStr, 64)
-		if realizePnl == 0 {
-			continue // 跳过开仓记录
-		}
-
-		// 解析字段
-		symbol, _ := fill["symbol"].(string)
-		direction, _ := fill["direction"].(string)
-		fillSizeStr, _ := fill["fillSize"].(string)
-		fillSize, _ := strconv.ParseFloat(fillSizeStr, 64)
-		fillValueStr, _ := fill["fillValue"].(string)
-		fillValue, _ := strconv.ParseFloat(fillValueStr, 64)
-		fillFeeStr, _ := fill["fillFee"].(string)
-		fillFee, _ := strconv.ParseFloat(fillFeeStr, 64)
-		createdTime, _ := fill["createdTime"].(float64)
-		tradeID := fmt.Sprintf("%v", fill["tradeId"])
+		fillSize, _ := weexMapFloat(fill, "fillSize")
+		fillValue, _ := weexMapFloat(fill, "fillValue")
+		fillFee, _ := weexMapFloat(fill, "fillFee")
+		realizePnl, _ := weexMapFloat(fill, "realizePnl")
+		createdTime, _ := weexMapFloat(fill, "createdTime")
 
 		// 计算价格
 		var price float64
@@ -1957,32 +3381,44 @@ func (t *WeexTrader) GetClosedPnL(startTime time.Time, limit int) ([]ClosedPnLRe
 			price = fillValue / fillSize
 		}
 
-		// 确定持仓方向
-		side := "long"
-		if strings.Contains(strings.ToLower(direction), "short") || strings.Contains(strings.ToLower(direction), "空") {
-			side = "short"
+		positionSide, action := weexFillDirection(weexMapString(fill, "direction"))
+		side := "BUY"
+		if (positionSide == "LONG") == (action == "close") {
+			side = "SELL" // 平多、开空为卖出
 		}
 
-		// 创建记录
-		record := ClosedPnLRecord{
-			Symbol:      symbol,
-			Side:        side,
-			EntryPrice:  0,           // WEEX 不提供入场价格
-			ExitPrice:   price,       // 使用成交价格
-			Quantity:    fillSize,
-			RealizedPnL: realizePnl,
-			Fee:         fillFee,
-			ExitTime:    time.UnixMilli(int64(createdTime)),
-			EntryTime:   time.UnixMilli(int64(createdTime)), // 使用相同时间
-			OrderID:     tradeID,
-			CloseType:   "unknown",
-			ExchangeID:  tradeID,
-		}
-
-		records = append(records, record)
+		trades = append(trades, TradeRecord{
+			TradeID:      weexStringValue(fill["tradeId"]),
+			Symbol:       weexMapString(fill, "symbol"),
+			Side:         side,
+			PositionSide: positionSide,
+			Action:       action,
+			Price:        price,
+			Quantity:     fillSize,
+			RealizedPnL:  realizePnl,
+			Fee:          fillFee,
+			Time:         time.UnixMilli(int64(createdTime)),
+		})
 	}
 
-	return records, nil
+	return trades, nil
+}
+
+// weexFillDirection 解析成交的 direction 字段（如 OPEN_LONG、close_short、开多、平空）
+// 返回持仓方向（LONG/SHORT）和开平仓动作（open/close，无法识别时为空，按已实现盈亏推断）
+func weexFillDirection(direction string) (positionSide, action string) {
+	direction = strings.ToLower(direction)
+	positionSide = "LONG"
+	if strings.Contains(direction, "short") || strings.Contains(direction, "空") {
+		positionSide = "SHORT"
+	}
+	switch {
+	case strings.Contains(direction, "close") || strings.Contains(direction, "平"):
+		action = "close"
+	case strings.Contains(direction, "open") || strings.Contains(direction, "开"):
+		action = "open"
+	}
+	return positionSide, action
 }
 
 // 辅助方法
@@ -2019,7 +3455,7 @@ func (t *WeexTrader) getQtyStep(symbol string) float64 {
 	// 调用 WEEX API 获取交易对精度信息
 	contractInfo, err := t.GetContractInfo(symbol)
 	if err != nil {
-		logger.Infof("⚠️ [WEEX] 获取合约信息失败: %v，使用默认精度", err)
+		t.logger.Infof("⚠️ [WEEX] 获取合约信息失败: %v，使用默认精度", err)
 		return 0.001 // 默认精度
 	}
 
@@ -2061,8 +3497,8 @@ func (t *WeexTrader) GetContractInfo(symbol string) (map[string]interface{}, err
 	}
 
 	// 解析响应数据（返回的是数组）
-	var contracts []map[string]interface{}
-	if err := json.Unmarshal(respBody, &contracts); err != nil {
+	contracts, err := decodeWeexList(respBody)
+	if err != nil {
 		return nil, fmt.Errorf("解析合约信息失败: %w", err)
 	}
 
@@ -2075,7 +3511,7 @@ func (t *WeexTrader) GetContractInfo(symbol string) (map[string]interface{}, err
 	contract := contracts[0]
 
 	// 🔍 调试日志：打印合约信息中的关键字段
-	logger.Infof("  🔍 [WEEX] %s 合约信息: tick_size=%v, priceEndStep=%v", symbol, contract["tick_size"], contract["priceEndStep"])
+	t.logger.Infof("  🔍 [WEEX] %s 合约信息: tick_size=%v, priceEndStep=%v", symbol, contract["tick_size"], contract["priceEndStep"])
 
 	return contract, nil
 }
@@ -2099,7 +3535,7 @@ func (t *WeexTrader) GetSymbolPrecision(symbol string) (int, error) {
 
 	// 计算小数位数
 	precision := calculatePrecisionFromValue(minOrderSize)
-	logger.Infof("  [WEEX] %s 数量精度: %d (minOrderSize: %s)", symbol, precision, minOrderSizeStr)
+	t.logger.Infof("  [WEEX] %s 数量精度: %d (minOrderSize: %s)", symbol, precision, minOrderSizeStr)
 
 	return precision, nil
 }
@@ -2123,7 +3559,7 @@ func (t *WeexTrader) GetPricePrecision(symbol string) (int, error) {
 
 	// 计算小数位数
 	precision := calculatePrecisionFromValue(tickSize)
-	logger.Infof("  [WEEX] %s 价格精度: %d (tick_size: %s)", symbol, precision, tickSizeStr)
+	t.logger.Infof("  [WEEX] %s 价格精度: %d (tick_size: %s)", symbol, precision, tickSizeStr)
 
 	return precision, nil
 }
@@ -2143,38 +3579,58 @@ func calculatePrecisionFromValue(value float64) int {
 	return 0
 }
 
+// defaultWeexMinNotional 合约未提供最小下单金额时使用的最小名义价值（USDT）
+const defaultWeexMinNotional = 10.0
+
 // GetMinNotional 获取最小名义价值（最小订单金额）
+// 优先使用合约接口返回的 minTradeUSDT，否则按 minOrderSize × 价格计算（不低于 10 USDT）；
+// 通过 WithWeexMinNotionalFloor 配置的下限更高时以下限为准
 func (t *WeexTrader) GetMinNotional(symbol string) float64 {
 	// 转换交易对格式为WEEX格式
 	symbol = t.normalizeSymbol(symbol)
 
+	minNotional, source := t.contractMinNotional(symbol)
+	if t.minNotionalFloor > minNotional {
+		minNotional, source = t.minNotionalFloor, "配置下限"
+	}
+
+	t.logger.Debugf("[WEEX] %s 最小名义价值: %.2f USDT (来源: %s)", symbol, minNotional, source)
+	return minNotional
+}
+
+// contractMinNotional 从合约信息得出最小名义价值，并返回其来源说明
+func (t *WeexTrader) contractMinNotional(symbol string) (float64, string) {
 	contractInfo, err := t.GetContractInfo(symbol)
 	if err != nil {
-		logger.Infof("⚠️ [WEEX] 获取合约信息失败: %v，使用默认最小名义价值", err)
-		return 10.0 // 默认10 USDT
+		t.logger.Infof("⚠️ [WEEX] 获取合约信息失败: %v，使用默认最小名义价值", err)
+		return defaultWeexMinNotional, "默认值"
+	}
+
+	// 合约直接给出最小下单金额时以其为准
+	if minTrade, ok := weexMapFloat(contractInfo, "minTradeUSDT", "min_trade_usdt"); ok && minTrade > 0 {
+		return minTrade, "合约 minTradeUSDT"
 	}
 
 	// 从 minOrderSize 计算最小名义价值
 	minOrderSizeStr, _ := contractInfo["minOrderSize"].(string)
 	minOrderSize, err := strconv.ParseFloat(minOrderSizeStr, 64)
 	if err != nil || minOrderSize <= 0 {
-		return 10.0
+		return defaultWeexMinNotional, "默认值"
 	}
 
 	// 获取当前市场价格
 	price, err := t.GetMarketPrice(symbol)
 	if err != nil {
-		logger.Infof("⚠️ [WEEX] 获取市场价格失败: %v，使用默认最小名义价值", err)
-		return 10.0
+		t.logger.Infof("⚠️ [WEEX] 获取市场价格失败: %v，使用默认最小名义价值", err)
+		return defaultWeexMinNotional, "默认值"
 	}
 
 	// 计算最小名义价值 = 最小数量 * 价格
 	minNotional := minOrderSize * price
-	if minNotional < 10.0 {
-		minNotional = 10.0 // 至少10 USDT
+	if minNotional < defaultWeexMinNotional {
+		return defaultWeexMinNotional, "默认值" // 至少10 USDT
 	}
-
-	return minNotional
+	return minNotional, "minOrderSize×价格"
 }
 
 // CheckMinNotional 检查订单是否满足最小名义价值要求
@@ -2235,6 +3691,6 @@ func (t *WeexTrader) UploadAILog(orderId int64, stage, model string, input, outp
 		return fmt.Errorf("上传AI日志失败: code=%s, msg=%s", code, msg)
 	}
 
-	logger.Infof("🤖 [WEEX] AI日志上传成功: stage=%s, model=%s", stage, model)
+	t.logger.Infof("🤖 [WEEX] AI日志上传成功: stage=%s, model=%s", stage, model)
 	return nil
 }
@@ -0,0 +1,1436 @@
+package trader
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingWeexLogger captures formatted log lines for assertions
+type recordingWeexLogger struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (l *recordingWeexLogger) record(level, format string, args ...any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lines = append(l.lines, level+" "+fmt.Sprintf(format, args...))
+}
+
+func (l *recordingWeexLogger) Debugf(format string, args ...any) { l.record("DEBUG", format, args...) }
+func (l *recordingWeexLogger) Infof(format string, args ...any)  { l.record("INFO", format, args...) }
+func (l *recordingWeexLogger) Warnf(format string, args ...any)  { l.record("WARN", format, args...) }
+func (l *recordingWeexLogger) Errorf(format string, args ...any) { l.record("ERROR", format, args...) }
+
+// newTestWeexTrader creates a WeexTrader that talks to the given mock server
+func newTestWeexTrader(t *testing.T, handler http.HandlerFunc, opts ...WeexOption) (*WeexTrader, *httptest.Server) {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	opts = append([]WeexOption{WithWeexHTTPClient(server.Client())}, opts...)
+	trader := NewWeexTrader("test_key", "test_secret", "test_passphrase", opts...)
+	trader.baseURL = server.URL
+
+	return trader, server
+}
+
+func TestWeexGetMarketPricesBoundedConcurrency(t *testing.T) {
+	var inFlight, maxInFlight int32
+
+	trader, _ := newTestWeexTrader(t, func(w http.ResponseWriter, r *http.Request) {
+		current := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			prev := atomic.LoadInt32(&maxInFlight)
+			if current <= prev || atomic.CompareAndSwapInt32(&maxInFlight, prev, current) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+
+		symbol := r.URL.Query().Get("symbol")
+		fmt.Fprintf(w, `{"symbol":"%s","last":"%d"}`, symbol, len(symbol))
+	})
+	trader.priceFetchConcurrency = 3
+
+	symbols := make([]string, 12)
+	for i := range symbols {
+		symbols[i] = fmt.Sprintf("COIN%02dUSDT", i)
+	}
+
+	prices, err := trader.GetMarketPrices(symbols)
+	require.NoError(t, err)
+	assert.Len(t, prices, len(symbols))
+	for _, symbol := range symbols {
+		assert.Equal(t, float64(len("cmt_"+strings.ToLower(symbol))), prices[symbol])
+	}
+	assert.LessOrEqual(t, atomic.LoadInt32(&maxInFlight), int32(3))
+	assert.Greater(t, atomic.LoadInt32(&maxInFlight), int32(1))
+}
+
+func TestWeexGetMarketPricesPartialFailure(t *testing.T) {
+	var mu sync.Mutex
+	requested := map[string]int{}
+
+	trader, _ := newTestWeexTrader(t, func(w http.ResponseWriter, r *http.Request) {
+		symbol := r.URL.Query().Get("symbol")
+		mu.Lock()
+		requested[symbol]++
+		mu.Unlock()
+
+		if symbol == "cmt_badusdt" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprint(w, `{"last":"100.5"}`)
+	})
+
+	prices, err := trader.GetMarketPrices([]string{"BTCUSDT", "BADUSDT", "ETHUSDT", "BTCUSDT"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "BADUSDT")
+	assert.Equal(t, map[string]float64{"BTCUSDT": 100.5, "ETHUSDT": 100.5}, prices)
+	assert.Equal(t, 1, requested["cmt_btcusdt"], "duplicate symbols should only be fetched once")
+}
+
+func TestWeexCloseOrdersAreReduceOnly(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		close     func(*WeexTrader) (map[string]interface{}, error)
+		orderType string
+	}{
+		{"CloseLong", func(w *WeexTrader) (map[string]interface{}, error) { return w.CloseLong("BTCUSDT", 0.5) }, "3"},
+		{"CloseShort", func(w *WeexTrader) (map[string]interface{}, error) { return w.CloseShort("BTCUSDT", 0.5) }, "4"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var orderBody map[string]interface{}
+
+			trader, _ := newTestWeexTrader(t, func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/capi/v2/market/contracts":
+					fmt.Fprint(w, `[{"symbol":"cmt_btcusdt","minOrderSize":"0.001"}]`)
+				case "/capi/v2/order/placeOrder":
+					require.NoError(t, json.NewDecoder(r.Body).Decode(&orderBody))
+					fmt.Fprint(w, `{"order_id":"1"}`)
+				default:
+					fmt.Fprint(w, `[]`)
+				}
+			})
+			trader.marginModeCache["cmt_btcusdt"] = 1
+
+			_, err := tc.close(trader)
+			require.NoError(t, err)
+			require.NotNil(t, orderBody)
+			assert.Equal(t, tc.orderType, orderBody["type"])
+			assert.Equal(t, true, orderBody["reduceOnly"])
+		})
+	}
+}
+
+func TestWeexGetMarginModePrefersAccountSettings(t *testing.T) {
+	var settingsCalls int32
+
+	trader, _ := newTestWeexTrader(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/capi/v2/account/settings":
+			atomic.AddInt32(&settingsCalls, 1)
+			assert.Equal(t, "cmt_ethusdt", r.URL.Query().Get("symbol"))
+			fmt.Fprint(w, `{"cmt_ethusdt":{"marginMode":"ISOLATED","isolated_long_leverage":"7","isolated_short_leverage":"5","cross_leverage":"20"}}`)
+		default:
+			// No positions: inference alone would fall back to the cross-margin default
+			fmt.Fprint(w, `[]`)
+		}
+	})
+
+	info, err := trader.QueryLeverageMargin("ETHUSDT")
+	require.NoError(t, err)
+	assert.Equal(t, 3, info.MarginMode)
+	assert.Equal(t, 7.0, info.LongLeverage)
+	assert.Equal(t, 5.0, info.ShortLeverage)
+
+	assert.Equal(t, 3, trader.getMarginMode("cmt_ethusdt"))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&settingsCalls), "settings should be served from cache within TTL")
+
+	// 超过TTL后重新查询，交易所上的模式变更能被感知
+	trader.leverageMarginCache["cmt_ethusdt"].fetchedAt = time.Now().Add(-trader.leverageMarginCacheTTL)
+	assert.Equal(t, 3, trader.getMarginMode("cmt_ethusdt"))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&settingsCalls), "settings should be queried again after the TTL")
+}
+
+// newFlattenTestWeexTrader mocks an account holding a BTC long and an ETH short, each with a plan
+// order; failSymbol (WEEX format) makes its close order fail
+func newFlattenTestWeexTrader(t *testing.T, failSymbol string) (*WeexTrader, func() (map[string]map[string]interface{}, map[string]int)) {
+	var (
+		mu             sync.Mutex
+		orders         = map[string]map[string]interface{}{}
+		canceledPlans  = map[string]int{}
+		planOrderOwner = map[string]string{"plan-btc": "cmt_btcusdt", "plan-eth": "cmt_ethusdt"}
+	)
+
+	trader, _ := newTestWeexTrader(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch r.URL.Path {
+		case "/capi/v2/account/position/allPosition":
+			fmt.Fprint(w, `[
+				{"symbol":"cmt_btcusdt","side":"LONG","size":"0.5","leverage":"10","open_value":"30000"},
+				{"symbol":"cmt_ethusdt","side":"SHORT","size":"2","leverage":"5","open_value":"6000"}
+			]`)
+		case "/capi/v2/market/ticker":
+			fmt.Fprint(w, `{"last":"100"}`)
+		case "/capi/v2/market/contracts":
+			fmt.Fprint(w, `[{"symbol":"cmt_btcusdt","minOrderSize":"0.001"},{"symbol":"cmt_ethusdt","minOrderSize":"0.01"}]`)
+		case "/capi/v2/order/currentPlan":
+			symbol := r.URL.Query().Get("symbol")
+			fmt.Fprintf(w, `[{"order_id":"plan-%s","type":"CLOSE_LONG","status":"UNTRIGGERED"}]`, strings.TrimSuffix(strings.TrimPrefix(symbol, "cmt_"), "usdt"))
+		case "/capi/v2/order/cancel_plan":
+			var body map[string]interface{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			canceledPlans[planOrderOwner[body["orderId"].(string)]]++
+			fmt.Fprint(w, `{"result":true}`)
+		case "/capi/v2/order/placeOrder":
+			var body map[string]interface{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			symbol := body["symbol"].(string)
+			if symbol == failSymbol {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			orders[symbol] = body
+			fmt.Fprint(w, `{"order_id":"1"}`)
+		default:
+			fmt.Fprint(w, `[]`)
+		}
+	})
+	trader.marginModeCache["cmt_btcusdt"] = 1
+	trader.marginModeCache["cmt_ethusdt"] = 1
+
+	return trader, func() (map[string]map[string]interface{}, map[string]int) {
+		mu.Lock()
+		defer mu.Unlock()
+		return orders, canceledPlans
+	}
+}
+
+func TestWeexCloseAllPositions(t *testing.T) {
+	trader, recorded := newFlattenTestWeexTrader(t, "")
+
+	results, err := trader.CloseAllPositions()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]error{"BTCUSDT": nil, "ETHUSDT": nil}, results)
+
+	orders, canceledPlans := recorded()
+	require.Len(t, orders, 2)
+	assert.Equal(t, "3", orders["cmt_btcusdt"]["type"])
+	assert.Equal(t, "4", orders["cmt_ethusdt"]["type"])
+	for symbol, want := range map[string]float64{"cmt_btcusdt": 0.5, "cmt_ethusdt": 2} {
+		size, err := strconv.ParseFloat(orders[symbol]["size"].(string), 64)
+		require.NoError(t, err)
+		assert.Equal(t, want, size, symbol)
+	}
+	for symbol, order := range orders {
+		assert.Equal(t, true, order["reduceOnly"], symbol)
+		assert.Equal(t, "1", order["match_price"], symbol)
+	}
+	assert.Equal(t, 1, canceledPlans["cmt_btcusdt"])
+	assert.Equal(t, 1, canceledPlans["cmt_ethusdt"])
+}
+
+func TestWeexCloseAllPositionsContinuesAfterFailure(t *testing.T) {
+	trader, recorded := newFlattenTestWeexTrader(t, "cmt_btcusdt")
+
+	results, err := trader.CloseAllPositions()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "BTCUSDT")
+	assert.Error(t, results["BTCUSDT"])
+	assert.NoError(t, results["ETHUSDT"])
+
+	orders, _ := recorded()
+	assert.Contains(t, orders, "cmt_ethusdt", "a failed close must not stop the remaining positions")
+}
+
+func TestWeexTraderUsesInjectedLogger(t *testing.T) {
+	log := &recordingWeexLogger{}
+	trader, _ := newTestWeexTrader(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/capi/v2/market/contracts":
+			fmt.Fprint(w, `[{"symbol":"cmt_btcusdt","minOrderSize":"0.001"}]`)
+		case "/capi/v2/order/placeOrder":
+			fmt.Fprint(w, `{"order_id":"42"}`)
+		case "/capi/v2/order/current", "/capi/v2/order/currentPlan":
+			fmt.Fprint(w, `[]`)
+		default:
+			fmt.Fprint(w, `{}`)
+		}
+	}, WithWeexLogger(log))
+
+	_, err := trader.OpenLong("BTCUSDT", 0.5, 10)
+	require.NoError(t, err)
+
+	log.mu.Lock()
+	defer log.mu.Unlock()
+	assert.Contains(t, log.lines, "INFO 🟢 [WEEX] 交易器初始化完成")
+	found := false
+	for _, line := range log.lines {
+		if strings.HasPrefix(line, "INFO ✓ [WEEX] 开多仓成功: cmt_btcusdt") && strings.HasSuffix(line, "订单ID: 42") {
+			found = true
+		}
+	}
+	assert.True(t, found, "expected the open to be logged through the injected logger, got %v", log.lines)
+}
+
+// newOrderStatusTestWeexTrader serves the given order responses in turn, repeating the last one
+func newOrderStatusTestWeexTrader(t *testing.T, responses ...string) (*WeexTrader, *int32) {
+	var polls int32
+	trader, _ := newTestWeexTrader(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/capi/v2/order/current", r.URL.Path)
+		assert.Equal(t, "42", r.URL.Query().Get("orderId"))
+		n := int(atomic.AddInt32(&polls, 1))
+		if n > len(responses) {
+			n = len(responses)
+		}
+		fmt.Fprint(w, responses[n-1])
+	})
+	return trader, &polls
+}
+
+func TestWeexWaitForFill(t *testing.T) {
+	trader, polls := newOrderStatusTestWeexTrader(t,
+		`[{"symbol":"cmt_btcusdt","status":"pending","filled_qty":"0"}]`,
+		`[{"symbol":"cmt_btcusdt","status":"filled","filled_qty":"0.5","price_avg":"101.5"}]`,
+	)
+
+	status, avgPrice, err := trader.WaitForFill(context.Background(), "BTCUSDT", "42", time.Millisecond, false)
+	require.NoError(t, err)
+	assert.Equal(t, "FILLED", status)
+	assert.Equal(t, 101.5, avgPrice)
+	assert.Equal(t, int32(2), atomic.LoadInt32(polls))
+}
+
+func TestWeexWaitForFillPartial(t *testing.T) {
+	partial := `[{"symbol":"cmt_btcusdt","status":"open","filled_qty":"0.2","price_avg":"100"}]`
+
+	t.Run("accept partial", func(t *testing.T) {
+		trader, _ := newOrderStatusTestWeexTrader(t, partial)
+		status, avgPrice, err := trader.WaitForFill(context.Background(), "BTCUSDT", "42", time.Millisecond, true)
+		require.NoError(t, err)
+		assert.Equal(t, "PARTIALLY_FILLED", status)
+		assert.Equal(t, 100.0, avgPrice)
+	})
+
+	t.Run("keeps waiting until the context expires", func(t *testing.T) {
+		trader, polls := newOrderStatusTestWeexTrader(t, partial)
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+		defer cancel()
+
+		status, _, err := trader.WaitForFill(ctx, "BTCUSDT", "42", time.Millisecond, false)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Equal(t, "PARTIALLY_FILLED", status)
+		assert.Greater(t, atomic.LoadInt32(polls), int32(1))
+	})
+}
+
+// roundTripFunc adapts a function to http.RoundTripper
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func TestWeexCustomTransport(t *testing.T) {
+	var requested []string
+	transport := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		requested = append(requested, r.URL.String())
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(`{"last":"123.4"}`)),
+			Header:     make(http.Header),
+			Request:    r,
+		}, nil
+	})
+
+	trader := NewWeexTrader("test_key", "test_secret", "test_passphrase", WithWeexTransport(transport))
+	assert.Equal(t, 30*time.Second, trader.httpClient.Timeout, "the default timeout should be kept")
+
+	price, err := trader.GetMarketPrice("BTCUSDT")
+	require.NoError(t, err)
+	assert.Equal(t, 123.4, price)
+	assert.Equal(t, []string{"https://api-contract.weex.com/capi/v2/market/ticker?symbol=cmt_btcusdt"}, requested)
+}
+
+func TestWeexCustomHTTPClient(t *testing.T) {
+	client := &http.Client{Timeout: time.Second}
+	trader := NewWeexTrader("test_key", "test_secret", "test_passphrase", WithWeexHTTPClient(client))
+	assert.Same(t, client, trader.httpClient)
+
+	// A transport on top of a custom client must not modify the caller's client
+	trader = NewWeexTrader("test_key", "test_secret", "test_passphrase",
+		WithWeexHTTPClient(client), WithWeexTransport(http.DefaultTransport))
+	assert.Nil(t, client.Transport)
+	assert.Equal(t, time.Second, trader.httpClient.Timeout)
+	assert.Equal(t, http.DefaultTransport, trader.httpClient.Transport)
+}
+
+func TestDecodeWeexList(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		wantLen int
+	}{
+		{name: "array", body: `[{"orderId":"1"},{"orderId":"2"}]`, wantLen: 2},
+		{name: "empty array", body: `[]`, wantLen: 0},
+		{name: "wrapped", body: `{"code":"00000","msg":"success","data":[{"orderId":"1"}]}`, wantLen: 1},
+		{name: "wrapped numeric code", body: `{"code":200,"data":[{"orderId":"1"},{"orderId":"2"}]}`, wantLen: 2},
+		{name: "wrapped null data", body: `{"code":"0","msg":"success","data":null}`, wantLen: 0},
+		{name: "wrapped list", body: `{"code":"00000","list":[{"orderId":"1"}]}`, wantLen: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list, err := decodeWeexList([]byte(tt.body))
+			require.NoError(t, err)
+			assert.NotNil(t, list)
+			assert.Len(t, list, tt.wantLen)
+		})
+	}
+}
+
+func TestDecodeWeexListErrors(t *testing.T) {
+	_, err := decodeWeexList([]byte(`{"code":"40015","msg":"Request too frequent"}`))
+	var apiErr *WeexAPIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, "40015", apiErr.Code)
+	assert.Equal(t, "Request too frequent", apiErr.Msg)
+
+	_, err = decodeWeexList([]byte(`{"code":40001,"msg":"Invalid signature","data":[]}`))
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, "40001", apiErr.Code)
+
+	_, err = decodeWeexList([]byte(`not json`))
+	require.Error(t, err)
+	assert.False(t, errors.As(err, &apiErr))
+
+	// Objects without a list are not an empty list
+	for _, body := range []string{`{}`, `{"msg":"system busy"}`, `{"code":"00000","msg":"success"}`} {
+		list, err := decodeWeexList([]byte(body))
+		assert.Error(t, err, body)
+		assert.Nil(t, list, body)
+	}
+}
+
+func TestWeexGetPositionsWrappedResponse(t *testing.T) {
+	trader, _ := newTestWeexTrader(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"code":"00000","msg":"success","data":[]}`))
+	})
+
+	positions, err := trader.GetPositions()
+	require.NoError(t, err)
+	assert.Empty(t, positions)
+
+	trader, _ = newTestWeexTrader(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"code":"40015","msg":"Request too frequent"}`))
+	})
+	_, err = trader.GetPositions()
+	var apiErr *WeexAPIError
+	require.ErrorAs(t, err, &apiErr)
+}
+
+func TestWeexCancelOrderByClientOidAfterLostResponse(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		open     = map[string]string{} // client_oid -> order_id of orders resting on the exchange
+		canceled []string
+	)
+
+	trader, _ := newTestWeexTrader(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch r.URL.Path {
+		case "/capi/v2/market/contracts":
+			fmt.Fprint(w, `[{"symbol":"cmt_btcusdt","minOrderSize":"0.001"}]`)
+		case "/capi/v2/order/placeOrder":
+			var body map[string]interface{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			// The exchange accepts the order, but the response never reaches us
+			open[body["client_oid"].(string)] = "order-1"
+			panic(http.ErrAbortHandler)
+		case "/capi/v2/order/current":
+			orders := []map[string]string{}
+			for clientOid, orderID := range open {
+				orders = append(orders, map[string]string{"order_id": orderID, "client_oid": clientOid, "status": "open"})
+			}
+			require.NoError(t, json.NewEncoder(w).Encode(orders))
+		case "/capi/v2/order/cancel_order":
+			var body map[string]interface{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			orderID := body["orderId"].(string)
+			for clientOid, id := range open {
+				if id == orderID {
+					delete(open, clientOid)
+				}
+			}
+			canceled = append(canceled, orderID)
+			fmt.Fprint(w, `{"result":true}`)
+		default:
+			fmt.Fprint(w, `[]`)
+		}
+	})
+	trader.marginModeCache["cmt_btcusdt"] = 1
+
+	_, err := trader.OpenLong("BTCUSDT", 0.01, 5)
+	var orderErr *WeexOrderError
+	require.ErrorAs(t, err, &orderErr)
+	require.NotEmpty(t, orderErr.ClientOid)
+
+	require.NoError(t, trader.CancelOrderByClientOid("BTCUSDT", orderErr.ClientOid))
+	mu.Lock()
+	assert.Equal(t, []string{"order-1"}, canceled)
+	assert.Empty(t, open)
+	mu.Unlock()
+
+	// Once resolved the order is gone, which callers can tell apart from a failed cancel
+	err = trader.CancelOrderByClientOid("BTCUSDT", orderErr.ClientOid)
+	assert.ErrorIs(t, err, ErrWeexOrderNotFound)
+}
+
+// newReconcileTestWeexTrader serves fixed positions, plan orders and open orders for cmt_btcusdt
+// and records the plan orders canceled. Plan orders listed in failCancel fail to cancel.
+func newReconcileTestWeexTrader(t *testing.T, positions, plans, orders string, failCancel ...string) (*WeexTrader, func() []string) {
+	var (
+		mu       sync.Mutex
+		canceled []string
+	)
+	trader, _ := newTestWeexTrader(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch r.URL.Path {
+		case "/capi/v2/account/position/allPosition":
+			fmt.Fprint(w, positions)
+		case "/capi/v2/market/ticker":
+			fmt.Fprint(w, `{"last":"100"}`)
+		case "/capi/v2/order/currentPlan":
+			fmt.Fprint(w, plans)
+		case "/capi/v2/order/current":
+			fmt.Fprint(w, orders)
+		case "/capi/v2/order/cancel_plan":
+			var body map[string]interface{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			orderID := body["orderId"].(string)
+			for _, id := range failCancel {
+				if id == orderID {
+					fmt.Fprint(w, `{"result":false,"err_msg":"order not found"}`)
+					return
+				}
+			}
+			canceled = append(canceled, orderID)
+			fmt.Fprint(w, `{"result":true}`)
+		default:
+			fmt.Fprint(w, `[]`)
+		}
+	})
+	return trader, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return canceled
+	}
+}
+
+func TestWeexReconcileCancelsPlanOrdersWithoutPosition(t *testing.T) {
+	trader, canceled := newReconcileTestWeexTrader(t,
+		`[{"symbol":"cmt_btcusdt","side":"LONG","size":"0.5","open_value":"50"}]`,
+		`[
+			{"order_id":"sl-long","type":"CLOSE_LONG","status":"UNTRIGGERED","triggerPrice":"90"},
+			{"order_id":"sl-short","type":"CLOSE_SHORT","status":"UNTRIGGERED","triggerPrice":"110"},
+			{"order_id":"done","type":"CLOSE_SHORT","status":"-1","triggerPrice":"120"}
+		]`,
+		`[]`)
+
+	report, err := trader.Reconcile("BTCUSDT")
+	require.NoError(t, err)
+	assert.Equal(t, "BTCUSDT", report.Symbol)
+	assert.Equal(t, []string{"LONG"}, report.PositionSides)
+	assert.Equal(t, []string{"sl-short"}, canceled(), "only the plan order closing a missing short is orphaned")
+	require.Len(t, report.Discrepancies, 1)
+	assert.Contains(t, report.Discrepancies[0], "sl-short")
+	assert.Len(t, report.Actions, 1)
+}
+
+func TestWeexReconcileWithoutPosition(t *testing.T) {
+	trader, canceled := newReconcileTestWeexTrader(t,
+		`[]`,
+		`[{"order_id":"tp-long","type":"3","status":0,"triggerPrice":"130"}]`,
+		`[{"order_id":"open-1","client_oid":"WEEX1","status":"open"}]`)
+	trader.pendingStopLoss["cmt_btcusdt"] = 95
+	trader.pendingTakeProfit["cmt_btcusdt"] = 120
+	trader.pendingStopLoss["cmt_ethusdt"] = 1800
+
+	report, err := trader.Reconcile("BTCUSDT")
+	require.NoError(t, err)
+	assert.Empty(t, report.PositionSides)
+	assert.Equal(t, []string{"tp-long"}, canceled())
+	assert.Len(t, report.Discrepancies, 4) // plan order, open order, pending stop loss and take profit
+	assert.Len(t, report.Actions, 3, "the open order is only reported")
+
+	assert.NotContains(t, trader.pendingStopLoss, "cmt_btcusdt")
+	assert.NotContains(t, trader.pendingTakeProfit, "cmt_btcusdt")
+	assert.Contains(t, trader.pendingStopLoss, "cmt_ethusdt", "other symbols are left alone")
+}
+
+func TestWeexReconcileConsistentState(t *testing.T) {
+	trader, canceled := newReconcileTestWeexTrader(t,
+		`[{"symbol":"cmt_btcusdt","side":"SHORT","size":"1","open_value":"100"}]`,
+		`[{"order_id":"sl-short","type":"CLOSE_SHORT","status":"UNTRIGGERED","triggerPrice":"110"}]`,
+		`[]`)
+
+	report, err := trader.Reconcile("BTCUSDT")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"SHORT"}, report.PositionSides)
+	assert.Empty(t, report.Discrepancies)
+	assert.Empty(t, report.Actions)
+	assert.Empty(t, canceled())
+}
+
+func TestWeexReconcileReportsCancelFailure(t *testing.T) {
+	trader, canceled := newReconcileTestWeexTrader(t,
+		`[]`,
+		`[
+			{"order_id":"stuck","type":"CLOSE_LONG","status":"UNTRIGGERED","triggerPrice":"90"},
+			{"order_id":"sl-short","type":"CLOSE_SHORT","status":"UNTRIGGERED","triggerPrice":"110"}
+		]`,
+		`[]`, "stuck")
+
+	report, err := trader.Reconcile("BTCUSDT")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "stuck")
+	require.NotNil(t, report)
+	assert.Len(t, report.Discrepancies, 2)
+	assert.Equal(t, []string{"sl-short"}, canceled(), "a failed cancel does not stop the others")
+}
+
+// newCancelTestWeexTrader serves three resting orders and plan orders for cmt_btcusdt and
+// answers cancelAllOrders with batchResponse, recording the single-order cancels it receives.
+// Orders listed in failCancel fail to cancel one by one.
+func newCancelTestWeexTrader(t *testing.T, batchStatus int, batchResponse string, failCancel ...string) (*WeexTrader, func() ([]map[string]interface{}, []string)) {
+	var (
+		mu       sync.Mutex
+		batches  []map[string]interface{}
+		canceled []string
+	)
+	trader, _ := newTestWeexTrader(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch r.URL.Path {
+		case "/capi/v2/order/current", "/capi/v2/order/currentPlan":
+			fmt.Fprint(w, `[{"order_id":"o-1"},{"order_id":"o-2"},{"order_id":"o-3"}]`)
+		case "/capi/v2/order/cancelAllOrders":
+			var body map[string]interface{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			batches = append(batches, body)
+			w.WriteHeader(batchStatus)
+			fmt.Fprint(w, batchResponse)
+		case "/capi/v2/order/cancel_order", "/capi/v2/order/cancel_plan":
+			var body map[string]interface{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			orderID := body["orderId"].(string)
+			for _, id := range failCancel {
+				if id == orderID {
+					fmt.Fprint(w, `{"result":false,"err_msg":"order not found"}`)
+					return
+				}
+			}
+			canceled = append(canceled, orderID)
+			fmt.Fprint(w, `{"result":true}`)
+		default:
+			fmt.Fprint(w, `[]`)
+		}
+	}, WithWeexLogger(&recordingWeexLogger{}))
+	return trader, func() ([]map[string]interface{}, []string) {
+		mu.Lock()
+		defer mu.Unlock()
+		return batches, canceled
+	}
+}
+
+func TestWeexCancelOrdersBatch(t *testing.T) {
+	trader, recorded := newCancelTestWeexTrader(t, http.StatusOK,
+		`[{"orderId":"o-1","success":true},{"orderId":"o-2","success":true},{"orderId":"o-3","success":false}]`)
+
+	count, err := trader.CancelAllOrdersCount("BTCUSDT")
+	assert.Equal(t, 2, count)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "o-3")
+
+	batches, canceled := recorded()
+	require.Len(t, batches, 1, "all orders are canceled in one request")
+	assert.Equal(t, "cmt_btcusdt", batches[0]["symbol"])
+	assert.Equal(t, "normal", batches[0]["cancelOrderType"])
+	assert.Empty(t, canceled, "orders reported by the batch are not canceled again")
+
+	require.Error(t, trader.CancelPlanOrders("BTCUSDT"))
+	batches, _ = recorded()
+	require.Len(t, batches, 2)
+	assert.Equal(t, "plan", batches[1]["cancelOrderType"])
+}
+
+func TestWeexCancelOrdersFallsBackToSingleCancels(t *testing.T) {
+	// The batch endpoint fails outright: every order is canceled on its own
+	trader, recorded := newCancelTestWeexTrader(t, http.StatusInternalServerError, `{"code":"500"}`, "o-2")
+
+	count, err := trader.CancelPlanOrdersCount("BTCUSDT")
+	assert.Equal(t, 2, count)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "o-2")
+	assert.Contains(t, err.Error(), "order not found")
+	_, canceled := recorded()
+	assert.Equal(t, []string{"o-1", "o-3"}, canceled)
+
+	// Orders missing from the batch result are canceled one by one
+	trader, recorded = newCancelTestWeexTrader(t, http.StatusOK, `[{"orderId":"o-1","success":true}]`)
+	count, err = trader.CancelAllOrdersCount("BTCUSDT")
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+	_, canceled = recorded()
+	assert.Equal(t, []string{"o-2", "o-3"}, canceled)
+}
+
+func TestWeexGetOpenOrders(t *testing.T) {
+	var query string
+	trader, _ := newTestWeexTrader(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/capi/v2/order/current", r.URL.Path)
+		query = r.URL.RawQuery
+		io.WriteString(w, `[
+			{"symbol":"cmt_btcusdt","order_id":"101","client_oid":"WEEX1","type":"1","price":"65000.5","size":"0.02","filled_qty":"0.005","status":"open"},
+			{"symbol":"cmt_btcusdt","order_id":"102","client_oid":"WEEX2","type":"CLOSE_LONG","price":"0","size":"0.01","filled_qty":"0","status":"pending"},
+			{"symbol":"cmt_btcusdt","order_id":"103","client_oid":"","type":"2","price":"66000","size":"0.03","filled_qty":"0","status":"open"},
+			{"symbol":"cmt_btcusdt","order_id":"104","client_oid":"","type":"9","price":"64000","size":"0.01","filled_qty":"0","status":"open"}
+		]`)
+	}, WithWeexLogger(&recordingWeexLogger{}))
+
+	orders, err := trader.GetOpenOrders("BTCUSDT")
+	require.NoError(t, err)
+	assert.Equal(t, "symbol=cmt_btcusdt", query)
+	assert.Equal(t, []WeexOrder{
+		{OrderID: "101", ClientOid: "WEEX1", Symbol: "BTCUSDT", Side: "BUY", PositionSide: "LONG", Type: "OPEN_LONG",
+			Price: 65000.5, Size: 0.02, Filled: 0.005, Status: "PARTIALLY_FILLED"},
+		{OrderID: "102", ClientOid: "WEEX2", Symbol: "BTCUSDT", Side: "SELL", PositionSide: "LONG", Type: "CLOSE_LONG",
+			Size: 0.01, Status: "NEW"},
+		{OrderID: "103", Symbol: "BTCUSDT", Side: "SELL", PositionSide: "SHORT", Type: "OPEN_SHORT",
+			Price: 66000, Size: 0.03, Status: "NEW"},
+		// unknown type: the direction is not guessed
+		{OrderID: "104", Symbol: "BTCUSDT", Type: "9", Price: 64000, Size: 0.01, Status: "NEW"},
+	}, orders)
+}
+
+func TestWeexDryRunInterceptsMutations(t *testing.T) {
+	var gets, posts atomic.Int32
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			posts.Add(1)
+		} else {
+			gets.Add(1)
+		}
+
+		switch r.URL.Path {
+		case "/capi/v2/market/contracts":
+			fmt.Fprint(w, `[{"symbol":"cmt_btcusdt","minOrderSize":"0.001"}]`)
+		case "/capi/v2/order/current":
+			fmt.Fprint(w, `[{"order_id":"resting-1","client_oid":"WEEX1","status":"open"}]`)
+		case "/capi/v2/order/placeOrder", "/capi/v2/order/plan_order":
+			fmt.Fprint(w, `{"client_oid":"WEEX1","order_id":"596471064624628269"}`)
+		case "/capi/v2/order/cancel_order", "/capi/v2/order/cancel_plan":
+			fmt.Fprint(w, `{"order_id":"resting-1","client_oid":null,"result":true,"err_msg":null}`)
+		case "/capi/v2/account/leverage":
+			fmt.Fprint(w, `{"msg":"success","requestTime":1713339011237,"code":"200"}`)
+		default:
+			fmt.Fprint(w, `[]`)
+		}
+	}
+
+	log := &recordingWeexLogger{}
+	dry, _ := newTestWeexTrader(t, handler, WithWeexDryRun(true), WithWeexLogger(log))
+	dry.marginModeCache["cmt_btcusdt"] = 1
+
+	result, err := dry.OpenLong("BTCUSDT", 0.01, 5)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(result["orderId"].(string), "DRYRUN-"))
+	assert.NotEmpty(t, result["clientOid"])
+	require.NoError(t, dry.SetLeverage("BTCUSDT", 10))
+	require.NoError(t, dry.CancelOrderByClientOid("BTCUSDT", "WEEX1"))
+	require.NoError(t, dry.CancelAllOrders("BTCUSDT"))
+	canceled, err := dry.CancelAllOrdersCount("BTCUSDT")
+	require.NoError(t, err)
+	assert.Equal(t, 1, canceled)
+	for _, line := range log.lines {
+		assert.NotContains(t, line, "改为逐个取消", "the synthesized batch cancel must be accepted")
+	}
+
+	assert.Zero(t, posts.Load(), "no mutating request may reach the exchange in dry-run")
+	assert.NotZero(t, gets.Load(), "read-only requests still hit the API")
+
+	// The synthesized responses have the same fields as the real ones
+	live, _ := newTestWeexTrader(t, handler)
+	for _, path := range []string{
+		"/capi/v2/order/placeOrder",
+		"/capi/v2/order/plan_order",
+		"/capi/v2/order/cancel_order",
+		"/capi/v2/order/cancel_plan",
+		"/capi/v2/account/leverage",
+	} {
+		body := map[string]interface{}{"symbol": "cmt_btcusdt", "client_oid": "WEEX1", "orderId": "resting-1"}
+		liveResp, err := live.sendRequest("POST", path, "", body)
+		require.NoError(t, err)
+		dryResp, err := dry.sendRequest("POST", path, "", body)
+		require.NoError(t, err)
+
+		for key := range dryResp {
+			assert.Contains(t, liveResp, key, path)
+		}
+		for _, key := range []string{"order_id", "result", "code"} {
+			if _, ok := liveResp[key]; ok {
+				assert.Contains(t, dryResp, key, path)
+			}
+		}
+	}
+}
+
+func TestWeexGetMinNotional(t *testing.T) {
+	tests := []struct {
+		name     string
+		contract string
+		floor    float64
+		want     float64
+		source   string
+	}{
+		{"API minimum", `{"symbol":"cmt_btcusdt","minTradeUSDT":"25","minOrderSize":"0.0001"}`, 0, 25, "minTradeUSDT"},
+		{"API minimum below default", `{"symbol":"cmt_btcusdt","minTradeUSDT":5}`, 0, 5, "minTradeUSDT"},
+		{"configured floor", `{"symbol":"cmt_btcusdt","minTradeUSDT":"25"}`, 50, 50, "配置下限"},
+		{"floor below API minimum", `{"symbol":"cmt_btcusdt","minTradeUSDT":"25"}`, 15, 25, "minTradeUSDT"},
+		{"from order size", `{"symbol":"cmt_btcusdt","minOrderSize":"0.2"}`, 0, 20, "minOrderSize"},
+		{"default", `{"symbol":"cmt_btcusdt","minOrderSize":"0.001"}`, 0, 10, "默认值"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := &recordingWeexLogger{}
+			trader, _ := newTestWeexTrader(t, func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/capi/v2/market/contracts":
+					fmt.Fprintf(w, "[%s]", tt.contract)
+				case "/capi/v2/market/ticker":
+					fmt.Fprint(w, `{"last":"100"}`)
+				}
+			}, WithWeexLogger(log), WithWeexMinNotionalFloor(tt.floor))
+
+			assert.InDelta(t, tt.want, trader.GetMinNotional("BTCUSDT"), 1e-9)
+
+			logged := false
+			for _, line := range log.lines {
+				if strings.Contains(line, "最小名义价值") && strings.Contains(line, tt.source) {
+					logged = true
+				}
+			}
+			assert.True(t, logged, "expected the %q source to be logged, got %v", tt.source, log.lines)
+		})
+	}
+
+	// CheckMinNotional enforces the configured floor
+	trader, _ := newTestWeexTrader(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/capi/v2/market/contracts":
+			fmt.Fprint(w, `[{"symbol":"cmt_btcusdt","minTradeUSDT":"5"}]`)
+		case "/capi/v2/market/ticker":
+			fmt.Fprint(w, `{"last":"100"}`)
+		}
+	}, WithWeexMinNotionalFloor(30))
+	assert.Error(t, trader.CheckMinNotional("BTCUSDT", 0.2))
+	assert.NoError(t, trader.CheckMinNotional("BTCUSDT", 0.3))
+}
+
+func TestWeexFeeRatesAndEstimate(t *testing.T) {
+	var requests atomic.Int32
+	trader, _ := newTestWeexTrader(t, func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		assert.Equal(t, "/capi/v2/market/contracts", r.URL.Path)
+		assert.Equal(t, "cmt_btcusdt", r.URL.Query().Get("symbol"))
+		fmt.Fprint(w, `[{"symbol":"cmt_btcusdt","makerFeeRate":"0.0002","takerFeeRate":0.0006,"minOrderSize":"0.0001"}]`)
+	})
+
+	rates, err := trader.GetContractFeeRates("BTCUSDT")
+	require.NoError(t, err)
+	assert.Equal(t, 0.0002, rates.Maker)
+	assert.Equal(t, 0.0006, rates.Taker)
+
+	fee, err := trader.EstimateFees("BTCUSDT", 10000, false)
+	require.NoError(t, err)
+	assert.InDelta(t, 6.0, fee, 1e-9)
+	fee, err = trader.EstimateFees("BTCUSDT", 10000, true)
+	require.NoError(t, err)
+	assert.InDelta(t, 2.0, fee, 1e-9)
+
+	assert.Equal(t, int32(1), requests.Load(), "fee rates are cached per symbol")
+}
+
+func TestWeexFeeRatesMissing(t *testing.T) {
+	trader, _ := newTestWeexTrader(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"symbol":"cmt_btcusdt","minOrderSize":"0.0001"}]`)
+	})
+
+	_, err := trader.EstimateFees("BTCUSDT", 1000, false)
+	assert.Error(t, err)
+}
+
+func TestWeexOpenDedupSuppressesDoubleFire(t *testing.T) {
+	var placed, cleanups atomic.Int32
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/capi/v2/market/contracts":
+			fmt.Fprint(w, `[{"symbol":"cmt_btcusdt","minOrderSize":"0.001"}]`)
+		case "/capi/v2/order/placeOrder":
+			placed.Add(1)
+			fmt.Fprint(w, `{"order_id":"42"}`)
+		case "/capi/v2/order/current", "/capi/v2/order/currentPlan":
+			// order cleanup at the start of every open looks up resting orders first
+			cleanups.Add(1)
+			fmt.Fprint(w, `[]`)
+		default:
+			fmt.Fprint(w, `[]`)
+		}
+	}
+
+	trader, _ := newTestWeexTrader(t, handler, WithWeexOpenDedupWindow(time.Minute))
+	trader.marginModeCache["cmt_btcusdt"] = 1
+
+	_, err := trader.OpenLong("BTCUSDT", 0.01, 5)
+	require.NoError(t, err)
+	cleanupsAfterFirst := cleanups.Load()
+	require.NotZero(t, cleanupsAfterFirst)
+
+	// A retry of the same decision inside the window never reaches the exchange
+	_, err = trader.OpenLong("BTCUSDT", 0.01, 5)
+	assert.ErrorIs(t, err, ErrWeexDuplicateOpen)
+	assert.Equal(t, int32(1), placed.Load())
+	assert.Equal(t, cleanupsAfterFirst, cleanups.Load(), "a suppressed open must not touch the first open's orders")
+
+	// The opposite side is a different intent
+	_, err = trader.OpenShort("BTCUSDT", 0.01, 5)
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), placed.Load())
+
+	// A zero window disables the guard
+	unguarded, _ := newTestWeexTrader(t, handler, WithWeexOpenDedupWindow(0))
+	unguarded.marginModeCache["cmt_btcusdt"] = 1
+	for i := 0; i < 2; i++ {
+		_, err := unguarded.OpenLong("BTCUSDT", 0.01, 5)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, int32(4), placed.Load())
+}
+
+func TestWeexOpenDedupReleasedOnRejection(t *testing.T) {
+	tests := []struct {
+		name        string
+		reject      func(w http.ResponseWriter)
+		wantAPIErr  bool
+		wantRelease bool
+	}{
+		{
+			name: "4xx rejection",
+			reject: func(w http.ResponseWriter) {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, `{"code":"40015","msg":"Request too frequent"}`)
+			},
+			wantAPIErr:  true,
+			wantRelease: true,
+		},
+		{
+			name:        "business error with 200",
+			reject:      func(w http.ResponseWriter) { fmt.Fprint(w, `{"code":"40762","msg":"insufficient balance"}`) },
+			wantAPIErr:  true,
+			wantRelease: true,
+		},
+		{
+			name:   "5xx outcome unknown",
+			reject: func(w http.ResponseWriter) { w.WriteHeader(http.StatusBadGateway) },
+		},
+		{
+			name:   "lost response",
+			reject: func(w http.ResponseWriter) { panic(http.ErrAbortHandler) },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var placed atomic.Int32
+			trader, _ := newTestWeexTrader(t, func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/capi/v2/market/contracts":
+					fmt.Fprint(w, `[{"symbol":"cmt_btcusdt","minOrderSize":"0.001"}]`)
+				case "/capi/v2/order/placeOrder":
+					if placed.Add(1) == 1 {
+						tt.reject(w)
+						return
+					}
+					fmt.Fprint(w, `{"order_id":"42"}`)
+				default:
+					fmt.Fprint(w, `[]`)
+				}
+			}, WithWeexOpenDedupWindow(time.Minute))
+			trader.marginModeCache["cmt_btcusdt"] = 1
+
+			_, err := trader.OpenLong("BTCUSDT", 0.01, 5)
+			var orderErr *WeexOrderError
+			require.ErrorAs(t, err, &orderErr)
+			var apiErr *WeexAPIError
+			assert.Equal(t, tt.wantAPIErr, errors.As(err, &apiErr))
+
+			// A rejected open can be retried at once; an open with an unknown outcome stays reserved
+			_, err = trader.OpenLong("BTCUSDT", 0.01, 5)
+			if tt.wantRelease {
+				require.NoError(t, err)
+				assert.Equal(t, int32(2), placed.Load())
+			} else {
+				assert.ErrorIs(t, err, ErrWeexDuplicateOpen)
+				assert.Equal(t, int32(1), placed.Load())
+			}
+		})
+	}
+}
+
+// newTriggerTypeTestWeexTrader mocks a BTC long with the given plan orders and records plan order bodies
+func newTriggerTypeTestWeexTrader(t *testing.T, plans string, opts ...WeexOption) (*WeexTrader, func() []map[string]interface{}) {
+	var (
+		mu     sync.Mutex
+		placed []map[string]interface{}
+	)
+	trader, _ := newTestWeexTrader(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch r.URL.Path {
+		case "/capi/v2/account/position/allPosition":
+			fmt.Fprint(w, `[{"symbol":"cmt_btcusdt","side":"LONG","size":"0.5","leverage":"10","open_value":"50"}]`)
+		case "/capi/v2/market/ticker":
+			fmt.Fprint(w, `{"last":"100"}`)
+		case "/capi/v2/market/contracts":
+			fmt.Fprint(w, `[{"symbol":"cmt_btcusdt","minOrderSize":"0.001","tick_size":"1","priceEndStep":1}]`)
+		case "/capi/v2/order/currentPlan":
+			fmt.Fprint(w, plans)
+		case "/capi/v2/order/plan_order":
+			var body map[string]interface{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			placed = append(placed, body)
+			fmt.Fprint(w, `{"order_id":"plan-1"}`)
+		default:
+			fmt.Fprint(w, `[]`)
+		}
+	}, opts...)
+	trader.marginModeCache["cmt_btcusdt"] = 1
+
+	return trader, func() []map[string]interface{} {
+		mu.Lock()
+		defer mu.Unlock()
+		return placed
+	}
+}
+
+func TestWeexStopOrdersSendTriggerPriceType(t *testing.T) {
+	trader, placed := newTriggerTypeTestWeexTrader(t, `[]`)
+
+	require.NoError(t, trader.SetStopLoss("BTCUSDT", "LONG", 0.5, 90))
+	require.NoError(t, trader.SetTakeProfitWithTrigger("BTCUSDT", "LONG", 0.5, 120, WeexTriggerIndexPrice))
+	require.NoError(t, trader.SetStopLossWithTrigger("BTCUSDT", "LONG", 0.5, 91, WeexTriggerLastPrice))
+
+	bodies := placed()
+	require.Len(t, bodies, 3)
+	assert.Equal(t, "mark", bodies[0]["trigger_price_type"], "stops default to the mark price")
+	assert.Equal(t, "index", bodies[1]["trigger_price_type"])
+	assert.Equal(t, "last", bodies[2]["trigger_price_type"])
+
+	err := trader.SetStopLossWithTrigger("BTCUSDT", "LONG", 0.5, 90, "bid")
+	require.Error(t, err)
+	assert.Len(t, placed(), 3, "an unknown trigger type must not place an order")
+
+	// The default can be switched per trader
+	trader, placed = newTriggerTypeTestWeexTrader(t, `[]`, WithWeexTriggerPriceType(WeexTriggerLastPrice))
+	require.NoError(t, trader.SetTakeProfit("BTCUSDT", "LONG", 0.5, 120))
+	require.Len(t, placed(), 1)
+	assert.Equal(t, "last", placed()[0]["trigger_price_type"])
+}
+
+func TestWeexGetPositionsReportsTriggerPriceType(t *testing.T) {
+	trader, _ := newTriggerTypeTestWeexTrader(t, `[
+		{"order_id":"sl","type":"CLOSE_LONG","status":"UNTRIGGERED","triggerPrice":"90","triggerPriceType":"MARK"},
+		{"order_id":"tp","type":"CLOSE_LONG","status":"UNTRIGGERED","trigger_price":"120","trigger_price_type":"index"}
+	]`)
+
+	positions, err := trader.GetPositions()
+	require.NoError(t, err)
+	require.Len(t, positions, 1)
+	assert.Equal(t, 90.0, positions[0]["stop_loss"])
+	assert.Equal(t, "mark", positions[0]["stop_loss_trigger_type"])
+	assert.Equal(t, 120.0, positions[0]["take_profit"])
+	assert.Equal(t, "index", positions[0]["take_profit_trigger_type"])
+}
+
+func TestWeexGetClosedPnLAggregatesRoundTrips(t *testing.T) {
+	// Times are minutes past 2024-01-01 00:00 UTC
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(minute int) int64 { return base.Add(time.Duration(minute) * time.Minute).UnixMilli() }
+
+	fills := []map[string]interface{}{
+		// BTC long opened in two fills, closed in two pieces
+		{"tradeId": 1, "symbol": "cmt_btcusdt", "direction": "OPEN_LONG", "fillSize": "1", "fillValue": "100", "fillFee": "0.1", "realizePnl": "0", "createdTime": at(0)},
+		{"tradeId": 2, "symbol": "cmt_btcusdt", "direction": "OPEN_LONG", "fillSize": "1", "fillValue": "110", "fillFee": "0.1", "realizePnl": "0", "createdTime": at(5)},
+		{"tradeId": 3, "symbol": "cmt_btcusdt", "direction": "CLOSE_LONG", "fillSize": "1.5", "fillValue": "180", "fillFee": "0.2", "realizePnl": "22.5", "createdTime": at(30)},
+		{"tradeId": 4, "symbol": "cmt_btcusdt", "direction": "CLOSE_LONG", "fillSize": "0.5", "fillValue": "65", "fillFee": "0.1", "realizePnl": "12.5", "createdTime": at(60)},
+		// ETH short closed at breakeven: no PnL, only the direction marks it as a close
+		{"tradeId": 5, "symbol": "cmt_ethusdt", "direction": "OPEN_SHORT", "fillSize": "2", "fillValue": "4000", "fillFee": "0.4", "realizePnl": "0", "createdTime": at(10)},
+		{"tradeId": 6, "symbol": "cmt_ethusdt", "direction": "CLOSE_SHORT", "fillSize": "2", "fillValue": "4000", "fillFee": "0.4", "realizePnl": "0", "createdTime": at(20)},
+		// SOL close whose opening fill is before the queried window
+		{"tradeId": 7, "symbol": "cmt_solusdt", "direction": "CLOSE_LONG", "fillSize": "10", "fillValue": "1000", "fillFee": "0.5", "realizePnl": "50", "createdTime": at(40)},
+	}
+
+	trader, _ := newTestWeexTrader(t, func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/capi/v2/order/fills", r.URL.Path)
+		json.NewEncoder(w).Encode(map[string]interface{}{"list": fills})
+	})
+
+	records, err := trader.GetClosedPnL(base, 100)
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+
+	eth, sol, btc := records[0], records[1], records[2]
+
+	assert.Equal(t, "cmt_ethusdt", eth.Symbol)
+	assert.Equal(t, "short", eth.Side)
+	assert.InDelta(t, 2000, eth.EntryPrice, 1e-9)
+	assert.InDelta(t, 0.8, eth.Fee, 1e-9)
+	assert.Equal(t, 10*time.Minute, eth.HoldingDuration())
+
+	assert.Equal(t, "long", sol.Side)
+	assert.InDelta(t, 95, sol.EntryPrice, 1e-9, "entry price falls back to exit price - PnL per unit")
+
+	assert.Equal(t, "cmt_btcusdt", btc.Symbol)
+	assert.Equal(t, "long", btc.Side)
+	assert.InDelta(t, 105, btc.EntryPrice, 1e-9)
+	assert.InDelta(t, 122.5, btc.ExitPrice, 1e-9)
+	assert.InDelta(t, 2, btc.Quantity, 1e-9)
+	assert.InDelta(t, 35, btc.RealizedPnL, 1e-9)
+	assert.InDelta(t, 0.5, btc.Fee, 1e-9)
+	assert.Equal(t, base, btc.EntryTime.UTC())
+	assert.Equal(t, time.Hour, btc.HoldingDuration())
+	assert.Equal(t, "4", btc.OrderID)
+}
+
+func TestWeexGetPnLSummaryPagesThroughFills(t *testing.T) {
+	// Times are minutes past 2024-01-01 00:00 UTC; the window is that day
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(minute int) int64 { return base.Add(time.Duration(minute) * time.Minute).UnixMilli() }
+	fill := func(id int, symbol, direction, size, value, fee, pnl string, minute int) map[string]interface{} {
+		return map[string]interface{}{"tradeId": id, "symbol": symbol, "direction": direction, "fillSize": size,
+			"fillValue": value, "fillFee": fee, "realizePnl": pnl, "createdTime": at(minute)}
+	}
+
+	fills := []map[string]interface{}{
+		// BTC long: loss of 20 before 0.4 fees
+		fill(1, "cmt_btcusdt", "OPEN_LONG", "1", "100", "0.2", "0", 0),
+		fill(2, "cmt_btcusdt", "CLOSE_LONG", "1", "80", "0.2", "-20", 10),
+		// ETH short: gain of 30 before 0.2 fees
+		fill(3, "cmt_ethusdt", "OPEN_SHORT", "1", "2000", "0.1", "0", 20),
+		fill(4, "cmt_ethusdt", "CLOSE_SHORT", "1", "1970", "0.1", "30", 30),
+	}
+	// 60 SOL round trips of 1 before 0.1 fees each: 120 fills span two pages
+	for i := 0; i < 60; i++ {
+		fills = append(fills,
+			fill(100+2*i, "cmt_solusdt", "OPEN_LONG", "1", "100", "0.05", "0", 40+2*i),
+			fill(101+2*i, "cmt_solusdt", "CLOSE_LONG", "1", "101", "0.05", "1", 41+2*i))
+	}
+	// Opened late in the day and still open: its fee is paid within the window
+	fills = append(fills, fill(998, "cmt_xrpusdt", "OPEN_LONG", "10", "5", "0.3", "0", 23*60))
+	// Closed at the end of the window, which belongs to the next day
+	fills = append(fills, fill(999, "cmt_btcusdt", "CLOSE_LONG", "1", "200", "0.2", "100", 24*60))
+
+	var pages atomic.Int32
+	trader, _ := newTestWeexTrader(t, func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/capi/v2/order/fills", r.URL.Path)
+		pages.Add(1)
+		query := r.URL.Query()
+		start, _ := strconv.ParseInt(query.Get("startTime"), 10, 64)
+		end, _ := strconv.ParseInt(query.Get("endTime"), 10, 64)
+		limit, _ := strconv.Atoi(query.Get("limit"))
+
+		page := []map[string]interface{}{}
+		for _, f := range fills {
+			if created := f["createdTime"].(int64); created >= start && created <= end && len(page) < limit {
+				page = append(page, f)
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"list": page})
+	}, WithWeexLogger(&recordingWeexLogger{}))
+
+	summary, err := trader.GetPnLSummary(base, base.Add(24*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), pages.Load())
+
+	assert.Equal(t, 62, summary.Trades)
+	assert.Equal(t, 61, summary.Wins)
+	assert.Equal(t, 1, summary.Losses)
+	assert.InDelta(t, 70, summary.GrossPnL, 1e-9)
+	assert.InDelta(t, 6.9, summary.Fees, 1e-9)
+	assert.InDelta(t, 63.1, summary.NetPnL, 1e-9)
+
+	require.Len(t, summary.BySymbol, 4)
+	xrp := summary.BySymbol["XRPUSDT"]
+	assert.Equal(t, 0, xrp.Trades)
+	assert.InDelta(t, 0.3, xrp.Fees, 1e-9)
+	assert.InDelta(t, -0.3, xrp.NetPnL, 1e-9)
+	assert.Equal(t, 1, summary.BySymbol["BTCUSDT"].Losses)
+	assert.InDelta(t, -20.4, summary.BySymbol["BTCUSDT"].NetPnL, 1e-9)
+	assert.Equal(t, 1, summary.BySymbol["ETHUSDT"].Wins)
+	assert.InDelta(t, 29.8, summary.BySymbol["ETHUSDT"].NetPnL, 1e-9)
+	sol := summary.BySymbol["SOLUSDT"]
+	assert.Equal(t, 60, sol.Trades)
+	assert.Equal(t, 60, sol.Wins)
+	assert.InDelta(t, 60, sol.GrossPnL, 1e-9)
+	assert.InDelta(t, 6, sol.Fees, 1e-9)
+	assert.InDelta(t, 54, sol.NetPnL, 1e-9)
+}
+
+func TestAggregateRoundTripsReportsPartialClose(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	records := AggregateRoundTrips([]TradeRecord{
+		{TradeID: "2", Symbol: "BTCUSDT", PositionSide: "LONG", Action: "close", Price: 120, Quantity: 1, RealizedPnL: 20, Fee: 0.1, Time: base.Add(time.Hour)},
+		{TradeID: "1", Symbol: "BTCUSDT", PositionSide: "LONG", Action: "open", Price: 100, Quantity: 4, Fee: 0.4, Time: base},
+	})
+
+	require.Len(t, records, 1)
+	assert.InDelta(t, 1, records[0].Quantity, 1e-9)
+	assert.InDelta(t, 100, records[0].EntryPrice, 1e-9)
+	assert.InDelta(t, 0.2, records[0].Fee, 1e-9, "a quarter of the opening fee is charged to the closed quarter")
+	assert.Equal(t, time.Hour, records[0].HoldingDuration())
+}
+
+// newPingTestWeexTrader serves server time at the given offset from now and answers the
+// authenticated assets request with the given status and body
+func newPingTestWeexTrader(t *testing.T, offset time.Duration, assetsStatus int, assetsBody string) *WeexTrader {
+	trader, _ := newTestWeexTrader(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/capi/v2/market/time":
+			fmt.Fprintf(w, `{"timestamp":%d}`, time.Now().Add(offset).UnixMilli())
+		case "/capi/v2/account/assets":
+			w.WriteHeader(assetsStatus)
+			io.WriteString(w, assetsBody)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			http.NotFound(w, r)
+		}
+	}, WithWeexLogger(&recordingWeexLogger{}))
+	return trader
+}
+
+func TestWeexPingFlagsAuthFailure(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+	}{
+		{"http 401", http.StatusUnauthorized, `{"code":"40001","msg":"Invalid ACCESS_KEY"}`},
+		{"wrapped error code", http.StatusOK, `{"code":"40012","msg":"Invalid ACCESS_PASSPHRASE","data":null}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trader := newPingTestWeexTrader(t, 0, tt.status, tt.body)
+
+			report := trader.Ping()
+			assert.True(t, report.Reachable, "server time is public and still reachable")
+			assert.NoError(t, report.ConnError)
+			assert.False(t, report.ClockSkewed)
+			assert.False(t, report.Authenticated)
+			require.Error(t, report.AuthError)
+			assert.Contains(t, report.AuthError.Error(), "400")
+			assert.False(t, report.Healthy())
+			assert.ErrorIs(t, report.Err(), report.AuthError)
+		})
+	}
+}
+
+func TestWeexPingHealthyAndClockSkew(t *testing.T) {
+	assets := `[{"coinName":"USDT","equity":"1250.5","available":"1000","unrealizePnl":"0"}]`
+
+	report := newPingTestWeexTrader(t, 0, http.StatusOK, assets).Ping()
+	assert.True(t, report.Healthy(), "unexpected problems: %v", report.Err())
+	assert.NoError(t, report.Err())
+	assert.Equal(t, 1250.5, report.Equity)
+	assert.Positive(t, report.Latency)
+	assert.Less(t, report.ClockOffset.Abs(), time.Second)
+
+	// A clock a minute behind the server authenticates but is not safe to trade with
+	report = newPingTestWeexTrader(t, time.Minute, http.StatusOK, assets).Ping()
+	assert.True(t, report.Authenticated)
+	assert.True(t, report.ClockSkewed)
+	assert.InDelta(t, time.Minute.Seconds(), report.ClockOffset.Seconds(), 1)
+	assert.False(t, report.Healthy())
+	assert.Error(t, report.Err())
+
+	// Unreachable time endpoint
+	trader, _ := newTestWeexTrader(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/capi/v2/market/time" {
+			http.Error(w, "bad gateway", http.StatusBadGateway)
+			return
+		}
+		io.WriteString(w, assets)
+	}, WithWeexLogger(&recordingWeexLogger{}))
+	report = trader.Ping()
+	assert.False(t, report.Reachable)
+	assert.Error(t, report.ConnError)
+	assert.True(t, report.Authenticated, "the credential check runs independently")
+	assert.False(t, report.Healthy())
+}
+
+// Run with -race: Start, the sync loop and concurrent Close calls share the lifecycle state
+func TestWeexStartCloseStopsBackgroundTasks(t *testing.T) {
+	var syncs atomic.Int32
+	trader, _ := newTestWeexTrader(t, func(w http.ResponseWriter, r *http.Request) {
+		syncs.Add(1)
+		fmt.Fprintf(w, `{"timestamp":%d}`, time.Now().Add(time.Hour).UnixMilli())
+	}, WithWeexLogger(&recordingWeexLogger{}), WithWeexClockSyncInterval(5*time.Millisecond))
+
+	// Warm up the keep-alive connection so its goroutines are part of the baseline
+	_, err := trader.getServerTime()
+	require.NoError(t, err)
+	baseline := runtime.NumGoroutine()
+
+	require.NoError(t, trader.Start(context.Background()))
+	assert.Error(t, trader.Start(context.Background()), "a second Start must be rejected")
+	assert.Eventually(t, func() bool { return syncs.Load() >= 4 }, time.Second, time.Millisecond)
+	// Signing timestamps follow the server clock
+	assert.InDelta(t, time.Hour.Seconds(), time.Until(trader.now()).Seconds(), 1)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, trader.Close())
+		}()
+	}
+	wg.Wait()
+	assert.NoError(t, trader.Close())
+	assert.Error(t, trader.Start(context.Background()), "a closed trader cannot be restarted")
+
+	stopped := syncs.Load()
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, stopped, syncs.Load(), "the clock sync must stop with Close")
+	// Polled by hand: assert.Eventually runs its condition on an extra goroutine
+	for deadline := time.Now().Add(time.Second); runtime.NumGoroutine() > baseline && time.Now().Before(deadline); {
+		time.Sleep(5 * time.Millisecond)
+	}
+	assert.LessOrEqual(t, runtime.NumGoroutine(), baseline, "goroutines leaked after Close")
+
+	// Cancelling the Start context also stops the background tasks
+	trader, _ = newTestWeexTrader(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"timestamp":%d}`, time.Now().UnixMilli())
+	}, WithWeexLogger(&recordingWeexLogger{}), WithWeexClockSyncInterval(5*time.Millisecond))
+	ctx, cancel := context.WithCancel(context.Background())
+	require.NoError(t, trader.Start(ctx))
+	cancel()
+	done := make(chan struct{})
+	go func() {
+		trader.backgroundWG.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("background tasks still running after the Start context was cancelled")
+	}
+	assert.NoError(t, trader.Close())
+}
+
+// weexDepthFixture is a BTC book around 100: asks 100.5 / 101 / 102, bids 99.5 / 99 / 97
+const weexDepthFixture = `{
+	"asks":[["101","2"],["100.5","1"],["102","5"]],
+	"bids":[["99.5","1.5"],[99,"2.5"],["97","10"],["0","3"]],
+	"timestamp":"1716710918113"
+}`
+
+func TestWeexGetOrderBook(t *testing.T) {
+	var query string
+	trader, _ := newTestWeexTrader(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/capi/v2/market/depth", r.URL.Path)
+		query = r.URL.RawQuery
+		io.WriteString(w, weexDepthFixture)
+	}, WithWeexLogger(&recordingWeexLogger{}))
+
+	book, err := trader.GetOrderBook("BTCUSDT", 2)
+	require.NoError(t, err)
+	assert.Equal(t, "symbol=cmt_btcusdt&limit=15", query, "depth is rounded up to a supported limit")
+	assert.Equal(t, []WeexBookLevel{{100.5, 1}, {101, 2}}, book.Asks, "asks sorted best first and truncated")
+	assert.Equal(t, []WeexBookLevel{{99.5, 1.5}, {99, 2.5}}, book.Bids)
+	assert.Equal(t, int64(1716710918113), book.Timestamp.UnixMilli())
+
+	book, err = trader.GetOrderBook("BTCUSDT", 50)
+	require.NoError(t, err)
+	assert.Equal(t, "symbol=cmt_btcusdt&limit=200", query)
+	assert.Len(t, book.Bids, 3, "empty levels are dropped")
+}
+
+func TestWeexEstimateMarketFillPriceWalksBook(t *testing.T) {
+	trader, _ := newTestWeexTrader(t, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, weexDepthFixture)
+	}, WithWeexLogger(&recordingWeexLogger{}))
+
+	tests := []struct {
+		name       string
+		side       string
+		quantity   float64
+		avgPrice   float64
+		worstPrice float64
+		filled     float64
+		complete   bool
+	}{
+		// Within the first level: no slippage
+		{"buy top of book", "buy", 0.5, 100.5, 100.5, 0.5, true},
+		// 1 @ 100.5 + 2 @ 101 + 1 @ 102 = 404.5 over 4
+		{"buy through three levels", "long", 4, 404.5 / 4, 102, 4, true},
+		// 1.5 @ 99.5 + 2.5 @ 99 + 1 @ 97 = 493.75 over 5
+		{"sell through three levels", "sell", 5, 493.75 / 5, 97, 5, true},
+		// Only 8 available on the ask side: 100.5 + 202 + 510 = 812.5 over 8
+		{"buy beyond the book", "buy", 10, 812.5 / 8, 102, 8, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			estimate, err := trader.EstimateMarketFillPrice("BTCUSDT", tt.side, tt.quantity)
+			require.NoError(t, err)
+			assert.InDelta(t, tt.avgPrice, estimate.AvgPrice, 1e-9)
+			assert.Equal(t, tt.worstPrice, estimate.WorstPrice)
+			assert.InDelta(t, tt.filled, estimate.FilledQuantity, 1e-9)
+			assert.Equal(t, tt.complete, estimate.Complete)
+			assert.InDelta(t, math.Abs(tt.avgPrice-estimate.BestPrice)/estimate.BestPrice*100, estimate.SlippagePct, 1e-9)
+		})
+	}
+
+	_, err := trader.EstimateMarketFillPrice("BTCUSDT", "hold", 1)
+	assert.Error(t, err)
+	_, err = trader.EstimateMarketFillPrice("BTCUSDT", "buy", 0)
+	assert.Error(t, err)
+}
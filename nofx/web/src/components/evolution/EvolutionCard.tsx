@@ -132,11 +132,11 @@ export default function EvolutionCard({
             {/* Current Status Display */}
             {evolution.status === 'running' && (() => {
               const currentIter = evolution.iterations.find(
-                (iter) => ['backtest', 'evaluating', 'optimizing'].includes(iter.status)
+                (iter) => ['backtesting', 'evaluating', 'optimizing'].includes(iter.status)
               )
               if (currentIter) {
                 const statusConfig: Record<string, { text: string; color: string }> = {
-                  backtest: { text: 'Backtesting...', color: 'text-blue-600' },
+                  backtesting: { text: 'Backtesting...', color: 'text-blue-600' },
                   evaluating: { text: 'AI Evaluating...', color: 'text-purple-600' },
                   optimizing: { text: 'AI Optimizing...', color: 'text-orange-600' },
                 }
@@ -165,7 +165,7 @@ export default function EvolutionCard({
                         : 'bg-red-100 text-red-700'
                       : iter.status === 'failed'
                       ? 'bg-red-100 text-red-700'
-                      : ['backtest', 'evaluating', 'optimizing'].includes(iter.status)
+                      : ['backtesting', 'evaluating', 'optimizing'].includes(iter.status)
                       ? 'bg-blue-100 text-blue-700'
                       : 'bg-gray-100 text-gray-500'
                   }`}
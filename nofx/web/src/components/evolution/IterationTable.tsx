@@ -35,7 +35,7 @@ export default function IterationTable({
   const getStatusBadge = (status: string) => {
     const colors: Record<string, string> = {
       pending: 'bg-gray-100 text-gray-800',
-      backtest: 'bg-blue-100 text-blue-800',
+      backtesting: 'bg-blue-100 text-blue-800',
       evaluating: 'bg-purple-100 text-purple-800',
       optimizing: 'bg-yellow-100 text-yellow-800',
       completed: 'bg-green-100 text-green-800',